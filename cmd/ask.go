@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// askColumns are the columns of the CSV file written by ask --batch
+var askColumns = []string{"id", "question", "answer", "sources", "error"}
+
+var askCmd = &cobra.Command{
+	Use:         "ask [collection-id-or-name] [question]",
+	Short:       "Answer questions from a collection without a chat session",
+	Annotations: requires(config.RequireDatabase),
+	Long: `Answer a question, or a CSV file of questions, from a collection.
+
+Each question is answered on its own with the same retrieval and prompts as
+the chat command, and the answer is followed by the documents it is based on.
+The answer cites the documents by their number in that list, such as [1],
+unless --no-citations is given. With --output json, the answer and its
+documents are printed as JSON.
+
+Batch mode (--batch) answers every row of a CSV file and writes the answers
+to another CSV file (--out) as they are generated. The questions are read
+from the "question" column, and rows are identified by the "id" column if
+there is one, or by their row number. Files without a header use the first
+column as the question.
+
+The output file has the columns id, question, answer, sources and error.
+Batches are resumable: when the output file already exists, the rows that
+were answered are kept and skipped, and the rows that failed are retried.
+Interrupt a batch with Ctrl-C and run the same command again to continue.
+
+Use --rate to limit how many questions are asked per minute, for backends
+with rate limits.
+
+With --abstain, questions whose documents all score below --min-score are
+answered with the abstention message and the closest documents instead of
+the model's guess.
+
+Text that isn't indexed, such as a draft to check against the collection,
+can be searched along with the collection with --context-file, or with
+--context as text or "-" to read it from stdin. Its chunks are embedded and
+ranked with the retrieved documents by similarity to the question, within
+the same --limit and --max-tokens, and listed with the sources.
+
+Examples:
+  # Answer one question
+  rag-cli ask my-docs "How do I rotate the database password?"
+
+  # Answer briefly, as a concise engineer
+  rag-cli ask my-docs "How do I rotate the database password?" --persona engineer
+
+  # Compare a draft with the documents of the collection
+  rag-cli ask my-docs "Does this draft contradict our docs?" --context-file draft.md
+
+  # Ask about text from the clipboard
+  pbpaste | rag-cli ask my-docs "Which of our services does this log mention?" --context -
+
+  # Answer every question in a CSV file
+  rag-cli ask my-docs --batch questions.csv --out answers.csv
+
+  # Ask at most 20 questions a minute, 2 at a time
+  rag-cli ask my-docs --batch questions.csv --out answers.csv --rate 20 --concurrency 2`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if batch, _ := cmd.Flags().GetString("batch"); batch != "" {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		batch, _ := cmd.Flags().GetString("batch")
+		out, _ := cmd.Flags().GetString("out")
+		rate, _ := cmd.Flags().GetFloat64("rate")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+
+		if batch != "" && out == "" {
+			return fmt.Errorf("--out is required with --batch")
+		}
+		if concurrency < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
+		}
+		if rate < 0 {
+			return fmt.Errorf("--rate cannot be negative")
+		}
+		if provided, _ := cmd.Flags().GetString("context"); provided == "-" && batch == "-" {
+			return fmt.Errorf("--context and --batch cannot both be read from stdin")
+		}
+
+		session, collection, err := newChatSession(cmd, args[0])
+		if err != nil {
+			return err
+		}
+
+		// Provided text is searched along with the collection for every
+		// question
+		session.provided, err = readProvidedContext(context.Background(), cmd, session.embeddingService)
+		if err != nil {
+			return err
+		}
+
+		if batch == "" {
+			answer, sources, err := askQuestion(context.Background(), session, args[1])
+			if err != nil {
+				return err
+			}
+			return printAnswer(args[1], answer, sources, session.citations)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		output.Success("Answering questions from collection: %s", collection.Name)
+		return runBatchAsk(ctx, session, batch, out, rate, concurrency)
+	},
+}
+
+// askRow is a question of a batch
+type askRow struct {
+	ID       string
+	Question string
+}
+
+// askQuestion answers a question on its own, returning the answer and the
+// documents it is based on
+func askQuestion(ctx context.Context, template *chatSession, question string) (string, []*database.SearchResult, error) {
+	// Each question gets its own conversation, so they can be asked at once
+	session := *template
+	session.conversation = nil
+
+	answer, err := session.answer(ctx, question, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSpace(answer), session.lastResults, nil
+}
+
+// runBatchAsk answers the questions of a CSV file and appends the answers to
+// the output file as they are generated, skipping the questions it already
+// answered
+func runBatchAsk(ctx context.Context, session *chatSession, inputPath, outputPath string, rate float64, concurrency int) error {
+	rows, err := readAskRows(inputPath)
+	if err != nil {
+		return err
+	}
+
+	answered, err := prepareAskOutput(outputPath)
+	if err != nil {
+		return err
+	}
+
+	var pending []askRow
+	for _, row := range rows {
+		if !answered[row.ID] {
+			pending = append(pending, row)
+		}
+	}
+	if skipped := len(rows) - len(pending); skipped > 0 {
+		output.Info("Skipping %d questions already answered in %s", skipped, outputPath)
+	}
+	if len(pending) == 0 {
+		output.Success("All %d questions are answered", len(rows))
+		return nil
+	}
+
+	file, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+
+	// Questions are started at most rate times a minute
+	var ticks <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Minute) / rate))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	jobs := make(chan askRow)
+	records := make(chan []string)
+	var workers sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for row := range jobs {
+				answer, sources, err := askQuestion(ctx, session, row.Question)
+				errText := ""
+				if err != nil {
+					errText = err.Error()
+				}
+				records <- []string{row.ID, row.Question, answer, strings.Join(answerSources(sources, session.citations), "; "), errText}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i, row := range pending {
+			if ticks != nil && i > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticks:
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- row:
+			}
+		}
+	}()
+	go func() {
+		workers.Wait()
+		close(records)
+	}()
+
+	// Write each answer as soon as it is generated, so an interrupted batch
+	// loses at most the questions in flight
+	done, failed := 0, 0
+	var writeErr error
+	for record := range records {
+		if ctx.Err() != nil && record[4] != "" {
+			// Interrupted; the question is asked again on the next run
+			continue
+		}
+		if writeErr != nil {
+			continue
+		}
+		writer.Write(record)
+		writer.Flush()
+		if writeErr = writer.Error(); writeErr != nil {
+			continue
+		}
+
+		done++
+		if record[4] != "" {
+			failed++
+			output.Warning("[%d/%d] Question %s failed: %s", done, len(pending), record[0], record[4])
+		} else {
+			output.Info("[%d/%d] Answered question %s", done, len(pending), record[0])
+		}
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to write answers: %w", writeErr)
+	}
+
+	if ctx.Err() != nil {
+		output.Warning("Interrupted after %d of %d questions; run the same command again to continue", done, len(pending))
+		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d questions failed; run the same command again to retry them", failed, len(pending))
+	}
+	output.Success("Answered %d questions into %s", done, outputPath)
+	return nil
+}
+
+// readAskRows reads the questions of a CSV file, or of stdin when path is "-"
+func readAskRows(path string) ([]askRow, error) {
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open questions: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read questions: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no questions to answer")
+	}
+
+	// Use the header when there is a question column
+	questionColumn, idColumn := -1, -1
+	for i, name := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "question":
+			questionColumn = i
+		case "id":
+			idColumn = i
+		}
+	}
+	if questionColumn >= 0 {
+		records = records[1:]
+	} else {
+		questionColumn, idColumn = 0, -1
+	}
+
+	var rows []askRow
+	seen := make(map[string]bool)
+	for i, record := range records {
+		if questionColumn >= len(record) || strings.TrimSpace(record[questionColumn]) == "" {
+			continue
+		}
+
+		id := strconv.Itoa(i + 1)
+		if idColumn >= 0 && idColumn < len(record) && strings.TrimSpace(record[idColumn]) != "" {
+			id = strings.TrimSpace(record[idColumn])
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("question id %s is used more than once", id)
+		}
+		seen[id] = true
+
+		rows = append(rows, askRow{ID: id, Question: strings.TrimSpace(record[questionColumn])})
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no questions to answer")
+	}
+	return rows, nil
+}
+
+// prepareAskOutput creates the output file of a batch, or keeps the answered
+// rows of an existing one and drops the failed ones so they are retried. It
+// returns the IDs of the answered questions.
+func prepareAskOutput(path string) (map[string]bool, error) {
+	answered := make(map[string]bool)
+
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(existing) == 0) {
+		return answered, writeAskOutput(path, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(existing))).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(askColumns, ",") {
+		return nil, fmt.Errorf("%s exists but wasn't written by ask --batch; choose another --out file", path)
+	}
+
+	var kept [][]string
+	for _, record := range records[1:] {
+		if record[4] == "" {
+			answered[record[0]] = true
+			kept = append(kept, record)
+		}
+	}
+	if len(kept) < len(records)-1 {
+		if err := writeAskOutput(path, kept); err != nil {
+			return nil, err
+		}
+	}
+	return answered, nil
+}
+
+// writeAskOutput replaces the output file with the header and the given rows
+func writeAskOutput(path string, records [][]string) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	writer := csv.NewWriter(temp)
+	writer.Write(askColumns)
+	writer.WriteAll(records)
+	if err := writer.Error(); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	addChatFlags(askCmd)
+	askCmd.Flags().String("batch", "", "CSV file of questions to answer ('-' for stdin)")
+	askCmd.Flags().StringP("out", "o", "", "CSV file to write the answers of a batch to; an existing file is resumed")
+	askCmd.Flags().Float64("rate", 0, "Maximum number of questions asked per minute (0 = unlimited)")
+	askCmd.Flags().Int("concurrency", 1, "Number of questions answered at once")
+	askCmd.Flags().String("context", "", "Text to answer from along with the collection, without indexing it ('-' for stdin)")
+	askCmd.Flags().StringArray("context-file", nil, "File to answer from along with the collection, without indexing it (repeatable)")
+	rootCmd.AddCommand(askCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/spf13/cobra"
+)
+
+// providedDocumentPrefix starts the IDs of the chunks of text given with
+// --context and --context-file, which aren't stored in the collection
+const providedDocumentPrefix = "provided:"
+
+// providedText is text given on the command line to answer from, with the
+// name it is shown under
+type providedText struct {
+	name string
+	text string
+}
+
+// readProvidedContext reads the text given with --context and --context-file
+// and splits it into embedded chunks, which are ranked with the retrieved
+// documents of every question without being indexed
+func readProvidedContext(ctx context.Context, cmd *cobra.Command, service *embedding.Service) ([]*database.SearchResult, error) {
+	texts, err := readProvidedTexts(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*database.SearchResult
+	for _, provided := range texts {
+		if strings.TrimSpace(provided.text) == "" {
+			return nil, fmt.Errorf("context %s is empty", provided.name)
+		}
+		chunks, err := service.ChunkText(provided.text, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to chunk context %s: %w", provided.name, err)
+		}
+		if err := service.GenerateEmbeddings(ctx, chunks); err != nil {
+			return nil, fmt.Errorf("failed to embed context %s: %w", provided.name, err)
+		}
+		for _, chunk := range chunks {
+			results = append(results, &database.SearchResult{
+				Document: &database.Document{
+					ID:         fmt.Sprintf("%s%s#%d", providedDocumentPrefix, provided.name, chunk.Index),
+					FilePath:   provided.name,
+					FileName:   filepath.Base(provided.name) + " (provided by the user)",
+					Content:    chunk.Content,
+					ChunkIndex: chunk.Index,
+					Embedding:  chunk.Embedding,
+				},
+			})
+		}
+	}
+	return results, nil
+}
+
+// readProvidedTexts reads the text of --context, from stdin when it is "-",
+// and of the files of --context-file
+func readProvidedTexts(cmd *cobra.Command) ([]providedText, error) {
+	var texts []providedText
+	if text, _ := cmd.Flags().GetString("context"); text == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read context from stdin: %w", err)
+		}
+		texts = append(texts, providedText{name: "stdin", text: string(data)})
+	} else if text != "" {
+		texts = append(texts, providedText{name: "context", text: text})
+	}
+
+	files, _ := cmd.Flags().GetStringArray("context-file")
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read context file: %w", err)
+		}
+		texts = append(texts, providedText{name: path, text: string(data)})
+	}
+	return texts, nil
+}
+
+// isProvidedDocument reports whether a document is a chunk of text given on
+// the command line rather than a document of the collection
+func isProvidedDocument(doc *database.Document) bool {
+	return strings.HasPrefix(doc.ID, providedDocumentPrefix)
+}
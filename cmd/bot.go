@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/busybytelab.com/rag-cli/pkg/bot"
+	"github.com/busybytelab.com/rag-cli/pkg/bot/discord"
+	"github.com/busybytelab.com/rag-cli/pkg/bot/slack"
+	"github.com/busybytelab.com/rag-cli/pkg/bot/telegram"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var botCmd = &cobra.Command{
+	Use:         "bot",
+	Short:       "Answer questions from chat platforms",
+	Annotations: requires(config.RequireDatabase),
+	Long: `Run rag-cli as a chat bot that answers questions from a collection.
+
+Bots answer with the same retrieval and prompts as the chat command and
+list the documents each answer is based on. Slack, Telegram and Discord are
+supported; each has a section under bots in the configuration.
+
+Questions are answered from the collection of the channel they were asked
+in. Map channels, chats or Discord servers to collections under channels,
+and set collection for the rest:
+
+  bots:
+    discord:
+      token: ...
+      collection: docs           # Default collection
+      channels:
+        "1234567890": runbooks   # A channel or server ID`,
+}
+
+var botSlackCmd = &cobra.Command{
+	Use:   "slack [collection-id-or-name]",
+	Short: "Answer Slack mentions from a collection",
+	Long: `Run a Slack bot that answers mentions from a collection.
+
+The bot connects with Socket Mode, so it needs no public URL. Each mention is
+answered in its thread, followed by the sources the answer is based on.
+
+Create a Slack app with Socket Mode enabled, subscribe it to the app_mention
+bot event and give it these tokens:
+  app token  an app-level token (xapp-...) with connections:write
+  bot token  a bot token (xoxb-...) with app_mentions:read and chat:write
+
+The tokens and collections are read from the bots.slack section of the
+configuration. The tokens can also be given with the SLACK_APP_TOKEN and
+SLACK_BOT_TOKEN environment variables.
+
+Examples:
+  # Answer from the collections in the configuration
+  rag-cli bot slack
+
+  # Answer from a specific collection
+  SLACK_APP_TOKEN=xapp-... SLACK_BOT_TOKEN=xoxb-... rag-cli bot slack my-docs`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		slackConfig := slackBotConfig()
+		if len(args) > 0 {
+			slackConfig.Collection = args[0]
+		}
+		if !slackConfig.Configured() {
+			return fmt.Errorf("set bots.slack.app_token and bots.slack.bot_token, or SLACK_APP_TOKEN and SLACK_BOT_TOKEN")
+		}
+		if err := slackConfig.Validate(); err != nil {
+			return fmt.Errorf("slack configuration error: %w", err)
+		}
+		return runBots(newSlackConnector(slackConfig))
+	},
+}
+
+var botTelegramCmd = &cobra.Command{
+	Use:   "telegram [collection-id-or-name]",
+	Short: "Answer Telegram messages from a collection",
+	Long: `Run a Telegram bot that answers questions from a collection.
+
+The bot receives messages with long polling, so it needs no public URL. It
+answers every message in private chats, and in groups the messages that
+mention it or reply to it.
+
+Create a bot with @BotFather and give its token in the bots.telegram
+section of the configuration or with the TELEGRAM_BOT_TOKEN environment
+variable. Map group chat IDs (such as -1001234567890) to collections under
+bots.telegram.channels.
+
+Examples:
+  # Answer from the collections in the configuration
+  rag-cli bot telegram
+
+  # Answer from a specific collection
+  TELEGRAM_BOT_TOKEN=123456:ABC... rag-cli bot telegram my-docs`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		telegramConfig := telegramBotConfig()
+		if len(args) > 0 {
+			telegramConfig.Collection = args[0]
+		}
+		if !telegramConfig.Configured() {
+			return fmt.Errorf("set bots.telegram.token or TELEGRAM_BOT_TOKEN")
+		}
+		if err := telegramConfig.Validate(); err != nil {
+			return fmt.Errorf("telegram configuration error: %w", err)
+		}
+		return runBots(newTelegramConnector(telegramConfig))
+	},
+}
+
+var botDiscordCmd = &cobra.Command{
+	Use:   "discord [collection-id-or-name]",
+	Short: "Answer Discord messages from a collection",
+	Long: `Run a Discord bot that answers questions from a collection.
+
+The bot connects to the Discord Gateway and answers direct messages and the
+messages that mention it, replying to each one.
+
+Create an application in the Discord developer portal, enable the Message
+Content intent for its bot, and invite the bot with the Send Messages and
+Read Message History permissions. Give the bot token in the bots.discord
+section of the configuration or with the DISCORD_BOT_TOKEN environment
+variable. Channels and servers are mapped to collections by their ID under
+bots.discord.channels; a channel's mapping wins over its server's.
+
+Examples:
+  # Answer from the collections in the configuration
+  rag-cli bot discord
+
+  # Answer from a specific collection
+  DISCORD_BOT_TOKEN=... rag-cli bot discord my-docs`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		discordConfig := discordBotConfig()
+		if len(args) > 0 {
+			discordConfig.Collection = args[0]
+		}
+		if !discordConfig.Configured() {
+			return fmt.Errorf("set bots.discord.token or DISCORD_BOT_TOKEN")
+		}
+		if err := discordConfig.Validate(); err != nil {
+			return fmt.Errorf("discord configuration error: %w", err)
+		}
+		return runBots(newDiscordConnector(discordConfig))
+	},
+}
+
+var botRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run every configured bot",
+	Long: `Run the bots of every platform that has tokens in the configuration or
+the environment, sharing one database connection.
+
+Examples:
+  rag-cli bot run`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var connectors []botConnector
+		if slackConfig := slackBotConfig(); slackConfig.Configured() {
+			if err := slackConfig.Validate(); err != nil {
+				return fmt.Errorf("slack configuration error: %w", err)
+			}
+			connectors = append(connectors, newSlackConnector(slackConfig))
+		}
+		if telegramConfig := telegramBotConfig(); telegramConfig.Configured() {
+			if err := telegramConfig.Validate(); err != nil {
+				return fmt.Errorf("telegram configuration error: %w", err)
+			}
+			connectors = append(connectors, newTelegramConnector(telegramConfig))
+		}
+		if discordConfig := discordBotConfig(); discordConfig.Configured() {
+			if err := discordConfig.Validate(); err != nil {
+				return fmt.Errorf("discord configuration error: %w", err)
+			}
+			connectors = append(connectors, newDiscordConnector(discordConfig))
+		}
+		if len(connectors) == 0 {
+			return fmt.Errorf("no bot is configured; set the tokens of at least one bot under bots in the configuration")
+		}
+		return runBots(connectors...)
+	},
+}
+
+// slackBotConfig returns the Slack settings, with the tokens taken from the
+// environment when the configuration has none
+func slackBotConfig() config.SlackConfig {
+	slackConfig := cfg.Bots.Slack
+	if slackConfig.AppToken == "" {
+		slackConfig.AppToken = os.Getenv("SLACK_APP_TOKEN")
+	}
+	if slackConfig.BotToken == "" {
+		slackConfig.BotToken = os.Getenv("SLACK_BOT_TOKEN")
+	}
+	return slackConfig
+}
+
+// telegramBotConfig returns the Telegram settings, with the token taken from
+// the environment when the configuration has none
+func telegramBotConfig() config.TelegramConfig {
+	telegramConfig := cfg.Bots.Telegram
+	if telegramConfig.Token == "" {
+		telegramConfig.Token = os.Getenv("TELEGRAM_BOT_TOKEN")
+	}
+	return telegramConfig
+}
+
+// discordBotConfig returns the Discord settings, with the token taken from
+// the environment when the configuration has none
+func discordBotConfig() config.DiscordConfig {
+	discordConfig := cfg.Bots.Discord
+	if discordConfig.Token == "" {
+		discordConfig.Token = os.Getenv("DISCORD_BOT_TOKEN")
+	}
+	return discordConfig
+}
+
+// botConnector is a bot to run along with the collections it answers from
+type botConnector struct {
+	section   string
+	connector bot.Connector
+	mapping   config.ChannelMapping
+}
+
+// newSlackConnector creates a Slack bot that reports errors as warnings
+func newSlackConnector(slackConfig config.SlackConfig) botConnector {
+	b := slack.New(&slackConfig)
+	b.OnError = botWarning(b.Name())
+	return botConnector{"slack", b, slackConfig.ChannelMapping}
+}
+
+// newTelegramConnector creates a Telegram bot that reports errors as warnings
+func newTelegramConnector(telegramConfig config.TelegramConfig) botConnector {
+	b := telegram.New(&telegramConfig)
+	b.OnError = botWarning(b.Name())
+	return botConnector{"telegram", b, telegramConfig.ChannelMapping}
+}
+
+// newDiscordConnector creates a Discord bot that reports errors as warnings
+func newDiscordConnector(discordConfig config.DiscordConfig) botConnector {
+	b := discord.New(&discordConfig)
+	b.OnError = botWarning(b.Name())
+	return botConnector{"discord", b, discordConfig.ChannelMapping}
+}
+
+// botWarning returns an error handler that prints a bot's errors as warnings
+func botWarning(name string) func(error) {
+	return func(err error) {
+		output.Warning("%s: %v", name, err)
+	}
+}
+
+// runBots runs bots until interrupted. If one stops with an error the others
+// are stopped too.
+func runBots(connectors ...botConnector) error {
+	db, err := openMigratedDatabase()
+	if err != nil {
+		return err
+	}
+	answerer := newBotAnswerer(db)
+
+	// Fail early on collections that don't exist
+	handlers := make([]bot.Handler, len(connectors))
+	for i, c := range connectors {
+		mapping, err := resolveChannelMapping(answerer.collectionMgr, c.mapping)
+		if err != nil {
+			return fmt.Errorf("bots.%s: %w", c.section, err)
+		}
+		handlers[i] = bot.NewRouter(answerer, mapping)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(connectors))
+	for i, c := range connectors {
+		output.Success("%s bot is answering questions", c.connector.Name())
+		go func(c botConnector, handler bot.Handler) {
+			if err := c.connector.Run(ctx, handler); err != nil {
+				errs <- fmt.Errorf("%s bot stopped: %w", c.connector.Name(), err)
+				return
+			}
+			errs <- nil
+		}(c, handlers[i])
+	}
+
+	var firstErr error
+	for range connectors {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			stop()
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	output.Info("Bot stopped")
+	return nil
+}
+
+// resolveChannelMapping replaces the collection names of a channel mapping
+// with their IDs, failing on collections that don't exist
+func resolveChannelMapping(collectionMgr database.CollectionManager, mapping config.ChannelMapping) (config.ChannelMapping, error) {
+	if len(mapping.Collections()) == 0 {
+		return mapping, fmt.Errorf("no collection is set; give one as an argument or set collection or channels")
+	}
+
+	ids := make(map[string]string)
+	resolve := func(ref string) (string, error) {
+		if id, ok := ids[ref]; ok || ref == "" {
+			return id, nil
+		}
+		collection, err := resolveCollection(collectionMgr, ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to get collection %s: %w", ref, err)
+		}
+		ids[ref] = collection.ID
+		return collection.ID, nil
+	}
+
+	resolved := config.ChannelMapping{Channels: make(map[string]string, len(mapping.Channels))}
+	var err error
+	if resolved.Collection, err = resolve(mapping.Collection); err != nil {
+		return resolved, err
+	}
+	for channel, ref := range mapping.Channels {
+		if resolved.Channels[channel], err = resolve(ref); err != nil {
+			return resolved, err
+		}
+	}
+	return resolved, nil
+}
+
+// botAnswerer answers questions for chat bots from a collection
+type botAnswerer struct {
+	db               *sql.DB
+	collectionMgr    database.CollectionManager
+	embeddingService *embedding.Service
+}
+
+// newBotAnswerer creates an answerer for chat bots
+func newBotAnswerer(db *sql.DB) *botAnswerer {
+	return &botAnswerer{
+		db:               db,
+		collectionMgr:    database.NewCollectionManager(db),
+		embeddingService: embedding.New(backends.LazyEmbedder(), &cfg.Embedding),
+	}
+}
+
+// Answer answers a question from a collection, citing the retrieved documents
+func (a *botAnswerer) Answer(ctx context.Context, collectionID, question string) (*bot.Answer, error) {
+	chat, err := newServiceChatSession(a.db, a.collectionMgr, a.embeddingService, collectionID, database.ChatSessionSettings{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := chat.answer(ctx, question, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := &bot.Answer{Text: text}
+	for _, result := range chat.lastResults {
+		answer.Sources = append(answer.Sources, bot.Source{
+			Path:       result.Document.FilePath,
+			ChunkIndex: result.Document.ChunkIndex,
+			Score:      result.CombinedScore,
+		})
+	}
+	return answer, nil
+}
+
+func init() {
+	botCmd.AddCommand(botSlackCmd)
+	botCmd.AddCommand(botTelegramCmd)
+	botCmd.AddCommand(botDiscordCmd)
+	botCmd.AddCommand(botRunCmd)
+	rootCmd.AddCommand(botCmd)
+}
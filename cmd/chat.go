@@ -3,16 +3,29 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/busybytelab.com/rag-cli/pkg/calibration"
 	"github.com/busybytelab.com/rag-cli/pkg/client"
 	"github.com/busybytelab.com/rag-cli/pkg/config"
 	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/decomposition"
 	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/expansion"
+	"github.com/busybytelab.com/rag-cli/pkg/history"
 	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/busybytelab.com/rag-cli/pkg/prompts"
+	"github.com/busybytelab.com/rag-cli/pkg/spelling"
+	"github.com/busybytelab.com/rag-cli/pkg/translation"
+	"github.com/busybytelab.com/rag-cli/pkg/voice"
 	"github.com/spf13/cobra"
 )
 
@@ -28,32 +41,89 @@ func getDefaultModelName(cfg *config.Config) string {
 	}
 }
 
+// Defaults of the chat retrieval options, shared by chat and the serve API
+const (
+	defaultChatLimit    = 5
+	defaultVectorWeight = 0.7
+	defaultTextWeight   = 0.3
+	defaultMinScore     = 0.1
+	defaultMaxDistance  = 0.8
+)
+
+// chatTimeout bounds how long the chat model may take to answer
+const chatTimeout = 180 * time.Second
+
+// queryEmbeddingCacheSize is how many query embeddings a chat session keeps
+// to reuse for repeated questions
+const queryEmbeddingCacheSize = 64
+
+// errChatEnded is returned by processUserInput when the user ends the session
+var errChatEnded = errors.New("chat session ended")
+
 // chatSession represents an active chat session
 type chatSession struct {
-	collectionID      string
-	limit             int
-	systemPrompt      string
-	userPrompt        string
-	searchQuery       string
-	chatModel         string
-	searchType        database.SearchType
-	vectorWeight      float64
-	textWeight        float64
-	minScore          float64
-	maxDistance       float64
-	rerank            bool
-	rerankInstruction string
-	collectionMgr     database.CollectionManager
-	searchEngine      database.SearchEngine
-	ollamaClient      client.Client
-	embeddingService  *embedding.Service
-	conversation      []client.Message
-	reader            *bufio.Reader
+	collectionID     string
+	limit            int
+	maxTokens        int // Token budget of the context documents, 0 for none
+	maxContextTokens int // Token budget of the whole prompt, 0 for none
+	contextChunks    int // Chunks before and after each context document joined to it
+	systemPrompt     string
+	persona          *prompts.Persona // Built-in persona shaping the answers, if any
+	userPrompt       string
+	searchQuery      string
+	chatModel        string
+	searchType       database.SearchType
+	vectorWeight     float64
+	textWeight       float64
+	minScore         float64
+	maxDistance      float64
+	fileTypes        []string // File types retrieval is limited to, all when empty
+	normalization    database.ScoreNormalization
+	pathWeight       float64
+	mmr              float64            // Maximal marginal relevance lambda diversifying the context (0 = off)
+	calibration      *calibration.Model // Maps combined scores to confidences, if fitted for the search type
+	minConfidence    float64            // Confidence below which the session abstains from answering
+	abstention       config.AbstentionConfig
+	decomposer       *decomposition.Service // Splits complex questions into sub-questions, if enabled
+	lastSubQuestions []string               // Sub-questions the last question was split into
+	lastQuestion     string                 // Question the last documents were retrieved for
+	subQuestionHits  map[string][]int       // Sub-questions that found each document of the last question, by document ID
+	rerank           bool
+	rerankSettings   config.RerankConfig
+	boosts           []database.BoostRule
+	where            []database.MetadataFilter // Custom metadata the context documents must match
+	metadata         map[string]string         // Metadata key-value pairs the context documents must contain
+	collectionMgr    database.CollectionManager
+	searchEngine     database.SearchEngine
+	ollamaClient     client.Client
+	embeddingService *embedding.Service
+	queryEmbedder    embedding.TextEmbedder // Embeds queries, reusing the embeddings of the session's earlier queries
+	spellChecker     *spelling.Service
+	expander         *expansion.Service
+	translator       *translation.Service // Translates queries into the language of the documents, if enabled
+	history          *history.Service     // Summarizes older turns of long conversations, if enabled
+	conversation     []client.Message
+	lastResults      []*database.SearchResult // Documents used as context for the last answer
+	pinned           []*database.SearchResult // Documents kept in the context of every turn
+	provided         []*database.SearchResult // Embedded chunks of text given with ask --context, ranked with the retrieved documents
+	citations        bool                     // Answers cite their context documents by number
+	noRetrieve       bool                     // Answer from the conversation and pinned documents only
+	voice            *voice.Service           // Records questions and speaks answers in voice mode, if enabled
+	voiceIn          bool                     // Questions are spoken instead of typed
+	voiceOut         bool                     // Answers are read aloud
+	stored           *storedChat              // Stored session the conversation is saved in, if any
+	memory           database.MemoryManager   // Stores what the user asks to remember, in interactive chats
+	feedback         database.FeedbackManager // Stores the relevance judgments of /good and /bad, in interactive chats
+	memories         []*database.Memory       // Remembered facts and answer style of the collection
+	collectionName   string
+	transcript       []*database.ChatMessage // Every question and answer of the session with its sources, for /save
+	reader           *bufio.Reader
 }
 
 var chatCmd = &cobra.Command{
-	Use:   "chat [collection-id-or-name]",
-	Short: "Start an interactive chat session with a collection",
+	Use:         "chat [collection-id-or-name]",
+	Short:       "Start an interactive chat session with a collection",
+	Annotations: requires(config.RequireDatabase),
 	Long: `Start an interactive chat session with documents in a collection.
 
 This command allows you to have a conversation with your documents using
@@ -68,6 +138,12 @@ The chat session supports multiple search types to find the most relevant docume
 
 Reranking can be enabled with the --rerank flag for improved document retrieval accuracy.
 
+Answers cite the documents they are based on by number, such as [1] or [2],
+and are followed by the list of numbered documents with their file path,
+chunk and score. With --output json, each answer is printed as JSON with its
+question and the documents, marked when they are cited. --no-citations turns
+citations off.
+
 Examples:
   # Start a chat session with a collection (uses hybrid search by default)
   rag-cli chat my-docs-collection
@@ -78,6 +154,9 @@ Examples:
   # Start with a custom system prompt
   rag-cli chat my-docs-collection --system "You are a technical expert"
 
+  # Start with a prompt from the prompt library
+  rag-cli chat my-docs-collection --prompt-name support-agent --var team=platform
+
   # Start with a specific chat model
   rag-cli chat my-docs-collection --model llama2
 
@@ -93,6 +172,12 @@ Examples:
   # Limit the number of context documents
   rag-cli chat my-docs-collection --limit 5
 
+  # Use as many context documents as fit in 3000 tokens
+  rag-cli chat my-docs-collection --max-tokens 3000
+
+  # Keep the whole prompt within an 8k context window, with room for the answer
+  rag-cli chat my-docs-collection --max-context-tokens 7000
+
   # Use vector-only search
   rag-cli chat my-docs-collection --search-type vector
 
@@ -105,14 +190,59 @@ Examples:
   # Use semantic search with filters
   rag-cli chat my-docs-collection --search-type semantic
 
+  # Fuse vector and BM25 results
+  rag-cli chat my-docs-collection --search-type fusion
+
   # Use reranking with custom instruction
-  rag-cli chat my-docs-collection --rerank --rerank-instruction "Focus on practical examples"`,
-	Args: cobra.ExactArgs(1),
+  rag-cli chat my-docs-collection --rerank --rerank-instruction "Focus on practical examples"
+
+  # Rerank 50 candidates and use the best 5 as context
+  rag-cli chat my-docs-collection --rerank --retrieve 50 --return 5
+
+  # Correct misspelled words in questions before retrieving documents
+  rag-cli chat my-docs-collection --spellcheck
+
+  # Also retrieve with synonym rewrites and paraphrases of each question
+  rag-cli chat my-docs-collection --expand --paraphrases 3
+
+  # Retrieve with search queries the chat model rewrites from each question
+  rag-cli chat my-docs-collection --query-rewrite
+
+  # Split comparative questions into sub-questions retrieved separately
+  rag-cli chat my-docs-collection --decompose
+
+  # Answer without citing the documents as [1], [2] or listing them
+  rag-cli chat my-docs-collection --no-citations
+
+  # Ask questions by voice and hear the answers
+  rag-cli chat my-docs-collection --voice-in --voice-out
+
+  # Keep the conversation in a stored session, and resume it later
+  rag-cli chat my-docs-collection --session design-review
+  rag-cli chat --session design-review`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		collectionID := args[0]
+		var collectionID string
+		if len(args) > 0 {
+			collectionID = args[0]
+		}
+
+		// A stored session is bound to its collection and settings
+		var stored *storedChat
+		if name, _ := cmd.Flags().GetString("session"); name != "" {
+			var err error
+			stored, err = openStoredChat(cmd, name, collectionID)
+			if err != nil {
+				return err
+			}
+			collectionID = stored.collectionID
+		}
+		if collectionID == "" {
+			return fmt.Errorf("give a collection, or a stored session with --session")
+		}
 
 		// Initialize chat session
-		session, err := initializeChatSession(cmd, collectionID)
+		session, err := initializeChatSession(cmd, collectionID, stored)
 		if err != nil {
 			return err
 		}
@@ -122,9 +252,168 @@ Examples:
 	},
 }
 
-// initializeChatSession sets up the chat session with all necessary components
-func initializeChatSession(cmd *cobra.Command, collectionID string) (*chatSession, error) {
-	limit, _ := cmd.Flags().GetInt("limit")
+// initializeChatSession sets up the chat session with all necessary components,
+// continuing the stored session if one is given, and prints its settings
+func initializeChatSession(cmd *cobra.Command, collectionID string, stored *storedChat) (*chatSession, error) {
+	session, collection, err := newChatSession(cmd, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		if err := session.attachStoredChat(stored); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only interactive chats get long enough to need summaries
+	session.history, err = newHistoryService(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.setupVoice(cmd); err != nil {
+		return nil, err
+	}
+	if err := session.loadMemories(); err != nil {
+		return nil, err
+	}
+
+	output.Success("Starting chat session with collection: %s", collection.Name)
+	output.KeyValue("Collection", collection.Name)
+	if stored != nil {
+		if len(session.conversation) > 0 {
+			output.KeyValuef("Session", "%s (%d earlier messages)", stored.name, len(session.conversation))
+		} else {
+			output.KeyValuef("Session", "%s (new)", stored.name)
+		}
+	}
+	output.KeyValue("Chat Backend", cfg.ChatBackend)
+	output.KeyValue("Embedding Backend", cfg.EmbeddingBackend)
+	if session.chatModel != "" {
+		output.KeyValue("Chat Model", session.chatModel)
+	} else {
+		output.KeyValue("Chat Model", getDefaultModelName(cfg))
+	}
+	if session.searchQuery != "" {
+		output.KeyValue("Search Query", session.searchQuery)
+	}
+	if session.persona != nil {
+		output.KeyValue("Persona", session.persona.Name)
+	}
+	output.KeyValue("Search Type", string(session.searchType))
+	if session.maxTokens > 0 {
+		output.KeyValuef("Context Tokens", "%d", session.maxTokens)
+	}
+	if session.maxContextTokens > 0 {
+		output.KeyValuef("Prompt Tokens", "%d", session.maxContextTokens)
+	}
+	if session.contextChunks > 0 {
+		output.KeyValuef("Expand Context", "%d chunks", session.contextChunks)
+	}
+	if session.mmr > 0 && session.mmr < 1 {
+		output.KeyValuef("MMR", "%.2f", session.mmr)
+	}
+	if session.searchType == database.SearchTypeHybrid || session.searchType == database.SearchTypeFusion {
+		output.KeyValuef("Vector Weight", "%.1f", session.vectorWeight)
+		output.KeyValuef("Text Weight", "%.1f", session.textWeight)
+	}
+	if len(session.fileTypes) > 0 {
+		output.KeyValue("File Types", strings.Join(session.fileTypes, ", "))
+	}
+	if len(session.boosts) > 0 {
+		output.KeyValue("Boosts", formatBoostRules(session.boosts))
+	}
+	for _, filter := range session.where {
+		output.KeyValue("Where", filter.String())
+	}
+	for _, key := range slices.Sorted(maps.Keys(session.metadata)) {
+		output.KeyValue("Metadata", key+"="+session.metadata[key])
+	}
+	if session.spellChecker != nil {
+		output.KeyValue("Spell Check", "Enabled")
+	}
+	if session.expander != nil && session.expander.Expands() {
+		output.KeyValue("Query Expansion", "Enabled")
+	}
+	if session.expander != nil && session.expander.RewritesQueries() {
+		output.KeyValue("Query Rewrite", "Enabled")
+	}
+	if session.translator != nil {
+		output.KeyValue("Translation", "Into "+session.translator.Language())
+	}
+	if session.decomposer != nil {
+		output.KeyValue("Question Decomposition", "Enabled")
+	}
+	if len(session.memories) > 0 {
+		output.KeyValuef("Memories", "%d remembered", len(session.memories))
+	}
+	if session.history != nil {
+		output.KeyValuef("History Summaries", "After about %d tokens", cfg.History.GetSummarizeAfter())
+	}
+	switch {
+	case session.voiceIn && session.voiceOut:
+		output.KeyValue("Voice", "Spoken questions and answers")
+	case session.voiceIn:
+		output.KeyValue("Voice", "Spoken questions")
+	case session.voiceOut:
+		output.KeyValue("Voice", "Spoken answers")
+	}
+	if session.rerank {
+		output.KeyValue("Reranking", "Enabled")
+		if session.rerankSettings.Instruction != "" {
+			output.KeyValue("Reranking Instruction", session.rerankSettings.Instruction)
+		}
+		if session.rerankSettings.Retrieve > 0 {
+			output.KeyValuef("Reranking Candidates", "%d", session.rerankSettings.Retrieve)
+		}
+	}
+
+	// Show different messages based on whether this is interactive or non-interactive
+	switch {
+	case session.userPrompt != "":
+		output.KeyValue("User Prompt", session.userPrompt)
+	case session.voiceIn:
+		output.Info("Ask your questions out loud; say 'quit' or 'exit' to end the session")
+	default:
+		output.Info("Type 'quit' or 'exit' to end the session, or /help for chat commands")
+	}
+	output.Info("")
+
+	return session, nil
+}
+
+// newChatSession creates a chat session with the collection and the
+// retrieval and model settings given by the chat flags of cmd
+func newChatSession(cmd *cobra.Command, collectionID string) (*chatSession, *database.Collection, error) {
+	// Connect to database
+	db, err := openMigratedDatabase()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Create managers
+	collectionMgr := database.NewCollectionManager(db)
+
+	// Get collection by ID or name
+	collection, err := resolveCollection(collectionMgr, collectionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	if err := applyProfile(cmd, "search-type"); err != nil {
+		return nil, nil, err
+	}
+	if err := applyCollectionRanking(cmd, collectionMgr, collection.ID, "search-type"); err != nil {
+		return nil, nil, err
+	}
+	limit, maxTokens := getResultLimit(cmd)
+	contextChunks, err := getContextChunks(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxContextTokens, _ := cmd.Flags().GetInt("max-context-tokens")
+	if maxContextTokens < 0 {
+		return nil, nil, fmt.Errorf("--max-context-tokens must be 0 or more")
+	}
 	systemPrompt, _ := cmd.Flags().GetString("system")
 	userPrompt, _ := cmd.Flags().GetString("prompt")
 	searchQuery, _ := cmd.Flags().GetString("query")
@@ -134,8 +423,29 @@ func initializeChatSession(cmd *cobra.Command, collectionID string) (*chatSessio
 	textWeight, _ := cmd.Flags().GetFloat64("text-weight")
 	minScore, _ := cmd.Flags().GetFloat64("min-score")
 	maxDistance, _ := cmd.Flags().GetFloat64("max-distance")
+	fileTypes, _ := cmd.Flags().GetStringSlice("file-types")
 	rerank, _ := cmd.Flags().GetBool("rerank")
-	rerankInstruction, _ := cmd.Flags().GetString("rerank-instruction")
+	rerankSettings := getRerankSettings(cmd)
+	abstention := cfg.Abstention
+	if cmd.Flags().Changed("abstain") {
+		abstention.Enabled, _ = cmd.Flags().GetBool("abstain")
+	}
+	normalization, err := getScoreNormalization(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	mmr, err := getMMR(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	decomposer, err := newDecomposer(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	persona, err := getPersona(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Parse search type
 	searchType := database.SearchType(searchTypeStr)
@@ -143,102 +453,247 @@ func initializeChatSession(cmd *cobra.Command, collectionID string) (*chatSessio
 		searchType = database.SearchTypeHybrid // Default to hybrid
 	}
 
-	// Connect to database
-	db, err := database.NewConnection(&cfg.Database)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	// Create managers
-	collectionMgr := database.NewCollectionManager(db)
-
 	// Create search engine with or without reranking
 	var searchEngine database.SearchEngine
 	if rerank {
-		// Create reranker
-		reranker, err := client.NewReranker(cfg)
+		reranker, err := backends.Reranker()
 		if err != nil {
-			return nil, fmt.Errorf("failed to create reranker: %w", err)
+			return nil, nil, err
 		}
 		searchEngine = database.NewSearchEngineWithReranker(db, reranker)
 	} else {
 		searchEngine = database.NewSearchEngine(db)
 	}
 
-	// Get collection by ID or name
-	collection, err := collectionMgr.GetCollectionByIdOrName(collectionID)
+	// Start the system prompt with the named prompt of the library
+	libraryPrompt, err := renderLibraryPrompt(cmd, db, collection, chatModel)
+	if err != nil {
+		return nil, nil, err
+	}
+	if libraryPrompt != "" {
+		systemPrompt = strings.TrimSpace(libraryPrompt + "\n\n" + systemPrompt)
+	}
+	if persona != nil {
+		systemPrompt = strings.TrimSpace(persona.SystemPrompt + "\n\n" + systemPrompt)
+	}
+
+	// Get boosting rules for the collection
+	boosts, err := getBoostRules(cmd, collectionMgr, collection.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	where, err := getMetadataFilters(cmd, collectionMgr, collection.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, err := getMetadataMatch(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get collection: %w", err)
+		return nil, nil, err
 	}
 
-	// Create embedder for generating embeddings
-	embedder, err := client.NewEmbedder(cfg)
+	// Create the spell check and query expansion services if they are enabled
+	spellChecker, err := newSpellChecker(cmd, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	expander, err := newQueryExpander(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create embedder: %w", err)
+		return nil, nil, err
+	}
+	translator, err := newTranslator(cmd)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Create client for chat operations
-	chatClient, err := client.New(cfg)
+	chatClient, err := backends.Chat()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create chat client: %w", err)
+		return nil, nil, err
 	}
+	warnEncryptedTextSearch(searchType, "")
 
-	// Create embedding service
-	embeddingService := embedding.New(embedder, &cfg.Embedding)
+	// The embedder is only created once a search actually needs query embeddings
+	embeddingService := embedding.New(backends.LazyEmbedder(), &cfg.Embedding)
 
 	session := &chatSession{
-		collectionID:      collection.ID,
-		limit:             limit,
-		systemPrompt:      systemPrompt,
-		userPrompt:        userPrompt,
-		searchQuery:       searchQuery,
-		chatModel:         chatModel,
-		searchType:        searchType,
-		vectorWeight:      vectorWeight,
-		textWeight:        textWeight,
-		minScore:          minScore,
-		maxDistance:       maxDistance,
-		rerank:            rerank,
-		rerankInstruction: rerankInstruction,
-		collectionMgr:     collectionMgr,
-		searchEngine:      searchEngine,
-		ollamaClient:      chatClient,
-		embeddingService:  embeddingService,
-		conversation:      make([]client.Message, 0),
-		reader:            bufio.NewReader(os.Stdin),
+		collectionID:     collection.ID,
+		collectionName:   collection.Name,
+		limit:            limit,
+		maxTokens:        maxTokens,
+		maxContextTokens: maxContextTokens,
+		contextChunks:    contextChunks,
+		systemPrompt:     systemPrompt,
+		persona:          persona,
+		userPrompt:       userPrompt,
+		searchQuery:      searchQuery,
+		chatModel:        chatModel,
+		searchType:       searchType,
+		vectorWeight:     vectorWeight,
+		textWeight:       textWeight,
+		minScore:         minScore,
+		maxDistance:      maxDistance,
+		fileTypes:        database.ParseFileTypes(fileTypes),
+		normalization:    normalization,
+		pathWeight:       getPathWeight(cmd),
+		mmr:              mmr,
+		calibration:      scoreCalibration(searchType),
+		minConfidence:    cfg.Calibration.MinConfidence,
+		abstention:       abstention,
+		decomposer:       decomposer,
+		rerank:           rerank,
+		rerankSettings:   rerankSettings,
+		boosts:           boosts,
+		where:            where,
+		metadata:         metadata,
+		collectionMgr:    collectionMgr,
+		searchEngine:     searchEngine,
+		ollamaClient:     chatClient,
+		embeddingService: embeddingService,
+		queryEmbedder:    embedding.NewCachedEmbedder(embeddingService, embedding.NewCache(queryEmbeddingCacheSize)),
+		memory:           database.NewMemoryManager(db),
+		feedback:         database.NewFeedbackManager(db),
+		spellChecker:     spellChecker,
+		expander:         expander,
+		translator:       translator,
+		citations:        getCitations(cmd),
+		conversation:     make([]client.Message, 0),
+		reader:           bufio.NewReader(os.Stdin),
 	}
 
-	output.Success("Starting chat session with collection: %s", collection.Name)
-	output.KeyValue("Collection", collection.Name)
-	output.KeyValue("Chat Backend", cfg.ChatBackend)
-	output.KeyValue("Embedding Backend", cfg.EmbeddingBackend)
-	if chatModel != "" {
-		output.KeyValue("Chat Model", chatModel)
-	} else {
-		output.KeyValue("Chat Model", getDefaultModelName(cfg))
+	return session, collection, nil
+}
+
+// getPersona returns the built-in persona selected with --persona, or nil
+// when none is
+func getPersona(cmd *cobra.Command) (*prompts.Persona, error) {
+	name, _ := cmd.Flags().GetString("persona")
+	if name == "" {
+		return nil, nil
+	}
+	return prompts.GetPersona(name)
+}
+
+// personaChatOptions returns the sampling settings of the answers of a persona
+func personaChatOptions(persona *prompts.Persona) client.ChatOptions {
+	temperature := persona.Temperature
+	return client.ChatOptions{Temperature: &temperature, MaxTokens: persona.MaxTokens}
+}
+
+// setupVoice turns on the voice mode selected with --voice-in and
+// --voice-out
+func (s *chatSession) setupVoice(cmd *cobra.Command) error {
+	s.voiceIn, _ = cmd.Flags().GetBool("voice-in")
+	s.voiceOut, _ = cmd.Flags().GetBool("voice-out")
+	if !s.voiceIn && !s.voiceOut {
+		return nil
+	}
+	if err := cfg.Voice.Validate(); err != nil {
+		return fmt.Errorf("invalid voice settings: %w", err)
+	}
+	s.voice = voice.New(&cfg.Voice)
+	return nil
+}
+
+// newHistoryService creates the service that summarizes long conversations,
+// or returns nil when summaries are disabled by the configuration or by
+// --summarize=false
+func newHistoryService(cmd *cobra.Command) (*history.Service, error) {
+	settings := cfg.History
+	if cmd.Flags().Changed("summarize") {
+		settings.Summarize, _ = cmd.Flags().GetBool("summarize")
+	}
+	if !settings.Summarize {
+		return nil, nil
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid history settings: %w", err)
+	}
+
+	chatClient, err := backends.Chat()
+	if err != nil {
+		return nil, err
 	}
-	if searchQuery != "" {
-		output.KeyValue("Search Query", searchQuery)
+	return history.New(chatClient, &settings), nil
+}
+
+// newServiceChatSession creates a chat session that answers questions
+// outside of the interactive chat loop, such as for the serve API and chat
+// bots. Settings left empty use the defaults of the chat command.
+func newServiceChatSession(db *sql.DB, collectionMgr database.CollectionManager, embeddingService *embedding.Service, collectionID string, settings database.ChatSessionSettings, history []client.Message) (*chatSession, error) {
+	chatClient, err := backends.Chat()
+	if err != nil {
+		return nil, err
+	}
+
+	boosts, err := collectionMgr.GetBoosts(collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collection boosts: %w", err)
+	}
+
+	// The collection's tuned ranking applies to what the settings leave
+	// out, like the flags of chat and search (see applyCollectionRanking)
+	ranking, err := collectionMgr.GetRanking(collectionID)
+	if err != nil {
+		return nil, err
+	}
+	searchType := settings.SearchType
+	if searchType == "" {
+		searchType = database.SearchTypeHybrid
+	}
+	vectorWeight, textWeight := defaultVectorWeight, defaultTextWeight
+	if ranking != nil && searchType == database.SearchTypeHybrid {
+		vectorWeight, textWeight = ranking.VectorWeight, ranking.TextWeight
 	}
-	output.KeyValue("Search Type", string(searchType))
-	if searchType == database.SearchTypeHybrid {
-		output.KeyValuef("Vector Weight", "%.1f", vectorWeight)
-		output.KeyValuef("Text Weight", "%.1f", textWeight)
+	rerank := ranking != nil && ranking.Rerank
+	if settings.Rerank != nil {
+		rerank = *settings.Rerank
 	}
+
+	searchEngine := database.NewSearchEngine(db)
 	if rerank {
-		output.KeyValue("Reranking", "Enabled")
-		if rerankInstruction != "" {
-			output.KeyValue("Reranking Instruction", rerankInstruction)
+		reranker, err := backends.Reranker()
+		if err != nil {
+			return nil, err
 		}
+		searchEngine = database.NewSearchEngineWithReranker(db, reranker)
+	}
+	translator, err := newTranslationService(cfg.Translation)
+	if err != nil {
+		return nil, err
 	}
 
-	// Show different messages based on whether this is interactive or non-interactive
-	if userPrompt != "" {
-		output.KeyValue("User Prompt", userPrompt)
-	} else {
-		output.Info("Type 'quit' or 'exit' to end the session")
+	session := &chatSession{
+		collectionID:     collectionID,
+		limit:            settings.Limit,
+		systemPrompt:     settings.SystemPrompt,
+		chatModel:        settings.Model,
+		searchType:       searchType,
+		vectorWeight:     vectorWeight,
+		textWeight:       textWeight,
+		minScore:         defaultMinScore,
+		maxDistance:      defaultMaxDistance,
+		normalization:    database.ScoreNormalization(cfg.Search.GetNormalization()),
+		pathWeight:       cfg.Search.PathWeight,
+		rerank:           rerank,
+		rerankSettings:   cfg.Rerank,
+		boosts:           boosts,
+		collectionMgr:    collectionMgr,
+		searchEngine:     searchEngine,
+		ollamaClient:     chatClient,
+		embeddingService: embeddingService,
+		queryEmbedder:    embedding.NewCachedEmbedder(embeddingService, embedding.NewCache(queryEmbeddingCacheSize)),
+		translator:       translator,
+		conversation:     history,
+	}
+	if session.limit == 0 {
+		session.limit = defaultChatLimit
+	}
+	session.calibration = scoreCalibration(session.searchType)
+	session.minConfidence = cfg.Calibration.MinConfidence
+	session.abstention = cfg.Abstention
+	if cfg.Decomposition.Enabled {
+		session.decomposer = decomposition.New(chatClient, &cfg.Decomposition)
 	}
-	output.Info("")
 
 	return session, nil
 }
@@ -250,6 +705,10 @@ func (s *chatSession) startChat() error {
 
 	for {
 		if err := s.processUserInput(); err != nil {
+			// The user ended the session
+			if errors.Is(err, errChatEnded) {
+				return nil
+			}
 			// If this was a non-interactive session and we've processed the prompt, exit gracefully
 			if hasInitialPrompt && s.userPrompt == "" {
 				return nil
@@ -275,10 +734,9 @@ func (s *chatSession) processUserInput() error {
 		s.userPrompt = ""
 	} else {
 		// Wait for user input
-		output.Print("You: ")
-		userInput, err := s.reader.ReadString('\n')
+		userInput, err := s.readInput()
 		if err != nil {
-			return fmt.Errorf("failed to read input: %w", err)
+			return err
 		}
 
 		input = strings.TrimSpace(userInput)
@@ -288,7 +746,14 @@ func (s *chatSession) processUserInput() error {
 
 		if input == "quit" || input == "exit" {
 			output.Info("Goodbye!")
-			return fmt.Errorf("chat session ended")
+			return errChatEnded
+		}
+
+		if strings.HasPrefix(input, "/") {
+			if err := s.runChatCommand(input); err != nil {
+				output.Error("%v", err)
+			}
+			return nil
 		}
 	}
 
@@ -300,79 +765,416 @@ func (s *chatSession) processUserInput() error {
 	return nil
 }
 
-// generateAndDisplayResponse generates a response for the user input and displays it
-func (s *chatSession) generateAndDisplayResponse(userInput string) error {
-	// Determine what to use for search embedding
-	searchText := userInput
-	if s.searchQuery != "" {
-		searchText = s.searchQuery
+// readInput waits for the next question, typed or, in voice mode, spoken
+func (s *chatSession) readInput() (string, error) {
+	if s.voiceIn {
+		return s.listen()
 	}
 
-	// Generate embedding for search query
-	ctx := context.Background()
-	queryEmbedding, err := s.embeddingService.GenerateEmbeddingForText(ctx, searchText)
+	output.Print("You: ")
+	userInput, err := s.reader.ReadString('\n')
+	if err == io.EOF {
+		output.Info("")
+		return "", errChatEnded
+	}
 	if err != nil {
-		return fmt.Errorf("failed to generate query embedding: %w", err)
+		return "", fmt.Errorf("failed to read input: %w", err)
 	}
+	return userInput, nil
+}
 
-	// Use configured search options
-	searchOpts := &database.SearchOptions{
-		SearchType:   s.searchType,
-		VectorWeight: s.vectorWeight,
-		TextWeight:   s.textWeight,
-		MinScore:     s.minScore,
-		MaxDistance:  s.maxDistance,
+// listen records and transcribes a spoken question and shows it. Saying
+// quit or exit ends the session like typing it does.
+func (s *chatSession) listen() (string, error) {
+	output.Info("Listening...")
+	question, err := s.voice.Listen(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if question == "" {
+		return "", nil
 	}
 
-	// Add reranking options if enabled
-	if s.rerank {
-		searchOpts.EnableReranking = true
-		searchOpts.RerankInstruction = s.rerankInstruction
-		// Use default weights for reranking
-		searchOpts.OriginalWeight = 0.7
-		searchOpts.RerankWeight = 0.3
+	output.Info("You: %s", question)
+	if word := strings.ToLower(strings.Trim(question, " .!?")); word == "quit" || word == "exit" {
+		return word, nil
 	}
+	return question, nil
+}
 
-	// Search for relevant documents using the search text
-	results, err := s.searchEngine.SearchDocumentsWithOptions(s.collectionID, queryEmbedding, searchText, s.limit, searchOpts)
+// generateAndDisplayResponse generates a response for the user input and displays it
+func (s *chatSession) generateAndDisplayResponse(userInput string) error {
+	answer, err := s.answer(context.Background(), userInput, nil)
 	if err != nil {
-		return fmt.Errorf("failed to search documents: %w", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			output.Info("This might be due to a timeout. Try reducing the context limit with -l flag.")
+		}
+		return err
+	}
+	reply := newChatAnswer(userInput, answer, s.lastResults)
+	s.recordTurn(reply)
+
+	// Display response, followed by the documents it cites
+	if output.IsJSON() {
+		if err := output.JSON(reply); err != nil {
+			return err
+		}
+	} else {
+		output.Info("Assistant: %s", answer)
+		if s.citations {
+			printAnswerSources(s.lastResults, true)
+		}
+		output.Info("")
+	}
+
+	if s.voiceOut {
+		if err := s.voice.Speak(context.Background(), answer); err != nil {
+			output.Warning("Failed to speak the answer: %v", err)
+		}
 	}
 
+	return nil
+}
+
+// answer retrieves the documents relevant to the user input, asks the chat
+// model to answer from them and adds the turn to the conversation. When
+// onChunk is set the answer is streamed to it while it is generated.
+func (s *chatSession) answer(ctx context.Context, userInput string, onChunk client.ChunkHandler) (string, error) {
+	// Pinned chunks are always part of the context, ahead of the retrieved ones.
+	// Complex questions are retrieved one sub-question at a time.
+	var results, nearMisses []*database.SearchResult
+	s.lastSubQuestions = nil
+	if !s.noRetrieve {
+		var err error
+		subQuestions := s.decompose(ctx, userInput)
+		if len(subQuestions) > 0 {
+			results, nearMisses, err = s.retrieveSubQuestions(ctx, subQuestions)
+			if err != nil {
+				return "", err
+			}
+			s.lastSubQuestions = subQuestions
+		} else {
+			results, err = s.retrieve(ctx, userInput)
+			if err != nil {
+				return "", err
+			}
+			if s.abstention.Enabled {
+				results, nearMisses = splitByMinScore(results, s.minScore)
+			}
+		}
+	}
+
+	// Don't let the model guess when retrieval found nothing good enough
+	if s.shouldAbstain(results) {
+		s.lastResults = nil
+		return s.abstain(userInput, append(results, nearMisses...), onChunk)
+	}
+
+	results = withPinned(s.pinned, results)
+	results = s.fitDocuments(userInput, results)
+	s.lastResults = results
+	s.lastQuestion = userInput
+
 	// Convert SearchResult to Document for backward compatibility
 	documents := make([]*database.Document, len(results))
 	for i, result := range results {
 		documents[i] = result.Document
 	}
 
-	// Build context from documents
+	// Build context from documents, grouped by sub-question if the question
+	// was split. The last results are kept in the order of their numbers in
+	// the context, which citations and /pin refer to.
 	contextStr := buildContextFromDocuments(documents)
+	if len(s.lastSubQuestions) > 0 {
+		contextStr, s.lastResults = s.buildSubQuestionContext(s.lastSubQuestions, results)
+	}
 
-	// Create system message with context
+	// Create system message with context, or without one for turns that
+	// are only conversation
 	systemMessage := s.buildSystemMessage(contextStr)
+	if s.noRetrieve && len(documents) == 0 {
+		systemMessage = s.buildConversationMessage()
+	}
+
+	// Replace the older turns of a long conversation with a summary, and
+	// keep the prompt within its token budget
+	s.compactConversation(ctx)
+	s.fitConversation(ctx, systemMessage, userInput)
 
 	// Prepare messages for chat
 	messages := s.prepareMessages(systemMessage, userInput)
 
 	// Get response from LLM
-	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second) // 3 minute timeout for chat
+	ctx, cancel := context.WithTimeout(ctx, chatTimeout)
 	defer cancel()
+	if s.persona != nil {
+		ctx = client.WithChatOptions(ctx, personaChatOptions(s.persona))
+	}
 
-	response, err := s.ollamaClient.Chat(ctx, s.chatModel, messages, false)
+	var response *client.ChatResponse
+	var err error
+	if onChunk != nil {
+		response, err = s.ollamaClient.ChatStream(ctx, s.chatModel, messages, onChunk)
+	} else {
+		response, err = s.ollamaClient.Chat(ctx, s.chatModel, messages, false)
+	}
 	if err != nil {
-		output.Info("This might be due to a timeout. Try reducing the context limit with -l flag.")
-		return fmt.Errorf("failed to get response: %w", err)
+		return "", fmt.Errorf("failed to get response: %w", err)
 	}
 
 	// Add to conversation history
 	s.conversation = append(s.conversation, client.Message{Role: "user", Content: userInput})
 	s.conversation = append(s.conversation, client.Message{Role: "assistant", Content: response.Message.Content})
 
-	// Display response
-	output.Info("Assistant: %s", response.Message.Content)
-	output.Info("")
+	return response.Message.Content, nil
+}
 
-	return nil
+// shouldAbstain reports whether the session answers with the abstention
+// message instead of the model's answer: when every retrieved document
+// scores below the minimum score, or none is confident enough by the
+// calibration. Pinned documents are always good enough to answer from.
+func (s *chatSession) shouldAbstain(results []*database.SearchResult) bool {
+	if s.noRetrieve || len(s.pinned) > 0 {
+		return false
+	}
+	if s.abstention.Enabled && len(results) == 0 {
+		return true
+	}
+	return s.calibration != nil && s.minConfidence > 0 && bestConfidence(results) < s.minConfidence
+}
+
+// abstain answers with the abstention message followed by the closest
+// documents, so the user can check them or rephrase the question
+func (s *chatSession) abstain(userInput string, nearMisses []*database.SearchResult, onChunk client.ChunkHandler) (string, error) {
+	var answer strings.Builder
+	answer.WriteString(s.abstention.GetMessage())
+	if len(nearMisses) > s.abstention.GetNearMisses() {
+		nearMisses = nearMisses[:s.abstention.GetNearMisses()]
+	}
+	if len(nearMisses) > 0 {
+		answer.WriteString("\n\nClosest documents:")
+		for i, result := range nearMisses {
+			fmt.Fprintf(&answer, "\n  %d. %s", i+1, formatChatSource(result))
+		}
+	}
+
+	if onChunk != nil {
+		if err := onChunk(answer.String()); err != nil {
+			return "", err
+		}
+	}
+	s.conversation = append(s.conversation, client.Message{Role: "user", Content: userInput})
+	s.conversation = append(s.conversation, client.Message{Role: "assistant", Content: answer.String()})
+	return answer.String(), nil
+}
+
+// splitByMinScore splits ranked results into the ones scoring at least
+// minScore and the near misses below it
+func splitByMinScore(results []*database.SearchResult, minScore float64) (kept, nearMisses []*database.SearchResult) {
+	for _, result := range results {
+		if result.CombinedScore >= minScore {
+			kept = append(kept, result)
+		} else {
+			nearMisses = append(nearMisses, result)
+		}
+	}
+	return kept, nearMisses
+}
+
+// retrieve searches the collection for the documents relevant to the user
+// input
+func (s *chatSession) retrieve(ctx context.Context, userInput string) ([]*database.SearchResult, error) {
+	// Determine what to use for search embedding
+	searchText := userInput
+	if s.searchQuery != "" {
+		searchText = s.searchQuery
+	}
+	return s.search(ctx, searchText)
+}
+
+// search searches the collection for a search text with the session's
+// retrieval settings
+func (s *chatSession) search(ctx context.Context, searchText string) ([]*database.SearchResult, error) {
+	// Correct misspelled words in the search text, and translate it into
+	// the language of the documents
+	searchText = correctQuery(ctx, s.spellChecker, s.collectionID, searchText)
+	original := searchText
+	searchText = translateQuery(ctx, s.translator, searchText)
+
+	// Generate embedding for search query unless searching by text only.
+	// Provided text is always ranked by it.
+	var queryEmbedding []float32
+	if s.searchType.UsesEmbedding() || len(s.provided) > 0 {
+		var err error
+		queryEmbedding, err = s.queryEmbedder.GenerateEmbeddingForText(ctx, searchText)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		}
+	}
+
+	// Use configured search options
+	searchOpts := &database.SearchOptions{
+		SearchType:    s.searchType,
+		VectorWeight:  s.vectorWeight,
+		TextWeight:    s.textWeight,
+		MinScore:      s.minScore,
+		MaxDistance:   s.maxDistance,
+		FileTypes:     s.fileTypes,
+		Normalization: s.normalization,
+		PathWeight:    s.pathWeight,
+		MMRLambda:     s.mmr,
+		Boosts:        s.boosts,
+		Where:         s.where,
+		Metadata:      s.metadata,
+		MaxTokens:     s.maxTokens,
+		ContextChunks: s.contextChunks,
+	}
+
+	// Expand the search text into variants that are searched alongside it
+	variants, err := expandQuery(ctx, s.expander, s.queryEmbedder, s.searchType, searchText)
+	if err != nil {
+		return nil, err
+	}
+	searchOpts.Variants, err = withOriginalQuery(ctx, s.queryEmbedder, s.searchType, variants, original, searchText)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add reranking options if enabled
+	if s.rerank {
+		searchOpts.EnableReranking = true
+		searchOpts.RerankInstruction = s.rerankSettings.Instruction
+		searchOpts.OriginalWeight = s.rerankSettings.OriginalWeight
+		searchOpts.RerankWeight = s.rerankSettings.RerankWeight
+		searchOpts.RerankLimit = s.rerankSettings.Limit
+		searchOpts.RetrieveLimit = s.rerankSettings.Retrieve
+	}
+
+	// Search for relevant documents using the search text
+	results, err := s.searchEngine.SearchDocumentsWithOptions(s.collectionID, queryEmbedding, searchText, s.limit, searchOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+	applyCalibration(s.calibration, results)
+	if err := translateResults(ctx, s.translator, original, results); err != nil {
+		output.Warning("%v, using the documents as they are", err)
+	}
+
+	// Rank the provided text with the documents, within the same limits
+	if len(s.provided) > 0 {
+		results = database.MergeByEmbedding(results, s.provided, queryEmbedding, s.limit, s.maxTokens)
+	}
+
+	return results, nil
+}
+
+// compactConversation summarizes the older turns of the conversation once it
+// gets long. If summarizing fails the whole conversation is kept.
+func (s *chatSession) compactConversation(ctx context.Context) {
+	if s.history == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, chatTimeout)
+	defer cancel()
+
+	conversation, err := s.history.Compact(ctx, s.chatModel, s.conversation)
+	if err != nil {
+		output.Warning("%v, sending the whole conversation", err)
+		return
+	}
+	if len(conversation) < len(s.conversation) {
+		output.Info("(Summarized the earlier conversation to keep it within the model's context)")
+	}
+	s.conversation = conversation
+}
+
+// fitDocuments leaves out the lowest ranked context documents that don't fit
+// in --max-context-tokens with the system prompt and the question. The
+// documents come first; the conversation gets the tokens they leave.
+func (s *chatSession) fitDocuments(userInput string, results []*database.SearchResult) []*database.SearchResult {
+	if s.maxContextTokens <= 0 {
+		return results
+	}
+
+	available := s.maxContextTokens - client.EstimateMessageTokens([]client.Message{
+		{Role: "system", Content: s.buildSystemMessage("")},
+		{Role: "user", Content: userInput},
+	})
+	for i, result := range results {
+		available -= client.EstimateTokens(formatContextDocument(i+1, result.Document))
+		if available < 0 {
+			output.Info("(Left out %d of %d documents to keep the prompt within %d tokens)", len(results)-i, len(results), s.maxContextTokens)
+			return results[:i]
+		}
+	}
+	return results
+}
+
+// fitConversation keeps the conversation within the tokens of
+// --max-context-tokens left by the system message and the question: its
+// older turns are summarized when summaries are enabled, and its oldest
+// turns are left out until it fits
+func (s *chatSession) fitConversation(ctx context.Context, systemMessage, userInput string) {
+	if s.maxContextTokens <= 0 || len(s.conversation) == 0 {
+		return
+	}
+
+	available := max(s.maxContextTokens-client.EstimateMessageTokens([]client.Message{
+		{Role: "system", Content: systemMessage},
+		{Role: "user", Content: userInput},
+	}), 0)
+	if client.EstimateMessageTokens(s.conversation) <= available {
+		return
+	}
+
+	var conversation []client.Message
+	if s.history != nil {
+		ctx, cancel := context.WithTimeout(ctx, chatTimeout)
+		defer cancel()
+
+		var err error
+		conversation, err = s.history.Fit(ctx, s.chatModel, s.conversation, available)
+		if err != nil {
+			output.Warning("%v, leaving out the oldest turns instead", err)
+		}
+	} else {
+		conversation = history.Trim(s.conversation, available)
+	}
+	output.Info("(Shortened the earlier conversation to keep the prompt within %d tokens)", s.maxContextTokens)
+	s.conversation = conversation
+}
+
+// renderLibraryPrompt returns the prompt named by --prompt-name with its
+// variables filled in, or an empty string when no prompt is named
+func renderLibraryPrompt(cmd *cobra.Command, db *sql.DB, collection *database.Collection, chatModel string) (string, error) {
+	name, _ := cmd.Flags().GetString("prompt-name")
+	if name == "" {
+		return "", nil
+	}
+	specs, _ := cmd.Flags().GetStringArray("var")
+
+	prompt, err := prompts.New(database.NewPromptManager(db), cfg.General.GetPromptsDir()).Get(name)
+	if err != nil {
+		return "", err
+	}
+
+	if chatModel == "" {
+		chatModel = getDefaultModelName(cfg)
+	}
+	vars := prompts.DefaultVars(time.Now())
+	vars["collection"] = collection.Name
+	vars["collection_description"] = collection.Description
+	vars["model"] = chatModel
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return "", fmt.Errorf("invalid --var %q, expected name=value", spec)
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+
+	return prompts.Render(prompt.Content, vars)
 }
 
 // buildSystemMessage creates the system message with context and custom prompt
@@ -386,14 +1188,33 @@ Context:
 
 Answer the user's question based on the context above.`
 
+	systemMessage := fmt.Sprintf(baseSystemPrompt, contextStr)
+	if s.citations {
+		systemMessage += "\n" + citationInstruction
+	}
 	if s.systemPrompt != "" {
-		// Append custom system prompt to the base prompt
-		baseSystemPrompt = fmt.Sprintf(`%s
-
-%s`, baseSystemPrompt, s.systemPrompt)
+		// Append custom system prompt to the base prompt; it isn't a format
+		// string, so it may contain % signs
+		systemMessage += "\n\n" + s.systemPrompt
 	}
+	if memoryPrompt := database.MemoryPrompt(s.memories); memoryPrompt != "" {
+		systemMessage += "\n\n" + memoryPrompt
+	}
+
+	return systemMessage
+}
 
-	return fmt.Sprintf(baseSystemPrompt, contextStr)
+// buildConversationMessage creates the system message of turns without
+// retrieval and pinned documents
+func (s *chatSession) buildConversationMessage() string {
+	systemMessage := "You are a helpful assistant. Continue the conversation with the user; no documents were retrieved for this question."
+	if s.systemPrompt != "" {
+		systemMessage += "\n\n" + s.systemPrompt
+	}
+	if memoryPrompt := database.MemoryPrompt(s.memories); memoryPrompt != "" {
+		systemMessage += "\n\n" + memoryPrompt
+	}
+	return systemMessage
 }
 
 // prepareMessages creates the message array for the LLM
@@ -419,24 +1240,60 @@ func buildContextFromDocuments(documents []*database.Document) string {
 
 	var contextParts []string
 	for i, doc := range documents {
-		contextParts = append(contextParts, fmt.Sprintf("Document %d (from %s):\n%s", i+1, doc.FileName, doc.Content))
+		contextParts = append(contextParts, formatContextDocument(i+1, doc))
 	}
 
 	return strings.Join(contextParts, "\n\n")
 }
 
+// formatContextDocument formats a context document with its number
+func formatContextDocument(number int, doc *database.Document) string {
+	return fmt.Sprintf("Document %d (from %s):\n%s", number, contextSource(doc), doc.Content)
+}
+
+// addChatFlags registers the flags for the retrieval and model settings of
+// commands that answer questions from a collection
+func addChatFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("limit", "l", defaultChatLimit, "Maximum number of documents to use as context")
+	cmd.Flags().Int("max-context-tokens", 0, "Token budget of the whole prompt: system prompt, documents, conversation and question (0 = unlimited)")
+	cmd.Flags().String("system", "", "Custom system prompt to append to the default assistant behavior")
+	cmd.Flags().String("prompt-name", "", "Name of a prompt from the prompt library to use as the system prompt")
+	cmd.Flags().String("persona", "", "Built-in assistant persona shaping the system prompt, temperature and answer length: "+strings.Join(prompts.PersonaNames(), ", "))
+	cmd.Flags().StringArray("var", nil, "Value of a prompt variable, e.g. 'team=platform' (repeatable)")
+	cmd.Flags().StringP("model", "m", "", "Override the default chat model (e.g., 'llama2', 'mistral', 'codellama')")
+	cmd.Flags().StringP("search-type", "t", "hybrid", "Search type: vector, text, hybrid, semantic, bm25, fusion")
+	cmd.Flags().Float64P("vector-weight", "", defaultVectorWeight, "Weight for vector similarity (0.0-1.0)")
+	cmd.Flags().Float64P("text-weight", "", defaultTextWeight, "Weight for text similarity (0.0-1.0)")
+	cmd.Flags().Float64P("min-score", "", defaultMinScore, "Minimum similarity score")
+	cmd.Flags().Float64P("max-distance", "", defaultMaxDistance, "Maximum vector distance")
+	cmd.Flags().StringSlice("file-types", nil, "Only use documents with these file extensions as context (e.g., 'md,go')")
+	addMetadataFlags(cmd)
+	addMaxTokensFlag(cmd, "Use as many top documents as context as fit in this many tokens, up to --limit if given (0 = no budget)")
+	addExpandContextFlag(cmd, "Join this many chunks before and after each document from the same file to the context")
+	addNormalizeFlag(cmd)
+	addPathWeightFlag(cmd)
+	addMMRFlag(cmd)
+	addProfileFlag(cmd)
+	cmd.Flags().Bool("decompose", false, "Split complex questions into sub-questions that are retrieved separately (overrides decomposition.enabled)")
+	cmd.Flags().Bool("abstain", false, "Answer that the collection has insufficient information when every document scores below --min-score (overrides abstention.enabled)")
+	cmd.Flags().BoolP("rerank", "r", false, "Enable reranking for document retrieval")
+	cmd.Flags().Bool("citations", true, "Have answers cite the documents they are based on as [1], [2] and list them after the answer")
+	cmd.Flags().Bool("no-citations", false, "Don't ask for citations; list the sources of ask answers without numbers")
+	cmd.MarkFlagsMutuallyExclusive("citations", "no-citations")
+	addRerankFlags(cmd)
+	addBoostFlag(cmd)
+	addSpellCheckFlags(cmd)
+	addExpansionFlags(cmd)
+	addTranslationFlags(cmd)
+}
+
 func init() {
-	chatCmd.Flags().IntP("limit", "l", 5, "Maximum number of documents to use as context")
-	chatCmd.Flags().String("system", "", "Custom system prompt to append to the default assistant behavior")
+	addChatFlags(chatCmd)
 	chatCmd.Flags().String("prompt", "", "Custom user prompt to use as input directly (instead of waiting for user input)")
 	chatCmd.Flags().String("query", "", "Search query to use for document retrieval (separate from user prompt)")
-	chatCmd.Flags().StringP("model", "m", "", "Override the default chat model (e.g., 'llama2', 'mistral', 'codellama')")
-	chatCmd.Flags().StringP("search-type", "t", "hybrid", "Search type: vector, text, hybrid, semantic")
-	chatCmd.Flags().Float64P("vector-weight", "", 0.7, "Weight for vector similarity (0.0-1.0)")
-	chatCmd.Flags().Float64P("text-weight", "", 0.3, "Weight for text similarity (0.0-1.0)")
-	chatCmd.Flags().Float64P("min-score", "", 0.1, "Minimum similarity score")
-	chatCmd.Flags().Float64P("max-distance", "", 0.8, "Maximum vector distance")
-	chatCmd.Flags().BoolP("rerank", "r", false, "Enable reranking for document retrieval")
-	chatCmd.Flags().String("rerank-instruction", "", "Custom instruction for reranking (e.g., 'Focus on practical examples')")
+	chatCmd.Flags().String("session", "", "Name of a stored session to keep the conversation in, created with the collection if it doesn't exist")
+	chatCmd.Flags().Bool("summarize", false, "Summarize older turns once the conversation is long (overrides history.summarize)")
+	chatCmd.Flags().Bool("voice-in", false, "Ask questions out loud: record them with voice.record_command and transcribe them at voice.transcription_url")
+	chatCmd.Flags().Bool("voice-out", false, "Read answers aloud: synthesize them at voice.speech_url and play them with voice.play_command")
 	rootCmd.AddCommand(chatCmd)
 }
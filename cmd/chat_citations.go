@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// citationInstruction asks the chat model to cite the numbered context
+// documents its answer is based on
+const citationInstruction = `Cite the documents each statement is based on by their number in square brackets, such as [1] or [2][3], right after the statement. Only cite documents of the context.`
+
+// citationPattern matches citations of context documents in an answer, such
+// as [1] or [2, 3]
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// chatAnswer is an answer with the documents it is based on, as printed in
+// JSON output
+type chatAnswer struct {
+	Question  string                `json:"question"`
+	Answer    string                `json:"answer"`
+	Citations []database.ChatSource `json:"citations"`
+}
+
+// getCitations returns whether answers cite their documents: they do unless
+// --no-citations or --citations=false is given
+func getCitations(cmd *cobra.Command) bool {
+	citations, _ := cmd.Flags().GetBool("citations")
+	noCitations, _ := cmd.Flags().GetBool("no-citations")
+	return citations && !noCitations
+}
+
+// citedNumbers returns the document numbers an answer cites
+func citedNumbers(answer string) map[int]bool {
+	cited := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		for _, number := range strings.Split(match[1], ",") {
+			if n, err := strconv.Atoi(strings.TrimSpace(number)); err == nil {
+				cited[n] = true
+			}
+		}
+	}
+	return cited
+}
+
+// newChatAnswer returns an answer along with its context documents,
+// numbered as in the context and marked when the answer cites them
+func newChatAnswer(question, answer string, results []*database.SearchResult) *chatAnswer {
+	cited := citedNumbers(answer)
+	citations := make([]database.ChatSource, len(results))
+	for i, result := range results {
+		citations[i] = database.ChatSource{
+			Number:     i + 1,
+			FilePath:   localPath(result.Document),
+			ChunkIndex: result.Document.ChunkIndex,
+			Score:      result.CombinedScore,
+			Cited:      cited[i+1],
+		}
+	}
+	return &chatAnswer{Question: question, Answer: answer, Citations: citations}
+}
+
+// answerSources describes the documents an answer is based on. With
+// citations they are numbered as the answer cites them.
+func answerSources(results []*database.SearchResult, citations bool) []string {
+	sources := make([]string, len(results))
+	for i, result := range results {
+		sources[i] = formatChatSource(result)
+		if citations {
+			sources[i] = fmt.Sprintf("[%d] %s", i+1, sources[i])
+		}
+	}
+	return sources
+}
+
+// printAnswer prints an answer followed by the documents it is based on, or
+// both as JSON in JSON mode
+func printAnswer(question, answer string, results []*database.SearchResult, citations bool) error {
+	if output.IsJSON() {
+		return output.JSON(newChatAnswer(question, answer, results))
+	}
+	output.Info("%s", answer)
+	printAnswerSources(results, citations)
+	return nil
+}
+
+// printAnswerSources prints the list of documents an answer is based on
+func printAnswerSources(results []*database.SearchResult, citations bool) {
+	if len(results) == 0 {
+		return
+	}
+	output.Info("")
+	output.Bold("Sources:")
+	for _, source := range answerSources(results, citations) {
+		output.Info("  %s", source)
+	}
+}
@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+)
+
+// chatCommandHelp describes the commands of an interactive chat session
+const chatCommandHelp = `Chat commands:
+  /sources                 List the documents used for the last answer
+  /grep <keyword>          List the documents of the last answer containing a keyword, with their matching lines
+  /pin <result#>...        Keep documents of the last answer in the context of every turn
+  /pin                     List the pinned documents
+  /unpin [pin#|all]        Unpin a document, or all of them
+  /noretrieve <question>   Answer a question from the conversation and pinned documents only
+  /noretrieve              Stop retrieving documents until /retrieve
+  /retrieve                Retrieve documents for every question again
+  /remember <fact>         Remember a fact for this and later sessions with the collection
+  /style <preference>      Remember how you like answers, e.g. "short, with code examples"
+  /memory                  List what is remembered for the collection
+  /forget <memory#>        Forget a memory
+  /good <result#>...       Mark documents of the last answer as relevant to its question, for 'rag-cli tune'
+  /bad <result#>...        Mark documents of the last answer as not relevant to its question
+  /save [file]             Save the conversation with its sources to Markdown, or JSON for a .json file
+  /limit [n]               Show or set the number of documents retrieved per question
+  /model [name]            Show or set the chat model
+  /search-type [type]      Show or set the search type (vector, text, hybrid, semantic, bm25 or fusion)
+  /clear                   Start over: forget the conversation so far and the last answer's documents
+  /help                    Show this help
+  quit, exit               End the session`
+
+// runChatCommand runs a chat command such as /pin. Unknown commands print
+// the help instead of being sent to the model.
+func (s *chatSession) runChatCommand(input string) error {
+	fields := strings.Fields(input)
+	command, args := strings.ToLower(fields[0]), fields[1:]
+
+	switch command {
+	case "/help", "/?":
+		output.Info("%s", chatCommandHelp)
+	case "/sources":
+		s.printSources()
+	case "/grep":
+		return s.grep(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), fields[0])))
+	case "/pin":
+		if len(args) == 0 {
+			s.printPinned()
+			return nil
+		}
+		return s.pin(args)
+	case "/unpin":
+		return s.unpin(args)
+	case "/noretrieve":
+		question := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), fields[0]))
+		if question == "" {
+			s.noRetrieve = true
+			output.Info("Retrieval is off; questions are answered from the conversation and pinned documents until /retrieve")
+			return nil
+		}
+		// Retrieval is only skipped for this question
+		defer func(noRetrieve bool) { s.noRetrieve = noRetrieve }(s.noRetrieve)
+		s.noRetrieve = true
+		if err := s.generateAndDisplayResponse(question); err != nil {
+			return fmt.Errorf("failed to generate response: %w", err)
+		}
+	case "/retrieve":
+		s.noRetrieve = false
+		output.Info("Retrieval is on")
+	case "/remember":
+		return s.remember(database.MemoryKindFact, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), fields[0])))
+	case "/style":
+		return s.remember(database.MemoryKindStyle, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), fields[0])))
+	case "/memory":
+		printMemories(s.memories)
+	case "/forget":
+		return s.forget(args)
+	case "/good":
+		return s.judge(args, true)
+	case "/bad":
+		return s.judge(args, false)
+	case "/save":
+		return s.save(args)
+	case "/limit":
+		return s.setLimit(args)
+	case "/model":
+		s.setModel(args)
+	case "/search-type":
+		return s.setSearchType(args)
+	case "/clear":
+		s.clear()
+	default:
+		output.Warning("Unknown command %s", fields[0])
+		output.Info("%s", chatCommandHelp)
+	}
+	return nil
+}
+
+// setLimit sets the number of documents retrieved for the next questions,
+// or shows it without an argument
+func (s *chatSession) setLimit(args []string) error {
+	if len(args) == 0 {
+		output.KeyValuef("Limit", "%d", s.limit)
+		return nil
+	}
+	limit, err := strconv.Atoi(args[0])
+	if err != nil || limit < 1 {
+		return fmt.Errorf("invalid limit %q: use a number of documents of 1 or more", args[0])
+	}
+	s.limit = limit
+	output.Success("Retrieving %d documents per question", limit)
+	return nil
+}
+
+// setModel sets the chat model answering the next questions, or shows it
+// without an argument
+func (s *chatSession) setModel(args []string) {
+	if len(args) == 0 {
+		output.KeyValue("Chat Model", s.chatModel)
+		return
+	}
+	s.chatModel = args[0]
+	output.Success("Answering with %s", s.chatModel)
+}
+
+// setSearchType sets the search type of the next questions, with the score
+// calibration fitted for it, or shows it without an argument
+func (s *chatSession) setSearchType(args []string) error {
+	if len(args) == 0 {
+		output.KeyValue("Search Type", string(s.searchType))
+		return nil
+	}
+	searchType, err := database.ParseSearchType(args[0])
+	if err != nil {
+		return err
+	}
+	s.searchType = searchType
+	s.calibration = scoreCalibration(searchType)
+	output.Success("Searching with %s search", searchType)
+	warnEncryptedTextSearch(searchType, "")
+	return nil
+}
+
+// clear starts the conversation over. Pinned documents stay pinned, and
+// /save and a stored session still keep the earlier turns.
+func (s *chatSession) clear() {
+	s.conversation = nil
+	s.lastResults = nil
+	s.lastQuestion = ""
+	s.lastSubQuestions = nil
+	output.Success("Cleared the conversation; the next question starts a new one")
+}
+
+// pin pins documents of the last answer by their result numbers
+func (s *chatSession) pin(args []string) error {
+	if len(s.lastResults) == 0 {
+		return fmt.Errorf("no documents to pin yet; ask a question first")
+	}
+
+	toPin, err := s.lastResultsByNumber(args)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range toPin {
+		if isPinned(s.pinned, result) {
+			output.Info("Already pinned: %s", formatChatSource(result))
+			continue
+		}
+		s.pinned = append(s.pinned, result)
+		output.Success("Pinned: %s", formatChatSource(result))
+	}
+	return nil
+}
+
+// lastResultsByNumber returns the documents of the last answer with the
+// result numbers listed by /sources
+func (s *chatSession) lastResultsByNumber(args []string) ([]*database.SearchResult, error) {
+	var selected []*database.SearchResult
+	for _, arg := range args {
+		n, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+		if err != nil || n < 1 || n > len(s.lastResults) {
+			return nil, fmt.Errorf("invalid result number %q: use 1-%d, as listed by /sources", arg, len(s.lastResults))
+		}
+		selected = append(selected, s.lastResults[n-1])
+	}
+	return selected, nil
+}
+
+// unpin unpins a document by its pin number, or all documents
+func (s *chatSession) unpin(args []string) error {
+	if len(s.pinned) == 0 {
+		output.Info("No documents are pinned")
+		return nil
+	}
+	if len(args) == 0 || strings.EqualFold(args[0], "all") {
+		output.Success("Unpinned %d documents", len(s.pinned))
+		s.pinned = nil
+		return nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil || n < 1 || n > len(s.pinned) {
+		return fmt.Errorf("invalid pin number %q: use 1-%d, as listed by /pin", args[0], len(s.pinned))
+	}
+	output.Success("Unpinned: %s", formatChatSource(s.pinned[n-1]))
+	s.pinned = append(s.pinned[:n-1:n-1], s.pinned[n:]...)
+	return nil
+}
+
+// printSources lists the documents used for the last answer
+func (s *chatSession) printSources() {
+	if len(s.lastResults) == 0 {
+		output.Info("No documents were used for the last answer")
+		return
+	}
+	if len(s.lastSubQuestions) > 0 {
+		output.Bold("The question was split into:")
+		for i, subQuestion := range s.lastSubQuestions {
+			output.Info("  %d. %s", i+1, subQuestion)
+		}
+	}
+	output.Bold("Documents used for the last answer:")
+	for i, result := range s.lastResults {
+		marker := ""
+		if isPinned(s.pinned, result) {
+			marker = " (pinned)"
+		}
+		output.Info("  %d. %s%s", i+1, formatChatSource(result), marker)
+	}
+}
+
+// Lines /grep shows per document, and the characters it keeps around the
+// keyword of longer lines
+const (
+	maxGrepLines   = 3
+	grepLineWindow = 80
+)
+
+// grep lists the documents used for the last answer that contain a keyword,
+// case-insensitively, with their matching lines, to check what was retrieved
+// without asking the model
+func (s *chatSession) grep(keyword string) error {
+	if keyword == "" {
+		return fmt.Errorf("usage: /grep <keyword>")
+	}
+	if len(s.lastResults) == 0 {
+		output.Info("No documents were used for the last answer")
+		return nil
+	}
+
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(keyword))
+	found := false
+	for i, result := range s.lastResults {
+		lines := grepLines(re, result.Document.Content)
+		if len(lines) == 0 && !re.MatchString(localPath(result.Document)) {
+			continue
+		}
+		if !found {
+			output.Bold("Documents of the last answer containing %q:", keyword)
+			found = true
+		}
+		output.Info("  %d. %s", i+1, formatChatSource(result))
+		for _, line := range lines {
+			output.Info("       %s", line)
+		}
+	}
+	if !found {
+		output.Info("None of the %d documents used for the last answer contain %q", len(s.lastResults), keyword)
+	}
+	return nil
+}
+
+// grepLines returns the first lines of content that match re, shortened
+// around the match and with the matches highlighted
+func grepLines(re *regexp.Regexp, content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		loc := re.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		if len(lines) == maxGrepLines {
+			lines = append(lines, "...")
+			break
+		}
+
+		line = strings.TrimSpace(shortenAround(line, loc[0], loc[1], grepLineWindow))
+		lines = append(lines, re.ReplaceAllStringFunc(line, output.Highlight))
+	}
+	return lines
+}
+
+// shortenAround keeps up to window bytes of a line before start and after
+// end, on character boundaries, marking what was cut with ...
+func shortenAround(line string, start, end, window int) string {
+	from := max(start-window, 0)
+	for from > 0 && !utf8.RuneStart(line[from]) {
+		from--
+	}
+	to := min(end+window, len(line))
+	for to < len(line) && !utf8.RuneStart(line[to]) {
+		to++
+	}
+
+	shortened := line[from:to]
+	if from > 0 {
+		shortened = "..." + shortened
+	}
+	if to < len(line) {
+		shortened += "..."
+	}
+	return shortened
+}
+
+// printPinned lists the pinned documents
+func (s *chatSession) printPinned() {
+	if len(s.pinned) == 0 {
+		output.Info("No documents are pinned; pin one with /pin <result#> from /sources")
+		return
+	}
+	output.Bold("Pinned documents:")
+	for i, result := range s.pinned {
+		output.Info("  %d. %s", i+1, formatChatSource(result))
+	}
+}
+
+// formatChatSource describes a document used as context
+func formatChatSource(result *database.SearchResult) string {
+	chunk := fmt.Sprintf("chunk %d", result.Document.ChunkIndex)
+	if location := chunkLocation(result.Document); location != "" {
+		chunk += ", " + location
+	}
+	if isProvidedDocument(result.Document) {
+		return fmt.Sprintf("%s (%s, provided)", localPath(result.Document), chunk)
+	}
+	if result.Confidence > 0 {
+		return fmt.Sprintf("%s (%s, score %.2f, %.0f%% confident)", localPath(result.Document), chunk, result.CombinedScore, 100*result.Confidence)
+	}
+	return fmt.Sprintf("%s (%s, score %.2f)", localPath(result.Document), chunk, result.CombinedScore)
+}
+
+// isPinned reports whether the document of result is pinned
+func isPinned(pinned []*database.SearchResult, result *database.SearchResult) bool {
+	for _, p := range pinned {
+		if p.Document.ID == result.Document.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// withPinned returns the pinned documents followed by the retrieved ones
+// that aren't pinned
+func withPinned(pinned, results []*database.SearchResult) []*database.SearchResult {
+	if len(pinned) == 0 {
+		return results
+	}
+	combined := append([]*database.SearchResult{}, pinned...)
+	for _, result := range results {
+		if !isPinned(pinned, result) {
+			combined = append(combined, result)
+		}
+	}
+	return combined
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/decomposition"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// newDecomposer returns the question decomposition service configured by
+// decomposition and the --decompose flag, or nil when it is disabled
+func newDecomposer(cmd *cobra.Command) (*decomposition.Service, error) {
+	settings := cfg.Decomposition
+
+	if cmd.Flags().Changed("decompose") {
+		settings.Enabled, _ = cmd.Flags().GetBool("decompose")
+	}
+	if !settings.Enabled {
+		return nil, nil
+	}
+
+	chat, err := backends.Chat()
+	if err != nil {
+		return nil, err
+	}
+	return decomposition.New(chat, &settings), nil
+}
+
+// decompose returns the sub-questions of the user input, or none when it
+// isn't split. A failed split is a warning, and the question is retrieved
+// as it is.
+func (s *chatSession) decompose(ctx context.Context, userInput string) []string {
+	if s.decomposer == nil || s.searchQuery != "" {
+		return nil
+	}
+	subQuestions, err := s.decomposer.Decompose(ctx, userInput)
+	if err != nil {
+		output.Warning("Could not split the question: %v", err)
+		return nil
+	}
+	return subQuestions
+}
+
+// retrieveSubQuestions searches for each sub-question separately and fuses
+// the results, so each sub-question gets its best documents into the
+// context. Results below the minimum score are returned as near misses when
+// abstention is enabled.
+func (s *chatSession) retrieveSubQuestions(ctx context.Context, subQuestions []string) ([]*database.SearchResult, []*database.SearchResult, error) {
+	rankings := make([][]*database.SearchResult, 0, len(subQuestions))
+	weights := make([]float64, 0, len(subQuestions))
+	var nearMisses []*database.SearchResult
+	for _, subQuestion := range subQuestions {
+		results, err := s.search(ctx, subQuestion)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to search sub-question %q: %w", subQuestion, err)
+		}
+		if s.abstention.Enabled {
+			var misses []*database.SearchResult
+			results, misses = splitByMinScore(results, s.minScore)
+			nearMisses = append(nearMisses, misses...)
+		}
+		rankings = append(rankings, results)
+		weights = append(weights, 1)
+	}
+
+	// The fused results remember which sub-questions found them
+	s.subQuestionHits = make(map[string][]int)
+	for i, ranking := range rankings {
+		for _, result := range ranking {
+			s.subQuestionHits[result.Document.ID] = append(s.subQuestionHits[result.Document.ID], i)
+		}
+	}
+
+	sort.SliceStable(nearMisses, func(i, j int) bool {
+		return nearMisses[i].CombinedScore > nearMisses[j].CombinedScore
+	})
+	return database.FuseRankings(rankings, weights, s.limit), dedupeResults(nearMisses), nil
+}
+
+// buildSubQuestionContext builds the context of a split question with the
+// documents grouped by the first sub-question that found them, after any
+// pinned documents. It also returns the results in the order the documents
+// are numbered in the context.
+func (s *chatSession) buildSubQuestionContext(subQuestions []string, results []*database.SearchResult) (string, []*database.SearchResult) {
+	groups := make([][]*database.SearchResult, len(subQuestions))
+	var pinned []*database.SearchResult
+	for _, result := range results {
+		hits := s.subQuestionHits[result.Document.ID]
+		if len(hits) == 0 {
+			pinned = append(pinned, result)
+			continue
+		}
+		groups[hits[0]] = append(groups[hits[0]], result)
+	}
+
+	var parts []string
+	numbered := make([]*database.SearchResult, 0, len(results))
+	addDocuments := func(title string, documents []*database.SearchResult) {
+		parts = append(parts, title)
+		if len(documents) == 0 {
+			parts = append(parts, "No relevant documents found.")
+		}
+		for _, result := range documents {
+			numbered = append(numbered, result)
+			doc := result.Document
+			parts = append(parts, fmt.Sprintf("Document %d (from %s):\n%s", len(numbered), contextSource(doc), doc.Content))
+		}
+	}
+
+	if len(pinned) > 0 {
+		addDocuments("Pinned documents:", pinned)
+	}
+	for i, subQuestion := range subQuestions {
+		addDocuments(fmt.Sprintf("Documents for sub-question %d: %s", i+1, subQuestion), groups[i])
+	}
+	return strings.Join(parts, "\n\n"), numbered
+}
+
+// dedupeResults removes later results of documents that are already listed
+func dedupeResults(results []*database.SearchResult) []*database.SearchResult {
+	seen := make(map[string]bool)
+	var unique []*database.SearchResult
+	for _, result := range results {
+		if seen[result.Document.ID] {
+			continue
+		}
+		seen[result.Document.ID] = true
+		unique = append(unique, result)
+	}
+	return unique
+}
@@ -1,17 +1,35 @@
 package cmd
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/busybytelab.com/rag-cli/pkg/config"
 	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
 	"github.com/busybytelab.com/rag-cli/pkg/output"
 	"github.com/spf13/cobra"
 )
 
+// diskANNSuggestedChunks is the collection size from which a DiskANN index is suggested
+const diskANNSuggestedChunks = 1000000
+
+// normSampleSize is the number of chunks whose embedding norms are checked
+// before a collection is flagged as normalized
+const normSampleSize = 100
+
+// maxNormDeviation is how far an embedding norm may stray from 1 for the
+// embeddings to count as normalized
+const maxNormDeviation = 0.01
+
 var collectionCmd = &cobra.Command{
-	Use:   "collection",
-	Short: "Manage collections",
+	Use:         "collection",
+	Short:       "Manage collections",
+	Annotations: requires(config.RequireDatabase),
 	Long: `Manage collections of documents for RAG operations.
 
 Collections are groups of documents that are indexed together and can be searched
@@ -30,6 +48,18 @@ Examples:
   # Edit collection details
   rag-cli collection edit abc123 --new-name "updated-name" --new-description "Updated description"
 
+  # Use a DiskANN vector index for a large collection
+  rag-cli collection set-index abc123 --type diskann
+
+  # Search a collection of normalized embeddings by inner product
+  rag-cli collection set-normalized abc123
+
+  # Refer to a collection by a shorter alias
+  rag-cli collection alias add prod-docs docs
+
+  # Find the collection most likely to answer a question
+  rag-cli collection suggest "how do I rotate the database password"
+
   # Add folder to collection
   rag-cli collection add-folder abc123 --folder ./new-docs
 
@@ -54,30 +84,47 @@ Examples:
   rag-cli collection create my-docs -d "My documentation" -f ./docs
 
   # Create a collection with multiple folders
-  rag-cli collection create project-docs -d "Project documentation" -f ./docs -f ./guides -f ./api`,
+  rag-cli collection create project-docs -d "Project documentation" -f ./docs -f ./guides -f ./api
+
+  # Create a large collection that uses a pgvectorscale DiskANN index
+  rag-cli collection create archive -d "Mail archive" -f ./archive --index-type diskann
+
+  # Create a collection for a model that returns normalized embeddings
+  rag-cli collection create notes -f ./notes --normalized`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		description, _ := cmd.Flags().GetString("description")
 		folders, _ := cmd.Flags().GetStringSlice("folders")
+		indexTypeName, _ := cmd.Flags().GetString("index-type")
+		normalizedEmbeddings, _ := cmd.Flags().GetBool("normalized")
 
 		if len(folders) == 0 {
 			return fmt.Errorf("at least one folder must be specified")
 		}
 
-		// Validate folders exist
-		for _, folder := range folders {
+		indexType, err := database.ParseIndexType(indexTypeName)
+		if err != nil {
+			return err
+		}
+
+		// Validate folders exist and store them in normalized form
+		for i, folder := range folders {
 			if _, err := os.Stat(folder); os.IsNotExist(err) {
 				return fmt.Errorf("folder does not exist: %s", folder)
 			}
+			normalized, err := database.NormalizePath(folder)
+			if err != nil {
+				return err
+			}
+			folders[i] = normalized
 		}
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create collection manager
 		collectionMgr := database.NewCollectionManager(db)
@@ -88,11 +135,34 @@ Examples:
 			return fmt.Errorf("failed to create collection: %w", err)
 		}
 
+		if indexType != database.IndexTypeHNSW || normalizedEmbeddings {
+			// Make sure the schema knows about index types
+			dbManager, err := database.NewDatabaseManagerWithDB(db)
+			if err != nil {
+				return fmt.Errorf("failed to create database manager: %w", err)
+			}
+			defer dbManager.Close()
+		}
+		if indexType != database.IndexTypeHNSW {
+			if err := switchIndexType(db, collectionMgr, collection.ID, indexType); err != nil {
+				return err
+			}
+		}
+		if normalizedEmbeddings {
+			if err := collectionMgr.SetNormalized(collection.ID, true); err != nil {
+				return fmt.Errorf("failed to flag collection as normalized: %w", err)
+			}
+		}
+
 		output.Success("Collection created successfully!")
 		output.KeyValue("ID", collection.ID)
 		output.KeyValue("Name", collection.Name)
 		output.KeyValue("Description", collection.Description)
 		output.KeyValuef("Folders", "%v", collection.Folders)
+		output.KeyValue("Vector index", string(indexType))
+		if normalizedEmbeddings {
+			output.KeyValue("Normalized", "yes (inner product search)")
+		}
 
 		return nil
 	},
@@ -106,29 +176,63 @@ var listCollectionsCmd = &cobra.Command{
 Shows all collections in the database along with their metadata,
 folder paths, and document statistics.
 
+For scripting, --id-only prints one collection ID per line and --json prints
+the collections as JSON. --name restricts the list to the collections a name
+or alias refers to, which lists every candidate of an ambiguous name.
+
 Examples:
   # List all collections
   rag-cli collection list
 
   # List collections with verbose output
-  rag-cli collection list -v`,
+  rag-cli collection list -v
+
+  # Print the IDs of the collections called or aliased "docs"
+  rag-cli collection list --name docs --id-only
+
+  # Print all collections as JSON
+  rag-cli collection list --json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		idOnly, _ := cmd.Flags().GetBool("id-only")
+
+		if idOnly && output.IsJSON() {
+			return fmt.Errorf("--id-only cannot be used with JSON output")
+		}
+
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openMigratedDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create collection manager
 		collectionMgr := database.NewCollectionManager(db)
 
 		// List collections
-		collections, err := collectionMgr.ListCollections()
+		var collections []*database.Collection
+		if name != "" {
+			collections, err = matchCollections(collectionMgr, name)
+		} else {
+			collections, err = collectionMgr.ListCollections()
+		}
 		if err != nil {
 			return fmt.Errorf("failed to list collections: %w", err)
 		}
 
+		switch {
+		case idOnly:
+			for _, collection := range collections {
+				output.Println(collection.ID)
+			}
+			return nil
+		case output.IsJSON():
+			if collections == nil {
+				collections = []*database.Collection{}
+			}
+			return output.JSON(collections)
+		}
+
 		if len(collections) == 0 {
 			output.Info("No collections found.")
 			return nil
@@ -152,6 +256,23 @@ Examples:
 	},
 }
 
+// matchCollections returns all collections a name or alias refers to
+func matchCollections(collectionMgr database.CollectionManager, name string) ([]*database.Collection, error) {
+	collection, err := collectionMgr.GetCollectionByIdOrName(name)
+
+	var ambiguous *database.AmbiguousCollectionError
+	switch {
+	case err == nil:
+		return []*database.Collection{collection}, nil
+	case errors.As(err, &ambiguous):
+		return ambiguous.Candidates, nil
+	case errors.Is(err, database.ErrCollectionNotFound):
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
 var showCollectionCmd = &cobra.Command{
 	Use:   "show [collection-id-or-name]",
 	Short: "Show collection details",
@@ -165,32 +286,67 @@ Examples:
   rag-cli collection show 550e8400-e29b-41d4-a716-446655440000
 
   # Show collection details by name
-  rag-cli collection show my-docs-collection`,
+  rag-cli collection show my-docs-collection
+
+  # Show collection details as JSON
+  rag-cli collection show my-docs-collection --output json`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		id := args[0]
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create collection manager
 		collectionMgr := database.NewCollectionManager(db)
 
 		// Get collection by ID or name
-		collection, err := collectionMgr.GetCollectionByIdOrName(id)
+		collection, err := resolveCollection(collectionMgr, id)
 		if err != nil {
 			return fmt.Errorf("failed to get collection: %w", err)
 		}
 
+		aliases, _ := collectionMgr.ListAliases(collection.ID)
+		boosts, _ := collectionMgr.GetBoosts(collection.ID)
+		schema, _ := collectionMgr.GetMetadataSchema(collection.ID)
+		indexType, indexErr := collectionMgr.GetIndexType(collection.ID)
+		normalized, _ := collectionMgr.GetNormalized(collection.ID)
+		ranking, _ := collectionMgr.GetRanking(collection.ID)
+
+		if output.IsJSON() {
+			details := collectionDetails{
+				Collection: collection,
+				Aliases:    append([]string{}, aliases...),
+				Boosts:     []string{},
+				Metadata:   []string{},
+				Normalized: normalized,
+				Ranking:    ranking,
+			}
+			for _, rule := range boosts {
+				details.Boosts = append(details.Boosts, rule.String())
+			}
+			for _, field := range schema {
+				details.Metadata = append(details.Metadata, field.String())
+			}
+			if indexErr == nil {
+				details.VectorIndex = string(indexType)
+			}
+			return output.JSON(details)
+		}
+
 		output.Bold("Collection Details:")
 		output.KeyValue("ID", collection.ID)
 		output.KeyValue("Name", collection.Name)
 		output.KeyValue("Description", collection.Description)
 		output.KeyValuef("Folders", "%v", collection.Folders)
+		for _, folder := range collection.Folders {
+			if root := cfg.Paths.LocalRoot(folder); root != folder {
+				output.KeyValue("  Mapped", fmt.Sprintf("%s -> %s", folder, root))
+			}
+		}
 		output.KeyValuef("Stats", "%d documents, %d chunks, %d bytes",
 			collection.Stats.TotalDocuments,
 			collection.Stats.TotalChunks,
@@ -198,10 +354,55 @@ Examples:
 		output.KeyValue("Created", collection.CreatedAt.Format("2006-01-02 15:04:05"))
 		output.KeyValue("Updated", collection.UpdatedAt.Format("2006-01-02 15:04:05"))
 
+		if len(aliases) > 0 {
+			output.KeyValue("Aliases", strings.Join(aliases, ", "))
+		}
+
+		if len(boosts) > 0 {
+			output.KeyValue("Boosts", formatBoostRules(boosts))
+		}
+
+		if len(schema) > 0 {
+			output.KeyValue("Metadata", schema.String())
+		}
+
+		if ranking != nil {
+			tuned := fmt.Sprintf("vector %.1f, text %.1f", ranking.VectorWeight, ranking.TextWeight)
+			if ranking.Rerank {
+				tuned += ", reranked"
+			}
+			output.KeyValuef("Ranking", "%s (tuned %s with %d questions, MRR %.3f)", tuned, ranking.TunedAt.Format("2006-01-02"), ranking.Queries, ranking.MRR)
+		}
+
+		if indexErr != nil {
+			output.KeyValue("Vector index", "unknown (run 'rag-cli migrate up')")
+			return nil
+		}
+		output.KeyValue("Vector index", string(indexType))
+		if normalized {
+			output.KeyValue("Normalized", "yes (inner product search)")
+		}
+		if indexType == database.IndexTypeHNSW && collection.Stats.TotalChunks >= diskANNSuggestedChunks {
+			output.Info("")
+			output.Info("This collection is large. A DiskANN index may search it faster with less memory:")
+			output.Info("  rag-cli collection set-index %s --type diskann", collection.Name)
+		}
+
 		return nil
 	},
 }
 
+// collectionDetails is a collection as printed by collection show in JSON mode
+type collectionDetails struct {
+	*database.Collection
+	Aliases     []string                    `json:"aliases"`
+	Boosts      []string                    `json:"boosts"`   // Boosting rules, e.g. file:README*=+0.1
+	Metadata    []string                    `json:"metadata"` // Metadata schema fields, e.g. priority:number:required
+	VectorIndex string                      `json:"vector_index,omitempty"`
+	Normalized  bool                        `json:"normalized"`
+	Ranking     *database.CollectionRanking `json:"ranking,omitempty"` // Ranking tuned with 'rag-cli tune', if any
+}
+
 var deleteCollectionCmd = &cobra.Command{
 	Use:   "delete [collection-id-or-name]",
 	Short: "Delete a collection",
@@ -234,17 +435,16 @@ Examples:
 		}
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create collection manager
 		collectionMgr := database.NewCollectionManager(db)
 
 		// Get collection by ID or name first to validate it exists
-		collection, err := collectionMgr.GetCollectionByIdOrName(id)
+		collection, err := resolveCollection(collectionMgr, id)
 		if err != nil {
 			return fmt.Errorf("failed to get collection: %w", err)
 		}
@@ -254,6 +454,7 @@ Examples:
 		if err != nil {
 			return fmt.Errorf("failed to delete collection: %w", err)
 		}
+		collectionCache().Forget(collection.ID)
 
 		output.Success("Collection deleted successfully!")
 
@@ -294,17 +495,16 @@ Examples:
 		}
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openMigratedDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create collection manager
 		collectionMgr := database.NewCollectionManager(db)
 
 		// Get collection by ID or name first to validate it exists
-		collection, err := collectionMgr.GetCollectionByIdOrName(id)
+		collection, err := resolveCollection(collectionMgr, id)
 		if err != nil {
 			return fmt.Errorf("failed to get collection: %w", err)
 		}
@@ -336,6 +536,265 @@ Examples:
 	},
 }
 
+var setIndexCmd = &cobra.Command{
+	Use:   "set-index [collection-id-or-name]",
+	Short: "Change the vector index type of a collection",
+	Long: `Change the vector index used to search a collection's embeddings.
+
+By default all collections share a pgvector HNSW index. Very large collections
+can switch to a dedicated StreamingDiskANN index provided by the pgvectorscale
+extension, which keeps most of the index on disk instead of in memory.
+
+The index is rebuilt in place, so existing documents don't need to be
+re-indexed. Building a DiskANN index on a large collection can take a while.
+
+Examples:
+  # Switch a collection to a DiskANN index
+  rag-cli collection set-index my-docs-collection --type diskann
+
+  # Switch back to the shared HNSW index
+  rag-cli collection set-index my-docs-collection --type hnsw`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		typeName, _ := cmd.Flags().GetString("type")
+
+		indexType, err := database.ParseIndexType(typeName)
+		if err != nil {
+			return err
+		}
+
+		// Connect to database
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+
+		// Make sure the schema knows about index types
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+		defer dbManager.Close()
+
+		// Create collection manager
+		collectionMgr := database.NewCollectionManager(db)
+
+		// Get collection by ID or name
+		collection, err := resolveCollection(collectionMgr, id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		currentType, err := collectionMgr.GetIndexType(collection.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get index type: %w", err)
+		}
+		if currentType == indexType {
+			output.Info("Collection '%s' already uses a %s index", collection.Name, indexType)
+			return nil
+		}
+
+		output.Info("Switching collection '%s' from %s to %s index...", collection.Name, currentType, indexType)
+		if err := switchIndexType(db, collectionMgr, collection.ID, indexType); err != nil {
+			return err
+		}
+
+		output.Success("Vector index updated successfully!")
+		output.KeyValue("Collection", collection.Name)
+		output.KeyValue("Vector index", string(indexType))
+
+		return nil
+	},
+}
+
+// switchIndexType checks that the index type is supported by the server and
+// switches the collection to it
+func switchIndexType(db *sql.DB, collectionMgr database.CollectionManager, collectionID string, indexType database.IndexType) error {
+	if indexType == database.IndexTypeDiskANN {
+		status, err := database.CheckExtension(db, database.VectorScaleExtension)
+		if err != nil {
+			return err
+		}
+		if !status.Available {
+			output.Error("DiskANN indexes need the pgvectorscale extension, which is not installed on the database server")
+			output.Info("Install pgvectorscale (%s) and try again", database.ExtensionInstallURL(database.VectorScaleExtension))
+			return database.ErrExtensionUnavailable
+		}
+	}
+
+	if err := collectionMgr.SetIndexType(collectionID, indexType); err != nil {
+		return fmt.Errorf("failed to set index type: %w", err)
+	}
+
+	return nil
+}
+
+var setNormalizedCmd = &cobra.Command{
+	Use:   "set-normalized [collection-id-or-name]",
+	Short: "Search a collection of normalized embeddings by inner product",
+	Long: `Flag a collection whose embedding model returns normalized (unit length)
+embeddings, or clear the flag with --off.
+
+For unit vectors the inner product ranks documents the same as the cosine
+distance but is cheaper to compute, so flagged collections are searched with
+pgvector's inner product operator and get a vector index with the matching
+operator class. Scores are unchanged. The index is rebuilt in place.
+
+The norms of a sample of the collection's embeddings are checked first, since
+inner product search ranks embeddings that are not normalized incorrectly.
+
+Examples:
+  # Search a collection by inner product
+  rag-cli collection set-normalized my-docs-collection
+
+  # Go back to cosine distance
+  rag-cli collection set-normalized my-docs-collection --off`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		off, _ := cmd.Flags().GetBool("off")
+		force, _ := cmd.Flags().GetBool("force")
+		normalized := !off
+
+		// Connect to database
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+
+		// Make sure the schema knows about normalized collections
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+		defer dbManager.Close()
+
+		// Create collection manager
+		collectionMgr := database.NewCollectionManager(db)
+
+		// Get collection by ID or name
+		collection, err := resolveCollection(collectionMgr, id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		current, err := collectionMgr.GetNormalized(collection.ID)
+		if err != nil {
+			return err
+		}
+		if current == normalized {
+			if normalized {
+				output.Info("Collection '%s' is already searched by inner product", collection.Name)
+			} else {
+				output.Info("Collection '%s' is already searched by cosine distance", collection.Name)
+			}
+			return nil
+		}
+
+		if normalized && !force {
+			deviation, err := collectionMgr.MaxEmbeddingNormDeviation(collection.ID, normSampleSize)
+			if err != nil {
+				return err
+			}
+			if deviation > maxNormDeviation {
+				output.Error("Some embeddings of collection '%s' are not normalized (norm off by %.3f)", collection.Name, deviation)
+				output.Info("Inner product search would rank them incorrectly. Use --force to flag the collection anyway.")
+				return fmt.Errorf("embeddings are not normalized")
+			}
+		}
+
+		if err := collectionMgr.SetNormalized(collection.ID, normalized); err != nil {
+			return err
+		}
+
+		output.Success("Collection updated successfully!")
+		output.KeyValue("Collection", collection.Name)
+		if normalized {
+			output.KeyValue("Distance", "inner product")
+		} else {
+			output.KeyValue("Distance", "cosine")
+		}
+
+		return nil
+	},
+}
+
+var setBoostsCmd = &cobra.Command{
+	Use:   "set-boosts [collection-id-or-name]",
+	Short: "Set the boosting rules of a collection",
+	Long: `Set the boosting rules applied when searching or chatting with a collection.
+
+Boosting rules adjust the score of matching results. They are written as
+kind:pattern=value, where the value either adds to the score (+0.1, -0.1)
+or multiplies it (x1.5):
+
+  file:<glob>=<value>          files whose name (or path, if the glob has a /) matches
+  tag:<tag>=<value>            chunks tagged <tag> in their metadata
+  recency:<half-life>=<value>  recently updated chunks, fading out with the given half-life
+
+The given rules replace the collection's current rules. Run without --boost
+to remove all rules. Rules passed to 'search' or 'chat' with --boost are
+applied in addition to these.
+
+Examples:
+  # Prefer READMEs and recently updated content
+  rag-cli collection set-boosts my-docs-collection --boost 'file:README*=+0.1' --boost 'recency:30d=+0.05'
+
+  # Demote deprecated content
+  rag-cli collection set-boosts my-docs-collection --boost 'tag:deprecated=x0.5'
+
+  # Remove all boosting rules
+  rag-cli collection set-boosts my-docs-collection`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		specs, _ := cmd.Flags().GetStringArray("boost")
+
+		rules, err := database.ParseBoostRules(specs)
+		if err != nil {
+			return err
+		}
+
+		// Connect to database
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+
+		// Make sure the schema knows about boosting rules
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+		defer dbManager.Close()
+
+		// Create collection manager
+		collectionMgr := database.NewCollectionManager(db)
+
+		// Get collection by ID or name
+		collection, err := resolveCollection(collectionMgr, id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		if err := collectionMgr.SetBoosts(collection.ID, rules); err != nil {
+			return fmt.Errorf("failed to set boosts: %w", err)
+		}
+
+		if len(rules) == 0 {
+			output.Success("Boosting rules removed from collection '%s'", collection.Name)
+			return nil
+		}
+
+		output.Success("Boosting rules updated successfully!")
+		output.KeyValue("Collection", collection.Name)
+		output.KeyValue("Boosts", formatBoostRules(rules))
+
+		return nil
+	},
+}
+
 var addFolderCmd = &cobra.Command{
 	Use:   "add-folder [collection-id-or-name]",
 	Short: "Add a folder to a collection",
@@ -344,6 +803,12 @@ var addFolderCmd = &cobra.Command{
 The folder will be added to the collection's folder list. Documents in the folder
 will need to be indexed separately using the 'index' command.
 
+When another collection has already indexed the folder with the same embedding
+model, metadata schema and normalization, its chunks, embeddings and file states
+are copied, so the folder is searchable at once without embedding it again. The
+next 'index' run then only embeds the files changed since. Pass --copy-index=false
+to index the folder from scratch instead.
+
 Examples:
   # Add folder to collection by ID
   rag-cli collection add-folder 550e8400-e29b-41d4-a716-446655440000 --folder ./new-docs
@@ -352,7 +817,10 @@ Examples:
   rag-cli collection add-folder my-docs-collection --folder ./additional-docs
 
   # Add folder using long flag
-  rag-cli collection add-folder my-docs-collection --folder ./new-folder`,
+  rag-cli collection add-folder my-docs-collection --folder ./new-folder
+
+  # Add a folder another collection indexed, without copying its index
+  rag-cli collection add-folder team-b --folder ./monorepo --copy-index=false`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		id := args[0]
@@ -367,22 +835,30 @@ Examples:
 			return fmt.Errorf("folder does not exist: %s", folder)
 		}
 
+		folder, err := database.NormalizePath(folder)
+		if err != nil {
+			return err
+		}
+
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create collection manager
 		collectionMgr := database.NewCollectionManager(db)
 
 		// Get collection by ID or name first to validate it exists
-		collection, err := collectionMgr.GetCollectionByIdOrName(id)
+		collection, err := resolveCollection(collectionMgr, id)
 		if err != nil {
 			return fmt.Errorf("failed to get collection: %w", err)
 		}
 
+		if existing, ok := findCollectionFolder(collection, folder); ok {
+			return fmt.Errorf("folder '%s' already exists in collection", existing)
+		}
+
 		// Add folder to collection
 		updatedCollection, err := collectionMgr.AddFolderToCollection(collection.ID, folder)
 		if err != nil {
@@ -394,6 +870,9 @@ Examples:
 		output.KeyValue("Name", updatedCollection.Name)
 		output.KeyValuef("Folders", "%v", updatedCollection.Folders)
 
+		if copyIndex, _ := cmd.Flags().GetBool("copy-index"); copyIndex {
+			return copyFolderIndex(db, updatedCollection, folder)
+		}
 		return nil
 	},
 }
@@ -429,23 +908,28 @@ Examples:
 		}
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openMigratedDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create collection manager
 		collectionMgr := database.NewCollectionManager(db)
 
 		// Get collection by ID or name first to validate it exists
-		collection, err := collectionMgr.GetCollectionByIdOrName(id)
+		collection, err := resolveCollection(collectionMgr, id)
 		if err != nil {
 			return fmt.Errorf("failed to get collection: %w", err)
 		}
 
+		// Folders may have been added in a different form, e.g. before they were normalized
+		existing, ok := findCollectionFolder(collection, folder)
+		if !ok {
+			return fmt.Errorf("folder '%s' does not exist in collection", folder)
+		}
+
 		// Remove folder from collection
-		updatedCollection, err := collectionMgr.RemoveFolderFromCollection(collection.ID, folder)
+		updatedCollection, err := collectionMgr.RemoveFolderFromCollection(collection.ID, existing)
 		if err != nil {
 			return fmt.Errorf("failed to remove folder from collection: %w", err)
 		}
@@ -463,12 +947,466 @@ Examples:
 	},
 }
 
+var setSchemaCmd = &cobra.Command{
+	Use:   "set-schema [collection-id-or-name]",
+	Short: "Set the custom metadata fields of a collection",
+	Long: `Set the custom metadata fields expected in a collection's documents.
+
+Fields are read from the front matter of each file when it is indexed, the
+key: value lines between two --- lines at the start of the file. Fields are
+written as name:type[:required], where the type is string, number, bool or
+date (2006-01-02). Files missing a required field, or with a value of the
+wrong type, are not indexed. Front matter keys that aren't fields are ignored.
+
+The fields can then be used to filter searches and chat context with --where,
+e.g. --where 'priority>=2'. Numbers and dates can be compared with =, !=, <,
+<=, > and >=; strings and bools with = and !=.
+
+The given fields replace the collection's current fields. Run without --field
+to remove them all. Re-index the collection to apply a new schema to indexed
+documents.
+
+Examples:
+  # Expect a priority and an optional owner and review date
+  rag-cli collection set-schema my-docs-collection --field priority:number:required --field owner:string --field reviewed:date
+
+  # Remove all metadata fields
+  rag-cli collection set-schema my-docs-collection`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		specs, _ := cmd.Flags().GetStringArray("field")
+
+		schema, err := database.ParseMetadataSchema(specs)
+		if err != nil {
+			return err
+		}
+
+		// Connect to database
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		// Create collection manager
+		collectionMgr := database.NewCollectionManager(db)
+
+		// Get collection by ID or name
+		collection, err := resolveCollection(collectionMgr, id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		if err := collectionMgr.SetMetadataSchema(collection.ID, schema); err != nil {
+			return fmt.Errorf("failed to set metadata schema: %w", err)
+		}
+
+		if len(schema) == 0 {
+			output.Success("Metadata fields removed from collection '%s'", collection.Name)
+			return nil
+		}
+
+		output.Success("Metadata schema updated successfully!")
+		output.KeyValue("Collection", collection.Name)
+		output.KeyValue("Metadata", schema.String())
+		output.Info("Run 'rag-cli index %s --force' to apply it to indexed documents", collection.Name)
+
+		return nil
+	},
+}
+
+var suggestCollectionCmd = &cobra.Command{
+	Use:   "suggest [query]",
+	Short: "Suggest the collections most likely to answer a query",
+	Long: `Suggest which collections are most likely to contain material relevant to a query.
+
+Collections are ranked by the similarity of the query to their centroid (the
+average embedding of their documents, updated on every index run) and to the
+embedding of their name and description, plus a keyword match with the name
+and description. Description embeddings are computed the first time they are
+needed and again after the collection is renamed or described anew, so
+collections that are not indexed yet can be suggested too.
+
+This is the ranking used by 'rag-cli search --route'.
+
+Examples:
+  # Suggest collections for a question
+  rag-cli collection suggest "how do I rotate the database password"
+
+  # Show the 5 best collections with their scores
+  rag-cli collection suggest "kubernetes ingress" --limit 5 --show-scores`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := args[0]
+		limit, _ := cmd.Flags().GetInt("limit")
+		showScores, _ := cmd.Flags().GetBool("show-scores")
+
+		// Connect to database
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+
+		embeddingService := embedding.New(backends.LazyEmbedder(), &cfg.Embedding)
+		routes, _, err := routeQuery(context.Background(), db, embeddingService, query, limit)
+		if err != nil {
+			return err
+		}
+
+		if len(routes) == 0 {
+			output.Info("No collections to suggest. Create and index a collection first.")
+			return nil
+		}
+
+		output.Bold("Suggested collections:")
+		for i, route := range routes {
+			output.Info("")
+			output.Bold("%d. %s", i+1, route.Collection.Name)
+			output.KeyValue("ID", route.Collection.ID)
+			if route.Collection.Description != "" {
+				output.KeyValue("Description", route.Collection.Description)
+			}
+			output.KeyValuef("Score", "%.4f", route.Score)
+			if showScores {
+				output.KeyValuef("Centroid Score", "%.4f", route.CentroidScore)
+				output.KeyValuef("Description Score", "%.4f", route.DescriptionScore)
+				output.KeyValuef("Keyword Score", "%.4f", route.KeywordScore)
+			}
+		}
+
+		return nil
+	},
+}
+
+var analyzeCollectionCmd = &cobra.Command{
+	Use:   "analyze [collection-id-or-name]",
+	Short: "Report on the quality of a collection's chunks and embeddings",
+	Long: `Analyze the chunks and embeddings of a collection and flag likely indexing problems.
+
+Analyze reports:
+  - a histogram of chunk lengths, in characters
+  - the number of chunks and their average length for each file type
+  - empty chunks and chunks shorter than 50 characters
+  - the share of chunks with the same content as another chunk
+  - chunks without an embedding, with an all-zero embedding, and whose
+    embedding length is far from the others', which often hold binary or
+    garbled text
+  - the size of the collection's vocabulary and its most common terms
+
+Warnings point to what to check: the chunk strategy, ignore patterns, the
+embedding backend, or boilerplate repeated in every file. Encrypted chunks
+are counted but left out of the length and duplicate statistics.
+
+Examples:
+  # Analyze a collection
+  rag-cli collection analyze my-docs-collection
+
+  # Show the 20 most common terms, as JSON
+  rag-cli collection analyze my-docs-collection --top-terms 20 --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		topTerms, _ := cmd.Flags().GetInt("top-terms")
+		if topTerms < 0 {
+			return fmt.Errorf("--top-terms must not be negative")
+		}
+
+		// Connect to database
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		collectionMgr := database.NewCollectionManager(db)
+		collection, err := resolveCollection(collectionMgr, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		samples, err := database.ListChunkSamples(db, collection.ID)
+		if err != nil {
+			return err
+		}
+		vocabulary, err := database.CollectionVocabularyStats(db, collection.ID, topTerms)
+		if err != nil {
+			return err
+		}
+		analysis := database.AnalyzeChunks(samples, vocabulary)
+
+		return output.Result(analysis, func() {
+			printCollectionAnalysis(collection, analysis)
+		})
+	},
+}
+
+// printCollectionAnalysis prints the report of collection analyze
+func printCollectionAnalysis(collection *database.Collection, analysis *database.CollectionAnalysis) {
+	output.Bold("Analysis of collection '%s'", collection.Name)
+	output.KeyValuef("Chunks", "%d (%d encrypted)", analysis.Chunks, analysis.Encrypted)
+	output.KeyValuef("Empty", "%d empty, %d near-empty", analysis.Empty, analysis.NearEmpty)
+	output.KeyValuef("Duplicates", "%d (%.1f%%)", analysis.Duplicates, analysis.DuplicateRatio*100)
+
+	output.Info("")
+	output.Bold("Chunk lengths (characters):")
+	widest := 0
+	for _, bucket := range analysis.Histogram {
+		widest = max(widest, bucket.Chunks)
+	}
+	for _, bucket := range analysis.Histogram {
+		bar := 0
+		if widest > 0 {
+			bar = (bucket.Chunks*40 + widest - 1) / widest
+		}
+		output.Info("  %-10s %6d %s", bucket.Label, bucket.Chunks, strings.Repeat("#", bar))
+	}
+
+	if len(analysis.FileTypes) > 0 {
+		output.Info("")
+		output.Bold("File types:")
+		for _, fileType := range analysis.FileTypes {
+			name := fileType.FileType
+			if name == "" {
+				name = "(none)"
+			}
+			output.Info("  %-10s %6d chunks, %d characters on average", name, fileType.Chunks, fileType.AverageLength)
+		}
+	}
+
+	output.Info("")
+	output.Bold("Embeddings:")
+	output.KeyValuef("Norm", "%.4f mean, %.4f standard deviation", analysis.NormMean, analysis.NormStdDev)
+	output.KeyValuef("Problems", "%d missing, %d all-zero, %d outliers", analysis.Unembedded, analysis.ZeroNorms, analysis.NormOutliers)
+	for _, outlier := range analysis.Outliers {
+		output.Info("  %s (chunk %d): norm %.4f", outlier.FilePath, outlier.ChunkIndex, outlier.Norm)
+	}
+
+	output.Info("")
+	output.Bold("Vocabulary:")
+	output.KeyValuef("Terms", "%d (%d found in a single chunk)", analysis.Vocabulary.Terms, analysis.Vocabulary.Rare)
+	if len(analysis.Vocabulary.TopTerms) > 0 {
+		terms := make([]string, len(analysis.Vocabulary.TopTerms))
+		for i, term := range analysis.Vocabulary.TopTerms {
+			terms[i] = fmt.Sprintf("%s (%d)", term.Term, term.Chunks)
+		}
+		output.KeyValue("Most common", strings.Join(terms, ", "))
+	}
+
+	output.Info("")
+	if len(analysis.Warnings) == 0 {
+		output.Success("No indexing problems found")
+		return
+	}
+	for _, warning := range analysis.Warnings {
+		output.Warning("%s", warning)
+	}
+}
+
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage collection aliases",
+	Long: `Manage alternative names for collections.
+
+An alias can be used everywhere a collection name is accepted. Aliases are
+unique and can't be the name of another collection.
+
+Examples:
+  # Let "docs" refer to the prod-docs collection
+  rag-cli collection alias add prod-docs docs
+
+  # List the aliases of a collection
+  rag-cli collection alias list prod-docs
+
+  # Remove an alias
+  rag-cli collection alias remove docs`,
+}
+
+var addAliasCmd = &cobra.Command{
+	Use:   "add [collection-id-or-name] [alias]",
+	Short: "Add an alias for a collection",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, alias := args[0], args[1]
+
+		// Connect to database
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+
+		// Make sure the schema knows about aliases
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+		defer dbManager.Close()
+
+		// Create collection manager
+		collectionMgr := database.NewCollectionManager(db)
+
+		// Get collection by ID or name
+		collection, err := resolveCollection(collectionMgr, id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		if err := collectionMgr.AddAlias(collection.ID, alias); err != nil {
+			return err
+		}
+
+		output.Success("Alias '%s' added to collection '%s'", alias, collection.Name)
+		return nil
+	},
+}
+
+var removeAliasCmd = &cobra.Command{
+	Use:   "remove [alias]",
+	Short: "Remove a collection alias",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias := args[0]
+
+		// Connect to database
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+
+		// Make sure the schema knows about aliases
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+		defer dbManager.Close()
+
+		if err := database.NewCollectionManager(db).RemoveAlias(alias); err != nil {
+			return err
+		}
+
+		output.Success("Alias '%s' removed", alias)
+		return nil
+	},
+}
+
+var listAliasesCmd = &cobra.Command{
+	Use:   "list [collection-id-or-name]",
+	Short: "List the aliases of a collection",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+
+		// Connect to database
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+
+		// Make sure the schema knows about aliases
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+		defer dbManager.Close()
+
+		// Create collection manager
+		collectionMgr := database.NewCollectionManager(db)
+
+		// Get collection by ID or name
+		collection, err := resolveCollection(collectionMgr, id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		aliases, err := collectionMgr.ListAliases(collection.ID)
+		if err != nil {
+			return err
+		}
+
+		if len(aliases) == 0 {
+			output.Info("Collection '%s' has no aliases.", collection.Name)
+			return nil
+		}
+
+		output.Bold("Aliases of '%s':", collection.Name)
+		for _, alias := range aliases {
+			output.Info("  %s", alias)
+		}
+		return nil
+	},
+}
+
+// routeQuery ranks the collections for a query with the collection router
+// and returns at most limit routes along with the query embedding. It first
+// runs any pending migrations and refreshes stale description embeddings.
+func routeQuery(ctx context.Context, db *sql.DB, embeddingService *embedding.Service, query string, limit int) ([]*database.CollectionRoute, []float32, error) {
+	router, err := prepareRouter(ctx, db, embeddingService)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queryEmbedding, err := embeddingService.GenerateEmbeddingForText(ctx, query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+
+	routes, err := router.RouteQuery(queryEmbedding, query, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to route query: %w", err)
+	}
+
+	return routes, queryEmbedding, nil
+}
+
+// prepareRouter returns the collection router with the description
+// embeddings of all collections up to date
+func prepareRouter(ctx context.Context, db *sql.DB, embeddingService *embedding.Service) (database.CollectionRouter, error) {
+	// Make sure the schema knows about centroids and description embeddings
+	dbManager, err := database.NewDatabaseManagerWithDB(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	defer dbManager.Close()
+
+	router := database.NewCollectionRouter(db)
+	if err := refreshDescriptionEmbeddings(ctx, router, embeddingService); err != nil {
+		return nil, err
+	}
+	return router, nil
+}
+
+// refreshDescriptionEmbeddings embeds the names and descriptions of the
+// collections whose description embedding is missing or out of date
+func refreshDescriptionEmbeddings(ctx context.Context, router database.CollectionRouter, embeddingService *embedding.Service) error {
+	collections, err := router.StaleDescriptionEmbeddings()
+	if err != nil {
+		return fmt.Errorf("failed to list collection descriptions: %w", err)
+	}
+
+	for _, collection := range collections {
+		source := database.CollectionDescriptionText(collection)
+		descriptionEmbedding, err := embeddingService.GenerateDocumentEmbedding(ctx, source)
+		if err != nil {
+			return fmt.Errorf("failed to embed description of collection %s: %w", collection.Name, err)
+		}
+		if err := router.SetDescriptionEmbedding(collection.ID, source, descriptionEmbedding); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func init() {
 	// Create collection flags
 	createCollectionCmd.Flags().StringP("description", "d", "", "Collection description")
 	createCollectionCmd.Flags().StringSliceP("folders", "f", []string{}, "Folders to include in collection")
+	createCollectionCmd.Flags().String("index-type", string(database.IndexTypeHNSW), "Vector index type (hnsw or diskann)")
+	createCollectionCmd.Flags().Bool("normalized", false, "The embedding model returns normalized embeddings; search by inner product")
 	createCollectionCmd.MarkFlagRequired("folders")
 
+	// List collections flags
+	listCollectionsCmd.Flags().String("name", "", "Only list the collections this name or alias refers to")
+	listCollectionsCmd.Flags().Bool("id-only", false, "Print only collection IDs, one per line")
+	listCollectionsCmd.Flags().Bool("json", false, "Print collections as JSON (same as --output json)")
+
 	// Delete collection flags
 	deleteCollectionCmd.Flags().BoolP("force", "f", false, "Force deletion without confirmation")
 
@@ -476,19 +1414,53 @@ func init() {
 	editCollectionCmd.Flags().String("new-name", "", "New name for the collection")
 	editCollectionCmd.Flags().String("new-description", "", "New description for the collection")
 
+	// Set index flags
+	setIndexCmd.Flags().String("type", "", "Vector index type (hnsw or diskann)")
+	setIndexCmd.MarkFlagRequired("type")
+
+	// Set normalized flags
+	setNormalizedCmd.Flags().Bool("off", false, "Clear the flag and search by cosine distance")
+	setNormalizedCmd.Flags().Bool("force", false, "Skip the check that the embeddings are normalized")
+
+	// Set boosts flags
+	setBoostsCmd.Flags().StringArray("boost", nil, "Boosting rule, e.g. 'file:README*=+0.1' (repeatable)")
+
+	// Set schema flags
+	setSchemaCmd.Flags().StringArray("field", nil, "Metadata field as name:type[:required], e.g. 'priority:number:required' (repeatable)")
+
+	// Suggest collection flags
+	suggestCollectionCmd.Flags().IntP("limit", "l", 3, "Maximum number of collections to suggest")
+	suggestCollectionCmd.Flags().Bool("show-scores", false, "Show the individual routing scores")
+
+	// Analyze collection flags
+	analyzeCollectionCmd.Flags().Int("top-terms", 10, "Number of most common vocabulary terms to show")
+
 	// Add folder flags
 	addFolderCmd.Flags().StringP("folder", "f", "", "Folder to add to collection")
 	addFolderCmd.MarkFlagRequired("folder")
+	addFolderCmd.Flags().Bool("copy-index", true, "Copy the chunks and embeddings of the folder from another collection that indexed it with the same model")
 
 	// Remove folder flags
 	removeFolderCmd.Flags().StringP("folder", "f", "", "Folder to remove from collection")
 	removeFolderCmd.MarkFlagRequired("folder")
 
+	// Alias subcommands
+	aliasCmd.AddCommand(addAliasCmd)
+	aliasCmd.AddCommand(removeAliasCmd)
+	aliasCmd.AddCommand(listAliasesCmd)
+
 	// Add subcommands
 	collectionCmd.AddCommand(createCollectionCmd)
 	collectionCmd.AddCommand(listCollectionsCmd)
 	collectionCmd.AddCommand(showCollectionCmd)
 	collectionCmd.AddCommand(editCollectionCmd)
+	collectionCmd.AddCommand(setIndexCmd)
+	collectionCmd.AddCommand(setNormalizedCmd)
+	collectionCmd.AddCommand(setBoostsCmd)
+	collectionCmd.AddCommand(setSchemaCmd)
+	collectionCmd.AddCommand(suggestCollectionCmd)
+	collectionCmd.AddCommand(analyzeCollectionCmd)
+	collectionCmd.AddCommand(aliasCmd)
 	collectionCmd.AddCommand(addFolderCmd)
 	collectionCmd.AddCommand(removeFolderCmd)
 	collectionCmd.AddCommand(deleteCollectionCmd)
@@ -496,3 +1468,75 @@ func init() {
 	// Add to root
 	rootCmd.AddCommand(collectionCmd)
 }
+
+// copyFolderIndex copies the index of a folder added to a collection from
+// another collection that indexed it with the current embedding model
+func copyFolderIndex(db *sql.DB, collection *database.Collection, folder string) error {
+	// An index run on the collection would write the same chunks
+	lock, err := lockCollection(collection)
+	if err != nil {
+		return err
+	}
+	defer unlockCollection(lock)
+
+	copied, err := database.NewDocumentManager(db).CopyIndexedFolder(collection.ID, folder, getEmbeddingModel(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to copy index of folder: %w", err)
+	}
+	if copied == nil {
+		output.Info("Run 'rag-cli index %s' to index the folder.", collection.Name)
+		return nil
+	}
+
+	if err := database.NewCollectionManager(db).UpdateCollectionStats(collection.ID); err != nil {
+		output.Warning("Failed to update collection stats: %v", err)
+	}
+	if err := database.NewCollectionRouter(db).UpdateCentroid(collection.ID); err != nil {
+		output.Warning("Failed to update collection centroid: %v", err)
+	}
+	if _, err := database.NewVocabularyManager(db).RefreshVocabulary(collection.ID); err != nil {
+		output.Warning("Failed to update collection vocabulary: %v", err)
+	}
+
+	output.Info("")
+	output.Success("Copied the index of the folder from collection %s", copied.CollectionName)
+	output.KeyValuef("Files", "%d", copied.Files)
+	output.KeyValuef("Chunks", "%d", copied.Chunks)
+	output.Info("Run 'rag-cli index %s' to embed the files changed since.", collection.Name)
+	return nil
+}
+
+// localFolder returns where a collection folder is on this machine,
+// following the root mappings in the paths configuration
+func localFolder(folder string) (string, error) {
+	return database.NormalizePath(cfg.Paths.LocalRoot(folder))
+}
+
+// findCollectionFolder returns the folder of the collection that is the same
+// folder as the given one on this machine
+func findCollectionFolder(collection *database.Collection, folder string) (string, bool) {
+	target, err := database.NormalizePath(folder)
+	if err != nil {
+		return "", false
+	}
+	for _, existing := range collection.Folders {
+		root, err := localFolder(existing)
+		if err == nil && database.SamePath(root, target) {
+			return existing, true
+		}
+	}
+	return "", false
+}
+
+// containingCollectionFolder returns the folder of the collection that
+// contains the given normalized path, along with where that folder is on
+// this machine
+func containingCollectionFolder(collection *database.Collection, path string) (string, string, bool) {
+	for _, existing := range collection.Folders {
+		root, err := localFolder(existing)
+		if err == nil && database.ContainsPath(root, path) {
+			return existing, root, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/export"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// embeddingExport is the summary of collection export-embeddings
+type embeddingExport struct {
+	Collection string   `json:"collection"`
+	Format     string   `json:"format"`
+	Files      []string `json:"files"`
+	Chunks     int      `json:"chunks"`
+	Dimensions int      `json:"dimensions"`
+}
+
+var exportEmbeddingsCmd = &cobra.Command{
+	Use:   "export-embeddings [collection-id-or-name]",
+	Short: "Export a collection's embeddings for other tools",
+	Long: `Export the embeddings of a collection's chunks to a file, for use in tools
+such as pandas, Polars, DuckDB or NumPy, e.g. to cluster the chunks or train a
+reranker, without access to the database.
+
+With --format parquet, one Parquet file is written with a row per chunk and
+the columns id, folder, file_path, chunk_index, metadata (as JSON) and
+embedding (a list of floats).
+
+With --format npy, the embeddings are written as a float32 array of one row
+per chunk to an .npy file, which numpy.load reads, and the id, folder,
+file_path, chunk_index and metadata of the chunk of each row are written as a
+line of JSON to a .jsonl file of the same name.
+
+Chunks without an embedding are left out. The file is written to --out, or to
+<collection>-embeddings.<format> in the current folder.
+
+Examples:
+  # Export a collection to Parquet
+  rag-cli collection export-embeddings my-docs-collection
+
+  # Export to NumPy, writing vectors.npy and vectors.jsonl
+  rag-cli collection export-embeddings my-docs-collection --format npy --out vectors.npy`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		collection, err := resolveCollection(database.NewCollectionManager(db), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+		if out == "" {
+			out = export.DefaultPath(collection.Name, format)
+		}
+
+		writer, files, err := export.Create(format, out)
+		if err != nil {
+			return err
+		}
+
+		summary := embeddingExport{Collection: collection.Name, Format: format, Files: files}
+		err = database.ExportEmbeddings(db, collection.ID, func(record *database.EmbeddingRecord) error {
+			summary.Chunks++
+			summary.Dimensions = len(record.Embedding)
+			return writer.Write(record)
+		})
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			// Don't leave an incomplete export behind
+			for _, file := range files {
+				os.Remove(file)
+			}
+			return fmt.Errorf("failed to export embeddings: %w", err)
+		}
+
+		return output.Result(summary, func() {
+			output.Success("Exported the embeddings of collection %s", collection.Name)
+			output.KeyValue("Files", strings.Join(files, ", "))
+			output.KeyValuef("Chunks", "%d", summary.Chunks)
+			output.KeyValuef("Dimensions", "%d", summary.Dimensions)
+		})
+	},
+}
+
+func init() {
+	exportEmbeddingsCmd.Flags().String("format", export.FormatParquet, "Export format ("+strings.Join(export.Formats, " or ")+")")
+	exportEmbeddingsCmd.Flags().StringP("out", "o", "", "File to write to (default <collection>-embeddings.<format>)")
+	collectionCmd.AddCommand(exportEmbeddingsCmd)
+}
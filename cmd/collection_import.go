@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/export"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// embeddingImport is the summary of collection import-embeddings
+type embeddingImport struct {
+	Collection string `json:"collection"`
+	File       string `json:"file"`
+	Model      string `json:"model"`
+	Files      int    `json:"files"`
+	Chunks     int    `json:"chunks"`
+	Skipped    int    `json:"skipped,omitempty"` // Chunks left out because they look like they contain secrets
+	Dimensions int    `json:"dimensions"`
+}
+
+// importedChunk identifies a chunk of an import by its file and index
+type importedChunk struct {
+	filePath   string
+	chunkIndex int
+}
+
+var importEmbeddingsCmd = &cobra.Command{
+	Use:   "import-embeddings [collection-id-or-name] [file]",
+	Short: "Import embeddings computed outside rag-cli",
+	Long: `Import chunks with embeddings computed by another pipeline into a collection,
+so they can be searched and chatted with like indexed files.
+
+The file is a Parquet file with a row per chunk and the columns:
+  file_path (or path)   Where the chunk is from, shown in results
+  content (or text)     Text of the chunk, given to the chat model
+  embedding (or vector) List of floats or doubles
+  chunk_index           Position of the chunk in its file (optional)
+  metadata              JSON object of metadata fields (optional)
+  model                 Embedding model that computed the embedding (optional)
+Other columns are ignored. Pages may be uncompressed or compressed with Snappy
+or gzip. An .npy file of a float32 or float64 array with a row per chunk can be
+imported too, with a .jsonl file of the same name holding the other columns as
+a JSON object per row.
+
+Queries are embedded with the configured embedding model, so the file's
+embeddings must have been computed with the same model: name it with --model
+unless the file has a model column. The import is refused when the model or
+the dimensions don't match the configured model or the collection's existing
+embeddings, and nothing is imported when any row is invalid.
+
+Chunks are scanned for secrets as when indexing: chunks that look like they
+contain secrets are skipped, or the whole import is refused when
+secrets.action is refuse.
+
+Imported chunks replace earlier imports of the same chunks of a file and are
+kept by index runs, which only change the files of the collection's folders.
+Chunks without a chunk_index are numbered in the order of the file.
+
+Examples:
+  # Import the output of an embedding pipeline
+  rag-cli collection import-embeddings my-docs-collection chunks.parquet --model nomic-embed-text
+
+  # Import vectors.npy along with vectors.jsonl
+  rag-cli collection import-embeddings my-docs-collection vectors.npy --model nomic-embed-text`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := args[1]
+		model, _ := cmd.Flags().GetString("model")
+
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+		defer dbManager.Close()
+
+		collectionMgr := database.NewCollectionManager(db)
+		collection, err := resolveCollection(collectionMgr, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+		snapshotOf, err := database.NewSnapshotManager(db).SnapshotOf(collection.ID)
+		if err != nil {
+			return err
+		}
+		if snapshotOf != "" {
+			return fmt.Errorf("collection %s is a snapshot and can't be imported into", collection.Name)
+		}
+		schema, err := collectionMgr.GetMetadataSchema(collection.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get metadata schema: %w", err)
+		}
+
+		// Secrets are kept out of the collection, like when indexing
+		scanner, err := newSecretScanner()
+		if err != nil {
+			return err
+		}
+
+		// The whole file is checked before anything is stored, numbering the
+		// chunks without an index as it goes
+		summary := embeddingImport{Collection: collection.Name, File: file, Model: model}
+		var chunkIndexes []int
+		skipped := make(map[int]bool)
+		nextIndex := make(map[string]int)
+		rows := make(map[importedChunk]int)
+		err = export.Read(file, func(record *export.ImportRecord) error {
+			if record.Model != "" {
+				if summary.Model == "" {
+					summary.Model = record.Model
+				}
+				if record.Model != summary.Model {
+					return fmt.Errorf("row %d was embedded with model %s, not %s", record.Row, record.Model, summary.Model)
+				}
+			}
+			if summary.Dimensions == 0 {
+				summary.Dimensions = len(record.Embedding)
+			}
+			if len(record.Embedding) != summary.Dimensions {
+				return fmt.Errorf("embedding of row %d has %d dimensions, expected %d", record.Row, len(record.Embedding), summary.Dimensions)
+			}
+			if _, err := importMetadata(record, schema); err != nil {
+				return err
+			}
+
+			index := record.ChunkIndex
+			if index < 0 {
+				index = nextIndex[record.FilePath]
+			}
+			nextIndex[record.FilePath] = max(nextIndex[record.FilePath], index+1)
+			chunk := importedChunk{filePath: record.FilePath, chunkIndex: index}
+			if row, ok := rows[chunk]; ok {
+				return fmt.Errorf("rows %d and %d are both chunk %d of %s", row, record.Row, index, record.FilePath)
+			}
+			rows[chunk] = record.Row
+			chunkIndexes = append(chunkIndexes, index)
+
+			if scanner == nil {
+				return nil
+			}
+			if found := scanner.Scan(record.Content); len(found) > 0 {
+				if cfg.Secrets.GetAction() == config.SecretsActionRefuse {
+					return fmt.Errorf("refusing to import row %d, chunk %d of %s: it looks like it contains secrets (%s)", record.Row, index, record.FilePath, strings.Join(found, ", "))
+				}
+				output.Warning("Skipping row %d, chunk %d of %s: it looks like it contains secrets (%s)", record.Row, index, record.FilePath, strings.Join(found, ", "))
+				skipped[record.Row] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		summary.Skipped = len(skipped)
+		summary.Chunks = len(chunkIndexes) - summary.Skipped
+		if summary.Chunks == 0 {
+			return fmt.Errorf("%s has no chunks to import", file)
+		}
+		summary.Files = len(nextIndex)
+
+		if err := checkImportedEmbeddings(db, collection, summary.Model, summary.Dimensions); err != nil {
+			return err
+		}
+
+		// Index runs would interleave their writes with the import's
+		lock, err := lockCollection(collection)
+		if err != nil {
+			return err
+		}
+		defer unlockCollection(lock)
+
+		if err := dbManager.SetEmbeddingDimensions(collection.ID, summary.Dimensions, summary.Model); err != nil {
+			return fmt.Errorf("failed to set embedding dimensions: %w", err)
+		}
+
+		documentMgr := database.NewDocumentManager(db)
+		fileChunks := make(map[string][]int)
+		now := time.Now()
+		err = export.Read(file, func(record *export.ImportRecord) error {
+			if skipped[record.Row] {
+				return nil
+			}
+			metadata, err := importMetadata(record, schema)
+			if err != nil {
+				return err
+			}
+			index := chunkIndexes[record.Row-1]
+			doc := &database.Document{
+				CollectionID: collection.ID,
+				FilePath:     record.FilePath,
+				FileName:     path.Base(record.FilePath),
+				Content:      record.Content,
+				ChunkIndex:   index,
+				Embedding:    record.Embedding,
+				Metadata:     metadata,
+				CreatedAt:    now,
+				UpdatedAt:    now,
+				TokenCount:   client.EstimateTokens(record.Content),
+			}
+			if err := documentMgr.UpsertDocument(doc); err != nil {
+				return fmt.Errorf("failed to store row %d: %w", record.Row, err)
+			}
+			fileChunks[record.FilePath] = append(fileChunks[record.FilePath], index)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", file, err)
+		}
+
+		// Chunks of an earlier import of a file that has fewer chunks now
+		// are left from before
+		if err := checkCollectionLock(lock, collection); err != nil {
+			return err
+		}
+		for filePath, indexes := range fileChunks {
+			if err := documentMgr.PruneDocumentsByPath(collection.ID, "", filePath, indexes); err != nil {
+				output.Warning("Failed to delete old chunks of %s: %v", filePath, err)
+			}
+		}
+
+		if err := collectionMgr.UpdateCollectionStats(collection.ID); err != nil {
+			output.Warning("Failed to update collection stats: %v", err)
+		}
+		if err := database.NewCollectionRouter(db).UpdateCentroid(collection.ID); err != nil {
+			output.Warning("Failed to update collection centroid: %v", err)
+		}
+		if _, err := database.NewVocabularyManager(db).RefreshVocabulary(collection.ID); err != nil {
+			output.Warning("Failed to update collection vocabulary: %v", err)
+		}
+
+		return output.Result(summary, func() {
+			output.Success("Imported the embeddings of %s into collection %s", file, collection.Name)
+			output.KeyValue("Model", summary.Model)
+			output.KeyValuef("Files", "%d", summary.Files)
+			output.KeyValuef("Chunks", "%d", summary.Chunks)
+			if summary.Skipped > 0 {
+				output.KeyValuef("Skipped (secrets)", "%d", summary.Skipped)
+			}
+			output.KeyValuef("Dimensions", "%d", summary.Dimensions)
+		})
+	},
+}
+
+// checkImportedEmbeddings checks that embeddings of a model and dimensions
+// can be searched in a collection: queries are embedded with the configured
+// model, which the collection's existing embeddings were computed with too
+func checkImportedEmbeddings(db *sql.DB, collection *database.Collection, model string, dimensions int) error {
+	configured := getEmbeddingModel(cfg)
+	if model == "" {
+		return fmt.Errorf("the file doesn't name the model of its embeddings: give it with --model")
+	}
+	if model != configured {
+		return fmt.Errorf("embeddings were computed with model %s, but queries are embedded with %s: configure %s as the embedding model to import them", model, configured, model)
+	}
+
+	existing, err := database.GetEmbeddingConfig(db, collection.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Model != model {
+		return fmt.Errorf("collection %s is embedded with model %s, not %s", collection.Name, existing.Model, model)
+	}
+	if existing != nil && existing.Dimensions != dimensions {
+		return fmt.Errorf("collection %s has embeddings of %d dimensions, not %d", collection.Name, existing.Dimensions, dimensions)
+	}
+	if known, err := embedding.LookupDimensions(model, cfg.Embedding.ModelDimensions); err == nil && known != dimensions {
+		return fmt.Errorf("model %s has %d dimensions, but the embeddings have %d", model, known, dimensions)
+	}
+
+	stored, err := database.EmbeddingColumnDimensions(db)
+	if err != nil {
+		return err
+	}
+	if stored > 0 && stored != dimensions {
+		return fmt.Errorf("the database stores embeddings of %d dimensions, but the embeddings have %d", stored, dimensions)
+	}
+	return nil
+}
+
+// importMetadata returns the metadata of an imported chunk as stored: its
+// file's path and name, the fields of the file, with the values of the
+// collection's metadata schema checked and made canonical
+func importMetadata(record *export.ImportRecord, schema database.MetadataSchema) (string, error) {
+	values := make(map[string]string)
+	if record.Metadata != "" {
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(record.Metadata), &fields); err != nil {
+			return "", fmt.Errorf("metadata of row %d isn't a JSON object: %w", record.Row, err)
+		}
+		for name, value := range fields {
+			switch value := value.(type) {
+			case nil:
+			case string:
+				values[name] = value
+			default:
+				encoded, _ := json.Marshal(value)
+				values[name] = string(encoded)
+			}
+		}
+	}
+
+	valid, err := schema.Validate(values)
+	if err != nil {
+		return "", fmt.Errorf("metadata of row %d: %w", record.Row, err)
+	}
+	for name, value := range valid {
+		values[name] = value
+	}
+	values["file_path"] = record.FilePath
+	values["file_name"] = path.Base(record.FilePath)
+
+	metadata, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return string(metadata), nil
+}
+
+func init() {
+	importEmbeddingsCmd.Flags().String("model", "", "Embedding model that computed the embeddings (default: the file's model column)")
+	collectionCmd.AddCommand(importEmbeddingsCmd)
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage collection snapshots",
+	Long: `Manage point-in-time copies of collections.
+
+A snapshot copies the documents and embeddings of a collection as they are
+when it is taken. It is kept as a read-only collection named
+collection@snapshot, which search, ask and chat accept like any other
+collection, so the answers given from a collection at one time can be
+reproduced after it has been reindexed. Snapshots aren't listed with the
+collections, aren't routed to and can't be indexed. Deleting a collection
+deletes its snapshots.
+
+Examples:
+  # Snapshot a collection during an incident
+  rag-cli collection snapshot create prod-docs incident-42
+
+  # Search the collection as it was then
+  rag-cli search prod-docs@incident-42 "How do I fail over the database?"
+
+  # List the snapshots of a collection
+  rag-cli collection snapshot list prod-docs
+
+  # Put the collection back the way it was
+  rag-cli collection snapshot restore prod-docs incident-42 --force`,
+}
+
+var createSnapshotCmd = &cobra.Command{
+	Use:   "create [collection-id-or-name] [snapshot]",
+	Short: "Snapshot a collection",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, name := args[0], args[1]
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		collection, err := resolveCollection(database.NewCollectionManager(db), id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		// Wait for an index run to finish, so the snapshot doesn't catch
+		// half of its changes
+		lock, err := lockCollection(collection)
+		if err != nil {
+			return err
+		}
+		defer unlockCollection(lock)
+
+		snapshot, err := database.NewSnapshotManager(db).CreateSnapshot(collection.ID, name)
+		if err != nil {
+			return err
+		}
+
+		output.Success("Snapshot '%s' of collection '%s' created", snapshot.Name, collection.Name)
+		output.KeyValue("Collection", database.SnapshotCollectionName(collection.Name, snapshot.Name))
+		output.KeyValuef("Stats", "%d documents, %d chunks, %d bytes",
+			snapshot.Stats.TotalDocuments,
+			snapshot.Stats.TotalChunks,
+			snapshot.Stats.TotalSize)
+		return nil
+	},
+}
+
+var listSnapshotsCmd = &cobra.Command{
+	Use:   "list [collection-id-or-name]",
+	Short: "List the snapshots of a collection",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		collection, err := resolveCollection(database.NewCollectionManager(db), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		snapshots, err := database.NewSnapshotManager(db).ListSnapshots(collection.ID)
+		if err != nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			if snapshots == nil {
+				snapshots = []*database.Snapshot{}
+			}
+			return output.JSON(snapshots)
+		}
+
+		if len(snapshots) == 0 {
+			output.Info("Collection '%s' has no snapshots.", collection.Name)
+			return nil
+		}
+
+		output.Bold("Snapshots of '%s':", collection.Name)
+		for _, snapshot := range snapshots {
+			output.Info("")
+			output.KeyValue("Name", snapshot.Name)
+			output.KeyValue("Collection", database.SnapshotCollectionName(collection.Name, snapshot.Name))
+			output.KeyValuef("Stats", "%d documents, %d chunks, %d bytes",
+				snapshot.Stats.TotalDocuments,
+				snapshot.Stats.TotalChunks,
+				snapshot.Stats.TotalSize)
+			output.KeyValue("Created", snapshot.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	},
+}
+
+var restoreSnapshotCmd = &cobra.Command{
+	Use:   "restore [collection-id-or-name] [snapshot]",
+	Short: "Replace a collection's documents with those of a snapshot",
+	Long: `Replace the documents, folders, boosts and metadata schema of a collection
+with those of one of its snapshots. The snapshot is kept.
+
+The documents indexed since the snapshot was taken are deleted, so --force is
+needed to confirm.
+
+Examples:
+  # Restore the collection as it was during an incident
+  rag-cli collection snapshot restore prod-docs incident-42 --force`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, name := args[0], args[1]
+		force, _ := cmd.Flags().GetBool("force")
+
+		if !force {
+			output.Warning("This will replace the documents of the collection with those of the snapshot.")
+			output.Info("Use --force to confirm.")
+			return nil
+		}
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		collection, err := resolveCollection(database.NewCollectionManager(db), id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		lock, err := lockCollection(collection)
+		if err != nil {
+			return err
+		}
+		defer unlockCollection(lock)
+
+		snapshot, err := database.NewSnapshotManager(db).RestoreSnapshot(collection.ID, name)
+		if err != nil {
+			return err
+		}
+		collectionCache().Forget(collection.ID)
+
+		if err := database.NewCollectionRouter(db).UpdateCentroid(collection.ID); err != nil {
+			output.Warning("Failed to update collection centroid: %v", err)
+		}
+
+		output.Success("Collection '%s' restored from snapshot '%s'", collection.Name, snapshot.Name)
+		output.KeyValuef("Folders", "%v", snapshot.Folders)
+		output.KeyValuef("Stats", "%d documents, %d chunks, %d bytes",
+			snapshot.Stats.TotalDocuments,
+			snapshot.Stats.TotalChunks,
+			snapshot.Stats.TotalSize)
+		return nil
+	},
+}
+
+var deleteSnapshotCmd = &cobra.Command{
+	Use:   "delete [collection-id-or-name] [snapshot]",
+	Short: "Delete a snapshot of a collection",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, name := args[0], args[1]
+		force, _ := cmd.Flags().GetBool("force")
+
+		if !force {
+			output.Warning("This will delete the snapshot and all its documents.")
+			output.Info("Use --force to confirm.")
+			return nil
+		}
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		collection, err := resolveCollection(database.NewCollectionManager(db), id)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		if err := database.NewSnapshotManager(db).DeleteSnapshot(collection.ID, name); err != nil {
+			return err
+		}
+
+		output.Success("Snapshot '%s' of collection '%s' deleted", name, collection.Name)
+		return nil
+	},
+}
+
+func init() {
+	restoreSnapshotCmd.Flags().BoolP("force", "f", false, "Restore without confirmation")
+	deleteSnapshotCmd.Flags().BoolP("force", "f", false, "Force deletion without confirmation")
+
+	snapshotCmd.AddCommand(createSnapshotCmd)
+	snapshotCmd.AddCommand(listSnapshotsCmd)
+	snapshotCmd.AddCommand(restoreSnapshotCmd)
+	snapshotCmd.AddCommand(deleteSnapshotCmd)
+	collectionCmd.AddCommand(snapshotCmd)
+}
@@ -2,9 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
 
 	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
 	"github.com/busybytelab.com/rag-cli/pkg/output"
 	"github.com/spf13/cobra"
 )
@@ -20,6 +27,14 @@ func maskAPIKey(apiKey string) string {
 	return apiKey[:4] + "..." + apiKey[len(apiKey)-4:]
 }
 
+// valueOrDefault returns the value, or the fallback description when the value is empty
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage configuration",
@@ -45,6 +60,7 @@ var showConfigCmd = &cobra.Command{
 		output.Info("  TLS: %t", cfg.Ollama.TLS)
 		output.Info("  Chat Model: %s", cfg.Ollama.ChatModel)
 		output.Info("  Embed Model: %s", cfg.Ollama.EmbeddingModel)
+		output.Info("  Reconnect Timeout: %s", cfg.Ollama.GetReconnectTimeout())
 		output.Info("")
 
 		output.Bold("OpenAI Settings:")
@@ -52,6 +68,9 @@ var showConfigCmd = &cobra.Command{
 		output.Info("  Base URL: %s", cfg.OpenAI.BaseURL)
 		output.Info("  Chat Model: %s", cfg.OpenAI.ChatModel)
 		output.Info("  Embed Model: %s", cfg.OpenAI.EmbeddingModel)
+		if cfg.OpenAI.InputTypeField != "" {
+			output.Info("  Input Type Field: %s (%s for queries, %s for documents)", cfg.OpenAI.InputTypeField, cfg.OpenAI.QueryInputType, cfg.OpenAI.DocumentInputType)
+		}
 		output.Info("")
 
 		output.Bold("Database Settings:")
@@ -60,18 +79,203 @@ var showConfigCmd = &cobra.Command{
 		output.Info("  Name: %s", cfg.Database.Name)
 		output.Info("  User: %s", cfg.Database.User)
 		output.Info("  SSL Mode: %s", cfg.Database.SSLMode)
+		output.Info("  Max Open Conns: %d", cfg.Database.GetMaxOpenConns())
+		output.Info("  Max Idle Conns: %d", cfg.Database.GetMaxIdleConns())
+		output.Info("  Conn Max Lifetime: %s", cfg.Database.GetConnMaxLifetime())
+		output.Info("  Reconnect Timeout: %s", cfg.Database.GetReconnectTimeout())
 		output.Info("")
 
 		output.Bold("Embedding Settings:")
+		output.Info("  Chunk Strategy: %s", cfg.Embedding.GetChunkStrategy())
+		if cfg.Embedding.GetChunkStrategy() == config.ChunkStrategyRecursive {
+			output.Info("  Separators: %q", cfg.Embedding.GetSeparators())
+		}
+		output.Info("  Chunk Markdown: %t", cfg.Embedding.ChunkMarkdown)
+		output.Info("  Chunk Code: %t", cfg.Embedding.ChunkCode)
 		output.Info("  Chunk Size: %d", cfg.Embedding.ChunkSize)
 		output.Info("  Chunk Overlap: %d", cfg.Embedding.ChunkOverlap)
-		output.Info("  Similarity Threshold: %.2f", cfg.Embedding.SimilarityThreshold)
-		output.Info("  Max Results: %d", cfg.Embedding.MaxResults)
+		output.Info("  Concurrency: %d", cfg.Embedding.GetConcurrency())
+		output.Info("  Batch Size: %d", cfg.Embedding.GetBatchSize())
+		if cfg.Embedding.QueryPrefix != "" || cfg.Embedding.DocumentPrefix != "" {
+			output.Info("  Query Prefix: %q", cfg.Embedding.QueryPrefix)
+			output.Info("  Document Prefix: %q", cfg.Embedding.DocumentPrefix)
+		}
+		for _, model := range slices.Sorted(maps.Keys(cfg.Embedding.ModelDimensions)) {
+			output.Info("  Dimensions of %s: %d", model, cfg.Embedding.ModelDimensions[model])
+		}
+		output.Info("")
+
+		output.Bold("Search Settings:")
+		output.Info("  Normalization: %s", cfg.Search.GetNormalization())
+		output.Info("  Path Weight: %.2f", cfg.Search.PathWeight)
+		output.Info("")
+
+		output.Bold("Calibration Settings:")
+		if cfg.Calibration.SearchType != "" {
+			output.Info("  Search Type: %s", cfg.Calibration.SearchType)
+			output.Info("  Slope: %.4f", cfg.Calibration.Slope)
+			output.Info("  Intercept: %.4f", cfg.Calibration.Intercept)
+		} else {
+			output.Info("  Search Type: (not calibrated)")
+		}
+		output.Info("  Min Confidence: %.2f", cfg.Calibration.MinConfidence)
+		output.Info("")
+
+		output.Bold("Abstention Settings:")
+		output.Info("  Enabled: %t", cfg.Abstention.Enabled)
+		output.Info("  Message: %s", cfg.Abstention.GetMessage())
+		output.Info("  Near Misses: %d", cfg.Abstention.GetNearMisses())
+		output.Info("")
+
+		output.Bold("Rerank Settings:")
+		output.Info("  Method: %s", cfg.Rerank.GetMethod())
+		output.Info("  Backend: %s", valueOrDefault(cfg.Rerank.Backend, "(embedding backend)"))
+		output.Info("  Model: %s", valueOrDefault(cfg.Rerank.Model, "(backend default)"))
+		if cfg.Rerank.GetMethod() == config.RerankMethodCrossEncoder {
+			output.Info("  Base URL: %s", valueOrDefault(cfg.Rerank.BaseURL, "(backend URL)"))
+			output.Info("  API Key: %s", maskAPIKey(cfg.Rerank.APIKey))
+		}
+		output.Info("  Instruction: %s", cfg.Rerank.Instruction)
+		output.Info("  Original Weight: %.2f", cfg.Rerank.OriginalWeight)
+		output.Info("  Rerank Weight: %.2f", cfg.Rerank.RerankWeight)
+		output.Info("  Limit: %d", cfg.Rerank.Limit)
+		output.Info("  Retrieve: %d", cfg.Rerank.Retrieve)
+		output.Info("  Concurrency: %d", cfg.Rerank.GetConcurrency())
+		output.Info("")
+
+		output.Bold("Query Expansion Settings:")
+		output.Info("  Enabled: %t", cfg.Expansion.Enabled)
+		output.Info("  Paraphrases: %d", cfg.Expansion.Paraphrases)
+		output.Info("  Model: %s", valueOrDefault(cfg.Expansion.Model, "(chat model)"))
+		output.Info("  Synonym Terms: %d", len(cfg.Expansion.Synonyms))
+		output.Info("  Query Rewrite: %t", cfg.Expansion.Rewrite)
+		output.Info("  Rewrites: %d", cfg.Expansion.GetRewrites())
+		output.Info("")
+
+		output.Bold("Question Decomposition Settings:")
+		output.Info("  Enabled: %t", cfg.Decomposition.Enabled)
+		output.Info("  Max Sub-questions: %d", cfg.Decomposition.GetMaxSubQuestions())
+		output.Info("  Model: %s", valueOrDefault(cfg.Decomposition.Model, "(chat model)"))
+		output.Info("")
+
+		output.Bold("Translation Settings:")
+		output.Info("  Enabled: %t", cfg.Translation.Enabled)
+		output.Info("  Language: %s", valueOrDefault(cfg.Translation.Language, "(not set)"))
+		output.Info("  Translate Results: %t", cfg.Translation.TranslateResults)
+		output.Info("  Model: %s", valueOrDefault(cfg.Translation.Model, "(chat model)"))
+		output.Info("")
+
+		output.Bold("Spell Check Settings:")
+		output.Info("  Enabled: %t", cfg.SpellCheck.Enabled)
+		output.Info("  Mode: %s", cfg.SpellCheck.GetMode())
+		output.Info("  Model: %s", valueOrDefault(cfg.SpellCheck.Model, "(chat model)"))
+		output.Info("  Min Similarity: %.2f", cfg.SpellCheck.GetMinSimilarity())
+		output.Info("  Auto Correct: %t", cfg.SpellCheck.AutoCorrect)
+		output.Info("")
+
+		output.Bold("History Settings:")
+		output.Info("  Summarize: %t", cfg.History.Summarize)
+		output.Info("  Summarize After: %d tokens", cfg.History.GetSummarizeAfter())
+		output.Info("  Keep Turns: %d", cfg.History.GetKeepTurns())
+		output.Info("  Model: %s", valueOrDefault(cfg.History.Model, "(chat model)"))
+		output.Info("")
+
+		output.Bold("Voice Settings:")
+		output.Info("  Transcription URL: %s", valueOrDefault(cfg.Voice.TranscriptionURL, "(not set)"))
+		output.Info("  Transcription Model: %s", cfg.Voice.TranscriptionModel)
+		output.Info("  Language: %s", valueOrDefault(cfg.Voice.Language, "(detected)"))
+		output.Info("  Record Command: %s", valueOrDefault(cfg.Voice.RecordCommand, "(not set)"))
+		output.Info("  Speech URL: %s", valueOrDefault(cfg.Voice.SpeechURL, "(not set)"))
+		output.Info("  Speech Model: %s", cfg.Voice.SpeechModel)
+		output.Info("  Voice: %s", cfg.Voice.Voice)
+		output.Info("  Play Command: %s", valueOrDefault(cfg.Voice.PlayCommand, "(not set)"))
+		if cfg.Voice.APIKey != "" {
+			output.Info("  API Key: %s", maskAPIKey(cfg.Voice.APIKey))
+		}
+		output.Info("  Timeout: %s", cfg.Voice.GetTimeout())
+		output.Info("")
+
+		output.Bold("Budget Settings:")
+		output.Info("  Max Daily Cost: %s", budgetLimit(cfg.Budget.MaxDailyCost > 0, fmt.Sprintf("$%.2f", cfg.Budget.MaxDailyCost)))
+		output.Info("  Max Request Tokens: %s", budgetLimit(cfg.Budget.MaxRequestTokens > 0, fmt.Sprint(cfg.Budget.MaxRequestTokens)))
+		output.Info("  Max Embedding Calls: %s", budgetLimit(cfg.Budget.MaxEmbeddingCalls > 0, fmt.Sprint(cfg.Budget.MaxEmbeddingCalls)))
+		output.Info("  Confirm Index Runs Above: %s", budgetLimit(cfg.Budget.ConfirmAbove > 0, fmt.Sprintf("$%.2f", cfg.Budget.ConfirmAbove)))
+		if spent, err := backends.Budget().SpentToday(); err == nil {
+			output.Info("  Spent Today: $%.4f", spent)
+		}
+		for _, model := range slices.Sorted(maps.Keys(cfg.Budget.Prices)) {
+			price := cfg.Budget.Prices[model]
+			output.Info("  Price of %s: $%.2f input, $%.2f output per million tokens", model, price.Input, price.Output)
+		}
+		output.Info("")
+
+		output.Bold("Secrets Settings:")
+		output.Info("  Enabled: %t", cfg.Secrets.Enabled)
+		output.Info("  Action: %s", cfg.Secrets.GetAction())
+		for _, rule := range cfg.Secrets.Rules {
+			output.Info("  Rule %s: %s", rule.Name, rule.Pattern)
+		}
+		for _, pattern := range cfg.Secrets.Allow {
+			output.Info("  Allow: %s", pattern)
+		}
+		output.Info("")
+
+		output.Bold("Ignore Settings:")
+		output.Info("  Ignore Files: %s", strings.Join(cfg.Ignore.GetFiles(), ", "))
+		for _, pattern := range cfg.Ignore.Patterns {
+			output.Info("  Pattern: %s", pattern)
+		}
+		output.Info("")
+
+		output.Bold("Encryption Settings:")
+		output.Info("  Enabled: %t", cfg.Encryption.Enabled)
+		switch {
+		case cfg.Encryption.KeyFile != "":
+			output.Info("  Key File: %s", cfg.Encryption.GetKeyFile())
+		case cfg.Encryption.KeyCommand != "":
+			output.Info("  Key Command: %s", cfg.Encryption.KeyCommand)
+		case cfg.Encryption.Key != "":
+			output.Info("  Key: ****")
+		}
+		output.Info("")
+
+		output.Bold("Telemetry Settings:")
+		output.Info("  Enabled: %t", cfg.Telemetry.Enabled)
+		output.Info("  Endpoint: %s", valueOrDefault(cfg.Telemetry.Endpoint, "(build default)"))
+		output.Info("")
+
+		output.Bold("Path Settings:")
+		if len(cfg.Paths.Roots) == 0 {
+			output.Info("  Root Mappings: (none)")
+		}
+		for _, root := range cfg.Paths.Roots {
+			output.Info("  %s -> %s", root.Folder, root.Path)
+		}
+		output.Info("")
+
+		output.Bold("Server Settings:")
+		output.Info("  Listen: %s", cfg.Server.GetListen())
+		output.Info("  TLS: %t", cfg.Server.TLSEnabled())
+		output.Info("  Shutdown Timeout: %s", cfg.Server.GetShutdownTimeout())
+		output.Info("  Session TTL: %s", cfg.Server.GetSessionTTL())
+		output.Info("")
+
+		output.Bold("Bot Settings:")
+		output.Info("  Slack App Token: %s", maskAPIKey(cfg.Bots.Slack.AppToken))
+		output.Info("  Slack Bot Token: %s", maskAPIKey(cfg.Bots.Slack.BotToken))
+		output.Info("  Slack Collection: %s", cfg.Bots.Slack.Collection)
+		output.Info("  Slack Mapped Channels: %d", len(cfg.Bots.Slack.Channels))
+		output.Info("  Telegram Token: %s", maskAPIKey(cfg.Bots.Telegram.Token))
+		output.Info("  Telegram Collection: %s", cfg.Bots.Telegram.Collection)
+		output.Info("  Telegram Mapped Channels: %d", len(cfg.Bots.Telegram.Channels))
+		output.Info("  Discord Token: %s", maskAPIKey(cfg.Bots.Discord.Token))
+		output.Info("  Discord Collection: %s", cfg.Bots.Discord.Collection)
+		output.Info("  Discord Mapped Channels: %d", len(cfg.Bots.Discord.Channels))
 		output.Info("")
 
 		output.Bold("General Settings:")
-		output.Info("  Log Level: %s", cfg.General.LogLevel)
-		output.Info("  Data Directory: %s", cfg.General.DataDir)
+		output.Info("  Data Directory: %s", cfg.General.GetDataDir())
+		output.Info("  Prompts Directory: %s", cfg.General.GetPromptsDir())
 
 		return nil
 	},
@@ -97,43 +301,171 @@ var editConfigCmd = &cobra.Command{
 	Short: "Edit configuration",
 	Long:  `Open the configuration file in your default editor.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
+		configFile := cfgFile
+		if configFile == "" {
+			var err error
+			configFile, err = config.ConfigFilePath(configName)
+			if err != nil {
+				return err
+			}
 		}
 
-		configFile := fmt.Sprintf("%s/.rag-cli/config.yaml", home)
-
 		// Check if config file exists
 		if _, err := os.Stat(configFile); os.IsNotExist(err) {
 			output.Warning("Configuration file does not exist. Creating default configuration...")
 			// This will create the default config
-			_, err = config.LoadConfig("")
+			_, err = config.LoadConfig(configName)
 			if err != nil {
 				return fmt.Errorf("failed to create default configuration: %w", err)
 			}
 		}
 
-		// Try to open the file with the default editor
-		editor := os.Getenv("EDITOR")
-		if editor == "" {
-			editor = "nano" // Default fallback
+		editor := editorCommand()
+		output.Info("Opening configuration file with: %s", strings.Join(editor, " "))
+		output.Info("File: %s", configFile)
+
+		editorCmd := exec.Command(editor[0], append(editor[1:], configFile)...)
+		editorCmd.Stdin = os.Stdin
+		editorCmd.Stdout = os.Stdout
+		editorCmd.Stderr = os.Stderr
+		if err := editorCmd.Run(); err != nil {
+			output.Info("Please edit the configuration file manually at: %s", configFile)
+			return fmt.Errorf("failed to run editor: %w", err)
 		}
 
-		output.Info("Opening configuration file with: %s", editor)
-		output.Info("File: %s", configFile)
+		return nil
+	},
+}
+
+var lintConfigCmd = &cobra.Command{
+	Use:   "lint [file]",
+	Short: "Check the configuration file for mistakes",
+	Long: `Check a configuration file for mistakes, without connecting to any backend.
+
+Lint reports keys that aren't settings (usually typos, with the setting they
+were probably meant to be), deprecated settings that are ignored, and values
+that are invalid or suspicious, such as a chunk overlap that isn't smaller
+than the chunk size or embedding dimensions that don't match the embedding
+model. Each issue comes with a suggested fix.
+
+The current configuration file is checked unless a file is given. Lint fails
+when it finds errors; warnings alone don't fail it.
+
+Examples:
+  # Check the current configuration
+  rag-cli config lint
+
+  # Check another file
+  rag-cli config lint ./ragcli-config-example.yaml
+
+  # Print the issues as JSON
+  rag-cli config lint --output json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile := cfgFile
+		if len(args) > 0 {
+			configFile = args[0]
+		}
+		if configFile == "" {
+			var err error
+			configFile, err = config.ConfigFilePath(configName)
+			if err != nil {
+				return err
+			}
+		}
+
+		issues, err := config.LintFile(configFile, embedding.LookupDimensions)
+		if err != nil {
+			return err
+		}
+		if issues == nil {
+			issues = []config.LintIssue{}
+		}
+
+		errorCount := 0
+		for _, issue := range issues {
+			if issue.Severity == config.LintError {
+				errorCount++
+			}
+		}
+
+		err = output.Result(issues, func() {
+			if len(issues) == 0 {
+				output.Success("No issues found in %s", configFile)
+				return
+			}
+			output.Bold("%s:", configFile)
+			for _, issue := range issues {
+				key := issue.Key
+				if key == "" {
+					key = "(config)"
+				}
+				if issue.Severity == config.LintError {
+					output.Error("  %s: %s", key, issue.Message)
+				} else {
+					output.Warning("  %s: %s", key, issue.Message)
+				}
+				if issue.Fix != "" {
+					output.Info("    fix: %s", issue.Fix)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if errorCount > 0 {
+			return fmt.Errorf("found %d errors in %s", errorCount, configFile)
+		}
+		return nil
+	},
+}
 
-		// Note: In a real implementation, you would use exec.Command to open the editor
-		// For now, we'll just show the path
-		output.Info("Please edit the configuration file manually at: %s", configFile)
+// editorCommand returns the editor to open files with, with its arguments:
+// $VISUAL or $EDITOR (e.g. "code --wait"), otherwise Notepad on Windows and
+// nano elsewhere
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(name)); len(editor) > 0 {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"nano"}
+}
+
+var generateKeyConfigCmd = &cobra.Command{
+	Use:   "generate-key",
+	Short: "Generate a key for encrypting document content",
+	Long: `Generate a random 256-bit key, base64 encoded, for encrypting the content
+of documents stored in the database.
+
+Keep the key in a file (encryption.key_file) or a keychain or KMS
+(encryption.key_command) rather than in the configuration itself. Documents
+can't be read without the key they were encrypted with.
+
+Examples:
+  # Store a new key in a file
+  rag-cli config generate-key > ~/.rag-cli/content.key
 
+  # Store a new key in the macOS keychain
+  security add-generic-password -s rag-cli -a content-key -w "$(rag-cli config generate-key)"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := database.GenerateContentKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
 		return nil
 	},
 }
 
 var validateConfigCmd = &cobra.Command{
-	Use:   "validate",
-	Short: "Validate configuration",
+	Use:         "validate",
+	Short:       "Validate configuration",
+	Annotations: requires(config.RequireAll),
 	Long: `Validate the current configuration by validating settings and testing connections.
 
 This command validates the configuration format and tests connectivity to both
@@ -156,6 +488,13 @@ Examples:
 			return err
 		}
 		output.Success("✓ Configuration format is valid")
+		if cfg.Encryption.Enabled {
+			if _, err := database.NewContentCipherFromConfig(&cfg.Encryption); err != nil {
+				output.Error("Encryption key is invalid: %v", err)
+				return err
+			}
+			output.Success("✓ Encryption key is valid")
+		}
 		output.Info("")
 
 		// Test database connection
@@ -173,8 +512,46 @@ Examples:
 		output.Success("✓ Database connection successful")
 		output.Info("")
 
+		// Check pgvector extension
+		output.Info("3. Checking pgvector extension...")
+		db, err := openDatabase()
+		if err != nil {
+			output.Error("Database connection failed: %v", err)
+			return err
+		}
+		status, err := database.CheckVectorExtension(db)
+		if err != nil {
+			output.Error("pgvector check failed: %v", err)
+			return err
+		}
+		switch {
+		case status.Installed:
+			output.Success("✓ pgvector extension installed (version %s)", status.InstalledVersion)
+		case status.Available:
+			output.Success("✓ pgvector extension available (version %s)", status.DefaultVersion)
+			output.Info("  It will be created by 'rag-cli migrate up'. If your user lacks the privilege,")
+			output.Info("  ask a superuser to run: CREATE EXTENSION IF NOT EXISTS vector;")
+		default:
+			output.Error("pgvector extension is not installed on the database server")
+			output.Info("")
+			output.Info("Troubleshooting tips:")
+			output.Info("  - Install pgvector: https://github.com/pgvector/pgvector#installation")
+			output.Info("  - On macOS with Homebrew: brew install pgvector")
+			return database.ErrExtensionUnavailable
+		}
+		scale, err := database.CheckExtension(db, database.VectorScaleExtension)
+		if err == nil && scale.Available {
+			output.Info("  pgvectorscale is available, collections can use DiskANN indexes")
+		}
+		trgm, err := database.CheckExtension(db, database.TrigramExtension)
+		if err == nil && !trgm.Available {
+			output.Warning("  pg_trgm is not available, it is needed by the migrations for spell checking")
+			output.Info("  Install the PostgreSQL contrib package: %s", database.ExtensionInstallURL(database.TrigramExtension))
+		}
+		output.Info("")
+
 		// Test Ollama connection (basic check)
-		output.Info("3. Testing Ollama connection...")
+		output.Info("4. Testing Ollama connection...")
 		ollamaURL := cfg.Ollama.GetServerURL()
 		output.Bold("Ollama URL: %s", ollamaURL)
 		if err := cfg.Ollama.TestOllamaConnection(); err != nil {
@@ -200,5 +577,15 @@ func init() {
 	configCmd.AddCommand(initConfigCmd)
 	configCmd.AddCommand(editConfigCmd)
 	configCmd.AddCommand(validateConfigCmd)
+	configCmd.AddCommand(lintConfigCmd)
+	configCmd.AddCommand(generateKeyConfigCmd)
 	rootCmd.AddCommand(configCmd)
 }
+
+// budgetLimit describes a budget limit, which is unlimited when not set
+func budgetLimit(set bool, limit string) string {
+	if !set {
+		return "unlimited"
+	}
+	return limit
+}
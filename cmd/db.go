@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/dockerdb"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:         "db",
+	Short:       "Run a local PostgreSQL database with Docker",
+	Annotations: requires(config.RequireNone),
+	Long: `Run a ready-to-use PostgreSQL database with pgvector in a Docker container.
+
+'db up' starts the container, waits for it to accept connections, writes the
+connection settings into the configuration and runs the migrations, so a
+first-time setup is a single command. Data is kept in a named Docker volume
+and survives 'db down'.
+
+Examples:
+  # Start a local database and configure rag-cli to use it
+  rag-cli db up
+
+  # Use another host port, e.g. when 5432 is taken
+  rag-cli db up --port 5433
+
+  # Show whether the database container is running
+  rag-cli db status
+
+  # Stop the database container
+  rag-cli db down
+
+  # Remove the container and all of its data
+  rag-cli db down --remove --volumes`,
+}
+
+var dbUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Start a local PostgreSQL database and configure rag-cli to use it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		image, _ := cmd.Flags().GetString("image")
+		volume, _ := cmd.Flags().GetString("volume")
+		port, _ := cmd.Flags().GetInt("port")
+		password, _ := cmd.Flags().GetString("password")
+		noSave, _ := cmd.Flags().GetBool("no-save")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		ctx := context.Background()
+		docker := dockerdb.New()
+		if err := docker.Available(ctx); err != nil {
+			output.Info("Install Docker (https://docs.docker.com/get-docker/) and make sure it is running, or set up PostgreSQL yourself (see docs/DATABASE_SETUP.md)")
+			return err
+		}
+
+		state, err := docker.State(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		// The password is fixed when the data directory is first initialized,
+		// so an existing container keeps using the configured one
+		if password == "" {
+			password = cfg.Database.Password
+		}
+		if password == "" {
+			if state != dockerdb.StateMissing {
+				return fmt.Errorf("container %s already exists but no database password is configured, pass --password", name)
+			}
+			password, err = generatePassword()
+			if err != nil {
+				return err
+			}
+		}
+
+		opts := dockerdb.Options{
+			Name:     name,
+			Image:    image,
+			Volume:   volume,
+			Port:     port,
+			User:     cfg.Database.User,
+			Password: password,
+			Database: cfg.Database.Name,
+		}
+
+		output.Info("Starting %s (%s)...", name, image)
+		created, err := docker.Start(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if created {
+			output.Info("Created container %s with data volume %s", name, volume)
+		}
+
+		output.Info("Waiting for the database to accept connections...")
+		if err := docker.WaitReady(ctx, opts, timeout); err != nil {
+			return err
+		}
+
+		// Point this run, and the configuration file, at the container
+		cfg.Database.Host = "localhost"
+		cfg.Database.Port = port
+		cfg.Database.Password = password
+		cfg.Database.SSLMode = "disable"
+
+		if !noSave {
+			configFile, err := saveDatabaseConfig(cfg.Database)
+			if err != nil {
+				return err
+			}
+			output.Info("Saved database settings to %s", configFile)
+		}
+
+		// Create the pgvector extension and schema
+		db, err := openDatabase()
+		if err != nil {
+			return err
+		}
+		if err := checkVectorExtension(db); err != nil {
+			return err
+		}
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+		defer dbManager.Close()
+
+		output.Success("Database is ready!")
+		output.KeyValue("Host", fmt.Sprintf("%s:%d", cfg.Database.Host, cfg.Database.Port))
+		output.KeyValue("Database", cfg.Database.Name)
+		output.KeyValue("User", cfg.Database.User)
+		if noSave {
+			output.KeyValue("Password", password)
+		}
+
+		return nil
+	},
+}
+
+var dbDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Stop the local PostgreSQL database",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		volume, _ := cmd.Flags().GetString("volume")
+		remove, _ := cmd.Flags().GetBool("remove")
+		volumes, _ := cmd.Flags().GetBool("volumes")
+
+		if volumes && !remove {
+			return fmt.Errorf("--volumes can only be used with --remove")
+		}
+
+		ctx := context.Background()
+		docker := dockerdb.New()
+		if err := docker.Available(ctx); err != nil {
+			return err
+		}
+
+		state, err := docker.State(ctx, name)
+		if err != nil {
+			return err
+		}
+		if state == dockerdb.StateMissing {
+			output.Info("Container %s does not exist", name)
+			return nil
+		}
+
+		if remove {
+			if !volumes {
+				volume = ""
+			}
+			if err := docker.Remove(ctx, name, volume); err != nil {
+				return err
+			}
+			if volumes {
+				output.Success("Removed container %s and its data", name)
+			} else {
+				output.Success("Removed container %s, its data is kept in volume %s", name, volume)
+			}
+			return nil
+		}
+
+		if state == dockerdb.StateRunning {
+			if err := docker.Stop(ctx, name); err != nil {
+				return err
+			}
+		}
+		output.Success("Stopped container %s", name)
+		return nil
+	},
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the local PostgreSQL database",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+
+		ctx := context.Background()
+		docker := dockerdb.New()
+		if err := docker.Available(ctx); err != nil {
+			return err
+		}
+
+		state, err := docker.State(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		output.KeyValue("Container", name)
+		output.KeyValue("State", string(state))
+		if state == dockerdb.StateMissing {
+			output.Info("Run 'rag-cli db up' to create it")
+		}
+		return nil
+	},
+}
+
+// saveDatabaseConfig stores the database settings in the configuration file
+// without saving any other command line overrides
+func saveDatabaseConfig(settings config.DatabaseConfig) (string, error) {
+	configFile, err := config.ConfigFilePath(configName)
+	if err != nil {
+		return "", err
+	}
+
+	fileConfig, err := config.LoadConfig(configName)
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	fileConfig.Database = settings
+
+	if err := config.SaveConfig(fileConfig, configFile); err != nil {
+		return "", fmt.Errorf("failed to save config: %w", err)
+	}
+	return configFile, nil
+}
+
+// generatePassword returns a random password for a new database
+func generatePassword() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{dbUpCmd, dbDownCmd, dbStatusCmd} {
+		c.Flags().String("name", dockerdb.DefaultName, "Name of the database container")
+	}
+	for _, c := range []*cobra.Command{dbUpCmd, dbDownCmd} {
+		c.Flags().String("volume", dockerdb.DefaultVolume, "Docker volume holding the database files")
+	}
+
+	dbUpCmd.Flags().String("image", dockerdb.DefaultImage, "PostgreSQL image with pgvector")
+	dbUpCmd.Flags().Int("port", dockerdb.DefaultPort, "Host port to publish PostgreSQL on")
+	dbUpCmd.Flags().String("password", "", "Database password (default: the configured one, or a generated one for a new database)")
+	dbUpCmd.Flags().Bool("no-save", false, "Don't write the connection settings into the configuration file")
+	dbUpCmd.Flags().Duration("timeout", 60*time.Second, "How long to wait for the database to be ready")
+
+	dbDownCmd.Flags().Bool("remove", false, "Remove the container instead of only stopping it")
+	dbDownCmd.Flags().Bool("volumes", false, "With --remove, also delete the data volume")
+
+	dbCmd.AddCommand(dbUpCmd)
+	dbCmd.AddCommand(dbDownCmd)
+	dbCmd.AddCommand(dbStatusCmd)
+	rootCmd.AddCommand(dbCmd)
+}
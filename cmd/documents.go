@@ -1,16 +1,24 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/busybytelab.com/rag-cli/pkg/config"
 	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/extract"
 	"github.com/busybytelab.com/rag-cli/pkg/output"
 	"github.com/spf13/cobra"
 )
 
 var documentsCmd = &cobra.Command{
-	Use:   "docs",
-	Short: "Manage documents",
+	Use:         "docs",
+	Short:       "Manage documents",
+	Annotations: requires(config.RequireDatabase),
 	Long: `Manage documents in collections.
 
 Documents are the indexed content from files in collection folders.
@@ -23,14 +31,23 @@ Examples:
   # List documents with file filter
   rag-cli docs list --collection my-docs-collection --folder ./docs --filter "*.md"
 
+  # List all indexed files of a collection, across its folders
+  rag-cli docs files --collection my-docs-collection
+
   # Show document chunk content
   rag-cli docs show --id 550e8400-e29b-41d4-a716-446655440000
 
   # Show document chunk content by collection and file path
   rag-cli docs show --collection my-docs-collection --file ./docs/README.md
 
+  # Rebuild a file from its chunks
+  rag-cli docs export --collection my-docs-collection --file ./docs/README.md --out README.rebuilt.md
+
   # Remove document chunk
-  rag-cli docs remove --id 550e8400-e29b-41d4-a716-446655440000`,
+  rag-cli docs remove --id 550e8400-e29b-41d4-a716-446655440000
+
+  # Remove all chunks of files under vendor directories
+  rag-cli docs remove --collection my-docs-collection --filter "**/vendor/**"`,
 }
 
 var listDocumentsCmd = &cobra.Command{
@@ -38,13 +55,23 @@ var listDocumentsCmd = &cobra.Command{
 	Short: "List documents in a collection folder",
 	Long: `List documents from a specific folder in a collection.
 
-Shows all documents in the specified folder with their metadata, sorted by file path.
+Shows all documents in the specified folder and its subfolders with their metadata,
+sorted by file path. Use --exact to only show files directly in the folder. The
+folder may be any folder of the collection or a subfolder of one.
 Supports pagination with limit and offset parameters, and file pattern filtering.
+The total number of matching documents and the offset of the next page are
+shown after each page.
 
 Examples:
   # List documents in a folder (default limit: 50)
   rag-cli docs list --collection my-docs-collection --folder ./docs
 
+  # List documents directly in the folder, skipping subfolders
+  rag-cli docs list --collection my-docs-collection --folder ./docs --exact
+
+  # List documents in a subfolder of a collection folder
+  rag-cli docs list --collection my-docs-collection --folder ./docs/api
+
   # List documents with custom limit
   rag-cli docs list --collection my-docs-collection --folder ./docs --limit 100
 
@@ -68,6 +95,7 @@ Examples:
 		fileFilter, _ := cmd.Flags().GetString("filter")
 		limit, _ := cmd.Flags().GetInt("limit")
 		offset, _ := cmd.Flags().GetInt("offset")
+		exact, _ := cmd.Flags().GetBool("exact")
 
 		if collectionID == "" {
 			return fmt.Errorf("collection must be specified")
@@ -76,51 +104,77 @@ Examples:
 			return fmt.Errorf("folder must be specified")
 		}
 
+		folder, err := database.NormalizePath(folder)
+		if err != nil {
+			return err
+		}
+
+		match := database.FolderMatchRecursive
+		if exact {
+			match = database.FolderMatchExact
+		}
+
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openMigratedDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create collection manager
 		collectionMgr := database.NewCollectionManager(db)
 
 		// Get collection by ID or name first to validate it exists
-		collection, err := collectionMgr.GetCollectionByIdOrName(collectionID)
+		collection, err := resolveCollection(collectionMgr, collectionID)
 		if err != nil {
 			return fmt.Errorf("failed to get collection: %w", err)
 		}
 
-		// Validate that the folder exists in the collection
-		folderExists := false
-		for _, existingFolder := range collection.Folders {
-			if existingFolder == folder {
-				folderExists = true
-				break
-			}
+		// Validate that the folder is one of the collection's folders or inside one
+		collectionFolder, root, ok := containingCollectionFolder(collection, folder)
+		if !ok {
+			return fmt.Errorf("folder '%s' is not in collection '%s'", folder, collection.Name)
 		}
-
-		if !folderExists {
-			return fmt.Errorf("folder '%s' does not exist in collection '%s'", folder, collection.Name)
+		subfolder, err := database.RelativePath(root, folder)
+		if err != nil {
+			return err
 		}
+		scope := database.FolderScope{Folder: collectionFolder, Subfolder: subfolder, Match: match}
 
 		// Create document manager
 		documentMgr := database.NewDocumentManager(db)
 
 		// List documents in the folder
-		var documents []*database.Document
+		var page *database.DocumentPage
 		if fileFilter != "" {
-			documents, err = documentMgr.ListDocumentsByFolderWithFilter(collection.ID, folder, fileFilter, limit, offset)
+			page, err = documentMgr.ListDocumentsByFolderWithFilter(collection.ID, scope, fileFilter, limit, offset)
 		} else {
-			documents, err = documentMgr.ListDocumentsByFolder(collection.ID, folder, limit, offset)
+			page, err = documentMgr.ListDocumentsByFolder(collection.ID, scope, limit, offset)
 		}
 		if err != nil {
 			return fmt.Errorf("failed to list documents: %w", err)
 		}
 
+		documents := page.Documents
+		listing := documentListing{
+			Collection: collection.Name,
+			Folder:     folder,
+			Filter:     fileFilter,
+			Documents:  summarizeDocuments(documents),
+			Total:      page.Total,
+			Limit:      limit,
+			Offset:     offset,
+		}
+		if page.HasMore() {
+			listing.NextOffset = page.NextOffset()
+		}
+		if output.IsJSON() {
+			return output.JSON(listing)
+		}
+
 		if len(documents) == 0 {
-			if fileFilter != "" {
+			if page.Total > 0 {
+				output.Info("No documents at offset %d, folder '%s' has %d matching documents", offset, folder, page.Total)
+			} else if fileFilter != "" {
 				output.Info("No documents found in folder '%s' matching filter '%s'", folder, fileFilter)
 			} else {
 				output.Info("No documents found in folder '%s'", folder)
@@ -135,9 +189,9 @@ Examples:
 		output.Info("")
 
 		for i, doc := range documents {
-			output.Info("Document %d:", i+1)
+			output.Info("Document %d:", offset+i+1)
 			output.KeyValue("ID", doc.ID)
-			output.KeyValue("File Path", doc.FilePath)
+			output.KeyValue("File Path", localPath(doc))
 			output.KeyValue("File Name", doc.FileName)
 			output.KeyValuef("Chunk Index", "%d", doc.ChunkIndex)
 			output.KeyValuef("Content Length", "%d", len(doc.Content))
@@ -150,14 +204,161 @@ Examples:
 		}
 
 		output.Info("")
-		output.KeyValuef("Total Documents", "%d", len(documents))
+		output.KeyValuef("Showing", "%d-%d of %d", offset+1, offset+len(documents), page.Total)
+		output.KeyValuef("Page", "%d of %d", page.Page(), page.TotalPages())
 		output.KeyValuef("Limit", "%d", limit)
 		output.KeyValuef("Offset", "%d", offset)
+		if page.HasMore() {
+			output.KeyValuef("Next Offset", "%d", page.NextOffset())
+			output.Info("")
+			output.Info("More documents available, use --offset %d to see the next page", page.NextOffset())
+		}
 
 		return nil
 	},
 }
 
+var listFilesCmd = &cobra.Command{
+	Use:   "files",
+	Short: "List the indexed files of a collection",
+	Long: `List every indexed file of a collection, regardless of which folder it was
+indexed from, with the number of chunks it was split into. Files are sorted by
+their path relative to their folder.
+
+With --path, the chunks of the files with that relative path are listed
+instead, from every folder of the collection that has one.
+
+Examples:
+  # List all indexed files of a collection
+  rag-cli docs files --collection my-docs-collection
+
+  # List the files as JSON
+  rag-cli docs files --collection my-docs-collection --json
+
+  # List the chunks of README.md in every folder of the collection
+  rag-cli docs files --collection my-docs-collection --path README.md`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		collectionID, _ := cmd.Flags().GetString("collection")
+		filePath, _ := cmd.Flags().GetString("path")
+
+		if collectionID == "" {
+			return fmt.Errorf("collection must be specified")
+		}
+
+		// Connect to database
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		collectionMgr := database.NewCollectionManager(db)
+		collection, err := resolveCollection(collectionMgr, collectionID)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		documentMgr := database.NewDocumentManager(db)
+
+		if filePath != "" {
+			// Stored paths are relative to their folder, with / separators
+			filePath = filepath.ToSlash(filepath.Clean(filePath))
+			documents, err := documentMgr.ListDocumentsByFile(collection.ID, filePath)
+			if err != nil {
+				return fmt.Errorf("failed to list documents: %w", err)
+			}
+			if output.IsJSON() {
+				return output.JSON(summarizeDocuments(documents))
+			}
+			if len(documents) == 0 {
+				output.Info("No file '%s' found in collection '%s'", filePath, collection.Name)
+				return nil
+			}
+
+			output.Bold("Chunks of '%s' in collection '%s':", filePath, collection.Name)
+			for _, doc := range documents {
+				output.Info("")
+				output.KeyValue("ID", doc.ID)
+				output.KeyValue("File Path", localPath(doc))
+				output.KeyValuef("Chunk Index", "%d", doc.ChunkIndex)
+				output.KeyValuef("Content Length", "%d", len(doc.Content))
+				output.KeyValue("Updated", doc.UpdatedAt.Format("2006-01-02 15:04:05"))
+			}
+			return nil
+		}
+
+		files, err := documentMgr.ListFiles(collection.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+
+		if output.IsJSON() {
+			if files == nil {
+				files = []*database.IndexedFile{}
+			}
+			return output.JSON(files)
+		}
+
+		if len(files) == 0 {
+			output.Info("No files indexed in collection '%s'", collection.Name)
+			return nil
+		}
+
+		output.Bold("Files in collection '%s':", collection.Name)
+		output.Info("")
+		chunks := 0
+		for _, file := range files {
+			local := localPath(&database.Document{Folder: file.Folder, FilePath: file.FilePath})
+			output.Info("%s (%d chunks, updated %s)", local, file.Chunks, file.UpdatedAt.Format("2006-01-02 15:04:05"))
+			chunks += file.Chunks
+		}
+		output.Info("")
+		output.KeyValuef("Total", "%d files, %d chunks", len(files), chunks)
+
+		return nil
+	},
+}
+
+// documentSummary is a chunk as listed by the docs commands, without its
+// content and embedding
+type documentSummary struct {
+	ID            string    `json:"id"`
+	FilePath      string    `json:"file_path"`
+	FileName      string    `json:"file_name"`
+	ChunkIndex    int       `json:"chunk_index"`
+	ContentLength int       `json:"content_length"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// documentListing is a page of documents printed by docs list
+type documentListing struct {
+	Collection string            `json:"collection"`
+	Folder     string            `json:"folder"`
+	Filter     string            `json:"filter,omitempty"`
+	Documents  []documentSummary `json:"documents"`
+	Total      int               `json:"total"`
+	Limit      int               `json:"limit"`
+	Offset     int               `json:"offset"`
+	NextOffset int               `json:"next_offset,omitempty"` // Offset of the next page, if there is one
+}
+
+// summarizeDocuments returns the summaries of documents, with their local paths
+func summarizeDocuments(documents []*database.Document) []documentSummary {
+	summaries := make([]documentSummary, len(documents))
+	for i, doc := range documents {
+		summaries[i] = documentSummary{
+			ID:            doc.ID,
+			FilePath:      localPath(doc),
+			FileName:      doc.FileName,
+			ChunkIndex:    doc.ChunkIndex,
+			ContentLength: len(doc.Content),
+			CreatedAt:     doc.CreatedAt,
+			UpdatedAt:     doc.UpdatedAt,
+		}
+	}
+	return summaries
+}
+
 var showDocumentCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show document chunk content",
@@ -192,11 +393,10 @@ Examples:
 		}
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openMigratedDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create document manager
 		documentMgr := database.NewDocumentManager(db)
@@ -212,13 +412,26 @@ Examples:
 		} else {
 			// Get collection first
 			collectionMgr := database.NewCollectionManager(db)
-			collection, err := collectionMgr.GetCollectionByIdOrName(collectionID)
+			collection, err := resolveCollection(collectionMgr, collectionID)
 			if err != nil {
 				return fmt.Errorf("failed to get collection: %w", err)
 			}
 
+			filePath, err := database.NormalizePath(filePath)
+			if err != nil {
+				return err
+			}
+			folder, root, ok := containingCollectionFolder(collection, filePath)
+			if !ok {
+				return fmt.Errorf("file '%s' is not in collection '%s'", filePath, collection.Name)
+			}
+			relativePath, err := database.RelativePath(root, filePath)
+			if err != nil {
+				return err
+			}
+
 			// Get document by collection ID and file path (first chunk)
-			document, err = documentMgr.GetDocumentByPathAndIndex(collection.ID, filePath, 0)
+			document, err = documentMgr.GetDocumentByPathAndIndex(collection.ID, folder, relativePath, 0)
 			if err != nil {
 				return fmt.Errorf("failed to get document: %w", err)
 			}
@@ -227,7 +440,7 @@ Examples:
 		// Display document information
 		output.Bold("Document Details:")
 		output.KeyValue("ID", document.ID)
-		output.KeyValue("File Path", document.FilePath)
+		output.KeyValue("File Path", localPath(document))
 		output.KeyValue("File Name", document.FileName)
 		output.KeyValuef("Chunk Index", "%d", document.ChunkIndex)
 		output.KeyValuef("Content Length", "%d", len(document.Content))
@@ -242,34 +455,179 @@ Examples:
 	},
 }
 
+var exportDocumentCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Rebuild a file from its stored chunks",
+	Long: `Rebuild an indexed file from its chunks and write it to a file, so the stored
+representation can be checked against the original.
+
+The chunks are joined in order, without the text each chunk repeats from the
+previous one. Chunking doesn't keep all whitespace between sentences, so when
+the original file is readable it is compared with the rebuilt one ignoring
+whitespace. Chunks that are missing, for example because they were skipped
+for containing secrets, are reported.
+
+Examples:
+  # Rebuild a file and compare it with the original
+  rag-cli docs export --collection my-docs-collection --file ./docs/README.md --out README.rebuilt.md`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		collectionID, _ := cmd.Flags().GetString("collection")
+		filePath, _ := cmd.Flags().GetString("file")
+		outPath, _ := cmd.Flags().GetString("out")
+
+		if collectionID == "" || filePath == "" || outPath == "" {
+			return fmt.Errorf("--collection, --file and --out must be specified")
+		}
+
+		// Connect to database
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		collectionMgr := database.NewCollectionManager(db)
+		collection, err := resolveCollection(collectionMgr, collectionID)
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		filePath, err = database.NormalizePath(filePath)
+		if err != nil {
+			return err
+		}
+		folder, root, ok := containingCollectionFolder(collection, filePath)
+		if !ok {
+			return fmt.Errorf("file '%s' is not in collection '%s'", filePath, collection.Name)
+		}
+		relativePath, err := database.RelativePath(root, filePath)
+		if err != nil {
+			return err
+		}
+
+		documentMgr := database.NewDocumentManager(db)
+		documents, err := documentMgr.ListDocumentsByFile(collection.ID, relativePath)
+		if err != nil {
+			return fmt.Errorf("failed to list documents: %w", err)
+		}
+
+		var chunks []*embedding.Chunk
+		for _, doc := range documents {
+			if doc.Folder != folder {
+				continue
+			}
+			var metadata map[string]string
+			if doc.Metadata != "" {
+				if err := json.Unmarshal([]byte(doc.Metadata), &metadata); err != nil {
+					return fmt.Errorf("failed to parse metadata of document %s: %w", doc.ID, err)
+				}
+			}
+			chunks = append(chunks, &embedding.Chunk{Content: doc.Content, Index: doc.ChunkIndex, Metadata: metadata})
+		}
+		if len(chunks) == 0 {
+			return fmt.Errorf("file '%s' is not indexed in collection '%s'", filePath, collection.Name)
+		}
+
+		rebuilt := embedding.StitchChunks(chunks)
+		if err := os.WriteFile(outPath, []byte(rebuilt+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", outPath, err)
+		}
+
+		output.Success("Rebuilt %s from %d chunks", filePath, len(chunks))
+		output.KeyValue("Output", outPath)
+		output.KeyValuef("Length", "%d", len(rebuilt))
+
+		var missing []int
+		for i := 1; i < len(chunks); i++ {
+			for index := chunks[i-1].Index + 1; index < chunks[i].Index; index++ {
+				missing = append(missing, index)
+			}
+		}
+		if len(missing) > 0 {
+			output.Warning("Chunks %v are missing, the rebuilt file has gaps", missing)
+		}
+
+		original, err := os.ReadFile(filePath)
+		var originalText string
+		if err == nil {
+			originalText, _, err = fileText(filePath, original)
+		}
+		switch {
+		case err != nil:
+			output.Info("The original file can't be read, so it wasn't compared: %v", err)
+		case embedding.SameText(originalText, rebuilt):
+			output.KeyValue("Original", "matches (ignoring whitespace)")
+		default:
+			output.Warning("The rebuilt file differs from the original; it may have changed since it was indexed")
+		}
+
+		return nil
+	},
+}
+
 var removeDocumentCmd = &cobra.Command{
 	Use:   "remove",
-	Short: "Remove a document chunk",
-	Long: `Remove a specific document chunk from a collection.
+	Short: "Remove document chunks",
+	Long: `Remove a specific document chunk, or all chunks of the files matching a path
+glob, from a collection.
 
-This operation will permanently delete the document chunk and its associated embedding.
+The glob is matched against file paths relative to their collection folder.
+* and ? match within a directory, ** matches across directories, and globs
+without a / match file names at any depth. All matching chunks are deleted in
+one transaction; use --dry-run to see which files match first.
+
+This operation will permanently delete the document chunks and their associated embeddings.
 Use with caution as this operation is irreversible.
 
 Examples:
   # Remove document chunk by ID
-  rag-cli docs remove --id 550e8400-e29b-41d4-a716-446655440000`,
+  rag-cli docs remove --id 550e8400-e29b-41d4-a716-446655440000
+
+  # Preview which files a glob would remove
+  rag-cli docs remove --collection my-docs-collection --filter "**/vendor/**" --dry-run
+
+  # Remove all chunks of minified JavaScript files
+  rag-cli docs remove --collection my-docs-collection --filter "*.min.js"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		documentID, _ := cmd.Flags().GetString("id")
+		collectionID, _ := cmd.Flags().GetString("collection")
+		pattern, _ := cmd.Flags().GetString("filter")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
 
-		if documentID == "" {
-			return fmt.Errorf("document ID must be specified")
+		if documentID == "" && (collectionID == "" || pattern == "") {
+			return fmt.Errorf("either --id or both --collection and --filter must be specified")
+		}
+		if documentID != "" && (collectionID != "" || pattern != "") {
+			return fmt.Errorf("cannot specify both --id and --collection/--filter")
+		}
+		if dryRun && pattern == "" {
+			return fmt.Errorf("--dry-run can only be used with --filter")
 		}
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openMigratedDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create document manager
 		documentMgr := database.NewDocumentManager(db)
 
+		if pattern != "" {
+			collectionMgr := database.NewCollectionManager(db)
+			collection, err := resolveCollection(collectionMgr, collectionID)
+			if err != nil {
+				return fmt.Errorf("failed to get collection: %w", err)
+			}
+			if !dryRun {
+				lock, err := lockCollection(collection)
+				if err != nil {
+					return err
+				}
+				defer unlockCollection(lock)
+			}
+			return removeDocumentsByPattern(documentMgr, collection, pattern, dryRun)
+		}
+
 		// Get document first to validate it exists and show details
 		document, err := documentMgr.GetDocumentByID(documentID)
 		if err != nil {
@@ -284,7 +642,7 @@ Examples:
 
 		output.Success("Document chunk deleted successfully!")
 		output.KeyValue("ID", document.ID)
-		output.KeyValue("File Path", document.FilePath)
+		output.KeyValue("File Path", localPath(document))
 		output.KeyValue("File Name", document.FileName)
 		output.KeyValuef("Chunk Index", "%d", document.ChunkIndex)
 
@@ -292,28 +650,111 @@ Examples:
 	},
 }
 
+// removeDocumentsByPattern removes the chunks of the files of a collection
+// matching a path glob, or only lists them with dryRun
+func removeDocumentsByPattern(documentMgr database.DocumentManager, collection *database.Collection, pattern string, dryRun bool) error {
+	files, err := documentMgr.DeleteDocumentsByPattern(collection.ID, pattern, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to remove documents: %w", err)
+	}
+
+	if len(files) == 0 {
+		output.Info("No files in collection '%s' match '%s'", collection.Name, pattern)
+		return nil
+	}
+
+	chunks := 0
+	for _, file := range files {
+		chunks += file.Chunks
+	}
+
+	if dryRun {
+		output.Bold("Files matching '%s' in collection '%s':", pattern, collection.Name)
+		for _, file := range files {
+			local := localPath(&database.Document{Folder: file.Folder, FilePath: file.FilePath})
+			output.Info("%s (%d chunks)", local, file.Chunks)
+		}
+		output.Info("")
+		output.Info("Dry run: %d chunks of %d files would be removed", chunks, len(files))
+		return nil
+	}
+
+	output.Success("Removed %d chunks of %d files matching '%s'", chunks, len(files), pattern)
+	return nil
+}
+
+// localPath returns where a document's file is on this machine
+func localPath(doc *database.Document) string {
+	if doc.Folder == "" {
+		// Indexed before paths were stored relative to their folder
+		return doc.FilePath
+	}
+	return filepath.Join(cfg.Paths.LocalRoot(doc.Folder), filepath.FromSlash(doc.FilePath))
+}
+
+// chunkLocation describes where a chunk is in its file, such as its page or
+// heading, or returns an empty string when its file has no locations
+func chunkLocation(doc *database.Document) string {
+	if doc.Metadata == "" {
+		return ""
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(doc.Metadata), &metadata); err != nil {
+		return ""
+	}
+	return extract.Location(metadata)
+}
+
+// contextSource names the file a chunk given to the model as context comes
+// from, with its location in the file
+func contextSource(doc *database.Document) string {
+	if location := chunkLocation(doc); location != "" {
+		return doc.FileName + ", " + location
+	}
+	return doc.FileName
+}
+
 func init() {
 	// List documents flags
 	listDocumentsCmd.Flags().String("collection", "", "Collection ID or name")
 	listDocumentsCmd.Flags().StringP("folder", "f", "", "Folder to list documents from")
-	listDocumentsCmd.Flags().String("filter", "", "File pattern filter (e.g., '*.md', '*coll*.go')")
+	listDocumentsCmd.Flags().String("filter", "", "File name glob filter, * and ? are wildcards (e.g., '*.md', '*coll*.go')")
 	listDocumentsCmd.Flags().IntP("limit", "l", 50, "Maximum number of documents to return")
 	listDocumentsCmd.Flags().IntP("offset", "o", 0, "Number of documents to skip")
+	listDocumentsCmd.Flags().Bool("exact", false, "Only list files directly in the folder, not in its subfolders")
 	listDocumentsCmd.MarkFlagRequired("collection")
 	listDocumentsCmd.MarkFlagRequired("folder")
 
+	// List files flags
+	listFilesCmd.Flags().String("collection", "", "Collection ID or name")
+	listFilesCmd.Flags().String("path", "", "List the chunks of the files with this path relative to their folder")
+	listFilesCmd.Flags().Bool("json", false, "Print files as JSON (same as --output json)")
+	listFilesCmd.MarkFlagRequired("collection")
+
 	// Show document flags
 	showDocumentCmd.Flags().String("id", "", "Document ID")
 	showDocumentCmd.Flags().String("collection", "", "Collection ID or name")
 	showDocumentCmd.Flags().StringP("file", "f", "", "File path within the collection")
 
+	// Export document flags
+	exportDocumentCmd.Flags().String("collection", "", "Collection ID or name")
+	exportDocumentCmd.Flags().StringP("file", "f", "", "File path within the collection")
+	exportDocumentCmd.Flags().StringP("out", "o", "", "File to write the rebuilt content to")
+	exportDocumentCmd.MarkFlagRequired("collection")
+	exportDocumentCmd.MarkFlagRequired("file")
+	exportDocumentCmd.MarkFlagRequired("out")
+
 	// Remove document flags
 	removeDocumentCmd.Flags().String("id", "", "Document ID")
-	removeDocumentCmd.MarkFlagRequired("id")
+	removeDocumentCmd.Flags().String("collection", "", "Collection ID or name")
+	removeDocumentCmd.Flags().String("filter", "", "Remove all chunks of the files whose path matches this glob (e.g., '**/vendor/**', '*.min.js')")
+	removeDocumentCmd.Flags().Bool("dry-run", false, "List the files the filter matches without removing them")
 
 	// Add subcommands
 	documentsCmd.AddCommand(listDocumentsCmd)
+	documentsCmd.AddCommand(listFilesCmd)
 	documentsCmd.AddCommand(showDocumentCmd)
+	documentsCmd.AddCommand(exportDocumentCmd)
 	documentsCmd.AddCommand(removeDocumentCmd)
 
 	// Add to root
@@ -3,52 +3,135 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/busybytelab.com/rag-cli/pkg/client"
 	"github.com/busybytelab.com/rag-cli/pkg/config"
 	"github.com/busybytelab.com/rag-cli/pkg/database"
 	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/extract"
+	"github.com/busybytelab.com/rag-cli/pkg/ignore"
 	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/busybytelab.com/rag-cli/pkg/secrets"
 	"github.com/spf13/cobra"
 )
 
 var indexCmd = &cobra.Command{
-	Use:   "index [collection-id-or-name]",
-	Short: "Index documents in a collection",
+	Use:         "index [collection-id-or-name]",
+	Short:       "Index documents in a collection",
+	Annotations: requires(config.RequireDatabase),
 	Long: `Index documents from the folders specified in a collection.
 
 This command processes all text files in the collection's folders, chunks them,
 generates embeddings, and stores them in the database for searching.
 
+The size, modification time and content hash of every indexed file are stored,
+so later runs only index the files that changed. Files whose modification time
+changed but whose content didn't are not embedded again. Use --force to index
+every file again, e.g. after changing the embedding model, chunking settings or
+metadata schema. Before a forced run, index shows the files it adds, deletes
+(indexed files no longer in the folders) and embeds again, and asks before
+replacing existing embeddings unless --yes is given; with --dry-run it lists
+them and stops.
+
+Files ignored by the .gitignore and .ragignore files of a folder, by the
+patterns of ignore.patterns or by --exclude are left out. Set ignore.gitignore
+to false to index the files .gitignore ignores.
+
+A chunk whose content is already indexed in another collection, with the same
+embedding model and dimensions, gets a copy of that embedding instead of being
+embedded again, and so does a file path indexed in another collection, so
+overlapping collections such as several over a shared monorepo are embedded
+once. Each collection still stores its own chunks. --share-embeddings=false
+embeds everything; --force does too unless --share-embeddings is given. The
+--dry-run estimate counts every chunk.
+
+--file and --filter restrict the run to some files of the collection's folders:
+those in the files or folders given with --file, and those whose path relative
+to their collection folder matches the --filter glob.
+
+Files are chunked and embedded embedding.concurrency at a time (4 by default).
+A file that fails doesn't stop the others; the failed files are listed at the
+end of the run.
+
 Examples:
   # Index documents in a collection
   rag-cli index my-docs-collection
 
-  # Force re-indexing (delete existing documents first)
+  # Index every file again, including unchanged ones
   rag-cli index my-docs-collection --force
 
-  # Force re-indexing using long flag
-  rag-cli index my-docs-collection --force`,
+  # Show what a forced re-index would add, delete and re-embed
+  rag-cli index my-docs-collection --force --dry-run
+
+  # Estimate the files, tokens and cost of a run without indexing
+  rag-cli index my-docs-collection --dry-run
+
+  # Chunk and embed 8 files at a time
+  rag-cli index my-docs-collection --concurrency 8
+
+  # Leave out generated files and a vendored folder
+  rag-cli index my-docs-collection --exclude '*.min.js' --exclude vendor/
+
+  # Index only a file that changed
+  rag-cli index my-docs-collection --file docs/guide.md
+
+  # Index only the Markdown files of a subfolder
+  rag-cli index my-docs-collection --file docs/guides --filter "*.md"
+
+  # Stop after 500 embedding requests
+  rag-cli index my-docs-collection --max-embedding-calls 500
+
+  # Compare the index with the files in the collection's folders
+  rag-cli index verify my-docs-collection`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		collectionID := args[0]
 		force, _ := cmd.Flags().GetBool("force")
+		exclude, _ := cmd.Flags().GetStringArray("exclude")
+		for _, pattern := range exclude {
+			if err := ignore.ValidatePattern(pattern); err != nil {
+				return fmt.Errorf("invalid --exclude: %w", err)
+			}
+		}
+		files, _ := cmd.Flags().GetStringArray("file")
+		filter, _ := cmd.Flags().GetString("filter")
+		only, err := newFileSelection(files, filter)
+		if err != nil {
+			return err
+		}
+
+		maxEmbeddingCalls := cfg.Budget.MaxEmbeddingCalls
+		if cmd.Flags().Changed("max-embedding-calls") {
+			maxEmbeddingCalls, _ = cmd.Flags().GetInt("max-embedding-calls")
+		}
+		backends.Budget().LimitEmbeddingCalls(maxEmbeddingCalls)
+
+		concurrency := cfg.Embedding.GetConcurrency()
+		if cmd.Flags().Changed("concurrency") {
+			concurrency, _ = cmd.Flags().GetInt("concurrency")
+			if concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1")
+			}
+		}
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
 		}
-		defer db.Close()
 
 		// Create database manager
-		dbManager, err := database.NewDatabaseManager(&cfg.Database)
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
 		if err != nil {
 			return fmt.Errorf("failed to create database manager: %w", err)
 		}
@@ -57,28 +140,86 @@ Examples:
 		// Create managers
 		collectionMgr := database.NewCollectionManager(db)
 		documentMgr := database.NewDocumentManager(db)
+		fileStateMgr := database.NewFileStateManager(db)
 
 		// Get collection by ID or name
-		collection, err := collectionMgr.GetCollectionByIdOrName(collectionID)
+		collection, err := resolveCollection(collectionMgr, collectionID)
 		if err != nil {
 			return fmt.Errorf("failed to get collection: %w", err)
 		}
 
+		// Snapshots keep the documents they were taken with
+		snapshotOf, err := database.NewSnapshotManager(db).SnapshotOf(collection.ID)
+		if err != nil {
+			return err
+		}
+		if snapshotOf != "" {
+			return fmt.Errorf("collection %s is a snapshot and can't be indexed", collection.Name)
+		}
+		rememberCollection(collection)
+
 		output.KeyValue("Indexing collection", collection.Name)
 		output.KeyValuef("Folders", "%v", collection.Folders)
 
 		// Create embedder for generating embeddings
-		embedder, err := client.NewEmbedder(cfg)
+		embedder, err := backends.Embedder()
 		if err != nil {
-			return fmt.Errorf("failed to create embedder: %w", err)
+			return err
 		}
 
 		// Create embedding service
 		embeddingService := embedding.New(embedder, &cfg.Embedding)
+		setChunkTokenizer(embeddingService)
+
+		// A forced run replaces the collection's embeddings, so show what it
+		// changes before a mis-pointed folder wipes them
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		var diff *indexDiff
+		if force {
+			if diff, err = diffForcedIndex(documentMgr, collection, exclude, only); err != nil {
+				return err
+			}
+			printIndexDiff(diff, dryRun)
+			if !dryRun {
+				if err := confirmForceIndex(cmd, diff); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Estimate runs billed by the embedding backend before paying for them
+		if model, paid := backends.EmbeddingModel(); paid || dryRun {
+			estimate, err := estimateIndexRun(collection.ID, collection.Folders, embeddingService, fileStateMgr, force, exclude, only)
+			if err != nil {
+				return err
+			}
+
+			var cost float64
+			costKnown := true
+			if paid {
+				cost, err = backends.Budget().EstimateCost(model, estimate.Tokens)
+				costKnown = err == nil
+			}
+			printIndexEstimate(estimate, model, cost, costKnown, dryRun)
+			if dryRun {
+				return nil
+			}
+			if err := confirmIndexCost(cmd, cost, costKnown); err != nil {
+				return err
+			}
+		}
+
+		// Another run indexing the collection would interleave its deletes
+		// and inserts of the same files with this one's
+		lock, err := lockCollection(collection)
+		if err != nil {
+			return err
+		}
+		defer unlockCollection(lock)
 
 		// Set embedding dimensions for the collection based on the model
 		embeddingModel := getEmbeddingModel(cfg)
-		dimensions, err := embedding.GetModelDimensions(embeddingModel)
+		dimensions, err := embedding.ResolveDimensions(context.Background(), embeddingModel, cfg.Embedding.ModelDimensions, embedder)
 		if err != nil {
 			output.Warning("Could not determine embedding dimensions for model %s: %v", embeddingModel, err)
 			output.Info("Using configured dimensions: %d", cfg.Embedding.Dimensions)
@@ -92,22 +233,74 @@ Examples:
 			output.Warning("Failed to set embedding dimensions: %v", err)
 		}
 
+		// Identical chunks in other collections embedded by the same model
+		// are copied, except when --force embeds everything again
+		var sharing *embeddingSharing
+		share, _ := cmd.Flags().GetBool("share-embeddings")
+		if share && (!force || cmd.Flags().Changed("share-embeddings")) {
+			sharing = &embeddingSharing{model: embeddingModel, dimensions: dimensions}
+		}
+
+		// Secrets are kept out of the index and away from the embedding backend
+		scanner, err := newSecretScanner()
+		if err != nil {
+			return err
+		}
+
+		// Custom metadata fields are validated as files are indexed
+		schema, err := collectionMgr.GetMetadataSchema(collection.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get metadata schema: %w", err)
+		}
+
 		// Process each folder
 		totalFiles := 0
 		totalChunks := 0
+		totalUnchanged := 0
+		totalShared := 0
+		var failed []fileFailure
 		startTime := time.Now()
 
 		for _, folder := range collection.Folders {
-			output.Info("Processing folder: %s", folder)
+			if err := checkCollectionLock(lock, collection); err != nil {
+				return err
+			}
 
-			files, chunks, err := processFolder(folder, collection.ID, documentMgr, embeddingService, force)
+			// The folder may be somewhere else on this machine
+			root, err := localFolder(folder)
+			if err != nil {
+				output.Error("Failed to resolve folder %s: %v", folder, err)
+				continue
+			}
+			output.Info("Processing folder: %s", root)
+
+			counts, err := processFolder(folder, root, collection.ID, documentMgr, fileStateMgr, embeddingService, scanner, schema, force, concurrency, exclude, only, sharing)
+			totalFiles += counts.files
+			totalChunks += counts.chunks
+			totalShared += counts.shared
+			totalUnchanged += counts.unchanged
+			failed = append(failed, counts.failed...)
+			if errors.Is(err, client.ErrBudgetExceeded) {
+				output.Error("Indexing stopped: %v", err)
+				break
+			}
 			if err != nil {
 				output.Error("Failed to process folder %s: %v", folder, err)
 				continue
 			}
+		}
 
-			totalFiles += files
-			totalChunks += chunks
+		for _, path := range only.unmatched() {
+			output.Warning("No files to index in %s: it isn't in the collection's folders, or is ignored or not a text file", path)
+		}
+
+		// A forced run leaves only the files in the folders in the index
+		totalDeleted := 0
+		if err := checkCollectionLock(lock, collection); err != nil {
+			return err
+		}
+		if diff != nil {
+			totalDeleted = deleteMissingFiles(documentMgr, collection.ID, diff.Delete)
 		}
 
 		// Update collection stats
@@ -115,10 +308,39 @@ Examples:
 			output.Warning("Failed to update collection stats: %v", err)
 		}
 
+		// Update the centroid and description embeddings used for routing queries
+		router := database.NewCollectionRouter(db)
+		if err := router.UpdateCentroid(collection.ID); err != nil {
+			output.Warning("Failed to update collection centroid: %v", err)
+		}
+		if err := refreshDescriptionEmbeddings(context.Background(), router, embeddingService); err != nil {
+			output.Warning("Failed to update collection description embeddings: %v", err)
+		}
+
+		// Rebuild the vocabulary used for spell checking queries
+		if _, err := database.NewVocabularyManager(db).RefreshVocabulary(collection.ID); err != nil {
+			output.Warning("Failed to update collection vocabulary: %v", err)
+		}
+
 		duration := time.Since(startTime)
 		output.Success("Indexing completed!")
 		output.KeyValuef("Total files processed", "%d", totalFiles)
 		output.KeyValuef("Total chunks created", "%d", totalChunks)
+		if totalUnchanged > 0 {
+			output.KeyValuef("Unchanged files skipped", "%d", totalUnchanged)
+		}
+		if totalDeleted > 0 {
+			output.KeyValuef("Deleted files", "%d", totalDeleted)
+		}
+		if totalShared > 0 {
+			output.KeyValuef("Embeddings copied from other collections", "%d chunks", totalShared)
+		}
+		if len(failed) > 0 {
+			output.KeyValuef("Failed files", "%d", len(failed))
+			for _, failure := range failed {
+				output.Error("  %s: %v", failure.path, failure.err)
+			}
+		}
 		output.KeyValue("Duration", duration.String())
 
 		return nil
@@ -137,113 +359,565 @@ func getEmbeddingModel(cfg *config.Config) string {
 	}
 }
 
-// processFolder processes all files in a folder
-func processFolder(folderPath, collectionID string, documentMgr database.DocumentManager, embeddingService *embedding.Service, force bool) (int, int, error) {
-	totalFiles := 0
-	totalChunks := 0
+// lockCollection takes the lock of a collection whose documents are about to
+// change, telling the user when it waits for another process to finish
+func lockCollection(collection *database.Collection) (*database.CollectionLock, error) {
+	dsn := cfg.Database.GetDSN()
+	lock, err := database.TryLockCollection(context.Background(), dsn, collection.ID)
+	if errors.Is(err, database.ErrCollectionLocked) {
+		output.Info("Waiting for another process changing collection %s to finish...", collection.Name)
+		lock, err = database.LockCollection(context.Background(), dsn, collection.ID)
+	}
+	return lock, err
+}
 
-	err := filepath.WalkDir(folderPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
+// checkCollectionLock fails when the lock of a collection was lost, e.g. to
+// a database restart, before changes made under it are committed
+func checkCollectionLock(lock *database.CollectionLock, collection *database.Collection) error {
+	if err := lock.Check(context.Background()); err != nil {
+		return fmt.Errorf("collection %s may have been changed by another process: %w", collection.Name, err)
+	}
+	return nil
+}
+
+// unlockCollection releases the lock of a collection
+func unlockCollection(lock *database.CollectionLock) {
+	if err := lock.Unlock(); err != nil {
+		output.Warning("Failed to release collection lock: %v", err)
+	}
+}
+
+// setChunkTokenizer gives an embedding service the tokenizer of the embedding
+// model when embedding.chunk_strategy sizes chunks in tokens
+func setChunkTokenizer(service *embedding.Service) {
+	if cfg.Embedding.GetChunkStrategy() != config.ChunkStrategyTokens {
+		return
+	}
+	tokenizer, err := client.NewTokenizer(cfg.EmbeddingBackend, getEmbeddingModel(cfg))
+	if err != nil {
+		output.Warning("Estimating the tokens of chunks: %v", err)
+	}
+	service.SetTokenizer(tokenizer)
+}
+
+// newSecretScanner creates the scanner for secrets in chunks, or returns
+// nil when scanning is disabled
+func newSecretScanner() (*secrets.Scanner, error) {
+	if !cfg.Secrets.Enabled {
+		return nil, nil
+	}
+	scanner, err := secrets.New(&cfg.Secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret scanner: %w", err)
+	}
+	return scanner, nil
+}
+
+// withoutSecrets returns the chunks of a file that don't contain secrets.
+// Chunks with secrets are skipped with a warning, or the whole file is
+// refused when secrets.action is refuse.
+func withoutSecrets(scanner *secrets.Scanner, path, content string, chunks []*embedding.Chunk) ([]*embedding.Chunk, error) {
+	if cfg.Secrets.GetAction() == config.SecretsActionRefuse {
+		if found := scanner.Scan(content); len(found) > 0 {
+			return nil, fmt.Errorf("refusing to index %s: it looks like it contains secrets (%s)", path, strings.Join(found, ", "))
+		}
+		return chunks, nil
+	}
+
+	kept := chunks[:0]
+	for _, chunk := range chunks {
+		if found := scanner.Scan(chunk.Content); len(found) > 0 {
+			output.Warning("Skipping chunk %d of %s: it looks like it contains secrets (%s)", chunk.Index, path, strings.Join(found, ", "))
+			continue
 		}
+		kept = append(kept, chunk)
+	}
+	return kept, nil
+}
 
-		if d.IsDir() {
+// folderCounts counts what processFolder did with the files of a folder
+type folderCounts struct {
+	files     int           // Files indexed
+	chunks    int           // Chunks created
+	unchanged int           // Files skipped because they haven't changed since they were indexed
+	shared    int           // Chunks whose embeddings were copied from other collections
+	failed    []fileFailure // Files that couldn't be indexed, in the order they failed
+}
+
+// embeddingSharing is the embedding model an index run embeds with, whose
+// embeddings of identical chunks in other collections are copied instead of
+// embedding the chunks again
+type embeddingSharing struct {
+	model      string
+	dimensions int
+}
+
+// fileFailure is why a file couldn't be indexed
+type fileFailure struct {
+	path string
+	err  error
+}
+
+// indexJob is a file of a folder to index
+type indexJob struct {
+	path         string // Path on this machine
+	relativePath string // Path relative to the folder, as stored
+}
+
+// folderIndexer indexes the files of a collection folder
+type folderIndexer struct {
+	folder           string
+	collectionID     string
+	documentMgr      database.DocumentManager
+	fileStateMgr     database.FileStateManager
+	embeddingService *embedding.Service
+	scanner          *secrets.Scanner
+	schema           database.MetadataSchema
+	states           map[string]*database.FileState
+	sharing          *embeddingSharing // Copies embeddings from other collections when set
+	shared           atomic.Int64      // Chunks whose embeddings were copied
+}
+
+// processFolder processes all files in a collection folder, found at root on
+// this machine, with up to concurrency files chunked and embedded at once.
+// Documents are stored with paths relative to the folder, with the values of
+// the schema's metadata fields from their front matter. Files with the same
+// size and modification time, or the same content, as when they were last
+// indexed are skipped unless force is set, and only the files only selects
+// are indexed. With sharing, the embeddings of chunks already stored in
+// other collections by the same model are copied.
+//
+// Files that fail are listed in the counts and don't stop the others, except
+// when the embedding budget runs out: the files not started yet are left and
+// client.ErrBudgetExceeded is returned.
+func processFolder(folder, root, collectionID string, documentMgr database.DocumentManager, fileStateMgr database.FileStateManager, embeddingService *embedding.Service, scanner *secrets.Scanner, schema database.MetadataSchema, force bool, concurrency int, exclude []string, only *fileSelection, sharing *embeddingSharing) (folderCounts, error) {
+	var counts folderCounts
+
+	states := make(map[string]*database.FileState)
+	if !force {
+		var err error
+		if states, err = fileStateMgr.ListFileStates(collectionID, folder); err != nil {
+			return counts, err
+		}
+	}
+
+	matcher, err := newIgnoreMatcher(root, exclude)
+	if err != nil {
+		return counts, err
+	}
+
+	var jobs []indexJob
+	err = matcher.Walk(func(path string, d fs.DirEntry) error {
+		// Check if it's a file with text to index
+		if !isIndexableFile(path) {
 			return nil
 		}
 
-		// Check if it's a text file
-		if !isTextFile(path) {
+		relativePath, err := database.RelativePath(root, path)
+		if err != nil {
+			counts.failed = append(counts.failed, fileFailure{path: path, err: fmt.Errorf("failed to resolve file path: %w", err)})
 			return nil
 		}
+		if !only.selects(path, relativePath) {
+			return nil
+		}
+		jobs = append(jobs, indexJob{path: path, relativePath: relativePath})
+		return nil
+	})
+	if err != nil {
+		return counts, err
+	}
+
+	indexer := &folderIndexer{
+		folder:           folder,
+		collectionID:     collectionID,
+		documentMgr:      documentMgr,
+		fileStateMgr:     fileStateMgr,
+		embeddingService: embeddingService,
+		scanner:          scanner,
+		schema:           schema,
+		states:           states,
+		sharing:          sharing,
+	}
 
-		// Check if file is already indexed (unless force is true)
-		if !force {
-			// For now, we'll always re-index. In a production system, you'd check file modification time
-			// and compare with the last indexed time in the database
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Running out of budget cancels the files still to be indexed, since
+	// every one of them would fail too
+	var budgetErr error
+	var stopped sync.Once
+	var mu sync.Mutex
+	queue := make(chan indexJob)
+
+	var workers sync.WaitGroup
+	for w := 0; w < concurrency && w < len(jobs); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range queue {
+				chunks, unchanged, err := indexer.indexFile(ctx, job)
+				if errors.Is(err, client.ErrBudgetExceeded) {
+					stopped.Do(func() {
+						budgetErr = err
+						cancel()
+					})
+					continue
+				}
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					output.Error("Failed to index %s: %v", job.path, err)
+					counts.failed = append(counts.failed, fileFailure{path: job.path, err: err})
+				case unchanged:
+					counts.unchanged++
+				case chunks > 0:
+					counts.files++
+					counts.chunks += chunks
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, job := range jobs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- job:
 		}
+	}
+	close(queue)
+	workers.Wait()
+
+	counts.shared = int(indexer.shared.Load())
+	return counts, budgetErr
+}
+
+// indexFile chunks, embeds and stores a file, returning the number of chunks
+// stored. Unchanged files are reported without being indexed again.
+func (ix *folderIndexer) indexFile(ctx context.Context, job indexJob) (int, bool, error) {
+	path, relativePath := job.path, job.relativePath
+
+	// Get file info for timestamps
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	// Files that look the same as when they were indexed aren't read
+	indexed := ix.states[relativePath]
+	if indexed != nil && indexed.SameStat(fileInfo.Size(), fileInfo.ModTime()) {
+		return 0, true, nil
+	}
+
+	output.Info("Processing file: %s", path)
+
+	// Read file content
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	hash, err := database.KeyedContentHash(content)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to hash file: %w", err)
+	}
+	state := &database.FileState{
+		Folder:      ix.folder,
+		FilePath:    relativePath,
+		Size:        fileInfo.Size(),
+		ModifiedAt:  fileInfo.ModTime(),
+		ContentHash: hash,
+	}
+
+	// Files that were only touched keep their chunks
+	if indexed != nil && indexed.ContentHash == state.ContentHash {
+		if err := ix.fileStateMgr.TouchFile(ix.collectionID, state); err != nil {
+			output.Warning("Failed to update the state of %s: %v", path, err)
+		}
+		return 0, true, nil
+	}
 
-		output.Info("Processing file: %s", path)
+	text, sections, err := fileText(path, content)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// Files without valid values for the schema's fields aren't indexed
+	fields, err := ix.schema.Validate(database.FrontMatter(text))
+	if err != nil {
+		return 0, false, ix.removeFile(relativePath, err)
+	}
+
+	// Create metadata
+	metadata := map[string]string{
+		"file_path":     relativePath,
+		"file_name":     filepath.Base(path),
+		"file_size":     fmt.Sprintf("%d", len(content)),
+		"file_modified": fileInfo.ModTime().Format(time.RFC3339),
+	}
+	for name, value := range fields {
+		metadata[name] = value
+	}
+
+	// Chunk the content
+	chunks, err := chunkFile(ix.embeddingService, path, text, sections, metadata)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to chunk file: %w", err)
+	}
 
-		// Get file info for timestamps
-		fileInfo, err := os.Stat(path)
+	if ix.scanner != nil {
+		chunks, err = withoutSecrets(ix.scanner, path, text, chunks)
 		if err != nil {
-			output.Error("Failed to get file info for %s: %v", path, err)
-			return nil
+			return 0, false, ix.removeFile(relativePath, err)
 		}
+		if len(chunks) == 0 {
+			return 0, false, ix.removeFile(relativePath, nil)
+		}
+	}
 
-		// Read file content
-		content, err := os.ReadFile(path)
+	// Generate embeddings, except for the chunks already embedded in other
+	// collections
+	unembedded, pathEmbedding := ix.copySharedEmbeddings(relativePath, chunks)
+	if err := ix.embeddingService.GenerateEmbeddings(ctx, unembedded); err != nil {
+		return 0, false, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+
+	// Embed the file path too, so files named after a query rank higher
+	if pathEmbedding == nil {
+		pathEmbedding, err = ix.embeddingService.GenerateDocumentEmbedding(ctx, database.PathText(relativePath))
 		if err != nil {
-			output.Error("Failed to read file %s: %v", path, err)
-			return nil // Continue with other files
+			if errors.Is(err, client.ErrBudgetExceeded) {
+				return 0, false, err
+			}
+			output.Warning("Failed to embed the path of %s: %v", path, err)
+			pathEmbedding = nil
 		}
+	}
 
-		// Delete existing documents for this file
-		if err := documentMgr.DeleteDocumentsByPath(collectionID, path); err != nil {
-			output.Error("Failed to delete existing documents for %s: %v", path, err)
-			return nil
+	// Use file modification time for both created and updated timestamps
+	// This represents when the file content was last changed
+	fileTime := fileInfo.ModTime()
+
+	// Store chunks in database, replacing those of the last run so that
+	// storing a file again, after a run that failed halfway, doesn't
+	// duplicate its chunks
+	var storeErr error
+	chunkIndexes := make([]int, 0, len(chunks))
+	for _, chunk := range chunks {
+		chunkIndexes = append(chunkIndexes, chunk.Index)
+
+		metadataJSON, err := json.Marshal(chunk.Metadata)
+		if err != nil {
+			storeErr = fmt.Errorf("failed to marshal metadata: %w", err)
+			continue
 		}
 
-		// Create metadata
-		metadata := map[string]string{
-			"file_path":     path,
-			"file_name":     filepath.Base(path),
-			"file_size":     fmt.Sprintf("%d", len(content)),
-			"file_modified": fileInfo.ModTime().Format(time.RFC3339),
+		doc := &database.Document{
+			CollectionID:  ix.collectionID,
+			Folder:        ix.folder,
+			FilePath:      relativePath,
+			FileName:      filepath.Base(path),
+			Content:       chunk.Content,
+			ChunkIndex:    chunk.Index,
+			Embedding:     chunk.Embedding,
+			Metadata:      string(metadataJSON),
+			CreatedAt:     fileTime, // Use file modification time as creation time
+			UpdatedAt:     fileTime, // Use file modification time as update time
+			PathEmbedding: pathEmbedding,
+			TokenCount:    client.EstimateTokens(chunk.Content),
 		}
 
-		// Chunk the content
-		chunks, err := embeddingService.ChunkText(string(content), metadata)
-		if err != nil {
-			output.Error("Failed to chunk file %s: %v", path, err)
-			return nil
+		if err := ix.documentMgr.UpsertDocument(doc); err != nil {
+			storeErr = fmt.Errorf("failed to store chunk %d: %w", chunk.Index, err)
+			continue
 		}
+	}
 
-		// Generate embeddings
-		ctx := context.Background()
-		if err := embeddingService.GenerateEmbeddings(ctx, chunks); err != nil {
-			output.Error("Failed to generate embeddings for %s: %v", path, err)
-			return nil
+	// Chunks past the end of a file that got shorter are left from before
+	if err := ix.documentMgr.PruneDocumentsByPath(ix.collectionID, ix.folder, relativePath, chunkIndexes); err != nil && storeErr == nil {
+		storeErr = fmt.Errorf("failed to delete old chunks: %w", err)
+	}
+
+	// Files with missing chunks are indexed again by the next run
+	if storeErr != nil {
+		return 0, false, storeErr
+	}
+	if err := ix.fileStateMgr.SaveFileState(ix.collectionID, state); err != nil {
+		output.Warning("Failed to save the state of %s: %v", path, err)
+	}
+
+	output.Info("Created %d chunks for %s", len(chunks), path)
+	return len(chunks), false, nil
+}
+
+// copySharedEmbeddings copies the embeddings of the chunks, and of the file
+// path, that other collections already store from the same embedding model.
+// It returns the chunks still to embed and the path embedding, if found.
+func (ix *folderIndexer) copySharedEmbeddings(relativePath string, chunks []*embedding.Chunk) ([]*embedding.Chunk, []float32) {
+	if ix.sharing == nil {
+		return chunks, nil
+	}
+
+	hashes := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		hash, err := database.KeyedContentHash([]byte(chunk.Content))
+		if err != nil {
+			output.Warning("Failed to find shared embeddings of %s: %v", relativePath, err)
+			return chunks, nil
 		}
+		hashes[i] = hash
+	}
+	shared, err := ix.documentMgr.FindSharedEmbeddings(ix.collectionID, ix.sharing.model, ix.sharing.dimensions, relativePath, hashes)
+	if err != nil {
+		output.Warning("Failed to find shared embeddings of %s: %v", relativePath, err)
+		return chunks, nil
+	}
 
-		// Use file modification time for both created and updated timestamps
-		// This represents when the file content was last changed
-		fileTime := fileInfo.ModTime()
+	var unembedded []*embedding.Chunk
+	for i, chunk := range chunks {
+		if vector, ok := shared.Chunks[hashes[i]]; ok {
+			chunk.Embedding = vector
+			continue
+		}
+		unembedded = append(unembedded, chunk)
+	}
+	ix.shared.Add(int64(len(chunks) - len(unembedded)))
+	return unembedded, shared.Path
+}
 
-		// Store chunks in database
-		for _, chunk := range chunks {
-			metadataJSON, err := json.Marshal(chunk.Metadata)
-			if err != nil {
-				output.Error("Failed to marshal metadata: %v", err)
-				continue
-			}
+// fileSelection restricts an index run to the files in some files or folders
+// and whose paths relative to their collection folder match a glob. A nil
+// selection selects every file.
+type fileSelection struct {
+	paths   []string        // Absolute paths of files or folders, none for any
+	filter  *regexp.Regexp  // Relative paths to select, nil for any
+	matched map[string]bool // Paths that selected a file
+}
 
-			doc := &database.Document{
-				CollectionID: collectionID,
-				FilePath:     path,
-				FileName:     filepath.Base(path),
-				Content:      chunk.Content,
-				ChunkIndex:   chunk.Index,
-				Embedding:    chunk.Embedding,
-				Metadata:     string(metadataJSON),
-				CreatedAt:    fileTime, // Use file modification time as creation time
-				UpdatedAt:    fileTime, // Use file modification time as update time
-			}
+// newFileSelection creates the selection of the --file paths and --filter
+// glob, or returns nil when neither is given
+func newFileSelection(paths []string, filter string) (*fileSelection, error) {
+	if len(paths) == 0 && filter == "" {
+		return nil, nil
+	}
 
-			if err := documentMgr.InsertDocument(doc); err != nil {
-				output.Error("Failed to insert document: %v", err)
-				continue
-			}
+	s := &fileSelection{matched: make(map[string]bool)}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("invalid --file: %w", err)
+		}
+		absolute, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --file: %w", err)
 		}
+		s.paths = append(s.paths, absolute)
+	}
+	if filter != "" {
+		re, err := regexp.Compile(database.PathGlobToRegex(filter))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --filter: %w", err)
+		}
+		s.filter = re
+	}
+	return s, nil
+}
 
-		totalFiles++
-		totalChunks += len(chunks)
-		output.Info("Created %d chunks for %s", len(chunks), path)
+// selects reports whether a file, at path on this machine and relativePath
+// in its collection folder, is indexed
+func (s *fileSelection) selects(path, relativePath string) bool {
+	if s == nil {
+		return true
+	}
+	if s.filter != nil && !s.filter.MatchString(relativePath) {
+		return false
+	}
+	if len(s.paths) == 0 {
+		return true
+	}
+
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, selected := range s.paths {
+		if absolute == selected || strings.HasPrefix(absolute, selected+string(filepath.Separator)) {
+			s.matched[selected] = true
+			return true
+		}
+	}
+	return false
+}
 
+// unmatched returns the --file paths that didn't select any file
+func (s *fileSelection) unmatched() []string {
+	if s == nil {
 		return nil
-	})
+	}
+	var paths []string
+	for _, path := range s.paths {
+		if !s.matched[path] {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// removeFile deletes the chunks of a file that is no longer indexed, and
+// returns the reason it isn't, if any
+func (ix *folderIndexer) removeFile(relativePath string, reason error) error {
+	if err := ix.documentMgr.DeleteDocumentsByPath(ix.collectionID, ix.folder, relativePath); err != nil {
+		return fmt.Errorf("failed to delete existing documents: %w", err)
+	}
+	return reason
+}
+
+// newIgnoreMatcher creates the matcher of the files of a folder left out of
+// indexing, by its ignore files, ignore.patterns and exclude patterns
+func newIgnoreMatcher(root string, exclude []string) (*ignore.Matcher, error) {
+	patterns := append(append([]string{}, cfg.Ignore.Patterns...), exclude...)
+	return ignore.New(root, cfg.Ignore.GetFiles(), patterns)
+}
+
+// fileText returns the text of a file's content. Documents that aren't plain
+// text, such as Word documents, have their text extracted, along with the
+// sections that locate it in the document.
+func fileText(path string, content []byte) (string, []extract.Section, error) {
+	extractor := extract.For(path)
+	if extractor == nil {
+		return string(content), nil, nil
+	}
+	doc, err := extractor.Extract(content)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to extract text: %w", err)
+	}
+	return doc.Text(), doc.Sections, nil
+}
+
+// chunkFile splits the text of a file into chunks, keeping the chunks of
+// extracted documents within their sections, those of source files between
+// their declarations when embedding.chunk_code is set, and those of Markdown
+// files within their headings when embedding.chunk_markdown is set
+func chunkFile(service *embedding.Service, path, text string, sections []extract.Section, metadata map[string]string) ([]*embedding.Chunk, error) {
+	if sections != nil {
+		return service.ChunkSections(sections, metadata)
+	}
+	if cfg.Embedding.ChunkCode && embedding.IsCodeFile(path) {
+		return service.ChunkCode(path, text, metadata)
+	}
+	if cfg.Embedding.ChunkMarkdown && embedding.IsMarkdownFile(path) {
+		return service.ChunkMarkdown(text, metadata)
+	}
+	return service.ChunkText(text, metadata)
+}
 
-	return totalFiles, totalChunks, err
+// isIndexableFile checks if a file is a text file or a document whose text
+// can be extracted
+func isIndexableFile(path string) bool {
+	return isTextFile(path) || extract.Supported(path)
 }
 
 // isTextFile checks if a file is a text file based on extension
@@ -292,6 +966,14 @@ func isTextFile(path string) bool {
 }
 
 func init() {
-	indexCmd.Flags().BoolP("force", "f", false, "Force re-indexing of all files")
+	indexCmd.Flags().BoolP("force", "f", false, "Re-index every file, including unchanged ones")
+	indexCmd.Flags().Bool("dry-run", false, "Print the estimated tokens and cost of every file without indexing")
+	indexCmd.Flags().BoolP("yes", "y", false, "Index without asking when the estimated cost is above budget.confirm_above, or before --force replaces existing embeddings")
+	indexCmd.Flags().Int("max-embedding-calls", 0, "Stop after this many embedding requests, 0 for unlimited (overrides budget.max_embedding_calls)")
+	indexCmd.Flags().StringArray("exclude", nil, "Leave out files matching a pattern in the gitignore format, e.g. 'vendor/' (repeatable, added to ignore.patterns)")
+	indexCmd.Flags().StringArray("file", nil, "Index only this file, or the files in this folder (repeatable)")
+	indexCmd.Flags().String("filter", "", "Index only the files whose path in their collection folder matches a glob, e.g. '*.md' or 'guides/**'")
+	indexCmd.Flags().Bool("share-embeddings", true, "Copy the embeddings of chunks already indexed in other collections with the same model instead of embedding them again (off with --force unless set)")
+	indexCmd.Flags().Int("concurrency", 0, "Number of files chunked and embedded at once (overrides embedding.concurrency)")
 	rootCmd.AddCommand(indexCmd)
 }
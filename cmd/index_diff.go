@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// indexDiff is what a forced index run changes in a collection's index
+type indexDiff struct {
+	Add       []database.DiskFile     // Files on disk that aren't indexed yet
+	Delete    []*database.IndexedFile // Indexed files no longer on disk, whose chunks are deleted
+	Reembed   []*database.IndexedFile // Indexed files modified since they were indexed
+	Unchanged int                     // Indexed files that didn't change, embedded again anyway
+}
+
+// replaces reports whether the run deletes or embeds again files that are
+// already indexed
+func (d *indexDiff) replaces() bool {
+	return len(d.Delete) > 0 || len(d.Reembed) > 0 || d.Unchanged > 0
+}
+
+// diffForcedIndex compares a collection's index with the files in its
+// folders, as a forced index run would change it. Only the files only
+// selects are compared, on disk and in the index.
+func diffForcedIndex(documentMgr database.DocumentManager, collection *database.Collection, exclude []string, only *fileSelection) (*indexDiff, error) {
+	files, err := documentMgr.ListFiles(collection.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	var indexed []*database.IndexedFile
+	for _, file := range files {
+		if only.selects(localPath(&database.Document{Folder: file.Folder, FilePath: file.FilePath}), file.FilePath) {
+			indexed = append(indexed, file)
+		}
+	}
+
+	// A folder that can't be read fails the run rather than looking empty,
+	// which would delete all its files
+	diskFiles, err := collectionDiskFiles(collection, exclude)
+	if err != nil {
+		return nil, err
+	}
+	var onDisk []database.DiskFile
+	for _, file := range diskFiles {
+		if only.selects(file.Path, file.FilePath) {
+			onDisk = append(onDisk, file)
+		}
+	}
+
+	report := database.CompareIndex(indexed, onDisk)
+	diff := &indexDiff{Add: report.Unindexed, Reembed: report.Stale, Unchanged: report.Current}
+	for _, file := range report.Missing {
+		// Files indexed before paths were stored relative to their folder
+		// can't be told apart from files of other folders
+		if file.Folder != "" {
+			diff.Delete = append(diff.Delete, file)
+		}
+	}
+	return diff, nil
+}
+
+// printIndexDiff prints the files a forced index run adds, deletes and
+// embeds again, listing them when verbose
+func printIndexDiff(diff *indexDiff, verbose bool) {
+	output.Info("")
+	output.Bold("Changes to the index:")
+	output.KeyValuef("Files to add", "%d", len(diff.Add))
+	output.KeyValuef("Files to delete", "%d", len(diff.Delete))
+	output.KeyValuef("Changed files to re-embed", "%d", len(diff.Reembed))
+	output.KeyValuef("Unchanged files to re-embed", "%d", diff.Unchanged)
+
+	if verbose {
+		for _, file := range diff.Add {
+			output.Info("  + %s", file.Path)
+		}
+		for _, file := range diff.Delete {
+			output.Info("  - %s", localPath(&database.Document{Folder: file.Folder, FilePath: file.FilePath}))
+		}
+		for _, file := range diff.Reembed {
+			output.Info("  ~ %s", localPath(&database.Document{Folder: file.Folder, FilePath: file.FilePath}))
+		}
+	}
+
+	indexed := len(diff.Delete) + len(diff.Reembed) + diff.Unchanged
+	if len(diff.Delete) > 0 && len(diff.Delete) == indexed {
+		output.Warning("Every indexed file would be deleted: check that the collection's folders are right")
+	}
+	output.Info("")
+}
+
+// confirmForceIndex asks whether to go on with a forced index run that
+// deletes or embeds again files already indexed. --yes skips the question,
+// which can only be answered in a terminal.
+func confirmForceIndex(cmd *cobra.Command, diff *indexDiff) error {
+	if !diff.replaces() {
+		return nil
+	}
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return nil
+	}
+
+	reason := fmt.Sprintf("--force deletes or re-embeds %d indexed files", len(diff.Delete)+len(diff.Reembed)+diff.Unchanged)
+	if !isInteractive() {
+		return fmt.Errorf("indexing not started: %s; pass --yes to index anyway", reason)
+	}
+
+	output.Warning("Indexing will replace existing embeddings: %s", reason)
+	output.Printf("Continue? [y/N]: ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("indexing cancelled")
+	}
+}
+
+// deleteMissingFiles deletes the chunks of the indexed files that are no
+// longer in the collection's folders
+func deleteMissingFiles(documentMgr database.DocumentManager, collectionID string, files []*database.IndexedFile) int {
+	deleted := 0
+	for _, file := range files {
+		if err := documentMgr.DeleteDocumentsByPath(collectionID, file.Folder, file.FilePath); err != nil {
+			output.Error("Failed to delete %s: %v", file.FilePath, err)
+			continue
+		}
+		deleted++
+	}
+	return deleted
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// indexEstimate is the estimated size of an index run
+type indexEstimate struct {
+	Files     []fileEstimate
+	Unchanged int // Files skipped because they haven't changed since they were indexed
+	Chunks    int
+	Requests  int // Embedding requests: one per batch of chunks and one per file path
+	Tokens    int
+}
+
+// fileEstimate is the estimated size of the embeddings of one file
+type fileEstimate struct {
+	Path   string
+	Chunks int
+	Tokens int
+}
+
+// estimateIndexRun chunks the files an index run would embed, without
+// embedding them, and estimates their tokens. Unchanged files are left out
+// unless force is set, as the run skips them, and so are the files only
+// doesn't select.
+func estimateIndexRun(collectionID string, folders []string, embeddingService *embedding.Service, fileStateMgr database.FileStateManager, force bool, exclude []string, only *fileSelection) (*indexEstimate, error) {
+	estimate := &indexEstimate{}
+	for _, folder := range folders {
+		root, err := localFolder(folder)
+		if err != nil {
+			output.Warning("Skipping folder %s: %v", folder, err)
+			continue
+		}
+
+		states := make(map[string]*database.FileState)
+		if !force {
+			if states, err = fileStateMgr.ListFileStates(collectionID, folder); err != nil {
+				return nil, err
+			}
+		}
+
+		matcher, err := newIgnoreMatcher(root, exclude)
+		if err != nil {
+			return nil, err
+		}
+		err = matcher.Walk(func(path string, d fs.DirEntry) error {
+			if !isIndexableFile(path) {
+				return nil
+			}
+
+			var indexed *database.FileState
+			if relativePath, err := database.RelativePath(root, path); err == nil {
+				if !only.selects(path, relativePath) {
+					return nil
+				}
+				indexed = states[relativePath]
+			}
+			if info, err := d.Info(); err == nil && indexed != nil && indexed.SameStat(info.Size(), info.ModTime()) {
+				estimate.Unchanged++
+				return nil
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				output.Warning("Skipping file %s: %v", path, err)
+				return nil
+			}
+			if hash, err := database.KeyedContentHash(content); err == nil && indexed != nil && indexed.ContentHash == hash {
+				estimate.Unchanged++
+				return nil
+			}
+			text, sections, err := fileText(path, content)
+			if err != nil {
+				output.Warning("Skipping file %s: %v", path, err)
+				return nil
+			}
+			chunks, err := chunkFile(embeddingService, path, text, sections, nil)
+			if err != nil {
+				output.Warning("Skipping file %s: %v", path, err)
+				return nil
+			}
+
+			file := fileEstimate{Path: path, Chunks: len(chunks)}
+			for _, chunk := range chunks {
+				file.Tokens += client.EstimateTokens(chunk.Content)
+			}
+			file.Tokens += client.EstimateTokens(database.PathText(path))
+			estimate.Files = append(estimate.Files, file)
+			estimate.Chunks += file.Chunks
+			estimate.Requests += embeddingService.EmbeddingRequests(file.Chunks) + 1
+			estimate.Tokens += file.Tokens
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan folder %s: %w", folder, err)
+		}
+	}
+	return estimate, nil
+}
+
+// printIndexEstimate prints the estimate of an index run, listing every
+// file when verbose. The cost is only printed when known.
+func printIndexEstimate(estimate *indexEstimate, model string, cost float64, costKnown, verbose bool) {
+	output.Bold("Index estimate:")
+	if verbose {
+		for _, file := range estimate.Files {
+			output.Info("  %s: %d chunks, ~%d tokens", file.Path, file.Chunks, file.Tokens)
+		}
+	}
+	output.KeyValuef("Files", "%d", len(estimate.Files))
+	if estimate.Unchanged > 0 {
+		output.KeyValuef("Unchanged files", "%d (skipped)", estimate.Unchanged)
+	}
+	output.KeyValuef("Chunks", "%d", estimate.Chunks)
+	output.KeyValuef("Embedding requests", "%d", estimate.Requests)
+	output.KeyValuef("Estimated tokens", "%d", estimate.Tokens)
+	switch {
+	case model == "":
+		output.KeyValue("Estimated cost", "$0.00 (the embedding backend is not billed)")
+	case costKnown:
+		output.KeyValuef("Estimated cost", "$%.4f (%s)", cost, model)
+	default:
+		output.KeyValuef("Estimated cost", "unknown, set the price of %s in budget.prices", model)
+	}
+
+	if limit := cfg.Budget.MaxEmbeddingCalls; limit > 0 && estimate.Requests > limit {
+		output.Warning("The run needs more embedding requests than budget.max_embedding_calls (%d) and will stop early", limit)
+	}
+}
+
+// confirmIndexCost asks whether to go on with an index run that costs more
+// than budget.confirm_above, or whose cost is unknown. --yes skips the
+// question, which can only be answered in a terminal.
+func confirmIndexCost(cmd *cobra.Command, cost float64, costKnown bool) error {
+	threshold := cfg.Budget.ConfirmAbove
+	if threshold == 0 || (costKnown && cost <= threshold) {
+		return nil
+	}
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return nil
+	}
+
+	reason := fmt.Sprintf("the estimated cost is more than budget.confirm_above ($%.2f)", threshold)
+	if !costKnown {
+		reason = "the cost of the run is unknown"
+	}
+	if !isInteractive() {
+		return fmt.Errorf("indexing not started: %s; pass --yes to index anyway", reason)
+	}
+
+	output.Warning("Indexing will be billed: %s", reason)
+	output.Printf("Continue? [y/N]: ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("indexing cancelled")
+	}
+}
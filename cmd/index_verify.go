@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var indexVerifyCmd = &cobra.Command{
+	Use:   "verify [collection-id-or-name]",
+	Short: "Compare a collection's index with its folders",
+	Long: `Check that a collection's index matches the files in its folders.
+
+Verify reports:
+  - files in the collection's folders that aren't indexed
+  - indexed files that are no longer on disk
+  - stale files, modified since they were indexed
+  - chunks whose embeddings don't have the collection's dimensions, and an
+    embedding model whose dimensions differ from the collection's
+
+Files that index leaves out, by ignore files, ignore.patterns or --exclude,
+aren't expected in the index; indexed files that are now ignored are reported
+as no longer on disk. Files refused for containing secrets are reported as
+not indexed. The command
+fails when it finds any problem, so it can be used in scripts; run
+'rag-cli index' to fix them.
+
+Examples:
+  # Verify a collection
+  rag-cli index verify my-docs-collection`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Connect to database
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
+		if err != nil {
+			return fmt.Errorf("failed to create database manager: %w", err)
+		}
+
+		collectionMgr := database.NewCollectionManager(db)
+		collection, err := resolveCollection(collectionMgr, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		indexed, err := database.NewDocumentManager(db).ListFiles(collection.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+
+		exclude, _ := cmd.Flags().GetStringArray("exclude")
+		onDisk, err := collectionDiskFiles(collection, exclude)
+		if err != nil {
+			return err
+		}
+
+		output.Bold("Verifying collection '%s'", collection.Name)
+		report := database.CompareIndex(indexed, onDisk)
+		problems := len(report.Unindexed) + len(report.Missing) + len(report.Stale)
+
+		if len(report.Unindexed) > 0 {
+			output.Info("")
+			output.Warning("%d files are not indexed:", len(report.Unindexed))
+			for _, file := range report.Unindexed {
+				output.Info("  %s", file.Path)
+			}
+		}
+		if len(report.Missing) > 0 {
+			output.Info("")
+			output.Warning("%d indexed files are no longer on disk:", len(report.Missing))
+			for _, file := range report.Missing {
+				output.Info("  %s", localPath(&database.Document{Folder: file.Folder, FilePath: file.FilePath}))
+			}
+		}
+		if len(report.Stale) > 0 {
+			output.Info("")
+			output.Warning("%d files were modified since they were indexed:", len(report.Stale))
+			for _, file := range report.Stale {
+				output.Info("  %s (indexed %s)", localPath(&database.Document{Folder: file.Folder, FilePath: file.FilePath}), file.UpdatedAt.Format("2006-01-02 15:04:05"))
+			}
+		}
+
+		mismatches, err := verifyDimensions(db, dbManager, collection.ID)
+		if err != nil {
+			return err
+		}
+		problems += mismatches
+
+		output.Info("")
+		output.KeyValuef("Files", "%d up to date, %d not indexed, %d missing, %d stale",
+			report.Current, len(report.Unindexed), len(report.Missing), len(report.Stale))
+
+		if problems > 0 {
+			return fmt.Errorf("collection '%s' doesn't match its folders, found %d problems", collection.Name, problems)
+		}
+		output.Success("Collection '%s' matches its folders", collection.Name)
+		return nil
+	},
+}
+
+// collectionDiskFiles lists the text files in a collection's folders, the
+// files index would index
+func collectionDiskFiles(collection *database.Collection, exclude []string) ([]database.DiskFile, error) {
+	var files []database.DiskFile
+	for _, folder := range collection.Folders {
+		root, err := localFolder(folder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve folder %s: %w", folder, err)
+		}
+
+		matcher, err := newIgnoreMatcher(root, exclude)
+		if err != nil {
+			return nil, err
+		}
+		err = matcher.Walk(func(path string, d fs.DirEntry) error {
+			if !isIndexableFile(path) {
+				return nil
+			}
+
+			relativePath, err := database.RelativePath(root, path)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files = append(files, database.DiskFile{Folder: folder, FilePath: relativePath, Path: path, ModTime: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read folder %s: %w", root, err)
+		}
+	}
+	return files, nil
+}
+
+// verifyDimensions reports the chunks of a collection whose embeddings don't
+// have the collection's dimensions, and an embedding model with other
+// dimensions. It returns the number of problems found.
+func verifyDimensions(db *sql.DB, dbManager database.DatabaseManager, collectionID string) (int, error) {
+	dimensions, err := dbManager.GetEmbeddingDimensions(collectionID)
+	if err != nil {
+		return 0, err
+	}
+	counts, err := database.EmbeddingDimensionCounts(db, collectionID)
+	if err != nil {
+		return 0, err
+	}
+
+	problems := 0
+	var found []int
+	for dims := range counts {
+		found = append(found, dims)
+	}
+	sort.Ints(found)
+	for _, dims := range found {
+		switch dims {
+		case dimensions:
+		case 0:
+			output.Warning("%d chunks have no embedding", counts[dims])
+			problems++
+		default:
+			output.Warning("%d chunks have %d-dimensional embeddings, the collection has %d dimensions", counts[dims], dims, dimensions)
+			problems++
+		}
+	}
+
+	embeddingModel := getEmbeddingModel(cfg)
+	modelDimensions, err := embedding.LookupDimensions(embeddingModel, cfg.Embedding.ModelDimensions)
+	if err != nil {
+		modelDimensions = cfg.Embedding.Dimensions
+	}
+	if modelDimensions != dimensions {
+		output.Warning("The embedding model %s has %d dimensions, the collection has %d; queries can't be compared with its chunks", embeddingModel, modelDimensions, dimensions)
+		problems++
+	}
+
+	output.KeyValuef("Dimensions", "%d", dimensions)
+	return problems, nil
+}
+
+func init() {
+	indexVerifyCmd.Flags().StringArray("exclude", nil, "Leave out files matching a pattern in the gitignore format, as with index --exclude (repeatable)")
+	indexCmd.AddCommand(indexVerifyCmd)
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var memoryCmd = &cobra.Command{
+	Use:         "memory",
+	Short:       "Manage what chat remembers about you",
+	Annotations: requires(config.RequireDatabase),
+	Long: `Manage the long-term memory of chat.
+
+In an interactive chat, /remember <fact> remembers a fact about you or your
+work, and /style <preference> how you like answers. Memories are kept per
+collection and given to the chat model in every later chat with the
+collection, until they are forgotten.
+
+Examples:
+  # List the memories of every collection, or of one
+  rag-cli memory list
+  rag-cli memory list my-docs-collection
+
+  # Forget a memory by its number, or all memories of a collection
+  rag-cli memory forget 12
+  rag-cli memory forget --collection my-docs-collection --all`,
+}
+
+var listMemoriesCmd = &cobra.Command{
+	Use:   "list [collection-id-or-name]",
+	Short: "List the memories of chat",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		var collectionID string
+		if len(args) > 0 {
+			collection, err := resolveCollection(database.NewCollectionManager(db), args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get collection: %w", err)
+			}
+			collectionID = collection.ID
+		}
+
+		memories, err := database.NewMemoryManager(db).ListMemories(collectionID)
+		if err != nil {
+			return err
+		}
+		if output.IsJSON() {
+			if memories == nil {
+				memories = []*database.Memory{}
+			}
+			return output.JSON(memories)
+		}
+		if len(memories) == 0 {
+			output.Info("Nothing is remembered. Use /remember or /style in a chat to remember something")
+			return nil
+		}
+
+		collection := ""
+		for _, memory := range memories {
+			if memory.CollectionName != collection {
+				if collection != "" {
+					output.Info("")
+				}
+				collection = memory.CollectionName
+				output.Bold("Collection %s:", collection)
+			}
+			output.Info("  #%d [%s] %s", memory.ID, memory.Kind, memory.Content)
+		}
+		return nil
+	},
+}
+
+var forgetMemoryCmd = &cobra.Command{
+	Use:   "forget [memory-id...]",
+	Short: "Forget memories of chat",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		collectionRef, _ := cmd.Flags().GetString("collection")
+		if all && collectionRef == "" {
+			return fmt.Errorf("--all needs the --collection to forget the memories of")
+		}
+
+		ids := make([]int64, len(args))
+		for i, arg := range args {
+			id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid memory number %q: use the numbers listed by 'rag-cli memory list'", arg)
+			}
+			ids[i] = id
+		}
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+		memoryMgr := database.NewMemoryManager(db)
+
+		if all {
+			collection, err := resolveCollection(database.NewCollectionManager(db), collectionRef)
+			if err != nil {
+				return fmt.Errorf("failed to get collection: %w", err)
+			}
+			forgotten, err := memoryMgr.ForgetMemories(collection.ID)
+			if err != nil {
+				return err
+			}
+			output.Success("Forgot %d memories of collection %s", forgotten, collection.Name)
+			return nil
+		}
+
+		for _, id := range ids {
+			if err := memoryMgr.ForgetMemory(id); err != nil {
+				if errors.Is(err, database.ErrMemoryNotFound) {
+					return fmt.Errorf("memory #%d not found", id)
+				}
+				return err
+			}
+			output.Success("Forgot memory #%d", id)
+		}
+		return nil
+	},
+}
+
+// loadMemories loads the memories of the session's collection
+func (s *chatSession) loadMemories() error {
+	memories, err := s.memory.ListMemories(s.collectionID)
+	if err != nil {
+		return err
+	}
+	s.memories = memories
+	return nil
+}
+
+// remember remembers a fact or answer style for this and later sessions
+// with the collection
+func (s *chatSession) remember(kind, content string) error {
+	if content == "" {
+		return fmt.Errorf("nothing to remember: give it after the command, e.g. /remember we deploy with Argo CD")
+	}
+	memory, err := s.memory.AddMemory(s.collectionID, kind, content)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(s.memories, func(known *database.Memory) bool { return known.ID == memory.ID }) {
+		output.Info("Already remembered as #%d", memory.ID)
+		return nil
+	}
+
+	s.memories = append(s.memories, memory)
+	output.Success("Remembered #%d: %s", memory.ID, memory.Content)
+	return nil
+}
+
+// forget forgets a memory of the collection by its number
+func (s *chatSession) forget(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("give the number of the memory to forget, as listed by /memory")
+	}
+	id, _ := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	index := slices.IndexFunc(s.memories, func(memory *database.Memory) bool { return memory.ID == id })
+	if index < 0 {
+		return fmt.Errorf("invalid memory number %q: use one listed by /memory", args[0])
+	}
+
+	if err := s.memory.ForgetMemory(id); err != nil && !errors.Is(err, database.ErrMemoryNotFound) {
+		return err
+	}
+	output.Success("Forgot #%d: %s", id, s.memories[index].Content)
+	s.memories = append(s.memories[:index:index], s.memories[index+1:]...)
+	return nil
+}
+
+// printMemories lists the memories of a chat session
+func printMemories(memories []*database.Memory) {
+	if len(memories) == 0 {
+		output.Info("Nothing is remembered for this collection; use /remember or /style")
+		return
+	}
+	for _, memory := range memories {
+		output.Info("  #%d [%s] %s", memory.ID, memory.Kind, memory.Content)
+	}
+}
+
+func init() {
+	forgetMemoryCmd.Flags().Bool("all", false, "Forget all memories of the collection given with --collection")
+	forgetMemoryCmd.Flags().String("collection", "", "Collection whose memories --all forgets")
+
+	memoryCmd.AddCommand(listMemoriesCmd)
+	memoryCmd.AddCommand(forgetMemoryCmd)
+	rootCmd.AddCommand(memoryCmd)
+}
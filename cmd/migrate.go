@@ -1,16 +1,19 @@
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
 
+	"github.com/busybytelab.com/rag-cli/pkg/config"
 	"github.com/busybytelab.com/rag-cli/pkg/database"
 	"github.com/busybytelab.com/rag-cli/pkg/output"
 	"github.com/spf13/cobra"
 )
 
 var migrateCmd = &cobra.Command{
-	Use:   "migrate",
-	Short: "Manage database migrations",
+	Use:         "migrate",
+	Short:       "Manage database migrations",
+	Annotations: requires(config.RequireDatabase),
 	Long: `Manage database schema migrations for RAG CLI.
 
 This command helps you migrate your database schema when there are changes
@@ -45,14 +48,18 @@ Examples:
 		toVersion, _ := cmd.Flags().GetInt("to")
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
+		}
+
+		// Make sure pgvector can be used before touching the schema
+		if err := checkVectorExtension(db); err != nil {
+			return err
 		}
-		defer db.Close()
 
 		// Create database manager
-		dbManager, err := database.NewDatabaseManager(&cfg.Database)
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
 		if err != nil {
 			return fmt.Errorf("failed to create database manager: %w", err)
 		}
@@ -103,17 +110,24 @@ This displays the current migration version and available migrations.
 
 Examples:
   # Show migration status
-  rag-cli migrate status`,
+  rag-cli migrate status
+
+  # Show migration status as JSON, e.g. for deployment scripts
+  rag-cli migrate status --output json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
+			return err
+		}
+
+		// Make sure pgvector can be used before touching the schema
+		if err := checkVectorExtension(db); err != nil {
+			return err
 		}
-		defer db.Close()
 
 		// Create database manager
-		dbManager, err := database.NewDatabaseManager(&cfg.Database)
+		dbManager, err := database.NewDatabaseManagerWithDB(db)
 		if err != nil {
 			return fmt.Errorf("failed to create database manager: %w", err)
 		}
@@ -125,20 +139,55 @@ Examples:
 			return fmt.Errorf("failed to get current migration version: %w", err)
 		}
 
-		output.Bold("Migration Status:")
-		output.KeyValue("Current version", fmt.Sprintf("%d", currentVersion))
-		output.KeyValue("Total migrations", fmt.Sprintf("%d", dbManager.GetTotalMigrations()))
-
-		if currentVersion < dbManager.GetTotalMigrations() {
-			output.Warning("Database is not up to date. Run 'rag-cli migrate up' to apply pending migrations.")
-		} else {
-			output.Success("Database is up to date")
+		status := migrationStatus{
+			CurrentVersion:  currentVersion,
+			TotalMigrations: dbManager.GetTotalMigrations(),
 		}
-
-		return nil
+		status.UpToDate = status.CurrentVersion >= status.TotalMigrations
+
+		return output.Result(status, func() {
+			output.Bold("Migration Status:")
+			output.KeyValue("Current version", fmt.Sprintf("%d", status.CurrentVersion))
+			output.KeyValue("Total migrations", fmt.Sprintf("%d", status.TotalMigrations))
+
+			if !status.UpToDate {
+				output.Warning("Database is not up to date. Run 'rag-cli migrate up' to apply pending migrations.")
+			} else {
+				output.Success("Database is up to date")
+			}
+		})
 	},
 }
 
+// migrationStatus is the schema version of the database, as printed by migrate status
+type migrationStatus struct {
+	CurrentVersion  int  `json:"current_version"`
+	TotalMigrations int  `json:"total_migrations"`
+	UpToDate        bool `json:"up_to_date"`
+}
+
+// checkVectorExtension verifies that the pgvector extension is available
+// before migrations try to use it
+func checkVectorExtension(db *sql.DB) error {
+	status, err := database.CheckVectorExtension(db)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case status.Installed:
+		output.Info("pgvector extension: installed (version %s)", status.InstalledVersion)
+	case status.Available:
+		output.Info("pgvector extension: available (version %s), it will be created by the migrations", status.DefaultVersion)
+	default:
+		output.Error("pgvector extension is not installed on the database server")
+		output.Info("Install pgvector (https://github.com/pgvector/pgvector#installation) and try again")
+		return database.ErrExtensionUnavailable
+	}
+
+	return nil
+}
+
 func init() {
 	// Add flags
 	migrateUpCmd.Flags().Int("to", 0, "Migrate to specific version (0 = run all)")
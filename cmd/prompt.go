@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/busybytelab.com/rag-cli/pkg/prompts"
+	"github.com/spf13/cobra"
+)
+
+var promptCmd = &cobra.Command{
+	Use:         "prompt",
+	Short:       "Manage the library of system prompts",
+	Annotations: requires(config.RequireDatabase),
+	Long: `Manage named system prompts that chat sessions can be started with.
+
+Prompts are stored in the database with "prompt add", or as .md and .txt files
+in the prompts directory (general.prompts_dir, ~/.rag-cli/prompts by default).
+A prompt file whose first line is a Markdown heading uses the heading as its
+description. Stored prompts take precedence over files with the same name.
+
+Start a chat with a prompt with --prompt-name. Prompts may contain variables
+that are filled in when the chat starts:
+  {{collection}}              Name of the collection
+  {{collection_description}}  Description of the collection
+  {{model}}                   Chat model
+  {{date}}, {{time}}          Current date and time
+  {{user}}                    Name of the user running rag-cli
+Other variables are set with --var name=value.
+
+Examples:
+  # Add a prompt
+  rag-cli prompt add support-agent --content "You help {{user}} with {{collection}} tickets."
+
+  # Add a prompt from a file
+  rag-cli prompt add reviewer --file reviewer.md --description "Reviews code changes"
+
+  # List the prompts
+  rag-cli prompt list
+
+  # Chat with a prompt
+  rag-cli chat my-docs --prompt-name support-agent`,
+}
+
+var listPromptsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the prompts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		library, err := newPromptLibrary()
+		if err != nil {
+			return err
+		}
+
+		list, err := library.List()
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			output.Info("No prompts found. Add one with 'rag-cli prompt add' or a file in %s", library.Dir())
+			return nil
+		}
+
+		output.Bold("Prompts:")
+		for _, p := range list {
+			output.Info("")
+			output.KeyValue("Name", p.Name)
+			if p.Description != "" {
+				output.KeyValue("Description", p.Description)
+			}
+			if p.File != "" {
+				output.KeyValue("File", p.File)
+			} else {
+				output.KeyValue("Stored", "database")
+			}
+			if vars := prompts.Variables(p.Content); len(vars) > 0 {
+				output.KeyValue("Variables", strings.Join(vars, ", "))
+			}
+			output.KeyValue("Updated", p.UpdatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	},
+}
+
+var addPromptCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a prompt to the database",
+	Long: `Add a prompt to the database. The content is read from --content, from
+--file, or from stdin when neither is given.
+
+Use --force to replace an existing prompt with the same name.
+
+Examples:
+  rag-cli prompt add support-agent --content "You are a support agent for {{collection}}."
+  rag-cli prompt add reviewer --file reviewer.md
+  cat prompt.txt | rag-cli prompt add triage --description "Sorts incoming tickets"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, _ := cmd.Flags().GetString("content")
+		file, _ := cmd.Flags().GetString("file")
+		description, _ := cmd.Flags().GetString("description")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if content != "" && file != "" {
+			return fmt.Errorf("--content and --file cannot be used together")
+		}
+		switch {
+		case file != "":
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read prompt file: %w", err)
+			}
+			content = string(data)
+		case content == "":
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read prompt: %w", err)
+			}
+			content = string(data)
+		}
+
+		library, err := newPromptLibrary()
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+		if err := prompts.ValidateName(name); err != nil {
+			return err
+		}
+		if !force {
+			if _, err := library.Get(name); err == nil {
+				return fmt.Errorf("prompt %s already exists, use --force to replace it", name)
+			} else if !errors.Is(err, prompts.ErrNotFound) {
+				return err
+			}
+		}
+
+		prompt := &database.Prompt{
+			Name:        name,
+			Description: description,
+			Content:     strings.TrimSpace(content),
+		}
+		if err := library.Save(prompt); err != nil {
+			return err
+		}
+
+		output.Success("Saved prompt %s", name)
+		if vars := prompts.Variables(prompt.Content); len(vars) > 0 {
+			output.KeyValue("Variables", strings.Join(vars, ", "))
+		}
+		return nil
+	},
+}
+
+var showPromptCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a prompt",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		library, err := newPromptLibrary()
+		if err != nil {
+			return err
+		}
+
+		prompt, err := library.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		output.KeyValue("Name", prompt.Name)
+		if prompt.Description != "" {
+			output.KeyValue("Description", prompt.Description)
+		}
+		if prompt.File != "" {
+			output.KeyValue("File", prompt.File)
+		}
+		if vars := prompts.Variables(prompt.Content); len(vars) > 0 {
+			output.KeyValue("Variables", strings.Join(vars, ", "))
+		}
+		output.KeyValue("Updated", prompt.UpdatedAt.Format("2006-01-02 15:04:05"))
+		output.Info("")
+		output.Info("%s", prompt.Content)
+		return nil
+	},
+}
+
+var deletePromptCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a prompt from the database",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		library, err := newPromptLibrary()
+		if err != nil {
+			return err
+		}
+
+		if err := library.Delete(args[0]); err != nil {
+			return err
+		}
+
+		output.Success("Deleted prompt %s", args[0])
+		return nil
+	},
+}
+
+// newPromptLibrary opens the prompt library of the database and the
+// configured prompts directory
+func newPromptLibrary() (*prompts.Library, error) {
+	db, err := openMigratedDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return prompts.New(database.NewPromptManager(db), cfg.General.GetPromptsDir()), nil
+}
+
+func init() {
+	addPromptCmd.Flags().String("content", "", "Prompt content")
+	addPromptCmd.Flags().StringP("file", "f", "", "File to read the prompt content from")
+	addPromptCmd.Flags().StringP("description", "d", "", "Short description of the prompt")
+	addPromptCmd.Flags().Bool("force", false, "Replace an existing prompt with the same name")
+
+	promptCmd.AddCommand(listPromptsCmd)
+	promptCmd.AddCommand(addPromptCmd)
+	promptCmd.AddCommand(showPromptCmd)
+	promptCmd.AddCommand(deletePromptCmd)
+
+	rootCmd.AddCommand(promptCmd)
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/ignore"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var quickCmd = &cobra.Command{
+	Use:         "quick [folder] [question]",
+	Short:       "Answer a question from a folder without creating a collection",
+	Annotations: requires(config.RequireDatabase | config.RequireChat | config.RequireEmbedding),
+	Long: `Answer a question from the files of a folder in one step.
+
+Quick indexes the folder into a temporary collection, answers the question
+from it like the ask command, and deletes the collection, so a folder can be
+explored without creating, indexing and deleting a collection by hand. The
+collection is deleted when the command is interrupted, too, unless --keep is
+given.
+
+Files are indexed as by the index command: files ignored by .gitignore,
+.ragignore, ignore.patterns or --exclude are left out, and chunks with
+secrets are kept out of the index. When the embedding backend bills by the
+token and the estimated cost is above budget.confirm_above, quick asks before
+indexing, unless --yes is given.
+
+Examples:
+  # Ask a question about a project
+  rag-cli quick ./my-project "How is the configuration loaded?"
+
+  # Leave out tests, and answer as a concise engineer
+  rag-cli quick ./my-project "Where are retries handled?" --exclude '*_test.go' --persona engineer
+
+  # Keep the collection to ask follow-up questions with chat
+  rag-cli quick ./docs "What does the backup policy say?" --keep`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keep, _ := cmd.Flags().GetBool("keep")
+		exclude, _ := cmd.Flags().GetStringArray("exclude")
+		for _, pattern := range exclude {
+			if err := ignore.ValidatePattern(pattern); err != nil {
+				return fmt.Errorf("invalid --exclude: %w", err)
+			}
+		}
+
+		info, err := os.Stat(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read folder: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a folder", args[0])
+		}
+		folder, err := database.NormalizePath(args[0])
+		if err != nil {
+			return err
+		}
+
+		embedder, err := backends.Embedder()
+		if err != nil {
+			return err
+		}
+		embeddingService := embedding.New(embedder, &cfg.Embedding)
+		setChunkTokenizer(embeddingService)
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+		collectionMgr := database.NewCollectionManager(db)
+		fileStateMgr := database.NewFileStateManager(db)
+
+		// Estimate runs billed by the embedding backend before paying for them
+		if model, paid := backends.EmbeddingModel(); paid {
+			estimate, err := estimateIndexRun("", []string{folder}, embeddingService, fileStateMgr, true, exclude, nil)
+			if err != nil {
+				return err
+			}
+			cost, err := backends.Budget().EstimateCost(model, estimate.Tokens)
+			costKnown := err == nil
+			printIndexEstimate(estimate, model, cost, costKnown, false)
+			if err := confirmIndexCost(cmd, cost, costKnown); err != nil {
+				return err
+			}
+		}
+
+		name := fmt.Sprintf("rag-cli-quick-%d", time.Now().UnixNano())
+		collection, err := collectionMgr.CreateCollection(name, "Temporary collection created by rag-cli quick", []string{folder})
+		if err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+
+		// Delete the collection when the command ends or is interrupted
+		var cleanup sync.Once
+		deleteCollection := func() {
+			cleanup.Do(func() {
+				if keep {
+					output.Info("Kept collection %s", collection.Name)
+					return
+				}
+				if err := collectionMgr.DeleteCollection(collection.ID); err != nil {
+					output.Warning("Failed to delete collection %s: %v", collection.Name, err)
+				}
+			})
+		}
+		defer deleteCollection()
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupts)
+		go func() {
+			if _, ok := <-interrupts; ok {
+				deleteCollection()
+				os.Exit(130)
+			}
+		}()
+
+		// Secrets are kept out of the index and away from the embedding backend
+		scanner, err := newSecretScanner()
+		if err != nil {
+			return err
+		}
+
+		output.Info("Indexing %s...", folder)
+		counts, err := processFolder(folder, folder, collection.ID, database.NewDocumentManager(db), fileStateMgr, embeddingService, scanner, nil, true, cfg.Embedding.GetConcurrency(), exclude, nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", folder, err)
+		}
+		for _, failure := range counts.failed {
+			output.Warning("Failed to index %s: %v", failure.path, failure.err)
+		}
+		if counts.chunks == 0 {
+			return fmt.Errorf("no text files to answer from in %s", folder)
+		}
+		if err := collectionMgr.UpdateCollectionStats(collection.ID); err != nil {
+			output.Warning("Failed to update collection stats: %v", err)
+		}
+		output.Info("Indexed %d files into %d chunks", counts.files, counts.chunks)
+		output.Info("")
+
+		session, _, err := newChatSession(cmd, collection.ID)
+		if err != nil {
+			return err
+		}
+		answer, sources, err := askQuestion(context.Background(), session, args[1])
+		if err != nil {
+			return err
+		}
+		return printAnswer(args[1], answer, sources, session.citations)
+	},
+}
+
+func init() {
+	addChatFlags(quickCmd)
+	quickCmd.Flags().Bool("keep", false, "Keep the collection instead of deleting it, to ask more questions with chat or ask")
+	quickCmd.Flags().StringArray("exclude", nil, "Leave out files matching a pattern in the gitignore format, e.g. 'vendor/' (repeatable, added to ignore.patterns)")
+	quickCmd.Flags().BoolP("yes", "y", false, "Index without asking when the estimated cost is above budget.confirm_above")
+	rootCmd.AddCommand(quickCmd)
+}
@@ -1,23 +1,60 @@
 package cmd
 
 import (
+	"bufio"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/busybytelab.com/rag-cli/pkg/client"
 	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
 	"github.com/busybytelab.com/rag-cli/pkg/output"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	cfgFile    string
-	configName string
-	cfg        *config.Config
-	noColor    bool
-	verbose    bool
+	cfgFile      string
+	configName   string
+	cfg          *config.Config
+	backends     *client.Provider
+	connections  *database.ConnectionProvider
+	noColor      bool
+	outputFormat string
+	verbose      bool
+	selectMatch  bool
 )
 
+// requiresAnnotation is the command annotation holding the configuration
+// requirements checked before the command runs
+const requiresAnnotation = "requires"
+
+// requires builds the annotations declaring the configuration a command needs.
+// Subcommands inherit the requirements of their closest annotated parent.
+func requires(req config.Requirement) map[string]string {
+	return map[string]string{requiresAnnotation: strconv.Itoa(int(req))}
+}
+
+// commandRequirements returns the configuration requirements declared for a command
+func commandRequirements(cmd *cobra.Command) config.Requirement {
+	for c := cmd; c != nil; c = c.Parent() {
+		if value, ok := c.Annotations[requiresAnnotation]; ok {
+			req, err := strconv.Atoi(value)
+			if err != nil {
+				return config.RequireAll
+			}
+			return config.Requirement(req)
+		}
+	}
+	return config.RequireNone
+}
+
 // GetConfig returns the current configuration
 func GetConfig() *config.Config {
 	return cfg
@@ -35,6 +72,13 @@ and perform vector search and chat with your documents using Ollama and PostgreS
 		if noColor {
 			output.DisableColors()
 		}
+		// A command's own --json flag is the same as --output json
+		if asJSON, err := cmd.Flags().GetBool("json"); err == nil && asJSON {
+			outputFormat = output.FormatJSON
+		}
+		if err := output.SetFormat(outputFormat); err != nil {
+			return err
+		}
 
 		// Set the global configuration name
 		config.CurrentConfigName = configName
@@ -55,6 +99,24 @@ and perform vector search and chat with your documents using Ollama and PostgreS
 			cfg.Ollama.Port = port
 		}
 
+		// Validate only what this command needs
+		if err := cfg.ValidateFor(commandRequirements(cmd)); err != nil {
+			return fmt.Errorf("configuration validation failed: %w", err)
+		}
+
+		// LLM backends are created on first use by the commands that need them
+		backends = client.NewProvider(cfg)
+
+		// The database pool is opened on first use and closed when the command exits
+		connections = database.NewConnectionProvider(&cfg.Database)
+
+		// Document content is encrypted and decrypted when encryption is enabled
+		database.UseContentEncryption(&cfg.Encryption)
+
+		// Prompts, conversations and context documents are counted with the
+		// tokenizer of the chat model
+		client.UseTokenCounter(client.NewTokenCounter(cfg.ChatBackend, getDefaultModelName(cfg)))
+
 		return nil
 	},
 }
@@ -62,12 +124,102 @@ and perform vector search and chat with your documents using Ollama and PostgreS
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	started := time.Now()
+	cmd, err := rootCmd.ExecuteC()
+
+	// Count the run in the usage statistics, when telemetry is on
+	recordTelemetry(cmd, started, err)
+
+	// Release the shared database pool before exiting
+	if connections != nil {
+		connections.Close()
+	}
+
+	if err != nil {
+		if output.IsJSON() {
+			output.JSON(map[string]string{"error": err.Error()})
+		} else {
+			fmt.Println(err)
+		}
 		os.Exit(1)
 	}
 }
 
+// openDatabase returns the shared database connection pool for the current command
+func openDatabase() (*sql.DB, error) {
+	db, err := connections.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+// openMigratedDatabase connects to the database and runs any pending
+// migrations, for commands that read documents in the latest schema
+func openMigratedDatabase() (*sql.DB, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	dbManager, err := database.NewDatabaseManagerWithDB(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	defer dbManager.Close()
+
+	return db, nil
+}
+
+// resolveCollection looks up a collection by ID, name or alias. When the
+// reference is ambiguous and --select is given, the user picks one of the
+// candidates interactively.
+func resolveCollection(collectionMgr database.CollectionManager, collectionIdOrName string) (*database.Collection, error) {
+	collection, err := collectionMgr.GetCollectionByIdOrName(collectionIdOrName)
+
+	var ambiguous *database.AmbiguousCollectionError
+	if !errors.As(err, &ambiguous) {
+		return collection, err
+	}
+	if !selectMatch {
+		return nil, fmt.Errorf("%w\nor pass --select to choose one interactively", err)
+	}
+
+	if !isInteractive() {
+		return nil, fmt.Errorf("%w\n--select needs an interactive terminal", err)
+	}
+	return promptForCollection(ambiguous)
+}
+
+// promptForCollection asks the user to pick one of the candidates of an ambiguous collection reference
+func promptForCollection(ambiguous *database.AmbiguousCollectionError) (*database.Collection, error) {
+	output.Warning("'%s' matches %d collections:", ambiguous.Reference, len(ambiguous.Candidates))
+	for i, candidate := range ambiguous.Candidates {
+		output.Info("  %d) %s  %s (%d documents)", i+1, candidate.ID, candidate.Name, candidate.Stats.TotalDocuments)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		output.Printf("Select a collection [1-%d]: ", len(ambiguous.Candidates))
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("no collection selected: %w", err)
+		}
+
+		choice, err := strconv.Atoi(strings.TrimSpace(line))
+		if err == nil && choice >= 1 && choice <= len(ambiguous.Candidates) {
+			return ambiguous.Candidates[choice-1], nil
+		}
+		output.Warning("Enter a number between 1 and %d", len(ambiguous.Candidates))
+	}
+}
+
+// isInteractive reports whether standard input is a terminal
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -80,7 +232,11 @@ func init() {
 
 	// Output flags
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable color output")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", output.FormatText, "Output format: text or json (messages go to stderr with json)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+
+	// Collection resolution flags
+	rootCmd.PersistentFlags().BoolVar(&selectMatch, "select", false, "Choose interactively when a collection name or alias matches several collections")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -97,7 +253,7 @@ func initConfig() {
 		}
 
 		// Search config in home directory with name ".rag-cli" (without extension).
-		viper.AddConfigPath(home + "/.rag-cli")
+		viper.AddConfigPath(filepath.Join(home, ".rag-cli"))
 		viper.SetConfigType("yaml")
 		viper.SetConfigName("config")
 	}
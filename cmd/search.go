@@ -2,18 +2,31 @@ package cmd
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/busybytelab.com/rag-cli/pkg/calibration"
 	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
 	"github.com/busybytelab.com/rag-cli/pkg/database"
 	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/expansion"
 	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/busybytelab.com/rag-cli/pkg/spelling"
+	"github.com/busybytelab.com/rag-cli/pkg/translation"
 	"github.com/spf13/cobra"
 )
 
 var searchCmd = &cobra.Command{
-	Use:   "search [collection-id-or-name] [query]",
-	Short: "Search documents in a collection",
+	Use:         "search [collection-id-or-name] [query]",
+	Short:       "Search documents in a collection",
+	Annotations: requires(config.RequireDatabase),
 	Long: `Search documents in a collection using various search methods.
 
 This command supports multiple search types:
@@ -21,8 +34,66 @@ This command supports multiple search types:
 - text: Full-text search using PostgreSQL text search
 - hybrid: Combined vector and text search
 - semantic: Semantic search with filters
+- bm25: BM25 keyword ranking over stored term vectors
+- fusion: Vector and BM25 results merged with reciprocal rank fusion
+
+Text and hybrid searches match the query with the web search syntax of
+PostgreSQL: all words are required, "quoted words" match as a phrase, or
+between words matches either of them, and -word leaves out the documents
+containing the word. Other punctuation is ignored.
+
+With --expand-context N, the N chunks before and after each result in its
+file are joined to the result's content, since the continuation of a match is
+often in the next chunk. A result already joined to a better ranked one is
+left out, and --max-tokens counts the joined content.
 
 Reranking can be enabled with the --rerank flag for improved result accuracy.
+With --retrieve, reranking chooses the results from a larger pool of
+candidates, e.g. --retrieve 50 --return 8 reranks 50 candidates and returns
+the best 8.
+
+With --route, the collection is omitted and the query is routed to the
+collections most likely to answer it, as ranked by 'rag-cli collection
+suggest': by comparing the query with each collection's centroid embedding
+(the average of its document embeddings) and with the embedding of its name
+and description. The best --route-limit collections are searched and their
+results merged.
+
+Spell checking (--spellcheck) corrects misspelled words before searching,
+using the nearest terms from the collection's vocabulary or the chat model,
+and prints a "did you mean" suggestion.
+
+Query expansion (--expand) also searches synonym rewrites of the query from
+the expansion.synonyms configuration and paraphrases generated by the chat
+model, and fuses all results together. Query rewriting (--query-rewrite) asks
+the chat model to rewrite the query into expansion.rewrites search queries,
+such as the keywords its answer likely contains, searches each and merges
+them with reciprocal rank fusion (multi-query retrieval), with or without
+--expand.
+
+Batch mode (--batch) reads queries from a file, or stdin with '-', one per
+line, and searches them concurrently. A line is either the query itself or a
+JSON object such as {"id": "q1", "query": "..."}; blank lines and lines
+starting with # are skipped. One JSON object is printed per query, in the
+order of the file, with its results, scores and any error, so the output can
+be fed to evaluation scripts.
+
+Calibration (--calibrate) fits a mapping of combined scores to a 0-1
+confidence from labeled queries and saves it in the configuration. Each line
+of the file is a JSON object such as {"query": "...", "relevant":
+["docs/auth.md"]}, listing the files that answer the query. Once calibrated,
+search shows the confidence of each result and chat abstains from answering
+when no document reaches calibration.min_confidence. Calibrations are fitted
+per search type; refit after changing weights, normalization or reranking.
+
+Local search (--local) works without the database, e.g. when it is
+unreachable: it reads the files of the collection's folders, or of a folder
+given instead of the collection, and lists the files containing the words of
+the query with their matching lines, like grep. Files are ranked by how many
+of the words they contain. Ignore files are honored and --limit,
+--file-filter and --file-types apply; other options don't. A collection's
+folders are remembered whenever it is searched or indexed, so it can be
+searched locally by name while the database is down.
 
 Examples:
   # Vector search (default)
@@ -31,145 +102,201 @@ Examples:
   # Text search only
   rag-cli search my-docs-collection "machine learning" --type text
 
+  # Text search for a phrase, or either of two words, without a third
+  rag-cli search my-docs-collection '"connection pool" timeout or deadline -mysql' --type text
+
   # Hybrid search with custom weights
   rag-cli search my-docs-collection "neural networks" --type hybrid --vector-weight 0.7 --text-weight 0.3
 
+  # BM25 keyword search
+  rag-cli search my-docs-collection "connection pool timeout" --type bm25
+
+  # Dense and BM25 retrieval fused together
+  rag-cli search my-docs-collection "connection pool timeout" --type fusion
+
   # Search with reranking enabled
   rag-cli search my-docs-collection "API documentation" --rerank --rerank-instruction "Focus on code examples"
 
+  # Rerank the top 50 candidates and return the best 8
+  rag-cli search my-docs-collection "API documentation" --rerank --retrieve 50 --return 8
+
   # Search with filters
   rag-cli search my-docs-collection "API documentation" --file-filter "*.md" --content-filter "authentication"
 
+  # Only search Markdown and Go files
+  rag-cli search my-docs-collection "retry policy" --file-types md,go
+
+  # Return as many top results as fit in 2000 tokens
+  rag-cli search my-docs-collection "deployment checklist" --max-tokens 2000
+
+  # Show each result with the chunk before and after it
+  rag-cli search my-docs-collection "deployment checklist" --expand-context 1 --show-content
+
+  # Only search documents with a priority of 2 or more in their front matter
+  rag-cli search my-docs-collection "incident response" --where 'priority>=2'
+
+  # Only search the chunks of files named runbook.md, by their metadata
+  rag-cli search my-docs-collection "incident response" --metadata file_name=runbook.md
+
+  # Boost READMEs and recently indexed chunks
+  rag-cli search my-docs-collection "getting started" --boost 'file:README*=+0.1' --boost 'recency:30d=+0.05'
+
+  # Search the collection's files directly while the database is down
+  rag-cli search my-docs-collection "connection pool timeout" --local
+
+  # Let the router pick the collections to search
+  rag-cli search --route "how do I rotate the database password"
+
+  # Correct misspelled words before searching
+  rag-cli search my-docs-collection "postgre conection pooling" --spellcheck
+
+  # Only suggest corrections from the chat model
+  rag-cli search my-docs-collection "kuberentes ingress" --spellcheck --spellcheck-mode llm --autocorrect=false
+
+  # Search synonym rewrites and 3 LLM paraphrases as well
+  rag-cli search my-docs-collection "k8s pod restarts" --expand --paraphrases 3
+
+  # Search queries the chat model rewrites from a question, fused together
+  rag-cli search my-docs-collection "why do my pods keep restarting after deploys?" --query-rewrite
+
+  # Search every query in a file, 8 at a time, and save the results as JSONL
+  rag-cli search my-docs-collection --batch queries.txt --concurrency 8 > results.jsonl
+
+  # Route queries from stdin
+  cat queries.txt | rag-cli search --route --batch -
+
+  # Calibrate confidences from labeled queries
+  rag-cli search my-docs-collection --calibrate labeled-queries.jsonl
+
   # Show detailed scores
   rag-cli search my-docs-collection "database queries" --show-scores
 
   # Show document content
-  rag-cli search my-docs-collection "error handling" --show-content`,
-	Args: cobra.ExactArgs(2),
+  rag-cli search my-docs-collection "error handling" --show-content
+
+  # Print the results as JSON, with their content
+  rag-cli search my-docs-collection "error handling" --show-content --output json`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		// With --route the collections are picked from the query alone, and
+		// with --batch the queries are read from a file
+		n := 2
+		if route, _ := cmd.Flags().GetBool("route"); route {
+			n--
+		}
+		batch, _ := cmd.Flags().GetString("batch")
+		calibrate, _ := cmd.Flags().GetString("calibrate")
+		if batch != "" || calibrate != "" {
+			n--
+		}
+		return cobra.ExactArgs(n)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		collectionID := args[0]
-		query := args[1]
-
-		// Get search options
-		searchType, _ := cmd.Flags().GetString("type")
-		limit, _ := cmd.Flags().GetInt("limit")
-		vectorWeight, _ := cmd.Flags().GetFloat64("vector-weight")
-		textWeight, _ := cmd.Flags().GetFloat64("text-weight")
-		minScore, _ := cmd.Flags().GetFloat64("min-score")
 		showScores, _ := cmd.Flags().GetBool("show-scores")
 		showContent, _ := cmd.Flags().GetBool("show-content")
-		maxDistance, _ := cmd.Flags().GetFloat64("max-distance")
-		fileFilter, _ := cmd.Flags().GetString("file-filter")
-		contentFilter, _ := cmd.Flags().GetString("content-filter")
-
-		// Get reranking options
-		enableReranking, _ := cmd.Flags().GetBool("rerank")
-		rerankInstruction, _ := cmd.Flags().GetString("rerank-instruction")
-		originalWeight, _ := cmd.Flags().GetFloat64("original-weight")
-		rerankWeight, _ := cmd.Flags().GetFloat64("rerank-weight")
-		rerankLimit, _ := cmd.Flags().GetInt("rerank-limit")
+		route, _ := cmd.Flags().GetBool("route")
+		batch, _ := cmd.Flags().GetString("batch")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		calibrate, _ := cmd.Flags().GetString("calibrate")
+		if calibrate != "" && (batch != "" || route) {
+			return fmt.Errorf("--calibrate can't be combined with --batch or --route")
+		}
+		if local, _ := cmd.Flags().GetBool("local"); local {
+			if route || batch != "" || calibrate != "" {
+				return fmt.Errorf("--local can't be combined with --route, --batch or --calibrate")
+			}
+			return runLocalSearch(cmd, args[0], args[1])
+		}
 
 		// Connect to database
-		db, err := database.NewConnection(&cfg.Database)
+		db, err := openMigratedDatabase()
 		if err != nil {
-			return fmt.Errorf("failed to connect to database: %w", err)
-		}
-		defer db.Close()
-
-		// Create managers
-		collectionMgr := database.NewCollectionManager(db)
-
-		// Create search engine with or without reranking
-		var searchEngine database.SearchEngine
-		if enableReranking {
-			// Create reranker
-			reranker, err := client.NewReranker(cfg)
-			if err != nil {
-				return fmt.Errorf("failed to create reranker: %w", err)
+			if !route {
+				return fmt.Errorf("%w\nuse --local to search the collection's files without the database", err)
 			}
-			searchEngine = database.NewSearchEngineWithReranker(db, reranker)
-		} else {
-			searchEngine = database.NewSearchEngine(db)
+			return err
 		}
 
-		// Get collection by ID or name
-		collection, err := collectionMgr.GetCollectionByIdOrName(collectionID)
+		ctx := context.Background()
+		collectionRef := ""
+		if !route {
+			collectionRef = args[0]
+		}
+		s, err := newSearcher(ctx, cmd, db, collectionRef)
 		if err != nil {
-			return fmt.Errorf("failed to get collection: %w", err)
+			return err
 		}
 
-		output.KeyValue("Searching in collection", collection.Name)
-		output.KeyValue("Query", query)
-		output.KeyValue("Search type", searchType)
-
-		// Create search options
-		searchOpts := &database.SearchOptions{
-			SearchType:    database.SearchType(searchType),
-			VectorWeight:  vectorWeight,
-			TextWeight:    textWeight,
-			MinScore:      minScore,
-			MaxDistance:   maxDistance,
-			FileFilter:    fileFilter,
-			ContentFilter: contentFilter,
+		if batch != "" {
+			return runBatchSearch(ctx, s, batch, concurrency, showContent)
 		}
-
-		// Add reranking options if enabled
-		if enableReranking {
-			searchOpts.EnableReranking = true
-			searchOpts.RerankInstruction = rerankInstruction
-			searchOpts.OriginalWeight = originalWeight
-			searchOpts.RerankWeight = rerankWeight
-			searchOpts.RerankLimit = rerankLimit
+		if calibrate != "" {
+			return runCalibration(ctx, s, calibrate)
 		}
 
-		// Determine if we need embeddings based on search type
-		var queryEmbedding []float32
-		var textQuery string
+		query := args[len(args)-1]
+		if !route {
+			output.KeyValue("Searching in collection", s.collections[0].Name)
+		}
 
-		switch database.SearchType(searchType) {
-		case database.SearchTypeText:
-			textQuery = query
-		case database.SearchTypeVector, database.SearchTypeHybrid, database.SearchTypeSemantic:
-			// Create embedder for generating embeddings
-			embedder, err := client.NewEmbedder(cfg)
-			if err != nil {
-				return fmt.Errorf("failed to create embedder: %w", err)
-			}
+		started := time.Now()
+		outcome, err := s.search(ctx, query)
+		if err != nil {
+			return err
+		}
 
-			// Create embedding service
-			embeddingService := embedding.New(embedder, &cfg.Embedding)
+		if output.IsJSON() {
+			result := &batchResult{Query: query, Results: []batchHit{}, DurationMS: time.Since(started).Milliseconds()}
+			addSearchOutcome(result, outcome, showContent)
+			return output.JSON(result)
+		}
 
-			// Generate embedding for query
-			ctx := context.Background()
-			queryEmbedding, err = embeddingService.GenerateEmbeddingForText(ctx, query)
-			if err != nil {
-				return fmt.Errorf("failed to generate query embedding: %w", err)
+		if route {
+			if len(outcome.routes) == 0 {
+				output.Info("No indexed collections to route the query to. Index a collection with 'rag-cli index' first.")
+				return nil
 			}
-
-			// For hybrid search, also use the original query as text
-			if database.SearchType(searchType) == database.SearchTypeHybrid {
-				textQuery = query
+			output.Bold("Routed to collections:")
+			for _, route := range outcome.routes {
+				output.Info("  %s (score %.4f)", route.Collection.Name, route.Score)
 			}
 		}
 
-		// Search documents using the enhanced search
-		results, err := searchEngine.SearchDocumentsWithOptions(collection.ID, queryEmbedding, textQuery, limit, searchOpts)
-		if err != nil {
-			return fmt.Errorf("failed to search documents: %w", err)
+		output.KeyValue("Query", query)
+		output.KeyValue("Search type", string(s.opts.SearchType))
+		if !route && len(s.boosts[s.collections[0].ID]) > 0 {
+			output.KeyValue("Boosts", formatBoostRules(s.boosts[s.collections[0].ID]))
+		}
+		for _, warning := range outcome.warnings {
+			output.Warning("%s", warning)
+		}
+		switch {
+		case outcome.suggestion != "":
+			output.Info("Did you mean: %s", outcome.suggestion)
+		case outcome.query != query:
+			output.Info("Did you mean: %s (searching for it instead)", outcome.query)
+		}
+		if outcome.translation != "" {
+			output.Info("Searching in %s for: %s", s.translator.Language(), outcome.translation)
+		}
+		if len(outcome.variants) > 0 {
+			output.Info("Query variants:")
+			for _, variant := range outcome.variants {
+				output.Info("  - %s", variant.Text)
+			}
 		}
 
-		// Rank and filter results
-		results = searchEngine.RankSearchResults(results)
-		results = searchEngine.FilterSearchResults(results, minScore)
-
+		results := outcome.results
 		if len(results) == 0 {
 			output.Info("No documents found.")
 			return nil
 		}
 
 		// Get search statistics
-		stats := searchEngine.GetSearchStats(results)
+		stats := s.searchEngine.GetSearchStats(results)
 		output.Success("Found %d documents:", len(results))
+		if s.opts.MaxTokens > 0 {
+			output.KeyValuef("Tokens", "%d of %d", database.TotalTokens(results), s.opts.MaxTokens)
+		}
 		if showScores {
 			output.KeyValuef("Average Combined Score", "%.4f", stats["avg_combined_score"])
 			output.KeyValuef("Score Range", "%.4f - %.4f", stats["min_score"], stats["max_score"])
@@ -178,13 +305,25 @@ Examples:
 
 		for i, result := range results {
 			output.Bold("Result %d:", i+1)
+			if route {
+				output.KeyValue("Collection", outcome.collectionNames[result.Document.CollectionID])
+			}
 			output.KeyValue("File", result.Document.FileName)
-			output.KeyValue("Path", result.Document.FilePath)
-			output.KeyValuef("Chunk", "%d", result.Document.ChunkIndex)
+			output.KeyValue("Path", localPath(result.Document))
+			output.KeyValue("Chunk", formatChunk(result))
+			if location := chunkLocation(result.Document); location != "" {
+				output.KeyValue("Location", location)
+			}
+			if s.calibration != nil {
+				output.KeyValuef("Confidence", "%.0f%%", 100*result.Confidence)
+			}
 
 			if showScores {
 				output.KeyValuef("Vector Score", "%.4f", result.VectorScore)
 				output.KeyValuef("Text Score", "%.4f", result.TextScore)
+				if s.opts.PathWeight > 0 {
+					output.KeyValuef("Path Score", "%.4f", result.PathScore)
+				}
 				output.KeyValuef("Combined Score", "%.4f", result.CombinedScore)
 				output.KeyValuef("Rank", "%d", result.Rank)
 			}
@@ -200,24 +339,924 @@ Examples:
 	},
 }
 
+// searcher runs searches with the services and options given to the search
+// command. It is safe for concurrent use, so batches are searched in
+// parallel.
+type searcher struct {
+	cmd              *cobra.Command
+	collectionMgr    database.CollectionManager
+	searchEngine     database.SearchEngine
+	embeddingService *embedding.Service
+	spellChecker     *spelling.Service
+	expander         *expansion.Service
+	translator       *translation.Service
+	opts             database.SearchOptions
+	limit            int
+	calibration      *calibration.Model // Maps combined scores to confidences, if fitted for the search type
+
+	// Either the collections to search, or the router that picks them
+	collections []*database.Collection
+	router      database.CollectionRouter
+	routeLimit  int
+
+	mu     sync.Mutex
+	boosts map[string][]database.BoostRule      // Boosting rules by collection ID
+	where  map[string][]database.MetadataFilter // Metadata filters by collection ID
+}
+
+// searchOutcome is the outcome of a search
+type searchOutcome struct {
+	routes          []*database.CollectionRoute // Collections the query was routed to
+	collectionNames map[string]string           // Names of the searched collections by ID
+	query           string                      // Query searched for, corrected when auto-correct is on
+	suggestion      string                      // Spelling suggestion that wasn't searched for
+	translation     string                      // Translation of the query searched for, if translated
+	variants        []database.QueryVariant
+	warnings        []string
+	results         []*database.SearchResult
+}
+
+// newSearcher creates a searcher for a collection, or for routed queries
+// when collectionRef is empty
+func newSearcher(ctx context.Context, cmd *cobra.Command, db *sql.DB, collectionRef string) (*searcher, error) {
+	if err := applyProfile(cmd, "type"); err != nil {
+		return nil, err
+	}
+
+	// Get collection by ID or name, and use the ranking tuned for it
+	collectionMgr := database.NewCollectionManager(db)
+	var collection *database.Collection
+	if collectionRef != "" {
+		var err error
+		collection, err = resolveCollection(collectionMgr, collectionRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get collection: %w", err)
+		}
+		if err := applyCollectionRanking(cmd, collectionMgr, collection.ID, "type"); err != nil {
+			return nil, err
+		}
+	}
+
+	searchType, _ := cmd.Flags().GetString("type")
+	limit, maxTokens := getResultLimit(cmd)
+	vectorWeight, _ := cmd.Flags().GetFloat64("vector-weight")
+	textWeight, _ := cmd.Flags().GetFloat64("text-weight")
+	minScore, _ := cmd.Flags().GetFloat64("min-score")
+	maxDistance, _ := cmd.Flags().GetFloat64("max-distance")
+	fileFilter, _ := cmd.Flags().GetString("file-filter")
+	fileTypes, _ := cmd.Flags().GetStringSlice("file-types")
+	contentFilter, _ := cmd.Flags().GetString("content-filter")
+	routeLimit, _ := cmd.Flags().GetInt("route-limit")
+	contextChunks, err := getContextChunks(cmd)
+	if err != nil {
+		return nil, err
+	}
+	enableReranking, _ := cmd.Flags().GetBool("rerank")
+	normalization, err := getScoreNormalization(cmd)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := getMetadataMatch(cmd)
+	if err != nil {
+		return nil, err
+	}
+	mmr, err := getMMR(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &searcher{
+		cmd:           cmd,
+		collectionMgr: collectionMgr,
+		limit:         limit,
+		routeLimit:    routeLimit,
+		boosts:        make(map[string][]database.BoostRule),
+		where:         make(map[string][]database.MetadataFilter),
+		opts: database.SearchOptions{
+			SearchType:    database.SearchType(searchType),
+			VectorWeight:  vectorWeight,
+			TextWeight:    textWeight,
+			MinScore:      minScore,
+			MaxDistance:   maxDistance,
+			FileFilter:    fileFilter,
+			FileTypes:     database.ParseFileTypes(fileTypes),
+			ContentFilter: contentFilter,
+			Metadata:      metadata,
+			Normalization: normalization,
+			PathWeight:    getPathWeight(cmd),
+			MaxTokens:     maxTokens,
+			ContextChunks: contextChunks,
+			MMRLambda:     mmr,
+		},
+	}
+
+	s.calibration = scoreCalibration(s.opts.SearchType)
+	warnEncryptedTextSearch(s.opts.SearchType, contentFilter)
+
+	// Create search engine with or without reranking
+	if enableReranking {
+		reranker, err := backends.Reranker()
+		if err != nil {
+			return nil, err
+		}
+		s.searchEngine = database.NewSearchEngineWithReranker(db, reranker)
+
+		rerankSettings := getRerankSettings(cmd)
+		s.opts.EnableReranking = true
+		s.opts.RerankInstruction = rerankSettings.Instruction
+		s.opts.OriginalWeight = rerankSettings.OriginalWeight
+		s.opts.RerankWeight = rerankSettings.RerankWeight
+		s.opts.RerankLimit = rerankSettings.Limit
+		s.opts.RetrieveLimit = rerankSettings.Retrieve
+	} else {
+		s.searchEngine = database.NewSearchEngine(db)
+	}
+
+	// Create the spell check and query expansion services if they are enabled
+	if s.spellChecker, err = newSpellChecker(cmd, db); err != nil {
+		return nil, err
+	}
+	if s.expander, err = newQueryExpander(cmd); err != nil {
+		return nil, err
+	}
+	if s.translator, err = newTranslator(cmd); err != nil {
+		return nil, err
+	}
+
+	// The embedder is only created once a search actually needs query embeddings
+	s.embeddingService = embedding.New(backends.LazyEmbedder(), &cfg.Embedding)
+
+	if collection == nil {
+		s.router, err = prepareRouter(ctx, db, s.embeddingService)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	s.collections = []*database.Collection{collection}
+	rememberCollection(collection)
+	if _, err := s.boostsFor(collection.ID); err != nil {
+		return nil, err
+	}
+	if _, err := s.whereFor(collection.ID); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// warnEncryptedTextSearch warns that encrypted chunks are only found by their
+// embeddings, so the text ranking of searchType and the content filter skip
+// them
+func warnEncryptedTextSearch(searchType database.SearchType, contentFilter string) {
+	if !cfg.Encryption.Enabled {
+		return
+	}
+	switch {
+	case !searchType.UsesText():
+	case searchType.UsesEmbedding():
+		output.Warning("Encrypted chunks have no searchable text, %s search only finds them by their embeddings", searchType)
+	default:
+		output.Warning("Encrypted chunks have no searchable text, %s search doesn't find them; use vector search", searchType)
+	}
+	if contentFilter != "" {
+		output.Warning("--content-filter doesn't match encrypted chunks")
+	}
+}
+
+// boostsFor returns the boosting rules of a collection, loading them on
+// first use
+func (s *searcher) boostsFor(collectionID string) ([]database.BoostRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rules, ok := s.boosts[collectionID]; ok {
+		return rules, nil
+	}
+	rules, err := getBoostRules(s.cmd, s.collectionMgr, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	s.boosts[collectionID] = rules
+	return rules, nil
+}
+
+// whereFor returns the metadata filters of --where parsed against a
+// collection's metadata schema, parsing them on first use
+func (s *searcher) whereFor(collectionID string) ([]database.MetadataFilter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if filters, ok := s.where[collectionID]; ok {
+		return filters, nil
+	}
+	filters, err := getMetadataFilters(s.cmd, s.collectionMgr, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	s.where[collectionID] = filters
+	return filters, nil
+}
+
+// search searches for a query in the searcher's collections, or in the
+// collections the query is routed to
+func (s *searcher) search(ctx context.Context, query string) (*searchOutcome, error) {
+	outcome := &searchOutcome{query: query, collectionNames: make(map[string]string)}
+
+	// Pick the collections to search: the given one, or the ones the
+	// router finds most relevant to the query
+	collections := s.collections
+	var queryEmbedding []float32
+	if s.router != nil {
+		var err error
+		queryEmbedding, err = s.embeddingService.GenerateEmbeddingForText(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		}
+		outcome.routes, err = s.router.RouteQuery(queryEmbedding, query, s.routeLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to route query: %w", err)
+		}
+		if len(outcome.routes) == 0 {
+			return outcome, nil
+		}
+		collections = nil
+		for _, route := range outcome.routes {
+			collections = append(collections, route.Collection)
+		}
+	}
+
+	// Correct misspelled words before the query is embedded or expanded.
+	// Routed queries are checked against the best matching collection.
+	corrected, err := spellCheck(ctx, s.spellChecker, collections[0].ID, query)
+	if err != nil {
+		outcome.warnings = append(outcome.warnings, fmt.Sprintf("Spell check failed: %v", err))
+	} else if corrected != query {
+		if s.spellChecker.AutoCorrect() {
+			outcome.query, queryEmbedding = corrected, nil
+		} else {
+			outcome.suggestion = corrected
+		}
+	}
+	query = outcome.query
+
+	// Translate the query into the language of the documents. The query as
+	// it was asked is searched alongside the translation.
+	original := query
+	if s.translator != nil {
+		translated, err := s.translator.TranslateQuery(ctx, query)
+		if err != nil {
+			outcome.warnings = append(outcome.warnings, fmt.Sprintf("%v, searching for the query as it is", err))
+		} else if translated != query {
+			outcome.translation, query, queryEmbedding = translated, translated, nil
+		}
+	}
+
+	// Determine if we need embeddings based on search type
+	var textQuery string
+	switch s.opts.SearchType {
+	case database.SearchTypeText, database.SearchTypeBM25:
+		textQuery = query
+		queryEmbedding = nil
+	case database.SearchTypeVector, database.SearchTypeHybrid, database.SearchTypeSemantic, database.SearchTypeFusion:
+		// Generate embedding for query, unless routing already did
+		if queryEmbedding == nil {
+			queryEmbedding, err = s.embeddingService.GenerateEmbeddingForText(ctx, query)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+			}
+		}
+
+		// For hybrid and fusion search, also use the original query as text
+		if s.opts.SearchType == database.SearchTypeHybrid || s.opts.SearchType == database.SearchTypeFusion {
+			textQuery = query
+		}
+	}
+
+	// Expand the query into variants that are searched alongside it
+	searchOpts := s.opts
+	if s.expander != nil {
+		texts, err := s.expander.Expand(ctx, query)
+		if err != nil {
+			outcome.warnings = append(outcome.warnings, fmt.Sprintf("Query expansion incomplete: %v", err))
+		}
+		searchOpts.Variants, err = embedVariants(ctx, s.embeddingService, s.opts.SearchType, texts)
+		if err != nil {
+			return nil, err
+		}
+		outcome.variants = searchOpts.Variants
+	}
+	searchOpts.Variants, err = withOriginalQuery(ctx, s.embeddingService, s.opts.SearchType, searchOpts.Variants, original, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Search each collection using the enhanced search
+	var results []*database.SearchResult
+	for _, collection := range collections {
+		outcome.collectionNames[collection.ID] = collection.Name
+
+		collectionOpts := searchOpts
+		if collectionOpts.Boosts, err = s.boostsFor(collection.ID); err != nil {
+			return nil, err
+		}
+		if collectionOpts.Where, err = s.whereFor(collection.ID); err != nil {
+			return nil, err
+		}
+
+		collectionResults, err := s.searchEngine.SearchDocumentsWithOptions(collection.ID, queryEmbedding, textQuery, s.limit, &collectionOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search documents in %s: %w", collection.Name, err)
+		}
+		results = append(results, collectionResults...)
+	}
+
+	// Rank and filter results
+	results = s.searchEngine.RankSearchResults(results)
+	results = s.searchEngine.FilterSearchResults(results, s.opts.MinScore)
+	if len(results) > s.limit {
+		results = results[:s.limit]
+	}
+	if s.opts.MaxTokens > 0 {
+		results = database.FitTokenBudget(results, s.opts.MaxTokens)
+	}
+	applyCalibration(s.calibration, results)
+	if err := translateResults(ctx, s.translator, original, results); err != nil {
+		outcome.warnings = append(outcome.warnings, fmt.Sprintf("%v, showing the documents as they are", err))
+	}
+	outcome.results = results
+
+	return outcome, nil
+}
+
+// getRerankSettings returns the rerank settings from the configuration,
+// overridden by any rerank flags explicitly set on the command line
+func getRerankSettings(cmd *cobra.Command) config.RerankConfig {
+	settings := cfg.Rerank
+
+	if cmd.Flags().Changed("rerank-instruction") {
+		settings.Instruction, _ = cmd.Flags().GetString("rerank-instruction")
+	}
+	if cmd.Flags().Changed("original-weight") {
+		settings.OriginalWeight, _ = cmd.Flags().GetFloat64("original-weight")
+	}
+	if cmd.Flags().Changed("rerank-weight") {
+		settings.RerankWeight, _ = cmd.Flags().GetFloat64("rerank-weight")
+	}
+	if cmd.Flags().Changed("rerank-limit") {
+		settings.Limit, _ = cmd.Flags().GetInt("rerank-limit")
+	}
+	if cmd.Flags().Changed("retrieve") {
+		settings.Retrieve, _ = cmd.Flags().GetInt("retrieve")
+	}
+
+	return settings
+}
+
+// getBoostRules returns the collection's stored boosting rules followed by
+// any rules given with --boost
+func getBoostRules(cmd *cobra.Command, collectionMgr database.CollectionManager, collectionID string) ([]database.BoostRule, error) {
+	rules, err := collectionMgr.GetBoosts(collectionID)
+	if err != nil {
+		output.Warning("Could not load collection boosts: %v", err)
+		rules = nil
+	}
+
+	specs, _ := cmd.Flags().GetStringArray("boost")
+	flagRules, err := database.ParseBoostRules(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(rules, flagRules...), nil
+}
+
+// getMetadataFilters parses the --where filters against the metadata schema
+// of a collection
+func getMetadataFilters(cmd *cobra.Command, collectionMgr database.CollectionManager, collectionID string) ([]database.MetadataFilter, error) {
+	exprs, _ := cmd.Flags().GetStringArray("where")
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+
+	schema, err := collectionMgr.GetMetadataSchema(collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata schema: %w", err)
+	}
+	return database.ParseMetadataFilters(exprs, schema)
+}
+
+// getMetadataMatch parses the --metadata key-value pairs
+func getMetadataMatch(cmd *cobra.Command) (map[string]string, error) {
+	exprs, _ := cmd.Flags().GetStringArray("metadata")
+	return database.ParseMetadataMatch(exprs)
+}
+
+// newQueryExpander returns the query expansion service configured by
+// expansion and the --expand, --paraphrases and --query-rewrite flags, or
+// nil when neither query expansion nor rewriting is enabled
+func newQueryExpander(cmd *cobra.Command) (*expansion.Service, error) {
+	settings := cfg.Expansion
+
+	if cmd.Flags().Changed("expand") {
+		settings.Enabled, _ = cmd.Flags().GetBool("expand")
+	}
+	if cmd.Flags().Changed("paraphrases") {
+		settings.Paraphrases, _ = cmd.Flags().GetInt("paraphrases")
+	}
+	if cmd.Flags().Changed("query-rewrite") {
+		settings.Rewrite, _ = cmd.Flags().GetBool("query-rewrite")
+	}
+	if !settings.Enabled && !settings.Rewrite {
+		return nil, nil
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid query expansion settings: %w", err)
+	}
+
+	// Rewriting alone searches neither synonyms nor paraphrases
+	if !settings.Enabled {
+		settings.Synonyms = nil
+		settings.Paraphrases = 0
+	}
+
+	// The chat model is only needed for paraphrases and rewrites
+	var chat client.Client
+	if settings.Paraphrases > 0 || settings.Rewrite {
+		var err error
+		chat, err = backends.Chat()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return expansion.New(chat, &settings), nil
+}
+
+// newSpellChecker returns the spell check service configured by spellcheck
+// and the --spellcheck, --spellcheck-mode and --autocorrect flags, or nil
+// when spell checking is disabled
+func newSpellChecker(cmd *cobra.Command, db *sql.DB) (*spelling.Service, error) {
+	settings := cfg.SpellCheck
+
+	if cmd.Flags().Changed("spellcheck") {
+		settings.Enabled, _ = cmd.Flags().GetBool("spellcheck")
+	}
+	if cmd.Flags().Changed("spellcheck-mode") {
+		settings.Mode, _ = cmd.Flags().GetString("spellcheck-mode")
+	}
+	if cmd.Flags().Changed("autocorrect") {
+		settings.AutoCorrect, _ = cmd.Flags().GetBool("autocorrect")
+	}
+	if !settings.Enabled {
+		return nil, nil
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid spell check settings: %w", err)
+	}
+
+	// The chat model is only needed in llm mode
+	var chat client.Client
+	if settings.GetMode() == config.SpellCheckModeLLM {
+		var err error
+		chat, err = backends.Chat()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return spelling.New(database.NewVocabularyManager(db), chat, &settings), nil
+}
+
+// spellCheck returns the spell checker's correction of a query, which is the
+// query itself when it has no misspelled words or spell checking is disabled
+func spellCheck(ctx context.Context, spellChecker *spelling.Service, collectionID, query string) (string, error) {
+	if spellChecker == nil {
+		return query, nil
+	}
+	return spellChecker.Correct(ctx, collectionID, query)
+}
+
+// correctQuery spell checks a query and prints a "did you mean" suggestion
+// when it has corrections. It returns the query to search for, which is the
+// corrected one when auto-correct is enabled. A failed spell check only
+// produces a warning.
+func correctQuery(ctx context.Context, spellChecker *spelling.Service, collectionID, query string) string {
+	corrected, err := spellCheck(ctx, spellChecker, collectionID, query)
+	if err != nil {
+		output.Warning("Spell check failed: %v", err)
+		return query
+	}
+	if corrected == query {
+		return query
+	}
+
+	if !spellChecker.AutoCorrect() {
+		output.Info("Did you mean: %s", corrected)
+		return query
+	}
+	output.Info("Did you mean: %s (searching for it instead)", corrected)
+	return corrected
+}
+
+// newTranslator returns the translation service configured by translation
+// and the --translate, --corpus-language and --translate-results flags, or
+// nil when translation is disabled. Giving --corpus-language enables
+// translation unless --translate=false is given too.
+func newTranslator(cmd *cobra.Command) (*translation.Service, error) {
+	settings := cfg.Translation
+
+	if cmd.Flags().Changed("corpus-language") {
+		settings.Language, _ = cmd.Flags().GetString("corpus-language")
+		settings.Enabled = true
+	}
+	if cmd.Flags().Changed("translate") {
+		settings.Enabled, _ = cmd.Flags().GetBool("translate")
+	}
+	if cmd.Flags().Changed("translate-results") {
+		settings.TranslateResults, _ = cmd.Flags().GetBool("translate-results")
+	}
+	return newTranslationService(settings)
+}
+
+// newTranslationService creates the translation service of the given
+// settings, or returns nil when translation is disabled
+func newTranslationService(settings config.TranslationConfig) (*translation.Service, error) {
+	if !settings.Enabled {
+		return nil, nil
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid translation settings: %w", err)
+	}
+
+	chat, err := backends.Chat()
+	if err != nil {
+		return nil, err
+	}
+	return translation.New(chat, &settings), nil
+}
+
+// translateQuery translates a query into the language of the documents,
+// telling the user what is searched for. It returns the query unchanged
+// when translation is disabled, and a failed translation only produces a
+// warning.
+func translateQuery(ctx context.Context, translator *translation.Service, query string) string {
+	if translator == nil {
+		return query
+	}
+	translated, err := translator.TranslateQuery(ctx, query)
+	if err != nil {
+		output.Warning("%v, searching for the query as it is", err)
+		return query
+	}
+	if translated != query {
+		output.Info("Searching in %s for: %s", translator.Language(), translated)
+	}
+	return translated
+}
+
+// withOriginalQuery adds the query as it was asked to the variants searched
+// alongside its translation, so documents in the language of the question
+// are found too
+func withOriginalQuery(ctx context.Context, embedder embedding.TextEmbedder, searchType database.SearchType, variants []database.QueryVariant, original, translated string) ([]database.QueryVariant, error) {
+	if original == translated {
+		return variants, nil
+	}
+	originals, err := embedVariants(ctx, embedder, searchType, []string{original})
+	if err != nil {
+		return nil, err
+	}
+	return append(variants, originals...), nil
+}
+
+// translateResults translates the content of retrieved documents into the
+// language of the question, when translation is enabled with
+// translate_results. Documents stay as they are if translating them fails.
+func translateResults(ctx context.Context, translator *translation.Service, question string, results []*database.SearchResult) error {
+	if translator == nil || !translator.TranslatesDocuments() {
+		return nil
+	}
+	for _, result := range results {
+		content, err := translator.TranslateDocument(ctx, question, result.Document.Content)
+		if err != nil {
+			return err
+		}
+		result.Document.Content = content
+	}
+	return nil
+}
+
+// expandQuery returns the variants of a query to search alongside it, with
+// embeddings when the search type uses them. Failing to generate paraphrases
+// only produces a warning, so the synonym variants are still searched.
+func expandQuery(ctx context.Context, expander *expansion.Service, embedder embedding.TextEmbedder, searchType database.SearchType, query string) ([]database.QueryVariant, error) {
+	if expander == nil {
+		return nil, nil
+	}
+
+	texts, err := expander.Expand(ctx, query)
+	if err != nil {
+		output.Warning("Query expansion incomplete: %v", err)
+	}
+	return embedVariants(ctx, embedder, searchType, texts)
+}
+
+// embedVariants turns the texts of query variants into variants to search,
+// with embeddings when the search type uses them
+func embedVariants(ctx context.Context, embedder embedding.TextEmbedder, searchType database.SearchType, texts []string) ([]database.QueryVariant, error) {
+	variants := make([]database.QueryVariant, 0, len(texts))
+	for _, text := range texts {
+		variant := database.QueryVariant{Text: text}
+		if searchType.UsesEmbedding() {
+			var err error
+			variant.Embedding, err = embedder.GenerateEmbeddingForText(ctx, text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate embedding for query variant %q: %w", text, err)
+			}
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+// formatBoostRules formats boosting rules for display
+func formatBoostRules(rules []database.BoostRule) string {
+	specs := make([]string, len(rules))
+	for i, rule := range rules {
+		specs[i] = rule.String()
+	}
+	return strings.Join(specs, ", ")
+}
+
+// addBoostFlag registers the flag for boosting rules on top of the collection's own
+func addBoostFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArray("boost", nil, "Boosting rule applied in addition to the collection's boosts, e.g. 'file:README*=+0.1' (repeatable)")
+}
+
+// tokenBudgetLimit is the number of results searched for to fill a token
+// budget when no --limit is given
+const tokenBudgetLimit = 50
+
+// getResultLimit returns the number of results to search for and the
+// --max-tokens budget they are trimmed to. --return takes precedence over
+// --limit, and with a budget and neither, enough results are searched for to
+// fill it.
+func getResultLimit(cmd *cobra.Command) (int, int) {
+	limit, _ := cmd.Flags().GetInt("limit")
+	maxTokens, _ := cmd.Flags().GetInt("max-tokens")
+	if cmd.Flags().Changed("return") {
+		limit, _ = cmd.Flags().GetInt("return")
+	} else if maxTokens > 0 && !cmd.Flags().Changed("limit") {
+		limit = tokenBudgetLimit
+	}
+	return limit, maxTokens
+}
+
+// addMaxTokensFlag registers the flag for token budget aware retrieval
+func addMaxTokensFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().Int("max-tokens", 0, usage)
+}
+
+// getContextChunks returns the number of neighboring chunks joined to each
+// result with --expand-context
+func getContextChunks(cmd *cobra.Command) (int, error) {
+	contextChunks, _ := cmd.Flags().GetInt("expand-context")
+	if contextChunks < 0 {
+		return 0, fmt.Errorf("--expand-context must be 0 or more")
+	}
+	return contextChunks, nil
+}
+
+// addExpandContextFlag registers the flag that joins neighboring chunks to
+// each result
+func addExpandContextFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().Int("expand-context", 0, usage)
+}
+
+// formatChunk describes the chunk of a result, and the chunks around it
+// joined to its content
+func formatChunk(result *database.SearchResult) string {
+	chunks := result.ContextChunks
+	if len(chunks) < 2 {
+		return strconv.Itoa(result.Document.ChunkIndex)
+	}
+	return fmt.Sprintf("%d (with chunks %d-%d)", result.Document.ChunkIndex, chunks[0], chunks[len(chunks)-1])
+}
+
+// addMetadataFlags registers the flags that filter by metadata
+func addMetadataFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("where", nil, "Only use chunks whose metadata field matches, e.g. 'priority>=2' (repeatable; see collection set-schema)")
+	cmd.Flags().StringArray("metadata", nil, "Only use chunks whose metadata has this exact value, e.g. 'file_name=README.md' (repeatable)")
+}
+
+// addSpellCheckFlags registers the flags that override the spell check configuration
+func addSpellCheckFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("spellcheck", false, "Correct misspelled words in the query before searching (overrides spellcheck.enabled)")
+	cmd.Flags().String("spellcheck-mode", "", "Spell check mode: vocabulary or llm (overrides spellcheck.mode)")
+	cmd.Flags().Bool("autocorrect", true, "Search with the corrected query instead of only suggesting it (overrides spellcheck.auto_correct)")
+}
+
+// addExpansionFlags registers the flags that override the query expansion configuration
+func addExpansionFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("expand", false, "Also search synonym rewrites and paraphrases of the query (overrides expansion.enabled)")
+	cmd.Flags().Int("paraphrases", 0, "Number of LLM paraphrases to generate when expanding (0 = synonyms only, overrides expansion.paraphrases)")
+	cmd.Flags().Bool("query-rewrite", false, "Ask the chat model to rewrite the query into search queries, searched and fused with it (overrides expansion.rewrite)")
+}
+
+// addTranslationFlags registers the flags that override the translation configuration
+func addTranslationFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("translate", false, "Translate the query into the language of the documents before searching (overrides translation.enabled)")
+	cmd.Flags().String("corpus-language", "", "Language of the documents to translate the query into, e.g. German; enables --translate (overrides translation.language)")
+	cmd.Flags().Bool("translate-results", false, "Translate retrieved documents back into the language of the question (overrides translation.translate_results)")
+}
+
+// getScoreNormalization returns the configured score normalization, or the
+// one given with --normalize
+func getScoreNormalization(cmd *cobra.Command) (database.ScoreNormalization, error) {
+	normalization := cfg.Search.GetNormalization()
+	if cmd.Flags().Changed("normalize") {
+		normalization, _ = cmd.Flags().GetString("normalize")
+	}
+	return database.ParseScoreNormalization(normalization)
+}
+
+// addNormalizeFlag registers the flag that overrides the score normalization
+func addNormalizeFlag(cmd *cobra.Command) {
+	cmd.Flags().String("normalize", "", "Scale scores before combining them: none, minmax, zscore (overrides search.normalization)")
+}
+
+// getPathWeight returns the configured weight of file path similarity, or
+// the one given with --path-weight
+func getPathWeight(cmd *cobra.Command) float64 {
+	pathWeight := cfg.Search.PathWeight
+	if cmd.Flags().Changed("path-weight") {
+		pathWeight, _ = cmd.Flags().GetFloat64("path-weight")
+	}
+	return pathWeight
+}
+
+// addPathWeightFlag registers the flag that overrides the weight of file
+// path similarity
+func addPathWeightFlag(cmd *cobra.Command) {
+	cmd.Flags().Float64("path-weight", 0, "Weight of the similarity between the query and file paths (0 = off, overrides search.path_weight)")
+}
+
+// getMMR returns the maximal marginal relevance lambda given with --mmr
+func getMMR(cmd *cobra.Command) (float64, error) {
+	mmr, _ := cmd.Flags().GetFloat64("mmr")
+	if mmr < 0 || mmr > 1 {
+		return 0, fmt.Errorf("--mmr must be between 0 and 1")
+	}
+	return mmr, nil
+}
+
+// addMMRFlag registers the flag that diversifies results with maximal
+// marginal relevance
+func addMMRFlag(cmd *cobra.Command) {
+	cmd.Flags().Float64("mmr", 0, "Diversify results with maximal marginal relevance: 1 = relevance only, lower values favor results unlike the better ranked ones (0 = off)")
+}
+
+// applyProfile sets the retrieval flags of cmd that aren't given to the
+// settings of the ranking profile selected with --profile. The search type
+// flag is named searchTypeFlag, since search and chat name it differently.
+func applyProfile(cmd *cobra.Command, searchTypeFlag string) error {
+	name, _ := cmd.Flags().GetString("profile")
+	if name == "" {
+		return nil
+	}
+	profile, err := cfg.Search.Profile(name)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]string{searchTypeFlag: profile.SearchType}
+	floats := map[string]float64{
+		"vector-weight": profile.VectorWeight,
+		"text-weight":   profile.TextWeight,
+		"mmr":           profile.MMR,
+		"min-score":     profile.MinScore,
+	}
+	for flag, value := range floats {
+		if value != 0 {
+			values[flag] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	if profile.Limit > 0 {
+		values["limit"] = strconv.Itoa(profile.Limit)
+	}
+	if profile.Retrieve > 0 {
+		values["retrieve"] = strconv.Itoa(profile.Retrieve)
+	}
+	if profile.Rerank {
+		values["rerank"] = "true"
+	}
+
+	for flag, value := range values {
+		if value == "" || cmd.Flags().Changed(flag) {
+			continue
+		}
+		if err := cmd.Flags().Set(flag, value); err != nil {
+			return fmt.Errorf("failed to apply profile setting %s: %w", flag, err)
+		}
+	}
+	return nil
+}
+
+// applyCollectionRanking sets the ranking flags of cmd that aren't given to
+// the ranking tuned for a collection with 'rag-cli tune'. A ranking profile
+// selected with --profile takes precedence over it.
+func applyCollectionRanking(cmd *cobra.Command, collectionMgr database.CollectionManager, collectionID, searchTypeFlag string) error {
+	if name, _ := cmd.Flags().GetString("profile"); name != "" {
+		return nil
+	}
+	ranking, err := collectionMgr.GetRanking(collectionID)
+	if err != nil {
+		return err
+	}
+	if ranking == nil {
+		return nil
+	}
+
+	values := map[string]string{
+		searchTypeFlag:  string(database.SearchTypeHybrid),
+		"vector-weight": strconv.FormatFloat(ranking.VectorWeight, 'f', -1, 64),
+		"text-weight":   strconv.FormatFloat(ranking.TextWeight, 'f', -1, 64),
+	}
+	if ranking.Rerank {
+		values["rerank"] = "true"
+	}
+
+	for flag, value := range values {
+		if cmd.Flags().Changed(flag) {
+			continue
+		}
+		if err := cmd.Flags().Set(flag, value); err != nil {
+			return fmt.Errorf("failed to apply tuned ranking setting %s: %w", flag, err)
+		}
+	}
+	return nil
+}
+
+// addProfileFlag registers the flag that selects a ranking profile
+func addProfileFlag(cmd *cobra.Command) {
+	builtin := slices.Sorted(maps.Keys(config.BuiltinProfiles))
+	cmd.Flags().String("profile", "", "Ranking profile setting the search type, weights, reranking, MMR and limits that aren't given: "+strings.Join(builtin, ", ")+" or one of search.profiles")
+}
+
+// addRerankFlags registers the flags that override the rerank configuration
+func addRerankFlags(cmd *cobra.Command) {
+	cmd.Flags().String("rerank-instruction", "", "Custom instruction for reranking (overrides rerank.instruction)")
+	cmd.Flags().Float64("original-weight", 0, "Weight for original search score (0.0-1.0, overrides rerank.original_weight)")
+	cmd.Flags().Float64("rerank-weight", 0, "Weight for reranking score (0.0-1.0, overrides rerank.rerank_weight)")
+	cmd.Flags().Int("rerank-limit", 0, "Number of results to rerank (0 = all, overrides rerank.limit)")
+	cmd.Flags().Int("retrieve", 0, "Number of candidates retrieved for reranking before the top results are returned (overrides rerank.retrieve)")
+	cmd.Flags().Int("return", 0, "Number of reranked results to return (overrides --limit)")
+}
+
 func init() {
 	searchCmd.Flags().IntP("limit", "l", 10, "Maximum number of results to return")
 	searchCmd.Flags().BoolP("show-content", "s", false, "Show full content of results")
 	searchCmd.Flags().BoolP("show-scores", "", false, "Show search scores for results")
-	searchCmd.Flags().StringP("type", "t", "hybrid", "Search type: vector, text, hybrid, semantic")
+	searchCmd.Flags().StringP("type", "t", "hybrid", "Search type: vector, text, hybrid, semantic, bm25, fusion")
 	searchCmd.Flags().Float64P("vector-weight", "", 0.7, "Weight for vector similarity (0.0-1.0)")
 	searchCmd.Flags().Float64P("text-weight", "", 0.3, "Weight for text similarity (0.0-1.0)")
 	searchCmd.Flags().Float64P("min-score", "", 0.0, "Minimum similarity score")
 	searchCmd.Flags().Float64P("max-distance", "", 1.0, "Maximum vector distance")
-	searchCmd.Flags().StringP("file-filter", "", "", "Filter by file name pattern")
+	searchCmd.Flags().StringP("file-filter", "", "", "Filter by file name glob (e.g., '*.md', 'api_*.go')")
+	searchCmd.Flags().StringSlice("file-types", nil, "Only return documents with these file extensions (e.g., 'md,go')")
 	searchCmd.Flags().StringP("content-filter", "", "", "Filter by content text")
+	addMetadataFlags(searchCmd)
+	addMaxTokensFlag(searchCmd, "Return as many top results as fit in this many tokens of content, up to --limit if given (0 = no budget)")
+	addExpandContextFlag(searchCmd, "Join this many chunks before and after each result from the same file to its content")
+	addNormalizeFlag(searchCmd)
+	addPathWeightFlag(searchCmd)
+	addMMRFlag(searchCmd)
+	addProfileFlag(searchCmd)
+
+	// Routing flags
+	searchCmd.Flags().Bool("route", false, "Search the collections most relevant to the query instead of a given collection")
+	searchCmd.Flags().Int("route-limit", 2, "Number of collections to search when routing")
+
+	// Batch flags
+	searchCmd.Flags().String("batch", "", "Search the queries in a file, one per line ('-' for stdin), and print JSONL results")
+	searchCmd.Flags().Int("concurrency", 4, "Number of batch queries searched at once")
+	searchCmd.Flags().String("calibrate", "", "Fit the confidence of results from a file of labeled queries and save it")
+
+	// Local search flags
+	searchCmd.Flags().Bool("local", false, "Search the words of the query in the files of the collection's folders, or of a given folder, without the database")
 
 	// Reranking flags
 	searchCmd.Flags().BoolP("rerank", "r", false, "Enable reranking for improved results")
-	searchCmd.Flags().String("rerank-instruction", "Given a web search query, retrieve relevant passages that answer the query", "Custom instruction for reranking")
-	searchCmd.Flags().Float64("original-weight", 0.7, "Weight for original search score (0.0-1.0)")
-	searchCmd.Flags().Float64("rerank-weight", 0.3, "Weight for reranking score (0.0-1.0)")
-	searchCmd.Flags().Int("rerank-limit", 0, "Number of results to rerank (0 = all)")
+	addRerankFlags(searchCmd)
+
+	// Boosting flags
+	addBoostFlag(searchCmd)
+
+	// Spell check and query expansion flags
+	addSpellCheckFlags(searchCmd)
+	addExpansionFlags(searchCmd)
+	addTranslationFlags(searchCmd)
 
 	rootCmd.AddCommand(searchCmd)
 }
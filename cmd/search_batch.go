@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+)
+
+// maxBatchLineSize is the longest line a batch query file may have
+const maxBatchLineSize = 1024 * 1024
+
+// batchQuery is a query read from a batch file
+type batchQuery struct {
+	ID       string   `json:"id"`
+	Query    string   `json:"query"`
+	Relevant []string `json:"relevant"` // Files relevant to the query, for calibration
+}
+
+// batchResult is the JSON line printed for each query of a batch, and the
+// result of a single search in JSON mode
+type batchResult struct {
+	ID          string     `json:"id,omitempty"`
+	Query       string     `json:"query"`
+	Searched    string     `json:"searched_query,omitempty"`   // Auto-corrected query that was searched instead
+	Suggestion  string     `json:"suggestion,omitempty"`       // Spelling suggestion that wasn't searched for
+	Translated  string     `json:"translated_query,omitempty"` // Translation of the query into the language of the documents
+	Collections []string   `json:"collections,omitempty"`      // Collections the query was routed to
+	Variants    []string   `json:"variants,omitempty"`         // Query expansion variants
+	Results     []batchHit `json:"results"`
+	Warnings    []string   `json:"warnings,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	DurationMS  int64      `json:"duration_ms"`
+}
+
+// batchHit is a search result in a batch result
+type batchHit struct {
+	Rank          int     `json:"rank"`
+	Collection    string  `json:"collection"`
+	DocumentID    string  `json:"document_id"`
+	File          string  `json:"file"`
+	Path          string  `json:"path"`
+	Chunk         int     `json:"chunk"`
+	VectorScore   float64 `json:"vector_score"`
+	TextScore     float64 `json:"text_score"`
+	CombinedScore float64 `json:"combined_score"`
+	Confidence    float64 `json:"confidence,omitempty"`
+	Content       string  `json:"content,omitempty"`
+}
+
+// runBatchSearch searches for every query in a file, or in stdin when path
+// is "-", with concurrency searches running at once. One JSON line is
+// printed per query, in the order of the file; failed queries get an error
+// field instead of failing the batch.
+func runBatchSearch(ctx context.Context, s *searcher, path string, concurrency int, showContent bool) error {
+	if concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open batch file: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	queries, err := readBatchQueries(input)
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan int)
+	results := make([]chan *batchResult, len(queries))
+	for i := range results {
+		results[i] = make(chan *batchResult, 1)
+	}
+
+	var workers sync.WaitGroup
+	for w := 0; w < concurrency && w < len(queries); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				results[i] <- searchBatchQuery(ctx, s, queries[i], showContent)
+			}
+		}()
+	}
+	go func() {
+		for i := range queries {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	// Print the results in input order as they become available
+	encoder := json.NewEncoder(os.Stdout)
+	failed := 0
+	for i := range queries {
+		result := <-results[i]
+		if result.Error != "" {
+			failed++
+		}
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+	}
+	workers.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d queries failed", failed, len(queries))
+	}
+	output.Fprintf(os.Stderr, "Searched %d queries\n", len(queries))
+	return nil
+}
+
+// searchBatchQuery searches for one query of a batch
+func searchBatchQuery(ctx context.Context, s *searcher, query batchQuery, showContent bool) *batchResult {
+	started := time.Now()
+	result := &batchResult{ID: query.ID, Query: query.Query, Results: []batchHit{}}
+
+	outcome, err := s.search(ctx, query.Query)
+	result.DurationMS = time.Since(started).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	addSearchOutcome(result, outcome, showContent)
+	return result
+}
+
+// addSearchOutcome adds the outcome of a search to its JSON result
+func addSearchOutcome(result *batchResult, outcome *searchOutcome, showContent bool) {
+	if outcome.query != result.Query {
+		result.Searched = outcome.query
+	}
+	result.Suggestion = outcome.suggestion
+	result.Translated = outcome.translation
+	result.Warnings = outcome.warnings
+	for _, route := range outcome.routes {
+		result.Collections = append(result.Collections, route.Collection.Name)
+	}
+	for _, variant := range outcome.variants {
+		result.Variants = append(result.Variants, variant.Text)
+	}
+
+	for i, r := range outcome.results {
+		hit := batchHit{
+			Rank:          i + 1,
+			Collection:    outcome.collectionNames[r.Document.CollectionID],
+			DocumentID:    r.Document.ID,
+			File:          r.Document.FileName,
+			Path:          localPath(r.Document),
+			Chunk:         r.Document.ChunkIndex,
+			VectorScore:   r.VectorScore,
+			TextScore:     r.TextScore,
+			CombinedScore: r.CombinedScore,
+			Confidence:    r.Confidence,
+		}
+		if showContent {
+			hit.Content = r.Document.Content
+		}
+		result.Results = append(result.Results, hit)
+	}
+}
+
+// readBatchQueries reads the queries of a batch, one per line. A line is
+// either the query itself, identified by its line number, or a JSON object
+// with "query" and an optional "id". Blank lines and lines starting with #
+// are skipped.
+func readBatchQueries(r io.Reader) ([]batchQuery, error) {
+	var queries []batchQuery
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineSize)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		query := batchQuery{Query: line}
+		if strings.HasPrefix(line, "{") {
+			query = batchQuery{}
+			if err := json.Unmarshal([]byte(line), &query); err != nil {
+				return nil, fmt.Errorf("invalid query on line %d: %w", lineNumber, err)
+			}
+			if strings.TrimSpace(query.Query) == "" {
+				return nil, fmt.Errorf("invalid query on line %d: query is empty", lineNumber)
+			}
+		}
+		if query.ID == "" {
+			query.ID = strconv.Itoa(lineNumber)
+		}
+		queries = append(queries, query)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read queries: %w", err)
+	}
+
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries to search")
+	}
+	return queries, nil
+}
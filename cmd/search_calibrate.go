@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/busybytelab.com/rag-cli/pkg/calibration"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+)
+
+// scoreCalibration returns the configured calibration of searchType, or nil
+// when none was fitted for it
+func scoreCalibration(searchType database.SearchType) *calibration.Model {
+	if !cfg.Calibration.Fitted(string(searchType)) {
+		return nil
+	}
+	return &calibration.Model{Slope: cfg.Calibration.Slope, Intercept: cfg.Calibration.Intercept}
+}
+
+// applyCalibration sets the confidence of results from their combined score
+func applyCalibration(model *calibration.Model, results []*database.SearchResult) {
+	if model == nil {
+		return
+	}
+	for _, result := range results {
+		result.Confidence = model.Confidence(result.CombinedScore)
+	}
+}
+
+// bestConfidence returns the highest confidence of results, or 0 when there
+// are none
+func bestConfidence(results []*database.SearchResult) float64 {
+	var best float64
+	for _, result := range results {
+		if result.Confidence > best {
+			best = result.Confidence
+		}
+	}
+	return best
+}
+
+// runCalibration searches for the labeled queries in a file, fits a
+// calibration of the combined scores of their results and saves it. Each
+// line is a JSON object with the query and the relevant files, such as
+// {"query": "reset password", "relevant": ["docs/auth.md"]}.
+func runCalibration(ctx context.Context, s *searcher, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open calibration file: %w", err)
+	}
+	defer file.Close()
+
+	queries, err := readBatchQueries(file)
+	if err != nil {
+		return err
+	}
+
+	var samples []calibration.Sample
+	relevant := 0
+	for _, query := range queries {
+		if len(query.Relevant) == 0 {
+			return fmt.Errorf("query %s has no relevant files to calibrate with", query.ID)
+		}
+		outcome, err := s.search(ctx, query.Query)
+		if err != nil {
+			return fmt.Errorf("failed to search query %s: %w", query.ID, err)
+		}
+		for _, result := range outcome.results {
+			sample := calibration.Sample{Score: result.CombinedScore, Relevant: isRelevantResult(result, query.Relevant)}
+			if sample.Relevant {
+				relevant++
+			}
+			samples = append(samples, sample)
+		}
+	}
+
+	model, err := calibration.Fit(samples)
+	if err != nil {
+		return err
+	}
+	if model.Slope <= 0 {
+		output.Warning("Higher scores are not more likely to be relevant in these queries; check the labels or the search settings")
+	}
+
+	output.KeyValuef("Queries", "%d", len(queries))
+	output.KeyValuef("Results", "%d (%d relevant)", len(samples), relevant)
+	output.KeyValue("Search type", string(s.opts.SearchType))
+	output.KeyValuef("Slope", "%.4f", model.Slope)
+	output.KeyValuef("Intercept", "%.4f", model.Intercept)
+	output.KeyValuef("Log loss", "%.4f (0.693 is a coin toss)", calibration.LogLoss(model, samples))
+	output.Info("")
+	output.Bold("Confidence by combined score:")
+	for _, score := range []float64{0.1, 0.3, 0.5, 0.7, 0.9} {
+		output.Info("  %.1f  %3.0f%%", score, 100*model.Confidence(score))
+	}
+
+	if err := saveCalibration(string(s.opts.SearchType), model); err != nil {
+		return err
+	}
+	output.Success("Calibration saved; search and chat show confidences for %s search", s.opts.SearchType)
+	return nil
+}
+
+// isRelevantResult reports whether a result is one of the relevant files,
+// given by their path in the collection folder, local path, file name or
+// document ID
+func isRelevantResult(result *database.SearchResult, relevant []string) bool {
+	doc := result.Document
+	for _, r := range relevant {
+		if r == doc.FilePath || r == doc.FileName || r == doc.ID || r == localPath(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveCalibration saves a fitted calibration to the configuration file,
+// keeping the configured minimum confidence
+func saveCalibration(searchType string, model calibration.Model) error {
+	configFile, err := config.ConfigFilePath(configName)
+	if err != nil {
+		return err
+	}
+
+	fileConfig, err := config.LoadConfig(configName)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	fileConfig.Calibration.SearchType = searchType
+	fileConfig.Calibration.Slope = model.Slope
+	fileConfig.Calibration.Intercept = model.Intercept
+
+	if err := config.SaveConfig(fileConfig, configFile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	cfg.Calibration = fileConfig.Calibration
+	return nil
+}
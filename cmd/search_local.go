@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/localsearch"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// localSearchLines is the number of matching lines shown for each file found
+// by a local search
+const localSearchLines = 3
+
+// localSearchResult is the result of a local search as printed in JSON mode
+type localSearchResult struct {
+	Query   string             `json:"query"`
+	Folders []string           `json:"folders"`
+	Files   []*localsearch.Hit `json:"files"`
+}
+
+// collectionCache returns the cache of collection folders that local searches
+// read when the database is unreachable
+func collectionCache() *localsearch.FolderCache {
+	return localsearch.NewFolderCache(filepath.Join(cfg.General.GetDataDir(), "collections.json"))
+}
+
+// rememberCollection caches the folders of a collection for local searches.
+// The cache is best effort: failing to write it never fails a command.
+func rememberCollection(collection *database.Collection) {
+	collectionCache().Remember(localsearch.CachedCollection{
+		ID:      collection.ID,
+		Name:    collection.Name,
+		Folders: collection.Folders,
+	})
+}
+
+// runLocalSearch searches the files of a folder, or of a collection's folders
+// as remembered from earlier searches and index runs, for the words of a
+// query without the database
+func runLocalSearch(cmd *cobra.Command, ref, query string) error {
+	limit, _ := cmd.Flags().GetInt("limit")
+	fileFilter, _ := cmd.Flags().GetString("file-filter")
+	fileTypes, _ := cmd.Flags().GetStringSlice("file-types")
+	types := make(map[string]bool)
+	for _, fileType := range database.ParseFileTypes(fileTypes) {
+		types[fileType] = true
+	}
+
+	folders, err := localSearchFolders(ref)
+	if err != nil {
+		return err
+	}
+	terms := localsearch.Terms(query)
+	if len(terms) == 0 {
+		return fmt.Errorf("query %q has no words to search for", query)
+	}
+
+	files, err := collectionDiskFiles(&database.Collection{Folders: folders}, nil)
+	if err != nil {
+		return err
+	}
+
+	hits := []*localsearch.Hit{}
+	for _, file := range files {
+		if len(types) > 0 && !types[database.FileType(file.Path)] {
+			continue
+		}
+		if fileFilter != "" {
+			if matched, _ := filepath.Match(fileFilter, filepath.Base(file.Path)); !matched {
+				continue
+			}
+		}
+		hit, err := localsearch.SearchFile(file.Path, terms, localSearchLines)
+		if err != nil {
+			output.Warning("%v", err)
+			continue
+		}
+		if hit != nil {
+			hits = append(hits, hit)
+		}
+	}
+	localsearch.Rank(hits)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return output.Result(&localSearchResult{Query: query, Folders: folders, Files: hits}, func() {
+		output.KeyValue("Query", query)
+		output.KeyValue("Search type", "local (file contents, without the database)")
+		output.KeyValuef("Folders", "%v", folders)
+		if len(hits) == 0 {
+			output.Info("No files found.")
+			return
+		}
+
+		output.Success("Found %d files:", len(hits))
+		output.Info("")
+		for _, hit := range hits {
+			output.Bold("%s", hit.Path)
+			output.KeyValuef("Matches", "%d lines, %d of %d words", hit.Matches, hit.Terms, len(terms))
+			for _, line := range hit.Lines {
+				output.Info("  %d: %s", line.Number, line.Text)
+			}
+			output.Info("")
+		}
+	})
+}
+
+// localSearchFolders returns the folders a local search searches: those of
+// the collection ref names when it is cached, ref itself when it is a folder,
+// and otherwise those of the collection in the database
+func localSearchFolders(ref string) ([]string, error) {
+	cached, ok, err := collectionCache().Lookup(ref)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return cached.Folders, nil
+	}
+
+	if info, err := os.Stat(ref); err == nil && info.IsDir() {
+		folder, err := database.NormalizePath(ref)
+		if err != nil {
+			return nil, err
+		}
+		return []string{folder}, nil
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return nil, fmt.Errorf("collection '%s' isn't cached and the database is unreachable; pass its folder instead: %w", ref, err)
+	}
+	collection, err := resolveCollection(database.NewCollectionManager(db), ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	rememberCollection(collection)
+	return collection.Folders, nil
+}
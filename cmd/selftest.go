@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// selftestCorpus is the built-in corpus indexed by selftest, by file name
+var selftestCorpus = map[string]string{
+	"backups.md": `# Database backups
+
+The production database is backed up every night at 02:00 UTC. Backups are
+written to the archive bucket and kept for 30 days, after which they are
+deleted automatically. Restoring a backup takes about twenty minutes.`,
+	"onboarding.md": `# Onboarding
+
+New employees receive their laptop on the first day. The first week is spent
+pairing with a buddy from the team and reading the architecture overview.`,
+	"deploys.md": `# Deploying
+
+Releases are deployed with make release. A release first goes to a canary
+that serves ten percent of the traffic for one hour before it is rolled out
+to every region.`,
+}
+
+const (
+	selftestQuery    = "How long are database backups kept?"
+	selftestExpected = "backups.md" // File that should rank first for the query
+	selftestAnswer   = "30"         // Fact the chat answer should mention
+)
+
+var selftestCmd = &cobra.Command{
+	Use:         "selftest",
+	Short:       "Check that indexing, search and chat work end to end",
+	Annotations: requires(config.RequireDatabase | config.RequireChat | config.RequireEmbedding),
+	Long: `Check that an installation works, from the database to the chat model.
+
+Selftest writes a tiny built-in corpus to a temporary folder, indexes it into
+a temporary collection, searches it for a question whose answer is in one of
+the files, and asks the chat model the same question with the search results
+as context. The command fails if any step fails, or if the search doesn't rank
+the expected file first. An answer that doesn't mention the expected fact is
+reported as a warning, since models word their answers differently.
+
+The temporary collection and folder are removed when the test ends, unless
+--keep is given.
+
+Examples:
+  # Check the installation
+  rag-cli selftest
+
+  # Keep the test collection to inspect it
+  rag-cli selftest --keep
+
+  # Print the result of every step as JSON
+  rag-cli selftest --output json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keep, _ := cmd.Flags().GetBool("keep")
+		ctx := context.Background()
+
+		test := &selftest{}
+		err := test.run(ctx, keep)
+
+		if output.IsJSON() {
+			if jsonErr := output.JSON(test); jsonErr != nil {
+				return jsonErr
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("selftest failed: %w", err)
+		}
+		output.Success("Selftest passed")
+		return nil
+	},
+}
+
+// selftest is the outcome of a selftest run
+type selftest struct {
+	Collection string         `json:"collection"`
+	Steps      []selftestStep `json:"steps"`
+	Passed     bool           `json:"passed"`
+}
+
+// selftestStep is the outcome of one step of a selftest run
+type selftestStep struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Detail   string `json:"detail"`
+	Duration string `json:"duration"`
+}
+
+// step runs a step of the test and records its outcome. The step returns a
+// detail to print when it passes.
+func (t *selftest) step(name string, run func() (string, error)) error {
+	start := time.Now()
+	detail, err := run()
+	duration := time.Since(start).Round(time.Millisecond)
+
+	step := selftestStep{Name: name, Passed: err == nil, Detail: detail, Duration: duration.String()}
+	if err != nil {
+		step.Detail = err.Error()
+		output.Error("%s: %v", name, err)
+	} else {
+		output.KeyValuef(name, "%s (%s)", detail, duration)
+	}
+	t.Steps = append(t.Steps, step)
+	return err
+}
+
+// run runs every step of the test, stopping at the first that fails, and
+// removes the test collection and folder unless keep is set
+func (t *selftest) run(ctx context.Context, keep bool) error {
+	output.Bold("Running selftest...")
+
+	var db *selftestDatabase
+	if err := t.step("Database", func() (string, error) {
+		var err error
+		db, err = openSelftestDatabase(ctx)
+		if err != nil {
+			return "", err
+		}
+		return "reachable and migrated", nil
+	}); err != nil {
+		return err
+	}
+
+	folder, err := os.MkdirTemp("", "rag-cli-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary folder: %w", err)
+	}
+	var collection *database.Collection
+	defer func() {
+		if keep && collection != nil {
+			output.Info("Kept collection %s and folder %s", t.Collection, folder)
+			return
+		}
+		if collection != nil {
+			if err := db.collectionMgr.DeleteCollection(collection.ID); err != nil {
+				output.Warning("Failed to delete collection %s: %v", collection.Name, err)
+			}
+		}
+		if err := os.RemoveAll(folder); err != nil {
+			output.Warning("Failed to remove folder %s: %v", folder, err)
+		}
+	}()
+
+	if err := t.step("Collection", func() (string, error) {
+		for name, content := range selftestCorpus {
+			if err := os.WriteFile(filepath.Join(folder, name), []byte(content), 0644); err != nil {
+				return "", fmt.Errorf("failed to write %s: %w", name, err)
+			}
+		}
+		name := fmt.Sprintf("rag-cli-selftest-%d", time.Now().Unix())
+		var err error
+		collection, err = db.collectionMgr.CreateCollection(name, "Temporary collection created by rag-cli selftest", []string{folder})
+		if err != nil {
+			return "", fmt.Errorf("failed to create collection: %w", err)
+		}
+		t.Collection = collection.Name
+		return fmt.Sprintf("%s created with %d files", collection.Name, len(selftestCorpus)), nil
+	}); err != nil {
+		return err
+	}
+
+	var embeddingService *embedding.Service
+	if err := t.step("Index", func() (string, error) {
+		embedder, err := backends.Embedder()
+		if err != nil {
+			return "", err
+		}
+		embeddingService = embedding.New(embedder, &cfg.Embedding)
+		setChunkTokenizer(embeddingService)
+
+		counts, err := processFolder(folder, folder, collection.ID, db.documentMgr, db.fileStateMgr, embeddingService, nil, nil, true, cfg.Embedding.GetConcurrency(), nil, nil, nil)
+		if err != nil {
+			return "", err
+		}
+		if len(counts.failed) > 0 {
+			return "", fmt.Errorf("failed to index %s: %w", counts.failed[0].path, counts.failed[0].err)
+		}
+		if counts.files != len(selftestCorpus) {
+			return "", fmt.Errorf("expected %d files to be indexed, got %d", len(selftestCorpus), counts.files)
+		}
+		if err := db.collectionMgr.UpdateCollectionStats(collection.ID); err != nil {
+			return "", fmt.Errorf("failed to update collection stats: %w", err)
+		}
+		return fmt.Sprintf("%d files, %d chunks", counts.files, counts.chunks), nil
+	}); err != nil {
+		return err
+	}
+
+	var results []*database.SearchResult
+	if err := t.step("Search", func() (string, error) {
+		queryEmbedding, err := embeddingService.GenerateEmbeddingForText(ctx, selftestQuery)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate query embedding: %w", err)
+		}
+		opts := &database.SearchOptions{
+			SearchType:   database.SearchTypeHybrid,
+			VectorWeight: defaultVectorWeight,
+			TextWeight:   defaultTextWeight,
+			MaxDistance:  defaultMaxDistance,
+		}
+		results, err = database.NewSearchEngine(db.db).SearchDocumentsWithOptions(collection.ID, queryEmbedding, selftestQuery, 3, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to search: %w", err)
+		}
+		if len(results) == 0 {
+			return "", fmt.Errorf("no results for %q", selftestQuery)
+		}
+		if top := results[0].Document.FileName; top != selftestExpected {
+			return "", fmt.Errorf("expected %s to rank first for %q, got %s", selftestExpected, selftestQuery, top)
+		}
+		return fmt.Sprintf("%s ranked first (score %.3f)", selftestExpected, results[0].CombinedScore), nil
+	}); err != nil {
+		return err
+	}
+
+	if err := t.step("Chat", func() (string, error) {
+		chatClient, err := backends.Chat()
+		if err != nil {
+			return "", err
+		}
+
+		documents := make([]*database.Document, len(results))
+		for i, result := range results {
+			documents[i] = result.Document
+		}
+		session := &chatSession{}
+		messages := []client.Message{
+			{Role: "system", Content: session.buildSystemMessage(buildContextFromDocuments(documents))},
+			{Role: "user", Content: selftestQuery},
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, chatTimeout)
+		defer cancel()
+		response, err := chatClient.Chat(ctx, "", messages, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to get response: %w", err)
+		}
+		answer := strings.TrimSpace(response.Message.Content)
+		if answer == "" {
+			return "", fmt.Errorf("the chat model returned an empty answer")
+		}
+		if !strings.Contains(answer, selftestAnswer) {
+			output.Warning("The answer doesn't mention %q: %s", selftestAnswer, answer)
+		}
+		return fmt.Sprintf("answered in %d characters", len(answer)), nil
+	}); err != nil {
+		return err
+	}
+
+	t.Passed = true
+	return nil
+}
+
+// selftestDatabase is the database and managers used by selftest
+type selftestDatabase struct {
+	db            *sql.DB
+	collectionMgr database.CollectionManager
+	documentMgr   database.DocumentManager
+	fileStateMgr  database.FileStateManager
+}
+
+// openSelftestDatabase connects to the database, applies pending migrations
+// and checks that it answers
+func openSelftestDatabase(ctx context.Context) (*selftestDatabase, error) {
+	db, err := openMigratedDatabase()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return &selftestDatabase{
+		db:            db,
+		collectionMgr: database.NewCollectionManager(db),
+		documentMgr:   database.NewDocumentManager(db),
+		fileStateMgr:  database.NewFileStateManager(db),
+	}, nil
+}
+
+func init() {
+	selftestCmd.Flags().Bool("keep", false, "Keep the test collection and folder instead of removing them")
+
+	rootCmd.AddCommand(selftestCmd)
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/busybytelab.com/rag-cli/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+// sessionEvictionInterval is how often expired chat sessions are deleted
+const sessionEvictionInterval = time.Minute
+
+var serveCmd = &cobra.Command{
+	Use:         "serve",
+	Short:       "Run rag-cli as an HTTP server",
+	Annotations: requires(config.RequireDatabase),
+	Long: `Run rag-cli as a long-running HTTP server.
+
+The server answers health checks for process supervisors and load balancers:
+  GET /healthz  the process is up
+  GET /readyz   the server can handle requests (the database is reachable
+                and it is not shutting down)
+
+Chat sessions let any number of clients chat with collections at once.
+Each session's conversation is stored in the database, so sessions survive
+restarts, and sessions idle for longer than the session TTL are deleted:
+  POST   /v1/sessions               start a session: {"collection": "my-docs"}
+                                    with optional model, system_prompt,
+                                    search_type, limit and rerank
+  GET    /v1/sessions/{id}          the session and its conversation
+  POST   /v1/sessions/{id}/messages ask a question: {"content": "..."}
+  DELETE /v1/sessions/{id}          end the session
+
+Messages are answered with the same retrieval and prompts as chat. Clients
+sending "Accept: text/event-stream" (or ?stream=true) receive the answer as
+server-sent events while it is generated: "chunk" events with pieces of the
+answer followed by a "done" event with the stored message.
+
+On SIGINT or SIGTERM the server reports not ready, stops accepting new
+connections and waits up to the shutdown timeout for in-flight requests.
+
+The listen address, TLS certificate, shutdown timeout and session TTL are
+read from the server section of the configuration and can be overridden
+with flags.
+
+Examples:
+  # Serve on the configured address (default 127.0.0.1:8080)
+  rag-cli serve
+
+  # Serve on all interfaces over HTTPS
+  rag-cli serve --listen :8443 --tls-cert server.crt --tls-key server.key
+
+  # Start a session and stream an answer
+  curl -s localhost:8080/v1/sessions -d '{"collection": "my-docs"}'
+  curl -N localhost:8080/v1/sessions/<id>/messages?stream=true -d '{"content": "How do I install it?"}'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serverConfig := cfg.Server
+		if cmd.Flags().Changed("listen") {
+			serverConfig.Listen, _ = cmd.Flags().GetString("listen")
+		}
+		if cmd.Flags().Changed("tls-cert") {
+			serverConfig.TLSCert, _ = cmd.Flags().GetString("tls-cert")
+		}
+		if cmd.Flags().Changed("tls-key") {
+			serverConfig.TLSKey, _ = cmd.Flags().GetString("tls-key")
+		}
+		if cmd.Flags().Changed("shutdown-timeout") {
+			serverConfig.ShutdownTimeout, _ = cmd.Flags().GetString("shutdown-timeout")
+		}
+		if cmd.Flags().Changed("session-ttl") {
+			serverConfig.SessionTTL, _ = cmd.Flags().GetString("session-ttl")
+		}
+		if err := serverConfig.Validate(); err != nil {
+			return fmt.Errorf("server configuration error: %w", err)
+		}
+
+		srv := server.New(&serverConfig)
+		srv.AddReadinessCheck("database", func(ctx context.Context) error {
+			db, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			return db.PingContext(ctx)
+		})
+
+		// Chat sessions are stored in the database, so it needs the latest schema
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+		collectionMgr := database.NewCollectionManager(db)
+		sessions := server.NewSessionAPI(
+			database.NewChatSessionManager(db),
+			newSessionResponder(db, collectionMgr),
+			collectionMgr.GetCollectionByIdOrName,
+			serverConfig.GetSessionTTL(),
+		)
+		sessions.Register(srv)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if serverConfig.GetSessionTTL() > 0 {
+			go sessions.RunEviction(ctx, sessionEvictionInterval, func(err error) {
+				output.Warning("Failed to delete expired chat sessions: %v", err)
+			})
+		}
+
+		scheme := "http"
+		if serverConfig.TLSEnabled() {
+			scheme = "https"
+		}
+		output.Info("Listening on %s://%s", scheme, serverConfig.GetListen())
+
+		if err := srv.Run(ctx); err != nil {
+			return err
+		}
+		output.Info("Server stopped")
+		return nil
+	},
+}
+
+// sessionResponder answers the messages of chat sessions served by the API
+// with the same retrieval and prompts as the chat command
+type sessionResponder struct {
+	db               *sql.DB
+	collectionMgr    database.CollectionManager
+	embeddingService *embedding.Service
+}
+
+// newSessionResponder creates a responder for chat session messages
+func newSessionResponder(db *sql.DB, collectionMgr database.CollectionManager) *sessionResponder {
+	return &sessionResponder{
+		db:               db,
+		collectionMgr:    collectionMgr,
+		embeddingService: embedding.New(backends.LazyEmbedder(), &cfg.Embedding),
+	}
+}
+
+// Respond answers a message using the session's settings and conversation
+func (r *sessionResponder) Respond(ctx context.Context, session *database.ChatSession, history []client.Message, input string, onChunk client.ChunkHandler) (string, error) {
+	chat, err := newServiceChatSession(r.db, r.collectionMgr, r.embeddingService, session.CollectionID, session.Settings, history)
+	if err != nil {
+		return "", err
+	}
+	return chat.answer(ctx, input, onChunk)
+}
+
+func init() {
+	serveCmd.Flags().String("listen", "", "Address to listen on (default from config, 127.0.0.1:8080)")
+	serveCmd.Flags().String("tls-cert", "", "TLS certificate file, enables HTTPS together with --tls-key")
+	serveCmd.Flags().String("tls-key", "", "TLS private key file")
+	serveCmd.Flags().String("shutdown-timeout", "", "How long in-flight requests may take to finish on shutdown (default from config, 30s)")
+	serveCmd.Flags().String("session-ttl", "", "How long an idle chat session is kept, 0 to keep sessions forever (default from config, 1h)")
+
+	rootCmd.AddCommand(serveCmd)
+}
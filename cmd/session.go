@@ -0,0 +1,362 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// storedChat is the stored session a chat continues, or is started as
+type storedChat struct {
+	store        database.ChatSessionManager
+	session      *database.ChatSession // Nil until a new session is created
+	name         string
+	collectionID string // Collection of the chat: given, or the one the session is bound to
+}
+
+// sessionTranscript is a stored session with its conversation, as printed
+// by session show in JSON mode
+type sessionTranscript struct {
+	*database.ChatSession
+	Messages []*database.ChatMessage `json:"messages"`
+}
+
+var sessionCmd = &cobra.Command{
+	Use:         "session",
+	Short:       "Manage stored chat sessions",
+	Annotations: requires(config.RequireDatabase),
+	Long: `Manage the chat sessions stored with 'rag-cli chat --session <name>'.
+
+A stored session keeps its whole conversation and is bound to the collection
+it was started with, so it can be resumed after rag-cli exits with
+'rag-cli chat --session <name>'. Sessions of the serve API are listed too.
+
+Examples:
+  # Start or resume a session
+  rag-cli chat my-docs --session design-review
+  rag-cli chat --session design-review
+
+  # List the sessions
+  rag-cli session list
+
+  # Show a session's conversation
+  rag-cli session show design-review
+
+  # Export a session with the sources of its answers
+  rag-cli session export design-review --format md
+
+  # Delete a session
+  rag-cli session delete design-review --force`,
+}
+
+var listSessionsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the stored chat sessions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		sessions, err := database.NewChatSessionManager(db).ListSessions()
+		if err != nil {
+			return err
+		}
+		if output.IsJSON() {
+			if sessions == nil {
+				sessions = []*database.ChatSessionSummary{}
+			}
+			return output.JSON(sessions)
+		}
+		if len(sessions) == 0 {
+			output.Info("No chat sessions found. Start one with 'rag-cli chat <collection> --session <name>'")
+			return nil
+		}
+
+		output.Bold("Chat sessions:")
+		for _, session := range sessions {
+			output.Info("")
+			output.KeyValue("Name", sessionName(&session.ChatSession))
+			output.KeyValue("Collection", session.CollectionName)
+			output.KeyValuef("Messages", "%d", session.Messages)
+			if session.Settings.Model != "" {
+				output.KeyValue("Model", session.Settings.Model)
+			}
+			output.KeyValue("Updated", session.UpdatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	},
+}
+
+var showSessionCmd = &cobra.Command{
+	Use:   "show <session-name-or-id>",
+	Short: "Show a stored chat session and its conversation",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		store := database.NewChatSessionManager(db)
+		session, err := getStoredSession(store, args[0])
+		if err != nil {
+			return err
+		}
+		messages, err := store.ListMessages(session.ID)
+		if err != nil {
+			return err
+		}
+		if output.IsJSON() {
+			if messages == nil {
+				messages = []*database.ChatMessage{}
+			}
+			return output.JSON(sessionTranscript{ChatSession: session, Messages: messages})
+		}
+
+		collection := session.CollectionID
+		if c, err := database.NewCollectionManager(db).GetCollection(session.CollectionID); err == nil {
+			collection = c.Name
+		}
+		output.KeyValue("Name", sessionName(session))
+		output.KeyValue("ID", session.ID)
+		output.KeyValue("Collection", collection)
+		if session.Settings.Model != "" {
+			output.KeyValue("Model", session.Settings.Model)
+		}
+		if session.Settings.SearchType != "" {
+			output.KeyValue("Search Type", string(session.Settings.SearchType))
+		}
+		if session.Settings.Limit > 0 {
+			output.KeyValuef("Limit", "%d", session.Settings.Limit)
+		}
+		if rerank := session.Settings.Rerank; rerank != nil && *rerank {
+			output.KeyValue("Reranking", "Enabled")
+		} else if rerank != nil {
+			output.KeyValue("Reranking", "Disabled")
+		}
+		if session.Settings.SystemPrompt != "" {
+			output.KeyValue("System Prompt", session.Settings.SystemPrompt)
+		}
+		output.KeyValue("Created", session.CreatedAt.Format("2006-01-02 15:04:05"))
+		output.KeyValue("Updated", session.UpdatedAt.Format("2006-01-02 15:04:05"))
+
+		output.Info("")
+		if len(messages) == 0 {
+			output.Info("No messages yet.")
+		}
+		for _, message := range messages {
+			if message.Role == "user" {
+				output.Info("You: %s", message.Content)
+			} else {
+				output.Info("Assistant: %s", message.Content)
+				output.Info("")
+			}
+		}
+		return nil
+	},
+}
+
+var deleteSessionCmd = &cobra.Command{
+	Use:   "delete <session-name-or-id>",
+	Short: "Delete a stored chat session",
+	Long: `Delete a stored chat session and its conversation.
+
+Examples:
+  # Delete a session (will ask to confirm with --force)
+  rag-cli session delete design-review
+
+  # Delete without confirmation
+  rag-cli session delete design-review --force`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		if !force {
+			output.Warning("This will delete the session and its conversation.")
+			output.Info("Use --force to confirm.")
+			return nil
+		}
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		store := database.NewChatSessionManager(db)
+		session, err := getStoredSession(store, args[0])
+		if err != nil {
+			return err
+		}
+		if err := store.DeleteSession(session.ID); err != nil {
+			return err
+		}
+
+		output.Success("Deleted chat session %s", sessionName(session))
+		return nil
+	},
+}
+
+// getStoredSession returns a stored session by its name or ID
+func getStoredSession(store database.ChatSessionManager, nameOrID string) (*database.ChatSession, error) {
+	session, err := store.GetSessionByIdOrName(nameOrID)
+	if errors.Is(err, database.ErrChatSessionNotFound) {
+		return nil, fmt.Errorf("chat session %s not found", nameOrID)
+	}
+	return session, err
+}
+
+// sessionName returns the name of a session, or its ID when it has none
+func sessionName(session *database.ChatSession) string {
+	if session.Name != "" {
+		return session.Name
+	}
+	return session.ID
+}
+
+// openStoredChat looks up the session a chat with --session continues. A
+// session that doesn't exist yet is created once the chat starts, which
+// needs a collection. A session that exists keeps its collection, and its
+// settings are used for the flags that aren't given.
+func openStoredChat(cmd *cobra.Command, name, collectionIDOrName string) (*storedChat, error) {
+	db, err := openMigratedDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	store := database.NewChatSessionManager(db)
+	session, err := store.GetSessionByIdOrName(name)
+	if errors.Is(err, database.ErrChatSessionNotFound) {
+		if collectionIDOrName == "" {
+			return nil, fmt.Errorf("chat session %s not found; give a collection to start it", name)
+		}
+		// An expired session of the serve API may still hold the name
+		if _, err := store.DeleteExpiredSessions(); err != nil {
+			return nil, err
+		}
+		return &storedChat{store: store, name: name, collectionID: collectionIDOrName}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if collectionIDOrName != "" {
+		collection, err := resolveCollection(database.NewCollectionManager(db), collectionIDOrName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get collection: %w", err)
+		}
+		if collection.ID != session.CollectionID {
+			return nil, fmt.Errorf("chat session %s is with another collection; leave out the collection to resume it", name)
+		}
+	}
+	if err := applySessionSettings(cmd, session.Settings); err != nil {
+		return nil, err
+	}
+
+	return &storedChat{store: store, session: session, name: name, collectionID: session.CollectionID}, nil
+}
+
+// applySessionSettings sets the chat flags that aren't given to the settings
+// a session was started with
+func applySessionSettings(cmd *cobra.Command, settings database.ChatSessionSettings) error {
+	values := map[string]string{
+		"model":       settings.Model,
+		"system":      settings.SystemPrompt,
+		"search-type": string(settings.SearchType),
+	}
+	if settings.Limit > 0 {
+		values["limit"] = strconv.Itoa(settings.Limit)
+	}
+	if settings.Rerank != nil {
+		values["rerank"] = strconv.FormatBool(*settings.Rerank)
+	}
+
+	for flag, value := range values {
+		if value == "" || cmd.Flags().Changed(flag) {
+			continue
+		}
+		if err := cmd.Flags().Set(flag, value); err != nil {
+			return fmt.Errorf("failed to apply session setting %s: %w", flag, err)
+		}
+	}
+	return nil
+}
+
+// attachStoredChat binds a chat to its stored session: a new session is
+// created with the chat's settings, and an existing one's conversation is
+// loaded so the chat continues it
+func (s *chatSession) attachStoredChat(stored *storedChat) error {
+	if stored.session == nil {
+		rerank := s.rerank
+		session := &database.ChatSession{
+			Name:         stored.name,
+			CollectionID: s.collectionID,
+			Settings: database.ChatSessionSettings{
+				Model:        s.chatModel,
+				SystemPrompt: s.systemPrompt,
+				SearchType:   s.searchType,
+				Limit:        s.limit,
+				Rerank:       &rerank,
+			},
+		}
+		if err := stored.store.CreateSession(session); err != nil {
+			return err
+		}
+		stored.session = session
+		s.stored = stored
+		return nil
+	}
+
+	messages, err := stored.store.ListMessages(stored.session.ID)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		s.conversation = append(s.conversation, client.Message{Role: message.Role, Content: message.Content})
+	}
+	s.transcript = messages
+	s.stored = stored
+	return nil
+}
+
+// recordTurn adds a question and its answer to the transcript of the chat,
+// and stores them in its stored session if it has one
+func (s *chatSession) recordTurn(reply *chatAnswer) {
+	now := time.Now()
+	s.transcript = append(s.transcript,
+		&database.ChatMessage{Role: "user", Content: reply.Question, CreatedAt: now},
+		&database.ChatMessage{Role: "assistant", Content: reply.Answer, Sources: reply.Citations, CreatedAt: now},
+	)
+	if s.stored == nil {
+		return
+	}
+
+	session := s.stored.session
+	if _, err := s.stored.store.AddMessage(session.ID, "user", reply.Question); err != nil {
+		output.Warning("Failed to save the question to session %s: %v", s.stored.name, err)
+		return
+	}
+	if _, err := s.stored.store.AddMessageWithSources(session.ID, "assistant", reply.Answer, reply.Citations); err != nil {
+		output.Warning("Failed to save the answer to session %s: %v", s.stored.name, err)
+		return
+	}
+	if err := s.stored.store.TouchSession(session.ID, session.ExpiresAt); err != nil {
+		output.Warning("Failed to update session %s: %v", s.stored.name, err)
+	}
+}
+
+func init() {
+	deleteSessionCmd.Flags().BoolP("force", "f", false, "Force deletion without confirmation")
+
+	sessionCmd.AddCommand(listSessionsCmd)
+	sessionCmd.AddCommand(showSessionCmd)
+	sessionCmd.AddCommand(deleteSessionCmd)
+	rootCmd.AddCommand(sessionCmd)
+}
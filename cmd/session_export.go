@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+// Conversation export formats
+const (
+	conversationFormatMarkdown = "md"
+	conversationFormatJSON     = "json"
+)
+
+// conversationExport is a conversation as written by session export and
+// /save
+type conversationExport struct {
+	Session    string                  `json:"session,omitempty"` // Name or ID of the stored session, if any
+	Collection string                  `json:"collection"`
+	Model      string                  `json:"model,omitempty"`
+	ExportedAt time.Time               `json:"exported_at"`
+	Messages   []*database.ChatMessage `json:"messages"`
+}
+
+// conversationExportSummary is the summary of a conversation export
+type conversationExportSummary struct {
+	File     string `json:"file"`
+	Format   string `json:"format"`
+	Messages int    `json:"messages"`
+}
+
+var exportSessionCmd = &cobra.Command{
+	Use:   "export <session-name-or-id>",
+	Short: "Export a stored chat session to Markdown or JSON",
+	Long: `Export the whole conversation of a stored chat session to a file, for
+sharing or auditing. Each answer is followed by the documents it was based
+on, with their file path, chunk and score, and whether the answer cites them.
+Answers stored before sources were kept are exported without them.
+
+In an interactive chat, /save [file] exports the conversation so far in the
+same way.
+
+Examples:
+  # Export a session to design-review.md
+  rag-cli session export design-review
+
+  # Export it as JSON to a file of your choice
+  rag-cli session export design-review --format json -o review.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+		if format != conversationFormatMarkdown && format != conversationFormatJSON {
+			return fmt.Errorf("invalid format %q: use %s or %s", format, conversationFormatMarkdown, conversationFormatJSON)
+		}
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+
+		store := database.NewChatSessionManager(db)
+		session, err := getStoredSession(store, args[0])
+		if err != nil {
+			return err
+		}
+		messages, err := store.ListMessages(session.ID)
+		if err != nil {
+			return err
+		}
+
+		collection := session.CollectionID
+		if c, err := database.NewCollectionManager(db).GetCollection(session.CollectionID); err == nil {
+			collection = c.Name
+		}
+		if out == "" {
+			out = sessionName(session) + "." + format
+		}
+
+		conversation := &conversationExport{
+			Session:    sessionName(session),
+			Collection: collection,
+			Model:      session.Settings.Model,
+			ExportedAt: time.Now(),
+			Messages:   messages,
+		}
+		if err := writeConversation(out, format, conversation); err != nil {
+			return err
+		}
+
+		summary := conversationExportSummary{File: out, Format: format, Messages: len(messages)}
+		return output.Result(summary, func() {
+			output.Success("Exported chat session %s to %s", sessionName(session), out)
+			output.KeyValuef("Messages", "%d", summary.Messages)
+		})
+	},
+}
+
+// save writes the conversation of the chat so far to a file: Markdown,
+// unless the file name ends in .json
+func (s *chatSession) save(args []string) error {
+	if len(s.transcript) == 0 {
+		return fmt.Errorf("nothing to save yet; ask a question first")
+	}
+
+	conversation := &conversationExport{
+		Collection: s.collectionName,
+		Model:      s.chatModel,
+		ExportedAt: time.Now(),
+		Messages:   s.transcript,
+	}
+	name := s.collectionName + "-chat"
+	if s.stored != nil {
+		conversation.Session = s.stored.name
+		name = s.stored.name
+	}
+
+	path := name + "." + conversationFormatMarkdown
+	if len(args) > 0 {
+		path = args[0]
+	}
+	format := conversationFormatMarkdown
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = conversationFormatJSON
+	}
+
+	if err := writeConversation(path, format, conversation); err != nil {
+		return err
+	}
+	output.Success("Saved %d messages to %s", len(s.transcript), path)
+	return nil
+}
+
+// writeConversation writes a conversation to a file in a format
+func writeConversation(path, format string, conversation *conversationExport) error {
+	var content []byte
+	if format == conversationFormatJSON {
+		var err error
+		content, err = json.MarshalIndent(conversation, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal conversation: %w", err)
+		}
+		content = append(content, '\n')
+	} else {
+		content = []byte(conversationMarkdown(conversation))
+	}
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// conversationMarkdown renders a conversation as Markdown, with the sources
+// of each answer listed after it
+func conversationMarkdown(conversation *conversationExport) string {
+	var b strings.Builder
+	if conversation.Session != "" {
+		fmt.Fprintf(&b, "# Chat session %s\n\n", conversation.Session)
+	} else {
+		fmt.Fprintf(&b, "# Chat with %s\n\n", conversation.Collection)
+	}
+	fmt.Fprintf(&b, "- Collection: %s\n", conversation.Collection)
+	if conversation.Model != "" {
+		fmt.Fprintf(&b, "- Model: %s\n", conversation.Model)
+	}
+	fmt.Fprintf(&b, "- Exported: %s\n", conversation.ExportedAt.Format("2006-01-02 15:04:05"))
+
+	for _, message := range conversation.Messages {
+		role := "Assistant"
+		if message.Role == "user" {
+			role = "You"
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", role, strings.TrimSpace(message.Content))
+
+		if len(message.Sources) == 0 {
+			continue
+		}
+		b.WriteString("\nSources:\n\n")
+		for _, source := range message.Sources {
+			cited := ""
+			if source.Cited {
+				cited = ", cited"
+			}
+			fmt.Fprintf(&b, "%d. `%s` (chunk %d, score %.2f%s)\n", source.Number, source.FilePath, source.ChunkIndex, source.Score, cited)
+		}
+	}
+	return b.String()
+}
+
+func init() {
+	exportSessionCmd.Flags().String("format", conversationFormatMarkdown, "Export format ("+conversationFormatMarkdown+" or "+conversationFormatJSON+")")
+	exportSessionCmd.Flags().StringP("out", "o", "", "File to write to (default <session>.<format>)")
+	sessionCmd.AddCommand(exportSessionCmd)
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/busybytelab.com/rag-cli/pkg/telemetry"
+	"github.com/spf13/cobra"
+)
+
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Manage anonymous usage statistics",
+	Long: `Manage the anonymous usage statistics that help the maintainers decide what to
+work on. Telemetry is off unless you turn it on.
+
+When on, rag-cli counts how often each command runs, the classes of errors it
+fails with (such as network or database, never the messages) and how long it
+takes. Arguments, flags, paths, queries and document content are never
+recorded. Reports are sent at most once a day, and 'rag-cli telemetry status'
+shows the next one exactly as it will be sent. Setting DO_NOT_TRACK=1 turns
+telemetry off whatever the settings.
+
+Examples:
+  # Turn telemetry on
+  rag-cli telemetry on
+
+  # Show the settings and the next report
+  rag-cli telemetry status
+
+  # Turn telemetry off and delete the statistics kept so far
+  rag-cli telemetry off`,
+}
+
+var telemetryOnCmd = &cobra.Command{
+	Use:   "on",
+	Short: "Turn on anonymous usage statistics",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := saveTelemetryEnabled(true); err != nil {
+			return err
+		}
+		output.Success("Telemetry is on. Thank you!")
+		output.Info("See what is reported with 'rag-cli telemetry status'")
+		if telemetry.DoNotTrack() {
+			output.Warning("DO_NOT_TRACK is set, so nothing is recorded until it is unset")
+		}
+		return nil
+	},
+}
+
+var telemetryOffCmd = &cobra.Command{
+	Use:   "off",
+	Short: "Turn off anonymous usage statistics and delete them",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := saveTelemetryEnabled(false); err != nil {
+			return err
+		}
+		if err := newTelemetryRecorder().Forget(); err != nil {
+			return err
+		}
+		output.Success("Telemetry is off and the statistics kept so far are deleted")
+		return nil
+	},
+}
+
+var telemetryStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the telemetry settings and the next report",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recorder := newTelemetryRecorder()
+
+		output.KeyValuef("Enabled", "%t", cfg.Telemetry.Enabled)
+		if telemetry.DoNotTrack() {
+			output.KeyValue("DO_NOT_TRACK", "set, nothing is recorded")
+		}
+		output.KeyValue("Endpoint", valueOrDefault(recorder.Endpoint(), "(none, reports stay on this machine)"))
+		output.KeyValue("Statistics", telemetryPath())
+
+		if !cfg.Telemetry.Enabled {
+			return nil
+		}
+		store, err := recorder.Load()
+		if err != nil {
+			return err
+		}
+		if !store.LastSent.IsZero() {
+			output.KeyValue("Last report", store.LastSent.Local().Format(time.RFC1123))
+		}
+
+		payload, err := json.MarshalIndent(recorder.Payload(store), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode telemetry report: %w", err)
+		}
+		output.Info("")
+		output.Bold("Next report, exactly as it will be sent:")
+		output.Info("%s", payload)
+		return nil
+	},
+}
+
+// telemetryPath returns the file the usage statistics are kept in
+func telemetryPath() string {
+	return filepath.Join(cfg.General.GetDataDir(), "telemetry.json")
+}
+
+// newTelemetryRecorder creates the recorder of the usage statistics
+func newTelemetryRecorder() *telemetry.Recorder {
+	return telemetry.New(telemetryPath(), cfg.Telemetry.Endpoint, getVersionInfo().Version)
+}
+
+// recordTelemetry records a run of a command when telemetry is on, and sends
+// a report when one is due. Telemetry never fails a command.
+func recordTelemetry(cmd *cobra.Command, started time.Time, runErr error) {
+	if cfg == nil || cmd == nil || !cfg.Telemetry.Enabled || telemetry.DoNotTrack() {
+		return
+	}
+
+	name := strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+	recorder := newTelemetryRecorder()
+	if err := recorder.Record(name, time.Since(started), runErr); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_ = recorder.SendIfDue(ctx)
+}
+
+// saveTelemetryEnabled turns telemetry on or off in the configuration file
+func saveTelemetryEnabled(enabled bool) error {
+	configFile, err := config.ConfigFilePath(configName)
+	if err != nil {
+		return err
+	}
+
+	fileConfig, err := config.LoadConfig(configName)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	fileConfig.Telemetry.Enabled = enabled
+
+	if err := config.SaveConfig(fileConfig, configFile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	cfg.Telemetry.Enabled = enabled
+	return nil
+}
+
+func init() {
+	telemetryCmd.AddCommand(telemetryOnCmd)
+	telemetryCmd.AddCommand(telemetryOffCmd)
+	telemetryCmd.AddCommand(telemetryStatusCmd)
+	rootCmd.AddCommand(telemetryCmd)
+}
@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/busybytelab.com/rag-cli/pkg/tuning"
+	"github.com/spf13/cobra"
+)
+
+// minTuneQueries is the number of judged queries below which tune warns that
+// the ranking may not carry over to other questions
+const minTuneQueries = 10
+
+// tuneSummary is the outcome of tune, as printed in JSON mode
+type tuneSummary struct {
+	Collection string         `json:"collection"`
+	Queries    int            `json:"queries"`
+	Judgments  int            `json:"judgments"`
+	Tried      int            `json:"settings_tried"`
+	Current    tuning.Outcome `json:"current"`
+	Tuned      tuning.Outcome `json:"tuned"`
+	Saved      bool           `json:"saved"`
+}
+
+var tuneCmd = &cobra.Command{
+	Use:         "tune <collection-id-or-name>",
+	Short:       "Tune the ranking of a collection to the feedback collected for it",
+	Annotations: requires(config.RequireDatabase),
+	Long: `Tune the ranking of a collection to the relevance feedback collected for it.
+
+In an interactive chat, /good <result#> marks documents of the last answer as
+relevant to its question and /bad <result#> as not relevant. Tune searches
+every judged question again with each combination of the vector and text
+weights of hybrid search, from 0 to 1 in steps of 0.1, without and with a
+recency boost, and with --rerank also with reranking. Each combination is
+scored by the mean reciprocal rank (MRR) of the first relevant file: 1 when
+a relevant file always comes first, 0.5 when it comes second, and so on.
+
+The best combination is saved as the collection's ranking: search, chat and
+ask use its weights and reranking for the collection unless a --profile or
+the ranking flags are given, and its recency boost replaces the recency
+rules of the collection's boosts. The current ranking is kept unless
+another combination scores better.
+
+Judgments are kept until they are deleted with --forget, for example when the
+documents of the collection changed so much that they no longer apply.
+
+Examples:
+  # Tune the ranking of a collection
+  rag-cli tune my-docs-collection
+
+  # Show what tuning would change, reranking included, without saving it
+  rag-cli tune my-docs-collection --rerank --dry-run
+
+  # Go back to the default ranking
+  rag-cli tune my-docs-collection --reset
+
+  # Start collecting judgments over
+  rag-cli tune my-docs-collection --forget`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		limit, _ := cmd.Flags().GetInt("limit")
+		tryRerank, _ := cmd.Flags().GetBool("rerank")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		reset, _ := cmd.Flags().GetBool("reset")
+		forget, _ := cmd.Flags().GetBool("forget")
+		if limit < 1 {
+			return fmt.Errorf("--limit must be 1 or more")
+		}
+
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+		collectionMgr := database.NewCollectionManager(db)
+		collection, err := resolveCollection(collectionMgr, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+		feedback := database.NewFeedbackManager(db)
+		if forget {
+			forgotten, err := feedback.ForgetJudgments(collection.ID)
+			if err != nil {
+				return err
+			}
+			output.Success("Deleted %d judgments of collection %s", forgotten, collection.Name)
+		}
+		if reset {
+			return resetRanking(collectionMgr, collection)
+		}
+		if forget {
+			return nil
+		}
+
+		judgments, err := feedback.ListJudgments(collection.ID)
+		if err != nil {
+			return err
+		}
+		queries := tuning.Queries(judgments)
+		if len(queries) == 0 {
+			return fmt.Errorf("no judged questions for collection %s: mark the documents of chat answers with /good and /bad first", collection.Name)
+		}
+
+		// The current ranking is tried first, so it is kept on ties
+		ranking, err := collectionMgr.GetRanking(collection.ID)
+		if err != nil {
+			return err
+		}
+		boosts, err := collectionMgr.GetBoosts(collection.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load collection boosts: %w", err)
+		}
+		current, otherBoosts := currentRanking(ranking, boosts)
+		tryRerank = tryRerank || current.Rerank
+
+		search, err := newTuneSearch(ctx, db, collection.ID, queries, otherBoosts, limit, tryRerank)
+		if err != nil {
+			return err
+		}
+		grid := []tuning.Settings{current}
+		for _, settings := range tuning.Grid(tryRerank) {
+			if settings != current {
+				grid = append(grid, settings)
+			}
+		}
+		if !output.IsJSON() {
+			output.Info("Searching %d judged questions with %d ranking settings...", len(queries), len(grid))
+		}
+		outcomes, err := tuning.Tune(search, queries, grid)
+		if err != nil {
+			return err
+		}
+
+		summary := tuneSummary{
+			Collection: collection.Name,
+			Queries:    len(queries),
+			Judgments:  len(judgments),
+			Tried:      len(grid),
+			Tuned:      outcomes[0],
+		}
+		summary.Current = outcomes[slices.IndexFunc(outcomes, func(o tuning.Outcome) bool { return o.Settings == current })]
+
+		changed := summary.Tuned.Settings != current
+		if changed && !dryRun {
+			if err := saveRanking(collectionMgr, collection.ID, summary.Tuned, len(queries), otherBoosts); err != nil {
+				return err
+			}
+			summary.Saved = true
+		}
+
+		return output.Result(summary, func() {
+			output.KeyValue("Collection", collection.Name)
+			output.KeyValuef("Judged Questions", "%d (%d judgments)", summary.Queries, summary.Judgments)
+			output.KeyValue("Current", formatTuneOutcome(summary.Current, len(queries)))
+			output.KeyValue("Tuned", formatTuneOutcome(summary.Tuned, len(queries)))
+			if len(queries) < minTuneQueries {
+				output.Warning("Only %d judged questions; the tuned ranking may not carry over to other questions", len(queries))
+			}
+
+			switch {
+			case !changed:
+				output.Info("The current ranking scores best; nothing changed")
+			case dryRun:
+				output.Info("Dry run; nothing saved")
+			default:
+				output.Success("Saved the tuned ranking of collection %s", collection.Name)
+			}
+		})
+	},
+}
+
+// currentRanking returns the ranking settings a collection is searched with
+// by default, and its boosting rules other than recency rules, which tuning
+// replaces
+func currentRanking(ranking *database.CollectionRanking, boosts []database.BoostRule) (tuning.Settings, []database.BoostRule) {
+	current := tuning.Settings{VectorWeight: defaultVectorWeight, TextWeight: defaultTextWeight}
+	if ranking != nil {
+		current.VectorWeight, current.TextWeight, current.Rerank = ranking.VectorWeight, ranking.TextWeight, ranking.Rerank
+	}
+
+	var other []database.BoostRule
+	for _, rule := range boosts {
+		if rule.Kind != database.BoostKindRecency {
+			other = append(other, rule)
+		} else if current.Recency == "" {
+			current.Recency = rule.String()
+		}
+	}
+	return current, other
+}
+
+// newTuneSearch returns the search tune scores ranking settings with: a
+// hybrid search of the collection for the judged queries, whose embeddings
+// are generated once
+func newTuneSearch(ctx context.Context, db *sql.DB, collectionID string, queries []tuning.Query, boosts []database.BoostRule, limit int, rerank bool) (tuning.Searcher, error) {
+	embeddingService := embedding.New(backends.LazyEmbedder(), &cfg.Embedding)
+	embeddings := make(map[string][]float32, len(queries))
+	for _, query := range queries {
+		queryEmbedding, err := embeddingService.GenerateEmbeddingForText(ctx, query.Text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		}
+		embeddings[query.Text] = queryEmbedding
+	}
+
+	searchEngine := database.NewSearchEngine(db)
+	rerankEngine := searchEngine
+	if rerank {
+		reranker, err := backends.Reranker()
+		if err != nil {
+			return nil, err
+		}
+		rerankEngine = database.NewSearchEngineWithReranker(db, reranker)
+	}
+
+	return func(query tuning.Query, settings tuning.Settings) ([]*database.SearchResult, error) {
+		rules := boosts
+		if settings.Recency != "" {
+			rule, err := database.ParseBoostRule(settings.Recency)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(slices.Clone(boosts), rule)
+		}
+
+		opts := &database.SearchOptions{
+			SearchType:    database.SearchTypeHybrid,
+			VectorWeight:  settings.VectorWeight,
+			TextWeight:    settings.TextWeight,
+			MinScore:      defaultMinScore,
+			MaxDistance:   defaultMaxDistance,
+			Normalization: database.ScoreNormalization(cfg.Search.GetNormalization()),
+			PathWeight:    cfg.Search.PathWeight,
+			Boosts:        rules,
+		}
+		engine := searchEngine
+		if settings.Rerank {
+			engine = rerankEngine
+			opts.EnableReranking = true
+			opts.RerankInstruction = cfg.Rerank.Instruction
+			opts.OriginalWeight = cfg.Rerank.OriginalWeight
+			opts.RerankWeight = cfg.Rerank.RerankWeight
+			opts.RerankLimit = cfg.Rerank.Limit
+			opts.RetrieveLimit = cfg.Rerank.Retrieve
+		}
+		return engine.SearchDocumentsWithOptions(collectionID, embeddings[query.Text], query.Text, limit, opts)
+	}, nil
+}
+
+// saveRanking saves tuned settings as the ranking of a collection, with
+// their recency boost in place of the collection's recency rules
+func saveRanking(collectionMgr database.CollectionManager, collectionID string, tuned tuning.Outcome, queries int, otherBoosts []database.BoostRule) error {
+	rules := otherBoosts
+	if tuned.Settings.Recency != "" {
+		rule, err := database.ParseBoostRule(tuned.Settings.Recency)
+		if err != nil {
+			return err
+		}
+		rules = append(slices.Clone(otherBoosts), rule)
+	}
+	if err := collectionMgr.SetBoosts(collectionID, rules); err != nil {
+		return err
+	}
+
+	return collectionMgr.SetRanking(collectionID, &database.CollectionRanking{
+		VectorWeight: tuned.Settings.VectorWeight,
+		TextWeight:   tuned.Settings.TextWeight,
+		Rerank:       tuned.Settings.Rerank,
+		Recency:      tuned.Settings.Recency,
+		Queries:      queries,
+		MRR:          tuned.MRR,
+		TunedAt:      time.Now(),
+	})
+}
+
+// resetRanking removes the tuned ranking of a collection, with the recency
+// boost it added
+func resetRanking(collectionMgr database.CollectionManager, collection *database.Collection) error {
+	ranking, err := collectionMgr.GetRanking(collection.ID)
+	if err != nil {
+		return err
+	}
+	if ranking == nil {
+		output.Info("Collection %s has no tuned ranking", collection.Name)
+		return nil
+	}
+
+	if ranking.Recency != "" {
+		boosts, err := collectionMgr.GetBoosts(collection.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load collection boosts: %w", err)
+		}
+		boosts = slices.DeleteFunc(boosts, func(rule database.BoostRule) bool { return rule.String() == ranking.Recency })
+		if err := collectionMgr.SetBoosts(collection.ID, boosts); err != nil {
+			return err
+		}
+	}
+	if err := collectionMgr.SetRanking(collection.ID, nil); err != nil {
+		return err
+	}
+	output.Success("Removed the tuned ranking of collection %s", collection.Name)
+	return nil
+}
+
+// formatTuneOutcome describes ranking settings and how well they rank the
+// judged queries
+func formatTuneOutcome(outcome tuning.Outcome, queries int) string {
+	settings := []string{fmt.Sprintf("vector %.1f, text %.1f", outcome.Settings.VectorWeight, outcome.Settings.TextWeight)}
+	if outcome.Settings.Rerank {
+		settings = append(settings, "reranked")
+	}
+	if outcome.Settings.Recency != "" {
+		settings = append(settings, outcome.Settings.Recency)
+	}
+	return fmt.Sprintf("%s: MRR %.3f, a relevant file found for %d of %d", strings.Join(settings, ", "), outcome.MRR, outcome.Hits, queries)
+}
+
+// judge records whether documents of the last answer are relevant to its
+// question, for tune
+func (s *chatSession) judge(args []string, relevant bool) error {
+	if len(s.lastResults) == 0 {
+		return fmt.Errorf("no documents to judge yet; ask a question first")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("give the numbers of the documents to judge, as listed by /sources")
+	}
+	results, err := s.lastResultsByNumber(args)
+	if err != nil {
+		return err
+	}
+
+	query := s.lastQuestion
+	if s.searchQuery != "" {
+		query = s.searchQuery
+	}
+	for _, result := range results {
+		if isProvidedDocument(result.Document) {
+			output.Info("Not judged, as it isn't part of the collection: %s", formatChatSource(result))
+			continue
+		}
+		if err := s.feedback.AddJudgment(s.collectionID, query, result.Document.Folder, result.Document.FilePath, relevant); err != nil {
+			return err
+		}
+		if relevant {
+			output.Success("Marked relevant: %s", formatChatSource(result))
+		} else {
+			output.Success("Marked not relevant: %s", formatChatSource(result))
+		}
+	}
+	return nil
+}
+
+func init() {
+	tuneCmd.Flags().IntP("limit", "l", defaultChatLimit, "Number of results of each search in which the first relevant file is looked for")
+	tuneCmd.Flags().Bool("rerank", false, "Also try the settings with reranking, which needs a reranker and takes longer")
+	tuneCmd.Flags().Bool("dry-run", false, "Show the tuned ranking without saving it")
+	tuneCmd.Flags().Bool("reset", false, "Remove the tuned ranking of the collection, going back to the defaults")
+	tuneCmd.Flags().Bool("forget", false, "Delete the relevance judgments collected for the collection instead of tuning")
+	rootCmd.AddCommand(tuneCmd)
+}
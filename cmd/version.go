@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"runtime"
+	"runtime/debug"
 
 	"github.com/busybytelab.com/rag-cli/pkg/output"
 	"github.com/spf13/cobra"
@@ -10,18 +12,98 @@ import (
 var (
 	// Version is the version of the CLI tool
 	Version = "dev"
+	// Commit is the git commit the CLI tool was built from
+	Commit = ""
+	// BuildDate is when the CLI tool was built
+	BuildDate = ""
 )
 
+// versionInfo describes the build of the CLI tool and the backends it uses
+type versionInfo struct {
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit"`
+	BuildDate string            `json:"build_date"`
+	GoVersion string            `json:"go_version"`
+	Platform  string            `json:"platform"`
+	Backends  map[string]string `json:"backends,omitempty"`
+}
+
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Display the version of the CLI tool",
-	Long:  `Display the version of the RAG CLI tool.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("RAG CLI version %s\n", output.Highlight(Version))
+	Long: `Display the version of the RAG CLI tool, the git commit and date it was built
+from, the Go version and the backends it is configured with.
+
+Examples:
+  # Show the version
+  rag-cli version
+
+  # Show the version as JSON, for bug reports
+  rag-cli version --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info := getVersionInfo()
+
+		if output.IsJSON() {
+			return output.JSON(info)
+		}
+
+		fmt.Printf("RAG CLI version %s\n", output.Highlight(info.Version))
+		output.KeyValue("Commit", info.Commit)
+		output.KeyValue("Built", info.BuildDate)
+		output.KeyValue("Go", info.GoVersion)
+		output.KeyValue("Platform", info.Platform)
+		if info.Backends != nil {
+			output.KeyValue("Chat backend", info.Backends["chat"])
+			output.KeyValue("Embedding backend", info.Backends["embedding"])
+			output.KeyValue("Rerank backend", info.Backends["rerank"])
+		}
+		return nil
 	},
 }
 
+// getVersionInfo returns the build metadata set with -ldflags, falling back
+// to the version control information Go embeds in builds such as go install
+func getVersionInfo() *versionInfo {
+	info := &versionInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+				if len(info.Commit) > 12 {
+					info.Commit = info.Commit[:12]
+				}
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+		if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+	}
+	info.Commit = valueOrDefault(info.Commit, "unknown")
+	info.BuildDate = valueOrDefault(info.BuildDate, "unknown")
+
+	if cfg != nil {
+		embedding := valueOrDefault(cfg.EmbeddingBackend, cfg.ChatBackend)
+		info.Backends = map[string]string{
+			"chat":      cfg.ChatBackend,
+			"embedding": embedding,
+			"rerank":    valueOrDefault(cfg.Rerank.Backend, embedding),
+		}
+	}
+	return info
+}
+
 func init() {
+	versionCmd.Flags().Bool("json", false, "Output the version as JSON (same as --output json)")
 	rootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/busybytelab.com/rag-cli/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+var warmupCmd = &cobra.Command{
+	Use:         "warmup [collection-id-or-name]",
+	Short:       "Load models and caches before the first chat",
+	Annotations: requires(config.RequireDatabase),
+	Long: `Get a collection ready to chat with, so the first question isn't slowed down
+by loading models and reading the index from disk.
+
+Warmup checks that the database is reachable, loads the chat and embedding
+models on the Ollama server and keeps them loaded for --keep-alive, and reads
+the collection's chunks and vector index into the database's caches. When the
+pg_prewarm extension is installed, the collection's indexes are loaded into the
+buffer cache with it too.
+
+Models of OpenAI compatible backends are not loaded, since those servers keep
+them loaded themselves.
+
+Examples:
+  # Warm up before a chat session
+  rag-cli warmup my-docs
+
+  # Keep the models loaded for two hours
+  rag-cli warmup my-docs --keep-alive 2h
+
+  # Keep the models loaded until the Ollama server stops
+  rag-cli warmup my-docs --keep-alive -1s`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keepAlive, _ := cmd.Flags().GetDuration("keep-alive")
+		ctx := context.Background()
+
+		output.Bold("Warming up...")
+
+		start := time.Now()
+		db, err := openMigratedDatabase()
+		if err != nil {
+			return err
+		}
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping database: %w", err)
+		}
+		output.KeyValuef("Database", "reachable (%s)", time.Since(start).Round(time.Millisecond))
+
+		collectionMgr := database.NewCollectionManager(db)
+		collection, err := resolveCollection(collectionMgr, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get collection: %w", err)
+		}
+
+		if err := preloadModels(ctx, keepAlive); err != nil {
+			return err
+		}
+
+		start = time.Now()
+		result, err := database.WarmUpCollection(db, collection.ID)
+		if err != nil {
+			return fmt.Errorf("failed to warm up collection: %w", err)
+		}
+		output.KeyValuef("Collection", "%s, %d chunks read (%s)", collection.Name, result.Chunks, time.Since(start).Round(time.Millisecond))
+		if len(result.Prewarmed) > 0 {
+			output.KeyValue("Prewarmed", strings.Join(result.Prewarmed, ", "))
+		}
+
+		output.Success("Collection '%s' is ready", collection.Name)
+		return nil
+	},
+}
+
+// preloadModels loads the chat and embedding models of the Ollama backends
+// and keeps them loaded for keepAlive
+func preloadModels(ctx context.Context, keepAlive time.Duration) error {
+	embeddingBackend := cfg.EmbeddingBackend
+	if embeddingBackend == "" {
+		embeddingBackend = cfg.ChatBackend
+	}
+
+	if cfg.ChatBackend != "ollama" && embeddingBackend != "ollama" {
+		output.KeyValuef("Models", "not loaded (%s backend)", cfg.ChatBackend)
+		return nil
+	}
+
+	ollama, err := client.NewOllama(&cfg.Ollama)
+	if err != nil {
+		return fmt.Errorf("failed to create Ollama client: %w", err)
+	}
+	preloader, ok := ollama.(client.Preloader)
+	if !ok {
+		return fmt.Errorf("the Ollama client can't preload models")
+	}
+
+	if cfg.ChatBackend == "ollama" {
+		start := time.Now()
+		if err := preloader.PreloadChatModel(ctx, keepAlive); err != nil {
+			return err
+		}
+		output.KeyValuef("Chat model", "%s loaded (%s)", cfg.Ollama.ChatModel, time.Since(start).Round(time.Millisecond))
+	}
+	if embeddingBackend == "ollama" {
+		start := time.Now()
+		if err := preloader.PreloadEmbeddingModel(ctx, keepAlive); err != nil {
+			return err
+		}
+		output.KeyValuef("Embedding model", "%s loaded (%s)", cfg.Ollama.EmbeddingModel, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+func init() {
+	warmupCmd.Flags().Duration("keep-alive", 30*time.Minute, "How long the Ollama server keeps the models loaded (negative = until it stops)")
+
+	rootCmd.AddCommand(warmupCmd)
+}
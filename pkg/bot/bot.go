@@ -0,0 +1,123 @@
+// Package bot is the chat bot subsystem. Connectors receive questions from
+// a chat platform and post the answers back; the questions are answered
+// from the collection mapped to the channel they were asked in.
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// AnswerTimeout bounds how long answering one question may take
+const AnswerTimeout = 5 * time.Minute
+
+// Replies posted instead of an answer
+const (
+	emptyQuestionReply = "Mention me with a question about the documentation."
+	noCollectionReply  = "This channel isn't connected to a collection."
+	failedReply        = "Sorry, I couldn't answer that right now."
+)
+
+// ErrNoCollection is returned for questions from channels without a collection
+var ErrNoCollection = errors.New("no collection is configured for this channel")
+
+// Answer is the answer to a question along with the documents it is based on
+type Answer struct {
+	Text    string
+	Sources []Source
+}
+
+// Source is a document chunk an answer is based on
+type Source struct {
+	Path       string  // Path of the file within its collection folder
+	ChunkIndex int     // Chunk of the file
+	Score      float64 // Retrieval score
+}
+
+// Answerer answers questions from a collection
+type Answerer interface {
+	Answer(ctx context.Context, collection, question string) (*Answer, error)
+}
+
+// Message is a question asked on a chat platform
+type Message struct {
+	Channel string // Channel or chat the question was asked in
+	Group   string // Server or workspace of the channel, if the platform has them
+	Text    string // Question, without the mention of the bot
+}
+
+// Handler answers the questions received by a connector
+type Handler interface {
+	Handle(ctx context.Context, msg Message) (*Answer, error)
+}
+
+// Connector connects a chat platform to a handler
+type Connector interface {
+	// Name returns the name of the platform
+	Name() string
+	// Run answers questions until ctx is done
+	Run(ctx context.Context, handler Handler) error
+}
+
+// Router answers questions from the collection mapped to their channel
+type Router struct {
+	answerer Answerer
+	mapping  config.ChannelMapping
+}
+
+// NewRouter creates a handler that answers questions with the collections
+// of a channel mapping
+func NewRouter(answerer Answerer, mapping config.ChannelMapping) *Router {
+	return &Router{answerer: answerer, mapping: mapping}
+}
+
+// Handle answers a question from the collection of its channel, or of its
+// group when the channel isn't mapped
+func (r *Router) Handle(ctx context.Context, msg Message) (*Answer, error) {
+	collection := r.mapping.CollectionFor(msg.Channel, msg.Group)
+	if collection == "" {
+		return nil, ErrNoCollection
+	}
+	return r.answerer.Answer(ctx, collection, msg.Text)
+}
+
+// Reply answers a message for a connector. Questions that can't be answered
+// get a reply explaining why; the error is returned so the connector can
+// report it.
+func Reply(ctx context.Context, handler Handler, msg Message) (*Answer, error) {
+	msg.Text = strings.TrimSpace(msg.Text)
+	if msg.Text == "" {
+		return &Answer{Text: emptyQuestionReply}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, AnswerTimeout)
+	defer cancel()
+
+	answer, err := handler.Handle(ctx, msg)
+	if errors.Is(err, ErrNoCollection) {
+		return &Answer{Text: noCollectionReply}, nil
+	}
+	if err != nil {
+		return &Answer{Text: failedReply}, err
+	}
+	return answer, nil
+}
+
+// Truncate shortens text to at most limit bytes for platforms that limit
+// the length of messages, without splitting a UTF-8 character
+func Truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	const ellipsis = "…"
+	cut := limit - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + ellipsis
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAnswerer records the collection questions are answered from
+type fakeAnswerer struct {
+	collection string
+	err        error
+}
+
+func (a *fakeAnswerer) Answer(ctx context.Context, collection, question string) (*Answer, error) {
+	a.collection = collection
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &Answer{Text: "You asked: " + question}, nil
+}
+
+func TestReply(t *testing.T) {
+	answerer := &fakeAnswerer{}
+	router := NewRouter(answerer, config.ChannelMapping{Channels: map[string]string{"c1": "docs"}})
+
+	answer, err := Reply(context.Background(), router, Message{Channel: "C1", Text: "  what is it? "})
+	require.NoError(t, err)
+	assert.Equal(t, "You asked: what is it?", answer.Text)
+	assert.Equal(t, "docs", answerer.collection, "channels are matched case-insensitively")
+
+	answer, err = Reply(context.Background(), router, Message{Channel: "C1", Text: " "})
+	require.NoError(t, err)
+	assert.Equal(t, emptyQuestionReply, answer.Text)
+
+	answer, err = Reply(context.Background(), router, Message{Channel: "C2", Text: "what is it?"})
+	require.NoError(t, err)
+	assert.Equal(t, noCollectionReply, answer.Text)
+
+	answerer.err = errors.New("model not found")
+	answer, err = Reply(context.Background(), router, Message{Channel: "C1", Text: "what is it?"})
+	assert.Error(t, err)
+	assert.Equal(t, failedReply, answer.Text)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", Truncate("short", 10))
+
+	truncated := Truncate("ääääää", 8)
+	assert.LessOrEqual(t, len(truncated), 8)
+	assert.True(t, utf8.ValidString(truncated), "characters must not be split")
+	assert.Equal(t, "ää…", truncated)
+}
+
+func TestKeepConnected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var reported []error
+	attempts := 0
+	err := KeepConnected(ctx, func(ctx context.Context) error {
+		attempts++
+		switch attempts {
+		case 1:
+			return ErrReconnect
+		case 2:
+			return errors.New("connection reset")
+		default:
+			return Permanent(errors.New("invalid token"))
+		}
+	}, func(err error) { reported = append(reported, err) })
+
+	assert.EqualError(t, err, "invalid token")
+	assert.Equal(t, 3, attempts)
+	require.Len(t, reported, 1, "requested reconnects aren't errors")
+	assert.Contains(t, reported[0].Error(), "connection reset")
+}
+
+func TestKeepConnectedStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := KeepConnected(ctx, func(ctx context.Context) error {
+		cancel()
+		return errors.New("connection closed")
+	}, nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,402 @@
+// Package discord runs a Discord bot that answers questions from a
+// collection. It receives messages over the Gateway and replies with the
+// REST API.
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/bot"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/websocket"
+)
+
+const (
+	// defaultAPIURL is the base URL of the Discord REST API
+	defaultAPIURL = "https://discord.com/api/v10"
+	// maxMessageLength is the longest message Discord accepts
+	maxMessageLength = 2000
+)
+
+// Gateway intents: messages in servers and DMs, and their content
+const intents = 1<<9 | 1<<12 | 1<<15
+
+// Gateway opcodes
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatAck   = 11
+)
+
+// mentionPattern matches user mentions such as <@123> and <@!123>
+var mentionPattern = regexp.MustCompile(`<@!?[0-9]+>`)
+
+// Bot answers direct messages and the messages that mention it
+type Bot struct {
+	config *config.DiscordConfig
+	apiURL string
+	http   *http.Client
+
+	// OnError is called with errors that don't stop the bot, such as a
+	// failed answer or a dropped connection
+	OnError func(error)
+}
+
+// payload is a Gateway message
+type payload struct {
+	Op       int             `json:"op"`
+	Data     json.RawMessage `json:"d,omitempty"`
+	Sequence *int64          `json:"s,omitempty"`
+	Type     string          `json:"t,omitempty"`
+}
+
+// user is a Discord user or bot
+type user struct {
+	ID  string `json:"id"`
+	Bot bool   `json:"bot"`
+}
+
+// message is the data of a MESSAGE_CREATE event
+type message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	Content   string `json:"content"`
+	Author    user   `json:"author"`
+	Mentions  []user `json:"mentions"`
+}
+
+// New creates a new Discord bot
+func New(config *config.DiscordConfig) *Bot {
+	return &Bot{
+		config: config,
+		apiURL: defaultAPIURL,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the name of the platform
+func (b *Bot) Name() string {
+	return "Discord"
+}
+
+// Run connects to the Gateway and answers messages until ctx is done.
+// Dropped connections are reopened; Run only fails when Discord rejects the
+// token or the intents.
+func (b *Bot) Run(ctx context.Context, handler bot.Handler) error {
+	if !b.config.Configured() {
+		return fmt.Errorf("a bot token is required")
+	}
+
+	var answers sync.WaitGroup
+	defer answers.Wait()
+
+	return bot.KeepConnected(ctx, func(ctx context.Context) error {
+		var gateway struct {
+			URL string `json:"url"`
+		}
+		if err := b.call(ctx, http.MethodGet, "/gateway/bot", nil, &gateway); err != nil {
+			return err
+		}
+		return b.serve(ctx, gateway.URL+"/?v=10&encoding=json", handler, &answers)
+	}, b.reportError)
+}
+
+// serve reads events from one Gateway connection until it is closed
+func (b *Bot) serve(ctx context.Context, url string, handler bot.Handler, answers *sync.WaitGroup) error {
+	conn, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	// Closing the connection unblocks the read loop
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-connCtx.Done()
+		conn.Close()
+	}()
+
+	var (
+		mu       sync.Mutex
+		sequence *int64
+		acked    = true
+		botID    string
+	)
+	heartbeat := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !acked {
+			// The connection is dead without having been closed
+			return fmt.Errorf("heartbeat was not acknowledged")
+		}
+		acked = false
+		return send(conn, opHeartbeat, sequence)
+	}
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return closeError(err)
+		}
+
+		var p payload
+		if err := json.Unmarshal(data, &p); err != nil {
+			b.reportError(fmt.Errorf("failed to parse gateway message: %w", err))
+			continue
+		}
+		if p.Sequence != nil {
+			mu.Lock()
+			sequence = p.Sequence
+			mu.Unlock()
+		}
+
+		switch p.Op {
+		case opHello:
+			var hello struct {
+				HeartbeatInterval int `json:"heartbeat_interval"`
+			}
+			if err := json.Unmarshal(p.Data, &hello); err != nil || hello.HeartbeatInterval <= 0 {
+				return fmt.Errorf("invalid hello from the gateway")
+			}
+			go keepAlive(connCtx, cancel, time.Duration(hello.HeartbeatInterval)*time.Millisecond, heartbeat, b.reportError)
+			if err := b.identify(conn); err != nil {
+				return err
+			}
+		case opHeartbeat:
+			mu.Lock()
+			err := send(conn, opHeartbeat, sequence)
+			mu.Unlock()
+			if err != nil {
+				return err
+			}
+		case opHeartbeatAck:
+			mu.Lock()
+			acked = true
+			mu.Unlock()
+		case opReconnect, opInvalidSession:
+			return bot.ErrReconnect
+		case opDispatch:
+			switch p.Type {
+			case "READY":
+				var ready struct {
+					User user `json:"user"`
+				}
+				if err := json.Unmarshal(p.Data, &ready); err != nil {
+					return fmt.Errorf("failed to parse ready event: %w", err)
+				}
+				botID = ready.User.ID
+			case "MESSAGE_CREATE":
+				var msg message
+				if err := json.Unmarshal(p.Data, &msg); err != nil {
+					b.reportError(fmt.Errorf("failed to parse message: %w", err))
+					continue
+				}
+				if !isQuestion(&msg, botID) {
+					continue
+				}
+				answers.Add(1)
+				go func() {
+					defer answers.Done()
+					b.handleMessage(ctx, handler, &msg)
+				}()
+			}
+		}
+	}
+}
+
+// keepAlive sends heartbeats until ctx is done, and cancels the connection
+// when one fails
+func keepAlive(ctx context.Context, cancel context.CancelFunc, interval time.Duration, heartbeat func() error, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				onError(err)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// identify starts a new session
+func (b *Bot) identify(conn *websocket.Conn) error {
+	return send(conn, opIdentify, map[string]interface{}{
+		"token":   b.config.Token,
+		"intents": intents,
+		"properties": map[string]string{
+			"os":      runtime.GOOS,
+			"browser": "rag-cli",
+			"device":  "rag-cli",
+		},
+	})
+}
+
+// send sends a Gateway message
+func send(conn *websocket.Conn, op int, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode gateway message: %w", err)
+	}
+	message, err := json.Marshal(payload{Op: op, Data: encoded})
+	if err != nil {
+		return fmt.Errorf("failed to encode gateway message: %w", err)
+	}
+	return conn.WriteMessage(message)
+}
+
+// closeError turns the close codes that reconnecting can't fix into
+// permanent errors
+func closeError(err error) error {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return err
+	}
+	switch closeErr.Code {
+	case 4004: // Authentication failed
+		return bot.Permanent(fmt.Errorf("discord rejected the token: %w", err))
+	case 4013, 4014: // Invalid or disallowed intents
+		return bot.Permanent(fmt.Errorf("enable the Message Content intent for the bot: %w", err))
+	}
+	return err
+}
+
+// isQuestion reports whether a message is a question for the bot: a direct
+// message, or a message in a server that mentions it
+func isQuestion(msg *message, botID string) bool {
+	if msg.Author.Bot || msg.Author.ID == botID {
+		return false
+	}
+	if msg.GuildID == "" {
+		return true
+	}
+	for _, mention := range msg.Mentions {
+		if mention.ID == botID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleMessage answers a message with a reply to it
+func (b *Bot) handleMessage(ctx context.Context, handler bot.Handler, msg *message) {
+	question := mentionPattern.ReplaceAllString(msg.Content, "")
+	answer, err := bot.Reply(ctx, handler, bot.Message{
+		Channel: msg.ChannelID,
+		Group:   msg.GuildID,
+		Text:    question,
+	})
+	if err != nil {
+		b.reportError(fmt.Errorf("failed to answer %q: %w", strings.TrimSpace(question), err))
+	}
+
+	reply := map[string]interface{}{
+		"content": bot.Truncate(FormatAnswer(answer), maxMessageLength),
+		"message_reference": map[string]interface{}{
+			"message_id":         msg.ID,
+			"fail_if_not_exists": false,
+		},
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	}
+	if err := b.call(ctx, http.MethodPost, "/channels/"+msg.ChannelID+"/messages", reply, nil); err != nil {
+		b.reportError(fmt.Errorf("failed to send reply: %w", err))
+	}
+}
+
+// FormatAnswer formats an answer as a Discord message, with the sources it
+// is based on listed after it
+func FormatAnswer(answer *bot.Answer) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(answer.Text))
+
+	if len(answer.Sources) > 0 {
+		b.WriteString("\n\n**Sources**")
+		for i, source := range answer.Sources {
+			fmt.Fprintf(&b, "\n%d. `%s` (chunk %d, score %.2f)", i+1, source.Path, source.ChunkIndex, source.Score)
+		}
+	}
+
+	return b.String()
+}
+
+// APIError is an error returned by the Discord REST API
+type APIError struct {
+	Path    string
+	Status  int
+	Message string
+}
+
+// Error returns the path and Discord's description of the error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("discord %s failed: %d %s", e.Path, e.Status, e.Message)
+}
+
+// call calls a REST API endpoint and decodes its response into result, if
+// given. A rejected token is reported as a permanent error.
+func (b *Bot) call(ctx context.Context, method, path string, body, result interface{}) error {
+	var encoded bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&encoded).Encode(body); err != nil {
+			return fmt.Errorf("failed to encode %s request: %w", path, err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.apiURL+path, &encoded)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", path, err)
+	}
+	req.Header.Set("Authorization", "Bot "+b.config.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DiscordBot (https://github.com/busybytelab.com/rag-cli, 1.0)")
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		apiErr := &APIError{Path: path, Status: resp.StatusCode, Message: failure.Message}
+		if resp.StatusCode == http.StatusUnauthorized {
+			return bot.Permanent(apiErr)
+		}
+		return apiErr
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// reportError passes an error to OnError
+func (b *Bot) reportError(err error) {
+	if b.OnError != nil {
+		b.OnError(err)
+	}
+}
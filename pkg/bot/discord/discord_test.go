@@ -0,0 +1,222 @@
+package discord
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/bot"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAnswerer answers every question with the question itself
+type fakeAnswerer struct {
+	collections chan string
+}
+
+func (a *fakeAnswerer) Answer(ctx context.Context, collection, question string) (*bot.Answer, error) {
+	a.collections <- collection
+	return &bot.Answer{
+		Text:    "You asked: " + question,
+		Sources: []bot.Source{{Path: "docs/install.md", ChunkIndex: 2, Score: 0.875}},
+	}, nil
+}
+
+// reply is a message posted by the bot
+type reply struct {
+	channel string
+	body    map[string]interface{}
+}
+
+// newFakeDiscord serves the REST endpoints the bot uses and a Gateway that
+// checks the identify message and then sends the given events. closeCode
+// makes the Gateway close the connection with that code instead.
+func newFakeDiscord(t *testing.T, closeCode uint16, events []string, replies chan reply) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/gateway/bot", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bot test-token", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]string{"url": "ws" + strings.TrimPrefix(server.URL, "http")})
+	})
+	mux.HandleFunc("/api/channels/", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		channel := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/channels/"), "/messages")
+		replies <- reply{channel: channel, body: body}
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "10", r.URL.Query().Get("v"))
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		writeFrame(rw.Writer, 0x1, `{"op":10,"d":{"heartbeat_interval":45000}}`)
+		rw.Flush()
+
+		identify, err := readClientFrame(rw.Reader)
+		require.NoError(t, err)
+		var p struct {
+			Op   int `json:"op"`
+			Data struct {
+				Token   string `json:"token"`
+				Intents int    `json:"intents"`
+			} `json:"d"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(identify), &p))
+		assert.Equal(t, opIdentify, p.Op)
+		assert.Equal(t, "test-token", p.Data.Token)
+		assert.NotZero(t, p.Data.Intents&(1<<15), "the message content intent is needed")
+
+		if closeCode != 0 {
+			var code [2]byte
+			binary.BigEndian.PutUint16(code[:], closeCode)
+			writeFrame(rw.Writer, 0x8, string(code[:])+"closed")
+			rw.Flush()
+			readClientFrame(rw.Reader)
+			return
+		}
+
+		writeFrame(rw.Writer, 0x1, `{"op":0,"s":1,"t":"READY","d":{"user":{"id":"900","bot":true}}}`)
+		for _, event := range events {
+			writeFrame(rw.Writer, 0x1, event)
+		}
+		rw.Flush()
+		for {
+			if _, err := readClientFrame(rw.Reader); err != nil {
+				return
+			}
+		}
+	})
+
+	server = httptest.NewServer(mux)
+	return server
+}
+
+// writeFrame writes an unmasked frame, as a server does
+func writeFrame(w *bufio.Writer, opcode byte, payload string) {
+	w.WriteByte(0x80 | opcode)
+	if len(payload) < 126 {
+		w.WriteByte(byte(len(payload)))
+	} else {
+		w.WriteByte(126)
+		w.WriteByte(byte(len(payload) >> 8))
+		w.WriteByte(byte(len(payload)))
+	}
+	w.WriteString(payload)
+}
+
+// readClientFrame reads a masked frame of up to 64KB sent by the client
+func readClientFrame(r *bufio.Reader) (string, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return "", err
+	}
+	if head[0]&0x0F == 0x8 {
+		return "", io.EOF
+	}
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return "", err
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return "", err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return string(payload), nil
+}
+
+func newTestBot(server *httptest.Server) *Bot {
+	b := New(&config.DiscordConfig{Token: "test-token"})
+	b.apiURL = server.URL + "/api"
+	return b
+}
+
+func TestBotAnswersMentionsAndDirectMessages(t *testing.T) {
+	replies := make(chan reply, 10)
+	server := newFakeDiscord(t, 0, []string{
+		`{"op":0,"s":2,"t":"MESSAGE_CREATE","d":{"id":"m1","channel_id":"c1","guild_id":"g1","content":"<@900> how do I install it?","author":{"id":"u1"},"mentions":[{"id":"900"}]}}`,
+		`{"op":0,"s":3,"t":"MESSAGE_CREATE","d":{"id":"m2","channel_id":"c1","guild_id":"g1","content":"just chatting","author":{"id":"u1"},"mentions":[]}}`,
+		`{"op":0,"s":4,"t":"MESSAGE_CREATE","d":{"id":"m3","channel_id":"dm1","content":"restart steps?","author":{"id":"u1"}}}`,
+		`{"op":0,"s":5,"t":"MESSAGE_CREATE","d":{"id":"m4","channel_id":"c1","guild_id":"g1","content":"You asked...","author":{"id":"900","bot":true}}}`,
+	}, replies)
+	defer server.Close()
+
+	answerer := &fakeAnswerer{collections: make(chan string, 10)}
+	handler := bot.NewRouter(answerer, config.ChannelMapping{
+		Collection: "docs",
+		Channels:   map[string]string{"g1": "support"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- newTestBot(server).Run(ctx, handler) }()
+
+	received := make(map[string]reply)
+	for len(received) < 2 {
+		select {
+		case r := <-replies:
+			received[r.channel] = r
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reply was posted")
+		}
+	}
+
+	mention := received["c1"].body
+	assert.Equal(t, "You asked: how do I install it?\n\n**Sources**\n1. `docs/install.md` (chunk 2, score 0.88)", mention["content"])
+	assert.Equal(t, "m1", mention["message_reference"].(map[string]interface{})["message_id"])
+	assert.Contains(t, received["dm1"].body["content"], "You asked: restart steps?")
+	assert.ElementsMatch(t, []string{"support", "docs"}, []string{<-answerer.collections, <-answerer.collections})
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Empty(t, replies, "only questions for the bot should be answered")
+}
+
+func TestBotStopsWhenTokenIsRejected(t *testing.T) {
+	server := newFakeDiscord(t, 4004, nil, nil)
+	defer server.Close()
+
+	err := newTestBot(server).Run(context.Background(), bot.NewRouter(&fakeAnswerer{}, config.ChannelMapping{}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rejected the token")
+}
+
+func TestBotStopsOnUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"401: Unauthorized","code":0}`))
+	}))
+	defer server.Close()
+
+	err := newTestBot(server).Run(context.Background(), bot.NewRouter(&fakeAnswerer{}, config.ChannelMapping{}))
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr), "expected an API error, got %v", err)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+}
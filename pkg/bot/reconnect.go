@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// minReconnectDelay and maxReconnectDelay bound the wait between reconnects
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// ErrReconnect is returned by connections the platform asked to reconnect.
+// They are reopened right away.
+var ErrReconnect = errors.New("reconnect requested")
+
+// permanentError marks an error that reconnecting can't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that reconnecting can't fix, such as a rejected
+// token, so KeepConnected gives up
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// KeepConnected runs connect until ctx is done, reconnecting when it
+// returns. Failed connections are retried with exponential backoff and
+// reported to onError; a permanent error is returned.
+func KeepConnected(ctx context.Context, connect func(ctx context.Context) error, onError func(error)) error {
+	delay := minReconnectDelay
+	for {
+		started := time.Now()
+		err := connect(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+
+		// A connection that lasted a while resets the backoff
+		if errors.Is(err, ErrReconnect) || time.Since(started) > maxReconnectDelay {
+			delay = minReconnectDelay
+		}
+		if err != nil && !errors.Is(err, ErrReconnect) && onError != nil {
+			onError(fmt.Errorf("connection lost, reconnecting in %s: %w", delay, err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		if !errors.Is(err, ErrReconnect) {
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+		}
+	}
+}
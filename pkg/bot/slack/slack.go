@@ -0,0 +1,281 @@
+// Package slack runs a Slack bot that answers mentions from a collection.
+// It connects with Socket Mode, so it needs no public URL.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/bot"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/websocket"
+)
+
+// defaultAPIURL is the base URL of the Slack Web API
+const defaultAPIURL = "https://slack.com/api/"
+
+// mentionPattern matches user mentions such as <@U0123ABCD>
+var mentionPattern = regexp.MustCompile(`<@[A-Z0-9]+(\|[^>]*)?>`)
+
+// Bot answers the mentions of a Slack app in the thread they were made in
+type Bot struct {
+	config *config.SlackConfig
+	apiURL string
+	http   *http.Client
+
+	// OnError is called with errors that don't stop the bot, such as a
+	// failed answer or a dropped connection
+	OnError func(error)
+}
+
+// envelope is a Socket Mode message
+type envelope struct {
+	Type       string          `json:"type"`
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+}
+
+// eventCallback is the payload of an events_api envelope
+type eventCallback struct {
+	Event event `json:"event"`
+}
+
+// event is a Slack event
+type event struct {
+	Type     string `json:"type"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Text     string `json:"text"`
+	Channel  string `json:"channel"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+}
+
+// apiResponse is the common part of Web API responses
+type apiResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	URL   string `json:"url"`
+}
+
+// New creates a new Slack bot
+func New(config *config.SlackConfig) *Bot {
+	return &Bot{
+		config: config,
+		apiURL: defaultAPIURL,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the name of the platform
+func (b *Bot) Name() string {
+	return "Slack"
+}
+
+// Run connects to Slack and answers mentions until ctx is done. Dropped
+// connections are reopened; Run only fails when Slack rejects the tokens.
+func (b *Bot) Run(ctx context.Context, handler bot.Handler) error {
+	if !b.config.Configured() {
+		return fmt.Errorf("both an app token and a bot token are required")
+	}
+
+	var answers sync.WaitGroup
+	defer answers.Wait()
+
+	return bot.KeepConnected(ctx, func(ctx context.Context) error {
+		url, err := b.openConnection(ctx)
+		if err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.fatal() {
+				return bot.Permanent(err)
+			}
+			return err
+		}
+		return b.serve(ctx, url, handler, &answers)
+	}, b.reportError)
+}
+
+// openConnection asks Slack for a Socket Mode URL
+func (b *Bot) openConnection(ctx context.Context) (string, error) {
+	response, err := b.call(ctx, b.config.AppToken, "apps.connections.open", nil)
+	if err != nil {
+		return "", err
+	}
+	return response.URL, nil
+}
+
+// serve reads envelopes from one Socket Mode connection until it is closed
+func (b *Bot) serve(ctx context.Context, url string, handler bot.Handler, answers *sync.WaitGroup) error {
+	conn, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	// Closing the connection unblocks the read loop
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+			conn.Close()
+		}
+	}()
+
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var env envelope
+		if err := json.Unmarshal(message, &env); err != nil {
+			b.reportError(fmt.Errorf("failed to parse message: %w", err))
+			continue
+		}
+
+		// Envelopes must be acknowledged quickly or Slack sends them again
+		if env.EnvelopeID != "" {
+			ack, _ := json.Marshal(map[string]string{"envelope_id": env.EnvelopeID})
+			if err := conn.WriteMessage(ack); err != nil {
+				return err
+			}
+		}
+
+		switch env.Type {
+		case "disconnect":
+			return bot.ErrReconnect
+		case "events_api":
+			var callback eventCallback
+			if err := json.Unmarshal(env.Payload, &callback); err != nil {
+				b.reportError(fmt.Errorf("failed to parse event: %w", err))
+				continue
+			}
+			if callback.Event.Type != "app_mention" || callback.Event.BotID != "" {
+				continue
+			}
+			answers.Add(1)
+			go func(ev event) {
+				defer answers.Done()
+				b.handleMention(ctx, handler, ev)
+			}(callback.Event)
+		}
+	}
+}
+
+// handleMention answers a mention in its thread
+func (b *Bot) handleMention(ctx context.Context, handler bot.Handler, ev event) {
+	thread := ev.ThreadTS
+	if thread == "" {
+		thread = ev.TS
+	}
+
+	question := mentionPattern.ReplaceAllString(ev.Text, "")
+	answer, err := bot.Reply(ctx, handler, bot.Message{Channel: ev.Channel, Text: question})
+	if err != nil {
+		b.reportError(fmt.Errorf("failed to answer %q: %w", strings.TrimSpace(question), err))
+	}
+	b.reply(ctx, ev.Channel, thread, FormatAnswer(answer))
+}
+
+// reply posts a message in a thread
+func (b *Bot) reply(ctx context.Context, channel, thread, text string) {
+	params := map[string]interface{}{
+		"channel":      channel,
+		"thread_ts":    thread,
+		"text":         text,
+		"unfurl_links": false,
+	}
+	if _, err := b.call(ctx, b.config.BotToken, "chat.postMessage", params); err != nil {
+		b.reportError(fmt.Errorf("failed to post reply: %w", err))
+	}
+}
+
+// FormatAnswer formats an answer as a Slack message, with the sources it
+// is based on listed after it
+func FormatAnswer(answer *bot.Answer) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(answer.Text))
+
+	if len(answer.Sources) > 0 {
+		b.WriteString("\n\n*Sources*")
+		for i, source := range answer.Sources {
+			fmt.Fprintf(&b, "\n%d. `%s` (chunk %d, score %.2f)", i+1, source.Path, source.ChunkIndex, source.Score)
+		}
+	}
+
+	return b.String()
+}
+
+// APIError is an error reported by the Slack Web API
+type APIError struct {
+	Method string
+	Code   string
+}
+
+// Error returns the method and Slack's error code
+func (e *APIError) Error() string {
+	return fmt.Sprintf("slack %s failed: %s", e.Method, e.Code)
+}
+
+// fatal reports whether retrying can't help, because the token is wrong
+func (e *APIError) fatal() bool {
+	switch e.Code {
+	case "invalid_auth", "not_authed", "account_inactive", "token_revoked", "not_allowed_token_type", "missing_scope":
+		return true
+	}
+	return false
+}
+
+// call calls a Web API method with a JSON body
+func (b *Bot) call(ctx context.Context, token, method string, params interface{}) (*apiResponse, error) {
+	var body bytes.Buffer
+	if params != nil {
+		if err := json.NewEncoder(&body).Encode(params); err != nil {
+			return nil, fmt.Errorf("failed to encode %s request: %w", method, err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.apiURL+method, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %w", method, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to call %s: %s", method, resp.Status)
+	}
+
+	var response apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if !response.OK {
+		return nil, &APIError{Method: method, Code: response.Error}
+	}
+	return &response, nil
+}
+
+// reportError passes an error to OnError
+func (b *Bot) reportError(err error) {
+	if b.OnError != nil {
+		b.OnError(err)
+	}
+}
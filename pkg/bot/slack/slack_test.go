@@ -0,0 +1,241 @@
+package slack
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/bot"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAnswerer answers every question with the question itself
+type fakeAnswerer struct {
+	collection string
+	err        error
+}
+
+func (a *fakeAnswerer) Answer(ctx context.Context, collection, question string) (*bot.Answer, error) {
+	a.collection = collection
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &bot.Answer{
+		Text:    "You asked: " + question,
+		Sources: []bot.Source{{Path: "docs/install.md", ChunkIndex: 2, Score: 0.875}},
+	}, nil
+}
+
+// fakeSlack serves the Web API methods the bot uses and a Socket Mode
+// connection that sends the given envelopes
+type fakeSlack struct {
+	t         *testing.T
+	server    *httptest.Server
+	envelopes []string
+	acks      chan string
+	posts     chan map[string]interface{}
+	openError string
+}
+
+func newFakeSlack(t *testing.T, envelopes ...string) *fakeSlack {
+	f := &fakeSlack{
+		t:         t,
+		envelopes: envelopes,
+		acks:      make(chan string, 10),
+		posts:     make(chan map[string]interface{}, 10),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer xapp-test", r.Header.Get("Authorization"))
+		if f.openError != "" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": f.openError})
+			return
+		}
+		url := "ws" + strings.TrimPrefix(f.server.URL, "http") + "/socket"
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "url": url})
+	})
+	mux.HandleFunc("/api/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer xoxb-test", r.Header.Get("Authorization"))
+		var params map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		f.posts <- params
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+	})
+	mux.HandleFunc("/socket", f.handleSocket)
+
+	f.server = httptest.NewServer(mux)
+	return f
+}
+
+// handleSocket accepts the WebSocket handshake, sends the envelopes and
+// reads acknowledgements until the client goes away
+func (f *fakeSlack) handleSocket(w http.ResponseWriter, r *http.Request) {
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	require.NoError(f.t, err)
+	defer conn.Close()
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+
+	for _, env := range append([]string{`{"type":"hello"}`}, f.envelopes...) {
+		writeTextFrame(rw.Writer, env)
+	}
+	rw.Flush()
+
+	for {
+		message, err := readClientFrame(rw.Reader)
+		if err != nil {
+			return
+		}
+		f.acks <- message
+	}
+}
+
+// writeTextFrame writes an unmasked text frame, as a server does
+func writeTextFrame(w *bufio.Writer, payload string) {
+	w.WriteByte(0x81)
+	if len(payload) < 126 {
+		w.WriteByte(byte(len(payload)))
+	} else {
+		w.WriteByte(126)
+		w.WriteByte(byte(len(payload) >> 8))
+		w.WriteByte(byte(len(payload)))
+	}
+	w.WriteString(payload)
+}
+
+// readClientFrame reads a small masked frame sent by the client
+func readClientFrame(r *bufio.Reader) (string, error) {
+	head := make([]byte, 6)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return "", err
+	}
+	if head[0]&0x0F == 0x8 {
+		return "", io.EOF
+	}
+	payload := make([]byte, head[1]&0x7F)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+	for i := range payload {
+		payload[i] ^= head[2+i%4]
+	}
+	return string(payload), nil
+}
+
+func newTestBot(f *fakeSlack) *Bot {
+	b := New(&config.SlackConfig{AppToken: "xapp-test", BotToken: "xoxb-test"})
+	b.apiURL = f.server.URL + "/api/"
+	return b
+}
+
+// newHandler routes every channel to the docs collection, except C2
+func newHandler(answerer bot.Answerer) bot.Handler {
+	return bot.NewRouter(answerer, config.ChannelMapping{
+		Collection: "docs",
+		Channels:   map[string]string{"c2": "runbooks"},
+	})
+}
+
+func TestBotAnswersMentionsInThread(t *testing.T) {
+	f := newFakeSlack(t,
+		`{"type":"events_api","envelope_id":"env-1","payload":{"event":{"type":"app_mention","user":"U1","text":"<@U0BOT> how do I install it?","channel":"C1","ts":"100.1"}}}`,
+		`{"type":"events_api","envelope_id":"env-2","payload":{"event":{"type":"message","text":"not for the bot","channel":"C1","ts":"100.2"}}}`,
+	)
+	defer f.server.Close()
+
+	answerer := &fakeAnswerer{}
+	b := newTestBot(f)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx, newHandler(answerer)) }()
+
+	assert.JSONEq(t, `{"envelope_id":"env-1"}`, <-f.acks)
+	assert.JSONEq(t, `{"envelope_id":"env-2"}`, <-f.acks)
+
+	select {
+	case post := <-f.posts:
+		assert.Equal(t, "C1", post["channel"])
+		assert.Equal(t, "100.1", post["thread_ts"])
+		assert.Equal(t, "You asked: how do I install it?\n\n*Sources*\n1. `docs/install.md` (chunk 2, score 0.88)", post["text"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply was posted")
+	}
+	assert.Equal(t, "docs", answerer.collection)
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Empty(t, f.posts, "only mentions should be answered")
+}
+
+func TestBotAnswersFromChannelCollection(t *testing.T) {
+	f := newFakeSlack(t,
+		`{"type":"events_api","envelope_id":"env-1","payload":{"event":{"type":"app_mention","text":"<@U0BOT> restart steps?","channel":"C2","ts":"5.1"}}}`,
+	)
+	defer f.server.Close()
+
+	answerer := &fakeAnswerer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- newTestBot(f).Run(ctx, newHandler(answerer)) }()
+
+	post := <-f.posts
+	assert.Equal(t, "C2", post["channel"])
+	assert.Equal(t, "runbooks", answerer.collection)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestBotRepliesWhenAnswerFails(t *testing.T) {
+	f := newFakeSlack(t,
+		`{"type":"events_api","envelope_id":"env-1","payload":{"event":{"type":"app_mention","text":"<@U0BOT> hi","channel":"C1","ts":"1.1","thread_ts":"1.0"}}}`,
+	)
+	defer f.server.Close()
+
+	var reported []error
+	b := newTestBot(f)
+	b.OnError = func(err error) { reported = append(reported, err) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx, newHandler(&fakeAnswerer{err: errors.New("model not found")})) }()
+
+	post := <-f.posts
+	assert.Equal(t, "1.0", post["thread_ts"], "replies go to the existing thread")
+	assert.Contains(t, post["text"], "couldn't answer")
+
+	cancel()
+	assert.NoError(t, <-done)
+	require.NotEmpty(t, reported)
+	assert.Contains(t, reported[0].Error(), "model not found")
+}
+
+func TestBotStopsOnInvalidToken(t *testing.T) {
+	f := newFakeSlack(t)
+	f.openError = "invalid_auth"
+	defer f.server.Close()
+
+	err := newTestBot(f).Run(context.Background(), newHandler(&fakeAnswerer{}))
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr), "expected an API error, got %v", err)
+	assert.Equal(t, "invalid_auth", apiErr.Code)
+}
+
+func TestFormatAnswer(t *testing.T) {
+	assert.Equal(t, "No sources here.", FormatAnswer(&bot.Answer{Text: " No sources here.\n"}))
+}
@@ -0,0 +1,276 @@
+// Package telegram runs a Telegram bot that answers questions from a
+// collection. It receives messages with long polling, so it needs no public
+// URL.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/bot"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+const (
+	// defaultAPIURL is the base URL of the Telegram Bot API
+	defaultAPIURL = "https://api.telegram.org/"
+	// pollTimeout is how long Telegram holds a getUpdates request open
+	pollTimeout = 30 * time.Second
+	// maxMessageLength is the longest message Telegram accepts
+	maxMessageLength = 4096
+)
+
+// Bot answers private messages, and the messages that mention it or reply
+// to it in groups
+type Bot struct {
+	config *config.TelegramConfig
+	apiURL string
+	http   *http.Client
+
+	// OnError is called with errors that don't stop the bot, such as a
+	// failed answer or a dropped connection
+	OnError func(error)
+}
+
+// user is a Telegram user or bot
+type user struct {
+	ID       int64  `json:"id"`
+	IsBot    bool   `json:"is_bot"`
+	Username string `json:"username"`
+}
+
+// chat is a private chat, group or channel
+type chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+// message is a Telegram message
+type message struct {
+	MessageID      int64    `json:"message_id"`
+	From           *user    `json:"from"`
+	Chat           chat     `json:"chat"`
+	Text           string   `json:"text"`
+	ReplyToMessage *message `json:"reply_to_message"`
+}
+
+// update is an incoming update from getUpdates
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+// apiResponse is a Bot API response
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// New creates a new Telegram bot
+func New(config *config.TelegramConfig) *Bot {
+	return &Bot{
+		config: config,
+		apiURL: defaultAPIURL,
+		// Long polling requests stay open for pollTimeout
+		http: &http.Client{Timeout: pollTimeout + 30*time.Second},
+	}
+}
+
+// Name returns the name of the platform
+func (b *Bot) Name() string {
+	return "Telegram"
+}
+
+// Run polls Telegram for messages and answers them until ctx is done. Failed
+// polls are retried; Run only fails when Telegram rejects the token.
+func (b *Bot) Run(ctx context.Context, handler bot.Handler) error {
+	if !b.config.Configured() {
+		return fmt.Errorf("a bot token is required")
+	}
+
+	var answers sync.WaitGroup
+	defer answers.Wait()
+
+	var offset int64
+	return bot.KeepConnected(ctx, func(ctx context.Context) error {
+		var me user
+		if err := b.call(ctx, "getMe", nil, &me); err != nil {
+			return err
+		}
+		mention := mentionPattern(me.Username)
+
+		for {
+			var updates []update
+			params := map[string]interface{}{
+				"offset":          offset,
+				"timeout":         int(pollTimeout.Seconds()),
+				"allowed_updates": []string{"message"},
+			}
+			if err := b.call(ctx, "getUpdates", params, &updates); err != nil {
+				return err
+			}
+
+			for _, u := range updates {
+				// Confirming an update stops Telegram from sending it again
+				offset = u.UpdateID + 1
+				if u.Message == nil {
+					continue
+				}
+				question, ok := questionFor(u.Message, me.ID, mention)
+				if !ok {
+					continue
+				}
+				answers.Add(1)
+				go func(msg *message) {
+					defer answers.Done()
+					b.handleMessage(ctx, handler, msg, question)
+				}(u.Message)
+			}
+		}
+	}, b.reportError)
+}
+
+// mentionPattern matches mentions of the bot such as @docs_bot
+func mentionPattern(username string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(username) + `\b`)
+}
+
+// questionFor returns the question asked by a message. Every message in a
+// private chat is a question; in groups only mentions of the bot and replies
+// to it are.
+func questionFor(msg *message, botID int64, mention *regexp.Regexp) (string, bool) {
+	if msg.Text == "" || (msg.From != nil && msg.From.IsBot) {
+		return "", false
+	}
+	if msg.Chat.Type == "private" {
+		return msg.Text, true
+	}
+	if mention.MatchString(msg.Text) {
+		return mention.ReplaceAllString(msg.Text, ""), true
+	}
+	if reply := msg.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == botID {
+		return msg.Text, true
+	}
+	return "", false
+}
+
+// handleMessage answers a message with a reply to it
+func (b *Bot) handleMessage(ctx context.Context, handler bot.Handler, msg *message, question string) {
+	answer, err := bot.Reply(ctx, handler, bot.Message{
+		Channel: strconv.FormatInt(msg.Chat.ID, 10),
+		Text:    question,
+	})
+	if err != nil {
+		b.reportError(fmt.Errorf("failed to answer %q: %w", strings.TrimSpace(question), err))
+	}
+
+	params := map[string]interface{}{
+		"chat_id":                  msg.Chat.ID,
+		"text":                     bot.Truncate(FormatAnswer(answer), maxMessageLength),
+		"disable_web_page_preview": true,
+		"reply_parameters": map[string]interface{}{
+			"message_id":                  msg.MessageID,
+			"allow_sending_without_reply": true,
+		},
+	}
+	if err := b.call(ctx, "sendMessage", params, nil); err != nil {
+		b.reportError(fmt.Errorf("failed to send reply: %w", err))
+	}
+}
+
+// FormatAnswer formats an answer as a plain text Telegram message, with the
+// sources it is based on listed after it
+func FormatAnswer(answer *bot.Answer) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(answer.Text))
+
+	if len(answer.Sources) > 0 {
+		b.WriteString("\n\nSources:")
+		for i, source := range answer.Sources {
+			fmt.Fprintf(&b, "\n%d. %s (chunk %d, score %.2f)", i+1, source.Path, source.ChunkIndex, source.Score)
+		}
+	}
+
+	return b.String()
+}
+
+// APIError is an error reported by the Telegram Bot API
+type APIError struct {
+	Method      string
+	Code        int
+	Description string
+}
+
+// Error returns the method and Telegram's description of the error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram %s failed: %d %s", e.Method, e.Code, e.Description)
+}
+
+// call calls a Bot API method with a JSON body and decodes its result into
+// result, if given. Rejected tokens are reported as permanent errors.
+func (b *Bot) call(ctx context.Context, method string, params, result interface{}) error {
+	var body bytes.Buffer
+	if params != nil {
+		if err := json.NewEncoder(&body).Encode(params); err != nil {
+			return fmt.Errorf("failed to encode %s request: %w", method, err)
+		}
+	}
+
+	endpoint := b.apiURL + "bot" + b.config.Token + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		// The URL contains the token, so don't include it in the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var response apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %s", method, resp.Status)
+	}
+	if !response.OK {
+		apiErr := &APIError{Method: method, Code: response.ErrorCode, Description: response.Description}
+		// 401 is an invalid token and 404 a malformed one
+		switch response.ErrorCode {
+		case http.StatusUnauthorized, http.StatusNotFound:
+			return bot.Permanent(apiErr)
+		}
+		return apiErr
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// reportError passes an error to OnError
+func (b *Bot) reportError(err error) {
+	if b.OnError != nil {
+		b.OnError(err)
+	}
+}
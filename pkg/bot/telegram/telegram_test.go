@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/bot"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAnswerer answers every question with the question itself
+type fakeAnswerer struct {
+	collections chan string
+}
+
+func (a *fakeAnswerer) Answer(ctx context.Context, collection, question string) (*bot.Answer, error) {
+	a.collections <- collection
+	return &bot.Answer{
+		Text:    "You asked: " + question,
+		Sources: []bot.Source{{Path: "docs/install.md", ChunkIndex: 2, Score: 0.875}},
+	}, nil
+}
+
+// newFakeTelegram serves the Bot API methods the bot uses. The first
+// getUpdates returns the given updates and later ones wait until the bot
+// stops.
+func newFakeTelegram(t *testing.T, updates string, sent chan map[string]interface{}) *httptest.Server {
+	polled := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bot123:test/getMe", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true,"result":{"id":42,"is_bot":true,"username":"docs_bot"}}`))
+	})
+	mux.HandleFunc("/bot123:test/getUpdates", func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		if polled {
+			assert.Equal(t, float64(4), params["offset"], "handled updates should be confirmed")
+			<-r.Context().Done()
+			return
+		}
+		polled = true
+		w.Write([]byte(`{"ok":true,"result":` + updates + `}`))
+	})
+	mux.HandleFunc("/bot123:test/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		sent <- params
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestBotAnswersQuestions(t *testing.T) {
+	sent := make(chan map[string]interface{}, 10)
+	server := newFakeTelegram(t, `[
+		{"update_id":1,"message":{"message_id":10,"from":{"id":7},"chat":{"id":7,"type":"private"},"text":"how do I install it?"}},
+		{"update_id":2,"message":{"message_id":11,"from":{"id":7},"chat":{"id":-100,"type":"supergroup"},"text":"just chatting"}},
+		{"update_id":3,"message":{"message_id":12,"from":{"id":7},"chat":{"id":-100,"type":"supergroup"},"text":"@Docs_Bot restart steps?"}}
+	]`, sent)
+	defer server.Close()
+
+	b := New(&config.TelegramConfig{Token: "123:test"})
+	b.apiURL = server.URL + "/"
+	answerer := &fakeAnswerer{collections: make(chan string, 10)}
+	handler := bot.NewRouter(answerer, config.ChannelMapping{
+		Collection: "docs",
+		Channels:   map[string]string{"-100": "runbooks"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx, handler) }()
+
+	replies := make(map[float64]map[string]interface{})
+	for len(replies) < 2 {
+		select {
+		case params := <-sent:
+			replies[params["chat_id"].(float64)] = params
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reply was sent")
+		}
+	}
+
+	private := replies[7]
+	assert.Equal(t, "You asked: how do I install it?\n\nSources:\n1. docs/install.md (chunk 2, score 0.88)", private["text"])
+	assert.Equal(t, float64(10), private["reply_parameters"].(map[string]interface{})["message_id"])
+
+	group := replies[-100]
+	assert.Contains(t, group["text"], "You asked: restart steps?")
+	assert.ElementsMatch(t, []string{"docs", "runbooks"}, []string{<-answerer.collections, <-answerer.collections})
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Empty(t, sent, "only questions for the bot should be answered")
+}
+
+func TestBotStopsOnInvalidToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+	}))
+	defer server.Close()
+
+	b := New(&config.TelegramConfig{Token: "123:test"})
+	b.apiURL = server.URL + "/"
+
+	err := b.Run(context.Background(), bot.NewRouter(&fakeAnswerer{}, config.ChannelMapping{}))
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr), "expected an API error, got %v", err)
+	assert.Equal(t, 401, apiErr.Code)
+}
+
+func TestQuestionFor(t *testing.T) {
+	mention := mentionPattern("docs_bot")
+	reply := &message{From: &user{ID: 42}}
+
+	tests := []struct {
+		name string
+		msg  message
+		want string
+		ok   bool
+	}{
+		{"private chat", message{Chat: chat{Type: "private"}, Text: "hi"}, "hi", true},
+		{"mention in a group", message{Chat: chat{Type: "group"}, Text: "@docs_bot hi"}, " hi", true},
+		{"reply to the bot", message{Chat: chat{Type: "group"}, Text: "and then?", ReplyToMessage: reply}, "and then?", true},
+		{"other bot", message{From: &user{IsBot: true}, Chat: chat{Type: "private"}, Text: "hi"}, "", false},
+		{"longer username", message{Chat: chat{Type: "group"}, Text: "@docs_bot2 hi"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := questionFor(&tt.msg, 42, mention)
+		assert.Equal(t, tt.ok, ok, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
@@ -0,0 +1,126 @@
+// Package calibration maps search scores to a probability-like confidence
+// that a result is relevant. Combined scores depend on the search type,
+// weights and reranking, so a score of 0.6 can be a sure hit in one setup and
+// noise in another. A logistic curve fitted from labeled queries (Platt
+// scaling) turns scores into confidences between 0 and 1 that can be compared
+// with a fixed threshold.
+package calibration
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// maxIterations bounds the Newton steps of a fit
+	maxIterations = 100
+	// tolerance is the step size at which a fit has converged
+	tolerance = 1e-10
+	// ridge keeps the Hessian invertible when all scores are close together
+	ridge = 1e-9
+)
+
+// Sample is the score of a search result and whether it was relevant
+type Sample struct {
+	Score    float64
+	Relevant bool
+}
+
+// Model is a logistic mapping from scores to confidences
+type Model struct {
+	Slope     float64
+	Intercept float64
+}
+
+// Confidence returns the probability-like confidence of a score
+func (m Model) Confidence(score float64) float64 {
+	return sigmoid(m.Slope*score + m.Intercept)
+}
+
+// Fit fits a model to samples with Platt scaling. The samples need both
+// relevant and irrelevant results, and the fitted confidence rises with the
+// score unless the samples say otherwise.
+func Fit(samples []Sample) (Model, error) {
+	var relevant, irrelevant int
+	for _, sample := range samples {
+		if sample.Relevant {
+			relevant++
+		} else {
+			irrelevant++
+		}
+	}
+	if relevant == 0 || irrelevant == 0 {
+		return Model{}, fmt.Errorf("need both relevant and irrelevant results to fit a calibration, got %d relevant and %d irrelevant", relevant, irrelevant)
+	}
+
+	// Platt's targets keep a separable set of samples from pushing the
+	// slope to infinity
+	highTarget := (float64(relevant) + 1) / (float64(relevant) + 2)
+	lowTarget := 1 / (float64(irrelevant) + 2)
+	targets := make([]float64, len(samples))
+	for i, sample := range samples {
+		targets[i] = lowTarget
+		if sample.Relevant {
+			targets[i] = highTarget
+		}
+	}
+
+	// Start from the base rate, then take Newton steps on the log loss
+	model := Model{Intercept: math.Log((float64(relevant) + 1) / (float64(irrelevant) + 1))}
+	for i := 0; i < maxIterations; i++ {
+		var gradA, gradB, hAA, hAB, hBB float64
+		for j, sample := range samples {
+			p := model.Confidence(sample.Score)
+			d := p - targets[j]
+			w := p * (1 - p)
+			gradA += d * sample.Score
+			gradB += d
+			hAA += w * sample.Score * sample.Score
+			hAB += w * sample.Score
+			hBB += w
+		}
+		hAA += ridge
+		hBB += ridge
+
+		det := hAA*hBB - hAB*hAB
+		if det <= 0 {
+			break
+		}
+		stepA := (hBB*gradA - hAB*gradB) / det
+		stepB := (hAA*gradB - hAB*gradA) / det
+		model.Slope -= stepA
+		model.Intercept -= stepB
+		if math.Abs(stepA) < tolerance && math.Abs(stepB) < tolerance {
+			break
+		}
+	}
+
+	if math.IsNaN(model.Slope) || math.IsNaN(model.Intercept) || math.IsInf(model.Slope, 0) || math.IsInf(model.Intercept, 0) {
+		return Model{}, fmt.Errorf("calibration fit did not converge")
+	}
+	return model, nil
+}
+
+// LogLoss returns the mean log loss of the model's confidences on samples,
+// where lower is better and 0.693 is no better than a coin toss
+func LogLoss(m Model, samples []Sample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	const epsilon = 1e-15
+	var loss float64
+	for _, sample := range samples {
+		p := math.Min(math.Max(m.Confidence(sample.Score), epsilon), 1-epsilon)
+		if sample.Relevant {
+			loss -= math.Log(p)
+		} else {
+			loss -= math.Log(1 - p)
+		}
+	}
+	return loss / float64(len(samples))
+}
+
+// sigmoid is the logistic function
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
@@ -0,0 +1,56 @@
+package calibration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFit(t *testing.T) {
+	// Relevant results mostly score high, irrelevant ones mostly low
+	var samples []Sample
+	for _, score := range []float64{0.55, 0.6, 0.65, 0.7, 0.75, 0.8, 0.85, 0.4} {
+		samples = append(samples, Sample{Score: score, Relevant: true})
+	}
+	for _, score := range []float64{0.1, 0.15, 0.2, 0.25, 0.3, 0.35, 0.45, 0.6} {
+		samples = append(samples, Sample{Score: score, Relevant: false})
+	}
+
+	model, err := Fit(samples)
+	require.NoError(t, err)
+	assert.Greater(t, model.Slope, 0.0)
+
+	assert.Greater(t, model.Confidence(0.8), 0.8)
+	assert.Less(t, model.Confidence(0.15), 0.2)
+	assert.InDelta(t, 0.5, model.Confidence(0.47), 0.15)
+	assert.Less(t, LogLoss(model, samples), 0.693)
+	assert.Less(t, LogLoss(model, samples), LogLoss(Model{}, samples))
+}
+
+func TestFitSeparable(t *testing.T) {
+	samples := []Sample{
+		{Score: 0.9, Relevant: true},
+		{Score: 0.8, Relevant: true},
+		{Score: 0.2, Relevant: false},
+		{Score: 0.1, Relevant: false},
+	}
+
+	model, err := Fit(samples)
+	require.NoError(t, err)
+	assert.Less(t, model.Confidence(0.9), 1.0, "confidences stay below certainty")
+	assert.Greater(t, model.Confidence(0.9), 0.7)
+	assert.Less(t, model.Confidence(0.1), 0.3)
+}
+
+func TestFitNeedsBothLabels(t *testing.T) {
+	_, err := Fit([]Sample{{Score: 0.9, Relevant: true}, {Score: 0.4, Relevant: true}})
+	assert.Error(t, err)
+	_, err = Fit(nil)
+	assert.Error(t, err)
+}
+
+func TestConfidence(t *testing.T) {
+	assert.Equal(t, 0.5, Model{}.Confidence(0.7))
+	assert.InDelta(t, 0.731, Model{Slope: 2, Intercept: -1}.Confidence(1), 0.001)
+}
@@ -0,0 +1,478 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// ErrBudgetExceeded is returned when a request would go over a configured
+// budget
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// defaultPrices are the OpenAI prices in USD per million tokens
+var defaultPrices = map[string]config.ModelPrice{
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.10},
+	"gpt-4.1":                {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":           {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":           {Input: 0.10, Output: 0.40},
+	"gpt-4o":                 {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":            {Input: 0.15, Output: 0.60},
+	"gpt-4-turbo":            {Input: 10.00, Output: 30.00},
+	"gpt-4":                  {Input: 30.00, Output: 60.00},
+	"gpt-3.5-turbo":          {Input: 0.50, Output: 1.50},
+}
+
+// Budget enforces the configured budgets on backend requests: the estimated
+// tokens of each request, the estimated OpenAI spend of each day and the
+// number of embedding requests of an index run. The daily spend is kept in
+// a file, so it is shared by all commands.
+type Budget struct {
+	config    *config.BudgetConfig
+	usagePath string
+	now       func() time.Time
+
+	mu             sync.Mutex
+	embeddingCalls int
+	embeddingLimit int
+}
+
+// dailyUsage is the content of the usage file
+type dailyUsage struct {
+	Date string  `json:"date"`
+	Cost float64 `json:"cost_usd"`
+}
+
+// NewBudget creates a budget that keeps the daily spend in usagePath
+func NewBudget(cfg *config.BudgetConfig, usagePath string) *Budget {
+	return &Budget{
+		config:    cfg,
+		usagePath: usagePath,
+		now:       time.Now,
+	}
+}
+
+// LimitEmbeddingCalls restarts the count of embedding requests and makes
+// requests over limit fail (0 = unlimited). Index runs call it, so the limit
+// applies to each run rather than to every command.
+func (b *Budget) LimitEmbeddingCalls(limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.embeddingCalls = 0
+	b.embeddingLimit = limit
+}
+
+// EmbeddingCalls returns the number of embedding requests since the limit
+// was set
+func (b *Budget) EmbeddingCalls() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.embeddingCalls
+}
+
+// SpentToday returns the estimated OpenAI spend of today in USD
+func (b *Budget) SpentToday() (float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	usage, err := b.readUsage()
+	if err != nil {
+		return 0, err
+	}
+	return usage.Cost, nil
+}
+
+// Price returns the price of an OpenAI model. The longest model name that
+// model starts with is used, so dated versions of a model have its price.
+// Configured prices replace the built-in ones.
+func (b *Budget) Price(model string) (config.ModelPrice, bool) {
+	var (
+		best  string
+		price config.ModelPrice
+	)
+	for _, prices := range []map[string]config.ModelPrice{defaultPrices, b.config.Prices} {
+		for name, p := range prices {
+			if strings.HasPrefix(model, name) && len(name) >= len(best) {
+				best, price = name, p
+			}
+		}
+	}
+	return price, best != ""
+}
+
+// EstimateCost returns the estimated cost in USD of sending tokens to an
+// OpenAI model
+func (b *Budget) EstimateCost(model string, tokens int) (float64, error) {
+	price, ok := b.Price(model)
+	if !ok {
+		return 0, fmt.Errorf("no price is known for the OpenAI model %s; set it in budget.prices", model)
+	}
+	return float64(tokens) * price.Input / 1e6, nil
+}
+
+// checkTokens fails requests with more estimated tokens than the budget allows
+func (b *Budget) checkTokens(tokens int) error {
+	if b.config.MaxRequestTokens > 0 && tokens > b.config.MaxRequestTokens {
+		return fmt.Errorf("%w: the request has about %d tokens, more than budget.max_request_tokens (%d)", ErrBudgetExceeded, tokens, b.config.MaxRequestTokens)
+	}
+	return nil
+}
+
+// countEmbeddingCall counts an embedding request, failing it when the index
+// run made as many as it is allowed to
+func (b *Budget) countEmbeddingCall() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.embeddingLimit > 0 && b.embeddingCalls >= b.embeddingLimit {
+		return fmt.Errorf("%w: the index run made %d embedding requests, the limit of budget.max_embedding_calls", ErrBudgetExceeded, b.embeddingLimit)
+	}
+	b.embeddingCalls++
+	return nil
+}
+
+// cost returns the estimated cost of a request to an OpenAI model
+func (b *Budget) cost(model string, inputTokens, outputTokens int) (float64, error) {
+	if b.config.MaxDailyCost <= 0 {
+		return 0, nil
+	}
+	price, ok := b.Price(model)
+	if !ok {
+		return 0, fmt.Errorf("no price is known for the OpenAI model %s; set it in budget.prices to use budget.max_daily_cost", model)
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1e6, nil
+}
+
+// reserve adds the estimated cost of a request to today's spend before it
+// is sent, failing the request when it would take the spend over the daily
+// budget. The check and the addition are one update of the usage file, so
+// commands running at once can't both spend the last of the budget.
+func (b *Budget) reserve(cost float64) error {
+	if b.config.MaxDailyCost <= 0 || cost <= 0 {
+		return nil
+	}
+	return b.updateUsage(func(usage *dailyUsage) error {
+		if usage.Cost+cost > b.config.MaxDailyCost {
+			return fmt.Errorf("%w: $%.4f of the $%.2f daily OpenAI budget (budget.max_daily_cost) is spent", ErrBudgetExceeded, usage.Cost, b.config.MaxDailyCost)
+		}
+		usage.Cost += cost
+		return nil
+	})
+}
+
+// settle replaces the reserved estimate of a request's cost in today's spend
+// with its actual cost, 0 for a request that failed
+func (b *Budget) settle(reserved, cost float64) error {
+	if reserved == cost {
+		return nil
+	}
+	return b.updateUsage(func(usage *dailyUsage) error {
+		usage.Cost = max(usage.Cost+cost-reserved, 0)
+		return nil
+	})
+}
+
+// updateUsage reads today's spend, updates it and writes it back while
+// holding the lock of the usage file, so the updates of commands running at
+// once aren't lost
+func (b *Budget) updateUsage(update func(usage *dailyUsage) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(b.usagePath), 0755); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	lock, err := os.OpenFile(b.usagePath+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open usage lock: %w", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("failed to lock usage: %w", err)
+	}
+	defer unlockFile(lock)
+
+	usage, err := b.readUsage()
+	if err != nil {
+		return err
+	}
+	if err := update(&usage); err != nil {
+		return err
+	}
+	return b.writeUsage(usage)
+}
+
+// readUsage reads today's spend. The spend of earlier days is discarded.
+func (b *Budget) readUsage() (dailyUsage, error) {
+	today := dailyUsage{Date: b.now().Format("2006-01-02")}
+
+	data, err := os.ReadFile(b.usagePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return today, nil
+	}
+	if err != nil {
+		return today, fmt.Errorf("failed to read usage: %w", err)
+	}
+
+	var usage dailyUsage
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return today, fmt.Errorf("failed to read usage from %s: %w", b.usagePath, err)
+	}
+	if usage.Date != today.Date {
+		return today, nil
+	}
+	return usage, nil
+}
+
+// writeUsage replaces the usage file
+func (b *Budget) writeUsage(usage dailyUsage) error {
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(b.usagePath), filepath.Base(b.usagePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	if err := os.Rename(temp.Name(), b.usagePath); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	return nil
+}
+
+// WrapClient enforces the budget on a chat client. Costs are only counted
+// when paid, using chatModel for requests without a model and embeddingModel
+// for embeddings.
+func (b *Budget) WrapClient(c Client, paid bool, chatModel, embeddingModel string) Client {
+	return &budgetClient{
+		Client:   c,
+		budget:   b,
+		paid:     paid,
+		model:    chatModel,
+		embedder: b.WrapEmbedder(c, paid, embeddingModel),
+	}
+}
+
+// WrapEmbedder enforces the budget on an embedder of model. Costs are only
+// counted when paid.
+func (b *Budget) WrapEmbedder(e Embedder, paid bool, model string) Embedder {
+	return &budgetEmbedder{
+		embedder: e,
+		budget:   b,
+		paid:     paid,
+		model:    model,
+	}
+}
+
+// WrapReranker enforces the budget on a reranker that uses model. Costs are
+// only counted when paid.
+func (b *Budget) WrapReranker(r Reranker, paid bool, model string) Reranker {
+	return &budgetReranker{
+		reranker: r,
+		budget:   b,
+		paid:     paid,
+		model:    model,
+	}
+}
+
+// budgetClient enforces a budget on chat requests
+type budgetClient struct {
+	Client
+	budget   *Budget
+	paid     bool
+	model    string
+	embedder Embedder
+}
+
+// Chat performs a chat completion within the budget
+func (c *budgetClient) Chat(ctx context.Context, model string, messages []Message, stream bool) (*ChatResponse, error) {
+	return c.guard(model, messages, func() (*ChatResponse, error) {
+		return c.Client.Chat(ctx, model, messages, stream)
+	})
+}
+
+// ChatStream performs a streamed chat completion within the budget
+func (c *budgetClient) ChatStream(ctx context.Context, model string, messages []Message, onChunk ChunkHandler) (*ChatResponse, error) {
+	return c.guard(model, messages, func() (*ChatResponse, error) {
+		return c.Client.ChatStream(ctx, model, messages, onChunk)
+	})
+}
+
+// GenerateEmbedding generates an embedding within the budget
+func (c *budgetClient) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
+	return c.embedder.GenerateEmbedding(ctx, text, inputType)
+}
+
+// GenerateEmbeddings generates embeddings within the budget
+func (c *budgetClient) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	return c.embedder.GenerateEmbeddings(ctx, texts, inputType)
+}
+
+// guard checks a chat request against the budget and reserves the cost of
+// its prompt before it is sent, and records its actual cost after
+func (c *budgetClient) guard(model string, messages []Message, send func() (*ChatResponse, error)) (*ChatResponse, error) {
+	if model == "" {
+		model = c.model
+	}
+
+	promptTokens := EstimateMessageTokens(messages)
+	if err := c.budget.checkTokens(promptTokens); err != nil {
+		return nil, err
+	}
+	var reserved float64
+	if c.paid {
+		var err error
+		if reserved, err = c.budget.cost(model, promptTokens, 0); err != nil {
+			return nil, err
+		}
+		if err := c.budget.reserve(reserved); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := send()
+	if !c.paid {
+		return response, err
+	}
+	if err != nil {
+		return nil, errors.Join(err, c.budget.settle(reserved, 0))
+	}
+
+	// Use the tokens reported by the backend, or estimate them
+	completionTokens := EstimateTokens(response.Message.Content)
+	if response.Usage != nil {
+		promptTokens, completionTokens = response.Usage.PromptTokens, response.Usage.CompletionTokens
+	}
+	cost, err := c.budget.cost(model, promptTokens, completionTokens)
+	if err == nil {
+		err = c.budget.settle(reserved, cost)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// budgetEmbedder enforces a budget on embedding requests
+type budgetEmbedder struct {
+	embedder Embedder
+	budget   *Budget
+	paid     bool
+	model    string
+}
+
+// GenerateEmbedding generates an embedding within the budget
+func (e *budgetEmbedder) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
+	tokens := EstimateTokens(text)
+	if err := e.budget.checkTokens(tokens); err != nil {
+		return nil, err
+	}
+	if err := e.budget.countEmbeddingCall(); err != nil {
+		return nil, err
+	}
+
+	var cost float64
+	if e.paid {
+		var err error
+		if cost, err = e.budget.cost(e.model, tokens, 0); err != nil {
+			return nil, err
+		}
+		if err := e.budget.reserve(cost); err != nil {
+			return nil, err
+		}
+	}
+
+	embedding, err := e.embedder.GenerateEmbedding(ctx, text, inputType)
+	if err != nil {
+		return nil, errors.Join(err, e.budget.settle(cost, 0))
+	}
+	return embedding, nil
+}
+
+// GenerateEmbeddings generates embeddings within the budget. The batch
+// counts as one embedding request, and each text is checked against the
+// request token limit on its own.
+func (e *budgetEmbedder) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	tokens := 0
+	for _, text := range texts {
+		textTokens := EstimateTokens(text)
+		if err := e.budget.checkTokens(textTokens); err != nil {
+			return nil, err
+		}
+		tokens += textTokens
+	}
+	if err := e.budget.countEmbeddingCall(); err != nil {
+		return nil, err
+	}
+
+	var cost float64
+	if e.paid {
+		var err error
+		if cost, err = e.budget.cost(e.model, tokens, 0); err != nil {
+			return nil, err
+		}
+		if err := e.budget.reserve(cost); err != nil {
+			return nil, err
+		}
+	}
+
+	embeddings, err := e.embedder.GenerateEmbeddings(ctx, texts, inputType)
+	if err != nil {
+		return nil, errors.Join(err, e.budget.settle(cost, 0))
+	}
+	return embeddings, nil
+}
+
+// budgetReranker enforces a budget on rerank requests
+type budgetReranker struct {
+	reranker Reranker
+	budget   *Budget
+	paid     bool
+	model    string
+}
+
+// Rerank reranks documents within the budget
+func (r *budgetReranker) Rerank(ctx context.Context, query string, documents []string, instruction string) ([]RerankResult, error) {
+	tokens := EstimateTokens(query)
+	for _, doc := range documents {
+		docTokens := EstimateTokens(doc)
+		if err := r.budget.checkTokens(docTokens); err != nil {
+			return nil, err
+		}
+		tokens += docTokens
+	}
+
+	var cost float64
+	if r.paid {
+		var err error
+		if cost, err = r.budget.cost(r.model, tokens, 0); err != nil {
+			return nil, err
+		}
+		if err := r.budget.reserve(cost); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := r.reranker.Rerank(ctx, query, documents, instruction)
+	if err != nil {
+		return nil, errors.Join(err, r.budget.settle(cost, 0))
+	}
+	return results, nil
+}
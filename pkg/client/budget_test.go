@@ -0,0 +1,275 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// fakeClient answers every request without a backend
+type fakeClient struct {
+	Client
+	answer     string
+	usage      *Usage
+	embeddings int
+}
+
+func (c *fakeClient) Chat(ctx context.Context, model string, messages []Message, stream bool) (*ChatResponse, error) {
+	return &ChatResponse{Model: model, Message: Message{Role: "assistant", Content: c.answer}, Usage: c.usage}, nil
+}
+
+func (c *fakeClient) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
+	c.embeddings++
+	return []float32{1, 0}, nil
+}
+
+func (c *fakeClient) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	c.embeddings++
+	embeddings := make([][]float32, len(texts))
+	for i := range texts {
+		embeddings[i] = []float32{1, 0}
+	}
+	return embeddings, nil
+}
+
+func newTestBudget(t *testing.T, cfg *config.BudgetConfig) *Budget {
+	t.Helper()
+	budget := NewBudget(cfg, filepath.Join(t.TempDir(), "usage.json"))
+	budget.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return budget
+}
+
+func TestBudgetMaxRequestTokens(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{MaxRequestTokens: 10})
+	embedder := budget.WrapEmbedder(&fakeClient{}, false, "")
+
+	if _, err := embedder.GenerateEmbedding(context.Background(), "short", InputTypeDocument); err != nil {
+		t.Fatalf("Expected a short text to be embedded: %v", err)
+	}
+	_, err := embedder.GenerateEmbedding(context.Background(), strings.Repeat("x", 100), InputTypeDocument)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded for a long text, got %v", err)
+	}
+}
+
+func TestBudgetEmbeddingBatch(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{MaxRequestTokens: 10})
+	backend := &fakeClient{}
+	embedder := budget.WrapEmbedder(backend, false, "")
+
+	// A batch is one request, and its texts are checked one at a time
+	budget.LimitEmbeddingCalls(1)
+	embeddings, err := embedder.GenerateEmbeddings(context.Background(), []string{"first", "second", "third"}, InputTypeDocument)
+	if err != nil {
+		t.Fatalf("Expected the batch to be embedded: %v", err)
+	}
+	if len(embeddings) != 3 {
+		t.Errorf("Expected 3 embeddings, got %d", len(embeddings))
+	}
+	if _, err := embedder.GenerateEmbeddings(context.Background(), []string{"fourth"}, InputTypeDocument); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded after one batch, got %v", err)
+	}
+
+	budget.LimitEmbeddingCalls(0)
+	if _, err := embedder.GenerateEmbeddings(context.Background(), []string{"short", strings.Repeat("x", 100)}, InputTypeDocument); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded for a long text in the batch, got %v", err)
+	}
+	if backend.embeddings != 1 {
+		t.Errorf("Expected 1 request to reach the backend, got %d", backend.embeddings)
+	}
+}
+
+func TestBudgetMaxEmbeddingCalls(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{})
+	backend := &fakeClient{}
+	embedder := budget.WrapEmbedder(backend, false, "")
+
+	budget.LimitEmbeddingCalls(2)
+	for i := 0; i < 2; i++ {
+		if _, err := embedder.GenerateEmbedding(context.Background(), "text", InputTypeDocument); err != nil {
+			t.Fatalf("Expected embedding %d to be allowed: %v", i+1, err)
+		}
+	}
+	if _, err := embedder.GenerateEmbedding(context.Background(), "text", InputTypeDocument); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded after 2 embeddings, got %v", err)
+	}
+	if backend.embeddings != 2 {
+		t.Errorf("Expected 2 requests to reach the backend, got %d", backend.embeddings)
+	}
+
+	// A new run starts counting again
+	budget.LimitEmbeddingCalls(2)
+	if _, err := embedder.GenerateEmbedding(context.Background(), "text", InputTypeDocument); err != nil {
+		t.Errorf("Expected the count to restart: %v", err)
+	}
+}
+
+func TestBudgetMaxDailyCost(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{
+		MaxDailyCost: 1.2,
+		Prices:       map[string]config.ModelPrice{"test-model": {Input: 100000, Output: 200000}},
+	})
+	backend := &fakeClient{answer: "answer", usage: &Usage{PromptTokens: 3, CompletionTokens: 1}}
+	chat := budget.WrapClient(backend, true, "test-model", "text-embedding-3-small")
+
+	// Requests are estimated at 6 prompt tokens, $0.60, and cost 3 prompt
+	// tokens at $0.10 and 1 completion token at $0.20
+	if _, err := chat.Chat(context.Background(), "", []Message{{Role: "user", Content: "question"}}, false); err != nil {
+		t.Fatalf("Expected the first request to be allowed: %v", err)
+	}
+	spent, err := budget.SpentToday()
+	if err != nil {
+		t.Fatalf("Failed to read spend: %v", err)
+	}
+	if math.Abs(spent-0.5) > 1e-9 {
+		t.Errorf("Expected $0.50 spent, got $%.4f", spent)
+	}
+
+	// The spend is kept between budgets sharing the usage file
+	again := NewBudget(budget.config, budget.usagePath)
+	again.now = budget.now
+	if spent, _ := again.SpentToday(); math.Abs(spent-0.5) > 1e-9 {
+		t.Errorf("Expected the spend to be read from the usage file, got $%.4f", spent)
+	}
+
+	if _, err := chat.Chat(context.Background(), "", []Message{{Role: "user", Content: "question"}}, false); err != nil {
+		t.Fatalf("Expected the second request to be allowed: %v", err)
+	}
+	if _, err := chat.Chat(context.Background(), "", []Message{{Role: "user", Content: "question"}}, false); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded once $1 is spent, got %v", err)
+	}
+
+	// The spend starts again the next day
+	budget.now = func() time.Time { return time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC) }
+	if _, err := chat.Chat(context.Background(), "", []Message{{Role: "user", Content: "question"}}, false); err != nil {
+		t.Errorf("Expected a request the next day to be allowed: %v", err)
+	}
+}
+
+func TestBudgetUnpaidBackends(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{MaxDailyCost: 0.000001})
+	chat := budget.WrapClient(&fakeClient{answer: strings.Repeat("x", 10000)}, false, "local-model", "local-embedding")
+
+	for i := 0; i < 3; i++ {
+		if _, err := chat.Chat(context.Background(), "", []Message{{Role: "user", Content: "question"}}, false); err != nil {
+			t.Fatalf("Expected requests to a free backend to be allowed: %v", err)
+		}
+	}
+	if spent, _ := budget.SpentToday(); spent != 0 {
+		t.Errorf("Expected nothing spent on a free backend, got $%.4f", spent)
+	}
+}
+
+func TestBudgetUnknownModel(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{MaxDailyCost: 1})
+	embedder := budget.WrapEmbedder(&fakeClient{}, true, "unknown-embedding")
+
+	_, err := embedder.GenerateEmbedding(context.Background(), "text", InputTypeDocument)
+	if err == nil || !strings.Contains(err.Error(), "budget.prices") {
+		t.Errorf("Expected an error naming budget.prices, got %v", err)
+	}
+}
+
+func TestBudgetPrice(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{
+		Prices: map[string]config.ModelPrice{"gpt-4o": {Input: 1, Output: 2}},
+	})
+
+	tests := []struct {
+		model string
+		input float64
+		found bool
+	}{
+		{"gpt-4o", 1, true},                    // Configured prices come first
+		{"gpt-4o-mini-2024-07-18", 0.15, true}, // The longest built-in prefix wins
+		{"text-embedding-3-small", 0.02, true},
+		{"llama3", 0, false},
+	}
+	for _, tt := range tests {
+		price, found := budget.Price(tt.model)
+		if found != tt.found || price.Input != tt.input {
+			t.Errorf("Price(%q) = %v, %t; want input %v, %t", tt.model, price, found, tt.input, tt.found)
+		}
+	}
+}
+
+func TestBudgetEstimateCost(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{})
+
+	cost, err := budget.EstimateCost("text-embedding-3-small", 2_000_000)
+	if err != nil {
+		t.Fatalf("Failed to estimate cost: %v", err)
+	}
+	if math.Abs(cost-0.04) > 1e-9 {
+		t.Errorf("Expected $0.04 for 2M tokens, got $%.4f", cost)
+	}
+
+	if _, err := budget.EstimateCost("unknown-embedding", 1000); err == nil {
+		t.Error("Expected an error for a model without a price")
+	}
+}
+
+func TestBudgetSharedByConcurrentCommands(t *testing.T) {
+	first := newTestBudget(t, &config.BudgetConfig{MaxDailyCost: 1})
+
+	// Budgets of commands running at once share the usage file
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		budget := NewBudget(first.config, first.usagePath)
+		budget.now = first.now
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := budget.reserve(0.1); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrBudgetExceeded) {
+				t.Errorf("Expected ErrBudgetExceeded, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 10 {
+		t.Errorf("Expected 10 of the requests to be allowed, got %d", allowed)
+	}
+	if spent, _ := first.SpentToday(); math.Abs(spent-1) > 1e-9 {
+		t.Errorf("Expected $1.00 spent, got $%.4f", spent)
+	}
+}
+
+func TestBudgetReleasesFailedRequests(t *testing.T) {
+	budget := newTestBudget(t, &config.BudgetConfig{
+		MaxDailyCost: 1,
+		Prices:       map[string]config.ModelPrice{"test-embedding": {Input: 100000}},
+	})
+	embedder := budget.WrapEmbedder(&failingEmbedder{}, true, "test-embedding")
+
+	if _, err := embedder.GenerateEmbedding(context.Background(), "text", InputTypeDocument); err == nil {
+		t.Fatal("Expected the embedder's error")
+	}
+	if spent, _ := budget.SpentToday(); spent != 0 {
+		t.Errorf("Expected the reserved cost of a failed request to be released, got $%.4f", spent)
+	}
+}
+
+// failingEmbedder fails every request
+type failingEmbedder struct{}
+
+func (failingEmbedder) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingEmbedder) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	return nil, errors.New("connection refused")
+}
@@ -1,10 +1,12 @@
 package client
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"math"
@@ -25,7 +27,9 @@ func New(cfg *config.Config) (Client, error) {
 	}
 }
 
-// NewEmbedder creates a new embedder based on the embedding backend configuration
+// NewEmbedder creates a new embedder based on the embedding backend
+// configuration, prefixing texts with embedding.query_prefix and
+// embedding.document_prefix
 func NewEmbedder(cfg *config.Config) (Embedder, error) {
 	// Use embedding backend if specified, otherwise fall back to chat backend
 	embeddingBackend := cfg.EmbeddingBackend
@@ -33,27 +37,65 @@ func NewEmbedder(cfg *config.Config) (Embedder, error) {
 		embeddingBackend = cfg.ChatBackend
 	}
 
+	var embedder Embedder
+	var err error
 	switch embeddingBackend {
 	case "ollama":
-		return NewOllama(&cfg.Ollama)
+		embedder, err = NewOllama(&cfg.Ollama)
 	case "openai":
-		return NewOpenAI(&cfg.OpenAI)
+		embedder, err = NewOpenAI(&cfg.OpenAI)
 	default:
 		return nil, fmt.Errorf("unsupported embedding backend: %s", embeddingBackend)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Instruction-tuned models may expect queries and documents to be marked
+	return withPrefixes(embedder, &cfg.Embedding), nil
 }
 
-// NewReranker creates a new reranker based on the embedding backend configuration
+// NewReranker creates a new reranker based on the rerank configuration.
+// The rerank backend defaults to the embedding backend. Passages are scored
+// with the embeddings of rerank.model, or else of the backend's reranker
+// model, or else of its embedding model. In llm mode,
+// passages are scored by the backend's chat model instead, and in
+// cross-encoder mode by a dedicated reranker model.
 func NewReranker(cfg *config.Config) (Reranker, error) {
-	// Use embedding backend if specified, otherwise fall back to chat backend
-	embeddingBackend := cfg.EmbeddingBackend
-	if embeddingBackend == "" {
-		embeddingBackend = cfg.ChatBackend
+	rerankBackend := cfg.Rerank.Backend
+	if rerankBackend == "" {
+		rerankBackend = cfg.EmbeddingBackend
+	}
+	if rerankBackend == "" {
+		rerankBackend = cfg.ChatBackend
 	}
 
-	switch embeddingBackend {
+	if cfg.Rerank.GetMethod() == config.RerankMethodCrossEncoder {
+		return newCrossEncoderReranker(cfg, rerankBackend)
+	}
+
+	if cfg.Rerank.GetMethod() == config.RerankMethodLLM {
+		var client Client
+		var err error
+		switch rerankBackend {
+		case "ollama":
+			client, err = NewOllama(&cfg.Ollama)
+		case "openai":
+			client, err = NewOpenAI(&cfg.OpenAI)
+		default:
+			return nil, fmt.Errorf("unsupported rerank backend: %s", rerankBackend)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewLLMReranker(client, cfg.Rerank.Model, cfg.Rerank.GetConcurrency()), nil
+	}
+
+	switch rerankBackend {
 	case "ollama":
-		client, err := NewOllama(&cfg.Ollama)
+		ollamaCfg := cfg.Ollama
+		ollamaCfg.EmbeddingModel = cmp.Or(cfg.Rerank.Model, cfg.Ollama.RerankerModel, cfg.Ollama.EmbeddingModel)
+		client, err := NewOllama(&ollamaCfg)
 		if err != nil {
 			return nil, err
 		}
@@ -63,7 +105,9 @@ func NewReranker(cfg *config.Config) (Reranker, error) {
 		}
 		return nil, fmt.Errorf("OllamaClient does not implement Reranker interface")
 	case "openai":
-		client, err := NewOpenAI(&cfg.OpenAI)
+		openaiCfg := cfg.OpenAI
+		openaiCfg.EmbeddingModel = cmp.Or(cfg.Rerank.Model, cfg.OpenAI.RerankerModel, cfg.OpenAI.EmbeddingModel)
+		client, err := NewOpenAI(&openaiCfg)
 		if err != nil {
 			return nil, err
 		}
@@ -73,10 +117,46 @@ func NewReranker(cfg *config.Config) (Reranker, error) {
 		}
 		return nil, fmt.Errorf("OpenAIClient does not implement Reranker interface")
 	default:
-		return nil, fmt.Errorf("unsupported embedding backend: %s", embeddingBackend)
+		return nil, fmt.Errorf("unsupported rerank backend: %s", rerankBackend)
 	}
 }
 
+// newCrossEncoderReranker creates a reranker for the rerank endpoint of a
+// backend: Cohere's Rerank API, Ollama's /api/rerank, or the /rerank endpoint
+// of an OpenAI-compatible server. rerank.base_url replaces the backend's URL.
+func newCrossEncoderReranker(cfg *config.Config, backend string) (Reranker, error) {
+	model := cfg.Rerank.Model
+	apiKey := cfg.Rerank.APIKey
+	var baseURL, path string
+	switch backend {
+	case "cohere":
+		baseURL, path = "https://api.cohere.com", "/v2/rerank"
+		if model == "" {
+			model = config.DefaultCohereRerankModel
+		}
+	case "ollama":
+		baseURL, path = cfg.Ollama.GetServerURL(), "/api/rerank"
+		if model == "" {
+			model = cfg.Ollama.RerankerModel
+		}
+	case "openai":
+		baseURL, path = cfg.OpenAI.GetBaseURL(), "/rerank"
+		if apiKey == "" {
+			apiKey = cfg.OpenAI.APIKey
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rerank backend: %s", backend)
+	}
+	if cfg.Rerank.BaseURL != "" {
+		baseURL = cfg.Rerank.BaseURL
+	}
+	if model == "" {
+		return nil, fmt.Errorf("no reranker model set for %s; set rerank.model", backend)
+	}
+
+	return NewCrossEncoderReranker(strings.TrimSuffix(baseURL, "/")+path, apiKey, model), nil
+}
+
 // NewOllama creates a new Ollama client
 func NewOllama(cfg *config.OllamaConfig) (Client, error) {
 	serverURL, err := url.Parse(cfg.GetServerURL())
@@ -99,13 +179,17 @@ func NewOllama(cfg *config.OllamaConfig) (Client, error) {
 }
 
 // GenerateEmbedding generates embeddings for the given text
-func (c *OllamaClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+func (c *OllamaClient) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
 	request := &api.EmbeddingRequest{
 		Model:  c.config.EmbeddingModel,
 		Prompt: text,
 	}
 
-	response, err := c.client.Embeddings(ctx, request)
+	var response *api.EmbeddingResponse
+	err := c.retry(ctx, func() (err error) {
+		response, err = c.client.Embeddings(ctx, request)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate embedding: %w", err)
 	}
@@ -119,6 +203,33 @@ func (c *OllamaClient) GenerateEmbedding(ctx context.Context, text string) ([]fl
 	return embedding, nil
 }
 
+// GenerateEmbeddings generates embeddings for the given texts with a single
+// request to the /api/embed endpoint
+func (c *OllamaClient) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+
+	request := &api.EmbedRequest{
+		Model: c.config.EmbeddingModel,
+		Input: texts,
+	}
+
+	var response *api.EmbedResponse
+	err := c.retry(ctx, func() (err error) {
+		response, err = c.client.Embed(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(response.Embeddings))
+	}
+
+	return response.Embeddings, nil
+}
+
 // Rerank reranks documents using the reranker model
 func (c *OllamaClient) Rerank(ctx context.Context, query string, documents []string, instruction string) ([]RerankResult, error) {
 	if len(documents) == 0 {
@@ -127,14 +238,14 @@ func (c *OllamaClient) Rerank(ctx context.Context, query string, documents []str
 
 	// For Ollama, we'll use a simple approach by generating embeddings for query and documents
 	// and computing cosine similarity as a reranking score
-	queryEmbedding, err := c.GenerateEmbedding(ctx, query)
+	queryEmbedding, err := c.GenerateEmbedding(ctx, query, InputTypeQuery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
 	var results []RerankResult
 	for i, doc := range documents {
-		docEmbedding, err := c.GenerateEmbedding(ctx, doc)
+		docEmbedding, err := c.GenerateEmbedding(ctx, doc, InputTypeDocument)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate document embedding: %w", err)
 		}
@@ -213,8 +324,28 @@ func cosineSimilarity(a, b []float32) float32 {
 	return float32(similarity)
 }
 
+// retry calls fn until Ollama answers, waiting up to the reconnect timeout
+// while the server is down or reloading
+func (c *OllamaClient) retry(ctx context.Context, fn func() error) error {
+	return retryUnavailable(ctx, c.config.GetReconnectTimeout(), fn)
+}
+
 // Chat performs a chat completion with the specified model
 func (c *OllamaClient) Chat(ctx context.Context, model string, messages []Message, stream bool) (*ChatResponse, error) {
+	if stream {
+		return c.ChatStream(ctx, model, messages, nil)
+	}
+	return c.chat(ctx, model, messages, false, nil)
+}
+
+// ChatStream performs a streamed chat completion with the specified model
+func (c *OllamaClient) ChatStream(ctx context.Context, model string, messages []Message, onChunk ChunkHandler) (*ChatResponse, error) {
+	return c.chat(ctx, model, messages, true, onChunk)
+}
+
+// chat sends a chat request. Streamed answers arrive in pieces that are
+// passed to onChunk and joined into the returned response.
+func (c *OllamaClient) chat(ctx context.Context, model string, messages []Message, stream bool, onChunk ChunkHandler) (*ChatResponse, error) {
 	if model == "" {
 		model = c.config.ChatModel
 	}
@@ -233,23 +364,54 @@ func (c *OllamaClient) Chat(ctx context.Context, model string, messages []Messag
 		Messages: ollamaMessages,
 		Stream:   &stream,
 	}
+	if options := chatOptions(ctx); options.Temperature != nil || options.MaxTokens > 0 {
+		req.Options = make(map[string]interface{})
+		if options.Temperature != nil {
+			req.Options["temperature"] = *options.Temperature
+		}
+		if options.MaxTokens > 0 {
+			req.Options["num_predict"] = options.MaxTokens
+		}
+	}
 
+	// A request is only retried until part of the answer was streamed
 	var resp *api.ChatResponse
-	err := c.client.Chat(ctx, req, func(response api.ChatResponse) error {
-		resp = &response
-		return nil
+	var content strings.Builder
+	err := c.retry(ctx, func() error {
+		resp = nil
+		content.Reset()
+		err := c.client.Chat(ctx, req, func(response api.ChatResponse) error {
+			resp = &response
+			content.WriteString(response.Message.Content)
+			if onChunk != nil && response.Message.Content != "" {
+				return onChunk(response.Message.Content)
+			}
+			return nil
+		})
+		if err != nil && onChunk != nil && content.Len() > 0 {
+			return interruptedError{err}
+		}
+		return err
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to chat: %w", err)
 	}
+	if resp == nil {
+		return nil, fmt.Errorf("failed to chat: empty response")
+	}
+
+	role := resp.Message.Role
+	if role == "" {
+		role = "assistant"
+	}
 
 	// Convert Ollama response to our generic response
 	return &ChatResponse{
 		Model:     resp.Model,
 		CreatedAt: resp.CreatedAt,
 		Message: Message{
-			Role:    resp.Message.Role,
-			Content: resp.Message.Content,
+			Role:    role,
+			Content: content.String(),
 		},
 		Done: resp.Done,
 	}, nil
@@ -281,9 +443,11 @@ func (c *OllamaClient) Generate(ctx context.Context, model string, prompt string
 	}
 
 	var resp *api.GenerateResponse
-	err := c.client.Generate(ctx, req, func(response api.GenerateResponse) error {
-		resp = &response
-		return nil
+	err := c.retry(ctx, func() error {
+		return c.client.Generate(ctx, req, func(response api.GenerateResponse) error {
+			resp = &response
+			return nil
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate: %w", err)
@@ -297,3 +461,37 @@ func (c *OllamaClient) Generate(ctx context.Context, model string, prompt string
 		Done:      resp.Done,
 	}, nil
 }
+
+// PreloadChatModel loads the chat model with an empty generate request,
+// which Ollama answers once the model is in memory
+func (c *OllamaClient) PreloadChatModel(ctx context.Context, keepAlive time.Duration) error {
+	req := &api.GenerateRequest{
+		Model:     c.config.ChatModel,
+		KeepAlive: &api.Duration{Duration: keepAlive},
+	}
+	err := c.retry(ctx, func() error {
+		return c.client.Generate(ctx, req, func(api.GenerateResponse) error { return nil })
+	})
+	if err != nil {
+		return fmt.Errorf("failed to preload chat model %s: %w", c.config.ChatModel, err)
+	}
+	return nil
+}
+
+// PreloadEmbeddingModel loads the embedding model with an empty embed
+// request, which Ollama answers once the model is in memory
+func (c *OllamaClient) PreloadEmbeddingModel(ctx context.Context, keepAlive time.Duration) error {
+	req := &api.EmbedRequest{
+		Model:     c.config.EmbeddingModel,
+		Input:     []string{},
+		KeepAlive: &api.Duration{Duration: keepAlive},
+	}
+	err := c.retry(ctx, func() error {
+		_, err := c.client.Embed(ctx, req)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to preload embedding model %s: %w", c.config.EmbeddingModel, err)
+	}
+	return nil
+}
@@ -1,7 +1,15 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/busybytelab.com/rag-cli/pkg/config"
 )
@@ -93,6 +101,51 @@ func TestNewEmbedderInvalidBackend(t *testing.T) {
 	}
 }
 
+func TestNewRerankerModel(t *testing.T) {
+	cfg := &config.Config{
+		EmbeddingBackend: "ollama",
+		Ollama:           config.OllamaConfig{Host: "localhost", Port: 11434, EmbeddingModel: "embedder"},
+		OpenAI:           config.OpenAIConfig{APIKey: "openai-key", EmbeddingModel: "text-embedding-3-large"},
+	}
+
+	// Passages are embedded with rerank.model, then the backend's reranker
+	// model, then its embedding model
+	tests := []struct {
+		backend, rerankModel, rerankerModel, want string
+	}{
+		{"ollama", "", "", "embedder"},
+		{"ollama", "", "qwen3-reranker", "qwen3-reranker"},
+		{"ollama", "override", "qwen3-reranker", "override"},
+		{"openai", "", "", "text-embedding-3-large"},
+		{"openai", "", "text-embedding-3-small", "text-embedding-3-small"},
+	}
+	for _, tt := range tests {
+		cfg.Rerank.Backend = tt.backend
+		cfg.Rerank.Model = tt.rerankModel
+		cfg.Ollama.RerankerModel = tt.rerankerModel
+		cfg.OpenAI.RerankerModel = tt.rerankerModel
+		reranker, err := NewReranker(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create %s reranker: %v", tt.backend, err)
+		}
+		var got string
+		switch r := reranker.(type) {
+		case *OllamaClient:
+			got = r.config.EmbeddingModel
+		case *OpenAIClient:
+			got = r.config.EmbeddingModel
+		}
+		if got != tt.want {
+			t.Errorf("%s with rerank.model %q and reranker_model %q: expected %s, got %s", tt.backend, tt.rerankModel, tt.rerankerModel, tt.want, got)
+		}
+	}
+
+	// The configured models are left as they are
+	if cfg.Ollama.EmbeddingModel != "embedder" {
+		t.Errorf("Expected the Ollama embedding model to be unchanged, got %s", cfg.Ollama.EmbeddingModel)
+	}
+}
+
 func TestCosineSimilarity(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -188,3 +241,331 @@ func abs(x float32) float32 {
 	}
 	return x
 }
+
+func TestProviderCreatesClientsLazily(t *testing.T) {
+	cfg := &config.Config{
+		ChatBackend:      "openai",
+		EmbeddingBackend: "ollama",
+		Ollama: config.OllamaConfig{
+			Host:           "localhost",
+			Port:           11434,
+			EmbeddingModel: "dengcao/Qwen3-Embedding-0.6B:Q8_0",
+		},
+		Embedding: config.EmbeddingConfig{
+			ChunkSize:           1000,
+			ChunkOverlap:        200,
+			SimilarityThreshold: 0.7,
+			MaxResults:          10,
+			Dimensions:          1024,
+		},
+	}
+
+	provider := NewProvider(cfg)
+
+	// The embedder only needs the ollama embedding settings
+	embedder, err := provider.Embedder()
+	if err != nil {
+		t.Fatalf("Failed to create embedder: %v", err)
+	}
+	again, err := provider.Embedder()
+	if err != nil {
+		t.Fatalf("Failed to get embedder: %v", err)
+	}
+	if embedder != again {
+		t.Error("Expected provider to reuse the embedder it created")
+	}
+
+	// The chat backend is only validated when a chat client is requested
+	if _, err := provider.Chat(); err == nil {
+		t.Error("Expected chat client creation to fail without OpenAI settings")
+	}
+}
+
+func TestOllamaChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, piece := range []string{"Hello", ", ", "world"} {
+			fmt.Fprintf(w, `{"model":"test","message":{"role":"assistant","content":%q},"done":false}`+"\n", piece)
+		}
+		fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":""},"done":true}`)
+	}))
+	defer server.Close()
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to parse server address: %v", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	chat, err := NewOllama(&config.OllamaConfig{Host: host, Port: port, ChatModel: "test"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	var chunks []string
+	response, err := chat.ChatStream(context.Background(), "", []Message{{Role: "user", Content: "Hi"}}, func(content string) error {
+		chunks = append(chunks, content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream chat: %v", err)
+	}
+
+	if len(chunks) != 3 {
+		t.Errorf("Expected 3 chunks, got %d: %q", len(chunks), chunks)
+	}
+	if response.Message.Content != "Hello, world" {
+		t.Errorf("Expected the chunks to be joined, got %q", response.Message.Content)
+	}
+	if response.Message.Role != "assistant" || !response.Done {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	// A failing chunk handler stops the stream
+	_, err = chat.ChatStream(context.Background(), "", []Message{{Role: "user", Content: "Hi"}}, func(content string) error {
+		return fmt.Errorf("client went away")
+	})
+	if err == nil {
+		t.Error("Expected an error when the chunk handler fails")
+	}
+}
+
+func TestOllamaPreload(t *testing.T) {
+	requests := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests[r.URL.Path] = body
+		switch r.URL.Path {
+		case "/api/generate":
+			fmt.Fprintln(w, `{"model":"chat","response":"","done":true}`)
+		case "/api/embed":
+			fmt.Fprintln(w, `{"model":"embed","embeddings":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to parse server address: %v", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	c, err := NewOllama(&config.OllamaConfig{Host: host, Port: port, ChatModel: "chat", EmbeddingModel: "embed"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	preloader, ok := c.(Preloader)
+	if !ok {
+		t.Fatal("Expected the Ollama client to preload models")
+	}
+
+	if err := preloader.PreloadChatModel(context.Background(), 10*time.Minute); err != nil {
+		t.Fatalf("Failed to preload chat model: %v", err)
+	}
+	if err := preloader.PreloadEmbeddingModel(context.Background(), 10*time.Minute); err != nil {
+		t.Fatalf("Failed to preload embedding model: %v", err)
+	}
+
+	generate := requests["/api/generate"]
+	if generate["model"] != "chat" || generate["keep_alive"] == nil || generate["prompt"] != "" {
+		t.Errorf("Unexpected generate request: %v", generate)
+	}
+	embed := requests["/api/embed"]
+	if embed["model"] != "embed" || embed["keep_alive"] == nil {
+		t.Errorf("Unexpected embed request: %v", embed)
+	}
+}
+
+func TestOllamaRetriesUnavailableServer(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// The first requests arrive while the model is reloading
+		if requests <= 2 {
+			http.Error(w, `{"error":"model is loading"}`, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, `{"model":"embed","embeddings":[[0.5,0.5]]}`)
+	}))
+	defer server.Close()
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to parse server address: %v", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	c, err := NewOllama(&config.OllamaConfig{Host: host, Port: port, EmbeddingModel: "embed", ReconnectTimeout: "5s"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	embeddings, err := c.GenerateEmbeddings(context.Background(), []string{"text"}, InputTypeDocument)
+	if err != nil {
+		t.Fatalf("Expected the request to be retried, got: %v", err)
+	}
+	if requests != 3 || len(embeddings) != 1 {
+		t.Errorf("Expected 3 requests and 1 embedding, got %d and %d", requests, len(embeddings))
+	}
+
+	// Without a reconnect timeout, the request fails at once
+	requests = 0
+	c, err = NewOllama(&config.OllamaConfig{Host: host, Port: port, EmbeddingModel: "embed", ReconnectTimeout: "0s"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := c.GenerateEmbeddings(context.Background(), []string{"text"}, InputTypeDocument); err == nil {
+		t.Error("Expected an error without a reconnect timeout")
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 request, got %d", requests)
+	}
+}
+
+// textRecorder records the texts it embeds
+type textRecorder struct {
+	texts []string
+}
+
+func (r *textRecorder) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
+	r.texts = append(r.texts, text)
+	return []float32{1}, nil
+}
+
+func (r *textRecorder) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	r.texts = append(r.texts, texts...)
+	return make([][]float32, len(texts)), nil
+}
+
+func TestPrefixEmbedder(t *testing.T) {
+	recorder := &textRecorder{}
+	if embedder := withPrefixes(recorder, &config.EmbeddingConfig{}); embedder != recorder {
+		t.Error("Expected an embedder without prefixes not to be wrapped")
+	}
+
+	embedder := withPrefixes(recorder, &config.EmbeddingConfig{QueryPrefix: "search_query: ", DocumentPrefix: "search_document: "})
+	if _, err := embedder.GenerateEmbedding(context.Background(), "backups", InputTypeQuery); err != nil {
+		t.Fatalf("Failed to embed query: %v", err)
+	}
+	if _, err := embedder.GenerateEmbeddings(context.Background(), []string{"one", "two"}, InputTypeDocument); err != nil {
+		t.Fatalf("Failed to embed documents: %v", err)
+	}
+
+	want := []string{"search_query: backups", "search_document: one", "search_document: two"}
+	if fmt.Sprint(recorder.texts) != fmt.Sprint(want) {
+		t.Errorf("Expected texts %q, got %q", want, recorder.texts)
+	}
+}
+
+func TestOpenAIInputTypeField(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"object":"list","model":"embed","data":[{"object":"embedding","index":0,"embedding":[0.5,0.5]}]}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.OpenAIConfig{APIKey: "key", BaseURL: server.URL, EmbeddingModel: "embed"}
+	c, err := NewOpenAI(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// The field isn't sent unless it is configured
+	if _, err := c.GenerateEmbedding(context.Background(), "text", InputTypeQuery); err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	cfg.InputTypeField, cfg.QueryInputType, cfg.DocumentInputType = "input_type", "search_query", "search_document"
+	if _, err := c.GenerateEmbedding(context.Background(), "text", InputTypeQuery); err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if _, err := c.GenerateEmbeddings(context.Background(), []string{"text"}, InputTypeDocument); err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(bodies))
+	}
+	if _, ok := bodies[0]["input_type"]; ok {
+		t.Errorf("Expected no input type without input_type_field, got %v", bodies[0])
+	}
+	if bodies[1]["input_type"] != "search_query" || bodies[2]["input_type"] != "search_document" {
+		t.Errorf("Expected query and document input types, got %v and %v", bodies[1]["input_type"], bodies[2]["input_type"])
+	}
+}
+
+func TestChatOptions(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		bodies = append(bodies, body)
+		if r.URL.Path == "/api/chat" {
+			fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":"Hi"},"done":true}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"id":"1","object":"chat.completion","created":1,"model":"test","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer server.Close()
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to parse server address: %v", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	ollama, err := NewOllama(&config.OllamaConfig{Host: host, Port: port, ChatModel: "test"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	openAI, err := NewOpenAI(&config.OpenAIConfig{APIKey: "key", BaseURL: server.URL, ChatModel: "test"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	temperature := 0.2
+	ctx := WithChatOptions(context.Background(), ChatOptions{Temperature: &temperature, MaxTokens: 100})
+	messages := []Message{{Role: "user", Content: "Hi"}}
+	for _, c := range []Client{ollama, openAI} {
+		if _, err := c.Chat(context.Background(), "", messages, false); err != nil {
+			t.Fatalf("Failed to chat: %v", err)
+		}
+		if _, err := c.Chat(ctx, "", messages, false); err != nil {
+			t.Fatalf("Failed to chat: %v", err)
+		}
+	}
+
+	if len(bodies) != 4 {
+		t.Fatalf("Expected 4 requests, got %d", len(bodies))
+	}
+	// Requests without options leave the model's defaults
+	if bodies[0]["options"] != nil {
+		t.Errorf("Expected no Ollama options, got %v", bodies[0]["options"])
+	}
+	if _, ok := bodies[2]["temperature"]; ok {
+		t.Errorf("Expected no OpenAI temperature, got %v", bodies[2])
+	}
+	options, _ := bodies[1]["options"].(map[string]interface{})
+	if options["temperature"] != 0.2 || options["num_predict"] != float64(100) {
+		t.Errorf("Expected the Ollama options to be sent, got %v", bodies[1]["options"])
+	}
+	if bodies[3]["temperature"] != 0.2 || bodies[3]["max_completion_tokens"] != float64(100) {
+		t.Errorf("Expected the OpenAI options to be sent, got %v", bodies[3])
+	}
+}
@@ -0,0 +1,325 @@
+// Package clienttest provides fakes of the LLM clients for tests that
+// shouldn't need a running model server.
+package clienttest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+)
+
+// DefaultDimensions is the size of the embeddings of a Client without dimensions
+const DefaultDimensions = 64
+
+// Call is a request made to a fake client
+type Call struct {
+	Method string // Name of the client method, e.g. Chat
+	Model  string
+	Input  string // Text embedded, or content of the last message or prompt
+
+	InputType client.InputType // Whether the text embedded is a query or a document
+}
+
+// Client is a client.Client answering from memory. Texts are embedded with
+// HashEmbedding unless they have an embedding in Embeddings, so texts sharing
+// words get similar embeddings. Chat and generate requests are answered by
+// Reply. Every request is recorded, and its zero value is ready to use.
+type Client struct {
+	// Dimensions is the size of the embeddings (DefaultDimensions when 0)
+	Dimensions int
+
+	// Embeddings are the embeddings of specific texts
+	Embeddings map[string][]float32
+
+	// Reply answers chat and generate requests; the content of the last
+	// message is echoed when nil
+	Reply func(messages []client.Message) (string, error)
+
+	// Err is returned by every request when set
+	Err error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var (
+	_ client.Client    = (*Client)(nil)
+	_ client.Preloader = (*Client)(nil)
+)
+
+// NewClient creates a fake client that answers every chat and generate
+// request with the given replies in turn, repeating the last one
+func NewClient(replies ...string) *Client {
+	c := &Client{}
+	if len(replies) > 0 {
+		next := 0
+		c.Reply = func([]client.Message) (string, error) {
+			reply := replies[next]
+			if next < len(replies)-1 {
+				next++
+			}
+			return reply, nil
+		}
+	}
+	return c
+}
+
+// Calls returns the requests made so far, in order
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// CallCount returns the number of requests made with the given method
+func (c *Client) CallCount(method string) int {
+	count := 0
+	for _, call := range c.Calls() {
+		if call.Method == method {
+			count++
+		}
+	}
+	return count
+}
+
+// record adds a request to the calls
+func (c *Client) record(method, model, input string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Model: model, Input: input})
+}
+
+// recordEmbedding adds an embedding request to the calls
+func (c *Client) recordEmbedding(method, input string, inputType client.InputType) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Input: input, InputType: inputType})
+}
+
+// GenerateEmbedding returns the embedding of the text
+func (c *Client) GenerateEmbedding(ctx context.Context, text string, inputType client.InputType) ([]float32, error) {
+	c.recordEmbedding("GenerateEmbedding", text, inputType)
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
+	return c.embed(text), nil
+}
+
+// GenerateEmbeddings returns the embeddings of the texts, recording the batch
+// as a single request with the texts joined by newlines
+func (c *Client) GenerateEmbeddings(ctx context.Context, texts []string, inputType client.InputType) ([][]float32, error) {
+	c.recordEmbedding("GenerateEmbeddings", strings.Join(texts, "\n"), inputType)
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = c.embed(text)
+	}
+	return embeddings, nil
+}
+
+// Chat answers the messages with Reply
+func (c *Client) Chat(ctx context.Context, model string, messages []client.Message, stream bool) (*client.ChatResponse, error) {
+	c.record("Chat", model, lastContent(messages))
+	content, err := c.reply(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return response(model, content, messages), nil
+}
+
+// ChatStream answers the messages with Reply, passing it to onChunk one word
+// at a time
+func (c *Client) ChatStream(ctx context.Context, model string, messages []client.Message, onChunk client.ChunkHandler) (*client.ChatResponse, error) {
+	c.record("ChatStream", model, lastContent(messages))
+	content, err := c.reply(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range strings.SplitAfter(content, " ") {
+		if chunk == "" {
+			continue
+		}
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return response(model, content, messages), nil
+}
+
+// Generate answers the prompt with Reply, as a single user message
+func (c *Client) Generate(ctx context.Context, model string, prompt string, options map[string]interface{}) (*client.GenerateResponse, error) {
+	c.record("Generate", model, prompt)
+	content, err := c.reply(ctx, []client.Message{{Role: "user", Content: prompt}})
+	if err != nil {
+		return nil, err
+	}
+	return &client.GenerateResponse{Model: model, CreatedAt: time.Now(), Response: content, Done: true}, nil
+}
+
+// PreloadChatModel records the request
+func (c *Client) PreloadChatModel(ctx context.Context, keepAlive time.Duration) error {
+	c.record("PreloadChatModel", "", "")
+	return c.check(ctx)
+}
+
+// PreloadEmbeddingModel records the request
+func (c *Client) PreloadEmbeddingModel(ctx context.Context, keepAlive time.Duration) error {
+	c.record("PreloadEmbeddingModel", "", "")
+	return c.check(ctx)
+}
+
+// check returns the error requests fail with, if any
+func (c *Client) check(ctx context.Context) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	return ctx.Err()
+}
+
+// reply returns the answer to the messages
+func (c *Client) reply(ctx context.Context, messages []client.Message) (string, error) {
+	if err := c.check(ctx); err != nil {
+		return "", err
+	}
+	if c.Reply == nil {
+		return lastContent(messages), nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Reply(messages)
+}
+
+// embed returns the embedding of a text
+func (c *Client) embed(text string) []float32 {
+	if embedding, ok := c.Embeddings[text]; ok {
+		return append([]float32(nil), embedding...)
+	}
+	dimensions := c.Dimensions
+	if dimensions <= 0 {
+		dimensions = DefaultDimensions
+	}
+	return HashEmbedding(text, dimensions)
+}
+
+// lastContent returns the content of the last message
+func lastContent(messages []client.Message) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	return messages[len(messages)-1].Content
+}
+
+// response builds a chat response, with the usage estimated from the text
+func response(model, content string, messages []client.Message) *client.ChatResponse {
+	prompt := 0
+	for _, message := range messages {
+		prompt += client.EstimateTokens(message.Content)
+	}
+	return &client.ChatResponse{
+		Model:     model,
+		CreatedAt: time.Now(),
+		Message:   client.Message{Role: "assistant", Content: content},
+		Done:      true,
+		Usage:     &client.Usage{PromptTokens: prompt, CompletionTokens: client.EstimateTokens(content)},
+	}
+}
+
+// HashEmbedding returns a unit-length embedding of the text in which every
+// lowercased word adds to a dimension picked by its hash, so texts sharing
+// words are similar. Texts without words get the first unit vector.
+func HashEmbedding(text string, dimensions int) []float32 {
+	embedding := make([]float32, dimensions)
+	textWords := words(text)
+	if len(textWords) == 0 {
+		embedding[0] = 1
+		return embedding
+	}
+
+	for _, word := range textWords {
+		sum := sha256.Sum256([]byte(word))
+		embedding[binary.BigEndian.Uint64(sum[:8])%uint64(dimensions)]++
+	}
+
+	var norm float64
+	for _, v := range embedding {
+		norm += float64(v) * float64(v)
+	}
+	norm = math.Sqrt(norm)
+	for i := range embedding {
+		embedding[i] = float32(float64(embedding[i]) / norm)
+	}
+	return embedding
+}
+
+// Reranker is a client.Reranker that scores documents from memory. Documents
+// without a score are scored by the share of the query's words they contain.
+type Reranker struct {
+	// Scores are the scores of specific documents, from 0 to 1
+	Scores map[string]float64
+
+	// Err is returned by Rerank when set
+	Err error
+}
+
+var _ client.Reranker = (*Reranker)(nil)
+
+// Rerank scores the documents and returns them from most to least relevant
+func (r *Reranker) Rerank(ctx context.Context, query string, documents []string, instruction string) ([]client.RerankResult, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	results := make([]client.RerankResult, len(documents))
+	for i, doc := range documents {
+		score, ok := r.Scores[doc]
+		if !ok {
+			score = WordOverlap(query, doc)
+		}
+		results[i] = client.RerankResult{Document: doc, Score: score}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+	return results, nil
+}
+
+// WordOverlap returns the share of the query's distinct words, lowercased,
+// that the text contains
+func WordOverlap(query, text string) float64 {
+	queryWords := make(map[string]bool)
+	for _, word := range words(query) {
+		queryWords[word] = true
+	}
+	if len(queryWords) == 0 {
+		return 0
+	}
+
+	found := make(map[string]bool)
+	for _, word := range words(text) {
+		if queryWords[word] {
+			found[word] = true
+		}
+	}
+	return float64(len(found)) / float64(len(queryWords))
+}
+
+// words splits a text into its lowercased words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,118 @@
+package clienttest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+)
+
+func TestClientReplies(t *testing.T) {
+	c := NewClient("first", "second answer")
+	ctx := context.Background()
+	messages := []client.Message{{Role: "user", Content: "question"}}
+
+	response, err := c.Chat(ctx, "model", messages, false)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if response.Message.Content != "first" {
+		t.Errorf("Expected the first reply, got %q", response.Message.Content)
+	}
+
+	var chunks []string
+	response, err = c.ChatStream(ctx, "model", messages, func(content string) error {
+		chunks = append(chunks, content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+	if strings.Join(chunks, "") != "second answer" || len(chunks) != 2 {
+		t.Errorf("Expected the second reply in two chunks, got %q", chunks)
+	}
+
+	// The last reply repeats
+	generated, err := c.Generate(ctx, "model", "prompt", nil)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if generated.Response != "second answer" {
+		t.Errorf("Expected the last reply to repeat, got %q", generated.Response)
+	}
+
+	calls := c.Calls()
+	if len(calls) != 3 || calls[0].Method != "Chat" || calls[2].Input != "prompt" {
+		t.Errorf("Unexpected calls: %+v", calls)
+	}
+}
+
+func TestClientEchoesWithoutReplies(t *testing.T) {
+	c := &Client{}
+	response, err := c.Chat(context.Background(), "", []client.Message{{Role: "user", Content: "hello"}}, false)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if response.Message.Content != "hello" {
+		t.Errorf("Expected the message to be echoed, got %q", response.Message.Content)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	c := &Client{Err: errors.New("server down")}
+	if _, err := c.GenerateEmbedding(context.Background(), "text", client.InputTypeQuery); err == nil {
+		t.Error("Expected the configured error")
+	}
+	if c.CallCount("GenerateEmbedding") != 1 {
+		t.Error("Expected failed requests to be recorded")
+	}
+}
+
+func TestHashEmbedding(t *testing.T) {
+	c := &Client{Dimensions: 32, Embeddings: map[string][]float32{"fixed": {1, 0}}}
+	ctx := context.Background()
+
+	fixed, _ := c.GenerateEmbedding(ctx, "fixed", client.InputTypeQuery)
+	if len(fixed) != 2 {
+		t.Errorf("Expected the configured embedding, got %v", fixed)
+	}
+
+	a, _ := c.GenerateEmbedding(ctx, "reset your password", client.InputTypeQuery)
+	b, _ := c.GenerateEmbedding(ctx, "How do I reset a password?", client.InputTypeQuery)
+	other, _ := c.GenerateEmbedding(ctx, "quarterly invoice totals", client.InputTypeQuery)
+	if len(a) != 32 {
+		t.Fatalf("Expected 32 dimensions, got %d", len(a))
+	}
+	if dot(a, a) < 0.999 || dot(a, a) > 1.001 {
+		t.Errorf("Expected a unit vector, got a norm of %v", dot(a, a))
+	}
+	if dot(a, b) <= dot(a, other) {
+		t.Errorf("Expected texts sharing words to be more similar: %v <= %v", dot(a, b), dot(a, other))
+	}
+}
+
+func TestReranker(t *testing.T) {
+	r := &Reranker{Scores: map[string]float64{"pinned": 0.5}}
+	results, err := r.Rerank(context.Background(), "reset password", []string{"about cats", "reset the password", "pinned"}, "")
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+
+	expected := []string{"reset the password", "pinned", "about cats"}
+	for i, result := range results {
+		if result.Document != expected[i] || result.Rank != i+1 {
+			t.Errorf("Expected %q at rank %d, got %q at rank %d", expected[i], i+1, result.Document, result.Rank)
+		}
+	}
+}
+
+// dot returns the dot product of two vectors
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// crossEncoderTimeout is how long a rerank request may take
+const crossEncoderTimeout = 60 * time.Second
+
+// CrossEncoderReranker reranks passages with a dedicated reranker model,
+// which reads the query and each passage together instead of comparing
+// their embeddings. All passages are scored in one request to a rerank
+// endpoint in the format shared by Cohere's Rerank API, Ollama's /api/rerank
+// and OpenAI-compatible servers such as llama-server or TEI.
+type CrossEncoderReranker struct {
+	url    string
+	apiKey string
+	model  string
+	http   *http.Client
+}
+
+// rerankRequest is the request of a rerank endpoint
+type rerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+// rerankResponse is the response of a rerank endpoint
+type rerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// NewCrossEncoderReranker creates a reranker that posts passages to the
+// rerank endpoint at url, sending apiKey as a bearer token when set
+func NewCrossEncoderReranker(url, apiKey, model string) *CrossEncoderReranker {
+	return &CrossEncoderReranker{
+		url:    url,
+		apiKey: apiKey,
+		model:  model,
+		http:   &http.Client{Timeout: crossEncoderTimeout},
+	}
+}
+
+// Rerank scores each document's relevance to the query and returns them
+// from most to least relevant. Reranker models are trained for relevance
+// alone, so the instruction isn't sent.
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, documents []string, instruction string) ([]RerankResult, error) {
+	if len(documents) == 0 {
+		return []RerankResult{}, nil
+	}
+
+	body, err := json.Marshal(rerankRequest{
+		Model:     r.model,
+		Query:     query,
+		Documents: documents,
+		TopN:      len(documents),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rerank request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rerank request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rerank documents: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rerank response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to rerank documents: server returned status code %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var response rerankResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse rerank response: %w", err)
+	}
+
+	// Documents the endpoint left out rank last with a score of 0
+	scores := make([]float64, len(documents))
+	for _, result := range response.Results {
+		if result.Index < 0 || result.Index >= len(documents) {
+			return nil, fmt.Errorf("rerank response has document index %d out of %d documents", result.Index, len(documents))
+		}
+		scores[result.Index] = result.RelevanceScore
+	}
+
+	results := make([]RerankResult, len(documents))
+	for i, doc := range documents {
+		results[i] = RerankResult{Document: doc, Score: scores[i]}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+
+	return results, nil
+}
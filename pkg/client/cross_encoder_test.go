@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+func TestCrossEncoderRerankerOrdersByScore(t *testing.T) {
+	var request rerankRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/rerank" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		// Like Cohere, only the most relevant documents are returned
+		fmt.Fprintln(w, `{"results":[{"index":2,"relevance_score":0.9},{"index":1,"relevance_score":0.4}]}`)
+	}))
+	defer server.Close()
+
+	reranker := NewCrossEncoderReranker(server.URL+"/v2/rerank", "secret", "rerank-v3.5")
+	documents := []string{"about cats", "login help", "reset password"}
+	results, err := reranker.Rerank(context.Background(), "How do I reset my password?", documents, "ignored")
+	if err != nil {
+		t.Fatalf("Failed to rerank: %v", err)
+	}
+
+	if request.Model != "rerank-v3.5" || request.Query != "How do I reset my password?" || len(request.Documents) != 3 || request.TopN != 3 {
+		t.Errorf("Unexpected request: %+v", request)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Expected bearer token, got %q", auth)
+	}
+
+	want := []string{"reset password", "login help", "about cats"}
+	for i, result := range results {
+		if result.Document != want[i] || result.Rank != i+1 {
+			t.Errorf("Result %d: expected %q at rank %d, got %q at rank %d", i, want[i], i+1, result.Document, result.Rank)
+		}
+	}
+	if results[0].Score != 0.9 || results[2].Score != 0 {
+		t.Errorf("Unexpected scores: %+v", results)
+	}
+}
+
+func TestCrossEncoderRerankerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad-index" {
+			fmt.Fprintln(w, `{"results":[{"index":5,"relevance_score":0.9}]}`)
+			return
+		}
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := NewCrossEncoderReranker(server.URL+"/rerank", "", "missing").Rerank(context.Background(), "query", []string{"a"}, "")
+	if err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Expected the server's error, got %v", err)
+	}
+
+	_, err = NewCrossEncoderReranker(server.URL+"/bad-index", "", "model").Rerank(context.Background(), "query", []string{"a"}, "")
+	if err == nil {
+		t.Error("Expected an error for a document index out of range")
+	}
+
+	results, err := NewCrossEncoderReranker(server.URL+"/rerank", "", "model").Rerank(context.Background(), "query", nil, "")
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected no results and no request for no documents, got %v, %v", results, err)
+	}
+}
+
+func TestNewCrossEncoderReranker(t *testing.T) {
+	cfg := &config.Config{
+		EmbeddingBackend: "ollama",
+		Ollama:           config.OllamaConfig{Host: "ollama", Port: 11434, RerankerModel: "qwen3-reranker"},
+		OpenAI:           config.OpenAIConfig{APIKey: "openai-key", BaseURL: "http://llama:8080/v1/"},
+		Rerank:           config.RerankConfig{Method: config.RerankMethodCrossEncoder},
+	}
+
+	tests := []struct {
+		backend, baseURL, model string
+		url, apiKey, wantModel  string
+	}{
+		{"ollama", "", "", "http://ollama:11434/api/rerank", "", "qwen3-reranker"},
+		{"openai", "", "bge-reranker", "http://llama:8080/v1/rerank", "openai-key", "bge-reranker"},
+		{"openai", "http://tei:8080", "bge-reranker", "http://tei:8080/rerank", "openai-key", "bge-reranker"},
+		{"cohere", "", "", "https://api.cohere.com/v2/rerank", "", config.DefaultCohereRerankModel},
+	}
+	for _, tt := range tests {
+		cfg.Rerank.Backend = tt.backend
+		cfg.Rerank.BaseURL = tt.baseURL
+		cfg.Rerank.Model = tt.model
+		reranker, err := NewReranker(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create %s reranker: %v", tt.backend, err)
+		}
+		crossEncoder, ok := reranker.(*CrossEncoderReranker)
+		if !ok {
+			t.Fatalf("Expected a CrossEncoderReranker for %s, got %T", tt.backend, reranker)
+		}
+		if crossEncoder.url != tt.url || crossEncoder.apiKey != tt.apiKey || crossEncoder.model != tt.wantModel {
+			t.Errorf("%s: expected %s %q %s, got %s %q %s", tt.backend, tt.url, tt.apiKey, tt.wantModel, crossEncoder.url, crossEncoder.apiKey, crossEncoder.model)
+		}
+	}
+
+	cfg.Rerank.Backend = "openai"
+	cfg.Rerank.Model = ""
+	if _, err := NewReranker(cfg); err == nil {
+		t.Error("Expected an error without a reranker model for openai")
+	}
+}
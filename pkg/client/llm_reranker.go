@@ -0,0 +1,222 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultRerankConcurrency is the number of passages an LLM reranker scores
+// at the same time when no concurrency is given
+const DefaultRerankConcurrency = 4
+
+// llmScoreCacheSize is the number of relevance scores an LLM reranker keeps
+const llmScoreCacheSize = 1000
+
+// maxRelevanceScore is the highest score the model is asked to give
+const maxRelevanceScore = 10
+
+// relevancePrompt asks the model for the relevance of one passage to a query
+const relevancePrompt = `%s
+
+Query: %s
+
+Passage:
+%s
+
+How relevant is the passage to the query, from 0 (not relevant) to 10 (answers it completely)? Reply with the number only.`
+
+// scorePattern matches the first number of a model's reply
+var scorePattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// LLMReranker reranks passages by asking a chat model how relevant each one
+// is to the query, one request per passage, which orders small candidate
+// sets better than comparing embeddings. Scores are cached, so asking the
+// same question again doesn't score its passages again.
+type LLMReranker struct {
+	client      Client
+	model       string
+	concurrency int
+	cache       *scoreCache
+}
+
+// NewLLMReranker creates a reranker that scores passages with model (the
+// client's chat model when empty), scoring up to concurrency passages at the
+// same time
+func NewLLMReranker(client Client, model string, concurrency int) *LLMReranker {
+	if concurrency <= 0 {
+		concurrency = DefaultRerankConcurrency
+	}
+	return &LLMReranker{
+		client:      client,
+		model:       model,
+		concurrency: concurrency,
+		cache:       newScoreCache(llmScoreCacheSize),
+	}
+}
+
+// Rerank scores each document's relevance to the query from 0 to 1 and
+// returns them from most to least relevant
+func (r *LLMReranker) Rerank(ctx context.Context, query string, documents []string, instruction string) ([]RerankResult, error) {
+	if len(documents) == 0 {
+		return []RerankResult{}, nil
+	}
+	if instruction == "" {
+		instruction = "Given a search query, judge whether the passage answers it."
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The first failure cancels the passages still being scored
+	scores := make([]float64, len(documents))
+	var firstErr error
+	var failed sync.Once
+	jobs := make(chan int)
+
+	var workers sync.WaitGroup
+	for w := 0; w < r.concurrency && w < len(documents); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				score, err := r.score(ctx, query, documents[i], instruction)
+				if err != nil {
+					failed.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				scores[i] = score
+			}
+		}()
+	}
+	for i := range documents {
+		jobs <- i
+	}
+	close(jobs)
+	workers.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	results := make([]RerankResult, len(documents))
+	for i, doc := range documents {
+		results[i] = RerankResult{Document: doc, Score: scores[i]}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+
+	return results, nil
+}
+
+// score returns the cached relevance of a document, or asks the model for it
+func (r *LLMReranker) score(ctx context.Context, query, document, instruction string) (float64, error) {
+	key := sha256.Sum256([]byte(r.model + "\x00" + instruction + "\x00" + query + "\x00" + document))
+	if score, ok := r.cache.get(key); ok {
+		return score, nil
+	}
+
+	messages := []Message{
+		{Role: "system", Content: "You rate how relevant passages are to search queries."},
+		{Role: "user", Content: fmt.Sprintf(relevancePrompt, instruction, query, document)},
+	}
+	response, err := r.client.Chat(ctx, r.model, messages, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to score passage: %w", err)
+	}
+
+	score, ok := ParseRelevanceScore(response.Message.Content)
+	if !ok {
+		// Unreadable replies rank the passage last, but aren't cached so
+		// the next search asks again
+		return 0, nil
+	}
+	r.cache.add(key, score)
+	return score, nil
+}
+
+// ParseRelevanceScore reads the 0-10 score of a model's reply and returns it
+// scaled to 0-1. Scores out of range are clamped.
+func ParseRelevanceScore(reply string) (float64, bool) {
+	match := scorePattern.FindString(StripThinking(reply))
+	if match == "" {
+		return 0, false
+	}
+	score, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, false
+	}
+	if score > maxRelevanceScore {
+		score = maxRelevanceScore
+	}
+	return score / maxRelevanceScore, true
+}
+
+// scoreCache is a least recently used cache of relevance scores, keyed by
+// the hash of what was scored. It is safe for concurrent use.
+type scoreCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // Most recently used first
+}
+
+// scoreEntry is a cached score and the key it is stored under
+type scoreEntry struct {
+	key   [sha256.Size]byte
+	score float64
+}
+
+// newScoreCache creates a cache that keeps up to size scores
+func newScoreCache(size int) *scoreCache {
+	return &scoreCache{
+		size:    size,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns a cached score
+func (c *scoreCache) get(key [sha256.Size]byte) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return 0, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*scoreEntry).score, true
+}
+
+// add caches a score, evicting the least recently used score when the cache
+// is full
+func (c *scoreCache) add(key [sha256.Size]byte, score float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*scoreEntry).score = score
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&scoreEntry{key: key, score: score})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*scoreEntry).key)
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scoringClient answers relevance prompts with the score of the first of
+// its passages that appears in the prompt
+type scoringClient struct {
+	Client
+	mu     sync.Mutex
+	scores map[string]string
+	calls  int
+	err    error
+}
+
+func (c *scoringClient) Chat(ctx context.Context, model string, messages []Message, stream bool) (*ChatResponse, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	prompt := messages[len(messages)-1].Content
+	for passage, score := range c.scores {
+		if strings.Contains(prompt, "Passage:\n"+passage+"\n") {
+			return &ChatResponse{Message: Message{Role: "assistant", Content: score}}, nil
+		}
+	}
+	return &ChatResponse{Message: Message{Role: "assistant", Content: "I can't tell"}}, nil
+}
+
+func TestLLMRerankerOrdersByScore(t *testing.T) {
+	chat := &scoringClient{scores: map[string]string{
+		"about cats":     "2",
+		"reset password": "<think>It explains the steps.</think>9",
+		"login help":     "Score: 6",
+	}}
+	reranker := NewLLMReranker(chat, "", 2)
+
+	documents := []string{"about cats", "login help", "reset password", "unrelated"}
+	results, err := reranker.Rerank(context.Background(), "How do I reset my password?", documents, "")
+	if err != nil {
+		t.Fatalf("Failed to rerank: %v", err)
+	}
+
+	expected := []string{"reset password", "login help", "about cats", "unrelated"}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, result := range results {
+		if result.Document != expected[i] {
+			t.Errorf("Expected %q at rank %d, got %q", expected[i], i+1, result.Document)
+		}
+		if result.Rank != i+1 {
+			t.Errorf("Expected rank %d, got %d", i+1, result.Rank)
+		}
+	}
+	if results[0].Score != 0.9 {
+		t.Errorf("Expected a score of 0.9, got %v", results[0].Score)
+	}
+}
+
+func TestLLMRerankerCachesScores(t *testing.T) {
+	chat := &scoringClient{scores: map[string]string{"first": "3", "second": "7"}}
+	reranker := NewLLMReranker(chat, "", 4)
+	documents := []string{"first", "second", "unreadable"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := reranker.Rerank(context.Background(), "query", documents, ""); err != nil {
+			t.Fatalf("Failed to rerank: %v", err)
+		}
+	}
+
+	// Unreadable replies are asked for again
+	if chat.calls != 4 {
+		t.Errorf("Expected 4 scoring requests, got %d", chat.calls)
+	}
+
+	if _, err := reranker.Rerank(context.Background(), "another query", documents[:1], ""); err != nil {
+		t.Fatalf("Failed to rerank: %v", err)
+	}
+	if chat.calls != 5 {
+		t.Errorf("Expected a new query to be scored again, got %d requests", chat.calls)
+	}
+}
+
+func TestLLMRerankerError(t *testing.T) {
+	chat := &scoringClient{err: errors.New("connection refused")}
+	reranker := NewLLMReranker(chat, "", 2)
+
+	_, err := reranker.Rerank(context.Background(), "query", []string{"a", "b", "c"}, "")
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Expected the scoring error, got %v", err)
+	}
+}
+
+func TestParseRelevanceScore(t *testing.T) {
+	tests := []struct {
+		reply string
+		score float64
+		ok    bool
+	}{
+		{"7", 0.7, true},
+		{"Relevance: 10", 1, true},
+		{"8.5/10", 0.85, true},
+		{"42", 1, true},
+		{"<think>Maybe 3?</think>5", 0.5, true},
+		{"not relevant", 0, false},
+	}
+
+	for _, test := range tests {
+		score, ok := ParseRelevanceScore(test.reply)
+		if ok != test.ok || score != test.score {
+			t.Errorf("ParseRelevanceScore(%q) = %v, %t; expected %v, %t", test.reply, score, ok, test.score, test.ok)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/busybytelab.com/rag-cli/pkg/config"
@@ -36,15 +37,20 @@ func NewOpenAI(cfg *config.OpenAIConfig) (Client, error) {
 }
 
 // GenerateEmbedding generates embeddings for the given text
-func (c *OpenAIClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+func (c *OpenAIClient) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
+	model := c.config.EmbeddingModel
+	if model == "" {
+		model = openai.EmbeddingModelTextEmbedding3Small
+	}
+
 	params := openai.EmbeddingNewParams{
-		Model: openai.EmbeddingModelTextEmbedding3Small,
+		Model: model,
 		Input: openai.EmbeddingNewParamsInputUnion{
 			OfArrayOfStrings: []string{text},
 		},
 	}
 
-	response, err := c.client.Embeddings.New(ctx, params)
+	response, err := c.client.Embeddings.New(ctx, params, c.inputTypeOptions(inputType)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding: %w", err)
 	}
@@ -62,13 +68,195 @@ func (c *OpenAIClient) GenerateEmbedding(ctx context.Context, text string) ([]fl
 	return embedding, nil
 }
 
+// GenerateEmbeddings generates embeddings for the given texts with a single
+// request
+func (c *OpenAIClient) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+
+	model := c.config.EmbeddingModel
+	if model == "" {
+		model = openai.EmbeddingModelTextEmbedding3Small
+	}
+
+	params := openai.EmbeddingNewParams{
+		Model: model,
+		Input: openai.EmbeddingNewParamsInputUnion{
+			OfArrayOfStrings: texts,
+		},
+	}
+
+	response, err := c.client.Embeddings.New(ctx, params, c.inputTypeOptions(inputType)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(response.Data))
+	}
+
+	// The data is in the order of its index in the input
+	embeddings := make([][]float32, len(texts))
+	for _, data := range response.Data {
+		if data.Index < 0 || int(data.Index) >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		embedding := make([]float32, len(data.Embedding))
+		for i, v := range data.Embedding {
+			embedding[i] = float32(v)
+		}
+		embeddings[data.Index] = embedding
+	}
+
+	return embeddings, nil
+}
+
+// inputTypeOptions sets the input type field of an embedding request, for
+// APIs configured with one
+func (c *OpenAIClient) inputTypeOptions(inputType InputType) []option.RequestOption {
+	if c.config.InputTypeField == "" {
+		return nil
+	}
+	value := c.config.DocumentInputType
+	if inputType == InputTypeQuery {
+		value = c.config.QueryInputType
+	}
+	return []option.RequestOption{option.WithJSONSet(c.config.InputTypeField, value)}
+}
+
 // Chat performs a chat completion with the specified model
 func (c *OpenAIClient) Chat(ctx context.Context, model string, messages []Message, stream bool) (*ChatResponse, error) {
 	if model == "" {
 		model = c.config.ChatModel
 	}
 
-	// Convert our Message type to OpenAI format
+	openaiMessages, err := toOpenAIMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	params := chatParams(ctx, model, openaiMessages)
+	if stream {
+		return c.stream(ctx, params, nil)
+	}
+
+	response, err := c.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat completion: %w", err)
+	}
+
+	// Convert OpenAI response to our generic response
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+
+	choice := response.Choices[0]
+	content := string(choice.Message.Content)
+
+	return &ChatResponse{
+		Model:     model,
+		CreatedAt: time.Unix(response.Created, 0),
+		Message: Message{
+			Role:    string(choice.Message.Role),
+			Content: content,
+		},
+		Done:  true,
+		Usage: toUsage(response.Usage),
+	}, nil
+}
+
+// ChatStream performs a streamed chat completion with the specified model
+func (c *OpenAIClient) ChatStream(ctx context.Context, model string, messages []Message, onChunk ChunkHandler) (*ChatResponse, error) {
+	if model == "" {
+		model = c.config.ChatModel
+	}
+
+	openaiMessages, err := toOpenAIMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.stream(ctx, chatParams(ctx, model, openaiMessages), onChunk)
+}
+
+// chatParams creates the parameters of a chat completion, with the chat
+// options of the context
+func chatParams(ctx context.Context, model string, messages []openai.ChatCompletionMessageParamUnion) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:    model,
+		Messages: messages,
+	}
+	options := chatOptions(ctx)
+	if options.Temperature != nil {
+		params.Temperature = openai.Float(*options.Temperature)
+	}
+	if options.MaxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(int64(options.MaxTokens))
+	}
+	return params
+}
+
+// stream reads a streamed chat completion, passing each piece of the answer
+// to onChunk and joining them into the returned response
+func (c *OpenAIClient) stream(ctx context.Context, params openai.ChatCompletionNewParams, onChunk ChunkHandler) (*ChatResponse, error) {
+	// The last chunk reports the tokens used
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+
+	stream := c.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	var content strings.Builder
+	var usage *Usage
+	createdAt := time.Now()
+	for stream.Next() {
+		chunk := stream.Current()
+		if chunk.Created != 0 {
+			createdAt = time.Unix(chunk.Created, 0)
+		}
+		if chunk.Usage.TotalTokens > 0 {
+			usage = toUsage(chunk.Usage)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		piece := chunk.Choices[0].Delta.Content
+		content.WriteString(piece)
+		if onChunk != nil {
+			if err := onChunk(piece); err != nil {
+				return nil, fmt.Errorf("failed to stream chat completion: %w", err)
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("failed to stream chat completion: %w", err)
+	}
+
+	return &ChatResponse{
+		Model:     params.Model,
+		CreatedAt: createdAt,
+		Message: Message{
+			Role:    "assistant",
+			Content: content.String(),
+		},
+		Done:  true,
+		Usage: usage,
+	}, nil
+}
+
+// toUsage converts the token usage reported by OpenAI, which is missing from
+// the responses of some compatible servers
+func toUsage(usage openai.CompletionUsage) *Usage {
+	if usage.TotalTokens == 0 {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     int(usage.PromptTokens),
+		CompletionTokens: int(usage.CompletionTokens),
+	}
+}
+
+// toOpenAIMessages converts our Message type to the OpenAI format
+func toOpenAIMessages(messages []Message) ([]openai.ChatCompletionMessageParamUnion, error) {
 	openaiMessages := make([]openai.ChatCompletionMessageParamUnion, len(messages))
 	for i, msg := range messages {
 		switch msg.Role {
@@ -100,68 +288,7 @@ func (c *OpenAIClient) Chat(ctx context.Context, model string, messages []Messag
 			return nil, fmt.Errorf("unsupported message role: %s", msg.Role)
 		}
 	}
-
-	params := openai.ChatCompletionNewParams{
-		Model:    model,
-		Messages: openaiMessages,
-	}
-
-	var response *openai.ChatCompletion
-	var err error
-
-	if stream {
-		// Use streaming API
-		stream := c.client.Chat.Completions.NewStreaming(ctx, params)
-		// For now, we'll collect the first chunk only
-		if stream.Next() {
-			chunk := stream.Current()
-			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-				content := chunk.Choices[0].Delta.Content
-				return &ChatResponse{
-					Model:     model,
-					CreatedAt: time.Unix(chunk.Created, 0),
-					Message: Message{
-						Role:    "assistant",
-						Content: content,
-					},
-					Done: true,
-				}, nil
-			}
-		}
-		return &ChatResponse{
-			Model:     model,
-			CreatedAt: time.Now(),
-			Message: Message{
-				Role:    "assistant",
-				Content: "",
-			},
-			Done: true,
-		}, nil
-	} else {
-		// Use non-streaming API
-		response, err = c.client.Chat.Completions.New(ctx, params)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create chat completion: %w", err)
-		}
-	}
-
-	// Convert OpenAI response to our generic response
-	if len(response.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
-	}
-
-	choice := response.Choices[0]
-	content := string(choice.Message.Content)
-
-	return &ChatResponse{
-		Model:     model,
-		CreatedAt: time.Unix(response.Created, 0),
-		Message: Message{
-			Role:    string(choice.Message.Role),
-			Content: content,
-		},
-		Done: true,
-	}, nil
+	return openaiMessages, nil
 }
 
 // Rerank reranks documents using the reranker model
@@ -172,14 +299,14 @@ func (c *OpenAIClient) Rerank(ctx context.Context, query string, documents []str
 
 	// For OpenAI, we'll use a similar approach as Ollama by generating embeddings
 	// and computing cosine similarity as a reranking score
-	queryEmbedding, err := c.GenerateEmbedding(ctx, query)
+	queryEmbedding, err := c.GenerateEmbedding(ctx, query, InputTypeQuery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
 	var results []RerankResult
 	for i, doc := range documents {
-		docEmbedding, err := c.GenerateEmbedding(ctx, doc)
+		docEmbedding, err := c.GenerateEmbedding(ctx, doc, InputTypeDocument)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate document embedding: %w", err)
 		}
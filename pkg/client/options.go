@@ -0,0 +1,25 @@
+package client
+
+import "context"
+
+// ChatOptions are the sampling settings of chat requests
+type ChatOptions struct {
+	Temperature *float64 // Sampling temperature, the model's default when nil
+	MaxTokens   int      // Maximum length of the answer in tokens, 0 for no limit
+}
+
+// chatOptionsKey is the context key of the chat options
+type chatOptionsKey struct{}
+
+// WithChatOptions returns a context whose chat requests use the given
+// options, so they reach the client through wrappers such as the budget
+// client without changing the Client interface
+func WithChatOptions(ctx context.Context, options ChatOptions) context.Context {
+	return context.WithValue(ctx, chatOptionsKey{}, options)
+}
+
+// chatOptions returns the chat options of a context, if any
+func chatOptions(ctx context.Context) ChatOptions {
+	options, _ := ctx.Value(chatOptionsKey{}).(ChatOptions)
+	return options
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// prefixEmbedder prepends the prefix of their input type to texts before
+// embedding them. Instruction-tuned models such as nomic-embed-text expect
+// queries and documents to be marked this way.
+type prefixEmbedder struct {
+	embedder       Embedder
+	queryPrefix    string
+	documentPrefix string
+}
+
+// withPrefixes wraps an embedder with the query and document prefixes of
+// the embedding configuration, if it has any
+func withPrefixes(embedder Embedder, cfg *config.EmbeddingConfig) Embedder {
+	if cfg.QueryPrefix == "" && cfg.DocumentPrefix == "" {
+		return embedder
+	}
+	return &prefixEmbedder{embedder: embedder, queryPrefix: cfg.QueryPrefix, documentPrefix: cfg.DocumentPrefix}
+}
+
+// prefix returns the prefix of an input type
+func (e *prefixEmbedder) prefix(inputType InputType) string {
+	if inputType == InputTypeQuery {
+		return e.queryPrefix
+	}
+	return e.documentPrefix
+}
+
+// GenerateEmbedding embeds the text with the prefix of its input type
+func (e *prefixEmbedder) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
+	return e.embedder.GenerateEmbedding(ctx, e.prefix(inputType)+text, inputType)
+}
+
+// GenerateEmbeddings embeds the texts with the prefix of their input type
+func (e *prefixEmbedder) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	prefix := e.prefix(inputType)
+	if prefix == "" {
+		return e.embedder.GenerateEmbeddings(ctx, texts, inputType)
+	}
+	prefixed := make([]string, len(texts))
+	for i, text := range texts {
+		prefixed[i] = prefix + text
+	}
+	return e.embedder.GenerateEmbeddings(ctx, prefixed, inputType)
+}
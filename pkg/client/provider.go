@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// Provider lazily creates the backend clients needed by a command.
+// Each client is validated and constructed on first use, so commands that
+// never touch a backend don't need its configuration.
+type Provider struct {
+	cfg    *config.Config
+	budget *Budget
+
+	mu       sync.Mutex
+	chat     Client
+	embedder Embedder
+	reranker Reranker
+}
+
+// NewProvider creates a new lazy client provider. The clients it creates
+// enforce the budget settings, keeping the daily spend in the data directory.
+func NewProvider(cfg *config.Config) *Provider {
+	return &Provider{
+		cfg:    cfg,
+		budget: NewBudget(&cfg.Budget, filepath.Join(cfg.General.GetDataDir(), "usage.json")),
+	}
+}
+
+// Budget returns the budget enforced on the clients
+func (p *Provider) Budget() *Budget {
+	return p.budget
+}
+
+// Chat returns the chat client, creating it on first use
+func (p *Provider) Chat() (Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.chat != nil {
+		return p.chat, nil
+	}
+
+	if err := p.cfg.ValidateFor(config.RequireChat); err != nil {
+		return nil, fmt.Errorf("invalid chat configuration: %w", err)
+	}
+
+	chat, err := New(p.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat client: %w", err)
+	}
+
+	p.chat = p.budget.WrapClient(chat, p.paid(p.cfg.ChatBackend), p.cfg.OpenAI.ChatModel, p.cfg.OpenAI.EmbeddingModel)
+	return p.chat, nil
+}
+
+// Embedder returns the embedder, creating it on first use
+func (p *Provider) Embedder() (Embedder, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.embedder != nil {
+		return p.embedder, nil
+	}
+
+	if err := p.cfg.ValidateFor(config.RequireEmbedding); err != nil {
+		return nil, fmt.Errorf("invalid embedding configuration: %w", err)
+	}
+
+	embedder, err := NewEmbedder(p.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedder: %w", err)
+	}
+
+	model, paid := p.EmbeddingModel()
+	p.embedder = p.budget.WrapEmbedder(embedder, paid, model)
+	return p.embedder, nil
+}
+
+// Reranker returns the reranker, creating it on first use
+func (p *Provider) Reranker() (Reranker, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.reranker != nil {
+		return p.reranker, nil
+	}
+
+	if err := p.cfg.ValidateFor(config.RequireReranker); err != nil {
+		return nil, fmt.Errorf("invalid rerank configuration: %w", err)
+	}
+
+	reranker, err := NewReranker(p.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reranker: %w", err)
+	}
+
+	backend := p.cfg.Rerank.Backend
+	if backend == "" {
+		backend = p.cfg.EmbeddingBackend
+	}
+	if backend == "" {
+		backend = p.cfg.ChatBackend
+	}
+	model := p.cfg.Rerank.Model
+	if model == "" && p.cfg.Rerank.GetMethod() == config.RerankMethodLLM {
+		model = p.cfg.OpenAI.ChatModel
+	} else if model == "" {
+		model = p.cfg.OpenAI.EmbeddingModel
+	}
+	p.reranker = p.budget.WrapReranker(reranker, p.paid(backend), model)
+	return p.reranker, nil
+}
+
+// EmbeddingModel returns the embedding model and whether embedding requests
+// cost money. Only the OpenAI API is paid, so the model is only known for it.
+func (p *Provider) EmbeddingModel() (string, bool) {
+	backend := p.cfg.EmbeddingBackend
+	if backend == "" {
+		backend = p.cfg.ChatBackend
+	}
+	if !p.paid(backend) {
+		return "", false
+	}
+	return p.cfg.OpenAI.EmbeddingModel, true
+}
+
+// paid reports whether requests to backend cost money: the OpenAI API is
+// paid, but OpenAI compatible servers set with base_url are not
+func (p *Provider) paid(backend string) bool {
+	return backend == "openai" && strings.Contains(p.cfg.OpenAI.GetBaseURL(), "api.openai.com")
+}
+
+// LazyEmbedder returns an Embedder that creates the real embedder on the
+// first embedding request
+func (p *Provider) LazyEmbedder() Embedder {
+	return &lazyEmbedder{provider: p}
+}
+
+// LazyReranker returns a Reranker that creates the real reranker on the
+// first rerank request
+func (p *Provider) LazyReranker() Reranker {
+	return &lazyReranker{provider: p}
+}
+
+// lazyEmbedder defers embedder creation until it is used
+type lazyEmbedder struct {
+	provider *Provider
+}
+
+// GenerateEmbedding generates embeddings for the given text
+func (e *lazyEmbedder) GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error) {
+	embedder, err := e.provider.Embedder()
+	if err != nil {
+		return nil, err
+	}
+	return embedder.GenerateEmbedding(ctx, text, inputType)
+}
+
+// GenerateEmbeddings generates embeddings for the given texts
+func (e *lazyEmbedder) GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error) {
+	embedder, err := e.provider.Embedder()
+	if err != nil {
+		return nil, err
+	}
+	return embedder.GenerateEmbeddings(ctx, texts, inputType)
+}
+
+// lazyReranker defers reranker creation until it is used
+type lazyReranker struct {
+	provider *Provider
+}
+
+// Rerank reranks documents using the reranker model
+func (r *lazyReranker) Rerank(ctx context.Context, query string, documents []string, instruction string) ([]RerankResult, error) {
+	reranker, err := r.provider.Reranker()
+	if err != nil {
+		return nil, err
+	}
+	return reranker.Rerank(ctx, query, documents, instruction)
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Waits between attempts to reach a backend that is unavailable, doubling
+// from the first to the longest
+const (
+	firstRetryDelay = 250 * time.Millisecond
+	maxRetryDelay   = 5 * time.Second
+)
+
+// interruptedError wraps an error that ended a streamed answer after part of
+// it was passed on, so the request can't be retried
+type interruptedError struct {
+	error
+}
+
+func (e interruptedError) Unwrap() error {
+	return e.error
+}
+
+// retryUnavailable calls fn until it succeeds, fails for another reason than
+// the backend being unavailable, or the backend has stayed unavailable for
+// longer than timeout. A backend restarting or reloading its model is thus
+// waited for instead of failing the request.
+func retryUnavailable(ctx context.Context, timeout time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	delay := firstRetryDelay
+	for {
+		err := fn()
+		if err == nil || !isUnavailable(err) || time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// isUnavailable reports whether an error means the backend couldn't be
+// reached or wasn't ready, rather than that it rejected the request
+func isUnavailable(err error) bool {
+	var interrupted interruptedError
+	if errors.As(err, &interrupted) {
+		return false
+	}
+
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,15 @@
+package client
+
+import (
+	"regexp"
+	"strings"
+)
+
+// thinkPattern matches the reasoning block some chat models emit before answering
+var thinkPattern = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// StripThinking removes the reasoning blocks of a model's reply, and the
+// space around what is left
+func StripThinking(reply string) string {
+	return strings.TrimSpace(thinkPattern.ReplaceAllString(reply, ""))
+}
@@ -0,0 +1,20 @@
+package client
+
+import "testing"
+
+func TestStripThinking(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  string
+	}{
+		{"7", "7"},
+		{"<think>The passage mentions\nthe query.</think>\n\n8", "8"},
+		{"<think>a</think> one <think>b</think> two ", "one  two"},
+		{"<think>unterminated", "<think>unterminated"},
+	}
+	for _, test := range tests {
+		if got := StripThinking(test.reply); got != test.want {
+			t.Errorf("StripThinking(%q) = %q, want %q", test.reply, got, test.want)
+		}
+	}
+}
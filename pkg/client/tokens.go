@@ -0,0 +1,123 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Tokenizer counts the tokens of texts
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// NewTokenizer returns the tokenizer of a model of a backend. OpenAI models
+// get their BPE tokenizer, whose vocabulary is downloaded on first use and
+// cached (see TIKTOKEN_CACHE_DIR); other models, and OpenAI models it fails
+// for, get the ApproximateTokenizer along with the error.
+func NewTokenizer(backend, model string) (Tokenizer, error) {
+	if backend != "openai" {
+		return ApproximateTokenizer{}, nil
+	}
+	encoding, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		return ApproximateTokenizer{}, fmt.Errorf("failed to load the tokenizer of %s: %w", model, err)
+	}
+	return bpeTokenizer{encoding: encoding}, nil
+}
+
+// bpeTokenizer counts tokens with a tiktoken BPE encoding
+type bpeTokenizer struct {
+	encoding *tiktoken.Tiktoken
+}
+
+// CountTokens returns the number of tokens the encoding splits text into
+func (t bpeTokenizer) CountTokens(text string) int {
+	return len(t.encoding.EncodeOrdinary(text))
+}
+
+// ApproximateTokenizer estimates tokens without a vocabulary, the way BPE
+// tokenizers split text: every run of letters or digits is a token per four
+// characters, and every other character but whitespace is a token of its own
+type ApproximateTokenizer struct{}
+
+// CountTokens returns the estimated number of tokens of text
+func (ApproximateTokenizer) CountTokens(text string) int {
+	tokens := 0
+	word := 0 // Characters of the current run of letters or digits
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word++
+			continue
+		}
+		tokens += (word + 3) / 4
+		word = 0
+		if !unicode.IsSpace(r) {
+			tokens++
+		}
+	}
+	return tokens + (word+3)/4
+}
+
+// messageOverheadTokens are the tokens a chat message takes besides its
+// content, for its role and delimiters
+const messageOverheadTokens = 4
+
+// TokenCounter counts the tokens of prompts with the tokenizer of a chat
+// model (see NewTokenizer), which is loaded on first use
+type TokenCounter struct {
+	backend   string
+	model     string
+	once      sync.Once
+	tokenizer Tokenizer
+}
+
+// NewTokenCounter creates a token counter for a chat model of a backend
+func NewTokenCounter(backend, model string) *TokenCounter {
+	return &TokenCounter{backend: backend, model: model}
+}
+
+// Count returns the number of tokens of text
+func (c *TokenCounter) Count(text string) int {
+	c.once.Do(func() {
+		// A tokenizer that fails to load leaves the approximate one
+		c.tokenizer, _ = NewTokenizer(c.backend, c.model)
+	})
+	return c.tokenizer.CountTokens(text)
+}
+
+// CountMessages returns the number of tokens of chat messages
+func (c *TokenCounter) CountMessages(messages []Message) int {
+	tokens := 0
+	for _, m := range messages {
+		tokens += messageOverheadTokens + c.Count(m.Content)
+	}
+	return tokens
+}
+
+// tokenCounter is the counter of EstimateTokens and EstimateMessageTokens,
+// the approximate one until UseTokenCounter sets the chat model's
+var tokenCounter atomic.Pointer[TokenCounter]
+
+func init() {
+	tokenCounter.Store(NewTokenCounter("", ""))
+}
+
+// UseTokenCounter counts tokens with counter from now on, so prompts, the
+// conversation and the context documents are all counted alike
+func UseTokenCounter(counter *TokenCounter) {
+	tokenCounter.Store(counter)
+}
+
+// EstimateTokens returns the number of tokens of text
+func EstimateTokens(text string) int {
+	return tokenCounter.Load().Count(text)
+}
+
+// EstimateMessageTokens returns the number of tokens of chat messages
+func EstimateMessageTokens(messages []Message) int {
+	return tokenCounter.Load().CountMessages(messages)
+}
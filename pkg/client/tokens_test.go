@@ -0,0 +1,64 @@
+package client
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApproximateTokenizer(t *testing.T) {
+	tokenizer := ApproximateTokenizer{}
+	tests := map[string]int{
+		"":                     0,
+		"Hello, ":              3,
+		"internationalization": 5,
+		"f(x) = 42":            6,
+		"ééééé":                2,
+	}
+	for text, want := range tests {
+		if got := tokenizer.CountTokens(text); got != want {
+			t.Errorf("Expected %d tokens for %q, got %d", want, text, got)
+		}
+	}
+}
+
+func TestNewTokenizer(t *testing.T) {
+	tokenizer, err := NewTokenizer("ollama", "qwen3:4b")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := tokenizer.(ApproximateTokenizer); !ok {
+		t.Errorf("Expected Ollama models to get the approximate tokenizer, got %T", tokenizer)
+	}
+}
+
+func TestTokenCounter(t *testing.T) {
+	counter := NewTokenCounter("ollama", "qwen3:4b")
+	if got := counter.Count(strings.Repeat("x", 70)); got != 18 {
+		t.Errorf("Expected 18 tokens, got %d", got)
+	}
+
+	messages := []Message{
+		{Role: "system", Content: strings.Repeat("x", 40)},
+		{Role: "user", Content: ""},
+	}
+	want := 10 + 2*messageOverheadTokens
+	if got := counter.CountMessages(messages); got != want {
+		t.Errorf("Expected %d tokens, got %d", want, got)
+	}
+	if got := counter.CountMessages(nil); got != 0 {
+		t.Errorf("Expected no tokens for no messages, got %d", got)
+	}
+}
+
+func TestEstimateTokensUsesTokenCounter(t *testing.T) {
+	defer UseTokenCounter(tokenCounter.Load())
+
+	UseTokenCounter(NewTokenCounter("ollama", "qwen3:4b"))
+	messages := []Message{{Role: "user", Content: "Hello, world"}}
+	if got, want := EstimateMessageTokens(messages), NewTokenCounter("ollama", "qwen3:4b").CountMessages(messages); got != want {
+		t.Errorf("Expected %d tokens, got %d", want, got)
+	}
+	if got := EstimateTokens("Hello, world"); got != 5 {
+		t.Errorf("Expected 5 tokens, got %d", got)
+	}
+}
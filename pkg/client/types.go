@@ -9,10 +9,25 @@ import (
 	"github.com/ollama/ollama/api"
 )
 
+// InputType is what a text is embedded for. Instruction-tuned embedding
+// models embed search queries and the documents they search differently.
+type InputType string
+
+// Input types
+const (
+	InputTypeQuery    InputType = "query"    // A search query
+	InputTypeDocument InputType = "document" // A document, or a part of one, to be searched
+)
+
 type (
-	// Embedder represents an interface for embedding text
+	// Embedder represents an interface for embedding text. The input type
+	// tells instruction-tuned models whether the text is a query or a
+	// document.
 	Embedder interface {
-		GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
+		GenerateEmbedding(ctx context.Context, text string, inputType InputType) ([]float32, error)
+		// GenerateEmbeddings embeds several texts in one request, returning
+		// their embeddings in the same order
+		GenerateEmbeddings(ctx context.Context, texts []string, inputType InputType) ([][]float32, error)
 	}
 
 	// Reranker represents an interface for reranking search results
@@ -32,10 +47,23 @@ type (
 		Embedder
 		// TODO: Chat method should be used instead of Generate
 		Chat(ctx context.Context, model string, messages []Message, stream bool) (*ChatResponse, error)
+		// ChatStream performs a chat completion, passing each piece of the
+		// answer to onChunk as it is generated. The returned response holds
+		// the complete answer.
+		ChatStream(ctx context.Context, model string, messages []Message, onChunk ChunkHandler) (*ChatResponse, error)
 		// TODO: remove
 		Generate(ctx context.Context, model string, prompt string, options map[string]interface{}) (*GenerateResponse, error)
 	}
 
+	// Preloader is implemented by clients whose server loads models on
+	// demand, so they can be loaded before the first request needs them
+	Preloader interface {
+		// PreloadChatModel loads the chat model and keeps it loaded for keepAlive
+		PreloadChatModel(ctx context.Context, keepAlive time.Duration) error
+		// PreloadEmbeddingModel loads the embedding model and keeps it loaded for keepAlive
+		PreloadEmbeddingModel(ctx context.Context, keepAlive time.Duration) error
+	}
+
 	// OllamaClient represents an Ollama API client implementation
 	OllamaClient struct {
 		serverURL *url.URL
@@ -43,6 +71,10 @@ type (
 		client    *api.Client
 	}
 
+	// ChunkHandler receives the pieces of a streamed chat answer. Returning
+	// an error stops the stream.
+	ChunkHandler func(content string) error
+
 	// Message represents a chat message
 	Message struct {
 		Role    string `json:"role"`
@@ -55,6 +87,13 @@ type (
 		CreatedAt time.Time `json:"created_at"`
 		Message   Message   `json:"message"`
 		Done      bool      `json:"done"`
+		Usage     *Usage    `json:"usage,omitempty"` // Tokens used, when the backend reports them
+	}
+
+	// Usage is the number of tokens used by a request
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
 	}
 
 	// GenerateResponse represents a text generation response
@@ -4,11 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/busybytelab.com/rag-cli/pkg/ignore"
 	_ "github.com/lib/pq"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
@@ -17,15 +24,36 @@ import (
 // CurrentConfigName holds the current configuration name
 var CurrentConfigName string
 
+// DefaultRerankInstruction is the instruction used for reranking when none is configured
+const DefaultRerankInstruction = "Given a web search query, retrieve relevant passages that answer the query"
+
 // Config represents the application configuration
 type Config struct {
-	ChatBackend      string          `mapstructure:"chat_backend" yaml:"chat_backend"`           // "ollama" or "openai"
-	EmbeddingBackend string          `mapstructure:"embedding_backend" yaml:"embedding_backend"` // "ollama" or "openai" (defaults to chat_backend if not specified)
-	Ollama           OllamaConfig    `mapstructure:"ollama" yaml:"ollama"`
-	OpenAI           OpenAIConfig    `mapstructure:"openai" yaml:"openai"`
-	Database         DatabaseConfig  `mapstructure:"database" yaml:"database"`
-	Embedding        EmbeddingConfig `mapstructure:"embedding" yaml:"embedding"`
-	General          GeneralConfig   `mapstructure:"general" yaml:"general"`
+	ChatBackend      string              `mapstructure:"chat_backend" yaml:"chat_backend"`           // "ollama" or "openai"
+	EmbeddingBackend string              `mapstructure:"embedding_backend" yaml:"embedding_backend"` // "ollama" or "openai" (defaults to chat_backend if not specified)
+	Ollama           OllamaConfig        `mapstructure:"ollama" yaml:"ollama"`
+	OpenAI           OpenAIConfig        `mapstructure:"openai" yaml:"openai"`
+	Database         DatabaseConfig      `mapstructure:"database" yaml:"database"`
+	Embedding        EmbeddingConfig     `mapstructure:"embedding" yaml:"embedding"`
+	Search           SearchConfig        `mapstructure:"search" yaml:"search"`
+	Calibration      CalibrationConfig   `mapstructure:"calibration" yaml:"calibration"`
+	Rerank           RerankConfig        `mapstructure:"rerank" yaml:"rerank"`
+	Expansion        ExpansionConfig     `mapstructure:"expansion" yaml:"expansion"`
+	Decomposition    DecompositionConfig `mapstructure:"decomposition" yaml:"decomposition"`
+	Translation      TranslationConfig   `mapstructure:"translation" yaml:"translation"`
+	SpellCheck       SpellCheckConfig    `mapstructure:"spellcheck" yaml:"spellcheck"`
+	History          HistoryConfig       `mapstructure:"history" yaml:"history"`
+	Abstention       AbstentionConfig    `mapstructure:"abstention" yaml:"abstention"`
+	Voice            VoiceConfig         `mapstructure:"voice" yaml:"voice"`
+	Budget           BudgetConfig        `mapstructure:"budget" yaml:"budget"`
+	Secrets          SecretsConfig       `mapstructure:"secrets" yaml:"secrets"`
+	Ignore           IgnoreConfig        `mapstructure:"ignore" yaml:"ignore"`
+	Encryption       EncryptionConfig    `mapstructure:"encryption" yaml:"encryption"`
+	Telemetry        TelemetryConfig     `mapstructure:"telemetry" yaml:"telemetry"`
+	Paths            PathsConfig         `mapstructure:"paths" yaml:"paths"`
+	Server           ServerConfig        `mapstructure:"server" yaml:"server"`
+	Bots             BotsConfig          `mapstructure:"bots" yaml:"bots"`
+	General          GeneralConfig       `mapstructure:"general" yaml:"general"`
 }
 
 // OllamaConfig represents Ollama server configuration
@@ -36,6 +64,8 @@ type OllamaConfig struct {
 	ChatModel      string `mapstructure:"chat_model" yaml:"chat_model"`
 	EmbeddingModel string `mapstructure:"embedding_model" yaml:"embedding_model"`
 	RerankerModel  string `mapstructure:"reranker_model" yaml:"reranker_model"`
+
+	ReconnectTimeout string `mapstructure:"reconnect_timeout" yaml:"reconnect_timeout"` // How long requests wait for a restarting server, e.g. "30s" ("0s" = fail at once)
 }
 
 // OpenAIConfig represents OpenAI API configuration
@@ -45,6 +75,12 @@ type OpenAIConfig struct {
 	ChatModel      string `mapstructure:"chat_model" yaml:"chat_model"`
 	EmbeddingModel string `mapstructure:"embedding_model" yaml:"embedding_model"`
 	RerankerModel  string `mapstructure:"reranker_model" yaml:"reranker_model"`
+
+	// Embedding APIs compatible with OpenAI's, such as Cohere's or Jina's,
+	// may take the input type of the texts in a request field of their own
+	InputTypeField    string `mapstructure:"input_type_field" yaml:"input_type_field"`       // Name of the field, e.g. input_type (empty = not sent)
+	QueryInputType    string `mapstructure:"query_input_type" yaml:"query_input_type"`       // Value of the field for search queries, e.g. search_query
+	DocumentInputType string `mapstructure:"document_input_type" yaml:"document_input_type"` // Value of the field for indexed chunks, e.g. search_document
 }
 
 // DatabaseConfig represents PostgreSQL database configuration
@@ -55,25 +91,423 @@ type DatabaseConfig struct {
 	User     string `mapstructure:"user" yaml:"user"`
 	Password string `mapstructure:"password" yaml:"password"`
 	SSLMode  string `mapstructure:"ssl_mode" yaml:"ssl_mode"`
+
+	// Connection pool settings
+	MaxOpenConns    int    `mapstructure:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns    int    `mapstructure:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetime string `mapstructure:"conn_max_lifetime" yaml:"conn_max_lifetime"` // Duration, e.g. "5m"
+
+	ReconnectTimeout string `mapstructure:"reconnect_timeout" yaml:"reconnect_timeout"` // How long new connections wait for a restarting server, e.g. "30s" ("0s" = fail at once)
 }
 
 // EmbeddingConfig represents embedding configuration
 type EmbeddingConfig struct {
-	ChunkSize           int     `mapstructure:"chunk_size" yaml:"chunk_size"`
-	ChunkOverlap        int     `mapstructure:"chunk_overlap" yaml:"chunk_overlap"`
-	SimilarityThreshold float64 `mapstructure:"similarity_threshold" yaml:"similarity_threshold"`
-	MaxResults          int     `mapstructure:"max_results" yaml:"max_results"`
-	Dimensions          int     `mapstructure:"dimensions" yaml:"dimensions"` // Embedding vector dimensions
+	ChunkStrategy       string   `mapstructure:"chunk_strategy" yaml:"chunk_strategy"` // "characters" or "tokens" to split between sentences with chunk_size in that unit, or "recursive" to split at separators
+	ChunkMarkdown       bool     `mapstructure:"chunk_markdown" yaml:"chunk_markdown"` // Split Markdown files at their headings, keeping code blocks and tables whole
+	ChunkCode           bool     `mapstructure:"chunk_code" yaml:"chunk_code"`         // Split Go, Python and JavaScript files between their functions and classes
+	ChunkSize           int      `mapstructure:"chunk_size" yaml:"chunk_size"`
+	ChunkOverlap        int      `mapstructure:"chunk_overlap" yaml:"chunk_overlap"`
+	Separators          []string `mapstructure:"separators" yaml:"separators"`                               // Where the recursive chunk strategy splits text, in order of preference ("" = between characters)
+	SimilarityThreshold float64  `mapstructure:"similarity_threshold" yaml:"similarity_threshold,omitempty"` // Deprecated: not used; search and chat take --min-score
+	MaxResults          int      `mapstructure:"max_results" yaml:"max_results,omitempty"`                   // Deprecated: not used; search and chat take --limit
+	Dimensions          int      `mapstructure:"dimensions" yaml:"dimensions"`                               // Embedding vector dimensions
+	Concurrency         int      `mapstructure:"concurrency" yaml:"concurrency"`                             // Files chunked and embedded at once when indexing (0 = 4)
+	BatchSize           int      `mapstructure:"batch_size" yaml:"batch_size"`                               // Chunks embedded per request when indexing (0 = 32)
+	QueryPrefix         string   `mapstructure:"query_prefix" yaml:"query_prefix"`                           // Prepended to search queries before embedding them, for instruction-tuned models
+	DocumentPrefix      string   `mapstructure:"document_prefix" yaml:"document_prefix"`                     // Prepended to indexed chunks before embedding them
+
+	// Dimensions of embedding models by name, in addition to or overriding
+	// the built-in ones
+	ModelDimensions map[string]int `mapstructure:"model_dimensions" yaml:"model_dimensions"`
+}
+
+// Chunk strategies, how text is split into chunks and the units chunks are
+// sized in
+const (
+	ChunkStrategyCharacters = "characters" // Between sentences, in bytes of text
+	ChunkStrategyTokens     = "tokens"     // Between sentences, in tokens of the embedding model, counted with its tokenizer or estimated
+	ChunkStrategyRecursive  = "recursive"  // At the first of the separators the text contains, then the next ones, in bytes of text
+)
+
+// DefaultSeparators are where the recursive chunk strategy splits text by
+// default: between paragraphs, lines, sentences, words and characters
+var DefaultSeparators = []string{"\n\n", "\n", ". ", " ", ""}
+
+// Score normalizations
+const (
+	NormalizationNone   = "none"   // Combine scores as they are
+	NormalizationMinMax = "minmax" // Scale scores to 0-1 over the results
+	NormalizationZScore = "zscore" // Scale scores to standard deviations from the mean of the results
+)
+
+// SearchConfig represents the default retrieval settings used by search and chat
+type SearchConfig struct {
+	Normalization string  `mapstructure:"normalization" yaml:"normalization"` // How vector, text and rerank scores are scaled before they are combined: "none", "minmax" or "zscore"
+	PathWeight    float64 `mapstructure:"path_weight" yaml:"path_weight"`     // Weight of the similarity between the query and the file path added to the combined score (0 = off)
+
+	// Ranking profiles by name, in addition to or overriding the built-in
+	// ones (see BuiltinProfiles)
+	Profiles map[string]RankingProfile `mapstructure:"profiles" yaml:"profiles"`
+}
+
+// RankingProfile is a named preset of retrieval settings, selected with
+// --profile in search and chat. Settings left at zero keep their defaults,
+// and flags given along with the profile override it.
+type RankingProfile struct {
+	SearchType   string  `mapstructure:"search_type" yaml:"search_type"`     // "vector", "text", "hybrid", "semantic", "bm25" or "fusion"
+	VectorWeight float64 `mapstructure:"vector_weight" yaml:"vector_weight"` // Weight for vector similarity in hybrid search
+	TextWeight   float64 `mapstructure:"text_weight" yaml:"text_weight"`     // Weight for text similarity in hybrid search
+	Rerank       bool    `mapstructure:"rerank" yaml:"rerank"`               // Rerank the results
+	Retrieve     int     `mapstructure:"retrieve" yaml:"retrieve"`           // Candidates retrieved for reranking
+	MMR          float64 `mapstructure:"mmr" yaml:"mmr"`                     // Maximal marginal relevance lambda: 1 = relevance only, lower values diversify the results (0 = off)
+	Limit        int     `mapstructure:"limit" yaml:"limit"`                 // Number of results, or of context documents in chat
+	MinScore     float64 `mapstructure:"min_score" yaml:"min_score"`         // Minimum similarity score
+}
+
+// BuiltinProfiles are the ranking profiles available without configuring
+// any
+var BuiltinProfiles = map[string]RankingProfile{
+	// Few results, reranked from a large pool
+	"precision": {SearchType: "hybrid", VectorWeight: 0.7, TextWeight: 0.3, Rerank: true, Retrieve: 50, Limit: 5},
+	// Many diverse results of dense and keyword retrieval
+	"recall": {SearchType: "fusion", MMR: 0.7, Limit: 20},
+	// Identifiers and exact terms count for more than meaning
+	"code-search": {SearchType: "hybrid", VectorWeight: 0.4, TextWeight: 0.6, Limit: 10},
+	// The few closest answers to a question, if any are close
+	"faq": {SearchType: "vector", Limit: 3, MinScore: 0.4},
+}
+
+// searchTypes are the search types a ranking profile may have
+var searchTypes = []string{"vector", "text", "hybrid", "semantic", "bm25", "fusion"}
+
+// CalibrationConfig represents the mapping of combined search scores to a
+// 0-1 confidence, fitted from labeled queries with 'rag-cli search --calibrate'
+type CalibrationConfig struct {
+	SearchType    string  `mapstructure:"search_type" yaml:"search_type"`       // Search type the calibration was fitted for (empty = not fitted)
+	Slope         float64 `mapstructure:"slope" yaml:"slope"`                   // Logistic slope
+	Intercept     float64 `mapstructure:"intercept" yaml:"intercept"`           // Logistic intercept
+	MinConfidence float64 `mapstructure:"min_confidence" yaml:"min_confidence"` // Confidence below which chat abstains from answering (0 = never)
+}
+
+// Rerank methods
+const (
+	RerankMethodEmbedding    = "embedding"     // Cosine similarity of the query and passage embeddings
+	RerankMethodLLM          = "llm"           // Relevance scores asked of a chat model, one request per passage
+	RerankMethodCrossEncoder = "cross-encoder" // Relevance scores of a dedicated reranker model, all passages in one request
+)
+
+// DefaultCohereRerankModel is the Cohere model used when rerank.model isn't set
+const DefaultCohereRerankModel = "rerank-v3.5"
+
+// RerankConfig represents the default reranking settings used by search and chat
+type RerankConfig struct {
+	Instruction    string  `mapstructure:"instruction" yaml:"instruction"`
+	OriginalWeight float64 `mapstructure:"original_weight" yaml:"original_weight"`
+	RerankWeight   float64 `mapstructure:"rerank_weight" yaml:"rerank_weight"`
+	Limit          int     `mapstructure:"limit" yaml:"limit"`             // Number of results to rerank (0 = all)
+	Retrieve       int     `mapstructure:"retrieve" yaml:"retrieve"`       // Candidates retrieved for reranking before the top results are returned (0 = as many as are returned)
+	Backend        string  `mapstructure:"backend" yaml:"backend"`         // "ollama", "openai" or "cohere" (defaults to embedding_backend if not specified)
+	Model          string  `mapstructure:"model" yaml:"model"`             // Overrides the backend's model used for reranking (its embedding model, its chat model in llm mode, or its reranker model in cross-encoder mode)
+	Method         string  `mapstructure:"method" yaml:"method"`           // "embedding", "llm" or "cross-encoder" (defaults to cross-encoder for cohere)
+	BaseURL        string  `mapstructure:"base_url" yaml:"base_url"`       // Base URL of the rerank endpoint in cross-encoder mode, e.g. http://localhost:8080/v1 (defaults to the backend's URL)
+	APIKey         string  `mapstructure:"api_key" yaml:"api_key"`         // Bearer token of the rerank endpoint (defaults to openai.api_key for openai)
+	Concurrency    int     `mapstructure:"concurrency" yaml:"concurrency"` // Passages scored at the same time in llm mode (0 = 4)
+}
+
+// ExpansionConfig represents the query expansion settings used by search and chat
+type ExpansionConfig struct {
+	Enabled     bool                `mapstructure:"enabled" yaml:"enabled"`
+	Paraphrases int                 `mapstructure:"paraphrases" yaml:"paraphrases"` // Number of LLM paraphrases (0 = disabled)
+	Model       string              `mapstructure:"model" yaml:"model"`             // Chat model used for paraphrases and rewrites (defaults to the chat model)
+	Synonyms    map[string][]string `mapstructure:"synonyms" yaml:"synonyms"`       // Terms and their synonyms, e.g. k8s: [kubernetes]
+	Rewrite     bool                `mapstructure:"rewrite" yaml:"rewrite"`         // Ask the chat model for better search queries before retrieval, even when expansion is disabled
+	Rewrites    int                 `mapstructure:"rewrites" yaml:"rewrites"`       // Number of search queries rewritten from the query (0 = 3)
+}
+
+// DecompositionConfig represents the splitting of complex chat questions
+// into sub-questions that are retrieved separately
+type DecompositionConfig struct {
+	Enabled         bool   `mapstructure:"enabled" yaml:"enabled"`
+	MaxSubQuestions int    `mapstructure:"max_sub_questions" yaml:"max_sub_questions"` // Most sub-questions per question (0 = 3)
+	Model           string `mapstructure:"model" yaml:"model"`                         // Chat model used to split questions (defaults to the chat model)
+}
+
+// TranslationConfig represents the translation of queries into the language
+// of the documents, for collections in another language than the questions
+type TranslationConfig struct {
+	Enabled          bool   `mapstructure:"enabled" yaml:"enabled"`
+	Language         string `mapstructure:"language" yaml:"language"`                   // Language of the documents queries are translated into, e.g. German
+	TranslateResults bool   `mapstructure:"translate_results" yaml:"translate_results"` // Translate retrieved documents back into the language of the question
+	Model            string `mapstructure:"model" yaml:"model"`                         // Chat model used for translations (defaults to the chat model)
+}
+
+// FilePlaceholder is replaced with the path of the audio file in the record
+// and play commands of the voice settings
+const FilePlaceholder = "{file}"
+
+// VoiceConfig represents the speech input and output of chat. Speech is
+// transcribed and synthesized by servers with OpenAI-compatible audio
+// endpoints, such as a Whisper server, and recorded and played by commands.
+type VoiceConfig struct {
+	TranscriptionURL   string `mapstructure:"transcription_url" yaml:"transcription_url"`     // Speech-to-text endpoint, e.g. http://localhost:8000/v1/audio/transcriptions
+	TranscriptionModel string `mapstructure:"transcription_model" yaml:"transcription_model"` // Whisper model of the transcription server
+	Language           string `mapstructure:"language" yaml:"language"`                       // Language spoken, e.g. en (empty = detected by the model)
+	RecordCommand      string `mapstructure:"record_command" yaml:"record_command"`           // Records a question into {file} as WAV, stopping when the speaker does
+	SpeechURL          string `mapstructure:"speech_url" yaml:"speech_url"`                   // Text-to-speech endpoint, e.g. http://localhost:8000/v1/audio/speech
+	SpeechModel        string `mapstructure:"speech_model" yaml:"speech_model"`               // Model of the text-to-speech server
+	Voice              string `mapstructure:"voice" yaml:"voice"`                             // Voice answers are spoken in
+	PlayCommand        string `mapstructure:"play_command" yaml:"play_command"`               // Plays the WAV audio in {file}
+	APIKey             string `mapstructure:"api_key" yaml:"api_key"`                         // Bearer token of both servers (empty for local servers)
+	Timeout            string `mapstructure:"timeout" yaml:"timeout"`                         // How long a transcription or speech request may take (default 60s)
+}
+
+// Spell check modes
+const (
+	SpellCheckModeVocabulary = "vocabulary" // Nearest terms from the collection's vocabulary (pg_trgm)
+	SpellCheckModeLLM        = "llm"        // Corrections suggested by the chat model
+)
+
+// SpellCheckConfig represents the query spell check settings used by search and chat
+type SpellCheckConfig struct {
+	Enabled       bool    `mapstructure:"enabled" yaml:"enabled"`
+	Mode          string  `mapstructure:"mode" yaml:"mode"`                     // "vocabulary" or "llm"
+	Model         string  `mapstructure:"model" yaml:"model"`                   // Chat model used in llm mode (defaults to the chat model)
+	MinSimilarity float64 `mapstructure:"min_similarity" yaml:"min_similarity"` // Minimum trigram similarity of a vocabulary correction (0 = 0.5)
+	AutoCorrect   bool    `mapstructure:"auto_correct" yaml:"auto_correct"`     // Search with the corrected query instead of only suggesting it
 }
 
+// HistoryConfig represents how chat keeps long conversations within the
+// context of the chat model
+type HistoryConfig struct {
+	Summarize      bool   `mapstructure:"summarize" yaml:"summarize"`             // Replace older turns with a summary once the history is long
+	SummarizeAfter int    `mapstructure:"summarize_after" yaml:"summarize_after"` // Estimated tokens of history that trigger a summary (0 = 3000)
+	KeepTurns      int    `mapstructure:"keep_turns" yaml:"keep_turns"`           // Most recent turns kept word for word (0 = 2)
+	Model          string `mapstructure:"model" yaml:"model"`                     // Chat model used for summaries (defaults to the chat model)
+}
+
+// DefaultAbstentionMessage is the answer of chat and ask when retrieval finds
+// nothing good enough to answer from
+const DefaultAbstentionMessage = "There is insufficient information in the collection to answer this."
+
+// AbstentionConfig represents how chat and ask answer when every retrieved
+// document scores below the minimum score, instead of letting the model guess
+type AbstentionConfig struct {
+	Enabled    bool   `mapstructure:"enabled" yaml:"enabled"`
+	Message    string `mapstructure:"message" yaml:"message"`         // Answer given instead (defaults to DefaultAbstentionMessage)
+	NearMisses int    `mapstructure:"near_misses" yaml:"near_misses"` // Closest documents listed with the answer (0 = 3)
+}
+
+// BudgetConfig represents the limits on backend requests that guard against
+// unexpected bills
+type BudgetConfig struct {
+	MaxDailyCost      float64               `mapstructure:"max_daily_cost" yaml:"max_daily_cost"`           // Estimated USD spent on OpenAI per day (0 = unlimited)
+	MaxRequestTokens  int                   `mapstructure:"max_request_tokens" yaml:"max_request_tokens"`   // Estimated tokens sent in one request (0 = unlimited)
+	MaxEmbeddingCalls int                   `mapstructure:"max_embedding_calls" yaml:"max_embedding_calls"` // Embedding requests per index run (0 = unlimited)
+	ConfirmAbove      float64               `mapstructure:"confirm_above" yaml:"confirm_above"`             // Estimated USD of an OpenAI index run above which index asks to continue (0 = never ask)
+	Prices            map[string]ModelPrice `mapstructure:"prices" yaml:"prices"`                           // USD per million tokens by model, in addition to the built-in OpenAI prices
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Input  float64 `mapstructure:"input" yaml:"input"`
+	Output float64 `mapstructure:"output" yaml:"output"`
+}
+
+// Secret scan actions
+const (
+	SecretsActionSkip   = "skip"   // Warn and leave chunks with secrets out of the index
+	SecretsActionRefuse = "refuse" // Don't index files with secrets at all
+)
+
+// SecretsConfig represents the scan for secrets in chunks before they are
+// embedded, so secrets are neither stored nor sent to a hosted API
+type SecretsConfig struct {
+	Enabled bool         `mapstructure:"enabled" yaml:"enabled"`
+	Action  string       `mapstructure:"action" yaml:"action"` // "skip" or "refuse"
+	Rules   []SecretRule `mapstructure:"rules" yaml:"rules"`   // Patterns scanned for in addition to the built-in rules
+	Allow   []string     `mapstructure:"allow" yaml:"allow"`   // Patterns of matches that aren't secrets, e.g. EXAMPLE
+}
+
+// IgnoreConfig represents the files left out of indexing, in addition to
+// those ignored by the .ragignore files of collection folders
+type IgnoreConfig struct {
+	GitIgnore bool     `mapstructure:"gitignore" yaml:"gitignore"` // Also leave out the files ignored by .gitignore files
+	Patterns  []string `mapstructure:"patterns" yaml:"patterns"`   // Patterns in the gitignore format left out of every folder, e.g. node_modules/
+}
+
+// SecretRule is a named regular expression that matches a secret
+type SecretRule struct {
+	Name    string `mapstructure:"name" yaml:"name"`
+	Pattern string `mapstructure:"pattern" yaml:"pattern"`
+}
+
+// EncryptionConfig represents the encryption of document content stored in
+// the database. One of the key settings gives the 256-bit key, base64 encoded.
+type EncryptionConfig struct {
+	Enabled    bool   `mapstructure:"enabled" yaml:"enabled"`
+	Key        string `mapstructure:"key" yaml:"key"`                 // The key itself
+	KeyFile    string `mapstructure:"key_file" yaml:"key_file"`       // File holding the key
+	KeyCommand string `mapstructure:"key_command" yaml:"key_command"` // Shell command printing the key, e.g. from a keychain or KMS
+}
+
+// TelemetryConfig represents the opt-in, anonymous usage statistics
+type TelemetryConfig struct {
+	Enabled  bool   `mapstructure:"enabled" yaml:"enabled"`
+	Endpoint string `mapstructure:"endpoint" yaml:"endpoint"` // Where reports are sent (defaults to the endpoint of the build)
+}
+
+// PathsConfig maps collection folders to where they are on this machine.
+// Documents are stored relative to the collection folder they were indexed
+// from, so a collection indexed elsewhere (or before a folder was moved) can
+// be used here by mapping its folders to local ones.
+type PathsConfig struct {
+	Roots []RootMapping `mapstructure:"roots" yaml:"roots"`
+}
+
+// RootMapping maps a collection folder, or a parent of collection folders, to a local path
+type RootMapping struct {
+	Folder string `mapstructure:"folder" yaml:"folder"` // Folder as stored in the collection
+	Path   string `mapstructure:"path" yaml:"path"`     // Where that folder is on this machine
+}
+
+// ServerConfig represents the HTTP server settings used by serve
+type ServerConfig struct {
+	Listen          string `mapstructure:"listen" yaml:"listen"`                     // Address to listen on (default 127.0.0.1:8080)
+	TLSCert         string `mapstructure:"tls_cert" yaml:"tls_cert"`                 // Certificate file, enables HTTPS together with tls_key
+	TLSKey          string `mapstructure:"tls_key" yaml:"tls_key"`                   // Private key file
+	ShutdownTimeout string `mapstructure:"shutdown_timeout" yaml:"shutdown_timeout"` // How long in-flight requests may take to finish on shutdown (default 30s)
+	SessionTTL      string `mapstructure:"session_ttl" yaml:"session_ttl"`           // How long an idle chat session is kept (default 1h, 0 = forever)
+}
+
+// BotsConfig represents the chat bot integrations run by bot
+type BotsConfig struct {
+	Slack    SlackConfig    `mapstructure:"slack" yaml:"slack"`
+	Telegram TelegramConfig `mapstructure:"telegram" yaml:"telegram"`
+	Discord  DiscordConfig  `mapstructure:"discord" yaml:"discord"`
+}
+
+// ChannelMapping maps the channels a bot is in to the collections their
+// questions are answered from
+type ChannelMapping struct {
+	Collection string            `mapstructure:"collection" yaml:"collection"` // Collection used for channels that aren't mapped
+	Channels   map[string]string `mapstructure:"channels" yaml:"channels"`     // Channel, chat or server ID to collection
+}
+
+// CollectionFor returns the collection questions from a channel are answered
+// from: the channel's own, then the group's (such as a Discord server), then
+// the default. Channel IDs are matched case-insensitively because the
+// configuration file lowercases map keys.
+func (m *ChannelMapping) CollectionFor(channel, group string) string {
+	for _, id := range []string{channel, group} {
+		if id == "" {
+			continue
+		}
+		for key, collection := range m.Channels {
+			if strings.EqualFold(key, id) {
+				return collection
+			}
+		}
+	}
+	return m.Collection
+}
+
+// Collections returns every collection the mapping refers to
+func (m *ChannelMapping) Collections() []string {
+	var collections []string
+	seen := make(map[string]bool)
+	for _, collection := range append([]string{m.Collection}, mapValues(m.Channels)...) {
+		if collection != "" && !seen[collection] {
+			seen[collection] = true
+			collections = append(collections, collection)
+		}
+	}
+	return collections
+}
+
+// mapValues returns the values of a map sorted by key
+func mapValues(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(m))
+	for _, key := range keys {
+		values = append(values, m[key])
+	}
+	return values
+}
+
+// SlackConfig represents the Slack bot settings. The bot connects with
+// Socket Mode, so it needs an app-level token as well as a bot token.
+type SlackConfig struct {
+	AppToken       string `mapstructure:"app_token" yaml:"app_token"` // App-level token (xapp-...) with connections:write
+	BotToken       string `mapstructure:"bot_token" yaml:"bot_token"` // Bot token (xoxb-...) with app_mentions:read and chat:write
+	ChannelMapping `mapstructure:",squash" yaml:",inline"`
+}
+
+// TelegramConfig represents the Telegram bot settings
+type TelegramConfig struct {
+	Token          string `mapstructure:"token" yaml:"token"` // Bot token from @BotFather
+	ChannelMapping `mapstructure:",squash" yaml:",inline"`
+}
+
+// DiscordConfig represents the Discord bot settings
+type DiscordConfig struct {
+	Token          string `mapstructure:"token" yaml:"token"` // Bot token with the Message Content intent enabled
+	ChannelMapping `mapstructure:",squash" yaml:",inline"`
+}
+
+// Configured reports whether the bot has the tokens it needs to run
+func (c *SlackConfig) Configured() bool { return c.AppToken != "" && c.BotToken != "" }
+
+// Configured reports whether the bot has the token it needs to run
+func (c *TelegramConfig) Configured() bool { return c.Token != "" }
+
+// Configured reports whether the bot has the token it needs to run
+func (c *DiscordConfig) Configured() bool { return c.Token != "" }
+
 // GeneralConfig represents general application configuration
 type GeneralConfig struct {
-	LogLevel string `mapstructure:"log_level" yaml:"log_level"`
-	DataDir  string `mapstructure:"data_dir" yaml:"data_dir"`
+	LogLevel   string `mapstructure:"log_level" yaml:"log_level,omitempty"` // Deprecated: not used; use --verbose
+	DataDir    string `mapstructure:"data_dir" yaml:"data_dir"`
+	PromptsDir string `mapstructure:"prompts_dir" yaml:"prompts_dir"` // Directory of prompt files used alongside the stored prompts
+}
+
+// GetDataDir returns the data directory with a leading ~ expanded to the home directory
+func (c *GeneralConfig) GetDataDir() string {
+	return expandHome(c.DataDir)
+}
+
+// GetPromptsDir returns the prompts directory with a leading ~ expanded to
+// the home directory (default ~/.rag-cli/prompts)
+func (c *GeneralConfig) GetPromptsDir() string {
+	if c.PromptsDir == "" {
+		return expandHome(filepath.Join("~", ".rag-cli", "prompts"))
+	}
+	return expandHome(c.PromptsDir)
+}
+
+// expandHome expands a leading ~ in a path to the home directory. Both / and
+// \ are accepted after the ~ so the same configuration works on Windows.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, filepath.FromSlash(strings.ReplaceAll(path[1:], `\`, "/")))
 }
 
 // Validate checks if the embedding configuration is valid
 func (c *EmbeddingConfig) Validate() error {
+	switch c.ChunkStrategy {
+	case "", ChunkStrategyCharacters, ChunkStrategyTokens, ChunkStrategyRecursive:
+	default:
+		return fmt.Errorf("invalid chunk strategy: %s. Valid strategies are: characters, tokens, recursive", c.ChunkStrategy)
+	}
 	if c.ChunkSize <= 0 {
 		return fmt.Errorf("chunk size must be greater than 0")
 	}
@@ -86,69 +520,842 @@ func (c *EmbeddingConfig) Validate() error {
 	if c.SimilarityThreshold < 0 || c.SimilarityThreshold > 1 {
 		return fmt.Errorf("similarity threshold must be between 0 and 1")
 	}
-	if c.MaxResults <= 0 {
-		return fmt.Errorf("max results must be greater than 0")
+	if c.MaxResults < 0 {
+		return fmt.Errorf("max results cannot be negative")
 	}
 	if c.Dimensions <= 0 {
 		return fmt.Errorf("embedding dimensions must be greater than 0")
 	}
+	if c.Concurrency < 0 {
+		return fmt.Errorf("embedding concurrency cannot be negative")
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("embedding batch size cannot be negative")
+	}
+	for model, dimensions := range c.ModelDimensions {
+		if dimensions <= 0 {
+			return fmt.Errorf("dimensions of embedding model %s must be greater than 0", model)
+		}
+	}
+	return nil
+}
+
+// GetSeparators returns where the recursive chunk strategy splits text, in
+// order of preference, DefaultSeparators when none are configured
+func (c *EmbeddingConfig) GetSeparators() []string {
+	if len(c.Separators) == 0 {
+		return DefaultSeparators
+	}
+	return c.Separators
+}
+
+// GetChunkStrategy returns how text is split into chunks, characters by
+// default
+func (c *EmbeddingConfig) GetChunkStrategy() string {
+	if c.ChunkStrategy == "" {
+		return ChunkStrategyCharacters
+	}
+	return c.ChunkStrategy
+}
+
+// GetConcurrency returns the number of files chunked and embedded at once when indexing
+func (c *EmbeddingConfig) GetConcurrency() int {
+	if c.Concurrency <= 0 {
+		return 4
+	}
+	return c.Concurrency
+}
+
+// GetBatchSize returns the number of chunks embedded per request when indexing
+func (c *EmbeddingConfig) GetBatchSize() int {
+	if c.BatchSize <= 0 {
+		return 32
+	}
+	return c.BatchSize
+}
+
+// Validate checks if the rerank configuration is valid
+func (c *RerankConfig) Validate() error {
+	if c.OriginalWeight < 0 || c.OriginalWeight > 1 {
+		return fmt.Errorf("original weight must be between 0 and 1")
+	}
+	if c.RerankWeight < 0 || c.RerankWeight > 1 {
+		return fmt.Errorf("rerank weight must be between 0 and 1")
+	}
+	if c.Limit < 0 {
+		return fmt.Errorf("rerank limit cannot be negative")
+	}
+	if c.Retrieve < 0 {
+		return fmt.Errorf("rerank retrieve cannot be negative")
+	}
+	if c.Method != "" && c.Method != RerankMethodEmbedding && c.Method != RerankMethodLLM && c.Method != RerankMethodCrossEncoder {
+		return fmt.Errorf("invalid rerank method: %s. Must be '%s', '%s' or '%s'", c.Method, RerankMethodEmbedding, RerankMethodLLM, RerankMethodCrossEncoder)
+	}
+	if c.Concurrency < 0 {
+		return fmt.Errorf("rerank concurrency cannot be negative")
+	}
+	if c.Backend != "" && c.Backend != "ollama" && c.Backend != "openai" && c.Backend != "cohere" {
+		return fmt.Errorf("invalid rerank backend: %s. Must be 'ollama', 'openai' or 'cohere'", c.Backend)
+	}
+	if c.Backend == "cohere" && c.GetMethod() != RerankMethodCrossEncoder {
+		return fmt.Errorf("rerank backend cohere only supports the %s method", RerankMethodCrossEncoder)
+	}
+	return nil
+}
+
+// GetMethod returns the rerank method, defaulting to cross-encoder for
+// cohere and to embedding otherwise
+func (c *RerankConfig) GetMethod() string {
+	if c.Method == "" && c.Backend == "cohere" {
+		return RerankMethodCrossEncoder
+	}
+	if c.Method == "" {
+		return RerankMethodEmbedding
+	}
+	return c.Method
+}
+
+// GetConcurrency returns the number of passages scored at the same time in llm mode
+func (c *RerankConfig) GetConcurrency() int {
+	if c.Concurrency <= 0 {
+		return 4
+	}
+	return c.Concurrency
+}
+
+// MaxParaphrases is the maximum number of LLM paraphrases per query
+const MaxParaphrases = 10
+
+// Validate checks if the expansion configuration is valid
+func (c *ExpansionConfig) Validate() error {
+	if c.Paraphrases < 0 || c.Paraphrases > MaxParaphrases {
+		return fmt.Errorf("paraphrases must be between 0 and %d", MaxParaphrases)
+	}
+	if c.Rewrites < 0 || c.Rewrites > MaxParaphrases {
+		return fmt.Errorf("rewrites must be between 0 and %d", MaxParaphrases)
+	}
+	for term, synonyms := range c.Synonyms {
+		if term == "" {
+			return fmt.Errorf("synonym terms cannot be empty")
+		}
+		if len(synonyms) == 0 {
+			return fmt.Errorf("no synonyms given for %q", term)
+		}
+	}
+	return nil
+}
+
+// GetRewrites returns the number of search queries rewritten from a query
+func (c *ExpansionConfig) GetRewrites() int {
+	if c.Rewrites <= 0 {
+		return 3
+	}
+	return c.Rewrites
+}
+
+// MaxSubQuestions is the maximum number of sub-questions per question
+const MaxSubQuestions = 8
+
+// Validate checks if the decomposition configuration is valid
+func (c *DecompositionConfig) Validate() error {
+	if c.MaxSubQuestions < 0 || c.MaxSubQuestions > MaxSubQuestions {
+		return fmt.Errorf("max sub-questions must be between 0 and %d", MaxSubQuestions)
+	}
+	return nil
+}
+
+// Validate checks if the translation configuration is valid
+func (c *TranslationConfig) Validate() error {
+	if c.Enabled && strings.TrimSpace(c.Language) == "" {
+		return fmt.Errorf("language is required when translation is enabled")
+	}
+	return nil
+}
+
+// Validate checks if the voice configuration is valid
+func (c *VoiceConfig) Validate() error {
+	for _, endpoint := range []struct{ name, url string }{
+		{"transcription_url", c.TranscriptionURL},
+		{"speech_url", c.SpeechURL},
+	} {
+		if endpoint.url == "" {
+			continue
+		}
+		if u, err := url.Parse(endpoint.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s must be an http or https URL", endpoint.name)
+		}
+	}
+	for _, command := range []struct{ name, command string }{
+		{"record_command", c.RecordCommand},
+		{"play_command", c.PlayCommand},
+	} {
+		if command.command != "" && !strings.Contains(command.command, FilePlaceholder) {
+			return fmt.Errorf("%s must contain %s, the path of the audio file", command.name, FilePlaceholder)
+		}
+	}
+	if c.Timeout != "" {
+		timeout, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be greater than 0")
+		}
+	}
+	return nil
+}
+
+// GetTimeout returns how long a transcription or speech request may take,
+// 60 seconds by default
+func (c *VoiceConfig) GetTimeout() time.Duration {
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout <= 0 {
+		return 60 * time.Second
+	}
+	return timeout
+}
+
+// GetMaxSubQuestions returns the most sub-questions a question is split into
+func (c *DecompositionConfig) GetMaxSubQuestions() int {
+	if c.MaxSubQuestions <= 0 {
+		return 3
+	}
+	return c.MaxSubQuestions
+}
+
+// Validate checks if the search configuration is valid
+func (c *SearchConfig) Validate() error {
+	switch c.Normalization {
+	case "", NormalizationNone, NormalizationMinMax, NormalizationZScore:
+	default:
+		return fmt.Errorf("invalid score normalization: %s. Must be '%s', '%s' or '%s'", c.Normalization, NormalizationNone, NormalizationMinMax, NormalizationZScore)
+	}
+	if c.PathWeight < 0 || c.PathWeight > 1 {
+		return fmt.Errorf("path weight must be between 0 and 1")
+	}
+	for name, profile := range c.Profiles {
+		if name == "" {
+			return fmt.Errorf("profile names cannot be empty")
+		}
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks if the ranking profile is valid
+func (p *RankingProfile) Validate() error {
+	if p.SearchType != "" && !slices.Contains(searchTypes, p.SearchType) {
+		return fmt.Errorf("invalid search type: %s. Must be one of %s", p.SearchType, strings.Join(searchTypes, ", "))
+	}
+	if p.VectorWeight < 0 || p.VectorWeight > 1 || p.TextWeight < 0 || p.TextWeight > 1 {
+		return fmt.Errorf("weights must be between 0 and 1")
+	}
+	if p.MMR < 0 || p.MMR > 1 {
+		return fmt.Errorf("mmr must be between 0 and 1")
+	}
+	if p.Limit < 0 || p.Retrieve < 0 {
+		return fmt.Errorf("limit and retrieve cannot be negative")
+	}
+	return nil
+}
+
+// Profile returns the ranking profile of a name, configured or built in
+func (c *SearchConfig) Profile(name string) (RankingProfile, error) {
+	if profile, ok := c.Profiles[name]; ok {
+		return profile, nil
+	}
+	if profile, ok := BuiltinProfiles[name]; ok {
+		return profile, nil
+	}
+	return RankingProfile{}, fmt.Errorf("unknown ranking profile: %s. Must be one of %s", name, strings.Join(c.ProfileNames(), ", "))
+}
+
+// ProfileNames returns the names of the configured and built-in ranking
+// profiles in order
+func (c *SearchConfig) ProfileNames() []string {
+	names := slices.Collect(maps.Keys(BuiltinProfiles))
+	for name := range c.Profiles {
+		if _, ok := BuiltinProfiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// GetNormalization returns the score normalization, defaulting to none
+func (c *SearchConfig) GetNormalization() string {
+	if c.Normalization == "" {
+		return NormalizationNone
+	}
+	return c.Normalization
+}
+
+// Validate checks if the calibration configuration is valid
+func (c *CalibrationConfig) Validate() error {
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		return fmt.Errorf("min confidence must be between 0 and 1")
+	}
+	return nil
+}
+
+// Fitted reports whether a calibration was fitted for searchType
+func (c *CalibrationConfig) Fitted(searchType string) bool {
+	return c.SearchType != "" && c.SearchType == searchType
+}
+
+// Validate checks if the spell check configuration is valid
+func (c *SpellCheckConfig) Validate() error {
+	if c.Mode != "" && c.Mode != SpellCheckModeVocabulary && c.Mode != SpellCheckModeLLM {
+		return fmt.Errorf("invalid spell check mode: %s. Must be '%s' or '%s'", c.Mode, SpellCheckModeVocabulary, SpellCheckModeLLM)
+	}
+	if c.MinSimilarity < 0 || c.MinSimilarity > 1 {
+		return fmt.Errorf("min similarity must be between 0 and 1")
+	}
+	return nil
+}
+
+// GetMode returns the spell check mode, defaulting to vocabulary
+func (c *SpellCheckConfig) GetMode() string {
+	if c.Mode == "" {
+		return SpellCheckModeVocabulary
+	}
+	return c.Mode
+}
+
+// GetMinSimilarity returns the minimum similarity of a vocabulary correction
+func (c *SpellCheckConfig) GetMinSimilarity() float64 {
+	if c.MinSimilarity <= 0 {
+		return 0.5
+	}
+	return c.MinSimilarity
+}
+
+// Validate checks if the history configuration is valid
+func (c *HistoryConfig) Validate() error {
+	if c.SummarizeAfter < 0 {
+		return fmt.Errorf("summarize after cannot be negative")
+	}
+	if c.KeepTurns < 0 {
+		return fmt.Errorf("keep turns cannot be negative")
+	}
+	return nil
+}
+
+// GetSummarizeAfter returns the estimated number of history tokens that
+// trigger a summary
+func (c *HistoryConfig) GetSummarizeAfter() int {
+	if c.SummarizeAfter <= 0 {
+		return 3000
+	}
+	return c.SummarizeAfter
+}
+
+// GetKeepTurns returns the number of recent turns that aren't summarized
+func (c *HistoryConfig) GetKeepTurns() int {
+	if c.KeepTurns <= 0 {
+		return 2
+	}
+	return c.KeepTurns
+}
+
+// Validate checks if the abstention configuration is valid
+func (c *AbstentionConfig) Validate() error {
+	if c.NearMisses < 0 {
+		return fmt.Errorf("near misses cannot be negative")
+	}
+	return nil
+}
+
+// GetMessage returns the answer given when abstaining
+func (c *AbstentionConfig) GetMessage() string {
+	if strings.TrimSpace(c.Message) == "" {
+		return DefaultAbstentionMessage
+	}
+	return c.Message
+}
+
+// GetNearMisses returns the number of closest documents listed when abstaining
+func (c *AbstentionConfig) GetNearMisses() int {
+	if c.NearMisses <= 0 {
+		return 3
+	}
+	return c.NearMisses
+}
+
+// Validate checks if the budget configuration is valid
+func (c *BudgetConfig) Validate() error {
+	if c.MaxDailyCost < 0 {
+		return fmt.Errorf("max daily cost cannot be negative")
+	}
+	if c.MaxRequestTokens < 0 {
+		return fmt.Errorf("max request tokens cannot be negative")
+	}
+	if c.MaxEmbeddingCalls < 0 {
+		return fmt.Errorf("max embedding calls cannot be negative")
+	}
+	if c.ConfirmAbove < 0 {
+		return fmt.Errorf("confirm above cannot be negative")
+	}
+	for model, price := range c.Prices {
+		if model == "" {
+			return fmt.Errorf("price models cannot be empty")
+		}
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("prices of %s cannot be negative", model)
+		}
+	}
+	return nil
+}
+
+// Validate checks if the secrets configuration is valid
+func (c *SecretsConfig) Validate() error {
+	if c.Action != "" && c.Action != SecretsActionSkip && c.Action != SecretsActionRefuse {
+		return fmt.Errorf("invalid secrets action: %s. Must be '%s' or '%s'", c.Action, SecretsActionSkip, SecretsActionRefuse)
+	}
+	for _, rule := range c.Rules {
+		if rule.Name == "" || rule.Pattern == "" {
+			return fmt.Errorf("secret rules need both a name and a pattern")
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern of secret rule %s: %w", rule.Name, err)
+		}
+	}
+	for _, pattern := range c.Allow {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid allow pattern %s: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks if the ignore configuration is valid
+func (c *IgnoreConfig) Validate() error {
+	for _, pattern := range c.Patterns {
+		if err := ignore.ValidatePattern(pattern); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Validate checks if the configuration is valid and can connect to the database
+// GetFiles returns the names of the ignore files read in collection folders
+func (c *IgnoreConfig) GetFiles() []string {
+	if c.GitIgnore {
+		return []string{ignore.GitIgnoreFile, ignore.RagIgnoreFile}
+	}
+	return []string{ignore.RagIgnoreFile}
+}
+
+// GetAction returns what happens to chunks with secrets, defaulting to skip
+func (c *SecretsConfig) GetAction() string {
+	if c.Action == "" {
+		return SecretsActionSkip
+	}
+	return c.Action
+}
+
+// Validate checks if the encryption configuration is valid
+func (c *EncryptionConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	sources := 0
+	for _, source := range []string{c.Key, c.KeyFile, c.KeyCommand} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("encryption needs exactly one of key, key_file and key_command")
+	}
+	return nil
+}
+
+// GetKeyFile returns the key file with a leading ~ expanded to the home directory
+func (c *EncryptionConfig) GetKeyFile() string {
+	return expandHome(c.KeyFile)
+}
+
+// Validate checks if the paths configuration is valid
+func (c *PathsConfig) Validate() error {
+	seen := make(map[string]bool)
+	for _, root := range c.Roots {
+		if root.Folder == "" || root.Path == "" {
+			return fmt.Errorf("root mappings need both a folder and a path")
+		}
+		folder := trimSeparators(root.Folder)
+		if isWindowsPath(folder) {
+			folder = strings.ToLower(folder)
+		}
+		if seen[folder] {
+			return fmt.Errorf("folder %s is mapped more than once", root.Folder)
+		}
+		seen[folder] = true
+	}
+	return nil
+}
+
+// LocalRoot returns where a collection folder is on this machine. The most
+// specific mapping that is the folder itself or one of its parents applies;
+// without one the folder is used as is.
+func (c *PathsConfig) LocalRoot(folder string) string {
+	best, bestLength := -1, -1
+	var rest string
+	for i, root := range c.Roots {
+		remainder, ok := cutFolder(folder, trimSeparators(root.Folder))
+		if ok && len(root.Folder) > bestLength {
+			best, bestLength, rest = i, len(root.Folder), remainder
+		}
+	}
+
+	if best < 0 {
+		return folder
+	}
+	// Folders may come from a machine with a different path separator
+	rest = strings.ReplaceAll(rest, `\`, "/")
+	return filepath.Join(expandHome(c.Roots[best].Path), filepath.FromSlash(rest))
+}
+
+// cutFolder returns the part of path below prefix, and whether path is
+// prefix itself or inside it. Windows paths are compared case-insensitively.
+func cutFolder(path, prefix string) (string, bool) {
+	path = trimSeparators(path)
+	hasPrefix := strings.HasPrefix
+	if isWindowsPath(path) {
+		hasPrefix = func(s, prefix string) bool {
+			return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+		}
+	}
+
+	if len(path) == len(prefix) && hasPrefix(path, prefix) {
+		return "", true
+	}
+	if !strings.HasSuffix(prefix, "/") && !strings.HasSuffix(prefix, `\`) {
+		if !hasPrefix(path, prefix+"/") && !hasPrefix(path, prefix+`\`) {
+			return "", false
+		}
+		return path[len(prefix)+1:], true
+	}
+	// prefix is a root such as /
+	if !hasPrefix(path, prefix) {
+		return "", false
+	}
+	return path[len(prefix):], true
+}
+
+// isWindowsPath reports whether a path is a Windows path with a drive letter
+// or a UNC path. Folders may come from a collection indexed on Windows, so
+// this doesn't depend on the platform rag-cli runs on.
+func isWindowsPath(path string) bool {
+	if strings.HasPrefix(path, `\\`) {
+		return true
+	}
+	return len(path) >= 2 && path[1] == ':' &&
+		(('a' <= path[0] && path[0] <= 'z') || ('A' <= path[0] && path[0] <= 'Z'))
+}
+
+// trimSeparators removes trailing path separators, keeping a lone root separator
+func trimSeparators(path string) string {
+	trimmed := strings.TrimRight(path, `/\`)
+	if trimmed == "" && path != "" {
+		return path[:1]
+	}
+	return trimmed
+}
+
+// Validate checks if the server configuration is valid
+func (c *ServerConfig) Validate() error {
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	if c.ShutdownTimeout != "" {
+		timeout, err := time.ParseDuration(c.ShutdownTimeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("invalid shutdown timeout: %s", c.ShutdownTimeout)
+		}
+	}
+	if c.SessionTTL != "" {
+		ttl, err := time.ParseDuration(c.SessionTTL)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("invalid session TTL: %s", c.SessionTTL)
+		}
+	}
+	return nil
+}
+
+// GetListen returns the address to listen on
+func (c *ServerConfig) GetListen() string {
+	if c.Listen == "" {
+		return "127.0.0.1:8080"
+	}
+	return c.Listen
+}
+
+// GetShutdownTimeout returns how long in-flight requests may take to finish on shutdown
+func (c *ServerConfig) GetShutdownTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(c.ShutdownTimeout); err == nil && timeout >= 0 {
+		return timeout
+	}
+	return 30 * time.Second
+}
+
+// GetSessionTTL returns how long an idle chat session is kept, or 0 if sessions never expire
+func (c *ServerConfig) GetSessionTTL() time.Duration {
+	if ttl, err := time.ParseDuration(c.SessionTTL); err == nil && ttl >= 0 {
+		return ttl
+	}
+	return time.Hour
+}
+
+// TLSEnabled reports whether the server should use HTTPS
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// Validate checks if the bot configuration is valid
+func (c *BotsConfig) Validate() error {
+	if err := c.Slack.Validate(); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	if err := c.Telegram.Validate(); err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	if err := c.Discord.Validate(); err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	return nil
+}
+
+// Validate checks that no channel is mapped to an empty collection
+func (m *ChannelMapping) Validate() error {
+	for channel, collection := range m.Channels {
+		if collection == "" {
+			return fmt.Errorf("channel %s is mapped to an empty collection", channel)
+		}
+	}
+	return nil
+}
+
+// Validate checks if the Slack configuration is valid. Tokens may be left
+// empty here and given when the bot is started.
+func (c *SlackConfig) Validate() error {
+	if c.AppToken != "" && !strings.HasPrefix(c.AppToken, "xapp-") {
+		return fmt.Errorf("app_token must be an app-level token (xapp-...)")
+	}
+	if c.BotToken != "" && !strings.HasPrefix(c.BotToken, "xoxb-") {
+		return fmt.Errorf("bot_token must be a bot token (xoxb-...)")
+	}
+	return c.ChannelMapping.Validate()
+}
+
+// Validate checks if the Telegram configuration is valid
+func (c *TelegramConfig) Validate() error {
+	if c.Token != "" && !strings.Contains(c.Token, ":") {
+		return fmt.Errorf("token must be a bot token from @BotFather (123456:ABC...)")
+	}
+	return c.ChannelMapping.Validate()
+}
+
+// Validate checks if the Discord configuration is valid
+func (c *DiscordConfig) Validate() error {
+	if strings.HasPrefix(c.Token, "Bot ") {
+		return fmt.Errorf("token must not include the \"Bot \" prefix")
+	}
+	return c.ChannelMapping.Validate()
+}
+
+// Requirement describes which parts of the configuration a command depends on
+type Requirement int
+
+const (
+	RequireDatabase  Requirement = 1 << iota // Database connection settings
+	RequireChat                              // Chat backend and chat model
+	RequireEmbedding                         // Embedding backend, model and chunking settings
+	RequireReranker                          // Rerank backend and settings
+
+	RequireNone Requirement = 0
+	RequireAll              = RequireDatabase | RequireChat | RequireEmbedding | RequireReranker
+)
+
+// Has reports whether all requirements in other are part of r
+func (r Requirement) Has(other Requirement) bool {
+	return r&other == other
+}
+
+// Validate checks that the whole configuration is valid
 func (c *Config) Validate() error {
-	// Validate chat backend selection
-	if c.ChatBackend != "ollama" && c.ChatBackend != "openai" {
-		return fmt.Errorf("invalid chat_backend: %s. Must be 'ollama' or 'openai'", c.ChatBackend)
+	if err := c.ValidateFor(RequireAll); err != nil {
+		return err
+	}
+	if err := c.Search.Validate(); err != nil {
+		return fmt.Errorf("search configuration error: %w", err)
+	}
+	if err := c.Calibration.Validate(); err != nil {
+		return fmt.Errorf("calibration configuration error: %w", err)
+	}
+	if err := c.Expansion.Validate(); err != nil {
+		return fmt.Errorf("expansion configuration error: %w", err)
+	}
+	if err := c.Decomposition.Validate(); err != nil {
+		return fmt.Errorf("decomposition configuration error: %w", err)
+	}
+	if err := c.Translation.Validate(); err != nil {
+		return fmt.Errorf("translation configuration error: %w", err)
+	}
+	if err := c.SpellCheck.Validate(); err != nil {
+		return fmt.Errorf("spellcheck configuration error: %w", err)
+	}
+	if err := c.History.Validate(); err != nil {
+		return fmt.Errorf("history configuration error: %w", err)
+	}
+	if err := c.Abstention.Validate(); err != nil {
+		return fmt.Errorf("abstention configuration error: %w", err)
+	}
+	if err := c.Voice.Validate(); err != nil {
+		return fmt.Errorf("voice configuration error: %w", err)
 	}
+	if err := c.Budget.Validate(); err != nil {
+		return fmt.Errorf("budget configuration error: %w", err)
+	}
+	if err := c.Secrets.Validate(); err != nil {
+		return fmt.Errorf("secrets configuration error: %w", err)
+	}
+	if err := c.Ignore.Validate(); err != nil {
+		return fmt.Errorf("ignore configuration error: %w", err)
+	}
+	if err := c.Encryption.Validate(); err != nil {
+		return fmt.Errorf("encryption configuration error: %w", err)
+	}
+	if err := c.Paths.Validate(); err != nil {
+		return fmt.Errorf("paths configuration error: %w", err)
+	}
+	if err := c.Server.Validate(); err != nil {
+		return fmt.Errorf("server configuration error: %w", err)
+	}
+	if err := c.Bots.Validate(); err != nil {
+		return fmt.Errorf("bots configuration error: %w", err)
+	}
+	return nil
+}
 
+// ValidateFor checks only the parts of the configuration needed by the given
+// requirements, so commands that don't talk to an LLM backend (or to the
+// database) are not blocked by unrelated settings. Each backend is validated
+// at most once, for just the models that are actually used.
+func (c *Config) ValidateFor(req Requirement) error {
 	// Set embedding backend to chat backend if not specified
 	if c.EmbeddingBackend == "" {
 		c.EmbeddingBackend = c.ChatBackend
 	}
 
-	// Validate embedding backend selection
-	if c.EmbeddingBackend != "ollama" && c.EmbeddingBackend != "openai" {
-		return fmt.Errorf("invalid embedding_backend: %s. Must be 'ollama' or 'openai'", c.EmbeddingBackend)
+	if req.Has(RequireDatabase) {
+		if err := c.Database.Validate(); err != nil {
+			return fmt.Errorf("database configuration error: %w", err)
+		}
 	}
 
-	// Validate embedding configuration
-	if err := c.Embedding.Validate(); err != nil {
-		return fmt.Errorf("embedding configuration error: %w", err)
+	// Collect which models are needed from each backend
+	var ollamaNeeds, openaiNeeds backendNeeds
+
+	if req.Has(RequireChat) {
+		if !isValidBackend(c.ChatBackend) {
+			return fmt.Errorf("invalid chat_backend: %s. Must be 'ollama' or 'openai'", c.ChatBackend)
+		}
+		backendNeedsFor(c.ChatBackend, &ollamaNeeds, &openaiNeeds).chat = true
 	}
 
-	// Validate database configuration
-	if err := c.Database.Validate(); err != nil {
-		return fmt.Errorf("database configuration error: %w", err)
+	if req.Has(RequireEmbedding) {
+		if !isValidBackend(c.EmbeddingBackend) {
+			return fmt.Errorf("invalid embedding_backend: %s. Must be 'ollama' or 'openai'", c.EmbeddingBackend)
+		}
+		if err := c.Embedding.Validate(); err != nil {
+			return fmt.Errorf("embedding configuration error: %w", err)
+		}
+		backendNeedsFor(c.EmbeddingBackend, &ollamaNeeds, &openaiNeeds).embedding = true
 	}
 
-	// Validate chat backend-specific configuration
-	switch c.ChatBackend {
-	case "ollama":
-		if err := c.Ollama.Validate(); err != nil {
-			return fmt.Errorf("ollama configuration error: %w", err)
+	if req.Has(RequireReranker) {
+		if err := c.Rerank.Validate(); err != nil {
+			return fmt.Errorf("rerank configuration error: %w", err)
 		}
-	case "openai":
-		if err := c.OpenAI.Validate(); err != nil {
-			return fmt.Errorf("openai configuration error: %w", err)
+		rerankBackend := c.Rerank.Backend
+		if rerankBackend == "" {
+			rerankBackend = c.EmbeddingBackend
+		}
+		crossEncoder := c.Rerank.GetMethod() == RerankMethodCrossEncoder
+		if rerankBackend == "cohere" && c.Rerank.APIKey == "" {
+			return fmt.Errorf("rerank configuration error: rerank.api_key is required for cohere")
+		} else if rerankBackend != "cohere" && !isValidBackend(rerankBackend) {
+			return fmt.Errorf("invalid rerank backend: %s. Must be 'ollama', 'openai' or 'cohere'", rerankBackend)
+		}
+		if crossEncoder && c.Rerank.Model == "" && (rerankBackend == "openai" || c.Ollama.RerankerModel == "") && rerankBackend != "cohere" {
+			// Only Ollama has a reranker model, ollama.reranker_model, to fall back on
+			return fmt.Errorf("rerank configuration error: rerank.model is required for %s in %s mode", rerankBackend, RerankMethodCrossEncoder)
+		}
+		// The reranker scores passages with rerank.model, or the backend's
+		// reranker model, or its embedding model (chat model in llm mode,
+		// reranker model in cross-encoder mode). Cohere and rerank.base_url
+		// endpoints don't use the backend's server.
+		rerankerModel := c.Ollama.RerankerModel
+		if rerankBackend == "openai" {
+			rerankerModel = c.OpenAI.RerankerModel
+		}
+		switch {
+		case crossEncoder && (rerankBackend == "cohere" || c.Rerank.BaseURL != ""):
+		case crossEncoder:
+			backendNeedsFor(rerankBackend, &ollamaNeeds, &openaiNeeds).connection = true
+		case c.Rerank.Model == "" && c.Rerank.GetMethod() == RerankMethodLLM:
+			backendNeedsFor(rerankBackend, &ollamaNeeds, &openaiNeeds).chat = true
+		case c.Rerank.Model == "" && rerankerModel == "":
+			backendNeedsFor(rerankBackend, &ollamaNeeds, &openaiNeeds).embedding = true
+		default:
+			backendNeedsFor(rerankBackend, &ollamaNeeds, &openaiNeeds).connection = true
 		}
 	}
 
-	// Validate embedding backend-specific configuration
-	switch c.EmbeddingBackend {
-	case "ollama":
-		if err := c.Ollama.Validate(); err != nil {
-			return fmt.Errorf("ollama embedding configuration error: %w", err)
+	if ollamaNeeds.any() {
+		if err := c.Ollama.validateFor(ollamaNeeds); err != nil {
+			return fmt.Errorf("ollama configuration error: %w", err)
 		}
-	case "openai":
-		if err := c.OpenAI.Validate(); err != nil {
-			return fmt.Errorf("openai embedding configuration error: %w", err)
+	}
+
+	if openaiNeeds.any() {
+		if err := c.OpenAI.validateFor(openaiNeeds); err != nil {
+			return fmt.Errorf("openai configuration error: %w", err)
 		}
 	}
 
 	return nil
 }
 
+// backendNeeds records which parts of a backend configuration are in use
+type backendNeeds struct {
+	connection bool
+	chat       bool
+	embedding  bool
+}
+
+// any reports whether the backend is used at all
+func (n backendNeeds) any() bool {
+	return n.connection || n.chat || n.embedding
+}
+
+// backendNeedsFor returns the needs entry for the named backend
+func backendNeedsFor(backend string, ollama, openai *backendNeeds) *backendNeeds {
+	if backend == "openai" {
+		return openai
+	}
+	return ollama
+}
+
+// isValidBackend checks if the backend name is supported
+func isValidBackend(backend string) bool {
+	return backend == "ollama" || backend == "openai"
+}
+
 // Validate checks if the database configuration is valid
 func (c *DatabaseConfig) Validate() error {
 	if c.Host == "" {
@@ -177,42 +1384,103 @@ func (c *DatabaseConfig) Validate() error {
 		return fmt.Errorf("invalid SSL mode: %s. Valid modes are: disable, allow, prefer, require, verify-ca, verify-full", c.SSLMode)
 	}
 
+	if c.MaxOpenConns < 0 {
+		return fmt.Errorf("max_open_conns cannot be negative")
+	}
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("max_idle_conns cannot be negative")
+	}
+	if c.ConnMaxLifetime != "" {
+		if _, err := time.ParseDuration(c.ConnMaxLifetime); err != nil {
+			return fmt.Errorf("invalid conn_max_lifetime: %w", err)
+		}
+	}
+	if err := validateReconnectTimeout(c.ReconnectTimeout); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // Validate checks if the Ollama configuration is valid
 func (c *OllamaConfig) Validate() error {
+	return c.validateFor(backendNeeds{chat: true, embedding: true})
+}
+
+// validateFor checks the Ollama server settings and only the models in use
+func (c *OllamaConfig) validateFor(needs backendNeeds) error {
 	if c.Host == "" {
 		return fmt.Errorf("ollama host cannot be empty")
 	}
 	if c.Port <= 0 || c.Port > 65535 {
 		return fmt.Errorf("ollama port must be between 1 and 65535")
 	}
-	if c.ChatModel == "" {
+	if needs.chat && c.ChatModel == "" {
 		return fmt.Errorf("ollama chat_model cannot be empty")
 	}
-	if c.EmbeddingModel == "" {
+	if needs.embedding && c.EmbeddingModel == "" {
 		return fmt.Errorf("ollama embed model cannot be empty")
 	}
+	if err := validateReconnectTimeout(c.ReconnectTimeout); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 // Validate checks if the OpenAI configuration is valid
 func (c *OpenAIConfig) Validate() error {
+	return c.validateFor(backendNeeds{chat: true, embedding: true})
+}
+
+// validateFor checks the OpenAI credentials and only the models in use
+func (c *OpenAIConfig) validateFor(needs backendNeeds) error {
 	if c.APIKey == "" {
 		return fmt.Errorf("openai api key cannot be empty")
 	}
-	if c.ChatModel == "" {
+	if needs.chat && c.ChatModel == "" {
 		return fmt.Errorf("openai chat_model cannot be empty")
 	}
-	if c.EmbeddingModel == "" {
+	if needs.embedding && c.EmbeddingModel == "" {
 		return fmt.Errorf("openai embed model cannot be empty")
 	}
+	if needs.embedding && c.InputTypeField != "" && (c.QueryInputType == "" || c.DocumentInputType == "") {
+		return fmt.Errorf("openai query_input_type and document_input_type must be set with input_type_field")
+	}
 
 	return nil
 }
 
+// validateReconnectTimeout checks a reconnect_timeout setting, which may be empty
+func validateReconnectTimeout(value string) error {
+	if value == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid reconnect_timeout: %w", err)
+	}
+	if timeout < 0 {
+		return fmt.Errorf("reconnect_timeout cannot be negative")
+	}
+	return nil
+}
+
+// getReconnectTimeout returns the duration of a reconnect_timeout setting,
+// 30 seconds when it isn't set
+func getReconnectTimeout(value string) time.Duration {
+	if timeout, err := time.ParseDuration(value); err == nil && timeout >= 0 {
+		return timeout
+	}
+	return 30 * time.Second
+}
+
+// GetReconnectTimeout returns how long a request waits for the Ollama
+// server to come back when it is down or reloading a model
+func (c *OllamaConfig) GetReconnectTimeout() time.Duration {
+	return getReconnectTimeout(c.ReconnectTimeout)
+}
+
 // GetServerURL returns the complete Ollama server URL
 func (c *OllamaConfig) GetServerURL() string {
 	protocol := "http"
@@ -272,6 +1540,40 @@ func (c *DatabaseConfig) GetDSN() string {
 	return dsn
 }
 
+// GetMaxOpenConns returns the maximum number of open connections in the pool
+func (c *DatabaseConfig) GetMaxOpenConns() int {
+	if c.MaxOpenConns <= 0 {
+		return 10
+	}
+	return c.MaxOpenConns
+}
+
+// GetMaxIdleConns returns the maximum number of idle connections in the pool
+func (c *DatabaseConfig) GetMaxIdleConns() int {
+	maxIdle := c.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = 5
+	}
+	if maxOpen := c.GetMaxOpenConns(); maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+	return maxIdle
+}
+
+// GetConnMaxLifetime returns how long a pooled connection may be reused
+func (c *DatabaseConfig) GetConnMaxLifetime() time.Duration {
+	if lifetime, err := time.ParseDuration(c.ConnMaxLifetime); err == nil && lifetime > 0 {
+		return lifetime
+	}
+	return 5 * time.Minute
+}
+
+// GetReconnectTimeout returns how long opening a connection waits for the
+// database server to come back when it is down or restarting
+func (c *DatabaseConfig) GetReconnectTimeout() time.Duration {
+	return getReconnectTimeout(c.ReconnectTimeout)
+}
+
 // TestDatabaseConnection tests if the database configuration can successfully connect
 func (c *DatabaseConfig) TestDatabaseConnection() error {
 	dsn := c.GetDSN()
@@ -350,29 +1652,35 @@ func (c *OpenAIConfig) TestOpenAIConnection() error {
 	return nil
 }
 
-// LoadConfig loads configuration from file or creates default if not exists
-func LoadConfig(configName string) (*Config, error) {
+// ConfigFilePath returns the path of a named configuration file, or of the
+// default configuration file when the name is empty
+func ConfigFilePath(configName string) (string, error) {
 	home, err := homedir.Dir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
 
 	configDir := filepath.Join(home, ".rag-cli")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if configName != "" {
+		return filepath.Join(configDir, configName+".yaml"), nil
+	}
+	return filepath.Join(configDir, "config.yaml"), nil
+}
+
+// LoadConfig loads configuration from file or creates default if not exists
+func LoadConfig(configName string) (*Config, error) {
+	configFile, err := ConfigFilePath(configName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create config directory: %w", err)
 	}
 
 	// Set default configuration
 	config := getDefaultConfig()
 
-	// Determine config file name
-	var configFile string
-	if configName != "" {
-		configFile = filepath.Join(configDir, configName+".yaml")
-	} else {
-		configFile = filepath.Join(configDir, "config.yaml")
-	}
-
 	// Check if config file exists
 	if _, err := os.Stat(configFile); os.IsNotExist(err) {
 		// Create default config file
@@ -391,9 +1699,10 @@ func LoadConfig(configName string) (*Config, error) {
 		}
 	}
 
-	// Validate the configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	// Set embedding backend to chat backend if not specified. The rest of the
+	// configuration is validated per command with ValidateFor.
+	if config.EmbeddingBackend == "" {
+		config.EmbeddingBackend = config.ChatBackend
 	}
 
 	return config, nil
@@ -410,6 +1719,23 @@ func SaveConfig(config *Config, configFile string) error {
 	viper.Set("openai", config.OpenAI)
 	viper.Set("database", config.Database)
 	viper.Set("embedding", config.Embedding)
+	viper.Set("search", config.Search)
+	viper.Set("calibration", config.Calibration)
+	viper.Set("rerank", config.Rerank)
+	viper.Set("expansion", config.Expansion)
+	viper.Set("decomposition", config.Decomposition)
+	viper.Set("translation", config.Translation)
+	viper.Set("spellcheck", config.SpellCheck)
+	viper.Set("history", config.History)
+	viper.Set("abstention", config.Abstention)
+	viper.Set("voice", config.Voice)
+	viper.Set("budget", config.Budget)
+	viper.Set("secrets", config.Secrets)
+	viper.Set("encryption", config.Encryption)
+	viper.Set("telemetry", config.Telemetry)
+	viper.Set("paths", config.Paths)
+	viper.Set("server", config.Server)
+	viper.Set("bots", config.Bots)
 	viper.Set("general", config.General)
 
 	return viper.WriteConfig()
@@ -429,6 +1755,8 @@ func getDefaultConfig() *Config {
 			ChatModel:      "qwen3:4b",
 			EmbeddingModel: "dengcao/Qwen3-Embedding-0.6B:Q8_0",
 			RerankerModel:  "dengcao/Qwen3-Reranker-0.6B:Q8_0",
+
+			ReconnectTimeout: "30s",
 		},
 		OpenAI: OpenAIConfig{
 			APIKey:         "",
@@ -438,23 +1766,105 @@ func getDefaultConfig() *Config {
 			RerankerModel:  "text-embedding-3-small", // OpenAI doesn't have dedicated reranker, use embedding model
 		},
 		Database: DatabaseConfig{
-			Host:     "localhost",
-			Port:     5432,
-			Name:     "rag_cli",
-			User:     "postgres",
-			Password: "",
-			SSLMode:  "prefer",
+			Host:             "localhost",
+			Port:             5432,
+			Name:             "rag_cli",
+			User:             "postgres",
+			Password:         "",
+			SSLMode:          "prefer",
+			MaxOpenConns:     10,
+			MaxIdleConns:     5,
+			ConnMaxLifetime:  "5m",
+			ReconnectTimeout: "30s",
 		},
 		Embedding: EmbeddingConfig{
-			ChunkSize:           1000,
-			ChunkOverlap:        200,
-			SimilarityThreshold: 0.7,
-			MaxResults:          10,
-			Dimensions:          1024, // Default to 1024 for dengcao/Qwen3-Embedding-0.6B:Q8_0
+			ChunkStrategy:   ChunkStrategyCharacters,
+			ChunkMarkdown:   false,
+			ChunkCode:       true,
+			ChunkSize:       1000,
+			ChunkOverlap:    200,
+			Dimensions:      1024, // Default to 1024 for dengcao/Qwen3-Embedding-0.6B:Q8_0
+			Concurrency:     4,
+			BatchSize:       32,
+			ModelDimensions: map[string]int{},
+		},
+		Search: SearchConfig{
+			Normalization: NormalizationNone,
+			PathWeight:    0.1,
+			Profiles:      map[string]RankingProfile{},
+		},
+		Calibration: CalibrationConfig{
+			MinConfidence: 0.3,
+		},
+		Rerank: RerankConfig{
+			Instruction:    DefaultRerankInstruction,
+			OriginalWeight: 0.7,
+			RerankWeight:   0.3,
+			Limit:          0,
+			Retrieve:       0,
+			Method:         RerankMethodEmbedding,
+			Concurrency:    4,
+		},
+		Expansion: ExpansionConfig{
+			Enabled:     false,
+			Paraphrases: 3,
+			Synonyms:    map[string][]string{},
+			Rewrites:    3,
+		},
+		Decomposition: DecompositionConfig{
+			Enabled:         false,
+			MaxSubQuestions: 3,
+		},
+		Translation: TranslationConfig{
+			Enabled:          false,
+			TranslateResults: false,
+		},
+		SpellCheck: SpellCheckConfig{
+			Enabled:       false,
+			Mode:          SpellCheckModeVocabulary,
+			MinSimilarity: 0.5,
+			AutoCorrect:   true,
+		},
+		History: HistoryConfig{
+			Summarize:      true,
+			SummarizeAfter: 3000,
+			KeepTurns:      2,
+		},
+		Abstention: AbstentionConfig{
+			Enabled:    false,
+			Message:    DefaultAbstentionMessage,
+			NearMisses: 3,
+		},
+		Voice: VoiceConfig{
+			TranscriptionURL:   "http://localhost:8000/v1/audio/transcriptions",
+			TranscriptionModel: "whisper-1",
+			RecordCommand:      "rec -q -c 1 -r 16000 {file} silence 1 0.1 1% 1 1.5 1%",
+			SpeechURL:          "http://localhost:8000/v1/audio/speech",
+			SpeechModel:        "tts-1",
+			Voice:              "alloy",
+			PlayCommand:        "play -q {file}",
+			Timeout:            "60s",
+		},
+		Budget: BudgetConfig{
+			ConfirmAbove: 1.0,
+			Prices:       map[string]ModelPrice{},
+		},
+		Secrets: SecretsConfig{
+			Enabled: true,
+			Action:  SecretsActionSkip,
+		},
+		Ignore: IgnoreConfig{
+			GitIgnore: true,
+			Patterns:  []string{},
+		},
+		Server: ServerConfig{
+			Listen:          "127.0.0.1:8080",
+			ShutdownTimeout: "30s",
+			SessionTTL:      "1h",
 		},
 		General: GeneralConfig{
-			LogLevel: "info",
-			DataDir:  filepath.Join(home, ".rag-cli", "data"),
+			DataDir:    filepath.Join(home, ".rag-cli", "data"),
+			PromptsDir: filepath.Join(home, ".rag-cli", "prompts"),
 		},
 	}
 }
@@ -2,7 +2,12 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -58,6 +63,14 @@ func TestLoadConfig(t *testing.T) {
 	if config.Embedding.Dimensions != 1024 {
 		t.Errorf("Expected embedding dimensions to be 1024, got %d", config.Embedding.Dimensions)
 	}
+
+	if config.Rerank.Instruction != DefaultRerankInstruction {
+		t.Errorf("Expected default rerank instruction, got '%s'", config.Rerank.Instruction)
+	}
+
+	if config.Rerank.OriginalWeight != 0.7 || config.Rerank.RerankWeight != 0.3 {
+		t.Errorf("Expected rerank weights 0.7/0.3, got %.1f/%.1f", config.Rerank.OriginalWeight, config.Rerank.RerankWeight)
+	}
 }
 
 func TestEmbeddingBackendFallback(t *testing.T) {
@@ -159,3 +172,650 @@ func TestGetDSN(t *testing.T) {
 		t.Errorf("Expected DSN '%s', got '%s'", expected, dsn)
 	}
 }
+
+func TestDatabasePoolSettings(t *testing.T) {
+	config := &DatabaseConfig{}
+	if got := config.GetMaxOpenConns(); got != 10 {
+		t.Errorf("Expected default max open conns 10, got %d", got)
+	}
+	if got := config.GetMaxIdleConns(); got != 5 {
+		t.Errorf("Expected default max idle conns 5, got %d", got)
+	}
+	if got := config.GetConnMaxLifetime(); got != 5*time.Minute {
+		t.Errorf("Expected default conn max lifetime 5m, got %s", got)
+	}
+
+	config = &DatabaseConfig{MaxOpenConns: 3, MaxIdleConns: 8, ConnMaxLifetime: "90s"}
+	if got := config.GetMaxIdleConns(); got != 3 {
+		t.Errorf("Expected max idle conns capped at 3, got %d", got)
+	}
+	if got := config.GetConnMaxLifetime(); got != 90*time.Second {
+		t.Errorf("Expected conn max lifetime 90s, got %s", got)
+	}
+}
+
+func TestChunkStrategy(t *testing.T) {
+	embedding := getDefaultConfig().Embedding
+	if got := embedding.GetChunkStrategy(); got != ChunkStrategyCharacters {
+		t.Errorf("Expected default chunk strategy characters, got %s", got)
+	}
+
+	embedding.ChunkStrategy = ChunkStrategyTokens
+	if err := embedding.Validate(); err != nil {
+		t.Errorf("Expected the tokens chunk strategy to be valid, got: %v", err)
+	}
+	embedding.ChunkStrategy = "words"
+	if err := embedding.Validate(); err == nil {
+		t.Error("Expected an error for an unknown chunk strategy")
+	}
+}
+
+func TestSeparators(t *testing.T) {
+	embedding := getDefaultConfig().Embedding
+	embedding.ChunkStrategy = ChunkStrategyRecursive
+	if err := embedding.Validate(); err != nil {
+		t.Errorf("Expected the recursive chunk strategy to be valid, got: %v", err)
+	}
+	if got := embedding.GetSeparators(); !slices.Equal(got, DefaultSeparators) {
+		t.Errorf("Expected the default separators, got %q", got)
+	}
+
+	embedding.Separators = []string{"\n", ","}
+	if got := embedding.GetSeparators(); !slices.Equal(got, []string{"\n", ","}) {
+		t.Errorf("Expected the configured separators, got %q", got)
+	}
+}
+
+func TestTranslationValidate(t *testing.T) {
+	translation := getDefaultConfig().Translation
+	if err := translation.Validate(); err != nil {
+		t.Errorf("Expected the default translation settings to be valid, got: %v", err)
+	}
+
+	translation.Enabled = true
+	if err := translation.Validate(); err == nil {
+		t.Error("Expected an error when translation is enabled without a language")
+	}
+	translation.Language = "German"
+	if err := translation.Validate(); err != nil {
+		t.Errorf("Expected translation with a language to be valid, got: %v", err)
+	}
+}
+
+func TestReconnectTimeout(t *testing.T) {
+	database := &DatabaseConfig{}
+	if got := database.GetReconnectTimeout(); got != 30*time.Second {
+		t.Errorf("Expected default reconnect timeout 30s, got %s", got)
+	}
+	ollama := &OllamaConfig{ReconnectTimeout: "0s"}
+	if got := ollama.GetReconnectTimeout(); got != 0 {
+		t.Errorf("Expected reconnect timeout 0s to disable retrying, got %s", got)
+	}
+
+	if err := validateReconnectTimeout("2m"); err != nil {
+		t.Errorf("Expected 2m to be valid, got: %v", err)
+	}
+	if err := validateReconnectTimeout("soon"); err == nil {
+		t.Error("Expected an error for an invalid duration")
+	}
+	if err := validateReconnectTimeout("-1s"); err == nil {
+		t.Error("Expected an error for a negative duration")
+	}
+}
+
+func TestRerankConfigValidation(t *testing.T) {
+	valid := RerankConfig{
+		Instruction:    DefaultRerankInstruction,
+		OriginalWeight: 0.7,
+		RerankWeight:   0.3,
+		Backend:        "ollama",
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid rerank config, got error: %v", err)
+	}
+
+	invalid := []RerankConfig{
+		{OriginalWeight: 1.5, RerankWeight: 0.3},
+		{OriginalWeight: 0.7, RerankWeight: -0.1},
+		{OriginalWeight: 0.7, RerankWeight: 0.3, Limit: -1},
+		{OriginalWeight: 0.7, RerankWeight: 0.3, Retrieve: -1},
+		{OriginalWeight: 0.7, RerankWeight: 0.3, Method: "invalid"},
+		{OriginalWeight: 0.7, RerankWeight: 0.3, Method: RerankMethodLLM, Concurrency: -1},
+		{OriginalWeight: 0.7, RerankWeight: 0.3, Backend: "invalid"},
+		{OriginalWeight: 0.7, RerankWeight: 0.3, Backend: "cohere", Method: RerankMethodLLM},
+	}
+	for i, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected rerank config %d to fail validation", i)
+		}
+	}
+
+	cohere := RerankConfig{Backend: "cohere"}
+	if err := cohere.Validate(); err != nil {
+		t.Errorf("Expected cohere rerank config to be valid, got error: %v", err)
+	}
+	if cohere.GetMethod() != RerankMethodCrossEncoder {
+		t.Errorf("Expected cohere to default to %s, got %s", RerankMethodCrossEncoder, cohere.GetMethod())
+	}
+}
+
+func TestEmbeddingConcurrency(t *testing.T) {
+	c := EmbeddingConfig{ChunkSize: 1000, ChunkOverlap: 200, MaxResults: 10, Dimensions: 1024}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected valid embedding config, got error: %v", err)
+	}
+	if got := c.GetConcurrency(); got != 4 {
+		t.Errorf("Expected default concurrency 4, got %d", got)
+	}
+
+	c.Concurrency = 8
+	if got := c.GetConcurrency(); got != 8 {
+		t.Errorf("Expected concurrency 8, got %d", got)
+	}
+
+	c.Concurrency = -1
+	if err := c.Validate(); err == nil {
+		t.Error("Expected negative concurrency to fail validation")
+	}
+}
+
+func TestEmbeddingBatchSize(t *testing.T) {
+	c := EmbeddingConfig{ChunkSize: 1000, ChunkOverlap: 200, MaxResults: 10, Dimensions: 1024}
+	if got := c.GetBatchSize(); got != 32 {
+		t.Errorf("Expected default batch size 32, got %d", got)
+	}
+
+	c.BatchSize = -1
+	if err := c.Validate(); err == nil {
+		t.Error("Expected negative batch size to fail validation")
+	}
+}
+
+func TestEmbeddingModelDimensions(t *testing.T) {
+	c := EmbeddingConfig{ChunkSize: 1000, ChunkOverlap: 200, Dimensions: 1024, ModelDimensions: map[string]int{"my-embedder": 512}}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected model dimensions to be valid, got error: %v", err)
+	}
+
+	c.ModelDimensions["broken-embedder"] = 0
+	if err := c.Validate(); err == nil {
+		t.Error("Expected zero model dimensions to fail validation")
+	}
+}
+
+func TestOpenAIInputTypeField(t *testing.T) {
+	c := OpenAIConfig{APIKey: "key", ChatModel: "gpt-4", EmbeddingModel: "embed", InputTypeField: "input_type", QueryInputType: "search_query"}
+	if err := c.Validate(); err == nil {
+		t.Error("Expected input_type_field without document_input_type to fail validation")
+	}
+
+	c.DocumentInputType = "search_document"
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected input types to be valid, got error: %v", err)
+	}
+}
+
+func TestIgnoreConfig(t *testing.T) {
+	c := IgnoreConfig{GitIgnore: true, Patterns: []string{"node_modules/", "*.min.js"}}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected patterns to be valid, got error: %v", err)
+	}
+	if files := c.GetFiles(); len(files) != 2 {
+		t.Errorf("Expected .gitignore and .ragignore to be read, got %v", files)
+	}
+
+	c.GitIgnore = false
+	if files := c.GetFiles(); len(files) != 1 || files[0] != ".ragignore" {
+		t.Errorf("Expected only .ragignore to be read, got %v", files)
+	}
+
+	c.Patterns = append(c.Patterns, "[]")
+	if err := c.Validate(); err == nil {
+		t.Error("Expected an invalid pattern to fail validation")
+	}
+}
+
+func TestSearchConfigValidation(t *testing.T) {
+	for _, normalization := range []string{"", NormalizationNone, NormalizationMinMax, NormalizationZScore} {
+		c := SearchConfig{Normalization: normalization}
+		if err := c.Validate(); err != nil {
+			t.Errorf("Expected normalization %q to be valid, got error: %v", normalization, err)
+		}
+	}
+
+	invalid := SearchConfig{Normalization: "softmax"}
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected unknown normalization to fail validation")
+	}
+
+	invalidPathWeight := SearchConfig{PathWeight: 1.5}
+	if err := invalidPathWeight.Validate(); err == nil {
+		t.Error("Expected path weight above 1 to fail validation")
+	}
+
+	var unset SearchConfig
+	if unset.GetNormalization() != NormalizationNone {
+		t.Errorf("Expected default normalization %q, got %q", NormalizationNone, unset.GetNormalization())
+	}
+}
+
+func TestRankingProfiles(t *testing.T) {
+	c := SearchConfig{Profiles: map[string]RankingProfile{
+		"faq":     {SearchType: "text", Limit: 2},
+		"runbook": {SearchType: "bm25", MMR: 0.5},
+	}}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected profiles to be valid, got error: %v", err)
+	}
+
+	// Configured profiles override the built-in ones of the same name
+	faq, err := c.Profile("faq")
+	if err != nil || faq.SearchType != "text" || faq.Limit != 2 {
+		t.Errorf("Expected the configured faq profile, got %+v, %v", faq, err)
+	}
+	precision, err := c.Profile("precision")
+	if err != nil || !precision.Rerank {
+		t.Errorf("Expected the built-in precision profile, got %+v, %v", precision, err)
+	}
+	if _, err := c.Profile("fast"); err == nil {
+		t.Error("Expected an unknown profile to fail")
+	}
+
+	expected := []string{"code-search", "faq", "precision", "recall", "runbook"}
+	if names := c.ProfileNames(); !slices.Equal(names, expected) {
+		t.Errorf("Expected profile names %v, got %v", expected, names)
+	}
+
+	for name, profile := range BuiltinProfiles {
+		if err := profile.Validate(); err != nil {
+			t.Errorf("Expected built-in profile %s to be valid, got error: %v", name, err)
+		}
+	}
+	invalid := []RankingProfile{{SearchType: "keyword"}, {VectorWeight: 2}, {MMR: -0.5}, {Limit: -1}}
+	for _, profile := range invalid {
+		c := SearchConfig{Profiles: map[string]RankingProfile{"broken": profile}}
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected profile %+v to fail validation", profile)
+		}
+	}
+}
+
+func TestDecompositionConfig(t *testing.T) {
+	var c DecompositionConfig
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected valid decomposition config, got error: %v", err)
+	}
+	if c.GetMaxSubQuestions() != 3 {
+		t.Errorf("Expected 3 sub-questions by default, got %d", c.GetMaxSubQuestions())
+	}
+
+	for _, n := range []int{-1, MaxSubQuestions + 1} {
+		invalid := DecompositionConfig{MaxSubQuestions: n}
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %d sub-questions to fail validation", n)
+		}
+	}
+}
+
+func TestCalibrationConfig(t *testing.T) {
+	c := CalibrationConfig{SearchType: "hybrid", Slope: 8, Intercept: -4, MinConfidence: 0.3}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected valid calibration config, got error: %v", err)
+	}
+	if !c.Fitted("hybrid") || c.Fitted("vector") {
+		t.Error("Expected the calibration to apply to hybrid search only")
+	}
+
+	var unfitted CalibrationConfig
+	if unfitted.Fitted("") {
+		t.Error("Expected an empty calibration not to be fitted")
+	}
+
+	invalid := CalibrationConfig{MinConfidence: 1.5}
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected min confidence above 1 to fail validation")
+	}
+}
+
+func TestAbstentionConfig(t *testing.T) {
+	var c AbstentionConfig
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected valid abstention config, got error: %v", err)
+	}
+	if c.GetMessage() != DefaultAbstentionMessage {
+		t.Errorf("Expected default message, got %q", c.GetMessage())
+	}
+	if c.GetNearMisses() != 3 {
+		t.Errorf("Expected 3 near misses by default, got %d", c.GetNearMisses())
+	}
+
+	invalid := AbstentionConfig{NearMisses: -1}
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected negative near misses to fail validation")
+	}
+}
+
+func TestExpansionConfigValidation(t *testing.T) {
+	valid := ExpansionConfig{
+		Enabled:     true,
+		Paraphrases: 3,
+		Synonyms:    map[string][]string{"k8s": {"kubernetes"}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid expansion config, got error: %v", err)
+	}
+
+	invalid := []ExpansionConfig{
+		{Paraphrases: -1},
+		{Paraphrases: MaxParaphrases + 1},
+		{Synonyms: map[string][]string{"": {"empty"}}},
+		{Synonyms: map[string][]string{"k8s": {}}},
+		{Rewrite: true, Rewrites: -1},
+		{Rewrite: true, Rewrites: MaxParaphrases + 1},
+	}
+	for i, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected expansion config %d to fail validation", i)
+		}
+	}
+
+	if rewrites := (&ExpansionConfig{Rewrite: true}).GetRewrites(); rewrites != 3 {
+		t.Errorf("Expected 3 rewrites by default, got %d", rewrites)
+	}
+}
+
+func TestPathsConfig(t *testing.T) {
+	if filepath.Separator != '/' {
+		t.Skip("expected paths below use / as the path separator")
+	}
+
+	paths := PathsConfig{Roots: []RootMapping{
+		{Folder: "/home/alice", Path: "/Users/bob"},
+		{Folder: "/home/alice/project/docs/", Path: "/srv/docs"},
+		{Folder: `C:\Users\carol\notes`, Path: "/data/notes"},
+	}}
+	if err := paths.Validate(); err != nil {
+		t.Fatalf("Expected valid paths config, got error: %v", err)
+	}
+
+	tests := map[string]string{
+		"/home/alice/project/docs":     "/srv/docs",
+		"/home/alice/project/docs/api": "/srv/docs/api",
+		"/home/alice/project/src":      "/Users/bob/project/src",
+		"/home/alice":                  "/Users/bob",
+		"/home/alice2/docs":            "/home/alice2/docs",
+		"/var/docs":                    "/var/docs",
+		`C:\Users\carol\notes\work`:    "/data/notes/work",
+	}
+	for folder, want := range tests {
+		if got := paths.LocalRoot(folder); got != want {
+			t.Errorf("LocalRoot(%q) = %q, want %q", folder, got, want)
+		}
+	}
+
+	windows := PathsConfig{Roots: []RootMapping{{Folder: `c:\Users\Carol`, Path: "/data/carol"}}}
+	if got := windows.LocalRoot(`C:\users\carol\Docs`); got != "/data/carol/Docs" {
+		t.Errorf("LocalRoot with a differently cased Windows folder = %q, want /data/carol/Docs", got)
+	}
+	if got := (&PathsConfig{Roots: []RootMapping{{Folder: "/Home/alice", Path: "/x"}}}).LocalRoot("/home/alice"); got != "/home/alice" {
+		t.Errorf("LocalRoot matched a Unix folder case-insensitively: %q", got)
+	}
+
+	root := PathsConfig{Roots: []RootMapping{{Folder: "/", Path: "/mnt/old"}}}
+	if got := root.LocalRoot("/docs"); got != "/mnt/old/docs" {
+		t.Errorf("LocalRoot with a root mapping = %q, want /mnt/old/docs", got)
+	}
+
+	invalid := []PathsConfig{
+		{Roots: []RootMapping{{Folder: "/docs"}}},
+		{Roots: []RootMapping{{Path: "/docs"}}},
+		{Roots: []RootMapping{{Folder: "/docs", Path: "/a"}, {Folder: "/docs/", Path: "/b"}}},
+		{Roots: []RootMapping{{Folder: `C:\Docs`, Path: "/a"}, {Folder: `c:\docs`, Path: "/b"}}},
+	}
+	for i, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected paths config %d to fail validation", i)
+		}
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	var empty ServerConfig
+	if err := empty.Validate(); err != nil {
+		t.Errorf("Expected empty server config to be valid, got error: %v", err)
+	}
+	if empty.GetListen() != "127.0.0.1:8080" {
+		t.Errorf("Expected default listen address, got %s", empty.GetListen())
+	}
+	if empty.GetShutdownTimeout() != 30*time.Second {
+		t.Errorf("Expected default shutdown timeout, got %s", empty.GetShutdownTimeout())
+	}
+	if empty.TLSEnabled() {
+		t.Error("Expected TLS to be disabled without a certificate")
+	}
+	if empty.GetSessionTTL() != time.Hour {
+		t.Errorf("Expected default session TTL, got %s", empty.GetSessionTTL())
+	}
+	forever := ServerConfig{SessionTTL: "0"}
+	if forever.GetSessionTTL() != 0 {
+		t.Errorf("Expected sessions to never expire, got TTL %s", forever.GetSessionTTL())
+	}
+
+	tls := ServerConfig{Listen: ":8443", TLSCert: "cert.pem", TLSKey: "key.pem", ShutdownTimeout: "5s"}
+	if err := tls.Validate(); err != nil {
+		t.Errorf("Expected TLS server config to be valid, got error: %v", err)
+	}
+	if !tls.TLSEnabled() || tls.GetShutdownTimeout() != 5*time.Second {
+		t.Errorf("Unexpected TLS server settings: %+v", tls)
+	}
+
+	invalid := []ServerConfig{
+		{TLSCert: "cert.pem"},
+		{TLSKey: "key.pem"},
+		{ShutdownTimeout: "soon"},
+		{ShutdownTimeout: "-1s"},
+		{SessionTTL: "a while"},
+		{SessionTTL: "-1h"},
+	}
+	for i, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected server config %d to fail validation", i)
+		}
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	tests := map[string]string{
+		"~":                home,
+		"~/.rag-cli/data":  filepath.Join(home, ".rag-cli", "data"),
+		`~\.rag-cli\data`:  filepath.Join(home, ".rag-cli", "data"),
+		"/var/lib/rag-cli": "/var/lib/rag-cli",
+		"~user/data":       "~user/data",
+	}
+	for path, want := range tests {
+		if got := expandHome(path); got != want {
+			t.Errorf("expandHome(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestValidateForRequirements(t *testing.T) {
+	// Database-only commands must not need any LLM configuration
+	config := &Config{
+		ChatBackend: "invalid",
+		Database: DatabaseConfig{
+			Host:    "localhost",
+			Port:    5432,
+			Name:    "testdb",
+			User:    "testuser",
+			SSLMode: "disable",
+		},
+	}
+	if err := config.ValidateFor(RequireDatabase); err != nil {
+		t.Errorf("Expected database-only validation to pass, got error: %v", err)
+	}
+	if err := config.ValidateFor(RequireChat); err == nil {
+		t.Error("Expected chat validation to fail with invalid chat backend")
+	}
+
+	// Ollama-only configuration must not require OpenAI settings
+	config = getDefaultConfig()
+	config.OpenAI = OpenAIConfig{}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected ollama-only config to pass full validation, got error: %v", err)
+	}
+
+	// Only the models actually used are required
+	config.Ollama.ChatModel = ""
+	if err := config.ValidateFor(RequireDatabase | RequireEmbedding); err != nil {
+		t.Errorf("Expected embedding validation to ignore chat model, got error: %v", err)
+	}
+	if err := config.ValidateFor(RequireChat); err == nil {
+		t.Error("Expected chat validation to fail without a chat model")
+	}
+
+	// A rerank backend is only validated when reranking is required
+	config = getDefaultConfig()
+	config.Rerank.Backend = "openai"
+	if err := config.ValidateFor(RequireDatabase | RequireChat | RequireEmbedding); err != nil {
+		t.Errorf("Expected validation without reranker to pass, got error: %v", err)
+	}
+	if err := config.ValidateFor(RequireReranker); err == nil {
+		t.Error("Expected reranker validation to fail without an OpenAI api key")
+	}
+
+	// Cohere needs its own api key, and no backend settings
+	config = getDefaultConfig()
+	config.Ollama = OllamaConfig{}
+	config.Rerank.Backend = "cohere"
+	config.Rerank.Method = RerankMethodCrossEncoder
+	if err := config.ValidateFor(RequireReranker); err == nil {
+		t.Error("Expected cohere reranker validation to fail without rerank.api_key")
+	}
+	config.Rerank.APIKey = "cohere-key"
+	if err := config.ValidateFor(RequireReranker); err != nil {
+		t.Errorf("Expected cohere reranker validation to pass, got error: %v", err)
+	}
+
+	// OpenAI-compatible rerank endpoints need a reranker model
+	config.Rerank = RerankConfig{Backend: "openai", Method: RerankMethodCrossEncoder, BaseURL: "http://localhost:8080/v1"}
+	if err := config.ValidateFor(RequireReranker); err == nil {
+		t.Error("Expected openai cross-encoder validation to fail without rerank.model")
+	}
+	config.Rerank.Model = "bge-reranker-v2-m3"
+	if err := config.ValidateFor(RequireReranker); err != nil {
+		t.Errorf("Expected openai cross-encoder validation to pass with rerank.base_url, got error: %v", err)
+	}
+}
+
+func TestChannelMapping(t *testing.T) {
+	mapping := ChannelMapping{
+		Collection: "docs",
+		Channels:   map[string]string{"c0123": "runbooks", "guild-1": "support"},
+	}
+
+	tests := []struct {
+		channel, group, want string
+	}{
+		{"C0123", "", "runbooks"},
+		{"C9999", "guild-1", "support"},
+		{"C0123", "guild-1", "runbooks"},
+		{"C9999", "", "docs"},
+	}
+	for _, tt := range tests {
+		if got := mapping.CollectionFor(tt.channel, tt.group); got != tt.want {
+			t.Errorf("CollectionFor(%q, %q) = %q, want %q", tt.channel, tt.group, got, tt.want)
+		}
+	}
+
+	collections := mapping.Collections()
+	if len(collections) != 3 || collections[0] != "docs" {
+		t.Errorf("Expected the default collection followed by the mapped ones, got %v", collections)
+	}
+
+	var unmapped ChannelMapping
+	if got := unmapped.CollectionFor("C0123", ""); got != "" {
+		t.Errorf("Expected no collection for an empty mapping, got %q", got)
+	}
+	if err := (&ChannelMapping{Channels: map[string]string{"c1": ""}}).Validate(); err == nil {
+		t.Error("Expected a channel mapped to an empty collection to fail validation")
+	}
+}
+
+func TestBotsConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", originalHome)
+	os.Setenv("HOME", tempDir)
+
+	configFile, err := ConfigFilePath("bots")
+	if err != nil {
+		t.Fatalf("Failed to get config file path: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	saved := getDefaultConfig()
+	saved.Bots.Telegram = TelegramConfig{
+		Token:          "123456:ABC",
+		ChannelMapping: ChannelMapping{Collection: "docs", Channels: map[string]string{"-100123": "support"}},
+	}
+	if err := SaveConfig(saved, configFile); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	loaded, err := LoadConfig("bots")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	telegram := loaded.Bots.Telegram
+	if !telegram.Configured() || telegram.Collection != "docs" {
+		t.Errorf("Expected the Telegram settings to be loaded, got %+v", telegram)
+	}
+	if got := telegram.CollectionFor("-100123", ""); got != "support" {
+		t.Errorf("Expected the mapped collection, got %q", got)
+	}
+
+	invalid := []BotsConfig{
+		{Slack: SlackConfig{AppToken: "xoxb-wrong"}},
+		{Telegram: TelegramConfig{Token: "not-a-token"}},
+		{Discord: DiscordConfig{Token: "Bot abc"}},
+	}
+	for i, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected bots config %d to fail validation", i)
+		}
+	}
+}
+
+func TestVoiceValidate(t *testing.T) {
+	voice := getDefaultConfig().Voice
+	if err := voice.Validate(); err != nil {
+		t.Errorf("Expected the default voice settings to be valid, got: %v", err)
+	}
+	if got := voice.GetTimeout(); got != 60*time.Second {
+		t.Errorf("Expected timeout 60s, got %s", got)
+	}
+
+	invalid := voice
+	invalid.SpeechURL = "localhost:8000/v1/audio/speech"
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected an error for a speech URL without a scheme")
+	}
+	invalid = voice
+	invalid.RecordCommand = "arecord -d 5 question.wav"
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected an error for a record command without {file}")
+	}
+	invalid = voice
+	invalid.Timeout = "soon"
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected an error for an invalid timeout")
+	}
+}
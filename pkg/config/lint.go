@@ -0,0 +1,261 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Lint issue severities
+const (
+	LintError   = "error"   // The setting is invalid or breaks a command
+	LintWarning = "warning" // The setting is ignored or probably not what was meant
+)
+
+// LintIssue is a problem found in a configuration file
+type LintIssue struct {
+	Key      string `json:"key"` // Dotted key of the setting, e.g. embedding.chunk_size
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"` // Suggested change
+}
+
+// deprecatedKeys are settings that are still read but no longer used, with
+// what replaced them
+var deprecatedKeys = map[string]string{
+	"embedding.similarity_threshold": "search and chat filter results with --min-score",
+	"embedding.max_results":          "search and chat limit results with --limit",
+	"general.log_level":              "use --verbose for more output",
+}
+
+// ModelDimensions returns the embedding dimensions of a model, given the
+// dimensions set in embedding.model_dimensions, or an error when they aren't
+// known
+type ModelDimensions func(model string, overrides map[string]int) (int, error)
+
+// LintFile reads a configuration file and lints it. Every key in the file is
+// checked against the known settings, and the settings are checked for
+// values that are invalid or suspicious. Embedding dimensions are compared
+// with those of the embedding model when modelDimensions knows them.
+func LintFile(path string, modelDimensions ModelDimensions) ([]LintIssue, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := getDefaultConfig()
+	if err := v.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	if config.EmbeddingBackend == "" {
+		config.EmbeddingBackend = config.ChatBackend
+	}
+
+	return Lint(v.AllKeys(), config, modelDimensions), nil
+}
+
+// Lint checks the keys set in a configuration file and the configuration
+// they make up. Issues are sorted by key.
+func Lint(keys []string, c *Config, modelDimensions ModelDimensions) []LintIssue {
+	var issues []LintIssue
+
+	known := knownKeys()
+	for _, key := range keys {
+		if reason, ok := deprecatedKeys[key]; ok {
+			issues = append(issues, LintIssue{
+				Key:      key,
+				Severity: LintWarning,
+				Message:  "deprecated and ignored: " + reason,
+				Fix:      "remove " + key,
+			})
+			continue
+		}
+		if known.has(key) {
+			continue
+		}
+		issue := LintIssue{Key: key, Severity: LintWarning, Message: "unknown key, it is ignored", Fix: "remove " + key}
+		if suggestion := known.closest(key); suggestion != "" {
+			issue.Fix = "did you mean " + suggestion + "?"
+		}
+		issues = append(issues, issue)
+	}
+
+	issues = append(issues, c.lintValues(modelDimensions)...)
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Key < issues[j].Key
+	})
+	return issues
+}
+
+// lintValues checks the configuration for invalid and suspicious values
+func (c *Config) lintValues(modelDimensions ModelDimensions) []LintIssue {
+	var issues []LintIssue
+
+	embedding := c.Embedding
+	switch {
+	case embedding.ChunkOverlap >= embedding.ChunkSize:
+		issues = append(issues, LintIssue{
+			Key:      "embedding.chunk_overlap",
+			Severity: LintError,
+			Message:  fmt.Sprintf("chunk_overlap (%d) is not less than chunk_size (%d), so chunks never advance", embedding.ChunkOverlap, embedding.ChunkSize),
+			Fix:      fmt.Sprintf("set embedding.chunk_overlap to %d", embedding.ChunkSize/5),
+		})
+	case embedding.ChunkOverlap > embedding.ChunkSize/2:
+		issues = append(issues, LintIssue{
+			Key:      "embedding.chunk_overlap",
+			Severity: LintWarning,
+			Message:  fmt.Sprintf("chunk_overlap (%d) is more than half of chunk_size (%d), so most text is embedded twice", embedding.ChunkOverlap, embedding.ChunkSize),
+			Fix:      fmt.Sprintf("set embedding.chunk_overlap to %d", embedding.ChunkSize/5),
+		})
+	}
+
+	if model := c.embeddingModel(); model != "" && modelDimensions != nil {
+		if dimensions, err := modelDimensions(model, embedding.ModelDimensions); err == nil && dimensions != embedding.Dimensions {
+			issues = append(issues, LintIssue{
+				Key:      "embedding.dimensions",
+				Severity: LintWarning,
+				Message:  fmt.Sprintf("dimensions (%d) don't match the %d dimensions of the embedding model %s", embedding.Dimensions, dimensions, model),
+				Fix:      fmt.Sprintf("set embedding.dimensions to %d", dimensions),
+			})
+		}
+	}
+
+	if c.Encryption.Key != "" {
+		issues = append(issues, LintIssue{
+			Key:      "encryption.key",
+			Severity: LintWarning,
+			Message:  "the encryption key is stored in the configuration file in plain text",
+			Fix:      "move the key to encryption.key_file or encryption.key_command",
+		})
+	}
+
+	if c.Rerank.OriginalWeight+c.Rerank.RerankWeight == 0 {
+		issues = append(issues, LintIssue{
+			Key:      "rerank.rerank_weight",
+			Severity: LintWarning,
+			Message:  "original_weight and rerank_weight are both 0, so reranked results all score 0",
+			Fix:      "set rerank.original_weight to 0.7 and rerank.rerank_weight to 0.3",
+		})
+	}
+
+	// Problems without a check of their own are reported as Validate finds
+	// them, one at a time
+	if !hasErrors(issues) {
+		if err := c.Validate(); err != nil {
+			issues = append(issues, LintIssue{Severity: LintError, Message: err.Error()})
+		}
+	}
+
+	return issues
+}
+
+// hasErrors reports whether any of the issues is an error
+func hasErrors(issues []LintIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddingModel returns the model of the embedding backend
+func (c *Config) embeddingModel() string {
+	if c.EmbeddingBackend == "openai" {
+		return c.OpenAI.EmbeddingModel
+	}
+	return c.Ollama.EmbeddingModel
+}
+
+// keySet holds the keys of the settings, and the prefixes of the settings
+// that are maps with keys of their own, such as budget.prices
+type keySet struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+// knownKeys returns the keys of every setting of Config
+func knownKeys() *keySet {
+	set := &keySet{keys: make(map[string]bool)}
+	set.add("", reflect.TypeOf(Config{}))
+	return set
+}
+
+// add adds the settings of a struct, with their keys under prefix
+func (s *keySet) add(prefix string, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Squashed structs have their fields at the same level
+		if options == "squash" && field.Type.Kind() == reflect.Struct {
+			s.add(prefix, field.Type)
+			continue
+		}
+
+		key := prefix + name
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			s.add(key+".", field.Type)
+		case reflect.Map:
+			s.keys[key] = true
+			s.prefixes = append(s.prefixes, key+".")
+		default:
+			s.keys[key] = true
+		}
+	}
+}
+
+// has reports whether key is a setting or a key of a map setting
+func (s *keySet) has(key string) bool {
+	if s.keys[key] {
+		return true
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// closest returns the known key nearest to an unknown one, or an empty
+// string when none is close enough to be a typo
+func (s *keySet) closest(key string) string {
+	best, bestDistance := "", 3
+	for known := range s.keys {
+		if distance := editDistance(key, known); distance < bestDistance || (distance == bestDistance && best != "" && known < best) {
+			best, bestDistance = known, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
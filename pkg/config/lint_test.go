@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testModelDimensions knows the dimensions of the default Ollama embedding
+// model, and of the models in overrides
+func testModelDimensions(model string, overrides map[string]int) (int, error) {
+	if dimensions, ok := overrides[model]; ok {
+		return dimensions, nil
+	}
+	if model == "dengcao/Qwen3-Embedding-0.6B:Q8_0" {
+		return 1024, nil
+	}
+	return 0, fmt.Errorf("unknown model %s", model)
+}
+
+// issueFor returns the issue reported for a key, or nil
+func issueFor(issues []LintIssue, key string) *LintIssue {
+	for i := range issues {
+		if issues[i].Key == key {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func TestLintDefaultConfig(t *testing.T) {
+	c := getDefaultConfig()
+	c.EmbeddingBackend = c.ChatBackend
+	if issues := Lint([]string{"chat_backend", "embedding.chunk_size", "budget.prices.my-model.input", "bots.slack.channels.c123"}, c, testModelDimensions); len(issues) != 0 {
+		t.Errorf("Expected no issues for the default configuration, got %+v", issues)
+	}
+}
+
+func TestLintKeys(t *testing.T) {
+	c := getDefaultConfig()
+	c.EmbeddingBackend = c.ChatBackend
+	issues := Lint([]string{"embeding.chunk_size", "embedding.max_results", "completely.unrelated"}, c, testModelDimensions)
+
+	if issue := issueFor(issues, "embeding.chunk_size"); issue == nil || issue.Fix != "did you mean embedding.chunk_size?" {
+		t.Errorf("Expected a typo to suggest embedding.chunk_size, got %+v", issue)
+	}
+	if issue := issueFor(issues, "embedding.max_results"); issue == nil || issue.Severity != LintWarning {
+		t.Errorf("Expected a deprecation warning for embedding.max_results, got %+v", issue)
+	}
+	if issue := issueFor(issues, "completely.unrelated"); issue == nil || issue.Fix != "remove completely.unrelated" {
+		t.Errorf("Expected an unknown key without a close match to be removed, got %+v", issue)
+	}
+}
+
+func TestLintValues(t *testing.T) {
+	c := getDefaultConfig()
+	c.EmbeddingBackend = c.ChatBackend
+	c.Embedding.ChunkOverlap = c.Embedding.ChunkSize
+	c.Embedding.Dimensions = 768
+	issues := Lint(nil, c, testModelDimensions)
+
+	if issue := issueFor(issues, "embedding.chunk_overlap"); issue == nil || issue.Severity != LintError {
+		t.Errorf("Expected an error for chunk_overlap >= chunk_size, got %+v", issue)
+	}
+	if issue := issueFor(issues, "embedding.dimensions"); issue == nil || issue.Fix != "set embedding.dimensions to 1024" {
+		t.Errorf("Expected the model's dimensions to be suggested, got %+v", issue)
+	}
+
+	// Dimensions set in model_dimensions take precedence
+	c.Embedding.ModelDimensions = map[string]int{"dengcao/Qwen3-Embedding-0.6B:Q8_0": 768}
+	if issue := issueFor(Lint(nil, c, testModelDimensions), "embedding.dimensions"); issue != nil {
+		t.Errorf("Expected no dimensions issue with model_dimensions set, got %+v", issue)
+	}
+
+	// Validation errors without a check of their own are reported too
+	c = getDefaultConfig()
+	c.EmbeddingBackend = c.ChatBackend
+	c.Search.Normalization = "invalid"
+	issues = Lint(nil, c, nil)
+	if len(issues) != 1 || issues[0].Severity != LintError {
+		t.Errorf("Expected one validation error, got %+v", issues)
+	}
+}
+
+func TestLintFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "chat_backend: ollama\nembedding:\n  chunk_size: 500\n  chunk_overlap: 400\n  dimensions: 1024\ngeneral:\n  log_level: debug\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := LintFile(path, testModelDimensions)
+	if err != nil {
+		t.Fatalf("Failed to lint config: %v", err)
+	}
+	if issue := issueFor(issues, "embedding.chunk_overlap"); issue == nil || issue.Severity != LintWarning {
+		t.Errorf("Expected a warning for an overlap over half the chunk size, got %+v", issue)
+	}
+	if issue := issueFor(issues, "general.log_level"); issue == nil {
+		t.Error("Expected a deprecation warning for general.log_level")
+	}
+	if len(issues) != 2 {
+		t.Errorf("Expected 2 issues, got %+v", issues)
+	}
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrCollectionNotFound is returned when no collection matches a reference
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// AmbiguousCollectionError is returned when a name or alias refers to more
+// than one collection
+type AmbiguousCollectionError struct {
+	Reference  string
+	Candidates []*Collection
+}
+
+// Error lists the candidates so the user can pick one by ID
+func (e *AmbiguousCollectionError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%q matches %d collections, use the collection ID instead:", e.Reference, len(e.Candidates))
+	for _, candidate := range e.Candidates {
+		fmt.Fprintf(&b, "\n  %s  %s", candidate.ID, candidate.Name)
+	}
+	return b.String()
+}
+
+// AddAlias adds an alternative name for a collection
+func (cm *CollectionManagerImpl) AddAlias(collectionID, alias string) error {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+	if isUUID(alias) {
+		return fmt.Errorf("alias %q cannot be a UUID", alias)
+	}
+
+	// An alias must not shadow the name of a collection
+	var exists bool
+	err := cm.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM collections WHERE name = $1)`, alias).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check collection names: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("alias %q is already the name of a collection", alias)
+	}
+
+	_, err = cm.db.Exec(`INSERT INTO collection_aliases (alias, collection_id) VALUES ($1, $2)`, alias, collectionID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("alias %q is already in use", alias)
+		}
+		return fmt.Errorf("failed to add alias: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveAlias removes an alias
+func (cm *CollectionManagerImpl) RemoveAlias(alias string) error {
+	result, err := cm.db.Exec(`DELETE FROM collection_aliases WHERE alias = $1`, alias)
+	if err != nil {
+		return fmt.Errorf("failed to remove alias: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("alias not found: %s", alias)
+	}
+
+	return nil
+}
+
+// ListAliases returns the aliases of a collection
+func (cm *CollectionManagerImpl) ListAliases(collectionID string) ([]string, error) {
+	rows, err := cm.db.Query(`SELECT alias FROM collection_aliases WHERE collection_id = $1 ORDER BY alias`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("failed to scan alias: %w", err)
+		}
+		aliases = append(aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// collectionsByAlias returns the collections an alias refers to. Databases
+// that were not migrated to support aliases have none.
+func (cm *CollectionManagerImpl) collectionsByAlias(alias string) ([]*Collection, error) {
+	query := `
+		SELECT c.id, c.name, c.description, c.folders, c.stats, c.created_at, c.updated_at
+		FROM collections c
+		JOIN collection_aliases a ON a.collection_id = c.id
+		WHERE a.alias = $1
+	`
+
+	collections, err := cm.queryCollections(query, alias)
+	if isUndefinedTable(err) {
+		return nil, nil
+	}
+	return collections, err
+}
+
+// checkNameNotAlias returns an error when a collection name is already used as an alias
+func (cm *CollectionManagerImpl) checkNameNotAlias(name string) error {
+	var exists bool
+	err := cm.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM collection_aliases WHERE alias = $1)`, name).Scan(&exists)
+	if isUndefinedTable(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check aliases: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("name %q is already an alias of a collection", name)
+	}
+	return nil
+}
+
+// isUndefinedTable reports whether an error is caused by a missing table
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
@@ -0,0 +1,320 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// nearEmptyChunkLength is the length in characters below which a chunk
+	// holds too little text to be worth retrieving
+	nearEmptyChunkLength = 50
+	// maxListedOutliers is the number of embedding norm outliers listed
+	maxListedOutliers = 10
+	// duplicateRatioWarning is the share of duplicate chunks above which the
+	// analysis warns
+	duplicateRatioWarning = 0.1
+	// nearEmptyRatioWarning is the share of empty and near-empty chunks above
+	// which the analysis warns
+	nearEmptyRatioWarning = 0.05
+	// commonTermRatioWarning is the share of chunks a term may be found in
+	// before the analysis warns about boilerplate
+	commonTermRatioWarning = 0.9
+)
+
+// chunkLengthBuckets are the upper bounds, in characters, of the chunk length
+// histogram; longer chunks fall in a last open bucket
+var chunkLengthBuckets = []int{100, 250, 500, 1000, 2000, 4000}
+
+// ChunkSample is what the analysis of a collection needs of each chunk
+type ChunkSample struct {
+	FilePath   string
+	ChunkIndex int
+	FileType   string  // File extension, e.g. md
+	Length     int     // Length in characters, without surrounding whitespace
+	Hash       string  // Hash of the content, to find duplicates
+	Encrypted  bool    // Encrypted content has no meaningful length or hash
+	Norm       float64 // Length of the embedding vector
+	Embedded   bool    // Whether the chunk has an embedding
+}
+
+// LengthBucket is a bar of the chunk length histogram
+type LengthBucket struct {
+	Label  string `json:"label"` // Range of lengths, e.g. 100-249
+	Chunks int    `json:"chunks"`
+}
+
+// FileTypeStats describes the chunks of one file type
+type FileTypeStats struct {
+	FileType      string `json:"file_type"`
+	Chunks        int    `json:"chunks"`
+	AverageLength int    `json:"average_length"`
+}
+
+// NormOutlier is a chunk whose embedding is much longer or shorter than the
+// others
+type NormOutlier struct {
+	FilePath   string  `json:"file_path"`
+	ChunkIndex int     `json:"chunk_index"`
+	Norm       float64 `json:"norm"`
+}
+
+// TermCount is a vocabulary term with the number of chunks it is found in
+type TermCount struct {
+	Term   string `json:"term"`
+	Chunks int    `json:"chunks"`
+}
+
+// VocabularyStats summarizes the vocabulary of a collection
+type VocabularyStats struct {
+	Terms    int         `json:"terms"`
+	Rare     int         `json:"rare"` // Terms found in a single chunk
+	TopTerms []TermCount `json:"top_terms"`
+}
+
+// CollectionAnalysis is a report on the quality of a collection's chunks and
+// embeddings, with warnings about likely indexing problems
+type CollectionAnalysis struct {
+	Chunks         int             `json:"chunks"`
+	Encrypted      int             `json:"encrypted"`
+	Empty          int             `json:"empty"`
+	NearEmpty      int             `json:"near_empty"` // Chunks shorter than 50 characters
+	Duplicates     int             `json:"duplicates"` // Chunks with the same content as another
+	DuplicateRatio float64         `json:"duplicate_ratio"`
+	Histogram      []LengthBucket  `json:"histogram"`
+	FileTypes      []FileTypeStats `json:"file_types"`
+	Unembedded     int             `json:"unembedded"`
+	ZeroNorms      int             `json:"zero_norms"`
+	NormMean       float64         `json:"norm_mean"`
+	NormStdDev     float64         `json:"norm_stddev"`
+	NormOutliers   int             `json:"norm_outliers"`
+	Outliers       []NormOutlier   `json:"outliers"` // The furthest outliers
+	Vocabulary     VocabularyStats `json:"vocabulary"`
+	Warnings       []string        `json:"warnings"`
+}
+
+// AnalyzeChunks computes the statistics of a collection from its chunks and
+// vocabulary. Embedding norms further than three standard deviations from the
+// mean, and more than 5% away from it, are outliers; the relative margin keeps
+// the rounding noise of normalized embeddings from being reported.
+func AnalyzeChunks(samples []ChunkSample, vocabulary VocabularyStats) *CollectionAnalysis {
+	analysis := &CollectionAnalysis{
+		Chunks:     len(samples),
+		Histogram:  make([]LengthBucket, len(chunkLengthBuckets)+1),
+		FileTypes:  []FileTypeStats{},
+		Outliers:   []NormOutlier{},
+		Vocabulary: vocabulary,
+	}
+	lower := 0
+	for i, upper := range chunkLengthBuckets {
+		analysis.Histogram[i].Label = fmt.Sprintf("%d-%d", lower, upper-1)
+		lower = upper
+	}
+	analysis.Histogram[len(chunkLengthBuckets)].Label = fmt.Sprintf("%d+", lower)
+
+	hashes := make(map[string]bool, len(samples))
+	fileTypes := make(map[string]*FileTypeStats)
+	var norms []float64
+	for _, sample := range samples {
+		if sample.Embedded {
+			norms = append(norms, sample.Norm)
+			if sample.Norm == 0 {
+				analysis.ZeroNorms++
+			}
+		} else {
+			analysis.Unembedded++
+		}
+
+		if sample.Encrypted {
+			analysis.Encrypted++
+			continue
+		}
+		if hashes[sample.Hash] {
+			analysis.Duplicates++
+		}
+		hashes[sample.Hash] = true
+
+		switch {
+		case sample.Length == 0:
+			analysis.Empty++
+		case sample.Length < nearEmptyChunkLength:
+			analysis.NearEmpty++
+		}
+		bucket := sort.SearchInts(chunkLengthBuckets, sample.Length+1)
+		analysis.Histogram[bucket].Chunks++
+
+		stats, ok := fileTypes[sample.FileType]
+		if !ok {
+			stats = &FileTypeStats{FileType: sample.FileType}
+			fileTypes[sample.FileType] = stats
+		}
+		stats.Chunks++
+		stats.AverageLength += sample.Length
+	}
+
+	if plain := analysis.Chunks - analysis.Encrypted; plain > 0 {
+		analysis.DuplicateRatio = float64(analysis.Duplicates) / float64(plain)
+	}
+	for _, stats := range fileTypes {
+		stats.AverageLength /= stats.Chunks
+		analysis.FileTypes = append(analysis.FileTypes, *stats)
+	}
+	sort.Slice(analysis.FileTypes, func(i, j int) bool {
+		if analysis.FileTypes[i].Chunks != analysis.FileTypes[j].Chunks {
+			return analysis.FileTypes[i].Chunks > analysis.FileTypes[j].Chunks
+		}
+		return analysis.FileTypes[i].FileType < analysis.FileTypes[j].FileType
+	})
+
+	analysis.NormMean, analysis.NormStdDev = meanAndStdDev(norms)
+	for _, sample := range samples {
+		if !sample.Embedded {
+			continue
+		}
+		distance := math.Abs(sample.Norm - analysis.NormMean)
+		if distance > 3*analysis.NormStdDev && distance > 0.05*analysis.NormMean {
+			analysis.NormOutliers++
+			analysis.Outliers = append(analysis.Outliers, NormOutlier{FilePath: sample.FilePath, ChunkIndex: sample.ChunkIndex, Norm: sample.Norm})
+		}
+	}
+	sort.SliceStable(analysis.Outliers, func(i, j int) bool {
+		return math.Abs(analysis.Outliers[i].Norm-analysis.NormMean) > math.Abs(analysis.Outliers[j].Norm-analysis.NormMean)
+	})
+	if len(analysis.Outliers) > maxListedOutliers {
+		analysis.Outliers = analysis.Outliers[:maxListedOutliers]
+	}
+
+	analysis.Warnings = analysis.warnings()
+	return analysis
+}
+
+// warnings returns the likely indexing problems the statistics point to
+func (a *CollectionAnalysis) warnings() []string {
+	warnings := []string{}
+	if a.Chunks == 0 {
+		return append(warnings, "the collection has no chunks; run 'rag-cli index'")
+	}
+
+	plain := a.Chunks - a.Encrypted
+	if plain > 0 && float64(a.Empty+a.NearEmpty)/float64(plain) > nearEmptyRatioWarning {
+		warnings = append(warnings, fmt.Sprintf("%d chunks are empty or shorter than %d characters; they waste search results (check the chunk strategy and file types)",
+			a.Empty+a.NearEmpty, nearEmptyChunkLength))
+	}
+	if a.DuplicateRatio > duplicateRatioWarning {
+		warnings = append(warnings, fmt.Sprintf("%.0f%% of chunks duplicate another chunk; copied files or boilerplate may crowd out other results (check ignore patterns)",
+			a.DuplicateRatio*100))
+	}
+	if a.Unembedded > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d chunks have no embedding and can't be found by vector search; run 'rag-cli index --force'", a.Unembedded))
+	}
+	if a.ZeroNorms > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d chunks have an all-zero embedding; the embedding backend may have failed on them", a.ZeroNorms))
+	}
+	if a.NormOutliers > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d chunks have unusual embedding lengths; they may hold binary or garbled text", a.NormOutliers))
+	}
+	if plain-a.Empty-a.NearEmpty > 0 && a.Vocabulary.Terms == 0 {
+		warnings = append(warnings, "the collection has no vocabulary; run 'rag-cli index' to build it")
+	}
+	for _, term := range a.Vocabulary.TopTerms {
+		if float64(term.Chunks)/float64(a.Chunks) > commonTermRatioWarning {
+			warnings = append(warnings, fmt.Sprintf("'%s' is found in %d of %d chunks; a repeated header or license may be indexed with every chunk",
+				term.Term, term.Chunks, a.Chunks))
+			break
+		}
+	}
+	return warnings
+}
+
+// meanAndStdDev returns the mean and population standard deviation of values
+func meanAndStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+// ListChunkSamples returns what the analysis of a collection needs of each of
+// its chunks
+func ListChunkSamples(db *sql.DB, collectionID string) ([]ChunkSample, error) {
+	rows, err := db.Query(`
+		SELECT file_path, chunk_index, file_type,
+		       length(btrim(content, E' \t\r\n')),
+		       md5(content),
+		       content LIKE '`+encryptedPrefix+`%',
+		       COALESCE(vector_norm(embedding), 0),
+		       embedding IS NOT NULL
+		FROM documents
+		WHERE collection_id = $1
+		ORDER BY file_path, chunk_index
+	`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []ChunkSample
+	for rows.Next() {
+		var sample ChunkSample
+		if err := rows.Scan(&sample.FilePath, &sample.ChunkIndex, &sample.FileType, &sample.Length,
+			&sample.Hash, &sample.Encrypted, &sample.Norm, &sample.Embedded); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		samples = append(samples, sample)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over chunks: %w", err)
+	}
+
+	return samples, nil
+}
+
+// CollectionVocabularyStats returns the size of a collection's vocabulary and
+// its most common terms
+func CollectionVocabularyStats(db *sql.DB, collectionID string, topTerms int) (VocabularyStats, error) {
+	stats := VocabularyStats{TopTerms: []TermCount{}}
+	err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE doc_count = 1)
+		FROM collection_terms
+		WHERE collection_id = $1
+	`, collectionID).Scan(&stats.Terms, &stats.Rare)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count vocabulary terms: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT term, doc_count FROM collection_terms
+		WHERE collection_id = $1
+		ORDER BY doc_count DESC, term
+		LIMIT $2
+	`, collectionID, topTerms)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list vocabulary terms: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var term TermCount
+		if err := rows.Scan(&term.Term, &term.Chunks); err != nil {
+			return stats, fmt.Errorf("failed to scan vocabulary term: %w", err)
+		}
+		stats.TopTerms = append(stats.TopTerms, term)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("error iterating over vocabulary terms: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeChunks(t *testing.T) {
+	var samples []ChunkSample
+	for i := 0; i < 20; i++ {
+		samples = append(samples, ChunkSample{
+			FilePath: "guide.md", ChunkIndex: i, FileType: "md", Length: 600,
+			Hash: fmt.Sprintf("hash-%d", i), Norm: 1.0 + float64(i%3)*0.001, Embedded: true,
+		})
+	}
+	samples = append(samples,
+		ChunkSample{FilePath: "main.go", FileType: "go", Length: 0, Hash: "empty", Norm: 1, Embedded: true},
+		ChunkSample{FilePath: "main.go", ChunkIndex: 1, FileType: "go", Length: 20, Hash: "short", Norm: 1, Embedded: true},
+		ChunkSample{FilePath: "copy.md", FileType: "md", Length: 600, Hash: "hash-0", Norm: 1, Embedded: true},
+		ChunkSample{FilePath: "image.txt", FileType: "txt", Length: 5000, Hash: "binary", Norm: 3.5, Embedded: true},
+		ChunkSample{FilePath: "secret.md", FileType: "md", Encrypted: true, Hash: "hash-1", Norm: 1, Embedded: true},
+		ChunkSample{FilePath: "new.md", FileType: "md", Length: 300, Hash: "new"},
+	)
+
+	analysis := AnalyzeChunks(samples, VocabularyStats{Terms: 100, TopTerms: []TermCount{{Term: "guide", Chunks: 20}}})
+	assert.Equal(t, 26, analysis.Chunks)
+	assert.Equal(t, 1, analysis.Encrypted)
+	assert.Equal(t, 1, analysis.Empty)
+	assert.Equal(t, 1, analysis.NearEmpty)
+	assert.Equal(t, 1, analysis.Duplicates)
+	assert.InDelta(t, 0.04, analysis.DuplicateRatio, 0.0001)
+	assert.Equal(t, 1, analysis.Unembedded)
+
+	assert.Equal(t, []LengthBucket{
+		{Label: "0-99", Chunks: 2},
+		{Label: "100-249", Chunks: 0},
+		{Label: "250-499", Chunks: 1},
+		{Label: "500-999", Chunks: 21},
+		{Label: "1000-1999", Chunks: 0},
+		{Label: "2000-3999", Chunks: 0},
+		{Label: "4000+", Chunks: 1},
+	}, analysis.Histogram)
+	assert.Equal(t, []FileTypeStats{
+		{FileType: "md", Chunks: 22, AverageLength: 586},
+		{FileType: "go", Chunks: 2, AverageLength: 10},
+		{FileType: "txt", Chunks: 1, AverageLength: 5000},
+	}, analysis.FileTypes)
+
+	assert.Equal(t, 1, analysis.NormOutliers)
+	assert.Equal(t, []NormOutlier{{FilePath: "image.txt", Norm: 3.5}}, analysis.Outliers)
+
+	require.Len(t, analysis.Warnings, 3)
+	assert.Contains(t, analysis.Warnings[0], "2 chunks are empty")
+	assert.Contains(t, analysis.Warnings[1], "1 chunks have no embedding")
+	assert.Contains(t, analysis.Warnings[2], "unusual embedding lengths")
+}
+
+func TestAnalyzeChunksNormalizedNoise(t *testing.T) {
+	var samples []ChunkSample
+	for i := 0; i < 50; i++ {
+		samples = append(samples, ChunkSample{Length: 500, Hash: fmt.Sprintf("%d", i), Norm: 1, Embedded: true})
+	}
+	// Rounding noise of a normalized embedding is far from the others in
+	// standard deviations, but not in length
+	samples[0].Norm = 1.0001
+
+	analysis := AnalyzeChunks(samples, VocabularyStats{Terms: 10})
+	assert.Zero(t, analysis.NormOutliers)
+	assert.Empty(t, analysis.Warnings)
+}
+
+func TestAnalyzeChunksWarnings(t *testing.T) {
+	assert.Equal(t, []string{"the collection has no chunks; run 'rag-cli index'"}, AnalyzeChunks(nil, VocabularyStats{}).Warnings)
+
+	samples := []ChunkSample{
+		{Length: 500, Hash: "a", Embedded: true},
+		{Length: 500, Hash: "a", Embedded: true},
+		{Length: 500, Hash: "b", Norm: 1, Embedded: true},
+	}
+	analysis := AnalyzeChunks(samples, VocabularyStats{TopTerms: []TermCount{{Term: "copyright", Chunks: 3}}})
+	require.Len(t, analysis.Warnings, 4)
+	assert.Contains(t, analysis.Warnings[0], "33% of chunks duplicate another chunk")
+	assert.Contains(t, analysis.Warnings[1], "2 chunks have an all-zero embedding")
+	assert.Contains(t, analysis.Warnings[2], "no vocabulary")
+	assert.Contains(t, analysis.Warnings[3], "'copyright' is found in 3 of 3 chunks")
+}
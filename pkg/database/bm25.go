@@ -0,0 +1,254 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+const (
+	// bm25K1 controls term frequency saturation
+	bm25K1 = 1.2
+	// bm25B controls document length normalization
+	bm25B = 0.75
+	// bm25SymbolWeight is how many occurrences in the content an occurrence
+	// among a code chunk's symbols counts as
+	bm25SymbolWeight = 3.0
+	// rrfK dampens the influence of top ranks in reciprocal rank fusion
+	rrfK = 60
+	// fusionCandidateFactor is how many candidates per requested result each retriever contributes
+	fusionCandidateFactor = 4
+	// minFusionCandidates is the minimum number of candidates each retriever contributes
+	minFusionCandidates = 50
+)
+
+// bm25WordPattern matches the words of a query that are safe to pass to to_tsquery
+var bm25WordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// bm25Query turns free text into a tsquery expression matching any of its words
+func bm25Query(textQuery string) string {
+	return strings.Join(bm25WordPattern.FindAllString(textQuery, -1), " | ")
+}
+
+// searchBM25 ranks documents with Okapi BM25 over the stored term vectors.
+// Terms among a code chunk's symbols, which have weight A, count
+// bm25SymbolWeight times. Text scores are normalized to 0-1 relative to the
+// best match.
+func (se *SearchEngineImpl) searchBM25(collectionID string, textQuery string, limit int, opts *SearchOptions) ([]*SearchResult, error) {
+	tsQuery := bm25Query(textQuery)
+	if tsQuery == "" {
+		return nil, fmt.Errorf("text query is required for BM25 search")
+	}
+
+	// Candidates are the documents containing at least one query term, so the
+	// per-term document frequency can be counted over the candidates alone
+	query := `
+		WITH query AS (
+			SELECT to_tsquery('english', $2) AS q,
+			       tsvector_to_array(to_tsvector('english', $3)) AS terms
+		),
+		stats AS (
+			SELECT COUNT(*)::float8 AS n, GREATEST(COALESCE(AVG(content_length), 0), 1)::float8 AS avgdl
+			FROM documents
+			WHERE collection_id = $1
+		),
+		matches AS (
+			SELECT d.id, d.content_length, t.lexeme,
+			       COALESCE((SELECT SUM(CASE WHEN w = 'A' THEN $8::float8 ELSE 1 END) FROM unnest(t.weights) w), 1)::float8 AS tf
+			FROM documents d, query, unnest(d.content_tsv) t
+			WHERE d.collection_id = $1
+			  AND d.content_tsv @@ query.q
+			  AND ($7::text[] IS NULL OR d.file_type = ANY($7::text[]))%s
+			  AND t.lexeme = ANY(query.terms)
+		),
+		df AS (
+			SELECT lexeme, COUNT(*)::float8 AS df
+			FROM matches
+			GROUP BY lexeme
+		),
+		scores AS (
+			SELECT m.id,
+			       SUM(ln(1 + (s.n - df.df + 0.5) / (df.df + 0.5)) *
+			           (m.tf * ($4::float8 + 1)) /
+			           (m.tf + $4::float8 * (1 - $5::float8 + $5::float8 * m.content_length / s.avgdl))) AS bm25
+			FROM matches m
+			JOIN df ON df.lexeme = m.lexeme
+			CROSS JOIN stats s
+			GROUP BY m.id
+		)
+		SELECT d.id, d.collection_id, COALESCE(d.folder, ''), d.file_path, d.file_name, d.content, d.chunk_index, d.embedding, d.metadata, d.created_at, d.updated_at,
+		       scores.bm25
+		FROM scores
+		JOIN documents d ON d.id = scores.id
+		ORDER BY scores.bm25 DESC
+		LIMIT $6
+	`
+
+	whereSQL, whereArgs := metadataConditionsSQL(opts, "d.metadata", 9)
+	query = fmt.Sprintf(query, whereSQL)
+
+	args := append([]interface{}{collectionID, tsQuery, strings.ReplaceAll(tsQuery, " | ", " "), bm25K1, bm25B, limit, pq.Array(opts.FileTypes), bm25SymbolWeight}, whereArgs...)
+	rows, err := se.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*SearchResult
+	for rows.Next() {
+		doc := &Document{}
+		var embeddingVector pgvector.Vector
+		var bm25Score float64
+
+		err := rows.Scan(
+			&doc.ID,
+			&doc.CollectionID,
+			&doc.Folder,
+			&doc.FilePath,
+			&doc.FileName,
+			&doc.Content,
+			&doc.ChunkIndex,
+			&embeddingVector,
+			&doc.Metadata,
+			&doc.CreatedAt,
+			&doc.UpdatedAt,
+			&bm25Score,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+
+		// Convert pgvector.Vector back to []float32
+		doc.Embedding = embeddingVector.Slice()
+		if err := decryptContent(&doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+		}
+
+		results = append(results, &SearchResult{
+			Document:  doc,
+			TextScore: bm25Score,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read documents: %w", err)
+	}
+
+	// Results are ordered by score, so the first one is the best match
+	if len(results) > 0 && results[0].TextScore > 0 {
+		best := results[0].TextScore
+		for _, result := range results {
+			result.TextScore /= best
+			result.CombinedScore = result.TextScore
+		}
+	}
+
+	return results, nil
+}
+
+// searchFusion combines dense vector search and BM25 with reciprocal rank fusion
+func (se *SearchEngineImpl) searchFusion(collectionID string, embedding []float32, textQuery string, limit int, opts *SearchOptions) ([]*SearchResult, error) {
+	candidates := fusionCandidates(limit)
+
+	var dense, sparse []*SearchResult
+	var err error
+
+	if embedding != nil {
+		dense, err = se.searchVectorOnly(collectionID, embedding, candidates, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hasText := bm25Query(textQuery) != ""
+	if hasText {
+		sparse, err = se.searchBM25(collectionID, textQuery, candidates, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if embedding == nil && !hasText {
+		return nil, fmt.Errorf("either embedding or text query must be provided")
+	}
+
+	return fuseResults(dense, sparse, opts.VectorWeight, opts.TextWeight, limit), nil
+}
+
+// fuseResults merges dense and sparse rankings with weighted reciprocal rank fusion
+func fuseResults(dense, sparse []*SearchResult, vectorWeight, textWeight float64, limit int) []*SearchResult {
+	if vectorWeight <= 0 && textWeight <= 0 {
+		vectorWeight, textWeight = 0.5, 0.5
+	}
+	return FuseRankings([][]*SearchResult{dense, sparse}, []float64{vectorWeight, textWeight}, limit)
+}
+
+// fusionCandidates returns how many results each ranking should contribute to a fusion
+func fusionCandidates(limit int) int {
+	candidates := limit * fusionCandidateFactor
+	if candidates < minFusionCandidates {
+		candidates = minFusionCandidates
+	}
+	return candidates
+}
+
+// FuseRankings merges several rankings of the same documents with weighted
+// reciprocal rank fusion. Each document keeps its best vector score, text
+// score and confidence, and combined scores are scaled so that a document
+// ranked first everywhere scores 1.
+func FuseRankings(rankings [][]*SearchResult, weights []float64, limit int) []*SearchResult {
+	var totalWeight float64
+	for i := range rankings {
+		totalWeight += weights[i]
+	}
+	if totalWeight <= 0 {
+		return nil
+	}
+	maxScore := totalWeight / (rrfK + 1)
+
+	fused := make(map[string]*SearchResult)
+	var order []string
+
+	for r, ranking := range rankings {
+		for i, result := range ranking {
+			existing, ok := fused[result.Document.ID]
+			if !ok {
+				existing = &SearchResult{Document: result.Document}
+				fused[result.Document.ID] = existing
+				order = append(order, result.Document.ID)
+			}
+			if result.VectorScore > existing.VectorScore {
+				existing.VectorScore = result.VectorScore
+			}
+			if result.TextScore > existing.TextScore {
+				existing.TextScore = result.TextScore
+			}
+			if result.Confidence > existing.Confidence {
+				existing.Confidence = result.Confidence
+			}
+			existing.CombinedScore += weights[r] / float64(rrfK+i+1) / maxScore
+		}
+	}
+
+	results := make([]*SearchResult, 0, len(order))
+	for _, id := range order {
+		results = append(results, fused[id])
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].CombinedScore > results[j].CombinedScore
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	for i, result := range results {
+		result.Rank = i + 1
+	}
+
+	return results
+}
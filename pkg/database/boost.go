@@ -0,0 +1,298 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BoostKind identifies what a boost rule matches on
+type BoostKind string
+
+const (
+	BoostKindFile    BoostKind = "file"    // File name (or path) glob
+	BoostKindTag     BoostKind = "tag"     // Tag in the document metadata
+	BoostKindRecency BoostKind = "recency" // Decays with the age of the chunk
+)
+
+// BoostOp is how a boost rule changes the score
+type BoostOp string
+
+const (
+	BoostOpAdd      BoostOp = "+" // Add the value to the score
+	BoostOpMultiply BoostOp = "x" // Multiply the score by the value
+)
+
+// defaultRecencyBoost is the boost applied by a recency rule without a value
+const defaultRecencyBoost = 0.1
+
+// BoostRule adjusts the score of search results that match it.
+//
+// Rules are written as kind:pattern=value, for example:
+//
+//	file:README*=+0.1   add 0.1 to chunks of files named README*
+//	file:docs/*.md=x1.2 multiply the score of markdown files under docs/ by 1.2
+//	tag:deprecated=x0.5 halve the score of chunks tagged "deprecated"
+//	recency:30d=+0.2    add up to 0.2 to recent chunks, halving every 30 days
+//
+// Rules are applied in order to each result's combined score.
+type BoostRule struct {
+	Kind     BoostKind     `json:"kind"`
+	Pattern  string        `json:"pattern,omitempty"`   // Glob for file rules, tag name for tag rules
+	HalfLife time.Duration `json:"half_life,omitempty"` // Half-life for recency rules
+	Op       BoostOp       `json:"op"`
+	Value    float64       `json:"value"`
+}
+
+// ParseBoostRule parses a boost rule from its kind:pattern=value form
+func ParseBoostRule(spec string) (BoostRule, error) {
+	kind, rest, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		return BoostRule{}, fmt.Errorf("invalid boost %q: expected kind:pattern=value", spec)
+	}
+
+	rule := BoostRule{Kind: BoostKind(strings.ToLower(kind))}
+
+	pattern, value, hasValue := rest, "", false
+	if i := strings.LastIndex(rest, "="); i >= 0 {
+		pattern, value, hasValue = rest[:i], rest[i+1:], true
+	}
+	if pattern == "" {
+		return BoostRule{}, fmt.Errorf("invalid boost %q: missing pattern", spec)
+	}
+
+	switch rule.Kind {
+	case BoostKindFile:
+		if _, err := path.Match(pattern, ""); err != nil {
+			return BoostRule{}, fmt.Errorf("invalid boost %q: bad file pattern: %w", spec, err)
+		}
+		rule.Pattern = pattern
+	case BoostKindTag:
+		rule.Pattern = pattern
+	case BoostKindRecency:
+		halfLife, err := parseHalfLife(pattern)
+		if err != nil {
+			return BoostRule{}, fmt.Errorf("invalid boost %q: %w", spec, err)
+		}
+		rule.HalfLife = halfLife
+		if !hasValue {
+			rule.Op, rule.Value = BoostOpAdd, defaultRecencyBoost
+			return rule, nil
+		}
+	default:
+		return BoostRule{}, fmt.Errorf("invalid boost %q: unknown kind %q (must be file, tag or recency)", spec, kind)
+	}
+
+	if !hasValue {
+		return BoostRule{}, fmt.Errorf("invalid boost %q: missing value", spec)
+	}
+
+	op, amount, err := parseBoostValue(value)
+	if err != nil {
+		return BoostRule{}, fmt.Errorf("invalid boost %q: %w", spec, err)
+	}
+	rule.Op, rule.Value = op, amount
+
+	return rule, nil
+}
+
+// ParseBoostRules parses a list of boost rules
+func ParseBoostRules(specs []string) ([]BoostRule, error) {
+	var rules []BoostRule
+	for _, spec := range specs {
+		rule, err := ParseBoostRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// String returns the rule in the form accepted by ParseBoostRule
+func (r BoostRule) String() string {
+	pattern := r.Pattern
+	if r.Kind == BoostKindRecency {
+		pattern = formatHalfLife(r.HalfLife)
+	}
+
+	value := strconv.FormatFloat(r.Value, 'g', -1, 64)
+	if r.Op == BoostOpAdd && r.Value >= 0 {
+		value = "+" + value
+	} else if r.Op == BoostOpMultiply {
+		value = "x" + value
+	}
+
+	return fmt.Sprintf("%s:%s=%s", r.Kind, pattern, value)
+}
+
+// parseBoostValue parses +0.1, -0.1, x1.5 or *1.5
+func parseBoostValue(value string) (BoostOp, float64, error) {
+	if value == "" {
+		return "", 0, fmt.Errorf("missing value")
+	}
+
+	op := BoostOpAdd
+	number := value
+	switch value[0] {
+	case 'x', 'X', '*':
+		op, number = BoostOpMultiply, value[1:]
+	case '+':
+		number = value[1:]
+	case '-':
+	default:
+		return "", 0, fmt.Errorf("value %q must start with +, -, x or *", value)
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return "", 0, fmt.Errorf("invalid value %q", value)
+	}
+	if op == BoostOpMultiply && amount <= 0 {
+		return "", 0, fmt.Errorf("multiplier %q must be greater than 0", value)
+	}
+
+	return op, amount, nil
+}
+
+// parseHalfLife parses a duration that may also be given in days, e.g. 30d
+func parseHalfLife(value string) (time.Duration, error) {
+	var halfLife time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid half-life %q", value)
+		}
+		halfLife = time.Duration(n * float64(24*time.Hour))
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid half-life %q", value)
+		}
+		halfLife = d
+	}
+
+	if halfLife <= 0 {
+		return 0, fmt.Errorf("half-life %q must be positive", value)
+	}
+	return halfLife, nil
+}
+
+// formatHalfLife formats a half-life, using days when it is a whole number of days
+func formatHalfLife(d time.Duration) string {
+	day := 24 * time.Hour
+	if d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
+
+// factor returns how strongly the rule applies to a document, from 0 (not at all) to 1
+func (r BoostRule) factor(doc *Document, tags []string, now time.Time) float64 {
+	switch r.Kind {
+	case BoostKindFile:
+		if !strings.Contains(r.Pattern, "/") {
+			if matched, _ := path.Match(r.Pattern, doc.FileName); matched {
+				return 1
+			}
+			return 0
+		}
+		// Path patterns match any trailing part of the path, so docs/*.md
+		// matches /repo/docs/intro.md
+		target := filepath.ToSlash(doc.FilePath)
+		for {
+			if matched, _ := path.Match(r.Pattern, target); matched {
+				return 1
+			}
+			i := strings.Index(target, "/")
+			if i < 0 {
+				break
+			}
+			target = target[i+1:]
+		}
+	case BoostKindTag:
+		for _, tag := range tags {
+			if strings.EqualFold(tag, r.Pattern) {
+				return 1
+			}
+		}
+	case BoostKindRecency:
+		if doc.UpdatedAt.IsZero() {
+			return 0
+		}
+		age := now.Sub(doc.UpdatedAt)
+		if age < 0 {
+			age = 0
+		}
+		return math.Pow(0.5, float64(age)/float64(r.HalfLife))
+	}
+	return 0
+}
+
+// documentTags returns the tags stored in a document's metadata, either as
+// a list or as a comma-separated string
+func documentTags(metadata string) []string {
+	if metadata == "" {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(metadata), &fields); err != nil {
+		return nil
+	}
+
+	var tags []string
+	switch value := fields["tags"].(type) {
+	case []interface{}:
+		for _, tag := range value {
+			if s, ok := tag.(string); ok {
+				tags = append(tags, strings.TrimSpace(s))
+			}
+		}
+	case string:
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// applyBoosts adjusts the combined scores of the results and re-sorts them
+func applyBoosts(results []*SearchResult, rules []BoostRule, now time.Time) []*SearchResult {
+	if len(rules) == 0 || len(results) == 0 {
+		return results
+	}
+
+	for _, result := range results {
+		tags := documentTags(result.Document.Metadata)
+		for _, rule := range rules {
+			factor := rule.factor(result.Document, tags, now)
+			if factor == 0 {
+				continue
+			}
+			switch rule.Op {
+			case BoostOpAdd:
+				result.CombinedScore += rule.Value * factor
+			case BoostOpMultiply:
+				result.CombinedScore *= 1 + (rule.Value-1)*factor
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].CombinedScore > results[j].CombinedScore
+	})
+	for i, result := range results {
+		result.Rank = i + 1
+	}
+
+	return results
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBoostRule(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected BoostRule
+	}{
+		{"file:README*=+0.1", BoostRule{Kind: BoostKindFile, Pattern: "README*", Op: BoostOpAdd, Value: 0.1}},
+		{"file:docs/*.md=x1.2", BoostRule{Kind: BoostKindFile, Pattern: "docs/*.md", Op: BoostOpMultiply, Value: 1.2}},
+		{"tag:deprecated=*0.5", BoostRule{Kind: BoostKindTag, Pattern: "deprecated", Op: BoostOpMultiply, Value: 0.5}},
+		{"tag:draft=-0.2", BoostRule{Kind: BoostKindTag, Pattern: "draft", Op: BoostOpAdd, Value: -0.2}},
+		{"recency:30d=+0.2", BoostRule{Kind: BoostKindRecency, HalfLife: 30 * 24 * time.Hour, Op: BoostOpAdd, Value: 0.2}},
+		{"recency:12h", BoostRule{Kind: BoostKindRecency, HalfLife: 12 * time.Hour, Op: BoostOpAdd, Value: defaultRecencyBoost}},
+	}
+
+	for _, test := range tests {
+		rule, err := ParseBoostRule(test.spec)
+		require.NoError(t, err, test.spec)
+		assert.Equal(t, test.expected, rule, test.spec)
+
+		// Rules survive a round trip through their string form
+		again, err := ParseBoostRule(rule.String())
+		require.NoError(t, err, rule.String())
+		assert.Equal(t, rule, again, rule.String())
+	}
+
+	invalid := []string{
+		"README*=+0.1",
+		"file:README*",
+		"file:=+0.1",
+		"file:[=+0.1",
+		"size:big=+0.1",
+		"tag:draft=0.1",
+		"tag:draft=x0",
+		"tag:draft=+abc",
+		"recency:soon=+0.1",
+		"recency:-1d=+0.1",
+	}
+	for _, spec := range invalid {
+		_, err := ParseBoostRule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestApplyBoosts(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	results := []*SearchResult{
+		{Document: &Document{ID: "guide", FileName: "guide.md", FilePath: "/repo/docs/guide.md", UpdatedAt: now.Add(-60 * 24 * time.Hour)}, CombinedScore: 0.8},
+		{Document: &Document{ID: "readme", FileName: "README.md", FilePath: "/repo/README.md", UpdatedAt: now.Add(-60 * 24 * time.Hour)}, CombinedScore: 0.75},
+		{Document: &Document{ID: "old", FileName: "old.txt", FilePath: "/repo/old.txt", Metadata: `{"tags": ["deprecated"]}`}, CombinedScore: 0.9},
+		{Document: &Document{ID: "new", FileName: "new.txt", FilePath: "/repo/new.txt", UpdatedAt: now}, CombinedScore: 0.5},
+	}
+
+	rules, err := ParseBoostRules([]string{"file:README*=+0.1", "tag:deprecated=x0.5", "recency:30d=+0.2", "file:docs/*.md=x1.1"})
+	require.NoError(t, err)
+
+	results = applyBoosts(results, rules, now)
+	require.Len(t, results, 4)
+
+	scores := map[string]float64{}
+	for i, result := range results {
+		assert.Equal(t, i+1, result.Rank)
+		scores[result.Document.ID] = result.CombinedScore
+	}
+
+	assert.InDelta(t, 0.75+0.1+0.2*0.25, scores["readme"], 1e-9)
+	// Rules apply in order, so the recency boost is multiplied too
+	assert.InDelta(t, (0.8+0.2*0.25)*1.1, scores["guide"], 1e-9)
+	assert.InDelta(t, 0.45, scores["old"], 1e-9)
+	assert.InDelta(t, 0.7, scores["new"], 1e-9)
+	assert.Equal(t, "guide", results[0].Document.ID)
+	assert.Equal(t, "old", results[3].Document.ID)
+}
+
+func TestDocumentTags(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, documentTags(`{"tags": ["a", "b"]}`))
+	assert.Equal(t, []string{"a", "b"}, documentTags(`{"tags": "a, b"}`))
+	assert.Nil(t, documentTags(`{"file_name": "x"}`))
+	assert.Nil(t, documentTags(`not json`))
+}
@@ -0,0 +1,270 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrChatSessionNotFound is returned when a chat session doesn't exist or has expired
+var ErrChatSessionNotFound = errors.New("chat session not found")
+
+// ChatSessionManagerImpl implements ChatSessionManager
+type ChatSessionManagerImpl struct {
+	db *sql.DB
+}
+
+// NewChatSessionManager creates a new chat session manager
+func NewChatSessionManager(db *sql.DB) ChatSessionManager {
+	return &ChatSessionManagerImpl{db: db}
+}
+
+// CreateSession stores a new session and fills in its ID and timestamps
+func (sm *ChatSessionManagerImpl) CreateSession(session *ChatSession) error {
+	settings, err := json.Marshal(session.Settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session settings: %w", err)
+	}
+
+	var name sql.NullString
+	if session.Name != "" {
+		name = sql.NullString{String: session.Name, Valid: true}
+	}
+
+	err = sm.db.QueryRow(`
+		INSERT INTO chat_sessions (name, collection_id, settings, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, name, session.CollectionID, settings, session.ExpiresAt).Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("chat session %q already exists", session.Name)
+		}
+		return fmt.Errorf("failed to create chat session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession returns a session that has not expired
+func (sm *ChatSessionManagerImpl) GetSession(id string) (*ChatSession, error) {
+	if !isUUID(id) {
+		return nil, ErrChatSessionNotFound
+	}
+
+	session := &ChatSession{}
+	var name sql.NullString
+	var settings []byte
+	var expiresAt sql.NullTime
+
+	err := sm.db.QueryRow(`
+		SELECT id, name, collection_id, settings, created_at, updated_at, expires_at
+		FROM chat_sessions
+		WHERE id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+	`, id).Scan(&session.ID, &name, &session.CollectionID, &settings, &session.CreatedAt, &session.UpdatedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrChatSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat session: %w", err)
+	}
+
+	session.Name = name.String
+	if expiresAt.Valid {
+		session.ExpiresAt = &expiresAt.Time
+	}
+	if err := json.Unmarshal(settings, &session.Settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session settings: %w", err)
+	}
+
+	return session, nil
+}
+
+// GetSessionByIdOrName returns a session that has not expired by its ID or
+// name
+func (sm *ChatSessionManagerImpl) GetSessionByIdOrName(idOrName string) (*ChatSession, error) {
+	if isUUID(idOrName) {
+		session, err := sm.GetSession(idOrName)
+		if !errors.Is(err, ErrChatSessionNotFound) {
+			return session, err
+		}
+	}
+
+	var id string
+	err := sm.db.QueryRow(`
+		SELECT id FROM chat_sessions
+		WHERE name = $1 AND (expires_at IS NULL OR expires_at > NOW())
+	`, idOrName).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrChatSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat session: %w", err)
+	}
+
+	return sm.GetSession(id)
+}
+
+// ListSessions returns the sessions that have not expired with the names of
+// their collections and the number of their messages, most recently used
+// first
+func (sm *ChatSessionManagerImpl) ListSessions() ([]*ChatSessionSummary, error) {
+	rows, err := sm.db.Query(`
+		SELECT s.id, s.name, s.collection_id, c.name, s.settings, s.created_at, s.updated_at, s.expires_at, COUNT(m.id)
+		FROM chat_sessions s
+		JOIN collections c ON c.id = s.collection_id
+		LEFT JOIN chat_messages m ON m.session_id = s.id
+		WHERE s.expires_at IS NULL OR s.expires_at > NOW()
+		GROUP BY s.id, c.name
+		ORDER BY s.updated_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chat sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*ChatSessionSummary
+	for rows.Next() {
+		session := &ChatSessionSummary{}
+		var name sql.NullString
+		var settings []byte
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&session.ID, &name, &session.CollectionID, &session.CollectionName, &settings, &session.CreatedAt, &session.UpdatedAt, &expiresAt, &session.Messages); err != nil {
+			return nil, fmt.Errorf("failed to scan chat session: %w", err)
+		}
+		session.Name = name.String
+		if expiresAt.Valid {
+			session.ExpiresAt = &expiresAt.Time
+		}
+		if err := json.Unmarshal(settings, &session.Settings); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal session settings: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chat sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// DeleteSession deletes a session and its messages
+func (sm *ChatSessionManagerImpl) DeleteSession(id string) error {
+	if !isUUID(id) {
+		return ErrChatSessionNotFound
+	}
+
+	result, err := sm.db.Exec(`DELETE FROM chat_sessions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrChatSessionNotFound
+	}
+
+	return nil
+}
+
+// TouchSession marks a session as used and moves its expiry
+func (sm *ChatSessionManagerImpl) TouchSession(id string, expiresAt *time.Time) error {
+	_, err := sm.db.Exec(`UPDATE chat_sessions SET updated_at = NOW(), expires_at = $2 WHERE id = $1`, id, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to update chat session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions that have expired and returns how many there were
+func (sm *ChatSessionManagerImpl) DeleteExpiredSessions() (int, error) {
+	result, err := sm.db.Exec(`DELETE FROM chat_sessions WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired chat sessions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// AddMessage appends a message to a session's conversation
+func (sm *ChatSessionManagerImpl) AddMessage(sessionID, role, content string) (*ChatMessage, error) {
+	return sm.AddMessageWithSources(sessionID, role, content, nil)
+}
+
+// AddMessageWithSources appends a message to a session's conversation along
+// with the context documents it is based on
+func (sm *ChatSessionManagerImpl) AddMessageWithSources(sessionID, role, content string, sources []ChatSource) (*ChatMessage, error) {
+	message := &ChatMessage{
+		SessionID: sessionID,
+		Role:      role,
+		Content:   content,
+		Sources:   sources,
+	}
+
+	var encoded []byte
+	if len(sources) > 0 {
+		var err error
+		encoded, err = json.Marshal(sources)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal message sources: %w", err)
+		}
+	}
+
+	err := sm.db.QueryRow(`
+		INSERT INTO chat_messages (session_id, role, content, sources)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, sessionID, role, content, encoded).Scan(&message.ID, &message.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add chat message: %w", err)
+	}
+
+	return message, nil
+}
+
+// ListMessages returns a session's conversation in order
+func (sm *ChatSessionManagerImpl) ListMessages(sessionID string) ([]*ChatMessage, error) {
+	rows, err := sm.db.Query(`
+		SELECT id, session_id, role, content, sources, created_at
+		FROM chat_messages
+		WHERE session_id = $1
+		ORDER BY id
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chat messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*ChatMessage
+	for rows.Next() {
+		message := &ChatMessage{}
+		var sources []byte
+		if err := rows.Scan(&message.ID, &message.SessionID, &message.Role, &message.Content, &sources, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan chat message: %w", err)
+		}
+		if sources != nil {
+			if err := json.Unmarshal(sources, &message.Sources); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal message sources: %w", err)
+			}
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chat messages: %w", err)
+	}
+
+	return messages, nil
+}
@@ -22,6 +22,10 @@ func NewCollectionManager(db *sql.DB) CollectionManager {
 
 // CreateCollection creates a new collection
 func (cm *CollectionManagerImpl) CreateCollection(name, description string, folders []string) (*Collection, error) {
+	if err := cm.checkNameNotAlias(name); err != nil {
+		return nil, err
+	}
+
 	query := `
 		INSERT INTO collections (name, description, folders)
 		VALUES ($1, $2, $3)
@@ -74,6 +78,9 @@ func (cm *CollectionManagerImpl) GetCollection(id string) (*Collection, error) {
 		&collection.UpdatedAt,
 	)
 
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, id)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get collection: %w", err)
 	}
@@ -91,6 +98,7 @@ func (cm *CollectionManagerImpl) ListCollections() ([]*Collection, error) {
 	query := `
 		SELECT id, name, description, folders, stats, created_at, updated_at
 		FROM collections
+		WHERE snapshot_of IS NULL
 		ORDER BY created_at DESC
 	`
 
@@ -148,6 +156,14 @@ func (cm *CollectionManagerImpl) DeleteCollection(id string) error {
 		return fmt.Errorf("collection not found")
 	}
 
+	// Drop the collection's dedicated vector index, if any
+	if isUUID(id) {
+		query := fmt.Sprintf(`DROP INDEX IF EXISTS %s`, pq.QuoteIdentifier(vectorIndexName(id)))
+		if _, err := cm.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to drop vector index: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -157,7 +173,7 @@ func (cm *CollectionManagerImpl) UpdateCollectionStats(collectionID string) erro
 		UPDATE collections 
 		SET stats = (
 			SELECT jsonb_build_object(
-				'total_documents', COUNT(DISTINCT file_path),
+				'total_documents', COUNT(DISTINCT (folder, file_path)),
 				'total_chunks', COUNT(*),
 				'total_size', COALESCE(SUM(length(content)), 0)
 			)
@@ -181,9 +197,10 @@ func isUUID(str string) bool {
 	return uuidRegex.MatchString(strings.ToLower(str))
 }
 
-// GetCollectionByIdOrName retrieves a collection by ID (UUID) or name
-// If the input looks like a UUID, it uses GetCollection directly
-// Otherwise, it searches by name and handles multiple matches
+// GetCollectionByIdOrName retrieves a collection by ID (UUID), name or alias.
+// If the input looks like a UUID, it uses GetCollection directly. Otherwise
+// it returns an AmbiguousCollectionError when the name and aliases match more
+// than one collection.
 func (cm *CollectionManagerImpl) GetCollectionByIdOrName(collectionIdOrName string) (*Collection, error) {
 	// Check if input looks like a UUID
 	if isUUID(collectionIdOrName) {
@@ -198,10 +215,39 @@ func (cm *CollectionManagerImpl) GetCollectionByIdOrName(collectionIdOrName stri
 		ORDER BY created_at DESC
 	`
 
-	rows, err := cm.db.Query(query, collectionIdOrName)
+	collections, err := cm.queryCollections(query, collectionIdOrName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query collections by name: %w", err)
 	}
+
+	// Then by alias
+	aliased, err := cm.collectionsByAlias(collectionIdOrName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collections by alias: %w", err)
+	}
+	for _, collection := range aliased {
+		if !containsCollection(collections, collection.ID) {
+			collections = append(collections, collection)
+		}
+	}
+
+	if len(collections) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionIdOrName)
+	}
+
+	if len(collections) > 1 {
+		return nil, &AmbiguousCollectionError{Reference: collectionIdOrName, Candidates: collections}
+	}
+
+	return collections[0], nil
+}
+
+// queryCollections runs a query returning collection rows
+func (cm *CollectionManagerImpl) queryCollections(query string, args ...interface{}) ([]*Collection, error) {
+	rows, err := cm.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	var collections []*Collection
@@ -230,18 +276,21 @@ func (cm *CollectionManagerImpl) GetCollectionByIdOrName(collectionIdOrName stri
 
 		collections = append(collections, collection)
 	}
-
-	if len(collections) == 0 {
-		return nil, fmt.Errorf("collection not found: %s", collectionIdOrName)
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	if len(collections) > 1 {
-		// Show warning about multiple matches
-		fmt.Printf("Warning: Multiple collections found with name '%s'. Using the first match (ID: %s).\n", collectionIdOrName, collections[0].ID)
-		fmt.Printf("To avoid ambiguity, use the collection ID instead.\n")
-	}
+	return collections, nil
+}
 
-	return collections[0], nil
+// containsCollection reports whether a collection with the given ID is in the list
+func containsCollection(collections []*Collection, id string) bool {
+	for _, collection := range collections {
+		if collection.ID == id {
+			return true
+		}
+	}
+	return false
 }
 
 // UpdateCollection updates a collection's name and description
@@ -250,6 +299,11 @@ func (cm *CollectionManagerImpl) UpdateCollection(id string, name *string, descr
 	if name == nil && description == nil {
 		return nil, fmt.Errorf("no fields to update")
 	}
+	if name != nil {
+		if err := cm.checkNameNotAlias(*name); err != nil {
+			return nil, err
+		}
+	}
 
 	// Build dynamic query based on which fields are being updated
 	var query string
@@ -295,6 +349,14 @@ func (cm *CollectionManagerImpl) UpdateCollection(id string, name *string, descr
 		return nil, fmt.Errorf("failed to update collection: %w", err)
 	}
 
+	// Snapshots are named after their collection
+	if name != nil {
+		_, err = cm.db.Exec(`UPDATE collections SET name = $2 || '@' || snapshot_name WHERE snapshot_of = $1`, id, *name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rename snapshots: %w", err)
+		}
+	}
+
 	// Parse stats JSON
 	if err := json.Unmarshal([]byte(statsJSON), &collection.Stats); err != nil {
 		return nil, fmt.Errorf("failed to parse stats: %w", err)
@@ -415,3 +477,136 @@ func (cm *CollectionManagerImpl) RemoveFolderFromCollection(id, folder string) (
 
 	return updatedCollection, nil
 }
+
+// GetBoosts returns the boosting rules stored for a collection
+func (cm *CollectionManagerImpl) GetBoosts(id string) ([]BoostRule, error) {
+	var specs []string
+	err := cm.db.QueryRow(`SELECT boosts FROM collections WHERE id = $1`, id).Scan(pq.Array(&specs))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("collection not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get boosts: %w", err)
+	}
+
+	rules, err := ParseBoostRules(specs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored boosts: %w", err)
+	}
+
+	return rules, nil
+}
+
+// GetRanking returns the ranking tuned for a collection, or nil when it
+// wasn't tuned
+func (cm *CollectionManagerImpl) GetRanking(id string) (*CollectionRanking, error) {
+	var data []byte
+	err := cm.db.QueryRow(`SELECT ranking FROM collections WHERE id = $1`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("collection not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ranking: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	ranking := &CollectionRanking{}
+	if err := json.Unmarshal(data, ranking); err != nil {
+		return nil, fmt.Errorf("failed to parse stored ranking: %w", err)
+	}
+	return ranking, nil
+}
+
+// SetRanking replaces the ranking tuned for a collection, or removes it
+// when ranking is nil
+func (cm *CollectionManagerImpl) SetRanking(id string, ranking *CollectionRanking) error {
+	var data []byte
+	if ranking != nil {
+		var err error
+		if data, err = json.Marshal(ranking); err != nil {
+			return fmt.Errorf("failed to marshal ranking: %w", err)
+		}
+	}
+
+	result, err := cm.db.Exec(`UPDATE collections SET ranking = $2, updated_at = NOW() WHERE id = $1`, id, data)
+	if err != nil {
+		return fmt.Errorf("failed to set ranking: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("collection not found")
+	}
+
+	return nil
+}
+
+// SetBoosts replaces the boosting rules stored for a collection
+func (cm *CollectionManagerImpl) SetBoosts(id string, rules []BoostRule) error {
+	specs := make([]string, len(rules))
+	for i, rule := range rules {
+		specs[i] = rule.String()
+	}
+
+	result, err := cm.db.Exec(`UPDATE collections SET boosts = $2, updated_at = NOW() WHERE id = $1`, id, pq.Array(specs))
+	if err != nil {
+		return fmt.Errorf("failed to set boosts: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("collection not found")
+	}
+
+	return nil
+}
+
+// GetMetadataSchema returns the custom metadata fields of a collection
+func (cm *CollectionManagerImpl) GetMetadataSchema(id string) (MetadataSchema, error) {
+	var specs []string
+	err := cm.db.QueryRow(`SELECT metadata_schema FROM collections WHERE id = $1`, id).Scan(pq.Array(&specs))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("collection not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata schema: %w", err)
+	}
+
+	schema, err := ParseMetadataSchema(specs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored metadata schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// SetMetadataSchema replaces the custom metadata fields of a collection
+func (cm *CollectionManagerImpl) SetMetadataSchema(id string, schema MetadataSchema) error {
+	specs := make([]string, len(schema))
+	for i, field := range schema {
+		specs[i] = field.String()
+	}
+
+	result, err := cm.db.Exec(`UPDATE collections SET metadata_schema = $2, updated_at = NOW() WHERE id = $1`, id, pq.Array(specs))
+	if err != nil {
+		return fmt.Errorf("failed to set metadata schema: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("collection not found")
+	}
+
+	return nil
+}
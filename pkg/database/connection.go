@@ -3,33 +3,153 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/lib/pq"
 )
 
-// NewConnection creates a new database connection with proper configuration
+// Waits between attempts to connect to a database server that is
+// unavailable, doubling from the first to the longest
+const (
+	firstReconnectDelay = 250 * time.Millisecond
+	maxReconnectDelay   = 5 * time.Second
+)
+
+// NewConnection creates a new database connection with proper configuration.
+// Connections the server dropped, e.g. when it restarted, are replaced by the
+// pool, and new connections wait for a restarting server up to the
+// reconnect timeout, so a long-running session outlives a restart.
 func NewConnection(cfg *config.DatabaseConfig) (*sql.DB, error) {
 	dsn := cfg.GetDSN()
 
-	db, err := sql.Open("postgres", dsn)
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db := sql.OpenDB(&reconnector{connector: connector, timeout: cfg.GetReconnectTimeout()})
 
 	// Test the connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.GetMaxOpenConns())
+	db.SetMaxIdleConns(cfg.GetMaxIdleConns())
+	db.SetConnMaxLifetime(cfg.GetConnMaxLifetime())
 
 	return db, nil
 }
+
+// ConnectionProvider hands out a single shared connection pool.
+// The pool is opened on first use and stays open until Close is called,
+// so every manager created during a command (or a long-running session)
+// shares the same connections.
+type ConnectionProvider struct {
+	cfg *config.DatabaseConfig
+
+	mu sync.Mutex
+	db *sql.DB
+}
+
+// NewConnectionProvider creates a new connection provider
+func NewConnectionProvider(cfg *config.DatabaseConfig) *ConnectionProvider {
+	return &ConnectionProvider{cfg: cfg}
+}
+
+// DB returns the shared connection pool, opening it on first use
+func (p *ConnectionProvider) DB() (*sql.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.db != nil {
+		return p.db, nil
+	}
+
+	db, err := NewConnection(p.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p.db = db
+	return db, nil
+}
+
+// Close closes the shared connection pool if it was opened
+func (p *ConnectionProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.db == nil {
+		return nil
+	}
+
+	err := p.db.Close()
+	p.db = nil
+	return err
+}
+
+// reconnector is a driver.Connector that retries connecting while the
+// database server is down or starting up
+type reconnector struct {
+	connector driver.Connector
+	timeout   time.Duration
+}
+
+// Connect opens a connection, retrying until it succeeds, fails for another
+// reason than the server being unavailable, the timeout is over or ctx is done
+func (r *reconnector) Connect(ctx context.Context) (driver.Conn, error) {
+	deadline := time.Now().Add(r.timeout)
+	delay := firstReconnectDelay
+	for {
+		conn, err := r.connector.Connect(ctx)
+		if err == nil || !isUnavailable(err) || time.Now().Add(delay).After(deadline) {
+			return conn, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// Driver returns the driver of the wrapped connector
+func (r *reconnector) Driver() driver.Driver {
+	return r.connector.Driver()
+}
+
+// isUnavailable reports whether a connection error means the server couldn't
+// be reached or isn't accepting connections yet, rather than that it refused
+// the connection, e.g. for a wrong password
+func isUnavailable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		}
+		return false
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, driver.ErrBadConn)
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/embedding"
+)
+
+// ChunkRangeFunc returns the chunks of a document's file with indexes from
+// first to last, ordered by index
+type ChunkRangeFunc func(doc *Document, first, last int) ([]*Document, error)
+
+// ExpandContext joins the n chunks before and after each result's chunk in
+// its file to the result's content, dropping the overlap each chunk repeats
+// from the previous one, so a match comes with the text around it. Chunks
+// already in a better ranked result's content aren't repeated, and a result
+// whose own chunk is already in one is dropped.
+func ExpandContext(results []*SearchResult, n int, chunkRange ChunkRangeFunc) ([]*SearchResult, error) {
+	if n <= 0 {
+		return results, nil
+	}
+
+	type file struct{ collectionID, folder, filePath string }
+	shown := make(map[file]map[int]bool)
+	expanded := make([]*SearchResult, 0, len(results))
+	for _, result := range results {
+		doc := result.Document
+		key := file{doc.CollectionID, doc.Folder, doc.FilePath}
+		if shown[key][doc.ChunkIndex] {
+			continue
+		}
+		if shown[key] == nil {
+			shown[key] = make(map[int]bool)
+		}
+
+		neighbors, err := chunkRange(doc, doc.ChunkIndex-n, doc.ChunkIndex+n)
+		if err != nil {
+			return nil, err
+		}
+		chunks := []*embedding.Chunk{contextChunk(doc)}
+		for _, neighbor := range neighbors {
+			if neighbor.ChunkIndex != doc.ChunkIndex && !shown[key][neighbor.ChunkIndex] {
+				chunks = append(chunks, contextChunk(neighbor))
+			}
+		}
+		sort.Slice(chunks, func(i, j int) bool { return chunks[i].Index < chunks[j].Index })
+		indexes := make([]int, len(chunks))
+		for i, chunk := range chunks {
+			indexes[i] = chunk.Index
+			shown[key][chunk.Index] = true
+		}
+
+		expandedDoc := *doc
+		expandedDoc.Content = embedding.StitchChunks(chunks)
+		expandedDoc.TokenCount = client.EstimateTokens(expandedDoc.Content)
+		expandedResult := *result
+		expandedResult.Document = &expandedDoc
+		expandedResult.ContextChunks = indexes
+		expanded = append(expanded, &expandedResult)
+	}
+	return expanded, nil
+}
+
+// contextChunk returns a document as a chunk to stitch with its neighbors
+func contextChunk(doc *Document) *embedding.Chunk {
+	var metadata map[string]string
+	if doc.Metadata != "" {
+		// Without the overlap in the metadata, it is found from the content
+		_ = json.Unmarshal([]byte(doc.Metadata), &metadata)
+	}
+	return &embedding.Chunk{Content: doc.Content, Index: doc.ChunkIndex, Metadata: metadata}
+}
+
+// chunkRange returns the chunks of a document's file with indexes from first
+// to last, ordered by index
+func (se *SearchEngineImpl) chunkRange(doc *Document, first, last int) ([]*Document, error) {
+	rows, err := se.db.Query(`
+		SELECT chunk_index, content, metadata
+		FROM documents
+		WHERE collection_id = $1 AND COALESCE(folder, '') = $2 AND file_path = $3 AND chunk_index BETWEEN $4 AND $5
+		ORDER BY chunk_index`,
+		doc.CollectionID, doc.Folder, doc.FilePath, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query neighboring chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []*Document
+	for rows.Next() {
+		chunk := &Document{CollectionID: doc.CollectionID, Folder: doc.Folder, FilePath: doc.FilePath}
+		if err := rows.Scan(&chunk.ChunkIndex, &chunk.Content, &chunk.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to scan neighboring chunk: %w", err)
+		}
+		if err := decryptContent(&chunk.Content); err != nil {
+			return nil, fmt.Errorf("failed to read chunk %d of %s: %w", chunk.ChunkIndex, doc.FilePath, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read neighboring chunks: %w", err)
+	}
+	return chunks, nil
+}
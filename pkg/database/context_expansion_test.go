@@ -0,0 +1,67 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandContext(t *testing.T) {
+	chunk := func(filePath string, index int, content, metadata string) *Document {
+		return &Document{CollectionID: "c", Folder: "/docs", FilePath: filePath, ChunkIndex: index, Content: content, Metadata: metadata}
+	}
+	files := map[string][]*Document{
+		"guide.md": {
+			chunk("guide.md", 0, "Install the tool.", ""),
+			chunk("guide.md", 1, "the tool. Configure the database.", `{"overlap": "9"}`),
+			chunk("guide.md", 2, "Run the first index.", ""),
+			chunk("guide.md", 3, "Search the collection.", ""),
+			chunk("guide.md", 4, "Chat with the collection.", ""),
+		},
+		"faq.md": {
+			chunk("faq.md", 0, "Why is search slow?", ""),
+		},
+	}
+	var requested [][2]int
+	chunkRange := func(doc *Document, first, last int) ([]*Document, error) {
+		requested = append(requested, [2]int{first, last})
+		var chunks []*Document
+		for _, c := range files[doc.FilePath] {
+			if c.ChunkIndex >= first && c.ChunkIndex <= last {
+				chunks = append(chunks, c)
+			}
+		}
+		return chunks, nil
+	}
+
+	results := []*SearchResult{
+		{Document: files["guide.md"][1], CombinedScore: 0.9, Rank: 1},
+		{Document: files["faq.md"][0], CombinedScore: 0.8, Rank: 2},
+		{Document: files["guide.md"][2], CombinedScore: 0.7, Rank: 3}, // Already joined to the first result
+		{Document: files["guide.md"][4], CombinedScore: 0.6, Rank: 4},
+	}
+
+	expanded, err := ExpandContext(results, 1, chunkRange)
+	require.NoError(t, err)
+	require.Len(t, expanded, 3)
+
+	// The overlap chunk 1 repeats from chunk 0 is dropped
+	assert.Equal(t, "Install the tool. Configure the database.Run the first index.", expanded[0].Document.Content)
+	assert.Equal(t, []int{0, 1, 2}, expanded[0].ContextChunks)
+	assert.Equal(t, 0.9, expanded[0].CombinedScore)
+	assert.Equal(t, "Why is search slow?", expanded[1].Document.Content)
+	assert.Equal(t, []int{0}, expanded[1].ContextChunks)
+	// Chunk 3 is the last result's neighbor; chunk 5 doesn't exist
+	assert.Equal(t, "Search the collection.Chat with the collection.", expanded[2].Document.Content)
+	assert.Equal(t, []int{3, 4}, expanded[2].ContextChunks)
+	assert.Equal(t, [][2]int{{0, 2}, {-1, 1}, {3, 5}}, requested)
+
+	// The results themselves are left as they were
+	assert.Equal(t, "the tool. Configure the database.", results[0].Document.Content)
+	assert.Empty(t, results[0].ContextChunks)
+
+	unchanged, err := ExpandContext(results, 0, chunkRange)
+	require.NoError(t, err)
+	assert.Equal(t, results, unchanged)
+}
@@ -1,10 +1,8 @@
 package database
 
 import (
-	"context"
 	"database/sql"
 	"fmt"
-	"time"
 
 	"github.com/busybytelab.com/rag-cli/pkg/config"
 )
@@ -12,34 +10,37 @@ import (
 // DatabaseManagerImpl implements DatabaseManager interface
 type DatabaseManagerImpl struct {
 	db               *sql.DB
+	ownsDB           bool
 	migrationManager *MigrationManager
 }
 
-// NewDatabaseManager creates a new database manager with all three components
+// NewDatabaseManager creates a new database manager with its own connection pool
 func NewDatabaseManager(cfg *config.DatabaseConfig) (DatabaseManager, error) {
-	dsn := cfg.GetDSN()
-
-	db, err := sql.Open("postgres", dsn)
+	db, err := NewConnection(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	// Test the connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	databaseManager, err := newDatabaseManager(db, true)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	return databaseManager, nil
+}
+
+// NewDatabaseManagerWithDB creates a new database manager on an existing
+// connection pool. Closing the manager leaves the pool open for its owner.
+func NewDatabaseManagerWithDB(db *sql.DB) (DatabaseManager, error) {
+	return newDatabaseManager(db, false)
+}
 
-	// Create the main database manager
+// newDatabaseManager creates the database manager and initializes the schema
+func newDatabaseManager(db *sql.DB, ownsDB bool) (*DatabaseManagerImpl, error) {
 	databaseManager := &DatabaseManagerImpl{
 		db:               db,
+		ownsDB:           ownsDB,
 		migrationManager: NewMigrationManager(db),
 	}
 
@@ -51,8 +52,11 @@ func NewDatabaseManager(cfg *config.DatabaseConfig) (DatabaseManager, error) {
 	return databaseManager, nil
 }
 
-// Close closes the database connection
+// Close closes the database connection if the manager owns it
 func (dm *DatabaseManagerImpl) Close() error {
+	if !dm.ownsDB {
+		return nil
+	}
 	return dm.db.Close()
 }
 
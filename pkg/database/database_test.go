@@ -1,9 +1,15 @@
 package database
 
 import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -56,6 +62,24 @@ func TestSearchTypeConstants(t *testing.T) {
 	assert.Equal(t, SearchType("semantic"), SearchTypeSemantic, "SearchTypeSemantic should be 'semantic'")
 }
 
+func TestParseSearchType(t *testing.T) {
+	searchType, err := ParseSearchType(" Fusion ")
+	assert.NoError(t, err)
+	assert.Equal(t, SearchTypeFusion, searchType)
+
+	_, err = ParseSearchType("magic")
+	assert.Error(t, err)
+}
+
+func TestSearchTypeUsesText(t *testing.T) {
+	for _, searchType := range []SearchType{SearchTypeText, SearchTypeHybrid, SearchTypeBM25, SearchTypeFusion} {
+		assert.True(t, searchType.UsesText(), searchType)
+	}
+	for _, searchType := range []SearchType{SearchTypeVector, SearchTypeSemantic} {
+		assert.False(t, searchType.UsesText(), searchType)
+	}
+}
+
 func TestSearchOptionsDefaults(t *testing.T) {
 	opts := &SearchOptions{}
 
@@ -149,3 +173,109 @@ func TestSearchResultStruct(t *testing.T) {
 	assert.Equal(t, 0.7, result.CombinedScore, "Combined score should match")
 	assert.Equal(t, 1, result.Rank, "Rank should match")
 }
+
+func TestParseIndexType(t *testing.T) {
+	indexType, err := ParseIndexType("DiskANN")
+	require.NoError(t, err)
+	assert.Equal(t, IndexTypeDiskANN, indexType)
+
+	indexType, err = ParseIndexType("hnsw")
+	require.NoError(t, err)
+	assert.Equal(t, IndexTypeHNSW, indexType)
+
+	_, err = ParseIndexType("ivfflat")
+	assert.Error(t, err)
+}
+
+func TestVectorIndexName(t *testing.T) {
+	name := vectorIndexName("550E8400-e29b-41d4-a716-446655440000")
+	assert.Equal(t, "idx_documents_embedding_550e8400e29b41d4a716446655440000", name)
+	assert.LessOrEqual(t, len(name), 63, "index name must fit in a PostgreSQL identifier")
+}
+
+func TestRankRoutes(t *testing.T) {
+	routes := []*CollectionRoute{
+		{Collection: &Collection{Name: "recipes"}, CentroidScore: 0.40, DescriptionScore: 0.30, hasCentroid: true, hasDescription: true},
+		{Collection: &Collection{Name: "ops"}, CentroidScore: 0.55, DescriptionScore: 0.40, hasCentroid: true, hasDescription: true},
+		{Collection: &Collection{Name: "dba"}, CentroidScore: 0.50, DescriptionScore: 0.70, KeywordScore: 0.5, hasCentroid: true, hasDescription: true},
+		{Collection: &Collection{Name: "new"}, DescriptionScore: 0.50, hasDescription: true},
+	}
+
+	ranked := rankRoutes(routes, 3)
+	require.Len(t, ranked, 3)
+
+	// A matching description outweighs a slightly closer centroid
+	assert.Equal(t, "dba", ranked[0].Collection.Name)
+	assert.InDelta(t, 0.6*0.50+0.3*0.70+0.1*0.5, ranked[0].Score, 1e-9)
+
+	assert.Equal(t, "ops", ranked[1].Collection.Name)
+
+	// A collection that is not indexed yet is scored on its description alone
+	assert.Equal(t, "new", ranked[2].Collection.Name)
+	assert.InDelta(t, 0.3*0.50/0.4, ranked[2].Score, 1e-9)
+}
+
+func TestCollectionDescriptionText(t *testing.T) {
+	assert.Equal(t, "docs", CollectionDescriptionText(&Collection{Name: "docs"}))
+	assert.Equal(t, "docs\nProduct manuals", CollectionDescriptionText(&Collection{Name: "docs", Description: "Product manuals"}))
+}
+
+func TestAmbiguousCollectionError(t *testing.T) {
+	err := &AmbiguousCollectionError{
+		Reference: "docs",
+		Candidates: []*Collection{
+			{ID: "550e8400-e29b-41d4-a716-446655440000", Name: "docs"},
+			{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8", Name: "prod-docs"},
+		},
+	}
+
+	assert.Equal(t, `"docs" matches 2 collections, use the collection ID instead:
+  550e8400-e29b-41d4-a716-446655440000  docs
+  6ba7b810-9dad-11d1-80b4-00c04fd430c8  prod-docs`, err.Error())
+}
+
+// flakyConnector fails to connect with err until it was called failures times
+type flakyConnector struct {
+	err      error
+	failures int
+	calls    int
+}
+
+func (c *flakyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.calls++
+	if c.calls <= c.failures {
+		return nil, c.err
+	}
+	return nil, nil
+}
+
+func (c *flakyConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestReconnector(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	// A restarting server is waited for
+	connector := &flakyConnector{err: refused, failures: 2}
+	_, err := (&reconnector{connector: connector, timeout: 5 * time.Second}).Connect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, connector.calls)
+
+	connector = &flakyConnector{err: &pq.Error{Code: "57P03", Message: "the database system is starting up"}, failures: 1}
+	_, err = (&reconnector{connector: connector, timeout: 5 * time.Second}).Connect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, connector.calls)
+
+	// Other errors, like a wrong password, fail at once
+	connector = &flakyConnector{err: &pq.Error{Code: "28P01", Message: "password authentication failed"}, failures: 1}
+	_, err = (&reconnector{connector: connector, timeout: 5 * time.Second}).Connect(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 1, connector.calls)
+
+	// The server isn't waited for beyond the timeout
+	connector = &flakyConnector{err: refused, failures: 100}
+	_, err = (&reconnector{connector: connector, timeout: 0}).Connect(context.Background())
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	assert.Equal(t, 1, connector.calls)
+}
@@ -0,0 +1,322 @@
+package databasetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+// SearchDocuments returns the chunks most similar to the embedding
+func (s *Store) SearchDocuments(collectionID string, embedding []float32, limit int) ([]*database.Document, error) {
+	opts := &database.SearchOptions{
+		SearchType:   database.SearchTypeVector,
+		VectorWeight: 1.0,
+		MaxDistance:  1.0,
+	}
+
+	results, err := s.SearchDocumentsWithOptions(collectionID, embedding, "", limit, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]*database.Document, len(results))
+	for i, result := range results {
+		documents[i] = result.Document
+	}
+	return documents, nil
+}
+
+// SearchDocumentsWithOptions searches a collection's chunks in memory. The
+// vector score is the cosine similarity to the embedding and the text score
+// is the share of the query's words a chunk contains, so text and BM25
+// searches rank the same. Hybrid and semantic searches weight the two scores
+// and fusion searches fuse both rankings with database.FuseRankings.
+//
+// The file, file type, content and metadata filters, the minimum score, the
+// maximum distance, the context expansion and the token budget are applied. Boosts, query variants,
+// path similarity, score normalization and reranking are ignored.
+func (s *Store) SearchDocumentsWithOptions(collectionID string, embedding []float32, textQuery string, limit int, opts *database.SearchOptions) ([]*database.SearchResult, error) {
+	if opts == nil {
+		opts = &database.SearchOptions{
+			SearchType:   database.SearchTypeHybrid,
+			VectorWeight: 0.7,
+			TextWeight:   0.3,
+			MaxDistance:  1.0,
+		}
+	}
+
+	searchType := opts.SearchType
+	if searchType == "" {
+		searchType = database.SearchTypeHybrid
+	}
+	hasText := strings.TrimSpace(textQuery) != ""
+	switch {
+	case (searchType == database.SearchTypeVector || searchType == database.SearchTypeSemantic) && embedding == nil:
+		return nil, fmt.Errorf("embedding is required for %s search", searchType)
+	case !searchType.UsesEmbedding() && !hasText:
+		return nil, fmt.Errorf("text query is required for %s search", searchType)
+	case embedding == nil && !hasText:
+		return nil, fmt.Errorf("embedding or text query is required for %s search", searchType)
+	}
+
+	candidates, err := s.candidates(collectionID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*database.SearchResult
+	switch searchType {
+	case database.SearchTypeVector:
+		results = vectorResults(candidates, embedding, opts.MaxDistance)
+	case database.SearchTypeText, database.SearchTypeBM25:
+		results = textResults(candidates, textQuery)
+	case database.SearchTypeFusion:
+		rankings := [][]*database.SearchResult{vectorResults(candidates, embedding, opts.MaxDistance), textResults(candidates, textQuery)}
+		results = database.FuseRankings(rankings, []float64{1, 1}, len(candidates))
+	default:
+		results = hybridResults(candidates, embedding, textQuery, opts)
+	}
+
+	results = s.processing.FilterSearchResults(results, opts.MinScore)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	for i, result := range results {
+		result.Rank = i + 1
+	}
+
+	if opts.ContextChunks > 0 {
+		results, err = database.ExpandContext(results, opts.ContextChunks, s.chunkRange)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.MaxTokens > 0 {
+		results = database.FitTokenBudget(results, opts.MaxTokens)
+	}
+	return results, nil
+}
+
+// RankSearchResults ranks search results by combined score
+func (s *Store) RankSearchResults(results []*database.SearchResult) []*database.SearchResult {
+	return s.processing.RankSearchResults(results)
+}
+
+// FilterSearchResults keeps the search results scoring at least minScore
+func (s *Store) FilterSearchResults(results []*database.SearchResult, minScore float64) []*database.SearchResult {
+	return s.processing.FilterSearchResults(results, minScore)
+}
+
+// GetSearchStats returns statistics about the search results
+func (s *Store) GetSearchStats(results []*database.SearchResult) map[string]interface{} {
+	return s.processing.GetSearchStats(results)
+}
+
+// candidates returns copies of the collection's chunks that pass the
+// search's filters
+func (s *Store) candidates(collectionID string, opts *database.SearchOptions) ([]*database.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nameFilter := globMatcher(opts.FileFilter, true)
+	fileTypes := make(map[string]bool)
+	for _, fileType := range opts.FileTypes {
+		fileTypes[fileType] = true
+	}
+
+	var candidates []*database.Document
+	for _, doc := range s.documents {
+		if doc.CollectionID != collectionID || !nameFilter(doc.FileName) {
+			continue
+		}
+		if len(fileTypes) > 0 && !fileTypes[database.FileType(doc.FileName)] {
+			continue
+		}
+		if opts.ContentFilter != "" && !strings.Contains(strings.ToLower(doc.Content), strings.ToLower(opts.ContentFilter)) {
+			continue
+		}
+		if len(opts.Where) > 0 || len(opts.Metadata) > 0 {
+			values, err := metadataValues(doc.Metadata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read metadata of document %s: %w", doc.ID, err)
+			}
+			if !matchesAll(opts.Where, values) || !containsAll(opts.Metadata, values) {
+				continue
+			}
+		}
+		candidates = append(candidates, copyDocument(doc))
+	}
+	return candidates, nil
+}
+
+// chunkRange returns the chunks of a document's file with indexes from
+// first to last, ordered by index
+func (s *Store) chunkRange(doc *database.Document, first, last int) ([]*database.Document, error) {
+	docs, err := s.ListDocumentsByFile(doc.CollectionID, doc.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []*database.Document
+	for _, chunk := range docs {
+		if chunk.Folder == doc.Folder && chunk.ChunkIndex >= first && chunk.ChunkIndex <= last {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, nil
+}
+
+// vectorResults scores the chunks by cosine similarity, leaving out those
+// further than maxDistance
+func vectorResults(docs []*database.Document, embedding []float32, maxDistance float64) []*database.SearchResult {
+	if embedding == nil {
+		return nil
+	}
+
+	var results []*database.SearchResult
+	for _, doc := range docs {
+		score := cosineSimilarity(embedding, doc.Embedding)
+		if maxDistance > 0 && 1-score > maxDistance {
+			continue
+		}
+		results = append(results, &database.SearchResult{Document: doc, VectorScore: score, CombinedScore: score})
+	}
+	return sortResults(results)
+}
+
+// textResults scores the chunks by the share of the query's words they
+// contain, leaving out those without any
+func textResults(docs []*database.Document, textQuery string) []*database.SearchResult {
+	var results []*database.SearchResult
+	for _, doc := range docs {
+		score := wordOverlap(textQuery, doc.Content)
+		if score == 0 {
+			continue
+		}
+		results = append(results, &database.SearchResult{Document: doc, TextScore: score, CombinedScore: score})
+	}
+	return sortResults(results)
+}
+
+// hybridResults weights the vector and text scores of the chunks
+func hybridResults(docs []*database.Document, embedding []float32, textQuery string, opts *database.SearchOptions) []*database.SearchResult {
+	vectorWeight, textWeight := opts.VectorWeight, opts.TextWeight
+	if vectorWeight+textWeight == 0 {
+		vectorWeight, textWeight = 0.7, 0.3
+	}
+	total := vectorWeight + textWeight
+
+	var results []*database.SearchResult
+	for _, doc := range docs {
+		result := &database.SearchResult{Document: doc}
+		if embedding != nil {
+			result.VectorScore = cosineSimilarity(embedding, doc.Embedding)
+			if opts.MaxDistance > 0 && 1-result.VectorScore > opts.MaxDistance {
+				continue
+			}
+		}
+		if textQuery != "" {
+			result.TextScore = wordOverlap(textQuery, doc.Content)
+		}
+		result.CombinedScore = (vectorWeight*result.VectorScore + textWeight*result.TextScore) / total
+		results = append(results, result)
+	}
+	return sortResults(results)
+}
+
+// sortResults orders results by combined score, keeping the order of chunks
+// with the same score
+func sortResults(results []*database.SearchResult) []*database.SearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].CombinedScore > results[j].CombinedScore
+	})
+	return results
+}
+
+// metadataValues returns a chunk's metadata values as text
+func metadataValues(metadata string) (map[string]string, error) {
+	if metadata == "" {
+		return nil, nil
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(metadata), &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if text, ok := value.(string); ok {
+			values[key] = text
+		} else {
+			encoded, _ := json.Marshal(value)
+			values[key] = string(encoded)
+		}
+	}
+	return values, nil
+}
+
+// matchesAll reports whether metadata values satisfy every filter
+func matchesAll(filters []database.MetadataFilter, values map[string]string) bool {
+	for _, filter := range filters {
+		if !filter.Matches(values) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAll reports whether metadata values contain every key-value pair
+func containsAll(match, values map[string]string) bool {
+	for key, value := range match {
+		if actual, ok := values[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors, 0 when
+// either is empty or their sizes differ
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	normA, normB := norm(a), norm(b)
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (normA * normB)
+}
+
+// wordOverlap returns the share of the query's distinct words, lowercased,
+// that the text contains
+func wordOverlap(query, text string) float64 {
+	queryWords := make(map[string]bool)
+	for _, word := range words(query) {
+		queryWords[word] = true
+	}
+	if len(queryWords) == 0 {
+		return 0
+	}
+
+	found := make(map[string]bool)
+	for _, word := range words(text) {
+		if queryWords[word] {
+			found[word] = true
+		}
+	}
+	return float64(len(found)) / float64(len(queryWords))
+}
+
+// words splits a text into its lowercased words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
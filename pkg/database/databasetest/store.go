@@ -0,0 +1,1012 @@
+// Package databasetest provides an in-memory implementation of the database
+// managers for tests that shouldn't need a PostgreSQL server.
+package databasetest
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+// Store keeps collections, their documents and file states in memory. It
+// implements the collection, document and file state managers, the search
+// engine (see SearchDocumentsWithOptions) and the database manager, all on
+// the same data, so deleting a collection deletes its documents like the
+// database does. It is safe for concurrent use.
+type Store struct {
+	mu          sync.Mutex
+	nextID      int
+	collections map[string]*collection
+	documents   []*database.Document
+	fileStates  map[string]*database.FileState // By collection ID, folder and path
+	aliases     map[string]string              // Collection ID by alias
+	dimensions  map[string]int
+	models      map[string]string // Embedding model by collection ID
+
+	// processing handles the search result methods that don't query the
+	// database, so they behave exactly like the real search engine's
+	processing database.SearchEngine
+}
+
+// collection is a stored collection with the settings kept alongside it
+type collection struct {
+	database.Collection
+	indexType  database.IndexType
+	normalized bool
+	boosts     []database.BoostRule
+	schema     database.MetadataSchema
+	ranking    *database.CollectionRanking
+}
+
+var (
+	_ database.CollectionManager = (*Store)(nil)
+	_ database.DocumentManager   = (*Store)(nil)
+	_ database.SearchEngine      = (*Store)(nil)
+	_ database.FileStateManager  = (*Store)(nil)
+	_ database.DatabaseManager   = (*Store)(nil)
+)
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		collections: make(map[string]*collection),
+		fileStates:  make(map[string]*database.FileState),
+		aliases:     make(map[string]string),
+		dimensions:  make(map[string]int),
+		models:      make(map[string]string),
+		processing:  database.NewSearchEngine(nil),
+	}
+}
+
+// newID returns a new UUID, unique within the store
+func (s *Store) newID() string {
+	s.nextID++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", s.nextID)
+}
+
+// CreateCollection creates a collection
+func (s *Store) CreateCollection(name, description string, folders []string) (*database.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.aliases[name]; ok {
+		return nil, fmt.Errorf("name %q is already an alias of a collection", name)
+	}
+
+	now := time.Now()
+	c := &collection{
+		Collection: database.Collection{
+			ID:          s.newID(),
+			Name:        name,
+			Description: description,
+			Folders:     append([]string(nil), folders...),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		indexType: database.IndexTypeHNSW,
+	}
+	s.collections[c.ID] = c
+	return copyCollection(c), nil
+}
+
+// GetCollection returns a collection by ID
+func (s *Store) GetCollection(id string) (*database.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", database.ErrCollectionNotFound, id)
+	}
+	return copyCollection(c), nil
+}
+
+// GetCollectionByIdOrName returns a collection by ID, name or alias. It
+// returns an AmbiguousCollectionError when a name or alias matches more than
+// one collection.
+func (s *Store) GetCollectionByIdOrName(collectionIdOrName string) (*database.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.collections[collectionIdOrName]; ok {
+		return copyCollection(c), nil
+	}
+
+	var matches []*database.Collection
+	for _, c := range s.sortedCollections() {
+		if c.Name == collectionIdOrName || s.aliases[collectionIdOrName] == c.ID {
+			matches = append(matches, copyCollection(c))
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", database.ErrCollectionNotFound, collectionIdOrName)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, &database.AmbiguousCollectionError{Reference: collectionIdOrName, Candidates: matches}
+	}
+}
+
+// ListCollections returns the collections, newest first
+func (s *Store) ListCollections() ([]*database.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var collections []*database.Collection
+	for _, c := range s.sortedCollections() {
+		collections = append(collections, copyCollection(c))
+	}
+	return collections, nil
+}
+
+// sortedCollections returns the collections, newest first
+func (s *Store) sortedCollections() []*collection {
+	collections := make([]*collection, 0, len(s.collections))
+	for _, c := range s.collections {
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		if !collections[i].CreatedAt.Equal(collections[j].CreatedAt) {
+			return collections[i].CreatedAt.After(collections[j].CreatedAt)
+		}
+		return collections[i].ID > collections[j].ID
+	})
+	return collections
+}
+
+// DeleteCollection deletes a collection with its documents, file states and aliases
+func (s *Store) DeleteCollection(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[id]; !ok {
+		return fmt.Errorf("collection with ID '%s' not found", id)
+	}
+	delete(s.collections, id)
+	delete(s.dimensions, id)
+	s.deleteDocuments(func(doc *database.Document) bool { return doc.CollectionID == id })
+	for key := range s.fileStates {
+		if strings.HasPrefix(key, id+"\x00") {
+			delete(s.fileStates, key)
+		}
+	}
+	for alias, collectionID := range s.aliases {
+		if collectionID == id {
+			delete(s.aliases, alias)
+		}
+	}
+	return nil
+}
+
+// UpdateCollectionStats counts the documents, chunks and content size of a collection
+func (s *Store) UpdateCollectionStats(collectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[collectionID]
+	if !ok {
+		return fmt.Errorf("collection not found")
+	}
+
+	stats := database.Stats{}
+	files := make(map[string]bool)
+	for _, doc := range s.documents {
+		if doc.CollectionID != collectionID {
+			continue
+		}
+		files[doc.Folder+"\x00"+doc.FilePath] = true
+		stats.TotalChunks++
+		stats.TotalSize += int64(len(doc.Content))
+	}
+	stats.TotalDocuments = len(files)
+	c.Stats = stats
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateCollection updates a collection's name and description
+func (s *Store) UpdateCollection(id string, name *string, description *string) (*database.Collection, error) {
+	if name == nil && description == nil {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to update collection: %w", database.ErrCollectionNotFound)
+	}
+	if name != nil {
+		if _, ok := s.aliases[*name]; ok {
+			return nil, fmt.Errorf("name %q is already an alias of a collection", *name)
+		}
+		c.Name = *name
+	}
+	if description != nil {
+		c.Description = *description
+	}
+	c.UpdatedAt = time.Now()
+	return copyCollection(c), nil
+}
+
+// AddFolderToCollection adds a folder to a collection
+func (s *Store) AddFolderToCollection(id, folder string) (*database.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get collection: %w: %s", database.ErrCollectionNotFound, id)
+	}
+	for _, existing := range c.Folders {
+		if existing == folder {
+			return nil, fmt.Errorf("folder '%s' already exists in collection", folder)
+		}
+	}
+	c.Folders = append(c.Folders, folder)
+	c.UpdatedAt = time.Now()
+	return copyCollection(c), nil
+}
+
+// RemoveFolderFromCollection removes a folder from a collection and deletes its documents
+func (s *Store) RemoveFolderFromCollection(id, folder string) (*database.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get collection: %w: %s", database.ErrCollectionNotFound, id)
+	}
+
+	var folders []string
+	for _, existing := range c.Folders {
+		if existing != folder {
+			folders = append(folders, existing)
+		}
+	}
+	if len(folders) == len(c.Folders) {
+		return nil, fmt.Errorf("folder '%s' does not exist in collection", folder)
+	}
+
+	s.deleteDocuments(func(doc *database.Document) bool { return doc.CollectionID == id && doc.Folder == folder })
+	c.Folders = folders
+	c.UpdatedAt = time.Now()
+	return copyCollection(c), nil
+}
+
+// GetIndexType returns the vector index type of a collection
+func (s *Store) GetIndexType(id string) (database.IndexType, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return "", fmt.Errorf("collection not found")
+	}
+	return c.indexType, nil
+}
+
+// SetIndexType sets the vector index type of a collection
+func (s *Store) SetIndexType(id string, indexType database.IndexType) error {
+	if _, err := database.ParseIndexType(string(indexType)); err != nil {
+		return err
+	}
+	return s.updateCollection(id, func(c *collection) { c.indexType = indexType })
+}
+
+// GetNormalized reports whether a collection's embeddings are flagged as normalized
+func (s *Store) GetNormalized(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return false, fmt.Errorf("collection not found")
+	}
+	return c.normalized, nil
+}
+
+// SetNormalized flags a collection's embeddings as normalized, or clears the flag
+func (s *Store) SetNormalized(id string, normalized bool) error {
+	return s.updateCollection(id, func(c *collection) { c.normalized = normalized })
+}
+
+// MaxEmbeddingNormDeviation returns how far the norm of up to sample of a
+// collection's embeddings is from 1 at most
+func (s *Store) MaxEmbeddingNormDeviation(id string, sample int) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deviation := 0.0
+	for _, doc := range s.documents {
+		if sample <= 0 {
+			break
+		}
+		if doc.CollectionID != id || len(doc.Embedding) == 0 {
+			continue
+		}
+		sample--
+		deviation = math.Max(deviation, math.Abs(norm(doc.Embedding)-1))
+	}
+	return deviation, nil
+}
+
+// GetBoosts returns the boosting rules of a collection
+func (s *Store) GetBoosts(id string) ([]database.BoostRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection not found")
+	}
+	return append([]database.BoostRule(nil), c.boosts...), nil
+}
+
+// SetBoosts replaces the boosting rules of a collection
+func (s *Store) SetBoosts(id string, rules []database.BoostRule) error {
+	return s.updateCollection(id, func(c *collection) { c.boosts = append([]database.BoostRule(nil), rules...) })
+}
+
+// GetRanking returns the ranking tuned for a collection, or nil
+func (s *Store) GetRanking(id string) (*database.CollectionRanking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection not found")
+	}
+	if c.ranking == nil {
+		return nil, nil
+	}
+	ranking := *c.ranking
+	return &ranking, nil
+}
+
+// SetRanking replaces the ranking tuned for a collection
+func (s *Store) SetRanking(id string, ranking *database.CollectionRanking) error {
+	return s.updateCollection(id, func(c *collection) {
+		c.ranking = nil
+		if ranking != nil {
+			copied := *ranking
+			c.ranking = &copied
+		}
+	})
+}
+
+// GetMetadataSchema returns the custom metadata fields of a collection
+func (s *Store) GetMetadataSchema(id string) (database.MetadataSchema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection not found")
+	}
+	return append(database.MetadataSchema(nil), c.schema...), nil
+}
+
+// SetMetadataSchema replaces the custom metadata fields of a collection
+func (s *Store) SetMetadataSchema(id string, schema database.MetadataSchema) error {
+	return s.updateCollection(id, func(c *collection) { c.schema = append(database.MetadataSchema(nil), schema...) })
+}
+
+// updateCollection changes a collection's settings
+func (s *Store) updateCollection(id string, update func(c *collection)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok {
+		return fmt.Errorf("collection not found")
+	}
+	update(c)
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// AddAlias adds an alternative name for a collection
+func (s *Store) AddAlias(id, alias string) error {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.collections {
+		if c.Name == alias {
+			return fmt.Errorf("alias %q is already the name of a collection", alias)
+		}
+	}
+	if _, ok := s.aliases[alias]; ok {
+		return fmt.Errorf("alias %q is already in use", alias)
+	}
+	if _, ok := s.collections[id]; !ok {
+		return fmt.Errorf("failed to add alias: %w", database.ErrCollectionNotFound)
+	}
+	s.aliases[alias] = id
+	return nil
+}
+
+// RemoveAlias removes an alias
+func (s *Store) RemoveAlias(alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.aliases[alias]; !ok {
+		return fmt.Errorf("alias not found: %s", alias)
+	}
+	delete(s.aliases, alias)
+	return nil
+}
+
+// ListAliases returns the aliases of a collection, sorted
+func (s *Store) ListAliases(id string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var aliases []string
+	for alias, collectionID := range s.aliases {
+		if collectionID == id {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
+
+// InsertDocument stores a document, setting its ID and times
+func (s *Store) InsertDocument(doc *database.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insertDocument(doc)
+}
+
+// insertDocument stores a document with the lock held
+func (s *Store) insertDocument(doc *database.Document) error {
+	if _, ok := s.collections[doc.CollectionID]; !ok {
+		return fmt.Errorf("failed to insert document: %w: %s", database.ErrCollectionNotFound, doc.CollectionID)
+	}
+
+	doc.ID = s.newID()
+	now := time.Now()
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = now
+	}
+	doc.UpdatedAt = now
+	hash, err := database.KeyedContentHash([]byte(doc.Content))
+	if err != nil {
+		return fmt.Errorf("failed to insert document: %w", err)
+	}
+	doc.ContentHash = hash
+	s.documents = append(s.documents, copyDocument(doc))
+	return nil
+}
+
+// UpsertDocument stores a document, replacing the chunk with the same index
+// of the same file
+func (s *Store) UpsertDocument(doc *database.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, stored := range s.documents {
+		if stored.CollectionID == doc.CollectionID && stored.Folder == doc.Folder && stored.FilePath == doc.FilePath && stored.ChunkIndex == doc.ChunkIndex {
+			doc.ID = stored.ID
+			if doc.CreatedAt.IsZero() {
+				doc.CreatedAt = stored.CreatedAt
+			}
+			hash, err := database.KeyedContentHash([]byte(doc.Content))
+			if err != nil {
+				return fmt.Errorf("failed to insert document: %w", err)
+			}
+			doc.UpdatedAt = time.Now()
+			doc.ContentHash = hash
+			s.documents[i] = copyDocument(doc)
+			return nil
+		}
+	}
+	return s.insertDocument(doc)
+}
+
+// DeleteDocumentsByPath deletes the chunks of a file
+func (s *Store) DeleteDocumentsByPath(collectionID, folder, filePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteDocuments(func(doc *database.Document) bool {
+		return doc.CollectionID == collectionID && doc.Folder == folder && doc.FilePath == filePath
+	})
+	return nil
+}
+
+// PruneDocumentsByPath deletes the chunks of a file other than those with the
+// given indexes
+func (s *Store) PruneDocumentsByPath(collectionID, folder, filePath string, chunkIndexes []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteDocuments(func(doc *database.Document) bool {
+		return doc.CollectionID == collectionID && doc.Folder == folder && doc.FilePath == filePath && !slices.Contains(chunkIndexes, doc.ChunkIndex)
+	})
+	return nil
+}
+
+// DeleteDocumentsByFolder deletes the chunks of every file of a collection folder
+func (s *Store) DeleteDocumentsByFolder(collectionID, folder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteDocuments(func(doc *database.Document) bool {
+		return doc.CollectionID == collectionID && doc.Folder == folder
+	})
+	return nil
+}
+
+// DeleteDocumentsByPattern deletes the chunks of every file whose path
+// matches a path glob (see database.PathGlobToRegex) and returns the files.
+// With dryRun nothing is deleted.
+func (s *Store) DeleteDocumentsByPattern(collectionID, pattern string, dryRun bool) ([]*database.IndexedFile, error) {
+	regex, err := regexp.Compile(database.PathGlobToRegex(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := func(doc *database.Document) bool {
+		return doc.CollectionID == collectionID && regex.MatchString(doc.FilePath)
+	}
+	files := s.files(matches)
+	if !dryRun {
+		s.deleteDocuments(matches)
+	}
+	return files, nil
+}
+
+// DeleteDocumentByID deletes a chunk
+func (s *Store) DeleteDocumentByID(documentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deleteDocuments(func(doc *database.Document) bool { return doc.ID == documentID }) == 0 {
+		return fmt.Errorf("document with ID '%s' not found", documentID)
+	}
+	return nil
+}
+
+// ListDocumentsByFolder lists one page of the chunks in a folder scope
+func (s *Store) ListDocumentsByFolder(collectionID string, scope database.FolderScope, limit, offset int) (*database.DocumentPage, error) {
+	return s.ListDocumentsByFolderWithFilter(collectionID, scope, "", limit, offset)
+}
+
+// ListDocumentsByFolderWithFilter lists one page of the chunks in a folder
+// scope whose file name matches a glob (see database.GlobToLike)
+func (s *Store) ListDocumentsByFolderWithFilter(collectionID string, scope database.FolderScope, fileFilter string, limit, offset int) (*database.DocumentPage, error) {
+	nameFilter := globMatcher(fileFilter, false)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var docs []*database.Document
+	for _, doc := range s.documents {
+		if doc.CollectionID == collectionID && inScope(doc, scope) && nameFilter(doc.FileName) {
+			docs = append(docs, doc)
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].FilePath != docs[j].FilePath {
+			return docs[i].FilePath < docs[j].FilePath
+		}
+		return docs[i].ChunkIndex < docs[j].ChunkIndex
+	})
+
+	page := &database.DocumentPage{Total: len(docs), Limit: limit, Offset: offset}
+	for i := offset; i < len(docs) && i < offset+limit; i++ {
+		page.Documents = append(page.Documents, copyDocument(docs[i]))
+	}
+	return page, nil
+}
+
+// GetDocumentByID returns a chunk by ID
+func (s *Store) GetDocumentByID(documentID string) (*database.Document, error) {
+	return s.findDocument(func(doc *database.Document) bool { return doc.ID == documentID })
+}
+
+// GetDocumentByPathAndIndex returns a chunk of a file
+func (s *Store) GetDocumentByPathAndIndex(collectionID, folder, filePath string, chunkIndex int) (*database.Document, error) {
+	return s.findDocument(func(doc *database.Document) bool {
+		return doc.CollectionID == collectionID && doc.Folder == folder && doc.FilePath == filePath && doc.ChunkIndex == chunkIndex
+	})
+}
+
+// findDocument returns the first chunk that matches
+func (s *Store) findDocument(matches func(doc *database.Document) bool) (*database.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, doc := range s.documents {
+		if matches(doc) {
+			return copyDocument(doc), nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get document: not found")
+}
+
+// ListDocumentsByFile lists the chunks of every file with the given path,
+// in any folder of a collection
+func (s *Store) ListDocumentsByFile(collectionID, filePath string) ([]*database.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var docs []*database.Document
+	for _, doc := range s.documents {
+		if doc.CollectionID == collectionID && doc.FilePath == filePath {
+			docs = append(docs, copyDocument(doc))
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].Folder != docs[j].Folder {
+			return docs[i].Folder < docs[j].Folder
+		}
+		return docs[i].ChunkIndex < docs[j].ChunkIndex
+	})
+	return docs, nil
+}
+
+// ListFiles lists the indexed files of a collection, sorted by path
+func (s *Store) ListFiles(collectionID string) ([]*database.IndexedFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.files(func(doc *database.Document) bool { return doc.CollectionID == collectionID }), nil
+}
+
+// FindSharedEmbeddings finds the embeddings of chunks with the given content
+// hashes, and of the file path, stored in other collections by the same
+// embedding model with the same dimensions
+func (s *Store) FindSharedEmbeddings(collectionID, model string, dimensions int, filePath string, hashes []string) (*database.SharedEmbeddings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		wanted[hash] = true
+	}
+	shared := &database.SharedEmbeddings{Chunks: make(map[string][]float32)}
+	for _, doc := range s.documents {
+		if doc.CollectionID == collectionID || s.models[doc.CollectionID] != model || s.dimensions[doc.CollectionID] != dimensions {
+			continue
+		}
+		if _, found := shared.Chunks[doc.ContentHash]; wanted[doc.ContentHash] && !found && len(doc.Embedding) == dimensions {
+			shared.Chunks[doc.ContentHash] = append([]float32(nil), doc.Embedding...)
+		}
+		if shared.Path == nil && doc.FilePath == filePath && len(doc.PathEmbedding) == dimensions {
+			shared.Path = append([]float32(nil), doc.PathEmbedding...)
+		}
+	}
+	return shared, nil
+}
+
+// CopyIndexedFolder copies the chunks, embeddings and file states of a
+// folder from the collection that indexed it most recently with the same
+// embedding model, metadata schema and normalization
+func (s *Store) CopyIndexedFolder(collectionID, folder, model string) (*database.FolderCopy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, ok := s.collections[collectionID]
+	if !ok {
+		return nil, fmt.Errorf("failed to copy folder: %w: %s", database.ErrCollectionNotFound, collectionID)
+	}
+	if current, ok := s.models[collectionID]; ok && current != model {
+		return nil, nil
+	}
+
+	// The source is the collection whose chunks of the folder were written last
+	var source *collection
+	var latest time.Time
+	for _, doc := range s.documents {
+		candidate := s.collections[doc.CollectionID]
+		if doc.CollectionID == collectionID || doc.Folder != folder || s.models[doc.CollectionID] != model {
+			continue
+		}
+		if _, ok := s.dimensions[collectionID]; ok && s.dimensions[doc.CollectionID] != s.dimensions[collectionID] {
+			continue
+		}
+		if candidate.normalized != target.normalized || !sameSchema(candidate.schema, target.schema) {
+			continue
+		}
+		if source == nil || doc.UpdatedAt.After(latest) {
+			source, latest = candidate, doc.UpdatedAt
+		}
+	}
+	if source == nil {
+		return nil, nil
+	}
+
+	copied := &database.FolderCopy{CollectionID: source.ID, CollectionName: source.Name}
+	for _, doc := range s.documents {
+		if doc.CollectionID != source.ID || doc.Folder != folder {
+			continue
+		}
+		if s.hasChunk(collectionID, doc.Folder, doc.FilePath, doc.ChunkIndex) {
+			continue
+		}
+		chunk := copyDocument(doc)
+		chunk.CollectionID = collectionID
+		chunk.CreatedAt = time.Time{}
+		if err := s.insertDocument(chunk); err != nil {
+			return nil, err
+		}
+		copied.Chunks++
+	}
+	for key, state := range s.fileStates {
+		if !strings.HasPrefix(key, source.ID+"\x00") || state.Folder != folder {
+			continue
+		}
+		targetKey := fileStateKey(collectionID, state.Folder, state.FilePath)
+		if _, ok := s.fileStates[targetKey]; !ok {
+			stateCopy := *state
+			s.fileStates[targetKey] = &stateCopy
+			copied.Files++
+		}
+	}
+	if _, ok := s.models[collectionID]; !ok {
+		s.models[collectionID] = model
+		s.dimensions[collectionID] = s.dimensions[source.ID]
+	}
+	return copied, nil
+}
+
+// hasChunk reports whether a collection has the chunk with the given index
+// of a file
+func (s *Store) hasChunk(collectionID, folder, filePath string, chunkIndex int) bool {
+	for _, doc := range s.documents {
+		if doc.CollectionID == collectionID && doc.Folder == folder && doc.FilePath == filePath && doc.ChunkIndex == chunkIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// sameSchema reports whether two metadata schemas have the same fields
+func sameSchema(a, b database.MetadataSchema) bool {
+	return slices.EqualFunc(a, b, func(x, y database.MetadataField) bool {
+		return x.String() == y.String()
+	})
+}
+
+// files groups the matching chunks by file, sorted by path
+func (s *Store) files(matches func(doc *database.Document) bool) []*database.IndexedFile {
+	byFile := make(map[string]*database.IndexedFile)
+	var files []*database.IndexedFile
+	for _, doc := range s.documents {
+		if !matches(doc) {
+			continue
+		}
+		key := doc.Folder + "\x00" + doc.FilePath
+		file, ok := byFile[key]
+		if !ok {
+			file = &database.IndexedFile{Folder: doc.Folder, FilePath: doc.FilePath, FileName: doc.FileName}
+			byFile[key] = file
+			files = append(files, file)
+		}
+		file.Chunks++
+		if doc.FileName < file.FileName {
+			file.FileName = doc.FileName
+		}
+		if doc.UpdatedAt.After(file.UpdatedAt) {
+			file.UpdatedAt = doc.UpdatedAt
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].FilePath != files[j].FilePath {
+			return files[i].FilePath < files[j].FilePath
+		}
+		return files[i].Folder < files[j].Folder
+	})
+	return files
+}
+
+// deleteDocuments deletes the matching chunks and returns how many there were
+func (s *Store) deleteDocuments(matches func(doc *database.Document) bool) int {
+	kept := s.documents[:0]
+	for _, doc := range s.documents {
+		if !matches(doc) {
+			kept = append(kept, doc)
+		}
+	}
+	deleted := len(s.documents) - len(kept)
+	for i := len(kept); i < len(s.documents); i++ {
+		s.documents[i] = nil
+	}
+	s.documents = kept
+	return deleted
+}
+
+// fileStateKey is the key of a file's state
+func fileStateKey(collectionID, folder, filePath string) string {
+	return collectionID + "\x00" + folder + "\x00" + filePath
+}
+
+// ListFileStates returns the states of the indexed files of a collection
+// folder by their path. Files without chunks are left out.
+func (s *Store) ListFileStates(collectionID, folder string) (map[string]*database.FileState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	indexed := make(map[string]bool)
+	for _, doc := range s.documents {
+		if doc.CollectionID == collectionID && doc.Folder == folder {
+			indexed[doc.FilePath] = true
+		}
+	}
+
+	states := make(map[string]*database.FileState)
+	for key, state := range s.fileStates {
+		if key == fileStateKey(collectionID, folder, state.FilePath) && indexed[state.FilePath] {
+			copied := *state
+			states[state.FilePath] = &copied
+		}
+	}
+	return states, nil
+}
+
+// SaveFileState records the state of a file that was indexed
+func (s *Store) SaveFileState(collectionID string, state *database.FileState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *state
+	s.fileStates[fileStateKey(collectionID, state.Folder, state.FilePath)] = &copied
+	return nil
+}
+
+// TouchFile records the new size and modification time of an unchanged file
+// and marks its chunks as updated
+func (s *Store) TouchFile(collectionID string, state *database.FileState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.fileStates[fileStateKey(collectionID, state.Folder, state.FilePath)]; ok {
+		stored.Size = state.Size
+		stored.ModifiedAt = state.ModifiedAt
+	}
+	now := time.Now()
+	for _, doc := range s.documents {
+		if doc.CollectionID == collectionID && doc.Folder == state.Folder && doc.FilePath == state.FilePath {
+			doc.UpdatedAt = now
+		}
+	}
+	return nil
+}
+
+// Close does nothing; the data stays in memory
+func (s *Store) Close() error {
+	return nil
+}
+
+// InitSchema does nothing; the store needs no schema
+func (s *Store) InitSchema() error {
+	return nil
+}
+
+// GetEmbeddingDimensions returns the embedding dimensions of a collection,
+// 768 when they aren't set like the database
+func (s *Store) GetEmbeddingDimensions(collectionID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dimensions, ok := s.dimensions[collectionID]; ok {
+		return dimensions, nil
+	}
+	return 768, nil
+}
+
+// SetEmbeddingDimensions sets the embedding dimensions of a collection
+func (s *Store) SetEmbeddingDimensions(collectionID string, dimensions int, modelName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dimensions[collectionID] = dimensions
+	s.models[collectionID] = modelName
+	return nil
+}
+
+// GetMigrationVersion returns 0; the store has no migrations
+func (s *Store) GetMigrationVersion() (int, error) {
+	return 0, nil
+}
+
+// RunMigrations does nothing; the store has no migrations
+func (s *Store) RunMigrations(targetVersion int) error {
+	return nil
+}
+
+// GetTotalMigrations returns 0; the store has no migrations
+func (s *Store) GetTotalMigrations() int {
+	return 0
+}
+
+// inScope reports whether a chunk is in a folder scope (see database.FolderScope)
+func inScope(doc *database.Document, scope database.FolderScope) bool {
+	if doc.Folder != scope.Folder {
+		return false
+	}
+	rest := doc.FilePath
+	if subfolder := strings.Trim(scope.Subfolder, "/"); subfolder != "" {
+		if !strings.HasPrefix(rest, subfolder+"/") {
+			return false
+		}
+		rest = strings.TrimPrefix(rest, subfolder+"/")
+	}
+	return scope.Match != database.FolderMatchExact || !strings.Contains(rest, "/")
+}
+
+// globMatcher returns a function matching file names against a glob the way
+// database.GlobToLike does: * and ? are wildcards, and globs without
+// wildcards match names that contain them. Empty globs match every name.
+func globMatcher(glob string, ignoreCase bool) func(name string) bool {
+	if glob == "" {
+		return func(string) bool { return true }
+	}
+
+	var b strings.Builder
+	if ignoreCase {
+		b.WriteString("(?i)")
+	}
+	if !strings.ContainsAny(glob, "*?") {
+		b.WriteString(regexp.QuoteMeta(glob))
+		return regexp.MustCompile(b.String()).MatchString
+	}
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()).MatchString
+}
+
+// copyCollection returns a copy of a collection that callers can change
+func copyCollection(c *collection) *database.Collection {
+	copied := c.Collection
+	copied.Folders = append([]string(nil), c.Folders...)
+	return &copied
+}
+
+// copyDocument returns a copy of a chunk that callers can change
+func copyDocument(doc *database.Document) *database.Document {
+	copied := *doc
+	copied.Embedding = append([]float32(nil), doc.Embedding...)
+	copied.PathEmbedding = append([]float32(nil), doc.PathEmbedding...)
+	return &copied
+}
+
+// norm returns the length of a vector
+func norm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
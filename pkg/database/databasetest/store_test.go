@@ -0,0 +1,288 @@
+package databasetest
+
+import (
+	"errors"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// insert stores a chunk of a file in the /docs folder
+func insert(t *testing.T, s *Store, collectionID, filePath string, chunkIndex int, content, metadata string, embedding ...float32) *database.Document {
+	t.Helper()
+	doc := &database.Document{
+		CollectionID: collectionID,
+		Folder:       "/docs",
+		FilePath:     filePath,
+		FileName:     path.Base(filePath),
+		Content:      content,
+		ChunkIndex:   chunkIndex,
+		Embedding:    embedding,
+		Metadata:     metadata,
+	}
+	require.NoError(t, s.InsertDocument(doc))
+	return doc
+}
+
+func TestStoreCollections(t *testing.T) {
+	s := NewStore()
+
+	docs, err := s.CreateCollection("docs", "Documentation", []string{"/docs"})
+	require.NoError(t, err)
+	_, err = s.CreateCollection("notes", "", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, s.AddAlias(docs.ID, "manual"))
+	assert.Error(t, s.AddAlias(docs.ID, "notes"), "aliases can't shadow names")
+
+	found, err := s.GetCollectionByIdOrName("manual")
+	require.NoError(t, err)
+	assert.Equal(t, docs.ID, found.ID)
+	found, err = s.GetCollectionByIdOrName(docs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "docs", found.Name)
+
+	_, err = s.GetCollectionByIdOrName("missing")
+	assert.True(t, errors.Is(err, database.ErrCollectionNotFound))
+
+	// Returned collections are copies
+	found.Folders[0] = "/changed"
+	found, err = s.GetCollection(docs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/docs"}, found.Folders)
+
+	_, err = s.CreateCollection("docs", "", nil)
+	require.NoError(t, err)
+	_, err = s.GetCollectionByIdOrName("docs")
+	var ambiguous *database.AmbiguousCollectionError
+	assert.ErrorAs(t, err, &ambiguous)
+
+	insert(t, s, docs.ID, "a.md", 0, "First", "{}", 1, 0)
+	require.NoError(t, s.DeleteCollection(docs.ID))
+	files, err := s.ListFiles(docs.ID)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	aliases, err := s.ListAliases(docs.ID)
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+}
+
+func TestStoreDocuments(t *testing.T) {
+	s := NewStore()
+	collection, err := s.CreateCollection("docs", "", []string{"/docs"})
+	require.NoError(t, err)
+
+	insert(t, s, collection.ID, "guides/setup.md", 1, "Second", "{}")
+	first := insert(t, s, collection.ID, "guides/setup.md", 0, "First", "{}")
+	insert(t, s, collection.ID, "guides/deep/notes.txt", 0, "Notes", "{}")
+	insert(t, s, collection.ID, "readme.md", 0, "Readme", "{}")
+
+	page, err := s.ListDocumentsByFolder(collection.ID, database.FolderScope{Folder: "/docs", Subfolder: "guides", Match: database.FolderMatchExact}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, "First", page.Documents[0].Content)
+
+	page, err = s.ListDocumentsByFolderWithFilter(collection.ID, database.FolderScope{Folder: "/docs"}, "*.md", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	require.Len(t, page.Documents, 1)
+	assert.Equal(t, "Second", page.Documents[0].Content)
+
+	doc, err := s.GetDocumentByPathAndIndex(collection.ID, "/docs", "guides/setup.md", 0)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, doc.ID)
+
+	files, err := s.DeleteDocumentsByPattern(collection.ID, "guides/**", true)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "guides/deep/notes.txt", files[0].FilePath)
+	assert.Equal(t, 2, files[1].Chunks)
+
+	_, err = s.DeleteDocumentsByPattern(collection.ID, "guides/**", false)
+	require.NoError(t, err)
+	require.NoError(t, s.UpdateCollectionStats(collection.ID))
+	collection, err = s.GetCollection(collection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, database.Stats{TotalDocuments: 1, TotalChunks: 1, TotalSize: 6}, collection.Stats)
+
+	assert.Error(t, s.DeleteDocumentByID(first.ID))
+}
+
+func TestStoreUpsertDocuments(t *testing.T) {
+	s := NewStore()
+	collection, err := s.CreateCollection("docs", "", []string{"/docs"})
+	require.NoError(t, err)
+
+	first := insert(t, s, collection.ID, "guide.md", 0, "First", "{}")
+	insert(t, s, collection.ID, "guide.md", 1, "Second", "{}")
+	insert(t, s, collection.ID, "guide.md", 2, "Third", "{}")
+
+	// Storing a chunk again replaces it
+	doc := &database.Document{CollectionID: collection.ID, Folder: "/docs", FilePath: "guide.md", FileName: "guide.md", Content: "First, edited", Metadata: "{}"}
+	require.NoError(t, s.UpsertDocument(doc))
+	assert.Equal(t, first.ID, doc.ID)
+	assert.Equal(t, database.ContentHash([]byte("First, edited")), doc.ContentHash)
+
+	require.NoError(t, s.PruneDocumentsByPath(collection.ID, "/docs", "guide.md", []int{0, 1}))
+	chunks, err := s.ListDocumentsByFile(collection.ID, "guide.md")
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "First, edited", chunks[0].Content)
+	assert.Equal(t, "Second", chunks[1].Content)
+}
+
+func TestStoreSharedEmbeddings(t *testing.T) {
+	s := NewStore()
+	shared, err := s.CreateCollection("shared", "", []string{"/docs"})
+	require.NoError(t, err)
+	other, err := s.CreateCollection("other-model", "", []string{"/docs"})
+	require.NoError(t, err)
+	collection, err := s.CreateCollection("docs", "", []string{"/docs"})
+	require.NoError(t, err)
+	require.NoError(t, s.SetEmbeddingDimensions(shared.ID, 2, "nomic-embed-text"))
+	require.NoError(t, s.SetEmbeddingDimensions(other.ID, 2, "mxbai-embed-large"))
+	require.NoError(t, s.SetEmbeddingDimensions(collection.ID, 2, "nomic-embed-text"))
+
+	insert(t, s, shared.ID, "guide.md", 0, "Install the tool.", "{}", 1, 0)
+	insert(t, s, other.ID, "guide.md", 1, "Configure it.", "{}", 0, 1)
+	insert(t, s, collection.ID, "guide.md", 1, "Configure it.", "{}", 0.5, 0.5)
+
+	hashes := []string{database.ContentHash([]byte("Install the tool.")), database.ContentHash([]byte("Configure it."))}
+	found, err := s.FindSharedEmbeddings(collection.ID, "nomic-embed-text", 2, "guide.md", hashes)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]float32{hashes[0]: {1, 0}}, found.Chunks)
+	assert.Nil(t, found.Path)
+
+	found, err = s.FindSharedEmbeddings(collection.ID, "nomic-embed-text", 3, "guide.md", hashes)
+	require.NoError(t, err)
+	assert.Empty(t, found.Chunks)
+}
+
+func TestStoreCopyIndexedFolder(t *testing.T) {
+	s := NewStore()
+	source, err := s.CreateCollection("team-a", "", []string{"/docs"})
+	require.NoError(t, err)
+	other, err := s.CreateCollection("other-model", "", []string{"/docs"})
+	require.NoError(t, err)
+	collection, err := s.CreateCollection("team-b", "", []string{"/docs"})
+	require.NoError(t, err)
+	require.NoError(t, s.SetEmbeddingDimensions(source.ID, 2, "nomic-embed-text"))
+	require.NoError(t, s.SetEmbeddingDimensions(other.ID, 2, "mxbai-embed-large"))
+
+	insert(t, s, source.ID, "guide.md", 0, "Install the tool.", "{}", 1, 0)
+	insert(t, s, source.ID, "guide.md", 1, "Configure it.", "{}", 0, 1)
+	insert(t, s, other.ID, "notes.md", 0, "Other model.", "{}", 1, 1)
+	require.NoError(t, s.SaveFileState(source.ID, &database.FileState{Folder: "/docs", FilePath: "guide.md", Size: 30, ModifiedAt: time.Now()}))
+
+	copied, err := s.CopyIndexedFolder(collection.ID, "/docs", "nomic-embed-text")
+	require.NoError(t, err)
+	require.NotNil(t, copied)
+	assert.Equal(t, source.ID, copied.CollectionID)
+	assert.Equal(t, int64(2), copied.Chunks)
+	assert.Equal(t, int64(1), copied.Files)
+
+	chunks, err := s.ListDocumentsByFile(collection.ID, "guide.md")
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, []float32{0, 1}, chunks[1].Embedding)
+	states, err := s.ListFileStates(collection.ID, "/docs")
+	require.NoError(t, err)
+	assert.Contains(t, states, "guide.md")
+	dimensions, err := s.GetEmbeddingDimensions(collection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, dimensions)
+
+	// Copying again doesn't duplicate chunks
+	copied, err = s.CopyIndexedFolder(collection.ID, "/docs", "nomic-embed-text")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), copied.Chunks)
+
+	// Nothing is copied for another model or a folder nobody indexed
+	copied, err = s.CopyIndexedFolder(collection.ID, "/docs", "mxbai-embed-large")
+	require.NoError(t, err)
+	assert.Nil(t, copied)
+	copied, err = s.CopyIndexedFolder(collection.ID, "/src", "nomic-embed-text")
+	require.NoError(t, err)
+	assert.Nil(t, copied)
+}
+
+func TestStoreFileStates(t *testing.T) {
+	s := NewStore()
+	collection, err := s.CreateCollection("docs", "", []string{"/docs"})
+	require.NoError(t, err)
+
+	state := &database.FileState{Folder: "/docs", FilePath: "a.md", Size: 5, ModifiedAt: time.Now(), ContentHash: database.ContentHash([]byte("hello"))}
+	require.NoError(t, s.SaveFileState(collection.ID, state))
+
+	// Files without chunks are left out
+	states, err := s.ListFileStates(collection.ID, "/docs")
+	require.NoError(t, err)
+	assert.Empty(t, states)
+
+	insert(t, s, collection.ID, "a.md", 0, "hello", "{}")
+	touched := *state
+	touched.ModifiedAt = state.ModifiedAt.Add(time.Minute)
+	require.NoError(t, s.TouchFile(collection.ID, &touched))
+
+	states, err = s.ListFileStates(collection.ID, "/docs")
+	require.NoError(t, err)
+	require.Contains(t, states, "a.md")
+	assert.True(t, states["a.md"].SameStat(5, touched.ModifiedAt))
+}
+
+func TestStoreSearch(t *testing.T) {
+	s := NewStore()
+	collection, err := s.CreateCollection("docs", "", []string{"/docs"})
+	require.NoError(t, err)
+
+	insert(t, s, collection.ID, "auth/reset.md", 0, "Reset your password from the login page.", `{"priority": "3"}`, 1, 0, 0)
+	insert(t, s, collection.ID, "billing/refunds.md", 0, "Refunds go to the original payment method.", `{"priority": "1"}`, 0, 1, 0)
+	insert(t, s, collection.ID, "config/parse.go", 0, "func ParseConfig(path string) reads the password file", `{}`, 0.2, 0, 1)
+
+	for _, searchType := range []database.SearchType{database.SearchTypeVector, database.SearchTypeText, database.SearchTypeHybrid, database.SearchTypeSemantic, database.SearchTypeBM25, database.SearchTypeFusion} {
+		opts := &database.SearchOptions{SearchType: searchType, VectorWeight: 0.7, TextWeight: 0.3, MaxDistance: 2}
+		results, err := s.SearchDocumentsWithOptions(collection.ID, []float32{0.9, 0.1, 0}, "reset password", 2, opts)
+		require.NoError(t, err, searchType)
+		require.NotEmpty(t, results, searchType)
+		assert.Equal(t, "auth/reset.md", results[0].Document.FilePath, searchType)
+		assert.Equal(t, 1, results[0].Rank, searchType)
+		assert.LessOrEqual(t, len(results), 2, searchType)
+	}
+
+	schema := database.MetadataSchema{{Name: "priority", Type: database.MetadataTypeNumber}}
+	where, err := database.ParseMetadataFilters([]string{"priority<3"}, schema)
+	require.NoError(t, err)
+	results, err := s.SearchDocumentsWithOptions(collection.ID, []float32{1, 0, 0}, "", 10, &database.SearchOptions{SearchType: database.SearchTypeVector, MaxDistance: 2, Where: where})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "billing/refunds.md", results[0].Document.FilePath)
+
+	results, err = s.SearchDocumentsWithOptions(collection.ID, []float32{1, 0, 0}, "", 10, &database.SearchOptions{SearchType: database.SearchTypeVector, MaxDistance: 2, Metadata: map[string]string{"priority": "3"}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "auth/reset.md", results[0].Document.FilePath)
+
+	results, err = s.SearchDocumentsWithOptions(collection.ID, nil, "password", 10, &database.SearchOptions{SearchType: database.SearchTypeBM25, FileTypes: []string{"go"}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "config/parse.go", results[0].Document.FilePath)
+
+	_, err = s.SearchDocumentsWithOptions(collection.ID, nil, "", 10, &database.SearchOptions{SearchType: database.SearchTypeText})
+	assert.Error(t, err)
+
+	insert(t, s, collection.ID, "auth/reset.md", 1, "The link expires after an hour.", `{}`, 0, 0, 1)
+	results, err = s.SearchDocumentsWithOptions(collection.ID, []float32{1, 0, 0}, "", 1, &database.SearchOptions{SearchType: database.SearchTypeVector, MaxDistance: 2, ContextChunks: 1})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Reset your password from the login page.The link expires after an hour.", results[0].Document.Content)
+	assert.Equal(t, []int{0, 1}, results[0].ContextChunks)
+
+	docs, err := s.SearchDocuments(collection.ID, []float32{0, 1, 0}, 1)
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "billing/refunds.md", docs[0].FilePath)
+}
@@ -3,7 +3,10 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/lib/pq"
 	"github.com/pgvector/pgvector-go"
 )
 
@@ -17,18 +20,66 @@ func NewDocumentManager(db *sql.DB) DocumentManager {
 	return &DocumentManagerImpl{db: db}
 }
 
-// InsertDocument inserts a new document
-func (dm *DocumentManagerImpl) InsertDocument(doc *Document) error {
-	query := `
-		INSERT INTO documents (collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+// insertDocumentQuery inserts a document, followed by the ON CONFLICT clause
+// of UpsertDocument or nothing
+const insertDocumentQuery = `
+		INSERT INTO documents (collection_id, folder, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at, file_type, symbols, path_embedding, token_count, content_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		%s
 		RETURNING id, created_at, updated_at
 	`
 
+// InsertDocument inserts a new document
+func (dm *DocumentManagerImpl) InsertDocument(doc *Document) error {
+	return dm.writeDocument(fmt.Sprintf(insertDocumentQuery, ""), doc)
+}
+
+// UpsertDocument inserts a document, or replaces the chunk with the same
+// index of the same file, so storing the chunks of a file again doesn't
+// duplicate them
+func (dm *DocumentManagerImpl) UpsertDocument(doc *Document) error {
+	return dm.writeDocument(fmt.Sprintf(insertDocumentQuery, `
+		ON CONFLICT (collection_id, COALESCE(folder, ''), file_path, chunk_index) DO UPDATE SET
+			file_name = EXCLUDED.file_name,
+			content = EXCLUDED.content,
+			embedding = EXCLUDED.embedding,
+			metadata = EXCLUDED.metadata,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at,
+			file_type = EXCLUDED.file_type,
+			symbols = EXCLUDED.symbols,
+			path_embedding = EXCLUDED.path_embedding,
+			token_count = EXCLUDED.token_count,
+			content_hash = EXCLUDED.content_hash`), doc)
+}
+
+// writeDocument stores a document with an insert query, setting its ID,
+// times and content hash
+func (dm *DocumentManagerImpl) writeDocument(query string, doc *Document) error {
 	// Convert embedding to vector type
 	embeddingVector := pgvector.NewVector(doc.Embedding)
 
-	err := dm.db.QueryRow(query, doc.CollectionID, doc.FilePath, doc.FileName, doc.Content, doc.ChunkIndex, embeddingVector, doc.Metadata, doc.CreatedAt, doc.UpdatedAt).Scan(
+	// Content is stored encrypted when encryption is enabled, and its hash keyed
+	content, err := encryptContent(doc.Content)
+	if err != nil {
+		return fmt.Errorf("failed to insert document: %w", err)
+	}
+	if doc.ContentHash, err = KeyedContentHash([]byte(doc.Content)); err != nil {
+		return fmt.Errorf("failed to insert document: %w", err)
+	}
+
+	var pathVector interface{}
+	if len(doc.PathEmbedding) > 0 {
+		pathVector = pgvector.NewVector(doc.PathEmbedding)
+	}
+
+	// Symbols are stored in plain text, so encrypted chunks don't get any
+	symbols := ""
+	if !IsEncrypted(content) {
+		symbols = ExtractSymbols(doc.FileName, doc.Content)
+	}
+
+	err = dm.db.QueryRow(query, doc.CollectionID, doc.Folder, doc.FilePath, doc.FileName, content, doc.ChunkIndex, embeddingVector, doc.Metadata, doc.CreatedAt, doc.UpdatedAt, FileType(doc.FileName), symbols, pathVector, doc.TokenCount, doc.ContentHash).Scan(
 		&doc.ID,
 		&doc.CreatedAt,
 		&doc.UpdatedAt,
@@ -41,11 +92,11 @@ func (dm *DocumentManagerImpl) InsertDocument(doc *Document) error {
 	return nil
 }
 
-// DeleteDocumentsByPath deletes all documents with a specific file path
-func (dm *DocumentManagerImpl) DeleteDocumentsByPath(collectionID, filePath string) error {
-	query := `DELETE FROM documents WHERE collection_id = $1 AND file_path = $2`
+// DeleteDocumentsByPath deletes all documents with a specific file path in a collection folder
+func (dm *DocumentManagerImpl) DeleteDocumentsByPath(collectionID, folder, filePath string) error {
+	query := `DELETE FROM documents WHERE collection_id = $1 AND folder = $2 AND file_path = $3`
 
-	_, err := dm.db.Exec(query, collectionID, filePath)
+	_, err := dm.db.Exec(query, collectionID, folder, filePath)
 	if err != nil {
 		return fmt.Errorf("failed to delete documents: %w", err)
 	}
@@ -53,111 +104,136 @@ func (dm *DocumentManagerImpl) DeleteDocumentsByPath(collectionID, filePath stri
 	return nil
 }
 
-// DeleteDocumentsByFolder deletes all documents from a specific folder in a collection
-func (dm *DocumentManagerImpl) DeleteDocumentsByFolder(collectionID, folder string) error {
-	query := `DELETE FROM documents WHERE collection_id = $1 AND file_path LIKE $2`
-
-	// Use LIKE with wildcard to match folder path
-	folderPattern := folder + "/%"
+// PruneDocumentsByPath deletes the chunks of a file other than those with the
+// given indexes, left over from when the file had more chunks
+func (dm *DocumentManagerImpl) PruneDocumentsByPath(collectionID, folder, filePath string, chunkIndexes []int) error {
+	query := `DELETE FROM documents WHERE collection_id = $1 AND folder = $2 AND file_path = $3 AND NOT (chunk_index = ANY($4))`
 
-	_, err := dm.db.Exec(query, collectionID, folderPattern)
+	_, err := dm.db.Exec(query, collectionID, folder, filePath, pq.Array(chunkIndexes))
 	if err != nil {
-		return fmt.Errorf("failed to delete documents from folder: %w", err)
+		return fmt.Errorf("failed to prune documents: %w", err)
 	}
 
 	return nil
 }
 
-// ListDocumentsByFolder lists documents from a specific folder in a collection
-func (dm *DocumentManagerImpl) ListDocumentsByFolder(collectionID, folder string, limit, offset int) ([]*Document, error) {
+// DeleteDocumentsByFolder deletes all documents from a specific folder (and its subfolders) in a collection
+func (dm *DocumentManagerImpl) DeleteDocumentsByFolder(collectionID, folder string) error {
+	// Documents indexed before paths were stored relative to their folder
+	// have no folder and an absolute path
+	prefix := folder
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+
 	query := `
-		SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
-		FROM documents 
-		WHERE collection_id = $1 AND file_path LIKE $2
-		ORDER BY file_path ASC
-		LIMIT $3 OFFSET $4
+		DELETE FROM documents
+		WHERE collection_id = $1
+		  AND (folder = $2 OR (folder IS NULL AND file_path LIKE $3))
 	`
 
-	// Use LIKE with wildcard to match folder path
-	folderPattern := folder + "/%"
+	_, err := dm.db.Exec(query, collectionID, folder, likeEscaper.Replace(prefix)+"%")
+	if err != nil {
+		return fmt.Errorf("failed to delete documents from folder: %w", err)
+	}
+
+	return nil
+}
 
-	rows, err := dm.db.Query(query, collectionID, folderPattern, limit, offset)
+// DeleteDocumentsByPattern deletes the chunks of every file of a collection
+// whose path relative to its folder matches a path glob (see PathGlobToRegex),
+// in one transaction. It returns the matching files; with dryRun nothing is
+// deleted.
+func (dm *DocumentManagerImpl) DeleteDocumentsByPattern(collectionID, pattern string, dryRun bool) ([]*IndexedFile, error) {
+	tx, err := dm.db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to query documents: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	regex := PathGlobToRegex(pattern)
+	rows, err := tx.Query(`
+		SELECT COALESCE(folder, ''), file_path, MIN(file_name), COUNT(*), MAX(updated_at)
+		FROM documents
+		WHERE collection_id = $1 AND file_path ~ $2
+		GROUP BY folder, file_path
+		ORDER BY file_path ASC, folder ASC
+	`, collectionID, regex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
 	}
 	defer rows.Close()
 
-	var documents []*Document
+	var files []*IndexedFile
 	for rows.Next() {
-		doc := &Document{}
-		var embeddingVector pgvector.Vector
-
-		err := rows.Scan(
-			&doc.ID,
-			&doc.CollectionID,
-			&doc.FilePath,
-			&doc.FileName,
-			&doc.Content,
-			&doc.ChunkIndex,
-			&embeddingVector,
-			&doc.Metadata,
-			&doc.CreatedAt,
-			&doc.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan document: %w", err)
+		file := &IndexedFile{}
+		if err := rows.Scan(&file.Folder, &file.FilePath, &file.FileName, &file.Chunks, &file.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
 		}
+		files = append(files, file)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over files: %w", err)
+	}
 
-		// Convert vector back to float32 slice
-		doc.Embedding = embeddingVector.Slice()
-
-		documents = append(documents, doc)
+	if dryRun || len(files) == 0 {
+		return files, nil
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over documents: %w", err)
+	if _, err := tx.Exec(`DELETE FROM documents WHERE collection_id = $1 AND file_path ~ $2`, collectionID, regex); err != nil {
+		return nil, fmt.Errorf("failed to delete documents: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit deletion: %w", err)
 	}
 
-	return documents, nil
+	return files, nil
+}
+
+// ListDocumentsByFolder lists one page of documents from a specific folder in a collection
+func (dm *DocumentManagerImpl) ListDocumentsByFolder(collectionID string, scope FolderScope, limit, offset int) (*DocumentPage, error) {
+	return dm.listDocumentsByFolder(collectionID, scope, "", limit, offset)
+}
+
+// ListDocumentsByFolderWithFilter lists one page of documents from a specific folder in a collection with file pattern filtering
+func (dm *DocumentManagerImpl) ListDocumentsByFolderWithFilter(collectionID string, scope FolderScope, fileFilter string, limit, offset int) (*DocumentPage, error) {
+	return dm.listDocumentsByFolder(collectionID, scope, fileFilter, limit, offset)
 }
 
-// ListDocumentsByFolderWithFilter lists documents from a specific folder in a collection with file pattern filtering
-func (dm *DocumentManagerImpl) ListDocumentsByFolderWithFilter(collectionID, folder, fileFilter string, limit, offset int) ([]*Document, error) {
-	var query string
-	var args []interface{}
+// listDocumentsByFolder counts the documents matching the folder and optional
+// file filter and returns the requested page of them
+func (dm *DocumentManagerImpl) listDocumentsByFolder(collectionID string, scope FolderScope, fileFilter string, limit, offset int) (*DocumentPage, error) {
+	condition, folderArgs := folderCondition(scope, 2)
 
+	where := `collection_id = $1 AND ` + condition
+	args := append([]interface{}{collectionID}, folderArgs...)
 	if fileFilter != "" {
-		query = `
-			SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
-			FROM documents 
-			WHERE collection_id = $1 AND file_path LIKE $2 AND file_name LIKE $3
-			ORDER BY file_path ASC
-			LIMIT $4 OFFSET $5
-		`
-		// Use LIKE with wildcard to match folder path
-		folderPattern := folder + "/%"
-		args = []interface{}{collectionID, folderPattern, fileFilter, limit, offset}
-	} else {
-		query = `
-			SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
-			FROM documents 
-			WHERE collection_id = $1 AND file_path LIKE $2
-			ORDER BY file_path ASC
-			LIMIT $3 OFFSET $4
-		`
-		// Use LIKE with wildcard to match folder path
-		folderPattern := folder + "/%"
-		args = []interface{}{collectionID, folderPattern, limit, offset}
+		where += fmt.Sprintf(` AND file_name LIKE $%d`, len(args)+1)
+		args = append(args, GlobToLike(fileFilter))
 	}
 
+	page := &DocumentPage{Limit: limit, Offset: offset}
+
+	countQuery := `SELECT COUNT(*) FROM documents WHERE ` + where
+	if err := dm.db.QueryRow(countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, collection_id, COALESCE(folder, ''), file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
+		FROM documents 
+		WHERE %s
+		ORDER BY file_path ASC, chunk_index ASC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
 	rows, err := dm.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query documents: %w", err)
 	}
 	defer rows.Close()
 
-	var documents []*Document
 	for rows.Next() {
 		doc := &Document{}
 		var embeddingVector pgvector.Vector
@@ -165,6 +241,7 @@ func (dm *DocumentManagerImpl) ListDocumentsByFolderWithFilter(collectionID, fol
 		err := rows.Scan(
 			&doc.ID,
 			&doc.CollectionID,
+			&doc.Folder,
 			&doc.FilePath,
 			&doc.FileName,
 			&doc.Content,
@@ -181,21 +258,24 @@ func (dm *DocumentManagerImpl) ListDocumentsByFolderWithFilter(collectionID, fol
 
 		// Convert vector back to float32 slice
 		doc.Embedding = embeddingVector.Slice()
+		if err := decryptContent(&doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+		}
 
-		documents = append(documents, doc)
+		page.Documents = append(page.Documents, doc)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating over documents: %w", err)
 	}
 
-	return documents, nil
+	return page, nil
 }
 
 // GetDocumentByID retrieves a document by its ID
 func (dm *DocumentManagerImpl) GetDocumentByID(documentID string) (*Document, error) {
 	query := `
-		SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
+		SELECT id, collection_id, COALESCE(folder, ''), file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
 		FROM documents 
 		WHERE id = $1
 	`
@@ -206,6 +286,7 @@ func (dm *DocumentManagerImpl) GetDocumentByID(documentID string) (*Document, er
 	err := dm.db.QueryRow(query, documentID).Scan(
 		&doc.ID,
 		&doc.CollectionID,
+		&doc.Folder,
 		&doc.FilePath,
 		&doc.FileName,
 		&doc.Content,
@@ -222,6 +303,9 @@ func (dm *DocumentManagerImpl) GetDocumentByID(documentID string) (*Document, er
 
 	// Convert vector back to float32 slice
 	doc.Embedding = embeddingVector.Slice()
+	if err := decryptContent(&doc.Content); err != nil {
+		return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+	}
 
 	return &doc, nil
 }
@@ -247,20 +331,21 @@ func (dm *DocumentManagerImpl) DeleteDocumentByID(documentID string) error {
 	return nil
 }
 
-// GetDocumentByPathAndIndex retrieves a document by collection ID, file path, and chunk index
-func (dm *DocumentManagerImpl) GetDocumentByPathAndIndex(collectionID, filePath string, chunkIndex int) (*Document, error) {
+// GetDocumentByPathAndIndex retrieves a document by collection ID, folder, file path relative to the folder, and chunk index
+func (dm *DocumentManagerImpl) GetDocumentByPathAndIndex(collectionID, folder, filePath string, chunkIndex int) (*Document, error) {
 	query := `
-		SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
+		SELECT id, collection_id, COALESCE(folder, ''), file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
 		FROM documents 
-		WHERE collection_id = $1 AND file_path = $2 AND chunk_index = $3
+		WHERE collection_id = $1 AND folder = $2 AND file_path = $3 AND chunk_index = $4
 	`
 
 	var doc Document
 	var embeddingVector pgvector.Vector
 
-	err := dm.db.QueryRow(query, collectionID, filePath, chunkIndex).Scan(
+	err := dm.db.QueryRow(query, collectionID, folder, filePath, chunkIndex).Scan(
 		&doc.ID,
 		&doc.CollectionID,
+		&doc.Folder,
 		&doc.FilePath,
 		&doc.FileName,
 		&doc.Content,
@@ -277,6 +362,96 @@ func (dm *DocumentManagerImpl) GetDocumentByPathAndIndex(collectionID, filePath
 
 	// Convert vector back to float32 slice
 	doc.Embedding = embeddingVector.Slice()
+	if err := decryptContent(&doc.Content); err != nil {
+		return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+	}
 
 	return &doc, nil
 }
+
+// ListDocumentsByFile lists the chunks of every file with the given path
+// relative to its folder, in any folder of a collection
+func (dm *DocumentManagerImpl) ListDocumentsByFile(collectionID, filePath string) ([]*Document, error) {
+	query := `
+		SELECT id, collection_id, COALESCE(folder, ''), file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at
+		FROM documents
+		WHERE collection_id = $1 AND file_path = $2
+		ORDER BY folder ASC, chunk_index ASC
+	`
+
+	rows, err := dm.db.Query(query, collectionID, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []*Document
+	for rows.Next() {
+		doc := &Document{}
+		var embeddingVector pgvector.Vector
+
+		err := rows.Scan(
+			&doc.ID,
+			&doc.CollectionID,
+			&doc.Folder,
+			&doc.FilePath,
+			&doc.FileName,
+			&doc.Content,
+			&doc.ChunkIndex,
+			&embeddingVector,
+			&doc.Metadata,
+			&doc.CreatedAt,
+			&doc.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+
+		// Convert vector back to float32 slice
+		doc.Embedding = embeddingVector.Slice()
+		if err := decryptContent(&doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+		}
+
+		documents = append(documents, doc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over documents: %w", err)
+	}
+
+	return documents, nil
+}
+
+// ListFiles lists the indexed files of a collection across all its folders,
+// sorted by path
+func (dm *DocumentManagerImpl) ListFiles(collectionID string) ([]*IndexedFile, error) {
+	query := `
+		SELECT COALESCE(folder, ''), file_path, MIN(file_name), COUNT(*), MAX(updated_at)
+		FROM documents
+		WHERE collection_id = $1
+		GROUP BY folder, file_path
+		ORDER BY file_path ASC, folder ASC
+	`
+
+	rows, err := dm.db.Query(query, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*IndexedFile
+	for rows.Next() {
+		file := &IndexedFile{}
+		if err := rows.Scan(&file.Folder, &file.FilePath, &file.FileName, &file.Chunks, &file.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over files: %w", err)
+	}
+
+	return files, nil
+}
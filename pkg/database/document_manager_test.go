@@ -0,0 +1,76 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentPage(t *testing.T) {
+	docs := func(n int) []*Document {
+		documents := make([]*Document, n)
+		for i := range documents {
+			documents[i] = &Document{}
+		}
+		return documents
+	}
+
+	tests := []struct {
+		name       string
+		page       DocumentPage
+		pageNumber int
+		totalPages int
+		hasMore    bool
+		nextOffset int
+	}{
+		{
+			name:       "first of several pages",
+			page:       DocumentPage{Documents: docs(20), Total: 45, Limit: 20, Offset: 0},
+			pageNumber: 1,
+			totalPages: 3,
+			hasMore:    true,
+			nextOffset: 20,
+		},
+		{
+			name:       "last partial page",
+			page:       DocumentPage{Documents: docs(5), Total: 45, Limit: 20, Offset: 40},
+			pageNumber: 3,
+			totalPages: 3,
+			hasMore:    false,
+			nextOffset: -1,
+		},
+		{
+			name:       "exactly one full page",
+			page:       DocumentPage{Documents: docs(20), Total: 20, Limit: 20, Offset: 0},
+			pageNumber: 1,
+			totalPages: 1,
+			hasMore:    false,
+			nextOffset: -1,
+		},
+		{
+			name:       "empty listing",
+			page:       DocumentPage{Total: 0, Limit: 50, Offset: 0},
+			pageNumber: 1,
+			totalPages: 1,
+			hasMore:    false,
+			nextOffset: -1,
+		},
+		{
+			name:       "offset not aligned to limit",
+			page:       DocumentPage{Documents: docs(10), Total: 30, Limit: 10, Offset: 5},
+			pageNumber: 1,
+			totalPages: 3,
+			hasMore:    true,
+			nextOffset: 15,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.pageNumber, tt.page.Page())
+			assert.Equal(t, tt.totalPages, tt.page.TotalPages())
+			assert.Equal(t, tt.hasMore, tt.page.HasMore())
+			assert.Equal(t, tt.nextOffset, tt.page.NextOffset())
+		})
+	}
+}
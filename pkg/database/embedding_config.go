@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EmbeddingConfig is the model a collection's chunks are embedded with and
+// the dimensions of their embeddings
+type EmbeddingConfig struct {
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions"`
+}
+
+// GetEmbeddingConfig returns the embedding model and dimensions of a
+// collection, or nil when it has none yet
+func GetEmbeddingConfig(db *sql.DB, collectionID string) (*EmbeddingConfig, error) {
+	var config EmbeddingConfig
+	err := db.QueryRow(`SELECT model_name, dimensions FROM embedding_config WHERE collection_id = $1`, collectionID).Scan(&config.Model, &config.Dimensions)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embedding config: %w", err)
+	}
+	return &config, nil
+}
+
+// EmbeddingColumnDimensions returns the dimensions of the embeddings the
+// documents table stores, or 0 when it stores embeddings of any dimensions
+func EmbeddingColumnDimensions(db *sql.DB) (int, error) {
+	var dimensions int
+	err := db.QueryRow(`
+		SELECT atttypmod FROM pg_attribute
+		WHERE attrelid = 'documents'::regclass AND attname = 'embedding'
+	`).Scan(&dimensions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get embedding column dimensions: %w", err)
+	}
+	return max(dimensions, 0), nil
+}
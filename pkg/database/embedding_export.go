@@ -0,0 +1,53 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// EmbeddingRecord is the embedding of a chunk along with what identifies the
+// chunk, as exported for use outside rag-cli
+type EmbeddingRecord struct {
+	ID         string    `json:"id"`
+	Folder     string    `json:"folder"`
+	FilePath   string    `json:"file_path"`
+	ChunkIndex int       `json:"chunk_index"`
+	Metadata   string    `json:"metadata"`
+	Embedding  []float32 `json:"-"`
+}
+
+// ExportEmbeddings calls fn with the embedding of every chunk of a collection
+// that has one, ordered by folder, file and chunk. The chunks are read by one
+// query, so an index run changing the collection meanwhile doesn't mix old
+// and new chunks. Exporting stops at the first error fn returns.
+func ExportEmbeddings(db *sql.DB, collectionID string, fn func(*EmbeddingRecord) error) error {
+	rows, err := db.Query(`
+		SELECT id, COALESCE(folder, ''), file_path, chunk_index, COALESCE(metadata::text, '{}'), embedding
+		FROM documents
+		WHERE collection_id = $1 AND embedding IS NOT NULL
+		ORDER BY folder, file_path, chunk_index
+	`, collectionID)
+	if err != nil {
+		return fmt.Errorf("failed to list embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record EmbeddingRecord
+		var embedding pgvector.Vector
+		if err := rows.Scan(&record.ID, &record.Folder, &record.FilePath, &record.ChunkIndex, &record.Metadata, &embedding); err != nil {
+			return fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		record.Embedding = embedding.Slice()
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over embeddings: %w", err)
+	}
+
+	return nil
+}
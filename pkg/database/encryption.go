@@ -0,0 +1,216 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// encryptedPrefix starts document content encrypted by a ContentCipher, so
+// encrypted and plain content can be told apart
+const encryptedPrefix = "enc:v1:"
+
+// ErrContentEncrypted is returned when encrypted content is read without
+// the encryption settings
+var ErrContentEncrypted = errors.New("document content is encrypted; enable encryption with the key it was encrypted with")
+
+// ContentCipher encrypts document content with AES-256-GCM. Embeddings are
+// not encrypted, so vector search works on encrypted collections.
+type ContentCipher struct {
+	aead    cipher.AEAD
+	hashKey []byte // Key of the content hashes, derived from the content key
+}
+
+// NewContentCipher creates a cipher for a 256-bit key
+func NewContentCipher(key []byte) (*ContentCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("rag-cli:content-hash"))
+	return &ContentCipher{aead: aead, hashKey: mac.Sum(nil)}, nil
+}
+
+// NewContentCipherFromConfig creates a cipher for the key of the encryption
+// settings, which is read from the settings, a file or the output of a command
+func NewContentCipherFromConfig(cfg *config.EncryptionConfig) (*ContentCipher, error) {
+	encoded := cfg.Key
+	switch {
+	case cfg.KeyFile != "":
+		data, err := os.ReadFile(cfg.GetKeyFile())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key: %w", err)
+		}
+		encoded = string(data)
+	case cfg.KeyCommand != "":
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+		out, err := exec.Command(shell, flag, cfg.KeyCommand).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to run encryption key command: %w", err)
+		}
+		encoded = string(out)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64 encoded: %w", err)
+	}
+	return NewContentCipher(key)
+}
+
+// GenerateContentKey returns a new random key, base64 encoded
+func GenerateContentKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// IsEncrypted reports whether content was encrypted by a ContentCipher
+func IsEncrypted(content string) bool {
+	return strings.HasPrefix(content, encryptedPrefix)
+}
+
+// Encrypt encrypts content with a random nonce
+func (c *ContentCipher) Encrypt(content string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to encrypt content: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(content), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Hash returns the HMAC-SHA256 of content, hex encoded like ContentHash.
+// Unlike a plain hash it can't be matched against guessed content without
+// the key.
+func (c *ContentCipher) Hash(content []byte) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Decrypt decrypts content encrypted by Encrypt. Plain content, such as that
+// of documents indexed before encryption was enabled, is returned as is.
+func (c *ContentCipher) Decrypt(content string) (string, error) {
+	if !IsEncrypted(content) {
+		return content, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(content, encryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("failed to decrypt content: malformed ciphertext")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt content, was it encrypted with another key? %w", err)
+	}
+	return string(plain), nil
+}
+
+// contentEncryption holds the encryption settings used by the document
+// managers and search engines. The cipher is created on first use, so
+// commands that never touch documents don't need the key.
+var contentEncryption struct {
+	mu     sync.Mutex
+	config *config.EncryptionConfig
+	cipher *ContentCipher
+}
+
+// UseContentEncryption encrypts the content of documents stored from now on
+// and decrypts content read, with the key of the encryption settings. Nil
+// settings, or disabled ones, turn encryption off.
+func UseContentEncryption(cfg *config.EncryptionConfig) {
+	contentEncryption.mu.Lock()
+	defer contentEncryption.mu.Unlock()
+	contentEncryption.config = cfg
+	contentEncryption.cipher = nil
+}
+
+// contentCipher returns the cipher of the encryption settings, or nil when
+// encryption is off
+func contentCipher() (*ContentCipher, error) {
+	contentEncryption.mu.Lock()
+	defer contentEncryption.mu.Unlock()
+
+	if contentEncryption.config == nil || !contentEncryption.config.Enabled {
+		return nil, nil
+	}
+	if contentEncryption.cipher == nil {
+		c, err := NewContentCipherFromConfig(contentEncryption.config)
+		if err != nil {
+			return nil, err
+		}
+		contentEncryption.cipher = c
+	}
+	return contentEncryption.cipher, nil
+}
+
+// encryptContent encrypts content when encryption is on
+func encryptContent(content string) (string, error) {
+	c, err := contentCipher()
+	if err != nil || c == nil {
+		return content, err
+	}
+	return c.Encrypt(content)
+}
+
+// KeyedContentHash returns the hash of content to store with a chunk or file
+// state: ContentHash when encryption is off, and the cipher's keyed Hash when
+// it is on, so the hashes stored next to encrypted content don't reveal it.
+// Only collections encrypted with the same key share chunks by hash.
+func KeyedContentHash(content []byte) (string, error) {
+	c, err := contentCipher()
+	if err != nil {
+		return "", err
+	}
+	if c == nil {
+		return ContentHash(content), nil
+	}
+	return c.Hash(content), nil
+}
+
+// decryptContent decrypts encrypted content in place
+func decryptContent(content *string) error {
+	if !IsEncrypted(*content) {
+		return nil
+	}
+	c, err := contentCipher()
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrContentEncrypted
+	}
+	plain, err := c.Decrypt(*content)
+	if err != nil {
+		return err
+	}
+	*content = plain
+	return nil
+}
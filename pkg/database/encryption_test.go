@@ -0,0 +1,128 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentCipher(t *testing.T) {
+	key, err := GenerateContentKey()
+	require.NoError(t, err)
+	c, err := NewContentCipherFromConfig(&config.EncryptionConfig{Enabled: true, Key: key})
+	require.NoError(t, err)
+
+	encrypted, err := c.Encrypt("the launch date is 3 March")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "launch")
+
+	again, err := c.Encrypt("the launch date is 3 March")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again, "each encryption should use a new nonce")
+
+	plain, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "the launch date is 3 March", plain)
+
+	// Content stored before encryption was enabled is read as is
+	plain, err = c.Decrypt("plain content")
+	require.NoError(t, err)
+	assert.Equal(t, "plain content", plain)
+
+	// Another key can't read it
+	otherKey, err := GenerateContentKey()
+	require.NoError(t, err)
+	other, err := NewContentCipherFromConfig(&config.EncryptionConfig{Enabled: true, Key: otherKey})
+	require.NoError(t, err)
+	_, err = other.Decrypt(encrypted)
+	assert.Error(t, err)
+}
+
+func TestNewContentCipherFromConfig(t *testing.T) {
+	key, err := GenerateContentKey()
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "content.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(key+"\n"), 0600))
+	_, err = NewContentCipherFromConfig(&config.EncryptionConfig{Enabled: true, KeyFile: keyFile})
+	assert.NoError(t, err)
+
+	if runtime.GOOS != "windows" {
+		_, err = NewContentCipherFromConfig(&config.EncryptionConfig{Enabled: true, KeyCommand: "echo " + key})
+		assert.NoError(t, err)
+		_, err = NewContentCipherFromConfig(&config.EncryptionConfig{Enabled: true, KeyCommand: "exit 1"})
+		assert.Error(t, err)
+	}
+
+	_, err = NewContentCipherFromConfig(&config.EncryptionConfig{Enabled: true, Key: "not base64!"})
+	assert.Error(t, err)
+	_, err = NewContentCipherFromConfig(&config.EncryptionConfig{Enabled: true, Key: "c2hvcnQ="})
+	assert.Error(t, err, "keys must be 32 bytes")
+}
+
+func TestContentEncryption(t *testing.T) {
+	key, err := GenerateContentKey()
+	require.NoError(t, err)
+	t.Cleanup(func() { UseContentEncryption(nil) })
+
+	UseContentEncryption(&config.EncryptionConfig{Enabled: true, Key: key})
+	stored, err := encryptContent("secret plans")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(stored))
+
+	content := stored
+	require.NoError(t, decryptContent(&content))
+	assert.Equal(t, "secret plans", content)
+
+	// Without encryption, content is stored as is and encrypted content can't be read
+	UseContentEncryption(&config.EncryptionConfig{})
+	stored, err = encryptContent("public notes")
+	require.NoError(t, err)
+	assert.Equal(t, "public notes", stored)
+
+	content = "enc:v1:AAAA"
+	assert.ErrorIs(t, decryptContent(&content), ErrContentEncrypted)
+}
+
+func TestKeyedContentHash(t *testing.T) {
+	t.Cleanup(func() { UseContentEncryption(nil) })
+
+	// Without encryption, content hashes are plain
+	hash, err := KeyedContentHash([]byte("secret plans"))
+	require.NoError(t, err)
+	assert.Equal(t, ContentHash([]byte("secret plans")), hash)
+
+	// With encryption, they are keyed: the same for the same key, and
+	// different for another key or content
+	key, err := GenerateContentKey()
+	require.NoError(t, err)
+	UseContentEncryption(&config.EncryptionConfig{Enabled: true, Key: key})
+	keyed, err := KeyedContentHash([]byte("secret plans"))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, keyed)
+	assert.Len(t, keyed, 64)
+	again, err := KeyedContentHash([]byte("secret plans"))
+	require.NoError(t, err)
+	assert.Equal(t, keyed, again)
+	other, err := KeyedContentHash([]byte("public notes"))
+	require.NoError(t, err)
+	assert.NotEqual(t, keyed, other)
+
+	otherKey, err := GenerateContentKey()
+	require.NoError(t, err)
+	UseContentEncryption(&config.EncryptionConfig{Enabled: true, Key: otherKey})
+	otherKeyed, err := KeyedContentHash([]byte("secret plans"))
+	require.NoError(t, err)
+	assert.NotEqual(t, keyed, otherKeyed)
+
+	// Without the key, there is no hash rather than a plain one
+	UseContentEncryption(&config.EncryptionConfig{Enabled: true, Key: "not base64!"})
+	_, err = KeyedContentHash([]byte("secret plans"))
+	assert.Error(t, err)
+}
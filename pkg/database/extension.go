@@ -0,0 +1,120 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// VectorExtension is the name of the pgvector extension
+	VectorExtension = "vector"
+	// VectorScaleExtension is the name of the pgvectorscale extension, which provides diskann indexes
+	VectorScaleExtension = "vectorscale"
+	// TrigramExtension is the name of the pg_trgm extension, which provides trigram similarity
+	TrigramExtension = "pg_trgm"
+)
+
+var (
+	// ErrExtensionUnavailable is returned when an extension is not installed on the server
+	ErrExtensionUnavailable = errors.New("extension is not available on the database server")
+	// ErrExtensionPermission is returned when the user may not create an extension
+	ErrExtensionPermission = errors.New("insufficient privileges to create extension")
+)
+
+// extensionInstallURLs points users to installation instructions for each extension
+var extensionInstallURLs = map[string]string{
+	VectorExtension:      "https://github.com/pgvector/pgvector#installation",
+	VectorScaleExtension: "https://github.com/timescale/pgvectorscale#installation",
+	TrigramExtension:     "https://www.postgresql.org/docs/current/pgtrgm.html",
+}
+
+// ExtensionStatus describes whether an extension can be used in the current database
+type ExtensionStatus struct {
+	Name             string
+	Available        bool
+	DefaultVersion   string
+	Installed        bool
+	InstalledVersion string
+}
+
+// CheckExtension reports whether an extension is available on the server
+// and installed in the current database
+func CheckExtension(db *sql.DB, name string) (*ExtensionStatus, error) {
+	status := &ExtensionStatus{Name: name}
+
+	var defaultVersion, installedVersion sql.NullString
+	err := db.QueryRow(`
+		SELECT default_version, installed_version
+		FROM pg_available_extensions
+		WHERE name = $1
+	`, name).Scan(&defaultVersion, &installedVersion)
+	if err == sql.ErrNoRows {
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s extension: %w", name, err)
+	}
+
+	status.Available = true
+	status.DefaultVersion = defaultVersion.String
+	status.Installed = installedVersion.Valid
+	status.InstalledVersion = installedVersion.String
+
+	return status, nil
+}
+
+// CheckVectorExtension reports the status of the pgvector extension
+func CheckVectorExtension(db *sql.DB) (*ExtensionStatus, error) {
+	return CheckExtension(db, VectorExtension)
+}
+
+// ExtensionInstallURL returns installation instructions for an extension
+func ExtensionInstallURL(name string) string {
+	if url, ok := extensionInstallURLs[name]; ok {
+		return url
+	}
+	return "https://www.postgresql.org/docs/current/external-extensions.html"
+}
+
+// ensureExtension creates an extension (and the extensions it depends on)
+// if it is not installed yet
+func ensureExtension(tx *sql.Tx, name string) error {
+	var installed bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = $1)`, name).Scan(&installed)
+	if err != nil {
+		return fmt.Errorf("failed to check %s extension: %w", name, err)
+	}
+	if installed {
+		return nil
+	}
+
+	// Extension names can't be bound as parameters
+	query := fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s CASCADE", pq.QuoteIdentifier(name))
+	if _, err := tx.Exec(query); err != nil {
+		return extensionError(name, err)
+	}
+
+	return nil
+}
+
+// extensionError turns a CREATE EXTENSION failure into an actionable error
+func extensionError(name string, err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return fmt.Errorf("failed to create %s extension: %w", name, err)
+	}
+
+	switch pqErr.Code {
+	case "42501": // insufficient_privilege
+		return fmt.Errorf("%w %s: ask a database superuser to run 'CREATE EXTENSION IF NOT EXISTS %s CASCADE;' "+
+			"in the rag-cli database, then try again (%s)", ErrExtensionPermission, name, name, pqErr.Message)
+	case "58P01", "0A000": // undefined_file, feature_not_supported
+		return fmt.Errorf("%w: install %s on the server (see %s), then try again (%s)",
+			ErrExtensionUnavailable, name, ExtensionInstallURL(name), pqErr.Message)
+	default:
+		return fmt.Errorf("failed to create %s extension: %w", name, err)
+	}
+}
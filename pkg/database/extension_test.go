@@ -0,0 +1,26 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtensionError(t *testing.T) {
+	err := extensionError(VectorExtension, &pq.Error{Code: "42501", Message: "permission denied to create extension \"vector\""})
+	assert.ErrorIs(t, err, ErrExtensionPermission)
+	assert.Contains(t, err.Error(), "CREATE EXTENSION IF NOT EXISTS vector CASCADE")
+
+	err = extensionError(VectorExtension, &pq.Error{Code: "58P01", Message: "could not open extension control file"})
+	assert.ErrorIs(t, err, ErrExtensionUnavailable)
+
+	err = extensionError(VectorExtension, &pq.Error{Code: "0A000", Message: "extension \"vector\" is not available"})
+	assert.ErrorIs(t, err, ErrExtensionUnavailable)
+
+	other := errors.New("connection reset")
+	err = extensionError(VectorExtension, other)
+	assert.ErrorIs(t, err, other)
+	assert.NotErrorIs(t, err, ErrExtensionPermission)
+}
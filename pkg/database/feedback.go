@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Judgment is a user's judgment of whether a file found for a query is
+// relevant to it
+type Judgment struct {
+	ID           int64     `json:"id"`
+	CollectionID string    `json:"collection_id"`
+	Query        string    `json:"query"`
+	Folder       string    `json:"folder"`    // Collection folder of the file
+	FilePath     string    `json:"file_path"` // Path relative to the folder
+	Relevant     bool      `json:"relevant"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// FeedbackManagerImpl implements FeedbackManager
+type FeedbackManagerImpl struct {
+	db *sql.DB
+}
+
+// NewFeedbackManager creates a new feedback manager
+func NewFeedbackManager(db *sql.DB) FeedbackManager {
+	return &FeedbackManagerImpl{db: db}
+}
+
+// AddJudgment stores whether the file of a folder is relevant to a query. A
+// later judgment of the same file for the same query replaces the earlier
+// one.
+func (fm *FeedbackManagerImpl) AddJudgment(collectionID, query, folder, filePath string, relevant bool) error {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return fmt.Errorf("judgment query cannot be empty")
+	}
+
+	_, err := fm.db.Exec(`
+		INSERT INTO search_feedback (collection_id, query, folder, file_path, relevant)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (collection_id, query, folder, file_path)
+		DO UPDATE SET relevant = EXCLUDED.relevant, created_at = NOW()
+	`, collectionID, query, folder, filePath, relevant)
+	if err != nil {
+		return fmt.Errorf("failed to add judgment: %w", err)
+	}
+
+	return nil
+}
+
+// ListJudgments returns the judgments collected for a collection, grouped
+// by query
+func (fm *FeedbackManagerImpl) ListJudgments(collectionID string) ([]*Judgment, error) {
+	rows, err := fm.db.Query(`
+		SELECT id, collection_id, query, folder, file_path, relevant, created_at
+		FROM search_feedback
+		WHERE collection_id = $1
+		ORDER BY query, id
+	`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list judgments: %w", err)
+	}
+	defer rows.Close()
+
+	var judgments []*Judgment
+	for rows.Next() {
+		judgment := &Judgment{}
+		if err := rows.Scan(&judgment.ID, &judgment.CollectionID, &judgment.Query, &judgment.Folder, &judgment.FilePath, &judgment.Relevant, &judgment.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan judgment: %w", err)
+		}
+		judgments = append(judgments, judgment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read judgments: %w", err)
+	}
+
+	return judgments, nil
+}
+
+// ForgetJudgments deletes the judgments of a collection and returns how
+// many there were
+func (fm *FeedbackManagerImpl) ForgetJudgments(collectionID string) (int, error) {
+	result, err := fm.db.Exec(`DELETE FROM search_feedback WHERE collection_id = $1`, collectionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to forget judgments: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to forget judgments: %w", err)
+	}
+	return int(deleted), nil
+}
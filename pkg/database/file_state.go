@@ -0,0 +1,121 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// FileState is what a file looked like when it was last indexed
+type FileState struct {
+	Folder      string    // Collection folder the file is in
+	FilePath    string    // Path relative to the folder, with / separators
+	Size        int64     // Size in bytes
+	ModifiedAt  time.Time // Modification time
+	ContentHash string    // Hash of the content (see KeyedContentHash)
+}
+
+// ContentHash returns the hash of a file's content stored in its state
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// SameStat reports whether a file of the given size and modification time
+// looks unchanged since it was indexed, without reading it. Times are
+// compared to the microsecond, the precision of the database.
+func (s *FileState) SameStat(size int64, modTime time.Time) bool {
+	return s.Size == size && s.ModifiedAt.Truncate(time.Microsecond).Equal(modTime.Truncate(time.Microsecond))
+}
+
+// FileStateManagerImpl implements FileStateManager
+type FileStateManagerImpl struct {
+	db *sql.DB
+}
+
+// NewFileStateManager creates a new file state manager
+func NewFileStateManager(db *sql.DB) FileStateManager {
+	return &FileStateManagerImpl{db: db}
+}
+
+// ListFileStates returns the states of the indexed files of a collection
+// folder by their path. Files whose chunks have been removed since they were
+// indexed are left out, so they are indexed again.
+func (fm *FileStateManagerImpl) ListFileStates(collectionID, folder string) (map[string]*FileState, error) {
+	rows, err := fm.db.Query(`
+		SELECT f.folder, f.file_path, f.size, f.modified_at, f.content_hash
+		FROM files f
+		WHERE f.collection_id = $1 AND f.folder = $2
+		  AND EXISTS (
+			SELECT 1 FROM documents d
+			WHERE d.collection_id = f.collection_id AND d.folder = f.folder AND d.file_path = f.file_path
+		  )
+	`, collectionID, folder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file states: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[string]*FileState)
+	for rows.Next() {
+		state := &FileState{}
+		if err := rows.Scan(&state.Folder, &state.FilePath, &state.Size, &state.ModifiedAt, &state.ContentHash); err != nil {
+			return nil, fmt.Errorf("failed to scan file state: %w", err)
+		}
+		states[state.FilePath] = state
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over file states: %w", err)
+	}
+
+	return states, nil
+}
+
+// SaveFileState records the state of a file that was indexed
+func (fm *FileStateManagerImpl) SaveFileState(collectionID string, state *FileState) error {
+	_, err := fm.db.Exec(`
+		INSERT INTO files (collection_id, folder, file_path, size, modified_at, content_hash, indexed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (collection_id, folder, file_path)
+		DO UPDATE SET
+			size = EXCLUDED.size,
+			modified_at = EXCLUDED.modified_at,
+			content_hash = EXCLUDED.content_hash,
+			indexed_at = NOW()
+	`, collectionID, state.Folder, state.FilePath, state.Size, state.ModifiedAt, state.ContentHash)
+	if err != nil {
+		return fmt.Errorf("failed to save file state: %w", err)
+	}
+	return nil
+}
+
+// TouchFile records the new modification time of a file whose content hasn't
+// changed since it was indexed, and marks its chunks as updated, so they
+// aren't reported as stale
+func (fm *FileStateManagerImpl) TouchFile(collectionID string, state *FileState) error {
+	tx, err := fm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE files SET size = $4, modified_at = $5
+		WHERE collection_id = $1 AND folder = $2 AND file_path = $3
+	`, collectionID, state.Folder, state.FilePath, state.Size, state.ModifiedAt); err != nil {
+		return fmt.Errorf("failed to update file state: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE documents SET updated_at = NOW()
+		WHERE collection_id = $1 AND folder = $2 AND file_path = $3
+	`, collectionID, state.Folder, state.FilePath); err != nil {
+		return fmt.Errorf("failed to update documents: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit file state: %w", err)
+	}
+	return nil
+}
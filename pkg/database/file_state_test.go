@@ -0,0 +1,25 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentHash(t *testing.T) {
+	assert.Equal(t, ContentHash([]byte("# Title\n")), ContentHash([]byte("# Title\n")))
+	assert.NotEqual(t, ContentHash([]byte("# Title\n")), ContentHash([]byte("# Title \n")))
+	assert.Len(t, ContentHash(nil), 64)
+}
+
+func TestFileStateSameStat(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := &FileState{FilePath: "guide.md", Size: 120, ModifiedAt: modified}
+
+	// Sub-microsecond differences are lost in the database
+	assert.True(t, state.SameStat(120, modified.Add(500*time.Nanosecond)))
+	assert.True(t, state.SameStat(120, modified.In(time.FixedZone("CET", 3600))))
+	assert.False(t, state.SameStat(121, modified))
+	assert.False(t, state.SameStat(120, modified.Add(time.Second)))
+}
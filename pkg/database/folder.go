@@ -0,0 +1,96 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FolderMatch controls which documents count as being in a folder
+type FolderMatch string
+
+const (
+	FolderMatchRecursive FolderMatch = "recursive" // Files in the folder and all of its subfolders
+	FolderMatchExact     FolderMatch = "exact"     // Only files directly in the folder
+)
+
+// NormalizePath returns the absolute, cleaned form of a file or folder path,
+// so the same folder is always stored and matched the same way no matter
+// which directory a command was run from or how the path was written
+func NormalizePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	return abs, nil
+}
+
+// SamePath reports whether two normalized paths refer to the same file or
+// folder. Paths are compared case-insensitively on Windows, where the file
+// system (and drive letters) are case-insensitive.
+func SamePath(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// ContainsPath reports whether path is the folder itself or inside it. Both
+// paths are expected to be normalized. Like SamePath it is case-insensitive
+// on Windows, and paths on different drives are never inside each other.
+func ContainsPath(folder, path string) bool {
+	rel, err := filepath.Rel(folder, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// RelativePath returns the path of a file relative to the folder it is in,
+// with / as the separator on every platform. Both paths are expected to be
+// normalized.
+func RelativePath(folder, path string) (string, error) {
+	if !ContainsPath(folder, path) {
+		return "", fmt.Errorf("%s is not in folder %s", path, folder)
+	}
+	rel, err := filepath.Rel(folder, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to make %s relative to %s: %w", path, folder, err)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// FolderScope selects the documents in a collection folder or in one of its subfolders
+type FolderScope struct {
+	Folder    string      // Collection folder, as stored in the collection
+	Subfolder string      // Path of a subfolder relative to the folder, empty for the folder itself
+	Match     FolderMatch // Whether files in nested subfolders are included
+}
+
+// folderCondition returns a SQL condition matching the documents in the
+// scope, and the arguments it takes starting at argIndex. The subfolder is
+// escaped so LIKE wildcards in it match literally, and the trailing
+// separator keeps similarly named sibling folders (docs-old for docs) from
+// matching.
+func folderCondition(scope FolderScope, argIndex int) (string, []interface{}) {
+	condition := fmt.Sprintf("folder = $%d", argIndex)
+	args := []interface{}{scope.Folder}
+
+	prefix := ""
+	if subfolder := strings.Trim(scope.Subfolder, "/"); subfolder != "" {
+		prefix = likeEscaper.Replace(subfolder) + "/"
+		condition += fmt.Sprintf(" AND file_path LIKE $%d", argIndex+len(args))
+		args = append(args, prefix+"%")
+	}
+
+	if scope.Match == FolderMatchExact {
+		condition += fmt.Sprintf(" AND file_path NOT LIKE $%d", argIndex+len(args))
+		args = append(args, prefix+"%/%")
+	}
+
+	return condition, args
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// FolderCopy is the index of a folder copied from another collection
+type FolderCopy struct {
+	CollectionID   string // Collection the index was copied from
+	CollectionName string
+	Files          int64 // Indexed files copied
+	Chunks         int64 // Chunks copied with their embeddings
+}
+
+// CopyIndexedFolder copies the chunks, embeddings and file states of a
+// folder from another collection that indexed it with the same embedding
+// model, so a folder added to a second collection is searchable at once
+// instead of being read and embedded again. Only a collection with the same
+// metadata schema and normalization is copied from, as its chunks are then
+// exactly those indexing would store, and the most recently indexed one is
+// chosen. The collection takes on the model and dimensions of the copied
+// embeddings when it has none yet. It returns nil when no collection has
+// indexed the folder with the model.
+func (dm *DocumentManagerImpl) CopyIndexedFolder(collectionID, folder, model string) (*FolderCopy, error) {
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A collection already indexed with another model or dimensions only
+	// copies embeddings it can search with
+	var dimensions sql.NullInt64
+	err = tx.QueryRow(`SELECT dimensions FROM embedding_config WHERE collection_id = $1 AND model_name = $2`, collectionID, model).Scan(&dimensions)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get embedding config: %w", err)
+	}
+	if err == sql.ErrNoRows {
+		var other int
+		err = tx.QueryRow(`SELECT COUNT(*) FROM embedding_config WHERE collection_id = $1`, collectionID).Scan(&other)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get embedding config: %w", err)
+		}
+		if other > 0 {
+			return nil, nil
+		}
+	}
+
+	source := &FolderCopy{}
+	var sourceDimensions int
+	err = tx.QueryRow(`
+		SELECT s.id, s.name, e.dimensions
+		FROM collections s
+		JOIN embedding_config e ON e.collection_id = s.id
+		JOIN collections t ON t.id = $1
+		JOIN files f ON f.collection_id = s.id AND f.folder = $2
+		WHERE s.id <> $1 AND e.model_name = $3 AND ($4::INTEGER IS NULL OR e.dimensions = $4)
+			AND s.metadata_schema = t.metadata_schema
+			AND s.normalized = t.normalized
+		GROUP BY s.id, s.name, e.dimensions
+		ORDER BY MAX(f.indexed_at) DESC
+		LIMIT 1`,
+		collectionID, folder, model, dimensions).Scan(&source.CollectionID, &source.CollectionName, &sourceDimensions)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find collection to copy from: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO documents (collection_id, folder, file_path, file_name, content, chunk_index, embedding, metadata, file_type, symbols, path_embedding, token_count, content_hash)
+		SELECT $1, folder, file_path, file_name, content, chunk_index, embedding, metadata, file_type, symbols, path_embedding, token_count, content_hash
+		FROM documents
+		WHERE collection_id = $2 AND folder = $3
+		ON CONFLICT DO NOTHING`,
+		collectionID, source.CollectionID, folder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy chunks: %w", err)
+	}
+	if source.Chunks, err = result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to count copied chunks: %w", err)
+	}
+
+	// The file states let the next index run skip the files that haven't
+	// changed since the other collection indexed them
+	result, err = tx.Exec(`
+		INSERT INTO files (collection_id, folder, file_path, size, modified_at, content_hash, indexed_at)
+		SELECT $1, folder, file_path, size, modified_at, content_hash, indexed_at
+		FROM files
+		WHERE collection_id = $2 AND folder = $3
+		ON CONFLICT DO NOTHING`,
+		collectionID, source.CollectionID, folder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy file states: %w", err)
+	}
+	if source.Files, err = result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to count copied file states: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO embedding_config (collection_id, dimensions, model_name, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (collection_id) DO NOTHING`,
+		collectionID, sourceDimensions, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set embedding config: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit folder copy: %w", err)
+	}
+	return source, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePath(t *testing.T) {
+	wd, err := filepath.Abs(".")
+	require.NoError(t, err)
+
+	normalized, err := NormalizePath("./docs/../docs/")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(wd, "docs"), normalized)
+
+	same, err := NormalizePath("docs")
+	require.NoError(t, err)
+	assert.Equal(t, normalized, same)
+}
+
+func TestContainsPath(t *testing.T) {
+	docs := filepath.FromSlash("/repo/docs")
+
+	assert.True(t, ContainsPath(docs, docs))
+	assert.True(t, ContainsPath(docs, filepath.FromSlash("/repo/docs/README.md")))
+	assert.True(t, ContainsPath(docs, filepath.FromSlash("/repo/docs/api/v1.md")))
+	assert.False(t, ContainsPath(docs, filepath.FromSlash("/repo/docs-old/README.md")))
+	assert.False(t, ContainsPath(docs, filepath.FromSlash("/repo/README.md")))
+	assert.False(t, ContainsPath(docs, filepath.FromSlash("/repo/..docs/README.md")))
+}
+
+func TestRelativePath(t *testing.T) {
+	docs := filepath.FromSlash("/repo/docs")
+
+	rel, err := RelativePath(docs, filepath.FromSlash("/repo/docs/api/v1.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "api/v1.md", rel)
+
+	rel, err = RelativePath(docs, docs)
+	require.NoError(t, err)
+	assert.Equal(t, "", rel)
+
+	_, err = RelativePath(docs, filepath.FromSlash("/repo/docs-old/README.md"))
+	assert.Error(t, err)
+}
+
+func TestFolderCondition(t *testing.T) {
+	condition, args := folderCondition(FolderScope{Folder: "/repo/docs", Match: FolderMatchRecursive}, 2)
+	assert.Equal(t, "folder = $2", condition)
+	assert.Equal(t, []interface{}{"/repo/docs"}, args)
+
+	condition, args = folderCondition(FolderScope{Folder: "/repo/docs", Match: FolderMatchExact}, 2)
+	assert.Equal(t, "folder = $2 AND file_path NOT LIKE $3", condition)
+	assert.Equal(t, []interface{}{"/repo/docs", "%/%"}, args)
+
+	condition, args = folderCondition(FolderScope{Folder: "/repo", Subfolder: "my_docs/", Match: FolderMatchRecursive}, 2)
+	assert.Equal(t, "folder = $2 AND file_path LIKE $3", condition)
+	assert.Equal(t, []interface{}{"/repo", `my\_docs/%`}, args)
+
+	condition, args = folderCondition(FolderScope{Folder: "/repo", Subfolder: "docs/api", Match: FolderMatchExact}, 3)
+	assert.Equal(t, "folder = $3 AND file_path LIKE $4 AND file_path NOT LIKE $5", condition)
+	assert.Equal(t, []interface{}{"/repo", "docs/api/%", "docs/api/%/%"}, args)
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowsPaths(t *testing.T) {
+	assert.True(t, SamePath(`C:\Users\Alice\Docs`, `c:\users\alice\docs`))
+	assert.True(t, ContainsPath(`C:\Users\Alice\Docs`, `c:\users\alice\docs\api\v1.md`))
+	assert.False(t, ContainsPath(`C:\Docs`, `D:\Docs\README.md`))
+
+	rel, err := RelativePath(`C:\Users\Alice\Docs`, `C:\Users\Alice\Docs\api\v1.md`)
+	require.NoError(t, err)
+	assert.Equal(t, "api/v1.md", rel)
+
+	normalized, err := NormalizePath(`C:\Users\Alice\Docs\..\Docs\`)
+	require.NoError(t, err)
+	assert.Equal(t, `C:\Users\Alice\Docs`, normalized)
+}
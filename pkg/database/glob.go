@@ -0,0 +1,88 @@
+package database
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// likeEscaper escapes the characters that are special in a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GlobToLike translates a file name glob into a LIKE pattern. * matches any
+// run of characters and ? matches a single character; everything else,
+// including LIKE's own % and _, matches literally. Patterns without
+// wildcards match file names that contain them, so "readme" still finds
+// README.md with ILIKE.
+func GlobToLike(glob string) string {
+	if !strings.ContainsAny(glob, "*?") {
+		return "%" + likeEscaper.Replace(glob) + "%"
+	}
+
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		default:
+			b.WriteString(likeEscaper.Replace(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// PathGlobToRegex translates a path glob into an anchored regular expression
+// matching file paths with / separators. * and ? match within a path
+// segment, ** matches across segments and **/ matches zero or more
+// directories. Globs without a / match file names at any depth, so
+// "*.min.js" matches "static/app.min.js".
+func PathGlobToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteByte('^')
+	if !strings.Contains(glob, "/") {
+		b.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteByte('$')
+	return b.String()
+}
+
+// FileType returns the file type of a file name: its extension, lower case
+// and without the dot, or "" when it has none
+func FileType(fileName string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+}
+
+// ParseFileTypes parses file types such as "md,.GO, txt" into the form
+// FileType returns, dropping empty and repeated ones
+func ParseFileTypes(values []string) []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, t := range strings.Split(value, ",") {
+			t = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(t), "."))
+			if t != "" && !seen[t] {
+				seen[t] = true
+				types = append(types, t)
+			}
+		}
+	}
+	return types
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobToLike(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"*.md", "%.md"},
+		{"*coll*.go", "%coll%.go"},
+		{"file?.txt", "file_.txt"},
+		{"README.md", "%README.md%"},
+		{"readme", "%readme%"},
+		{"my_file*.go", `my\_file%.go`},
+		{"100%", `%100\%%`},
+		{`back\slash*`, `back\\slash%`},
+		{"", "%%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			assert.Equal(t, tt.want, GlobToLike(tt.glob))
+		})
+	}
+}
+
+func TestPathGlobToRegex(t *testing.T) {
+	tests := []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{"**/vendor/**", "vendor/lib/a.go", true},
+		{"**/vendor/**", "src/vendor/lib/a.go", true},
+		{"**/vendor/**", "src/vendored/a.go", false},
+		{"docs/*.md", "docs/README.md", true},
+		{"docs/*.md", "docs/api/README.md", false},
+		{"docs/**/*.md", "docs/README.md", true},
+		{"docs/**/*.md", "docs/api/v1/README.md", true},
+		{"*.min.js", "static/app.min.js", true},
+		{"*.min.js", "static/app.js", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file/.txt", false},
+		{"a+b(c).txt", "a+b(c).txt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.path, func(t *testing.T) {
+			re := regexp.MustCompile(PathGlobToRegex(tt.glob))
+			assert.Equal(t, tt.matches, re.MatchString(tt.path))
+		})
+	}
+}
+
+func TestFileType(t *testing.T) {
+	assert.Equal(t, "md", FileType("README.MD"))
+	assert.Equal(t, "gz", FileType("backup.tar.gz"))
+	assert.Equal(t, "env", FileType(".env"))
+	assert.Equal(t, "", FileType("Makefile"))
+}
+
+func TestParseFileTypes(t *testing.T) {
+	assert.Equal(t, []string{"md", "go", "txt"}, ParseFileTypes([]string{"md,.GO", " txt ,md", ""}))
+	assert.Nil(t, ParseFileTypes(nil))
+}
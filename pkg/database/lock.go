@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/lib/pq"
+)
+
+// ErrCollectionLocked is returned by TryLockCollection when another process
+// holds the lock of the collection
+var ErrCollectionLocked = errors.New("collection is locked by another process")
+
+// ErrCollectionLockLost is returned by CollectionLock.Check when the
+// connection holding the lock ended, and with it the lock
+var ErrCollectionLockLost = errors.New("lost the lock of the collection")
+
+// CollectionLock is a PostgreSQL advisory lock on a collection, held while
+// its documents are changed so that two index runs, or an index run and a
+// removal, don't interleave their deletes and inserts of the same files.
+// The lock is held by a connection of its own, outside the pool the command
+// uses, so a pool of one connection doesn't wait on itself. PostgreSQL
+// releases the lock when that connection ends, so a crashed process doesn't
+// leave it behind; the connection is never replaced, so a lost lock is
+// reported by Check instead of going unnoticed.
+type CollectionLock struct {
+	db   *sql.DB
+	conn *sql.Conn
+	key  int64
+}
+
+// LockCollection takes the lock of a collection on the database of dsn,
+// waiting until any other holder releases it or ctx is done
+func LockCollection(ctx context.Context, dsn string, collectionID string) (*CollectionLock, error) {
+	lock, err := openCollectionLock(ctx, dsn, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := lock.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lock.key); err != nil {
+		lock.close()
+		return nil, fmt.Errorf("failed to lock collection: %w", err)
+	}
+	return lock, nil
+}
+
+// TryLockCollection takes the lock of a collection if no one else holds it,
+// and returns ErrCollectionLocked otherwise
+func TryLockCollection(ctx context.Context, dsn string, collectionID string) (*CollectionLock, error) {
+	lock, err := openCollectionLock(ctx, dsn, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked bool
+	if err := lock.conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lock.key).Scan(&locked); err != nil {
+		lock.close()
+		return nil, fmt.Errorf("failed to lock collection: %w", err)
+	}
+	if !locked {
+		lock.close()
+		return nil, ErrCollectionLocked
+	}
+	return lock, nil
+}
+
+// openCollectionLock opens the connection that holds the lock of a collection
+func openCollectionLock(ctx context.Context, dsn string, collectionID string) (*CollectionLock, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock collection: %w", err)
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(1)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to lock collection: %w", err)
+	}
+	return &CollectionLock{db: db, conn: conn, key: collectionLockKey(collectionID)}, nil
+}
+
+// Check returns ErrCollectionLockLost unless the lock is still held, for
+// callers to check before committing changes made under it
+func (l *CollectionLock) Check(ctx context.Context) error {
+	// Advisory locks on a bigint key are listed with its high and low halves
+	var held bool
+	err := l.conn.QueryRowContext(ctx, `SELECT EXISTS(
+		SELECT 1 FROM pg_locks
+		WHERE locktype = 'advisory' AND pid = pg_backend_pid() AND granted
+		AND classid = $1 AND objid = $2 AND objsubid = 1)`,
+		int64(uint64(l.key)>>32), int64(uint32(l.key))).Scan(&held)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCollectionLockLost, err)
+	}
+	if !held {
+		return ErrCollectionLockLost
+	}
+	return nil
+}
+
+// Unlock releases the lock and its connection
+func (l *CollectionLock) Unlock() error {
+	defer l.close()
+	if _, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+		return fmt.Errorf("failed to unlock collection: %w", err)
+	}
+	return nil
+}
+
+// close closes the connection of the lock, which releases it if held
+func (l *CollectionLock) close() {
+	l.conn.Close()
+	l.db.Close()
+}
+
+// collectionLockKey returns the advisory lock key of a collection. Keys are
+// shared by every application of the database, so the collection ID is
+// hashed with a prefix of this one.
+func collectionLockKey(collectionID string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("rag-cli:collection:" + collectionID))
+	return int64(h.Sum64())
+}
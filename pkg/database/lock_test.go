@@ -0,0 +1,13 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionLockKey(t *testing.T) {
+	key := collectionLockKey("6f1c2a9e-0000-4000-8000-000000000001")
+	assert.Equal(t, key, collectionLockKey("6f1c2a9e-0000-4000-8000-000000000001"))
+	assert.NotEqual(t, key, collectionLockKey("6f1c2a9e-0000-4000-8000-000000000002"))
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrMemoryNotFound is returned when a memory doesn't exist
+var ErrMemoryNotFound = errors.New("memory not found")
+
+// Memory kinds
+const (
+	MemoryKindFact  = "fact"  // Something the user confirmed about themselves or their work
+	MemoryKindStyle = "style" // How the user prefers answers, e.g. short with code examples
+)
+
+// Memory is something the user asked chat to remember, which is given to the
+// chat model in the later chat sessions with the same collection
+type Memory struct {
+	ID             int64     `json:"id"`
+	CollectionID   string    `json:"collection_id"`
+	CollectionName string    `json:"collection_name"`
+	Kind           string    `json:"kind"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// MemoryManagerImpl implements MemoryManager
+type MemoryManagerImpl struct {
+	db *sql.DB
+}
+
+// NewMemoryManager creates a new memory manager
+func NewMemoryManager(db *sql.DB) MemoryManager {
+	return &MemoryManagerImpl{db: db}
+}
+
+// AddMemory remembers something for the chat sessions with a collection. A
+// memory that is already remembered is returned as it is.
+func (mm *MemoryManagerImpl) AddMemory(collectionID, kind, content string) (*Memory, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, fmt.Errorf("memory cannot be empty")
+	}
+	if kind != MemoryKindFact && kind != MemoryKindStyle {
+		return nil, fmt.Errorf("invalid memory kind: %s. Must be '%s' or '%s'", kind, MemoryKindFact, MemoryKindStyle)
+	}
+
+	memory := &Memory{CollectionID: collectionID, Kind: kind, Content: content}
+	err := mm.db.QueryRow(`
+		WITH added AS (
+			INSERT INTO chat_memories (collection_id, kind, content)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (collection_id, kind, content) DO UPDATE SET content = EXCLUDED.content
+			RETURNING id, created_at
+		)
+		SELECT added.id, added.created_at, c.name
+		FROM added, collections c
+		WHERE c.id = $1
+	`, collectionID, kind, content).Scan(&memory.ID, &memory.CreatedAt, &memory.CollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add memory: %w", err)
+	}
+
+	return memory, nil
+}
+
+// ListMemories returns the memories of a collection, or of every collection
+// when collectionID is empty, oldest first
+func (mm *MemoryManagerImpl) ListMemories(collectionID string) ([]*Memory, error) {
+	rows, err := mm.db.Query(`
+		SELECT m.id, m.collection_id, c.name, m.kind, m.content, m.created_at
+		FROM chat_memories m
+		JOIN collections c ON c.id = m.collection_id
+		WHERE $1 = '' OR m.collection_id::text = $1
+		ORDER BY c.name, m.id
+	`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+	defer rows.Close()
+
+	var memories []*Memory
+	for rows.Next() {
+		memory := &Memory{}
+		if err := rows.Scan(&memory.ID, &memory.CollectionID, &memory.CollectionName, &memory.Kind, &memory.Content, &memory.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan memory: %w", err)
+		}
+		memories = append(memories, memory)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read memories: %w", err)
+	}
+
+	return memories, nil
+}
+
+// ForgetMemory deletes a memory
+func (mm *MemoryManagerImpl) ForgetMemory(id int64) error {
+	result, err := mm.db.Exec(`DELETE FROM chat_memories WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to forget memory: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to forget memory: %w", err)
+	}
+	if deleted == 0 {
+		return ErrMemoryNotFound
+	}
+	return nil
+}
+
+// ForgetMemories deletes the memories of a collection and returns how many
+// there were
+func (mm *MemoryManagerImpl) ForgetMemories(collectionID string) (int, error) {
+	result, err := mm.db.Exec(`DELETE FROM chat_memories WHERE collection_id = $1`, collectionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to forget memories: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to forget memories: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// MemoryPrompt returns the instructions giving memories to the chat model,
+// or an empty string when there are none
+func MemoryPrompt(memories []*Memory) string {
+	var facts, styles []string
+	for _, memory := range memories {
+		if memory.Kind == MemoryKindStyle {
+			styles = append(styles, "- "+memory.Content)
+		} else {
+			facts = append(facts, "- "+memory.Content)
+		}
+	}
+
+	var parts []string
+	if len(facts) > 0 {
+		parts = append(parts, "The user confirmed these facts in earlier conversations; rely on them when they are relevant:\n"+strings.Join(facts, "\n"))
+	}
+	if len(styles) > 0 {
+		parts = append(parts, "The user prefers answers like this:\n"+strings.Join(styles, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
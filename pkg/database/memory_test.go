@@ -0,0 +1,25 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPrompt(t *testing.T) {
+	assert.Empty(t, MemoryPrompt(nil))
+
+	prompt := MemoryPrompt([]*Memory{
+		{Kind: MemoryKindFact, Content: "We deploy with Argo CD"},
+		{Kind: MemoryKindStyle, Content: "Short answers with examples"},
+		{Kind: MemoryKindFact, Content: "Production runs in eu-west-1"},
+	})
+	assert.Equal(t, `The user confirmed these facts in earlier conversations; rely on them when they are relevant:
+- We deploy with Argo CD
+- Production runs in eu-west-1
+
+The user prefers answers like this:
+- Short answers with examples`, prompt)
+
+	assert.NotContains(t, MemoryPrompt([]*Memory{{Kind: MemoryKindStyle, Content: "Short"}}), "facts")
+}
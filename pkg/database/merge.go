@@ -0,0 +1,35 @@
+package database
+
+import "sort"
+
+// MergeByEmbedding scores the extra results, which aren't stored in a
+// collection, by the cosine similarity of their embeddings to the query
+// embedding, and ranks them together with the retrieved results by combined
+// score. Only the top limit results that fit in maxTokens together are kept;
+// maxTokens 0 keeps them all.
+func MergeByEmbedding(results, extra []*SearchResult, embedding []float32, limit, maxTokens int) []*SearchResult {
+	query := &Document{Embedding: embedding}
+	merged := append([]*SearchResult(nil), results...)
+	for _, result := range extra {
+		score := embeddingSimilarity(query, result.Document)
+		merged = append(merged, &SearchResult{
+			Document:      result.Document,
+			VectorScore:   score,
+			CombinedScore: score,
+		})
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].CombinedScore > merged[j].CombinedScore
+	})
+
+	if maxTokens > 0 {
+		merged = FitTokenBudget(merged, maxTokens)
+	}
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	for i, result := range merged {
+		result.Rank = i + 1
+	}
+	return merged
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeByEmbedding(t *testing.T) {
+	retrieved := func() []*SearchResult {
+		return []*SearchResult{
+			{Document: &Document{ID: "install", Content: "install"}, CombinedScore: 0.75},
+			{Document: &Document{ID: "upgrade", Content: "upgrade"}, CombinedScore: 0.4},
+		}
+	}
+	extra := []*SearchResult{
+		{Document: &Document{ID: "draft#0", Content: "draft", Embedding: []float32{1, 0}}},
+		{Document: &Document{ID: "draft#1", Content: "draft", Embedding: []float32{0, 1}}},
+	}
+	ids := func(results []*SearchResult) []string {
+		var ids []string
+		for i, result := range results {
+			assert.Equal(t, i+1, result.Rank)
+			ids = append(ids, result.Document.ID)
+		}
+		return ids
+	}
+	embedding := []float32{0.6, 0.8}
+
+	// The provided chunks are ranked among the retrieved results by
+	// similarity to the query
+	merged := MergeByEmbedding(retrieved(), extra, embedding, 10, 0)
+	assert.Equal(t, []string{"draft#1", "install", "draft#0", "upgrade"}, ids(merged))
+	assert.InDelta(t, 0.8, merged[0].VectorScore, 1e-6)
+
+	// The limit applies to all of them
+	assert.Equal(t, []string{"draft#1", "install"}, ids(MergeByEmbedding(retrieved(), extra, embedding, 2, 0)))
+
+	// So does the token budget
+	extra[1].Document.Content = strings.Repeat("long draft ", 100)
+	assert.Equal(t, []string{"install", "draft#0", "upgrade"}, ids(MergeByEmbedding(retrieved(), extra, embedding, 10, 10)))
+
+	// The provided results themselves aren't changed
+	assert.Zero(t, extra[0].CombinedScore)
+}
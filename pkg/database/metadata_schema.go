@@ -0,0 +1,396 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetadataType is the type of a custom metadata field
+type MetadataType string
+
+const (
+	MetadataTypeString MetadataType = "string"
+	MetadataTypeNumber MetadataType = "number"
+	MetadataTypeBool   MetadataType = "bool"
+	MetadataTypeDate   MetadataType = "date" // Written as 2006-01-02
+)
+
+// metadataDateLayout is the layout of date metadata values
+const metadataDateLayout = "2006-01-02"
+
+// metadataFieldPattern matches the names allowed for custom metadata fields
+var metadataFieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedMetadataFields are the metadata fields set by indexing itself
+var reservedMetadataFields = map[string]bool{
+	"file_path":     true,
+	"file_name":     true,
+	"file_size":     true,
+	"file_modified": true,
+	"overlap":       true,
+	"page":          true,
+	"slide":         true,
+	"chapter":       true,
+	"heading":       true,
+}
+
+// MetadataField is a custom metadata field expected in a collection's
+// documents, read from their front matter when they are indexed.
+//
+// Fields are written as name:type[:required], for example:
+//
+//	priority:number:required
+//	owner:string
+//	reviewed:date
+type MetadataField struct {
+	Name     string       `json:"name"`
+	Type     MetadataType `json:"type"`
+	Required bool         `json:"required"`
+}
+
+// MetadataSchema is the set of custom metadata fields of a collection
+type MetadataSchema []MetadataField
+
+// ParseMetadataField parses a metadata field from its name:type[:required] form
+func ParseMetadataField(spec string) (MetadataField, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return MetadataField{}, fmt.Errorf("invalid metadata field %q: expected name:type[:required]", spec)
+	}
+
+	field := MetadataField{Name: parts[0], Type: MetadataType(strings.ToLower(parts[1]))}
+	if !metadataFieldPattern.MatchString(field.Name) {
+		return MetadataField{}, fmt.Errorf("invalid metadata field %q: names may only contain letters, digits and _", spec)
+	}
+	if reservedMetadataFields[field.Name] {
+		return MetadataField{}, fmt.Errorf("invalid metadata field %q: %s is set by indexing", spec, field.Name)
+	}
+
+	switch field.Type {
+	case MetadataTypeString, MetadataTypeNumber, MetadataTypeBool, MetadataTypeDate:
+	default:
+		return MetadataField{}, fmt.Errorf("invalid metadata field %q: unknown type %q (must be string, number, bool or date)", spec, parts[1])
+	}
+
+	if len(parts) == 3 {
+		if !strings.EqualFold(parts[2], "required") {
+			return MetadataField{}, fmt.Errorf("invalid metadata field %q: expected required, got %q", spec, parts[2])
+		}
+		field.Required = true
+	}
+
+	return field, nil
+}
+
+// ParseMetadataSchema parses a list of metadata fields
+func ParseMetadataSchema(specs []string) (MetadataSchema, error) {
+	var schema MetadataSchema
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		field, err := ParseMetadataField(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("metadata field %s is defined twice", field.Name)
+		}
+		seen[field.Name] = true
+		schema = append(schema, field)
+	}
+	return schema, nil
+}
+
+// String returns the field in the form accepted by ParseMetadataField
+func (f MetadataField) String() string {
+	if f.Required {
+		return f.Name + ":" + string(f.Type) + ":required"
+	}
+	return f.Name + ":" + string(f.Type)
+}
+
+// String returns the schema's fields in the form accepted by
+// ParseMetadataField, separated by commas
+func (s MetadataSchema) String() string {
+	specs := make([]string, len(s))
+	for i, field := range s {
+		specs[i] = field.String()
+	}
+	return strings.Join(specs, ", ")
+}
+
+// Normalize checks that a value has the field's type and returns its
+// canonical form, which is how it is stored and compared
+func (f MetadataField) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch f.Type {
+	case MetadataTypeNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a number, got %q", f.Name, value)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case MetadataTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be true or false, got %q", f.Name, value)
+		}
+		return strconv.FormatBool(b), nil
+	case MetadataTypeDate:
+		date, err := time.Parse(metadataDateLayout, value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a date like 2006-01-02, got %q", f.Name, value)
+		}
+		return date.Format(metadataDateLayout), nil
+	default:
+		return value, nil
+	}
+}
+
+// Field returns the field with the given name
+func (s MetadataSchema) Field(name string) (MetadataField, bool) {
+	for _, field := range s {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return MetadataField{}, false
+}
+
+// Validate checks a document's metadata values against the schema and returns
+// the canonical values of the schema's fields. Values of fields that aren't
+// in the schema are dropped.
+func (s MetadataSchema) Validate(values map[string]string) (map[string]string, error) {
+	valid := make(map[string]string)
+	for _, field := range s {
+		value, ok := values[field.Name]
+		if !ok || strings.TrimSpace(value) == "" {
+			if field.Required {
+				return nil, fmt.Errorf("missing required metadata field %s", field.Name)
+			}
+			continue
+		}
+		normalized, err := field.Normalize(value)
+		if err != nil {
+			return nil, err
+		}
+		valid[field.Name] = normalized
+	}
+	return valid, nil
+}
+
+// FrontMatter returns the key: value pairs of the front matter that a file
+// starts with, between two --- lines. Quotes around values are removed;
+// lists and nested values are not supported.
+func FrontMatter(content string) map[string]string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return nil
+	}
+
+	values := make(map[string]string)
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "---" {
+			return values
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+
+	// Without a closing line there is no front matter
+	return nil
+}
+
+// MetadataFilter restricts a search to chunks whose metadata field compares
+// to a value, e.g. priority>=2
+type MetadataFilter struct {
+	Field    MetadataField `json:"field"`
+	Operator string        `json:"operator"` // =, !=, <, <=, > or >=
+	Value    string        `json:"value"`    // Canonical form of the value (see MetadataField.Normalize)
+}
+
+// ParseMetadataFilter parses a filter such as priority>=2 on a field of the
+// schema. Only numbers and dates can be compared with <, <=, > and >=.
+func ParseMetadataFilter(expr string, schema MetadataSchema) (MetadataFilter, error) {
+	expr = strings.TrimSpace(expr)
+
+	// The operator is the first of =, !=, <, <=, > and >=
+	i := strings.IndexAny(expr, "!<>=")
+	if i <= 0 {
+		return MetadataFilter{}, fmt.Errorf("invalid filter %q: expected field, operator and value, e.g. priority>=2", expr)
+	}
+	op := expr[i : i+1]
+	if i+1 < len(expr) && expr[i+1] == '=' && op != "=" {
+		op = expr[i : i+2]
+	}
+	if op == "!" {
+		return MetadataFilter{}, fmt.Errorf("invalid filter %q: expected field, operator and value, e.g. priority>=2", expr)
+	}
+	name, value := strings.TrimSpace(expr[:i]), expr[i+len(op):]
+
+	field, ok := schema.Field(name)
+	if !ok {
+		return MetadataFilter{}, fmt.Errorf("invalid filter %q: the collection has no metadata field %q", expr, name)
+	}
+	if op != "=" && op != "!=" && field.Type != MetadataTypeNumber && field.Type != MetadataTypeDate {
+		return MetadataFilter{}, fmt.Errorf("invalid filter %q: %s fields can only be compared with = and !=", expr, field.Type)
+	}
+	normalized, err := field.Normalize(value)
+	if err != nil {
+		return MetadataFilter{}, fmt.Errorf("invalid filter %q: %w", expr, err)
+	}
+	return MetadataFilter{Field: field, Operator: op, Value: normalized}, nil
+}
+
+// String returns the filter in the form accepted by ParseMetadataFilter
+func (f MetadataFilter) String() string {
+	return f.Field.Name + f.Operator + f.Value
+}
+
+// metadataNumberPattern and metadataDatePattern match the values that the
+// search queries compare as numbers and dates
+var (
+	metadataNumberPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	metadataDatePattern   = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+)
+
+// Matches reports whether a chunk's metadata values satisfy the filter,
+// comparing them the way the search queries do. Missing values, and values
+// that don't have the field's type, match no filter.
+func (f MetadataFilter) Matches(values map[string]string) bool {
+	value, ok := values[f.Field.Name]
+	if !ok {
+		return false
+	}
+
+	var cmp int
+	switch f.Field.Type {
+	case MetadataTypeNumber:
+		if !metadataNumberPattern.MatchString(value) {
+			return false
+		}
+		a, _ := strconv.ParseFloat(value, 64)
+		b, _ := strconv.ParseFloat(f.Value, 64)
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	case MetadataTypeDate:
+		if !metadataDatePattern.MatchString(value) {
+			return false
+		}
+		// Dates in the canonical form sort like strings
+		cmp = strings.Compare(value, f.Value)
+	default:
+		cmp = strings.Compare(value, f.Value)
+	}
+
+	switch f.Operator {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return false
+	}
+}
+
+// ParseMetadataFilters parses a list of metadata filters
+func ParseMetadataFilters(exprs []string, schema MetadataSchema) ([]MetadataFilter, error) {
+	var filters []MetadataFilter
+	for _, expr := range exprs {
+		filter, err := ParseMetadataFilter(expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// ParseMetadataMatch parses key=value pairs that the metadata of a chunk must
+// contain. Unlike filters, they work on any metadata key, with or without a
+// schema, and compare values exactly.
+func ParseMetadataMatch(exprs []string) (map[string]string, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	match := make(map[string]string, len(exprs))
+	for _, expr := range exprs {
+		key, value, ok := strings.Cut(expr, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid metadata %q: expected key=value, e.g. file_name=README.md", expr)
+		}
+		if previous, ok := match[key]; ok && previous != value {
+			return nil, fmt.Errorf("metadata %s is given twice with different values", key)
+		}
+		match[key] = value
+	}
+	return match, nil
+}
+
+// metadataConditionsSQL returns the conditions of the metadata filters and
+// key-value pairs of the search options on column, each starting with AND, and
+// their arguments, numbered from next
+func metadataConditionsSQL(opts *SearchOptions, column string, next int) (string, []interface{}) {
+	where, args := metadataFiltersSQL(opts.Where, column, next)
+	contains, containsArgs := metadataContainsSQL(opts.Metadata, column, next+len(args))
+	return where + contains, append(args, containsArgs...)
+}
+
+// metadataContainsSQL returns the condition that the metadata in column
+// contains all the key-value pairs, which the GIN index on metadata can
+// answer, and its argument, numbered next
+func metadataContainsSQL(match map[string]string, column string, next int) (string, []interface{}) {
+	if len(match) == 0 {
+		return "", nil
+	}
+	// Maps of strings always encode
+	pairs, _ := json.Marshal(match)
+	return fmt.Sprintf(" AND %s @> $%d::jsonb", column, next), []interface{}{string(pairs)}
+}
+
+// metadataFiltersSQL returns the conditions of metadata filters on column,
+// each starting with AND, and their arguments, numbered from next. Values
+// that don't have the field's type, such as ones indexed before the schema
+// changed, match no filter instead of failing the search.
+func metadataFiltersSQL(filters []MetadataFilter, column string, next int) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	for _, filter := range filters {
+		value := fmt.Sprintf("(%s->>$%d)", column, next)
+		param := fmt.Sprintf("$%d", next+1)
+		switch filter.Field.Type {
+		case MetadataTypeNumber:
+			fmt.Fprintf(&b, " AND (CASE WHEN %s ~ '^-?[0-9]+(\\.[0-9]+)?$' THEN %s::numeric END) %s %s::numeric", value, value, filter.Operator, param)
+		case MetadataTypeDate:
+			fmt.Fprintf(&b, " AND (CASE WHEN %s ~ '^[0-9]{4}-[0-9]{2}-[0-9]{2}$' THEN %s::date END) %s %s::date", value, value, filter.Operator, param)
+		default:
+			fmt.Fprintf(&b, " AND %s %s %s", value, filter.Operator, param)
+		}
+		args = append(args, filter.Field.Name, filter.Value)
+		next += 2
+	}
+	return b.String(), args
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadataSchema(t *testing.T) {
+	schema, err := ParseMetadataSchema([]string{"priority:number:required", "owner:String", "reviewed:date"})
+	require.NoError(t, err)
+	assert.Equal(t, MetadataSchema{
+		{Name: "priority", Type: MetadataTypeNumber, Required: true},
+		{Name: "owner", Type: MetadataTypeString},
+		{Name: "reviewed", Type: MetadataTypeDate},
+	}, schema)
+	assert.Equal(t, "priority:number:required, owner:string, reviewed:date", schema.String())
+
+	for _, spec := range []string{"priority", "priority:int", "file_name:string", "my-field:string", "owner:string:optional"} {
+		_, err := ParseMetadataField(spec)
+		assert.Error(t, err, spec)
+	}
+
+	_, err = ParseMetadataSchema([]string{"owner:string", "owner:number"})
+	assert.Error(t, err)
+}
+
+func TestMetadataSchemaValidate(t *testing.T) {
+	schema := MetadataSchema{
+		{Name: "priority", Type: MetadataTypeNumber, Required: true},
+		{Name: "draft", Type: MetadataTypeBool},
+		{Name: "reviewed", Type: MetadataTypeDate},
+	}
+
+	values, err := schema.Validate(map[string]string{"priority": "02.50", "draft": "TRUE", "title": "Guide"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"priority": "2.5", "draft": "true"}, values)
+
+	_, err = schema.Validate(map[string]string{"draft": "false"})
+	assert.ErrorContains(t, err, "missing required metadata field priority")
+
+	_, err = schema.Validate(map[string]string{"priority": "high"})
+	assert.ErrorContains(t, err, "priority must be a number")
+
+	_, err = schema.Validate(map[string]string{"priority": "1", "reviewed": "03/01/2026"})
+	assert.ErrorContains(t, err, "reviewed must be a date")
+}
+
+func TestFrontMatter(t *testing.T) {
+	content := "---\ntitle: \"Install guide\"\npriority: 2\ntags:\n  - setup\n---\n# Install\n"
+	assert.Equal(t, map[string]string{"title": "Install guide", "priority": "2", "tags": ""}, FrontMatter(content))
+
+	assert.Nil(t, FrontMatter("# No front matter\npriority: 2\n"))
+	assert.Nil(t, FrontMatter("---\npriority: 2\n"))
+}
+
+func TestParseMetadataFilter(t *testing.T) {
+	schema := MetadataSchema{
+		{Name: "priority", Type: MetadataTypeNumber},
+		{Name: "owner", Type: MetadataTypeString},
+	}
+
+	filter, err := ParseMetadataFilter("priority>=2", schema)
+	require.NoError(t, err)
+	assert.Equal(t, MetadataFilter{Field: schema[0], Operator: ">=", Value: "2"}, filter)
+	assert.Equal(t, "priority>=2", filter.String())
+
+	filter, err = ParseMetadataFilter("owner != docs-team", schema)
+	require.NoError(t, err)
+	assert.Equal(t, MetadataFilter{Field: schema[1], Operator: "!=", Value: "docs-team"}, filter)
+
+	_, err = ParseMetadataFilter("owner>docs", schema)
+	assert.ErrorContains(t, err, "can only be compared with = and !=")
+	_, err = ParseMetadataFilter("severity=1", schema)
+	assert.ErrorContains(t, err, "no metadata field")
+	_, err = ParseMetadataFilter("priority>=high", schema)
+	assert.ErrorContains(t, err, "must be a number")
+	filter, err = ParseMetadataFilter("owner=a<b", schema)
+	require.NoError(t, err)
+	assert.Equal(t, "a<b", filter.Value)
+
+	_, err = ParseMetadataFilter("priority", schema)
+	assert.Error(t, err)
+	_, err = ParseMetadataFilter("priority!2", schema)
+	assert.Error(t, err)
+}
+
+func TestMetadataFiltersSQL(t *testing.T) {
+	filters := []MetadataFilter{
+		{Field: MetadataField{Name: "owner", Type: MetadataTypeString}, Operator: "=", Value: "docs"},
+		{Field: MetadataField{Name: "priority", Type: MetadataTypeNumber}, Operator: ">=", Value: "2"},
+	}
+
+	sql, args := metadataFiltersSQL(filters, "d.metadata", 4)
+	assert.Equal(t, " AND (d.metadata->>$4) = $5"+
+		" AND (CASE WHEN (d.metadata->>$6) ~ '^-?[0-9]+(\\.[0-9]+)?$' THEN (d.metadata->>$6)::numeric END) >= $7::numeric", sql)
+	assert.Equal(t, []interface{}{"owner", "docs", "priority", "2"}, args)
+
+	sql, args = metadataFiltersSQL(nil, "metadata", 1)
+	assert.Empty(t, sql)
+	assert.Empty(t, args)
+}
+
+func TestParseMetadataMatch(t *testing.T) {
+	match, err := ParseMetadataMatch([]string{"section=Install", " lang =go", "title=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"section": "Install", "lang": "go", "title": "a=b", "empty": ""}, match)
+
+	match, err = ParseMetadataMatch(nil)
+	require.NoError(t, err)
+	assert.Nil(t, match)
+
+	_, err = ParseMetadataMatch([]string{"section"})
+	assert.Error(t, err)
+	_, err = ParseMetadataMatch([]string{"=Install"})
+	assert.Error(t, err)
+	_, err = ParseMetadataMatch([]string{"lang=go", "lang=md"})
+	assert.Error(t, err)
+}
+
+func TestMetadataConditionsSQL(t *testing.T) {
+	opts := &SearchOptions{
+		Where:    []MetadataFilter{{Field: MetadataField{Name: "owner", Type: MetadataTypeString}, Operator: "=", Value: "docs"}},
+		Metadata: map[string]string{"section": "Install", "lang": "go"},
+	}
+
+	sql, args := metadataConditionsSQL(opts, "metadata", 6)
+	assert.Equal(t, " AND (metadata->>$6) = $7 AND metadata @> $8::jsonb", sql)
+	assert.Equal(t, []interface{}{"owner", "docs", `{"lang":"go","section":"Install"}`}, args)
+
+	sql, args = metadataConditionsSQL(&SearchOptions{}, "metadata", 1)
+	assert.Empty(t, sql)
+	assert.Empty(t, args)
+}
+
+func TestMetadataFilterMatches(t *testing.T) {
+	schema := MetadataSchema{
+		{Name: "priority", Type: MetadataTypeNumber},
+		{Name: "owner", Type: MetadataTypeString},
+		{Name: "reviewed", Type: MetadataTypeDate},
+	}
+	values := map[string]string{"priority": "10", "owner": "docs", "reviewed": "2025-03-01"}
+
+	tests := []struct {
+		expr    string
+		matches bool
+	}{
+		{"priority>=2", true}, // Compared as numbers, not strings
+		{"priority<2", false},
+		{"owner=docs", true},
+		{"owner!=docs", false},
+		{"reviewed>2025-01-31", true},
+		{"reviewed<=2025-02-28", false},
+	}
+	for _, test := range tests {
+		filter, err := ParseMetadataFilter(test.expr, schema)
+		require.NoError(t, err)
+		assert.Equal(t, test.matches, filter.Matches(values), test.expr)
+	}
+
+	// Missing values and values of the wrong type match nothing
+	filter, err := ParseMetadataFilter("priority!=1", schema)
+	require.NoError(t, err)
+	assert.False(t, filter.Matches(map[string]string{}))
+	assert.False(t, filter.Matches(map[string]string{"priority": "high"}))
+}
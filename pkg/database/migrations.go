@@ -39,6 +39,174 @@ func (mm *MigrationManager) registerMigrations() {
 			Up:          mm.migration001CreateCompleteSchema,
 			Down:        mm.migration001CreateCompleteSchemaDown,
 		},
+		{
+			Version:     2,
+			Description: "Add per-collection vector index type",
+			Up:          mm.migration002AddCollectionIndexType,
+			Down:        mm.migration002AddCollectionIndexTypeDown,
+		},
+		{
+			Version:     3,
+			Description: "Store term vectors for BM25 ranking",
+			Up:          mm.migration003AddBM25TermVectors,
+			Down:        mm.migration003AddBM25TermVectorsDown,
+		},
+		{
+			Version:     4,
+			Description: "Add per-collection boosting rules",
+			Up:          mm.migration004AddCollectionBoosts,
+			Down:        mm.migration004AddCollectionBoostsDown,
+		},
+		{
+			Version:     5,
+			Description: "Store collection vocabularies for spell checking",
+			Up:          mm.migration005AddCollectionTerms,
+			Down:        mm.migration005AddCollectionTermsDown,
+		},
+		{
+			Version:     6,
+			Description: "Add collection centroid embeddings for query routing",
+			Up:          mm.migration006AddCollectionCentroids,
+			Down:        mm.migration006AddCollectionCentroidsDown,
+		},
+		{
+			Version:     7,
+			Description: "Add collection description embeddings",
+			Up:          mm.migration007AddDescriptionEmbeddings,
+			Down:        mm.migration007AddDescriptionEmbeddingsDown,
+		},
+		{
+			Version:     8,
+			Description: "Add collection aliases",
+			Up:          mm.migration008AddCollectionAliases,
+			Down:        mm.migration008AddCollectionAliasesDown,
+		},
+		{
+			Version:     9,
+			Description: "Store document paths relative to their collection folder",
+			Up:          mm.migration009AddDocumentFolders,
+			Down:        mm.migration009AddDocumentFoldersDown,
+		},
+		{
+			Version:     10,
+			Description: "Store chat sessions and their messages",
+			Up:          mm.migration010AddChatSessions,
+			Down:        mm.migration010AddChatSessionsDown,
+		},
+		{
+			Version:     11,
+			Description: "Add the prompt library",
+			Up:          mm.migration011AddPrompts,
+			Down:        mm.migration011AddPromptsDown,
+		},
+		{
+			Version:     12,
+			Description: "Leave encrypted content out of term vectors",
+			Up:          mm.migration012SkipEncryptedTermVectors,
+			Down:        mm.migration012SkipEncryptedTermVectorsDown,
+		},
+		{
+			Version:     13,
+			Description: "Add file types to documents",
+			Up:          mm.migration013AddFileTypes,
+			Down:        mm.migration013AddFileTypesDown,
+		},
+		{
+			Version:     14,
+			Description: "Flag collections with normalized embeddings",
+			Up:          mm.migration014AddNormalizedCollections,
+			Down:        mm.migration014AddNormalizedCollectionsDown,
+		},
+		{
+			Version:     15,
+			Description: "Index code symbols with a higher weight",
+			Up:          mm.migration015AddSymbols,
+			Down:        mm.migration015AddSymbolsDown,
+		},
+		{
+			Version:     16,
+			Description: "Add file path embeddings",
+			Up:          mm.migration016AddPathEmbeddings,
+			Down:        mm.migration016AddPathEmbeddingsDown,
+		},
+		{
+			Version:     17,
+			Description: "Add collection metadata schemas",
+			Up:          mm.migration017AddMetadataSchemas,
+			Down:        mm.migration017AddMetadataSchemasDown,
+		},
+		{
+			Version:     18,
+			Description: "Add chunk token counts",
+			Up:          mm.migration018AddTokenCounts,
+			Down:        mm.migration018AddTokenCountsDown,
+		},
+		{
+			Version:     19,
+			Description: "Add indexed file states",
+			Up:          mm.migration019AddFileStates,
+			Down:        mm.migration019AddFileStatesDown,
+		},
+		{
+			Version:     20,
+			Description: "Make document chunks unique",
+			Up:          mm.migration020UniqueChunks,
+			Down:        mm.migration020UniqueChunksDown,
+		},
+		{
+			Version:     21,
+			Description: "Index document metadata",
+			Up:          mm.migration021IndexMetadata,
+			Down:        mm.migration021IndexMetadataDown,
+		},
+		{
+			Version:     22,
+			Description: "Index chunk content hashes",
+			Up:          mm.migration022IndexContentHashes,
+			Down:        mm.migration022IndexContentHashesDown,
+		},
+		{
+			Version:     23,
+			Description: "Add collection snapshots",
+			Up:          mm.migration023AddSnapshots,
+			Down:        mm.migration023AddSnapshotsDown,
+		},
+		{
+			Version:     24,
+			Description: "Add chat memories",
+			Up:          mm.migration024AddChatMemories,
+			Down:        mm.migration024AddChatMemoriesDown,
+		},
+		{
+			Version:     25,
+			Description: "Add chat message sources",
+			Up:          mm.migration025AddMessageSources,
+			Down:        mm.migration025AddMessageSourcesDown,
+		},
+		{
+			Version:     26,
+			Description: "Add search feedback and tuned collection ranking",
+			Up:          mm.migration026AddSearchFeedback,
+			Down:        mm.migration026AddSearchFeedbackDown,
+		},
+		{
+			Version:     27,
+			Description: "Make document chunks without a folder unique",
+			Up:          mm.migration027UniqueChunksWithoutFolder,
+			Down:        mm.migration027UniqueChunksWithoutFolderDown,
+		},
+		{
+			Version:     28,
+			Description: "Clear plain content hashes of encrypted collections",
+			Up:          mm.migration028ClearEncryptedContentHashes,
+			Down:        mm.migration028ClearEncryptedContentHashesDown,
+		},
+		{
+			Version:     29,
+			Description: "Judge files by folder and path",
+			Up:          mm.migration029JudgeFilesByFolder,
+			Down:        mm.migration029JudgeFilesByFolderDown,
+		},
 	}
 }
 
@@ -150,6 +318,11 @@ func (mm *MigrationManager) createMigrationsTable() error {
 
 // migration001CreateCompleteSchema creates the complete schema with configurable dimensions
 func (mm *MigrationManager) migration001CreateCompleteSchema(tx *sql.Tx) error {
+	// The documents table needs the vector type
+	if err := ensureExtension(tx, VectorExtension); err != nil {
+		return err
+	}
+
 	queries := []string{
 		`CREATE TABLE IF NOT EXISTS collections (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -235,6 +408,1047 @@ func (mm *MigrationManager) migration001CreateCompleteSchemaDown(tx *sql.Tx) err
 	return nil
 }
 
+// migration002AddCollectionIndexType records which vector index each collection uses
+func (mm *MigrationManager) migration002AddCollectionIndexType(tx *sql.Tx) error {
+	query := `ALTER TABLE collections ADD COLUMN IF NOT EXISTS index_type VARCHAR(32) NOT NULL DEFAULT 'hnsw';`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration002AddCollectionIndexTypeDown drops per-collection indexes and the index type column
+func (mm *MigrationManager) migration002AddCollectionIndexTypeDown(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id FROM collections WHERE index_type <> 'hnsw'`)
+	if err != nil {
+		return fmt.Errorf("failed to query collections: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan collection: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := dropCollectionVectorIndex(tx, id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`ALTER TABLE collections DROP COLUMN IF EXISTS index_type;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration003AddBM25TermVectors stores each chunk's term vector and length,
+// the sparse representation BM25 ranks against
+func (mm *MigrationManager) migration003AddBM25TermVectors(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_tsv tsvector;`,
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_length INTEGER NOT NULL DEFAULT 0;`,
+		`CREATE OR REPLACE FUNCTION tsvector_token_count(tsvector)
+		RETURNS INTEGER AS $$
+			SELECT COALESCE(SUM(COALESCE(array_length(positions, 1), 1)), 0)::INTEGER FROM unnest($1);
+		$$ LANGUAGE sql IMMUTABLE;`,
+		`CREATE OR REPLACE FUNCTION update_documents_term_vector()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			NEW.content_tsv = to_tsvector('english', NEW.content);
+			NEW.content_length = tsvector_token_count(NEW.content_tsv);
+			RETURN NEW;
+		END;
+		$$ language 'plpgsql';`,
+
+		// Backfill existing chunks without touching their updated_at
+		`ALTER TABLE documents DISABLE TRIGGER update_documents_updated_at;`,
+		`UPDATE documents SET
+			content_tsv = to_tsvector('english', content),
+			content_length = tsvector_token_count(to_tsvector('english', content));`,
+		`ALTER TABLE documents ENABLE TRIGGER update_documents_updated_at;`,
+
+		`DROP TRIGGER IF EXISTS update_documents_term_vector ON documents;`,
+		`CREATE TRIGGER update_documents_term_vector
+		BEFORE INSERT OR UPDATE OF content ON documents
+		FOR EACH ROW
+		EXECUTE FUNCTION update_documents_term_vector();`,
+		`CREATE INDEX IF NOT EXISTS idx_documents_content_tsv ON documents USING gin(content_tsv);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration003AddBM25TermVectorsDown drops the stored term vectors
+func (mm *MigrationManager) migration003AddBM25TermVectorsDown(tx *sql.Tx) error {
+	queries := []string{
+		`DROP TRIGGER IF EXISTS update_documents_term_vector ON documents;`,
+		`DROP FUNCTION IF EXISTS update_documents_term_vector CASCADE;`,
+		`DROP INDEX IF EXISTS idx_documents_content_tsv;`,
+		`ALTER TABLE documents DROP COLUMN IF EXISTS content_length;`,
+		`ALTER TABLE documents DROP COLUMN IF EXISTS content_tsv;`,
+		`DROP FUNCTION IF EXISTS tsvector_token_count CASCADE;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration004AddCollectionBoosts stores the boosting rules applied when searching a collection
+func (mm *MigrationManager) migration004AddCollectionBoosts(tx *sql.Tx) error {
+	query := `ALTER TABLE collections ADD COLUMN IF NOT EXISTS boosts TEXT[] NOT NULL DEFAULT '{}';`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration004AddCollectionBoostsDown drops the boosting rules
+func (mm *MigrationManager) migration004AddCollectionBoostsDown(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE collections DROP COLUMN IF EXISTS boosts;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration005AddCollectionTerms stores the vocabulary of each collection,
+// with a trigram index for finding the terms nearest to a misspelled word
+func (mm *MigrationManager) migration005AddCollectionTerms(tx *sql.Tx) error {
+	if err := ensureExtension(tx, TrigramExtension); err != nil {
+		return err
+	}
+
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS collection_terms (
+			collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			term TEXT NOT NULL,
+			doc_count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (collection_id, term)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_collection_terms_trgm ON collection_terms USING gin(term gin_trgm_ops);`,
+
+		// Backfill the vocabularies of existing collections
+		vocabularyInsertQuery(""),
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration005AddCollectionTermsDown drops the collection vocabularies
+func (mm *MigrationManager) migration005AddCollectionTermsDown(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DROP TABLE IF EXISTS collection_terms;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration006AddCollectionCentroids stores the average document embedding of
+// each collection, used to route queries to the collections most likely to answer them
+func (mm *MigrationManager) migration006AddCollectionCentroids(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE collections ADD COLUMN IF NOT EXISTS centroid vector;`,
+
+		// Backfill the centroids of existing collections
+		`UPDATE collections c SET centroid = (
+			SELECT AVG(d.embedding)
+			FROM documents d
+			WHERE d.collection_id = c.id AND d.embedding IS NOT NULL
+		);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration006AddCollectionCentroidsDown drops the collection centroids
+func (mm *MigrationManager) migration006AddCollectionCentroidsDown(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE collections DROP COLUMN IF EXISTS centroid;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration007AddDescriptionEmbeddings stores the embedding of each
+// collection's name and description, and the text it was computed from so
+// it can be refreshed when the collection is renamed or described anew
+func (mm *MigrationManager) migration007AddDescriptionEmbeddings(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE collections ADD COLUMN IF NOT EXISTS description_embedding vector;`,
+		`ALTER TABLE collections ADD COLUMN IF NOT EXISTS description_source TEXT;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration007AddDescriptionEmbeddingsDown drops the collection description embeddings
+func (mm *MigrationManager) migration007AddDescriptionEmbeddingsDown(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE collections DROP COLUMN IF EXISTS description_source;`,
+		`ALTER TABLE collections DROP COLUMN IF EXISTS description_embedding;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration008AddCollectionAliases stores alternative names for collections
+func (mm *MigrationManager) migration008AddCollectionAliases(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS collection_aliases (
+			alias VARCHAR(255) PRIMARY KEY,
+			collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_collection_aliases_collection_id ON collection_aliases(collection_id);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration008AddCollectionAliasesDown drops the collection aliases
+func (mm *MigrationManager) migration008AddCollectionAliasesDown(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DROP TABLE IF EXISTS collection_aliases;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration009AddDocumentFolders records which collection folder each
+// document was indexed from and makes its path relative to that folder, so
+// collections keep working when their folders are somewhere else. Paths
+// outside all of the collection's folders are left as they are.
+func (mm *MigrationManager) migration009AddDocumentFolders(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS folder TEXT;`,
+
+		// Backfill existing chunks without touching their updated_at. The most
+		// specific folder wins when collection folders are nested.
+		`ALTER TABLE documents DISABLE TRIGGER update_documents_updated_at;`,
+		`UPDATE documents d SET
+			folder = f.folder,
+			file_path = substr(d.file_path, length(rtrim(f.folder, '/')) + 2)
+		FROM (
+			SELECT DISTINCT ON (d.id) d.id, f.folder
+			FROM documents d
+			JOIN collections c ON c.id = d.collection_id
+			CROSS JOIN LATERAL unnest(c.folders) AS f(folder)
+			WHERE d.folder IS NULL
+			  AND left(d.file_path, length(rtrim(f.folder, '/')) + 1) = rtrim(f.folder, '/') || '/'
+			ORDER BY d.id, length(f.folder) DESC
+		) f
+		WHERE d.id = f.id;`,
+		`ALTER TABLE documents ENABLE TRIGGER update_documents_updated_at;`,
+
+		`CREATE INDEX IF NOT EXISTS idx_documents_folder ON documents(collection_id, folder);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration009AddDocumentFoldersDown makes document paths absolute again and drops their folders
+func (mm *MigrationManager) migration009AddDocumentFoldersDown(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE documents DISABLE TRIGGER update_documents_updated_at;`,
+		`UPDATE documents SET file_path = rtrim(folder, '/') || '/' || file_path WHERE folder IS NOT NULL;`,
+		`ALTER TABLE documents ENABLE TRIGGER update_documents_updated_at;`,
+		`DROP INDEX IF EXISTS idx_documents_folder;`,
+		`ALTER TABLE documents DROP COLUMN IF EXISTS folder;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration010AddChatSessions stores chat sessions and their conversations
+// so they outlive the process that started them. Sessions without an expiry
+// are kept until they are deleted.
+func (mm *MigrationManager) migration010AddChatSessions(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS chat_sessions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) UNIQUE,
+			collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			settings JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			expires_at TIMESTAMP WITH TIME ZONE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_sessions_collection_id ON chat_sessions(collection_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_sessions_expires_at ON chat_sessions(expires_at) WHERE expires_at IS NOT NULL;`,
+
+		`CREATE TABLE IF NOT EXISTS chat_messages (
+			id BIGSERIAL PRIMARY KEY,
+			session_id UUID NOT NULL REFERENCES chat_sessions(id) ON DELETE CASCADE,
+			role VARCHAR(20) NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_messages_session_id ON chat_messages(session_id, id);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration010AddChatSessionsDown drops the chat sessions and their messages
+func (mm *MigrationManager) migration010AddChatSessionsDown(tx *sql.Tx) error {
+	queries := []string{
+		`DROP TABLE IF EXISTS chat_messages;`,
+		`DROP TABLE IF EXISTS chat_sessions;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration011AddPrompts stores named system prompts that chat sessions can
+// be started with
+func (mm *MigrationManager) migration011AddPrompts(tx *sql.Tx) error {
+	query := `CREATE TABLE IF NOT EXISTS prompts (
+		name VARCHAR(255) PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration011AddPromptsDown drops the prompt library
+func (mm *MigrationManager) migration011AddPromptsDown(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DROP TABLE IF EXISTS prompts;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration012SkipEncryptedTermVectors gives encrypted chunks empty term
+// vectors, since the terms of their ciphertext match nothing and would skew
+// the average chunk length BM25 uses
+func (mm *MigrationManager) migration012SkipEncryptedTermVectors(tx *sql.Tx) error {
+	query := `CREATE OR REPLACE FUNCTION update_documents_term_vector()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			IF NEW.content LIKE 'enc:v1:%' THEN
+				NEW.content_tsv = ''::tsvector;
+			ELSE
+				NEW.content_tsv = to_tsvector('english', NEW.content);
+			END IF;
+			NEW.content_length = tsvector_token_count(NEW.content_tsv);
+			RETURN NEW;
+		END;
+		$$ language 'plpgsql';`
+
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration012SkipEncryptedTermVectorsDown restores the term vectors of all content
+func (mm *MigrationManager) migration012SkipEncryptedTermVectorsDown(tx *sql.Tx) error {
+	query := `CREATE OR REPLACE FUNCTION update_documents_term_vector()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			NEW.content_tsv = to_tsvector('english', NEW.content);
+			NEW.content_length = tsvector_token_count(NEW.content_tsv);
+			RETURN NEW;
+		END;
+		$$ language 'plpgsql';`
+
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration013AddFileTypes stores the file type of each chunk in its own
+// column, so searches restricted to file types can use an index
+func (mm *MigrationManager) migration013AddFileTypes(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS file_type TEXT NOT NULL DEFAULT '';`,
+
+		// Backfill existing chunks without touching their updated_at
+		`ALTER TABLE documents DISABLE TRIGGER update_documents_updated_at;`,
+		`UPDATE documents SET file_type = lower(substring(file_name from '\.([^.]+)$'))
+		WHERE file_name ~ '\.[^.]+$';`,
+		`ALTER TABLE documents ENABLE TRIGGER update_documents_updated_at;`,
+
+		`CREATE INDEX IF NOT EXISTS idx_documents_collection_file_type ON documents(collection_id, file_type);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration013AddFileTypesDown drops the file types of documents
+func (mm *MigrationManager) migration013AddFileTypesDown(tx *sql.Tx) error {
+	queries := []string{
+		`DROP INDEX IF EXISTS idx_documents_collection_file_type;`,
+		`ALTER TABLE documents DROP COLUMN IF EXISTS file_type;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration014AddNormalizedCollections records which collections have
+// normalized embeddings and are searched by inner product
+func (mm *MigrationManager) migration014AddNormalizedCollections(tx *sql.Tx) error {
+	query := `ALTER TABLE collections ADD COLUMN IF NOT EXISTS normalized BOOLEAN NOT NULL DEFAULT FALSE;`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration014AddNormalizedCollectionsDown switches normalized collections
+// back to cosine indexes and drops the flag
+func (mm *MigrationManager) migration014AddNormalizedCollectionsDown(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, index_type FROM collections WHERE normalized`)
+	if err != nil {
+		return fmt.Errorf("failed to query collections: %w", err)
+	}
+
+	type collectionIndex struct {
+		id        string
+		indexType string
+	}
+	var collections []collectionIndex
+	for rows.Next() {
+		var c collectionIndex
+		if err := rows.Scan(&c.id, &c.indexType); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan collection: %w", err)
+		}
+		collections = append(collections, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query collections: %w", err)
+	}
+
+	for _, c := range collections {
+		indexType, err := ParseIndexType(c.indexType)
+		if err != nil {
+			return err
+		}
+		if err := createCollectionVectorIndex(tx, c.id, indexType, false); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`ALTER TABLE collections DROP COLUMN IF EXISTS normalized;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration015AddSymbols stores the symbols each code chunk defines and
+// imports, and adds them to its term vector with weight A, above the
+// content's default weight D. The content length BM25 normalizes by still
+// counts the content alone. Existing chunks get symbols when re-indexed.
+// Encrypted chunks keep empty term vectors, as since migration 12.
+func (mm *MigrationManager) migration015AddSymbols(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS symbols TEXT NOT NULL DEFAULT '';`,
+		`CREATE OR REPLACE FUNCTION update_documents_term_vector()
+		RETURNS TRIGGER AS $$
+		DECLARE
+			content_vector tsvector;
+		BEGIN
+			IF NEW.content LIKE 'enc:v1:%' THEN
+				NEW.content_tsv = ''::tsvector;
+				NEW.content_length = 0;
+				RETURN NEW;
+			END IF;
+			content_vector = to_tsvector('english', NEW.content);
+			NEW.content_tsv = setweight(to_tsvector('english', NEW.symbols), 'A') || content_vector;
+			NEW.content_length = tsvector_token_count(content_vector);
+			RETURN NEW;
+		END;
+		$$ language 'plpgsql';`,
+		`DROP TRIGGER IF EXISTS update_documents_term_vector ON documents;`,
+		`CREATE TRIGGER update_documents_term_vector
+		BEFORE INSERT OR UPDATE OF content, symbols ON documents
+		FOR EACH ROW
+		EXECUTE FUNCTION update_documents_term_vector();`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration015AddSymbolsDown drops the symbols and rebuilds the term
+// vectors from the content alone, restoring the function of migration 12
+func (mm *MigrationManager) migration015AddSymbolsDown(tx *sql.Tx) error {
+	queries := []string{
+		`DROP TRIGGER IF EXISTS update_documents_term_vector ON documents;`,
+		`CREATE OR REPLACE FUNCTION update_documents_term_vector()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			IF NEW.content LIKE 'enc:v1:%' THEN
+				NEW.content_tsv = ''::tsvector;
+			ELSE
+				NEW.content_tsv = to_tsvector('english', NEW.content);
+			END IF;
+			NEW.content_length = tsvector_token_count(NEW.content_tsv);
+			RETURN NEW;
+		END;
+		$$ language 'plpgsql';`,
+
+		// Rebuild the term vectors of chunks with symbols without touching their updated_at
+		`ALTER TABLE documents DISABLE TRIGGER update_documents_updated_at;`,
+		`UPDATE documents SET content_tsv = to_tsvector('english', content)
+		WHERE symbols <> '' AND content NOT LIKE 'enc:v1:%';`,
+		`ALTER TABLE documents ENABLE TRIGGER update_documents_updated_at;`,
+
+		`ALTER TABLE documents DROP COLUMN IF EXISTS symbols;`,
+		`CREATE TRIGGER update_documents_term_vector
+		BEFORE INSERT OR UPDATE OF content ON documents
+		FOR EACH ROW
+		EXECUTE FUNCTION update_documents_term_vector();`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration016AddPathEmbeddings stores the embedding of each chunk's file
+// path, which search weighs in so a file named after the query ranks higher
+// even when its content is terse. Existing chunks get one when re-indexed.
+func (mm *MigrationManager) migration016AddPathEmbeddings(tx *sql.Tx) error {
+	query := `ALTER TABLE documents ADD COLUMN IF NOT EXISTS path_embedding vector;`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration016AddPathEmbeddingsDown drops the file path embeddings
+func (mm *MigrationManager) migration016AddPathEmbeddingsDown(tx *sql.Tx) error {
+	query := `ALTER TABLE documents DROP COLUMN IF EXISTS path_embedding;`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration017AddMetadataSchemas stores the custom metadata fields expected
+// in each collection's documents
+func (mm *MigrationManager) migration017AddMetadataSchemas(tx *sql.Tx) error {
+	query := `ALTER TABLE collections ADD COLUMN IF NOT EXISTS metadata_schema TEXT[] NOT NULL DEFAULT '{}';`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration017AddMetadataSchemasDown drops the metadata schemas
+func (mm *MigrationManager) migration017AddMetadataSchemasDown(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE collections DROP COLUMN IF EXISTS metadata_schema;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration018AddTokenCounts stores the estimated number of tokens of each
+// chunk. Existing chunks are estimated at four characters a token, except
+// encrypted ones, which are estimated when they are read.
+func (mm *MigrationManager) migration018AddTokenCounts(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS token_count INTEGER NOT NULL DEFAULT 0;`,
+		`UPDATE documents SET token_count = (char_length(content) + 3) / 4 WHERE content NOT LIKE 'enc:v1:%';`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration018AddTokenCountsDown drops the chunk token counts
+func (mm *MigrationManager) migration018AddTokenCountsDown(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE documents DROP COLUMN IF EXISTS token_count;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration019AddFileStates adds the modification time, size and content
+// hash of every indexed file, so unchanged files are skipped when indexing
+func (mm *MigrationManager) migration019AddFileStates(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS files (
+			collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			folder TEXT NOT NULL,
+			file_path TEXT NOT NULL,
+			size BIGINT NOT NULL,
+			modified_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			content_hash TEXT NOT NULL,
+			indexed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (collection_id, folder, file_path)
+		);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration019AddFileStatesDown drops the indexed file states
+func (mm *MigrationManager) migration019AddFileStatesDown(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DROP TABLE IF EXISTS files;`); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
+// migration020UniqueChunks allows a single chunk per index of a file, so
+// indexing a file again replaces its chunks instead of adding to them, and
+// stores the hash of every chunk's content. Duplicates left by runs that
+// failed halfway are removed, keeping the most recently written.
+func (mm *MigrationManager) migration020UniqueChunks(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';`,
+		`UPDATE documents SET content_hash = encode(sha256(convert_to(content, 'UTF8')), 'hex') WHERE content NOT LIKE 'enc:v1:%';`,
+		dedupChunksQuery,
+		createChunkIndexQuery,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// dedupChunksQuery deletes all but the most recently written chunk of each
+// index of a file. Documents from before migration 9 that are outside all
+// collection folders have no folder, which is the same as an empty one.
+const dedupChunksQuery = `DELETE FROM documents a USING documents b
+	WHERE a.collection_id = b.collection_id
+	AND COALESCE(a.folder, '') = COALESCE(b.folder, '')
+	AND a.file_path = b.file_path
+	AND a.chunk_index = b.chunk_index
+	AND a.ctid < b.ctid;`
+
+// createChunkIndexQuery creates the unique index of chunks, the conflict
+// target of UpsertDocument. Folders are coalesced because NULLs are distinct
+// in unique indexes, and NULLS NOT DISTINCT needs PostgreSQL 15.
+const createChunkIndexQuery = `CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_chunk
+	ON documents(collection_id, COALESCE(folder, ''), file_path, chunk_index);`
+
+// migration020UniqueChunksDown drops the unique chunk index and the chunk
+// content hashes
+func (mm *MigrationManager) migration020UniqueChunksDown(tx *sql.Tx) error {
+	queries := []string{
+		`DROP INDEX IF EXISTS idx_documents_chunk;`,
+		`ALTER TABLE documents DROP COLUMN IF EXISTS content_hash;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration021IndexMetadata indexes the metadata of documents, so searches
+// restricted to chunks containing metadata key-value pairs can use an index
+func (mm *MigrationManager) migration021IndexMetadata(tx *sql.Tx) error {
+	query := `CREATE INDEX IF NOT EXISTS idx_documents_metadata ON documents USING gin(metadata jsonb_path_ops);`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration021IndexMetadataDown drops the metadata index
+func (mm *MigrationManager) migration021IndexMetadataDown(tx *sql.Tx) error {
+	query := `DROP INDEX IF EXISTS idx_documents_metadata;`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration022IndexContentHashes indexes the content hashes of chunks, so
+// the embeddings of chunks already stored in other collections are found
+// when indexing
+func (mm *MigrationManager) migration022IndexContentHashes(tx *sql.Tx) error {
+	query := `CREATE INDEX IF NOT EXISTS idx_documents_content_hash ON documents(content_hash);`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration022IndexContentHashesDown drops the content hash index
+func (mm *MigrationManager) migration022IndexContentHashesDown(tx *sql.Tx) error {
+	query := `DROP INDEX IF EXISTS idx_documents_content_hash;`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration023AddSnapshots adds snapshots of collections: read-only
+// collections holding a copy of another's documents at a point in time
+func (mm *MigrationManager) migration023AddSnapshots(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE collections ADD COLUMN IF NOT EXISTS snapshot_of UUID REFERENCES collections(id) ON DELETE CASCADE;`,
+		`ALTER TABLE collections ADD COLUMN IF NOT EXISTS snapshot_name TEXT;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_collections_snapshot ON collections(snapshot_of, snapshot_name) WHERE snapshot_of IS NOT NULL;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration023AddSnapshotsDown deletes the snapshots and drops their columns
+func (mm *MigrationManager) migration023AddSnapshotsDown(tx *sql.Tx) error {
+	queries := []string{
+		`DELETE FROM collections WHERE snapshot_of IS NOT NULL;`,
+		`DROP INDEX IF EXISTS idx_collections_snapshot;`,
+		`ALTER TABLE collections DROP COLUMN IF EXISTS snapshot_name;`,
+		`ALTER TABLE collections DROP COLUMN IF EXISTS snapshot_of;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration024AddChatMemories stores what users ask chat to remember for
+// the later sessions with a collection
+func (mm *MigrationManager) migration024AddChatMemories(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS chat_memories (
+			id BIGSERIAL PRIMARY KEY,
+			collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (collection_id, kind, content)
+		);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration024AddChatMemoriesDown drops the chat memories
+func (mm *MigrationManager) migration024AddChatMemoriesDown(tx *sql.Tx) error {
+	query := `DROP TABLE IF EXISTS chat_memories;`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration025AddMessageSources stores the context documents of chat
+// answers, so conversations can be exported with their sources
+func (mm *MigrationManager) migration025AddMessageSources(tx *sql.Tx) error {
+	query := `ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS sources JSONB;`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration025AddMessageSourcesDown drops the sources of chat messages
+func (mm *MigrationManager) migration025AddMessageSourcesDown(tx *sql.Tx) error {
+	query := `ALTER TABLE chat_messages DROP COLUMN IF EXISTS sources;`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// migration026AddSearchFeedback stores the relevance judgments of search
+// results, and the ranking tuned from them for each collection
+func (mm *MigrationManager) migration026AddSearchFeedback(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS search_feedback (
+			id BIGSERIAL PRIMARY KEY,
+			collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			query TEXT NOT NULL,
+			file_path TEXT NOT NULL,
+			relevant BOOLEAN NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (collection_id, query, file_path)
+		);`,
+		`ALTER TABLE collections ADD COLUMN IF NOT EXISTS ranking JSONB;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration026AddSearchFeedbackDown drops the search feedback and the tuned
+// collection ranking
+func (mm *MigrationManager) migration026AddSearchFeedbackDown(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE collections DROP COLUMN IF EXISTS ranking;`,
+		`DROP TABLE IF EXISTS search_feedback;`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration027UniqueChunksWithoutFolder rebuilds the unique chunk index of
+// databases migrated before migration 20 coalesced folders, whose index
+// let chunks without a folder be duplicated
+func (mm *MigrationManager) migration027UniqueChunksWithoutFolder(tx *sql.Tx) error {
+	queries := []string{
+		`DROP INDEX IF EXISTS idx_documents_chunk;`,
+		dedupChunksQuery,
+		createChunkIndexQuery,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration027UniqueChunksWithoutFolderDown restores the unique chunk index
+// on the folder column
+func (mm *MigrationManager) migration027UniqueChunksWithoutFolderDown(tx *sql.Tx) error {
+	queries := []string{
+		`DROP INDEX IF EXISTS idx_documents_chunk;`,
+		`CREATE UNIQUE INDEX idx_documents_chunk ON documents(collection_id, folder, file_path, chunk_index);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration028ClearEncryptedContentHashes clears the plain content hashes
+// stored with encrypted chunks, and with the file states of collections with
+// encrypted chunks, which could be matched against guessed content. They are
+// keyed when the chunks and files are indexed again; until then, their
+// chunks aren't shared and touched files are indexed again.
+func (mm *MigrationManager) migration028ClearEncryptedContentHashes(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE documents DISABLE TRIGGER update_documents_updated_at;`,
+		`UPDATE documents SET content_hash = '' WHERE content LIKE 'enc:v1:%' AND content_hash <> '';`,
+		`ALTER TABLE documents ENABLE TRIGGER update_documents_updated_at;`,
+		`UPDATE files SET content_hash = '' WHERE collection_id IN (
+			SELECT DISTINCT collection_id FROM documents WHERE content LIKE 'enc:v1:%');`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration028ClearEncryptedContentHashesDown does nothing: cleared hashes
+// can't be restored, and chunks indexed since keep their keyed hashes
+func (mm *MigrationManager) migration028ClearEncryptedContentHashesDown(tx *sql.Tx) error {
+	return nil
+}
+
+// migration029JudgeFilesByFolder adds the folder to the files of relevance
+// judgments, since two folders of a collection may have files with the same
+// path. Earlier judgments of a path found in only one folder get its folder.
+func (mm *MigrationManager) migration029JudgeFilesByFolder(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE search_feedback ADD COLUMN IF NOT EXISTS folder TEXT NOT NULL DEFAULT '';`,
+		`UPDATE search_feedback f SET folder = d.folder
+			FROM (
+				SELECT collection_id, file_path, MIN(folder) AS folder
+				FROM documents
+				WHERE folder IS NOT NULL
+				GROUP BY collection_id, file_path
+				HAVING COUNT(DISTINCT folder) = 1
+			) d
+			WHERE f.collection_id = d.collection_id AND f.file_path = d.file_path;`,
+		`ALTER TABLE search_feedback DROP CONSTRAINT IF EXISTS search_feedback_collection_id_query_file_path_key;`,
+		`ALTER TABLE search_feedback ADD CONSTRAINT search_feedback_collection_id_query_folder_file_path_key
+			UNIQUE (collection_id, query, folder, file_path);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migration029JudgeFilesByFolderDown drops the folder of judgments, keeping
+// the latest judgment of a path judged in several folders
+func (mm *MigrationManager) migration029JudgeFilesByFolderDown(tx *sql.Tx) error {
+	queries := []string{
+		`ALTER TABLE search_feedback DROP CONSTRAINT IF EXISTS search_feedback_collection_id_query_folder_file_path_key;`,
+		`DELETE FROM search_feedback a USING search_feedback b
+			WHERE a.collection_id = b.collection_id AND a.query = b.query AND a.file_path = b.file_path
+			AND (a.created_at, a.id) < (b.created_at, b.id);`,
+		`ALTER TABLE search_feedback DROP COLUMN IF EXISTS folder;`,
+		`ALTER TABLE search_feedback ADD CONSTRAINT search_feedback_collection_id_query_file_path_key
+			UNIQUE (collection_id, query, file_path);`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
 // GetEmbeddingDimensions gets the embedding dimensions for a collection
 func (mm *MigrationManager) GetEmbeddingDimensions(collectionID string) (int, error) {
 	var dimensions int
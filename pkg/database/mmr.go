@@ -0,0 +1,78 @@
+package database
+
+import "math"
+
+// Diversify picks limit results with maximal marginal relevance: each pick
+// is the result with the best lambda times its relevance minus (1 - lambda)
+// times its greatest similarity to the results already picked, so near
+// duplicate chunks give way to results covering other parts of the
+// collection. Relevance is the combined score scaled to 0-1 over the
+// results, and similarity the cosine similarity of the embeddings; results
+// without an embedding are similar to none. A lambda of 1 keeps the ranking.
+func Diversify(results []*SearchResult, lambda float64, limit int) []*SearchResult {
+	if limit > len(results) {
+		limit = len(results)
+	}
+	if len(results) == 0 {
+		return results
+	}
+
+	low, high := results[0].CombinedScore, results[0].CombinedScore
+	for _, result := range results {
+		low = math.Min(low, result.CombinedScore)
+		high = math.Max(high, result.CombinedScore)
+	}
+	relevance := func(result *SearchResult) float64 {
+		if high == low {
+			return 1
+		}
+		return (result.CombinedScore - low) / (high - low)
+	}
+
+	// similarity[i] is the greatest similarity of candidate i to the picks
+	candidates := append([]*SearchResult(nil), results...)
+	similarity := make([]float64, len(candidates))
+	picked := make([]*SearchResult, 0, limit)
+	for len(picked) < limit {
+		best, bestScore := -1, math.Inf(-1)
+		for i, candidate := range candidates {
+			if candidate == nil {
+				continue
+			}
+			score := lambda*relevance(candidate) - (1-lambda)*similarity[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		pick := candidates[best]
+		candidates[best] = nil
+		pick.Rank = len(picked) + 1
+		picked = append(picked, pick)
+		for i, candidate := range candidates {
+			if candidate != nil {
+				similarity[i] = math.Max(similarity[i], embeddingSimilarity(pick.Document, candidate.Document))
+			}
+		}
+	}
+	return picked
+}
+
+// embeddingSimilarity returns the cosine similarity of the embeddings of two
+// documents, or 0 when either has none
+func embeddingSimilarity(a, b *Document) float64 {
+	if a == nil || b == nil || len(a.Embedding) == 0 || len(a.Embedding) != len(b.Embedding) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a.Embedding {
+		dot += float64(a.Embedding[i]) * float64(b.Embedding[i])
+		normA += float64(a.Embedding[i]) * float64(a.Embedding[i])
+		normB += float64(b.Embedding[i]) * float64(b.Embedding[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
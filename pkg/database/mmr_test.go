@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiversify(t *testing.T) {
+	newResults := func() []*SearchResult {
+		return []*SearchResult{
+			{Document: &Document{ID: "install", Embedding: []float32{1, 0}}, CombinedScore: 0.9},
+			{Document: &Document{ID: "install-again", Embedding: []float32{1, 0.05}}, CombinedScore: 0.88},
+			{Document: &Document{ID: "upgrade", Embedding: []float32{0, 1}}, CombinedScore: 0.7},
+			{Document: &Document{ID: "unembedded"}, CombinedScore: 0.5},
+		}
+	}
+	ids := func(results []*SearchResult) []string {
+		var ids []string
+		for i, result := range results {
+			assert.Equal(t, i+1, result.Rank)
+			ids = append(ids, result.Document.ID)
+		}
+		return ids
+	}
+
+	// The near duplicate gives way to a result about something else
+	assert.Equal(t, []string{"install", "upgrade"}, ids(Diversify(newResults(), 0.5, 2)))
+	assert.Equal(t, []string{"install", "upgrade", "unembedded", "install-again"}, ids(Diversify(newResults(), 0.5, 10)))
+
+	// A lambda of 1 keeps the ranking
+	assert.Equal(t, []string{"install", "install-again", "upgrade"}, ids(Diversify(newResults(), 1, 3)))
+
+	require.Empty(t, Diversify(nil, 0.5, 3))
+}
+
+func TestEmbeddingSimilarity(t *testing.T) {
+	assert.InDelta(t, 1, embeddingSimilarity(&Document{Embedding: []float32{1, 1}}, &Document{Embedding: []float32{2, 2}}), 1e-9)
+	assert.InDelta(t, 0, embeddingSimilarity(&Document{Embedding: []float32{1, 0}}, &Document{Embedding: []float32{0, 1}}), 1e-9)
+	assert.Zero(t, embeddingSimilarity(&Document{Embedding: []float32{1, 0}}, &Document{}))
+	assert.Zero(t, embeddingSimilarity(&Document{Embedding: []float32{1, 0}}, &Document{Embedding: []float32{1, 0, 0}}))
+}
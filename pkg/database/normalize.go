@@ -0,0 +1,111 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ScoreNormalization is how scores from different sources are brought to a
+// common scale before they are weighted into a combined score. Vector
+// similarity, ts_rank and reranker scores live on different scales, so
+// weighting them as they are lets whichever is largest dominate.
+type ScoreNormalization string
+
+const (
+	// NormalizationNone combines the scores as they are
+	NormalizationNone ScoreNormalization = "none"
+	// NormalizationMinMax scales each score to 0-1 over the result set
+	NormalizationMinMax ScoreNormalization = "minmax"
+	// NormalizationZScore scales each score to its distance from the mean
+	// of the result set, in standard deviations
+	NormalizationZScore ScoreNormalization = "zscore"
+)
+
+// ParseScoreNormalization parses a score normalization name, where an empty
+// name means none
+func ParseScoreNormalization(s string) (ScoreNormalization, error) {
+	switch ScoreNormalization(strings.ToLower(strings.TrimSpace(s))) {
+	case "", NormalizationNone:
+		return NormalizationNone, nil
+	case NormalizationMinMax:
+		return NormalizationMinMax, nil
+	case NormalizationZScore:
+		return NormalizationZScore, nil
+	default:
+		return "", fmt.Errorf("invalid score normalization %q (must be %s, %s or %s)", s, NormalizationNone, NormalizationMinMax, NormalizationZScore)
+	}
+}
+
+// Enabled reports whether scores are normalized at all
+func (n ScoreNormalization) Enabled() bool {
+	return n == NormalizationMinMax || n == NormalizationZScore
+}
+
+// NormalizeScores returns the scores brought to a common scale. With min-max
+// normalization equal scores become 1, or 0 when they are all 0 (such as
+// text scores of results without text matches). With z-score normalization
+// equal scores become 0.
+func NormalizeScores(scores []float64, method ScoreNormalization) []float64 {
+	normalized := make([]float64, len(scores))
+	if len(scores) == 0 {
+		return normalized
+	}
+
+	switch method {
+	case NormalizationMinMax:
+		lowest, highest := scores[0], scores[0]
+		for _, score := range scores {
+			lowest = math.Min(lowest, score)
+			highest = math.Max(highest, score)
+		}
+		for i, score := range scores {
+			switch {
+			case highest > lowest:
+				normalized[i] = (score - lowest) / (highest - lowest)
+			case highest != 0:
+				normalized[i] = 1
+			}
+		}
+	case NormalizationZScore:
+		var sum float64
+		for _, score := range scores {
+			sum += score
+		}
+		mean := sum / float64(len(scores))
+		var variance float64
+		for _, score := range scores {
+			variance += (score - mean) * (score - mean)
+		}
+		stddev := math.Sqrt(variance / float64(len(scores)))
+		if stddev > 0 {
+			for i, score := range scores {
+				normalized[i] = (score - mean) / stddev
+			}
+		}
+	default:
+		copy(normalized, scores)
+	}
+	return normalized
+}
+
+// normalizeCombinedScores recomputes the combined scores of results from
+// their normalized vector and text scores, and sorts the results by them
+func normalizeCombinedScores(results []*SearchResult, vectorWeight, textWeight float64, method ScoreNormalization) {
+	vectorScores := make([]float64, len(results))
+	textScores := make([]float64, len(results))
+	for i, result := range results {
+		vectorScores[i] = result.VectorScore
+		textScores[i] = result.TextScore
+	}
+	vectorScores = NormalizeScores(vectorScores, method)
+	textScores = NormalizeScores(textScores, method)
+
+	for i, result := range results {
+		result.CombinedScore = vectorWeight*vectorScores[i] + textWeight*textScores[i]
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].CombinedScore > results[j].CombinedScore
+	})
+}
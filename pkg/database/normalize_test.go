@@ -0,0 +1,54 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScoreNormalization(t *testing.T) {
+	for input, expected := range map[string]ScoreNormalization{
+		"":        NormalizationNone,
+		"none":    NormalizationNone,
+		"MinMax":  NormalizationMinMax,
+		" zscore": NormalizationZScore,
+	} {
+		normalization, err := ParseScoreNormalization(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, normalization, input)
+	}
+
+	_, err := ParseScoreNormalization("softmax")
+	assert.Error(t, err)
+}
+
+func TestNormalizeScores(t *testing.T) {
+	scores := []float64{0.2, 0.6, 1.0}
+
+	assert.InDeltaSlice(t, []float64{0, 0.5, 1}, NormalizeScores(scores, NormalizationMinMax), 1e-9)
+	assert.InDeltaSlice(t, []float64{-1.224745, 0, 1.224745}, NormalizeScores(scores, NormalizationZScore), 1e-6)
+	assert.Equal(t, scores, NormalizeScores(scores, NormalizationNone))
+
+	// Equal scores
+	assert.Equal(t, []float64{1, 1}, NormalizeScores([]float64{0.4, 0.4}, NormalizationMinMax))
+	assert.Equal(t, []float64{0, 0}, NormalizeScores([]float64{0, 0}, NormalizationMinMax))
+	assert.Equal(t, []float64{0, 0}, NormalizeScores([]float64{0.4, 0.4}, NormalizationZScore))
+	assert.Empty(t, NormalizeScores(nil, NormalizationMinMax))
+}
+
+func TestNormalizeCombinedScores(t *testing.T) {
+	// Raw ts_rank scores are tiny next to vector scores, so the text match
+	// barely counts until both are on the same scale
+	results := []*SearchResult{
+		{Document: &Document{ID: "a"}, VectorScore: 0.90, TextScore: 0.01},
+		{Document: &Document{ID: "b"}, VectorScore: 0.85, TextScore: 0.09},
+		{Document: &Document{ID: "c"}, VectorScore: 0.50, TextScore: 0.05},
+	}
+
+	normalizeCombinedScores(results, 0.5, 0.5, NormalizationMinMax)
+	assert.Equal(t, "b", results[0].Document.ID)
+	assert.InDelta(t, 0.5*0.875+0.5*1, results[0].CombinedScore, 1e-9)
+	assert.Equal(t, "a", results[1].Document.ID)
+	assert.Equal(t, "c", results[2].Document.ID)
+}
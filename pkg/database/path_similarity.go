@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+// pathSeparators are the characters that separate the words of a file path
+var pathSeparators = strings.NewReplacer("/", " ", "\\", " ", "_", " ", "-", " ", ".", " ")
+
+// PathText returns the text embedded for a file path: its directories, name
+// and extension as words, e.g. "infra nginx ingress yaml" for
+// infra/nginx/ingress.yaml
+func PathText(filePath string) string {
+	return strings.Join(strings.Fields(pathSeparators.Replace(path.Clean(filePath))), " ")
+}
+
+// applyPathSimilarity adds the similarity between the query and the file
+// path of each result, times weight, to its combined score and returns the
+// best limit results. Chunks indexed without a path embedding get no boost.
+func (se *SearchEngineImpl) applyPathSimilarity(embedding []float32, results []*SearchResult, weight float64, limit int) ([]*SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Document.ID
+	}
+
+	rows, err := se.db.Query(`
+		SELECT id, 1 - (path_embedding <=> $2)
+		FROM documents
+		WHERE id = ANY($1)
+		  AND path_embedding IS NOT NULL
+		  AND vector_dims(path_embedding) = $3
+	`, pq.Array(ids), pgvector.NewVector(embedding), len(embedding))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path similarities: %w", err)
+	}
+	defer rows.Close()
+
+	scores := make(map[string]float64)
+	for rows.Next() {
+		var id string
+		var score float64
+		if err := rows.Scan(&id, &score); err != nil {
+			return nil, fmt.Errorf("failed to scan path similarity: %w", err)
+		}
+		scores[id] = score
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get path similarities: %w", err)
+	}
+
+	return boostByPath(results, scores, weight, limit), nil
+}
+
+// boostByPath adds the path scores of results, times weight, to their
+// combined scores and returns the best limit results
+func boostByPath(results []*SearchResult, scores map[string]float64, weight float64, limit int) []*SearchResult {
+	for _, result := range results {
+		result.PathScore = scores[result.Document.ID]
+		result.CombinedScore += weight * result.PathScore
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].CombinedScore > results[j].CombinedScore
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathText(t *testing.T) {
+	assert.Equal(t, "infra nginx ingress yaml", PathText("infra/nginx/ingress.yaml"))
+	assert.Equal(t, "docs my file md", PathText("./docs/my_file.md"))
+	assert.Equal(t, "Makefile", PathText("Makefile"))
+}
+
+func TestBoostByPath(t *testing.T) {
+	results := []*SearchResult{
+		{Document: &Document{ID: "readme"}, CombinedScore: 0.6},
+		{Document: &Document{ID: "ingress"}, CombinedScore: 0.5},
+		{Document: &Document{ID: "old"}, CombinedScore: 0.4},
+	}
+	scores := map[string]float64{"readme": 0.2, "ingress": 0.9}
+
+	boosted := boostByPath(results, scores, 0.2, 2)
+	assert.Len(t, boosted, 2)
+	assert.Equal(t, "ingress", boosted[0].Document.ID)
+	assert.InDelta(t, 0.68, boosted[0].CombinedScore, 1e-9)
+	assert.Equal(t, 0.9, boosted[0].PathScore)
+	assert.Equal(t, "readme", boosted[1].Document.ID)
+	assert.InDelta(t, 0.64, boosted[1].CombinedScore, 1e-9)
+}
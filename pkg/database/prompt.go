@@ -0,0 +1,100 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrPromptNotFound is returned when a prompt doesn't exist
+var ErrPromptNotFound = errors.New("prompt not found")
+
+// PromptManagerImpl implements PromptManager
+type PromptManagerImpl struct {
+	db *sql.DB
+}
+
+// NewPromptManager creates a new prompt manager
+func NewPromptManager(db *sql.DB) PromptManager {
+	return &PromptManagerImpl{db: db}
+}
+
+// SavePrompt creates a prompt, or replaces the prompt with the same name,
+// and fills in its timestamps
+func (pm *PromptManagerImpl) SavePrompt(prompt *Prompt) error {
+	err := pm.db.QueryRow(`
+		INSERT INTO prompts (name, description, content)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET description = EXCLUDED.description, content = EXCLUDED.content, updated_at = NOW()
+		RETURNING created_at, updated_at
+	`, prompt.Name, prompt.Description, prompt.Content).Scan(&prompt.CreatedAt, &prompt.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save prompt: %w", err)
+	}
+
+	return nil
+}
+
+// GetPrompt returns a prompt by name
+func (pm *PromptManagerImpl) GetPrompt(name string) (*Prompt, error) {
+	prompt := &Prompt{}
+	err := pm.db.QueryRow(`
+		SELECT name, description, content, created_at, updated_at
+		FROM prompts
+		WHERE name = $1
+	`, name).Scan(&prompt.Name, &prompt.Description, &prompt.Content, &prompt.CreatedAt, &prompt.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrPromptNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt: %w", err)
+	}
+
+	return prompt, nil
+}
+
+// ListPrompts returns all prompts sorted by name
+func (pm *PromptManagerImpl) ListPrompts() ([]*Prompt, error) {
+	rows, err := pm.db.Query(`
+		SELECT name, description, content, created_at, updated_at
+		FROM prompts
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	defer rows.Close()
+
+	var prompts []*Prompt
+	for rows.Next() {
+		prompt := &Prompt{}
+		if err := rows.Scan(&prompt.Name, &prompt.Description, &prompt.Content, &prompt.CreatedAt, &prompt.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt: %w", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+
+	return prompts, nil
+}
+
+// DeletePrompt deletes a prompt by name
+func (pm *PromptManagerImpl) DeletePrompt(name string) error {
+	result, err := pm.db.Exec(`DELETE FROM prompts WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete prompt: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete prompt: %w", err)
+	}
+	if deleted == 0 {
+		return ErrPromptNotFound
+	}
+
+	return nil
+}
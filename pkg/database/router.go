@@ -0,0 +1,234 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+const (
+	// routeCentroidWeight is the weight of the query's similarity to a collection's centroid
+	routeCentroidWeight = 0.6
+	// routeDescriptionWeight is the weight of the query's similarity to a collection's description embedding
+	routeDescriptionWeight = 0.3
+	// routeKeywordWeight is the weight of the query's keyword match with a collection's name and description
+	routeKeywordWeight = 0.1
+)
+
+// CollectionRoute is a collection picked by the router for a query
+type CollectionRoute struct {
+	Collection       *Collection `json:"collection"`
+	CentroidScore    float64     `json:"centroid_score"`    // Cosine similarity to the collection's centroid embedding
+	DescriptionScore float64     `json:"description_score"` // Cosine similarity to the collection's description embedding
+	KeywordScore     float64     `json:"keyword_score"`     // Keyword match with the collection's name and description (0-1)
+	Score            float64     `json:"score"`             // Combined routing score
+
+	hasCentroid    bool
+	hasDescription bool
+}
+
+// CollectionDescriptionText returns the text that is embedded to describe a collection
+func CollectionDescriptionText(collection *Collection) string {
+	if collection.Description == "" {
+		return collection.Name
+	}
+	return collection.Name + "\n" + collection.Description
+}
+
+// CollectionRouterImpl implements CollectionRouter
+type CollectionRouterImpl struct {
+	db *sql.DB
+}
+
+// NewCollectionRouter creates a new collection router
+func NewCollectionRouter(db *sql.DB) CollectionRouter {
+	return &CollectionRouterImpl{db: db}
+}
+
+// UpdateCentroid recomputes the centroid of a collection, the average of its
+// document embeddings, which represents what the collection is about
+func (cr *CollectionRouterImpl) UpdateCentroid(collectionID string) error {
+	query := `
+		UPDATE collections
+		SET centroid = (
+			SELECT AVG(embedding)
+			FROM documents
+			WHERE collection_id = $1 AND embedding IS NOT NULL
+		)
+		WHERE id = $1
+	`
+
+	if _, err := cr.db.Exec(query, collectionID); err != nil {
+		return fmt.Errorf("failed to update collection centroid: %w", err)
+	}
+
+	return nil
+}
+
+// StaleDescriptionEmbeddings returns the collections whose description
+// embedding is missing or was computed from an older name or description
+func (cr *CollectionRouterImpl) StaleDescriptionEmbeddings() ([]*Collection, error) {
+	query := `
+		SELECT id, name, description, folders, stats, created_at, updated_at,
+		       description_embedding IS NOT NULL, COALESCE(description_source, '')
+		FROM collections
+		WHERE snapshot_of IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := cr.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collections: %w", err)
+	}
+	defer rows.Close()
+
+	var stale []*Collection
+	for rows.Next() {
+		var statsJSON, source string
+		var embedded bool
+		collection := &Collection{}
+
+		err := rows.Scan(
+			&collection.ID,
+			&collection.Name,
+			&collection.Description,
+			pq.Array(&collection.Folders),
+			&statsJSON,
+			&collection.CreatedAt,
+			&collection.UpdatedAt,
+			&embedded,
+			&source,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %w", err)
+		}
+
+		// Parse stats JSON
+		if err := json.Unmarshal([]byte(statsJSON), &collection.Stats); err != nil {
+			return nil, fmt.Errorf("failed to parse stats: %w", err)
+		}
+
+		if !embedded || source != CollectionDescriptionText(collection) {
+			stale = append(stale, collection)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read collections: %w", err)
+	}
+
+	return stale, nil
+}
+
+// SetDescriptionEmbedding stores the embedding of a collection's description
+// along with the text it was computed from
+func (cr *CollectionRouterImpl) SetDescriptionEmbedding(collectionID, source string, embedding []float32) error {
+	query := `
+		UPDATE collections
+		SET description_embedding = $2, description_source = $3
+		WHERE id = $1
+	`
+
+	if _, err := cr.db.Exec(query, collectionID, pgvector.NewVector(embedding), source); err != nil {
+		return fmt.Errorf("failed to set description embedding: %w", err)
+	}
+
+	return nil
+}
+
+// RouteQuery ranks the collections by how likely they are to contain
+// material relevant to the query, using the similarity of the query embedding
+// to each collection's centroid and description embedding, and the keyword
+// match of the query with each collection's name and description. It returns
+// at most limit routes.
+func (cr *CollectionRouterImpl) RouteQuery(embedding []float32, textQuery string, limit int) ([]*CollectionRoute, error) {
+	if len(embedding) == 0 {
+		return nil, fmt.Errorf("query embedding is required for routing")
+	}
+
+	// Embeddings from a model of different dimensions can't be compared
+	query := `
+		SELECT id, name, description, folders, stats, created_at, updated_at,
+		       CASE WHEN vector_dims(centroid) = $3 THEN 1 - (centroid <=> $1) END AS centroid_score,
+		       CASE WHEN vector_dims(description_embedding) = $3 THEN 1 - (description_embedding <=> $1) END AS description_score,
+		       CASE WHEN $2 = '' THEN 0
+		            ELSE ts_rank_cd(to_tsvector('english', name || ' ' || COALESCE(description, '')), to_tsquery('english', $2), 32)
+		       END AS keyword_score
+		FROM collections
+		WHERE snapshot_of IS NULL AND (vector_dims(centroid) = $3 OR vector_dims(description_embedding) = $3)
+	`
+
+	rows, err := cr.db.Query(query, pgvector.NewVector(embedding), bm25Query(textQuery), len(embedding))
+	if err != nil {
+		return nil, fmt.Errorf("failed to route query: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []*CollectionRoute
+	for rows.Next() {
+		var statsJSON string
+		var centroidScore, descriptionScore sql.NullFloat64
+		route := &CollectionRoute{Collection: &Collection{}}
+
+		err := rows.Scan(
+			&route.Collection.ID,
+			&route.Collection.Name,
+			&route.Collection.Description,
+			pq.Array(&route.Collection.Folders),
+			&statsJSON,
+			&route.Collection.CreatedAt,
+			&route.Collection.UpdatedAt,
+			&centroidScore,
+			&descriptionScore,
+			&route.KeywordScore,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %w", err)
+		}
+		route.CentroidScore, route.hasCentroid = centroidScore.Float64, centroidScore.Valid
+		route.DescriptionScore, route.hasDescription = descriptionScore.Float64, descriptionScore.Valid
+
+		// Parse stats JSON
+		if err := json.Unmarshal([]byte(statsJSON), &route.Collection.Stats); err != nil {
+			return nil, fmt.Errorf("failed to parse stats: %w", err)
+		}
+
+		routes = append(routes, route)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read collections: %w", err)
+	}
+
+	return rankRoutes(routes, limit), nil
+}
+
+// rankRoutes combines the routing signals and returns the best routes first.
+// Collections that are not indexed yet, or whose description is not embedded
+// yet, are scored on the signals they have.
+func rankRoutes(routes []*CollectionRoute, limit int) []*CollectionRoute {
+	for _, route := range routes {
+		score := routeKeywordWeight * route.KeywordScore
+		weight := routeKeywordWeight
+		if route.hasCentroid {
+			score += routeCentroidWeight * route.CentroidScore
+			weight += routeCentroidWeight
+		}
+		if route.hasDescription {
+			score += routeDescriptionWeight * route.DescriptionScore
+			weight += routeDescriptionWeight
+		}
+		route.Score = score / weight
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Score > routes[j].Score
+	})
+
+	if limit > 0 && len(routes) > limit {
+		routes = routes[:limit]
+	}
+	return routes
+}
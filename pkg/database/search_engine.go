@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/lib/pq"
 	"github.com/pgvector/pgvector-go"
 )
 
@@ -66,48 +68,156 @@ func (se *SearchEngineImpl) SearchDocumentsWithOptions(collectionID string, embe
 		}
 	}
 
-	var results []*SearchResult
-	var err error
+	// Retrieve a larger pool for the reranker to choose the results from
+	reranking := opts.EnableReranking && se.reranker != nil
+	retrieve := limit
+	if reranking && opts.RetrieveLimit > limit {
+		retrieve = opts.RetrieveLimit
+	}
 
-	switch opts.SearchType {
-	case SearchTypeVector:
-		results, err = se.searchVectorOnly(collectionID, embedding, limit, opts)
-	case SearchTypeText:
-		results, err = se.searchTextOnly(collectionID, textQuery, limit, opts)
-	case SearchTypeHybrid:
-		results, err = se.searchHybrid(collectionID, embedding, textQuery, limit, opts)
-	case SearchTypeSemantic:
-		results, err = se.searchSemantic(collectionID, embedding, textQuery, limit, opts)
-	default:
-		results, err = se.searchHybrid(collectionID, embedding, textQuery, limit, opts)
+	// Maximal marginal relevance chooses diverse results from a larger pool too
+	diversify := opts.MMRLambda > 0 && opts.MMRLambda < 1
+	if diversify {
+		retrieve = max(retrieve, fusionCandidates(limit))
+	}
+
+	// Fetch extra candidates when the results are fused with query variants
+	// or reordered by path similarity
+	pathSimilarity := opts.PathWeight > 0 && embedding != nil
+	candidates := retrieve
+	if len(opts.Variants) > 0 || pathSimilarity {
+		candidates = fusionCandidates(retrieve)
 	}
 
+	results, err := se.search(collectionID, embedding, textQuery, candidates, opts)
 	if err != nil {
 		return nil, err
 	}
 
+	// Search the query variants too and fuse all rankings
+	if len(opts.Variants) > 0 {
+		rankings := [][]*SearchResult{results}
+		weights := []float64{1}
+		for _, variant := range opts.Variants {
+			variantResults, err := se.search(collectionID, variant.Embedding, variant.Text, candidates, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to search query variant %q: %w", variant.Text, err)
+			}
+			rankings = append(rankings, variantResults)
+			weights = append(weights, queryVariantWeight)
+		}
+		results = FuseRankings(rankings, weights, candidates)
+	}
+
+	if pathSimilarity {
+		results, err = se.applyPathSimilarity(embedding, results, opts.PathWeight, retrieve)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(results) > retrieve {
+		results = results[:retrieve]
+	}
+
+	// Apply boosting rules before reranking so the boosted score is the original score
+	results = applyBoosts(results, opts.Boosts, time.Now())
+
 	// Apply reranking if enabled and reranker is available
-	if opts.EnableReranking && se.reranker != nil {
+	if reranking {
 		results, err = se.applyReranking(context.Background(), textQuery, results, opts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to apply reranking: %w", err)
 		}
 	}
+	if diversify {
+		results = Diversify(results, opts.MMRLambda, limit)
+	} else if len(results) > limit {
+		results = results[:limit]
+	}
+
+	// Join the chunks around each result to its content
+	if opts.ContextChunks > 0 {
+		results, err = ExpandContext(results, opts.ContextChunks, se.chunkRange)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Keep the top results that fit in the token budget
+	if opts.MaxTokens > 0 {
+		if err := se.loadTokenCounts(results); err != nil {
+			return nil, err
+		}
+		results = FitTokenBudget(results, opts.MaxTokens)
+	}
 
 	return results, nil
 }
 
+// queryVariantWeight is the weight of each query variant's ranking relative to the original query
+const queryVariantWeight = 0.8
+
+// search runs a single query with the configured search type
+func (se *SearchEngineImpl) search(collectionID string, embedding []float32, textQuery string, limit int, opts *SearchOptions) ([]*SearchResult, error) {
+	switch opts.SearchType {
+	case SearchTypeVector:
+		return se.searchVectorOnly(collectionID, embedding, limit, opts)
+	case SearchTypeText:
+		return se.searchTextOnly(collectionID, textQuery, limit, opts)
+	case SearchTypeHybrid:
+		return se.searchHybrid(collectionID, embedding, textQuery, limit, opts)
+	case SearchTypeSemantic:
+		return se.searchSemantic(collectionID, embedding, textQuery, limit, opts)
+	case SearchTypeBM25:
+		return se.searchBM25(collectionID, textQuery, limit, opts)
+	case SearchTypeFusion:
+		return se.searchFusion(collectionID, embedding, textQuery, limit, opts)
+	default:
+		return se.searchHybrid(collectionID, embedding, textQuery, limit, opts)
+	}
+}
+
+// vectorDistance returns the SQL for the cosine distance between a
+// collection's embeddings and the query vector in param, and the expression
+// to order by so the collection's vector index is used. Collections with
+// normalized embeddings are searched by inner product, which ranks unit
+// vectors the same but is cheaper.
+func (se *SearchEngineImpl) vectorDistance(collectionID, param string) (string, string, error) {
+	var normalized bool
+	err := se.db.QueryRow(`SELECT normalized FROM collections WHERE id = $1`, collectionID).Scan(&normalized)
+	if err != nil && err != sql.ErrNoRows {
+		return "", "", fmt.Errorf("failed to get normalized flag: %w", err)
+	}
+	distance, order := distanceSQL(normalized, param)
+	return distance, order, nil
+}
+
+// distanceSQL returns the cosine distance and ordering expressions for the
+// query vector in param. For unit vectors the negative inner product of <#>
+// is the cosine distance minus 1.
+func distanceSQL(normalized bool, param string) (string, string) {
+	if normalized {
+		return fmt.Sprintf("(1 + (embedding <#> %s))", param), fmt.Sprintf("embedding <#> %s", param)
+	}
+	return fmt.Sprintf("(embedding <=> %s)", param), fmt.Sprintf("embedding <=> %s", param)
+}
+
 // searchVectorOnly performs vector similarity search only
 func (se *SearchEngineImpl) searchVectorOnly(collectionID string, embedding []float32, limit int, opts *SearchOptions) ([]*SearchResult, error) {
-	query := `
-		SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at,
-		       1 - (embedding <=> $2) as vector_score
+	distance, order, err := se.vectorDistance(collectionID, "$2")
+	if err != nil {
+		return nil, err
+	}
+	where, whereArgs := metadataConditionsSQL(opts, "metadata", 6)
+	query := fmt.Sprintf(`
+		SELECT id, collection_id, COALESCE(folder, ''), file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at,
+		       1 - %s as vector_score
 		FROM documents
 		WHERE collection_id = $1
-		  AND (embedding <=> $2) <= $3
-		ORDER BY embedding <=> $2 ASC
+		  AND %s <= $3
+		  AND ($5::text[] IS NULL OR file_type = ANY($5::text[]))%s
+		ORDER BY %s ASC
 		LIMIT $4
-	`
+	`, distance, distance, where, order)
 
 	searchVector := pgvector.NewVector(embedding)
 	maxDistance := opts.MaxDistance
@@ -115,7 +225,8 @@ func (se *SearchEngineImpl) searchVectorOnly(collectionID string, embedding []fl
 		maxDistance = 1.0
 	}
 
-	rows, err := se.db.Query(query, collectionID, searchVector, maxDistance, limit)
+	args := append([]interface{}{collectionID, searchVector, maxDistance, limit, pq.Array(opts.FileTypes)}, whereArgs...)
+	rows, err := se.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search documents: %w", err)
 	}
@@ -130,6 +241,7 @@ func (se *SearchEngineImpl) searchVectorOnly(collectionID string, embedding []fl
 		err := rows.Scan(
 			&doc.ID,
 			&doc.CollectionID,
+			&doc.Folder,
 			&doc.FilePath,
 			&doc.FileName,
 			&doc.Content,
@@ -147,6 +259,9 @@ func (se *SearchEngineImpl) searchVectorOnly(collectionID string, embedding []fl
 
 		// Convert pgvector.Vector back to []float32
 		doc.Embedding = embeddingVector.Slice()
+		if err := decryptContent(&doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+		}
 
 		result := &SearchResult{
 			Document:      doc,
@@ -160,28 +275,41 @@ func (se *SearchEngineImpl) searchVectorOnly(collectionID string, embedding []fl
 	return results, nil
 }
 
+// textSearchQuery returns the SQL for the tsquery of the text query in
+// param. Text queries use the web search syntax: words are all required,
+// "quoted words" match as a phrase, or between words matches either, and
+// -word leaves out the documents with the word. Punctuation is ignored, so
+// any text is a valid query.
+func textSearchQuery(param string) string {
+	return fmt.Sprintf("websearch_to_tsquery('english', %s)", param)
+}
+
 // searchTextOnly performs full-text search only
 func (se *SearchEngineImpl) searchTextOnly(collectionID string, textQuery string, limit int, opts *SearchOptions) ([]*SearchResult, error) {
 	if textQuery == "" {
 		return nil, fmt.Errorf("text query is required for text search")
 	}
 
-	// Build the text search query
-	searchQuery := fmt.Sprintf("to_tsquery('english', '%s')", strings.ReplaceAll(textQuery, " ", " & "))
+	// The stored term vector ranks the symbols a code chunk defines above
+	// the rest of its content
+	searchQuery := textSearchQuery("$4")
 
 	query := `
-		SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at,
-		       ts_rank(to_tsvector('english', content), %s) as text_score
+		SELECT id, collection_id, COALESCE(folder, ''), file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at,
+		       ts_rank(content_tsv, %s) as text_score
 		FROM documents
 		WHERE collection_id = $1
-		  AND to_tsvector('english', content) @@ %s
+		  AND content_tsv @@ %s
+		  AND ($3::text[] IS NULL OR file_type = ANY($3::text[]))%s
 		ORDER BY text_score DESC
 		LIMIT $2
 	`
 
-	query = fmt.Sprintf(query, searchQuery, searchQuery)
+	where, whereArgs := metadataConditionsSQL(opts, "metadata", 5)
+	query = fmt.Sprintf(query, searchQuery, searchQuery, where)
 
-	rows, err := se.db.Query(query, collectionID, limit)
+	args := append([]interface{}{collectionID, limit, pq.Array(opts.FileTypes), textQuery}, whereArgs...)
+	rows, err := se.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search documents: %w", err)
 	}
@@ -196,6 +324,7 @@ func (se *SearchEngineImpl) searchTextOnly(collectionID string, textQuery string
 		err := rows.Scan(
 			&doc.ID,
 			&doc.CollectionID,
+			&doc.Folder,
 			&doc.FilePath,
 			&doc.FileName,
 			&doc.Content,
@@ -213,6 +342,9 @@ func (se *SearchEngineImpl) searchTextOnly(collectionID string, textQuery string
 
 		// Convert pgvector.Vector back to []float32
 		doc.Embedding = embeddingVector.Slice()
+		if err := decryptContent(&doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+		}
 
 		result := &SearchResult{
 			Document:      doc,
@@ -239,32 +371,45 @@ func (se *SearchEngineImpl) searchHybrid(collectionID string, embedding []float3
 	vectorWeight := opts.VectorWeight / totalWeight
 	textWeight := opts.TextWeight / totalWeight
 
+	// Fetch extra candidates when the scores are normalized, since the
+	// normalized combined score can rank them differently than the raw one
+	candidates := limit
+	if opts.Normalization.Enabled() {
+		candidates = fusionCandidates(limit)
+	}
+
 	// Build the query based on available inputs
 	var query string
 	var args []interface{}
 
 	if embedding != nil && textQuery != "" {
 		// Both vector and text search
-		searchQuery := fmt.Sprintf("to_tsquery('english', '%s')", strings.ReplaceAll(textQuery, " ", " & "))
+		distance, _, err := se.vectorDistance(collectionID, "$2")
+		if err != nil {
+			return nil, err
+		}
+		searchQuery := textSearchQuery("$8")
 		query = `
-			SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at,
-			       1 - (embedding <=> $2) as vector_score,
-			       ts_rank(to_tsvector('english', content), %s) as text_score,
-			       ($5 * (1 - (embedding <=> $2))) + ($6 * ts_rank(to_tsvector('english', content), %s)) as combined_score
+			SELECT id, collection_id, COALESCE(folder, ''), file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at,
+			       1 - %s as vector_score,
+			       ts_rank(content_tsv, %s) as text_score,
+			       ($5 * (1 - %s)) + ($6 * ts_rank(content_tsv, %s)) as combined_score
 			FROM documents
 			WHERE collection_id = $1
-			  AND (embedding <=> $2) <= $3
-			  AND to_tsvector('english', content) @@ %s
+			  AND %s <= $3
+			  AND content_tsv @@ %s
+			  AND ($7::text[] IS NULL OR file_type = ANY($7::text[]))%s
 			ORDER BY combined_score DESC
 			LIMIT $4
 		`
-		query = fmt.Sprintf(query, searchQuery, searchQuery, searchQuery)
+		where, whereArgs := metadataConditionsSQL(opts, "metadata", 9)
+		query = fmt.Sprintf(query, distance, searchQuery, distance, searchQuery, distance, searchQuery, where)
 		searchVector := pgvector.NewVector(embedding)
 		maxDistance := opts.MaxDistance
 		if maxDistance <= 0 {
 			maxDistance = 1.0
 		}
-		args = []interface{}{collectionID, searchVector, maxDistance, limit, vectorWeight, textWeight}
+		args = append([]interface{}{collectionID, searchVector, maxDistance, candidates, vectorWeight, textWeight, pq.Array(opts.FileTypes), textQuery}, whereArgs...)
 	} else if embedding != nil {
 		// Vector search only
 		return se.searchVectorOnly(collectionID, embedding, limit, opts)
@@ -290,6 +435,7 @@ func (se *SearchEngineImpl) searchHybrid(collectionID string, embedding []float3
 		err := rows.Scan(
 			&doc.ID,
 			&doc.CollectionID,
+			&doc.Folder,
 			&doc.FilePath,
 			&doc.FileName,
 			&doc.Content,
@@ -309,6 +455,9 @@ func (se *SearchEngineImpl) searchHybrid(collectionID string, embedding []float3
 
 		// Convert pgvector.Vector back to []float32
 		doc.Embedding = embeddingVector.Slice()
+		if err := decryptContent(&doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+		}
 
 		result := &SearchResult{
 			Document:      doc,
@@ -319,6 +468,13 @@ func (se *SearchEngineImpl) searchHybrid(collectionID string, embedding []float3
 		results = append(results, result)
 	}
 
+	if opts.Normalization.Enabled() {
+		normalizeCombinedScores(results, vectorWeight, textWeight, opts.Normalization)
+		if len(results) > limit {
+			results = results[:limit]
+		}
+	}
+
 	return results, nil
 }
 
@@ -337,7 +493,14 @@ func (se *SearchEngineImpl) searchSemantic(collectionID string, embedding []floa
 	// File name filter
 	if opts.FileFilter != "" {
 		filters = append(filters, fmt.Sprintf("file_name ILIKE $%d", argIndex))
-		args = append(args, "%"+opts.FileFilter+"%")
+		args = append(args, GlobToLike(opts.FileFilter))
+		argIndex++
+	}
+
+	// File type filter
+	if len(opts.FileTypes) > 0 {
+		filters = append(filters, fmt.Sprintf("file_type = ANY($%d)", argIndex))
+		args = append(args, pq.Array(opts.FileTypes))
 		argIndex++
 	}
 
@@ -351,16 +514,26 @@ func (se *SearchEngineImpl) searchSemantic(collectionID string, embedding []floa
 	// Build the WHERE clause
 	whereClause := strings.Join(filters, " AND ")
 
+	// Custom metadata filters
+	where, whereArgs := metadataConditionsSQL(opts, "metadata", argIndex)
+	whereClause += where
+	args = append(args, whereArgs...)
+	argIndex += len(whereArgs)
+
 	// Build the query
+	distance, order, err := se.vectorDistance(collectionID, fmt.Sprintf("$%d", argIndex))
+	if err != nil {
+		return nil, err
+	}
 	query := fmt.Sprintf(`
-		SELECT id, collection_id, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at,
-		       1 - (embedding <=> $%d) as vector_score
+		SELECT id, collection_id, COALESCE(folder, ''), file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at,
+		       1 - %s as vector_score
 		FROM documents
 		WHERE %s
-		  AND (embedding <=> $%d) <= $%d
-		ORDER BY embedding <=> $%d ASC
+		  AND %s <= $%d
+		ORDER BY %s ASC
 		LIMIT $%d
-	`, argIndex, whereClause, argIndex, argIndex+1, argIndex, argIndex+2)
+	`, distance, whereClause, distance, argIndex+1, order, argIndex+2)
 
 	searchVector := pgvector.NewVector(embedding)
 	maxDistance := opts.MaxDistance
@@ -384,6 +557,7 @@ func (se *SearchEngineImpl) searchSemantic(collectionID string, embedding []floa
 		err := rows.Scan(
 			&doc.ID,
 			&doc.CollectionID,
+			&doc.Folder,
 			&doc.FilePath,
 			&doc.FileName,
 			&doc.Content,
@@ -401,6 +575,9 @@ func (se *SearchEngineImpl) searchSemantic(collectionID string, embedding []floa
 
 		// Convert pgvector.Vector back to []float32
 		doc.Embedding = embeddingVector.Slice()
+		if err := decryptContent(&doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+		}
 
 		result := &SearchResult{
 			Document:      doc,
@@ -444,12 +621,26 @@ func (se *SearchEngineImpl) applyReranking(ctx context.Context, textQuery string
 		rerankMap[rr.Document] = &rr
 	}
 
-	// Update search results with reranking scores
+	// Bring the original and reranking scores of the reranked results to a
+	// common scale if enabled
+	var reranked []*SearchResult
+	var originalScores, rerankingScores []float64
 	for _, result := range results {
+		if rerankResult, exists := rerankMap[result.Document.Content]; exists {
+			reranked = append(reranked, result)
+			originalScores = append(originalScores, result.CombinedScore)
+			rerankingScores = append(rerankingScores, rerankResult.Score)
+		}
+	}
+	originalScores = NormalizeScores(originalScores, opts.Normalization)
+	rerankingScores = NormalizeScores(rerankingScores, opts.Normalization)
+
+	// Update search results with reranking scores
+	for i, result := range reranked {
 		if rerankResult, exists := rerankMap[result.Document.Content]; exists {
 			// Update the combined score using the specified weights
-			originalScore := result.CombinedScore
-			rerankingScore := rerankResult.Score
+			originalScore := originalScores[i]
+			rerankingScore := rerankingScores[i]
 
 			// Use default weights if not specified
 			originalWeight := opts.OriginalWeight
@@ -31,6 +31,8 @@ func TestSearchTypeString(t *testing.T) {
 		{SearchTypeText, "text"},
 		{SearchTypeHybrid, "hybrid"},
 		{SearchTypeSemantic, "semantic"},
+		{SearchTypeBM25, "bm25"},
+		{SearchTypeFusion, "fusion"},
 	}
 
 	for _, test := range tests {
@@ -128,3 +130,77 @@ func TestGetSearchStatsEmpty(t *testing.T) {
 	assert.Equal(t, 0.0, stats["min_score"], "Should have 0.0 min score")
 	assert.Equal(t, 0.0, stats["max_score"], "Should have 0.0 max score")
 }
+
+func TestBM25Query(t *testing.T) {
+	assert.Equal(t, "connection | pool | timeout", bm25Query("connection pool timeout"))
+	assert.Equal(t, "it | s | a | b", bm25Query("it's a & b!"))
+	assert.Equal(t, "", bm25Query("' & | !"))
+}
+
+func TestFuseResults(t *testing.T) {
+	doc := func(id string) *Document { return &Document{ID: id} }
+
+	dense := []*SearchResult{
+		{Document: doc("a"), VectorScore: 0.9},
+		{Document: doc("b"), VectorScore: 0.8},
+		{Document: doc("c"), VectorScore: 0.7},
+	}
+	sparse := []*SearchResult{
+		{Document: doc("b"), TextScore: 1.0},
+		{Document: doc("d"), TextScore: 0.6},
+	}
+
+	results := fuseResults(dense, sparse, 0.5, 0.5, 3)
+	require.Len(t, results, 3)
+
+	// "b" is found by both retrievers so it ranks first
+	assert.Equal(t, "b", results[0].Document.ID)
+	assert.Equal(t, 0.8, results[0].VectorScore)
+	assert.Equal(t, 1.0, results[0].TextScore)
+	assert.Equal(t, "a", results[1].Document.ID)
+	for i, result := range results {
+		assert.Equal(t, i+1, result.Rank)
+		assert.LessOrEqual(t, result.CombinedScore, 1.0)
+	}
+
+	// A document ranked first by both retrievers scores 1
+	results = fuseResults(dense[:1], []*SearchResult{{Document: doc("a")}}, 0.7, 0.3, 10)
+	require.Len(t, results, 1)
+	assert.InDelta(t, 1.0, results[0].CombinedScore, 1e-9)
+}
+
+func TestFuseRankings(t *testing.T) {
+	doc := func(id string) *Document { return &Document{ID: id} }
+
+	// The original query and a query variant each find one document first
+	original := []*SearchResult{
+		{Document: doc("a"), VectorScore: 0.9},
+		{Document: doc("b"), VectorScore: 0.5},
+	}
+	variant := []*SearchResult{
+		{Document: doc("c"), VectorScore: 0.95},
+		{Document: doc("b"), VectorScore: 0.85},
+	}
+
+	results := FuseRankings([][]*SearchResult{original, variant}, []float64{1, 0.8}, 0)
+	require.Len(t, results, 3)
+
+	// "b" is found by both queries and keeps its best vector score
+	assert.Equal(t, "b", results[0].Document.ID)
+	assert.Equal(t, 0.85, results[0].VectorScore)
+	// The original query outweighs the variant
+	assert.Equal(t, "a", results[1].Document.ID)
+	assert.Equal(t, "c", results[2].Document.ID)
+
+	assert.Empty(t, FuseRankings([][]*SearchResult{original}, []float64{0}, 10))
+}
+
+func TestDistanceSQL(t *testing.T) {
+	distance, order := distanceSQL(false, "$2")
+	assert.Equal(t, "(embedding <=> $2)", distance)
+	assert.Equal(t, "embedding <=> $2", order)
+
+	distance, order = distanceSQL(true, "$4")
+	assert.Equal(t, "(1 + (embedding <#> $4))", distance)
+	assert.Equal(t, "embedding <#> $4", order)
+}
@@ -0,0 +1,230 @@
+//go:build integration
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// searchFixtures is a small corpus with the queries it should answer, read
+// from testdata/search_fixtures.json. Embeddings only list their leading
+// values; each dimension stands for a topic, so the expected results are
+// clear for both vector and text search.
+type searchFixtures struct {
+	Documents []struct {
+		FilePath  string            `json:"file_path"`
+		Content   string            `json:"content"`
+		Metadata  map[string]string `json:"metadata"`
+		Embedding []float32         `json:"embedding"`
+	} `json:"documents"`
+	Queries []struct {
+		Text        string       `json:"text"`
+		Embedding   []float32    `json:"embedding"`
+		Expected    string       `json:"expected"` // Path of the file that should rank first
+		SearchTypes []SearchType `json:"search_types"`
+	} `json:"queries"`
+}
+
+// loadSearchFixtures indexes the fixture corpus into a new collection
+func loadSearchFixtures(t testing.TB, db *sql.DB) (*searchFixtures, *Collection) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/search_fixtures.json")
+	require.NoError(t, err)
+	fixtures := &searchFixtures{}
+	require.NoError(t, json.Unmarshal(data, fixtures))
+
+	collection := newTestCollection(t, db, "search-fixtures")
+	dm := NewDocumentManager(db)
+	for _, doc := range fixtures.Documents {
+		metadata, err := json.Marshal(doc.Metadata)
+		require.NoError(t, err)
+		require.NoError(t, dm.InsertDocument(&Document{
+			CollectionID: collection.ID,
+			Folder:       "/docs",
+			FilePath:     doc.FilePath,
+			FileName:     path.Base(doc.FilePath),
+			Content:      doc.Content,
+			Embedding:    testEmbedding(doc.Embedding...),
+			Metadata:     string(metadata),
+		}))
+	}
+	require.NoError(t, NewCollectionManager(db).UpdateCollectionStats(collection.ID))
+
+	return fixtures, collection
+}
+
+// fixtureSearchOptions returns the options the fixture queries are searched with
+func fixtureSearchOptions(searchType SearchType) *SearchOptions {
+	return &SearchOptions{
+		SearchType:   searchType,
+		VectorWeight: 0.7,
+		TextWeight:   0.3,
+		MaxDistance:  2.0, // Cosine distances go up to 2, so no result is cut off
+	}
+}
+
+func TestIntegrationSearchFixtures(t *testing.T) {
+	db := newMigratedTestDB(t)
+	fixtures, collection := loadSearchFixtures(t, db)
+	se := NewSearchEngine(db)
+
+	// Reciprocal rank of the expected file per search type, so a regression
+	// in one search type's SQL shows up in its own line of the log
+	reciprocalRanks := make(map[SearchType][]float64)
+	for _, query := range fixtures.Queries {
+		for _, searchType := range query.SearchTypes {
+			t.Run(string(searchType)+"/"+query.Text, func(t *testing.T) {
+				results, err := se.SearchDocumentsWithOptions(collection.ID, testEmbedding(query.Embedding...), query.Text, 5, fixtureSearchOptions(searchType))
+				require.NoError(t, err)
+				require.NotEmpty(t, results)
+
+				reciprocalRank := 0.0
+				for i, result := range results {
+					if result.Document.FilePath == query.Expected {
+						reciprocalRank = 1 / float64(i+1)
+						break
+					}
+				}
+				reciprocalRanks[searchType] = append(reciprocalRanks[searchType], reciprocalRank)
+				assert.Equal(t, query.Expected, results[0].Document.FilePath)
+			})
+		}
+	}
+
+	for searchType, ranks := range reciprocalRanks {
+		total := 0.0
+		for _, rank := range ranks {
+			total += rank
+		}
+		t.Logf("%s: MRR %.2f over %d queries", searchType, total/float64(len(ranks)), len(ranks))
+	}
+}
+
+func TestIntegrationSearchFilters(t *testing.T) {
+	db := newMigratedTestDB(t)
+	fixtures, collection := loadSearchFixtures(t, db)
+	se := NewSearchEngine(db)
+	query := fixtures.Queries[0]
+
+	schema, err := ParseMetadataSchema([]string{"team:string", "priority:number"})
+	require.NoError(t, err)
+	where, err := ParseMetadataFilters([]string{"priority>=3"}, schema)
+	require.NoError(t, err)
+
+	opts := fixtureSearchOptions(SearchTypeVector)
+	opts.Where = where
+	results, err := se.SearchDocumentsWithOptions(collection.ID, testEmbedding(query.Embedding...), query.Text, 10, opts)
+	require.NoError(t, err)
+	var paths []string
+	for _, result := range results {
+		paths = append(paths, result.Document.FilePath)
+	}
+	assert.ElementsMatch(t, []string{"auth/password-reset.md", "ops/backups.md"}, paths)
+
+	// Metadata key-value pairs narrow the filtered results further, with any
+	// search type
+	for _, searchType := range []SearchType{SearchTypeVector, SearchTypeSemantic, SearchTypeHybrid, SearchTypeFusion} {
+		opts = fixtureSearchOptions(searchType)
+		opts.Where = where
+		opts.Metadata = map[string]string{"team": "identity"}
+		results, err = se.SearchDocumentsWithOptions(collection.ID, testEmbedding(query.Embedding...), query.Text, 10, opts)
+		require.NoError(t, err, searchType)
+		require.Len(t, results, 1, searchType)
+		assert.Equal(t, "auth/password-reset.md", results[0].Document.FilePath, searchType)
+	}
+
+	opts = fixtureSearchOptions(SearchTypeBM25)
+	opts.FileTypes = []string{"go"}
+	results, err = se.SearchDocumentsWithOptions(collection.ID, nil, "ParseConfig defaults", 10, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "config/parse.go", results[0].Document.FilePath)
+
+	// A budget that only fits the top result returns it alone
+	opts = fixtureSearchOptions(SearchTypeVector)
+	opts.MaxTokens = client.EstimateTokens(fixtures.Documents[0].Content)
+	results, err = se.SearchDocumentsWithOptions(collection.ID, testEmbedding(query.Embedding...), query.Text, 10, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, fixtures.Documents[0].FilePath, results[0].Document.FilePath)
+}
+
+func TestIntegrationSearchContextChunks(t *testing.T) {
+	db := newMigratedTestDB(t)
+	collection := newTestCollection(t, db, "context-chunks")
+	dm := NewDocumentManager(db)
+	chunks := []string{"Install the tool. ", "Configure the database. ", "Run the first index. ", "Search the collection. "}
+	for i, content := range chunks {
+		embedding := make([]float32, len(chunks))
+		embedding[i] = 1
+		require.NoError(t, dm.InsertDocument(&Document{
+			CollectionID: collection.ID,
+			Folder:       "/docs",
+			FilePath:     "guide.md",
+			FileName:     "guide.md",
+			Content:      content,
+			ChunkIndex:   i,
+			Embedding:    testEmbedding(embedding...),
+			Metadata:     "{}",
+		}))
+	}
+
+	opts := fixtureSearchOptions(SearchTypeVector)
+	opts.ContextChunks = 1
+	results, err := NewSearchEngine(db).SearchDocumentsWithOptions(collection.ID, testEmbedding(0, 1), "", 1, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, chunks[0]+chunks[1]+chunks[2], results[0].Document.Content)
+	assert.Equal(t, []int{0, 1, 2}, results[0].ContextChunks)
+}
+
+func TestIntegrationTextSearchSyntax(t *testing.T) {
+	db := newMigratedTestDB(t)
+	_, collection := loadSearchFixtures(t, db)
+	se := NewSearchEngine(db)
+
+	search := func(query string) []string {
+		t.Helper()
+		results, err := se.SearchDocumentsWithOptions(collection.ID, nil, query, 10, fixtureSearchOptions(SearchTypeText))
+		require.NoError(t, err)
+		var paths []string
+		for _, result := range results {
+			paths = append(paths, result.Document.FilePath)
+		}
+		return paths
+	}
+
+	// Punctuation and quotes are part of the query, not of the SQL
+	assert.Equal(t, []string{"auth/password-reset.md"}, search("forgotten password?"))
+	assert.Empty(t, search("x'); DROP TABLE documents; --"))
+	assert.Equal(t, []string{"ops/deployments.md"}, search(`"health check"`))
+	assert.Empty(t, search(`"check health"`))
+	assert.ElementsMatch(t, []string{"billing/refunds.md", "ops/backups.md"}, search("refund or pg_dump"))
+	assert.NotContains(t, search("invoice -refund"), "billing/refunds.md")
+}
+
+func BenchmarkIntegrationSearch(b *testing.B) {
+	db := newMigratedTestDB(b)
+	fixtures, collection := loadSearchFixtures(b, db)
+	se := NewSearchEngine(db)
+
+	for _, searchType := range []SearchType{SearchTypeVector, SearchTypeText, SearchTypeHybrid, SearchTypeBM25, SearchTypeFusion} {
+		b.Run(string(searchType), func(b *testing.B) {
+			opts := fixtureSearchOptions(searchType)
+			for i := 0; i < b.N; i++ {
+				query := fixtures.Queries[i%len(fixtures.Queries)]
+				if _, err := se.SearchDocumentsWithOptions(collection.ID, testEmbedding(query.Embedding...), query.Text, 5, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+// SharedEmbeddings are the embeddings of a file's chunks and path already
+// stored in other collections, so content indexed into several collections,
+// such as a monorepo shared by teams, is embedded once
+type SharedEmbeddings struct {
+	Chunks map[string][]float32 // Chunk embeddings by content hash (see KeyedContentHash)
+	Path   []float32            // Embedding of the file path, nil when not found
+}
+
+// FindSharedEmbeddings finds the embeddings of chunks with the given content
+// hashes, and of the file path, stored in collections other than the given
+// one by the same embedding model with the same dimensions. Embeddings only
+// depend on the embedded text, so they can be copied instead of embedding
+// the text again.
+func (dm *DocumentManagerImpl) FindSharedEmbeddings(collectionID, model string, dimensions int, filePath string, hashes []string) (*SharedEmbeddings, error) {
+	shared := &SharedEmbeddings{Chunks: make(map[string][]float32)}
+
+	rows, err := dm.db.Query(`
+		SELECT DISTINCT ON (d.content_hash) d.content_hash, d.embedding
+		FROM documents d
+		JOIN embedding_config e ON e.collection_id = d.collection_id
+		WHERE d.collection_id <> $1 AND e.model_name = $2 AND e.dimensions = $3
+			AND d.content_hash = ANY($4) AND d.embedding IS NOT NULL AND vector_dims(d.embedding) = $3`,
+		collectionID, model, dimensions, pq.Array(hashes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		var embedding pgvector.Vector
+		if err := rows.Scan(&hash, &embedding); err != nil {
+			return nil, fmt.Errorf("failed to scan shared embedding: %w", err)
+		}
+		shared.Chunks[hash] = embedding.Slice()
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read shared embeddings: %w", err)
+	}
+
+	// Paths are embedded relative to their folder, so the same path in
+	// another collection has the same embedding
+	var path pgvector.Vector
+	err = dm.db.QueryRow(`
+		SELECT d.path_embedding
+		FROM documents d
+		JOIN embedding_config e ON e.collection_id = d.collection_id
+		WHERE d.collection_id <> $1 AND e.model_name = $2 AND e.dimensions = $3
+			AND d.file_path = $4 AND d.path_embedding IS NOT NULL AND vector_dims(d.path_embedding) = $3
+		LIMIT 1`,
+		collectionID, model, dimensions, filePath).Scan(&path)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, fmt.Errorf("failed to query shared path embedding: %w", err)
+	default:
+		shared.Path = path.Slice()
+	}
+
+	return shared, nil
+}
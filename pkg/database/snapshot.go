@@ -0,0 +1,264 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrSnapshotNotFound is returned when a collection has no snapshot of the given name
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// snapshotNamePattern matches the names snapshots can be given
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Snapshot is a copy of a collection's documents at a point in time. It is
+// stored as a read-only collection named collection@snapshot, which can be
+// searched like any other.
+type Snapshot struct {
+	ID           string    `json:"id"` // ID of the collection holding the snapshot's documents
+	CollectionID string    `json:"collection_id"`
+	Name         string    `json:"name"`
+	Folders      []string  `json:"folders"`
+	Stats        Stats     `json:"stats"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SnapshotManagerImpl implements SnapshotManager
+type SnapshotManagerImpl struct {
+	db *sql.DB
+}
+
+// NewSnapshotManager creates a new snapshot manager
+func NewSnapshotManager(db *sql.DB) SnapshotManager {
+	return &SnapshotManagerImpl{db: db}
+}
+
+// SnapshotCollectionName returns the name of the collection holding a
+// collection's snapshot
+func SnapshotCollectionName(collection, snapshot string) string {
+	return collection + "@" + snapshot
+}
+
+// ValidateSnapshotName checks that a snapshot name is made of letters,
+// digits, dots, dashes and underscores
+func ValidateSnapshotName(name string) error {
+	if !snapshotNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '-' and '_'", name)
+	}
+	return nil
+}
+
+// CreateSnapshot copies the documents, file states, embedding settings,
+// boosts, metadata schema and vocabulary of a collection into a new
+// snapshot. Snapshots of snapshots can't be taken.
+func (sm *SnapshotManagerImpl) CreateSnapshot(collectionID, name string) (*Snapshot, error) {
+	if err := ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+
+	tx, err := sm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var collectionName string
+	var snapshotOf sql.NullString
+	err = tx.QueryRow(`SELECT name, snapshot_of FROM collections WHERE id = $1`, collectionID).Scan(&collectionName, &snapshotOf)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	if snapshotOf.Valid {
+		return nil, fmt.Errorf("%s is a snapshot; take snapshots of the collection instead", collectionName)
+	}
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM collections WHERE snapshot_of = $1 AND snapshot_name = $2)`, collectionID, name).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check snapshot name: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("collection %s already has a snapshot named %s", collectionName, name)
+	}
+
+	snapshot := &Snapshot{CollectionID: collectionID, Name: name}
+	var statsJSON string
+	err = tx.QueryRow(`
+		INSERT INTO collections (name, description, folders, stats, normalized, boosts, metadata_schema, snapshot_of, snapshot_name)
+		SELECT $2, description, folders, stats, normalized, boosts, metadata_schema, id, $3
+		FROM collections
+		WHERE id = $1
+		RETURNING id, folders, stats, created_at`,
+		collectionID, SnapshotCollectionName(collectionName, name), name).Scan(&snapshot.ID, pq.Array(&snapshot.Folders), &statsJSON, &snapshot.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	if err := json.Unmarshal([]byte(statsJSON), &snapshot.Stats); err != nil {
+		return nil, fmt.Errorf("failed to parse stats: %w", err)
+	}
+
+	if err := copyCollectionContents(tx, collectionID, snapshot.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots returns the snapshots of a collection, oldest first
+func (sm *SnapshotManagerImpl) ListSnapshots(collectionID string) ([]*Snapshot, error) {
+	rows, err := sm.db.Query(`
+		SELECT id, snapshot_name, folders, stats, created_at
+		FROM collections
+		WHERE snapshot_of = $1
+		ORDER BY created_at, snapshot_name`,
+		collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*Snapshot
+	for rows.Next() {
+		var statsJSON string
+		snapshot := &Snapshot{CollectionID: collectionID}
+		if err := rows.Scan(&snapshot.ID, &snapshot.Name, pq.Array(&snapshot.Folders), &statsJSON, &snapshot.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		if err := json.Unmarshal([]byte(statsJSON), &snapshot.Stats); err != nil {
+			return nil, fmt.Errorf("failed to parse stats: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// GetSnapshot returns the snapshot of a collection with the given name
+func (sm *SnapshotManagerImpl) GetSnapshot(collectionID, name string) (*Snapshot, error) {
+	snapshots, err := sm.ListSnapshots(collectionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+}
+
+// SnapshotOf returns the ID of the collection a snapshot was taken of, or
+// an empty string when the collection isn't a snapshot
+func (sm *SnapshotManagerImpl) SnapshotOf(collectionID string) (string, error) {
+	var snapshotOf sql.NullString
+	err := sm.db.QueryRow(`SELECT snapshot_of FROM collections WHERE id = $1`, collectionID).Scan(&snapshotOf)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: %s", ErrCollectionNotFound, collectionID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get collection: %w", err)
+	}
+	return snapshotOf.String, nil
+}
+
+// RestoreSnapshot replaces the documents, file states, folders, embedding
+// settings, boosts and metadata schema of a collection with those of its
+// snapshot. The snapshot is kept.
+func (sm *SnapshotManagerImpl) RestoreSnapshot(collectionID, name string) (*Snapshot, error) {
+	snapshot, err := sm.GetSnapshot(collectionID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := sm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"documents", "files", "embedding_config", "collection_terms"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE collection_id = $1`, collectionID); err != nil {
+			return nil, fmt.Errorf("failed to clear %s: %w", strings.ReplaceAll(table, "_", " "), err)
+		}
+	}
+	if err := copyCollectionContents(tx, snapshot.ID, collectionID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE collections c
+		SET folders = s.folders, stats = s.stats, normalized = s.normalized, boosts = s.boosts,
+			metadata_schema = s.metadata_schema, updated_at = NOW()
+		FROM collections s
+		WHERE c.id = $1 AND s.id = $2`,
+		collectionID, snapshot.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore collection settings: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return snapshot, nil
+}
+
+// DeleteSnapshot deletes the snapshot of a collection with the given name
+func (sm *SnapshotManagerImpl) DeleteSnapshot(collectionID, name string) error {
+	result, err := sm.db.Exec(`DELETE FROM collections WHERE snapshot_of = $1 AND snapshot_name = $2`, collectionID, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+	return nil
+}
+
+// copyCollectionContents copies the documents, file states, embedding
+// settings and vocabulary of one collection into another
+func copyCollectionContents(tx *sql.Tx, fromID, toID string) error {
+	queries := []struct{ what, query string }{
+		{"documents", `
+			INSERT INTO documents (collection_id, folder, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at, file_type, symbols, path_embedding, token_count, content_hash)
+			SELECT $2, folder, file_path, file_name, content, chunk_index, embedding, metadata, created_at, updated_at, file_type, symbols, path_embedding, token_count, content_hash
+			FROM documents
+			WHERE collection_id = $1`},
+		{"file states", `
+			INSERT INTO files (collection_id, folder, file_path, size, modified_at, content_hash, indexed_at)
+			SELECT $2, folder, file_path, size, modified_at, content_hash, indexed_at
+			FROM files
+			WHERE collection_id = $1`},
+		{"embedding config", `
+			INSERT INTO embedding_config (collection_id, dimensions, model_name)
+			SELECT $2, dimensions, model_name
+			FROM embedding_config
+			WHERE collection_id = $1`},
+		{"vocabulary", `
+			INSERT INTO collection_terms (collection_id, term, doc_count)
+			SELECT $2, term, doc_count
+			FROM collection_terms
+			WHERE collection_id = $1`},
+	}
+
+	for _, q := range queries {
+		if _, err := tx.Exec(q.query, fromID, toID); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", q.what, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSnapshotName(t *testing.T) {
+	for _, name := range []string{"incident-42", "2026-10-16", "v1.2_rc"} {
+		assert.NoError(t, ValidateSnapshotName(name), name)
+	}
+	for _, name := range []string{"", "-rc", "incident 42", "a@b", "../x"} {
+		assert.Error(t, ValidateSnapshotName(name), name)
+	}
+	assert.Equal(t, "prod-docs@incident-42", SnapshotCollectionName("prod-docs", "incident-42"))
+}
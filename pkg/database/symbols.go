@@ -0,0 +1,119 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxSymbols is the maximum number of symbols stored for a chunk
+const maxSymbols = 200
+
+// symbolPatterns match the symbols defined or imported in code, by file
+// type. The first group of each pattern is the symbol.
+var symbolPatterns = map[string][]*regexp.Regexp{}
+
+func init() {
+	goPatterns := compileSymbolPatterns(
+		`^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`,
+		`^\s*type\s+([A-Za-z_]\w*)`,
+		`^\s*(?:import\s+)?(?:[\w.]+\s+)?"([\w./-]+)"\s*$`,
+	)
+	pythonPatterns := compileSymbolPatterns(
+		`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`,
+		`^\s*class\s+([A-Za-z_]\w*)`,
+		`^\s*import\s+([\w.]+)`,
+		`^\s*from\s+([\w.]+)\s+import\b`,
+	)
+	jsPatterns := compileSymbolPatterns(
+		`\bfunction\s*\*?\s*([A-Za-z_$][\w$]*)`,
+		`\bclass\s+([A-Za-z_$][\w$]*)`,
+		`\b(?:interface|enum)\s+([A-Za-z_$][\w$]*)`,
+		`^\s*(?:export\s+)?type\s+([A-Za-z_$][\w$]*)\s*(?:<[^>]*>)?\s*=`,
+		`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:\([^)]*\)|[A-Za-z_$][\w$]*)\s*=>`,
+		`\bfrom\s+['"]([^'"]+)['"]`,
+		`\brequire\(\s*['"]([^'"]+)['"]\s*\)`,
+	)
+	jvmPatterns := compileSymbolPatterns(
+		`\b(?:class|interface|enum|record|struct|object|trait)\s+([A-Za-z_]\w*)`,
+		`^\s*(?:(?:public|private|protected|internal|static|final|abstract|synchronized|override|virtual|async|open|suspend)\s+)+(?:[\w<>\[\],.?]+\s+)?([A-Za-z_]\w*)\s*\(`,
+		`^\s*fun\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?([A-Za-z_]\w*)\s*\(`,
+		`^\s*def\s+([A-Za-z_]\w*)`,
+		`^\s*(?:import|using)\s+(?:static\s+)?([\w.]+)`,
+	)
+	cPatterns := compileSymbolPatterns(
+		`^\s*#\s*include\s*[<"]([^>"]+)[>"]`,
+		`\b(?:class|struct|enum|union|namespace)\s+([A-Za-z_]\w*)\s*[:{]`,
+		`^(?:[A-Za-z_][\w:<>,*&]*\s+)+\**&?((?:[A-Za-z_]\w*::)*[A-Za-z_]\w*)\s*\([^;]*$`,
+	)
+
+	register := func(patterns []*regexp.Regexp, fileTypes ...string) {
+		for _, fileType := range fileTypes {
+			symbolPatterns[fileType] = patterns
+		}
+	}
+	register(goPatterns, "go")
+	register(pythonPatterns, "py", "pyi")
+	register(jsPatterns, "js", "jsx", "mjs", "cjs", "ts", "tsx")
+	register(jvmPatterns, "java", "kt", "kts", "scala", "cs")
+	register(cPatterns, "c", "h", "cc", "cpp", "cxx", "hpp", "hh")
+	register(compileSymbolPatterns(
+		`\bfn\s+([A-Za-z_]\w*)`,
+		`\b(?:struct|enum|trait|type|mod)\s+([A-Za-z_]\w*)`,
+		`^\s*(?:pub\s+)?use\s+([\w:]+)`,
+	), "rs")
+	register(compileSymbolPatterns(
+		`^\s*def\s+(?:self\.)?([A-Za-z_]\w*[?!]?)`,
+		`^\s*(?:class|module)\s+([A-Z][\w:]*)`,
+		`^\s*require(?:_relative)?\s*\(?\s*['"]([^'"]+)['"]`,
+	), "rb")
+	register(compileSymbolPatterns(
+		`\bfunction\s+&?([A-Za-z_]\w*)`,
+		`\b(?:class|interface|trait|enum)\s+([A-Za-z_]\w*)`,
+		`^\s*use\s+([\w\\]+)`,
+	), "php")
+}
+
+// compileSymbolPatterns compiles line-based symbol patterns
+func compileSymbolPatterns(patterns ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		compiled[i] = regexp.MustCompile(`(?m)` + pattern)
+	}
+	return compiled
+}
+
+// symbolKeywords are words the patterns can mistake for symbols, such as
+// the control statements of C-like languages
+var symbolKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true,
+	"return": true, "sizeof": true, "else": true, "new": true, "delete": true,
+}
+
+// ExtractSymbols returns the names of the functions, types and classes a
+// code chunk defines and the paths it imports, separated by spaces, or ""
+// when the file type isn't a programming language it knows. Symbols are
+// indexed with a higher weight than the content, so a search for a name
+// finds its definition before the places that mention it.
+func ExtractSymbols(fileName, content string) string {
+	patterns := symbolPatterns[FileType(fileName)]
+	if len(patterns) == 0 {
+		return ""
+	}
+
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		for _, match := range pattern.FindAllStringSubmatch(content, -1) {
+			symbol := match[1]
+			if symbol == "" || seen[symbol] || symbolKeywords[symbol] {
+				continue
+			}
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+			if len(symbols) == maxSymbols {
+				return strings.Join(symbols, " ")
+			}
+		}
+	}
+	return strings.Join(symbols, " ")
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractSymbols(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+		want     []string
+	}{
+		{
+			name:     "go",
+			fileName: "collection.go",
+			content: `package database
+
+import (
+	"database/sql"
+	pq "github.com/lib/pq"
+)
+
+type CollectionManagerImpl struct {
+	db *sql.DB
+}
+
+// NewCollectionManager creates a collection manager
+func NewCollectionManager(db *sql.DB) CollectionManager {
+	if db == nil {
+		return nil
+	}
+	return &CollectionManagerImpl{db: db}
+}
+
+func (cm *CollectionManagerImpl) GetCollection(id string) (*Collection, error) {
+	return nil, nil
+}`,
+			want: []string{"NewCollectionManager", "GetCollection", "CollectionManagerImpl", "database/sql", "github.com/lib/pq"},
+		},
+		{
+			name:     "python",
+			fileName: "app.py",
+			content:  "from os import path\nimport json\n\nclass Loader:\n    async def load(self):\n        pass\n",
+			want:     []string{"load", "Loader", "json", "os"},
+		},
+		{
+			name:     "typescript",
+			fileName: "client.ts",
+			content:  "import { x } from './util';\nexport class Client {}\nexport const fetchAll = async (id) => id;\nexport type Id = string;\n",
+			want:     []string{"Client", "Id", "fetchAll", "./util"},
+		},
+		{
+			name:     "c",
+			fileName: "main.c",
+			content:  "#include <stdio.h>\nstatic int parse_args(int argc, char **argv) {\n  if (argc > 1) {\n  }\n}\n",
+			want:     []string{"stdio.h", "parse_args"},
+		},
+		{
+			name:     "prose",
+			fileName: "README.md",
+			content:  "Call NewCollectionManager to create a manager.",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbols := ExtractSymbols(tt.fileName, tt.content)
+			if tt.want == nil {
+				assert.Empty(t, symbols)
+				return
+			}
+			assert.Equal(t, tt.want, strings.Fields(symbols))
+		})
+	}
+}
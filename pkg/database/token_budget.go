@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/lib/pq"
+)
+
+// loadTokenCounts sets the stored token counts of the results' documents.
+// Results with an expanded context keep the estimate of their content.
+func (se *SearchEngineImpl) loadTokenCounts(results []*SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Document.ID
+	}
+
+	rows, err := se.db.Query(`SELECT id, token_count FROM documents WHERE id = ANY($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to get token counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int, len(ids))
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return fmt.Errorf("failed to scan token count: %w", err)
+		}
+		counts[id] = count
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read token counts: %w", err)
+	}
+
+	for _, result := range results {
+		if len(result.ContextChunks) == 0 {
+			result.Document.TokenCount = counts[result.Document.ID]
+		}
+	}
+	return nil
+}
+
+// documentTokens returns the token count of a document, estimating it from
+// the content when it isn't stored
+func documentTokens(doc *Document) int {
+	if doc.TokenCount > 0 {
+		return doc.TokenCount
+	}
+	return client.EstimateTokens(doc.Content)
+}
+
+// FitTokenBudget returns the results, in order, whose content fits in
+// maxTokens together. Results too large for the tokens left are skipped, so
+// smaller results further down can still use them.
+func FitTokenBudget(results []*SearchResult, maxTokens int) []*SearchResult {
+	var fitted []*SearchResult
+	left := maxTokens
+	for _, result := range results {
+		tokens := documentTokens(result.Document)
+		if tokens > left {
+			continue
+		}
+		fitted = append(fitted, result)
+		left -= tokens
+	}
+	return fitted
+}
+
+// TotalTokens returns the number of tokens of the results' content
+func TotalTokens(results []*SearchResult) int {
+	total := 0
+	for _, result := range results {
+		total += documentTokens(result.Document)
+	}
+	return total
+}
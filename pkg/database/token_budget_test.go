@@ -0,0 +1,27 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFitTokenBudget(t *testing.T) {
+	results := []*SearchResult{
+		{Document: &Document{ID: "a", TokenCount: 400}},
+		{Document: &Document{ID: "b", TokenCount: 700}},
+		{Document: &Document{ID: "c", TokenCount: 300}},
+		// Without a stored count, 40 characters are estimated at 10 tokens
+		{Document: &Document{ID: "d", Content: "0123456789012345678901234567890123456789"}},
+	}
+
+	fitted := FitTokenBudget(results, 1000)
+	var ids []string
+	for _, result := range fitted {
+		ids = append(ids, result.Document.ID)
+	}
+	assert.Equal(t, []string{"a", "c", "d"}, ids)
+	assert.Equal(t, 710, TotalTokens(fitted))
+
+	assert.Empty(t, FitTokenBudget(results, 5))
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -18,19 +20,50 @@ type CollectionManager interface {
 	UpdateCollection(id string, name *string, description *string) (*Collection, error)
 	AddFolderToCollection(id, folder string) (*Collection, error)
 	RemoveFolderFromCollection(id, folder string) (*Collection, error)
+
+	// Vector index operations
+	GetIndexType(id string) (IndexType, error)
+	SetIndexType(id string, indexType IndexType) error
+	GetNormalized(id string) (bool, error)
+	SetNormalized(id string, normalized bool) error
+	MaxEmbeddingNormDeviation(id string, sample int) (float64, error)
+
+	// Boosting rule operations
+	GetBoosts(id string) ([]BoostRule, error)
+	SetBoosts(id string, rules []BoostRule) error
+
+	// Tuned ranking operations
+	GetRanking(id string) (*CollectionRanking, error)
+	SetRanking(id string, ranking *CollectionRanking) error
+
+	// Metadata schema operations
+	GetMetadataSchema(id string) (MetadataSchema, error)
+	SetMetadataSchema(id string, schema MetadataSchema) error
+
+	// Alias operations
+	AddAlias(id, alias string) error
+	RemoveAlias(alias string) error
+	ListAliases(id string) ([]string, error)
 }
 
 // DocumentManager defines operations for managing documents
 type DocumentManager interface {
 	// Document operations
 	InsertDocument(doc *Document) error
-	DeleteDocumentsByPath(collectionID, filePath string) error
+	UpsertDocument(doc *Document) error
+	DeleteDocumentsByPath(collectionID, folder, filePath string) error
+	PruneDocumentsByPath(collectionID, folder, filePath string, chunkIndexes []int) error
 	DeleteDocumentsByFolder(collectionID, folder string) error
+	DeleteDocumentsByPattern(collectionID, pattern string, dryRun bool) ([]*IndexedFile, error)
 	DeleteDocumentByID(documentID string) error
-	ListDocumentsByFolder(collectionID, folder string, limit, offset int) ([]*Document, error)
-	ListDocumentsByFolderWithFilter(collectionID, folder, fileFilter string, limit, offset int) ([]*Document, error)
+	ListDocumentsByFolder(collectionID string, scope FolderScope, limit, offset int) (*DocumentPage, error)
+	ListDocumentsByFolderWithFilter(collectionID string, scope FolderScope, fileFilter string, limit, offset int) (*DocumentPage, error)
 	GetDocumentByID(documentID string) (*Document, error)
-	GetDocumentByPathAndIndex(collectionID, filePath string, chunkIndex int) (*Document, error)
+	GetDocumentByPathAndIndex(collectionID, folder, filePath string, chunkIndex int) (*Document, error)
+	ListDocumentsByFile(collectionID, filePath string) ([]*Document, error)
+	ListFiles(collectionID string) ([]*IndexedFile, error)
+	FindSharedEmbeddings(collectionID, model string, dimensions int, filePath string, hashes []string) (*SharedEmbeddings, error)
+	CopyIndexedFolder(collectionID, folder, model string) (*FolderCopy, error)
 }
 
 // SearchEngine defines operations for searching documents
@@ -45,6 +78,78 @@ type SearchEngine interface {
 	GetSearchStats(results []*SearchResult) map[string]interface{}
 }
 
+// VocabularyManager defines operations on the terms that occur in a collection
+type VocabularyManager interface {
+	RefreshVocabulary(collectionID string) (int, error)
+	KnownTerms(collectionID string, terms []string) (map[string]bool, error)
+	NearestTerm(collectionID, term string, minSimilarity float64) (string, error)
+}
+
+// FileStateManager defines operations on the states of indexed files
+type FileStateManager interface {
+	ListFileStates(collectionID, folder string) (map[string]*FileState, error)
+	SaveFileState(collectionID string, state *FileState) error
+	TouchFile(collectionID string, state *FileState) error
+}
+
+// CollectionRouter picks the collections most relevant to a query
+type CollectionRouter interface {
+	UpdateCentroid(collectionID string) error
+	StaleDescriptionEmbeddings() ([]*Collection, error)
+	SetDescriptionEmbedding(collectionID, source string, embedding []float32) error
+	RouteQuery(embedding []float32, textQuery string, limit int) ([]*CollectionRoute, error)
+}
+
+// SnapshotManager keeps point-in-time copies of collections
+type SnapshotManager interface {
+	CreateSnapshot(collectionID, name string) (*Snapshot, error)
+	ListSnapshots(collectionID string) ([]*Snapshot, error)
+	GetSnapshot(collectionID, name string) (*Snapshot, error)
+	SnapshotOf(collectionID string) (string, error)
+	RestoreSnapshot(collectionID, name string) (*Snapshot, error)
+	DeleteSnapshot(collectionID, name string) error
+}
+
+// MemoryManager stores what chat remembers about the user across sessions
+type MemoryManager interface {
+	AddMemory(collectionID, kind, content string) (*Memory, error)
+	ListMemories(collectionID string) ([]*Memory, error)
+	ForgetMemory(id int64) error
+	ForgetMemories(collectionID string) (int, error)
+}
+
+// FeedbackManager stores the relevance judgments of search results that
+// collection rankings are tuned with
+type FeedbackManager interface {
+	AddJudgment(collectionID, query, folder, filePath string, relevant bool) error
+	ListJudgments(collectionID string) ([]*Judgment, error)
+	ForgetJudgments(collectionID string) (int, error)
+}
+
+// ChatSessionManager stores chat sessions and their conversations
+type ChatSessionManager interface {
+	CreateSession(session *ChatSession) error
+	GetSession(id string) (*ChatSession, error)
+	GetSessionByIdOrName(idOrName string) (*ChatSession, error)
+	ListSessions() ([]*ChatSessionSummary, error)
+	DeleteSession(id string) error
+	TouchSession(id string, expiresAt *time.Time) error
+	DeleteExpiredSessions() (int, error)
+
+	// Conversation operations
+	AddMessage(sessionID, role, content string) (*ChatMessage, error)
+	AddMessageWithSources(sessionID, role, content string, sources []ChatSource) (*ChatMessage, error)
+	ListMessages(sessionID string) ([]*ChatMessage, error)
+}
+
+// PromptManager stores the named system prompts of the prompt library
+type PromptManager interface {
+	SavePrompt(prompt *Prompt) error
+	GetPrompt(name string) (*Prompt, error)
+	ListPrompts() ([]*Prompt, error)
+	DeletePrompt(name string) error
+}
+
 // DatabaseManager manages database connection and schema
 type DatabaseManager interface {
 	// Connection management
@@ -69,19 +174,82 @@ const (
 	SearchTypeText     SearchType = "text"     // Full-text search only
 	SearchTypeHybrid   SearchType = "hybrid"   // Combined vector and text search
 	SearchTypeSemantic SearchType = "semantic" // Semantic search (vector with filters)
+	SearchTypeBM25     SearchType = "bm25"     // BM25 ranking over stored term vectors
+	SearchTypeFusion   SearchType = "fusion"   // Vector and BM25 combined with reciprocal rank fusion
 )
 
+// UsesEmbedding reports whether the search type needs a query embedding
+func (t SearchType) UsesEmbedding() bool {
+	return t != SearchTypeText && t != SearchTypeBM25
+}
+
+// UsesText reports whether the search type ranks chunks by their text, which
+// doesn't match encrypted chunks
+func (t SearchType) UsesText() bool {
+	return t != SearchTypeVector && t != SearchTypeSemantic
+}
+
+// ParseSearchType parses a search type name
+func ParseSearchType(s string) (SearchType, error) {
+	searchType := SearchType(strings.ToLower(strings.TrimSpace(s)))
+	switch searchType {
+	case SearchTypeVector, SearchTypeText, SearchTypeHybrid, SearchTypeSemantic, SearchTypeBM25, SearchTypeFusion:
+		return searchType, nil
+	default:
+		return "", fmt.Errorf("invalid search type %q (must be vector, text, hybrid, semantic, bm25 or fusion)", s)
+	}
+}
+
+// IndexType is the kind of vector index used for a collection's embeddings
+type IndexType string
+
+const (
+	IndexTypeHNSW    IndexType = "hnsw"    // Shared pgvector HNSW index (default)
+	IndexTypeDiskANN IndexType = "diskann" // Per-collection pgvectorscale StreamingDiskANN index
+)
+
+// ParseIndexType parses an index type name
+func ParseIndexType(s string) (IndexType, error) {
+	switch IndexType(strings.ToLower(strings.TrimSpace(s))) {
+	case IndexTypeHNSW:
+		return IndexTypeHNSW, nil
+	case IndexTypeDiskANN:
+		return IndexTypeDiskANN, nil
+	default:
+		return "", fmt.Errorf("invalid index type %q (must be %s or %s)", s, IndexTypeHNSW, IndexTypeDiskANN)
+	}
+}
+
 // SearchOptions represents search configuration options
 type SearchOptions struct {
-	SearchType    SearchType `json:"search_type"`
-	VectorWeight  float64    `json:"vector_weight"`   // Weight for vector similarity (0.0-1.0)
-	TextWeight    float64    `json:"text_weight"`     // Weight for text similarity (0.0-1.0)
-	MinScore      float64    `json:"min_score"`       // Minimum similarity score
-	MaxDistance   float64    `json:"max_distance"`    // Maximum vector distance
-	FileFilter    string     `json:"file_filter"`     // File name pattern filter
-	ContentFilter string     `json:"content_filter"`  // Content text filter
-	UseFuzzyMatch bool       `json:"use_fuzzy_match"` // Enable fuzzy text matching
-	FuzzyDistance int        `json:"fuzzy_distance"`  // Levenshtein distance for fuzzy matching
+	SearchType    SearchType        `json:"search_type"`
+	VectorWeight  float64           `json:"vector_weight"`   // Weight for vector similarity (0.0-1.0)
+	TextWeight    float64           `json:"text_weight"`     // Weight for text similarity (0.0-1.0)
+	MinScore      float64           `json:"min_score"`       // Minimum similarity score
+	MaxDistance   float64           `json:"max_distance"`    // Maximum vector distance
+	FileFilter    string            `json:"file_filter"`     // File name glob filter, e.g. *.md
+	ContentFilter string            `json:"content_filter"`  // Content text filter
+	FileTypes     []string          `json:"file_types"`      // Only search files of these types, e.g. md and go (see FileType)
+	Where         []MetadataFilter  `json:"where"`           // Only search chunks whose custom metadata matches all of these
+	Metadata      map[string]string `json:"metadata"`        // Only search chunks whose metadata contains all of these key-value pairs
+	UseFuzzyMatch bool              `json:"use_fuzzy_match"` // Enable fuzzy text matching
+	FuzzyDistance int               `json:"fuzzy_distance"`  // Levenshtein distance for fuzzy matching
+
+	// Normalization brings vector, text and reranking scores to a common
+	// scale before they are weighted into the combined score
+	Normalization ScoreNormalization `json:"normalization"`
+
+	// PathWeight is the weight of the similarity between the query and the
+	// file path added to the combined score (0 = off)
+	PathWeight float64 `json:"path_weight"`
+
+	// MaxTokens keeps only the top results whose content fits in this many
+	// tokens together (0 = no budget)
+	MaxTokens int `json:"max_tokens"`
+
+	// ContextChunks joins this many chunks before and after each result's
+	// chunk in its file to its content (0 = the chunk alone)
+	ContextChunks int `json:"context_chunks"`
 
 	// Reranking options
 	EnableReranking   bool    `json:"enable_reranking"`   // Enable reranking for search results
@@ -89,22 +257,48 @@ type SearchOptions struct {
 	OriginalWeight    float64 `json:"original_weight"`    // Weight for original search score (0.0-1.0)
 	RerankWeight      float64 `json:"rerank_weight"`      // Weight for reranking score (0.0-1.0)
 	RerankLimit       int     `json:"rerank_limit"`       // Number of results to rerank (0 = all)
+
+	// RetrieveLimit is the number of candidates retrieved for the reranker
+	// before the top results are returned (0 = the number of results)
+	RetrieveLimit int `json:"retrieve_limit"`
+
+	// MMRLambda reorders the results with maximal marginal relevance,
+	// weighing relevance against similarity to better ranked results (see
+	// Diversify; 0 or 1 = off)
+	MMRLambda float64 `json:"mmr_lambda"`
+
+	// Boosting rules applied to the combined score
+	Boosts []BoostRule `json:"boosts"`
+
+	// Query expansion variants searched alongside the original query and fused with it
+	Variants []QueryVariant `json:"variants"`
+}
+
+// QueryVariant is an alternative form of a search query, such as a synonym
+// rewrite or a paraphrase
+type QueryVariant struct {
+	Text      string    `json:"text"`
+	Embedding []float32 `json:"embedding"` // Only needed by search types that use embeddings
 }
 
 // SearchResult represents a search result with scoring information
 type SearchResult struct {
 	Document      *Document `json:"document"`
-	VectorScore   float64   `json:"vector_score"`   // Vector similarity score (0-1, higher is better)
-	TextScore     float64   `json:"text_score"`     // Text search score (0-1, higher is better)
-	CombinedScore float64   `json:"combined_score"` // Combined weighted score
-	Rank          int       `json:"rank"`           // Result rank
+	VectorScore   float64   `json:"vector_score"`             // Vector similarity score (0-1, higher is better)
+	TextScore     float64   `json:"text_score"`               // Text search score (0-1, higher is better)
+	CombinedScore float64   `json:"combined_score"`           // Combined weighted score
+	PathScore     float64   `json:"path_score,omitempty"`     // Similarity between the query and the file path, when path similarity is weighted
+	Confidence    float64   `json:"confidence,omitempty"`     // Calibrated 0-1 confidence that the result is relevant, when a calibration is fitted
+	Rank          int       `json:"rank"`                     // Result rank
+	ContextChunks []int     `json:"context_chunks,omitempty"` // Indexes of the chunks of the file joined into the content, when the context is expanded
 }
 
 // Document represents a document in the database
 type Document struct {
 	ID           string    `json:"id"`
 	CollectionID string    `json:"collection_id"`
-	FilePath     string    `json:"file_path"`
+	Folder       string    `json:"folder"`    // Collection folder the file was indexed from
+	FilePath     string    `json:"file_path"` // Path relative to the folder, with / separators
 	FileName     string    `json:"file_name"`
 	Content      string    `json:"content"`
 	ChunkIndex   int       `json:"chunk_index"`
@@ -112,6 +306,66 @@ type Document struct {
 	Metadata     string    `json:"metadata"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	// PathEmbedding is the embedding of the file path (see PathText), stored
+	// with every chunk of the file
+	PathEmbedding []float32 `json:"path_embedding,omitempty"`
+
+	// TokenCount is the estimated number of tokens of the content, 0 when
+	// it isn't known
+	TokenCount int `json:"token_count,omitempty"`
+
+	// ContentHash is the hash of the content (see KeyedContentHash), set when the
+	// document is stored
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// IndexedFile is a file of a collection folder with the number of chunks it
+// was indexed into
+type IndexedFile struct {
+	Folder    string    `json:"folder"`    // Collection folder the file was indexed from
+	FilePath  string    `json:"file_path"` // Path relative to the folder, with / separators
+	FileName  string    `json:"file_name"`
+	Chunks    int       `json:"chunks"`
+	UpdatedAt time.Time `json:"updated_at"` // Latest update of the file's chunks
+}
+
+// DocumentPage is one page of a document listing along with the total
+// number of documents matching the listing
+type DocumentPage struct {
+	Documents []*Document `json:"documents"`
+	Total     int         `json:"total"`
+	Limit     int         `json:"limit"`
+	Offset    int         `json:"offset"`
+}
+
+// Page returns the 1-based number of the page
+func (p *DocumentPage) Page() int {
+	if p.Limit <= 0 {
+		return 1
+	}
+	return p.Offset/p.Limit + 1
+}
+
+// TotalPages returns how many pages of this size the listing has
+func (p *DocumentPage) TotalPages() int {
+	if p.Limit <= 0 || p.Total == 0 {
+		return 1
+	}
+	return (p.Total + p.Limit - 1) / p.Limit
+}
+
+// HasMore reports whether there are documents after this page
+func (p *DocumentPage) HasMore() bool {
+	return p.Offset+len(p.Documents) < p.Total
+}
+
+// NextOffset returns the offset of the next page, or -1 if this is the last page
+func (p *DocumentPage) NextOffset() int {
+	if !p.HasMore() {
+		return -1
+	}
+	return p.Offset + len(p.Documents)
 }
 
 // Collection represents a collection in the database
@@ -125,9 +379,82 @@ type Collection struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// CollectionRanking is the ranking tuned for a collection from the
+// relevance judgments of its search results. Search and chat use it for the
+// collection unless a ranking profile or the ranking flags are given.
+type CollectionRanking struct {
+	VectorWeight float64   `json:"vector_weight"`
+	TextWeight   float64   `json:"text_weight"`
+	Rerank       bool      `json:"rerank,omitempty"`
+	Recency      string    `json:"recency,omitempty"` // Recency boost rule added to the collection's boosts, if any
+	Queries      int       `json:"queries"`           // Judged queries the ranking was tuned with
+	MRR          float64   `json:"mrr"`               // Mean reciprocal rank of the first relevant file for those queries
+	TunedAt      time.Time `json:"tuned_at"`
+}
+
 // Stats represents collection statistics
 type Stats struct {
 	TotalDocuments int   `json:"total_documents"`
 	TotalChunks    int   `json:"total_chunks"`
 	TotalSize      int64 `json:"total_size"`
 }
+
+// ChatSession is a conversation with a collection that is stored so it can
+// be continued later, possibly by another process
+type ChatSession struct {
+	ID           string              `json:"id"`
+	Name         string              `json:"name,omitempty"`
+	CollectionID string              `json:"collection_id"`
+	Settings     ChatSessionSettings `json:"settings"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+	ExpiresAt    *time.Time          `json:"expires_at,omitempty"` // Nil for sessions that never expire
+}
+
+// ChatSessionSummary is a chat session with the name of its collection and
+// the number of messages of its conversation
+type ChatSessionSummary struct {
+	ChatSession
+	CollectionName string `json:"collection_name"`
+	Messages       int    `json:"messages"`
+}
+
+// ChatSessionSettings are the chat options a session was started with.
+// Zero values mean the defaults of the process continuing the session.
+type ChatSessionSettings struct {
+	Model        string     `json:"model,omitempty"`
+	SystemPrompt string     `json:"system_prompt,omitempty"`
+	SearchType   SearchType `json:"search_type,omitempty"`
+	Limit        int        `json:"limit,omitempty"`
+	Rerank       *bool      `json:"rerank,omitempty"` // Nil uses the collection's tuned ranking
+}
+
+// ChatSource is a context document of an answer with the number the answer
+// cites it by
+type ChatSource struct {
+	Number     int     `json:"number"`
+	FilePath   string  `json:"file_path"`
+	ChunkIndex int     `json:"chunk_index"`
+	Score      float64 `json:"score"`
+	Cited      bool    `json:"cited"` // The answer cites the document
+}
+
+// Prompt is a named system prompt. Its content may contain variables such
+// as {{collection}} that are filled in when a chat starts.
+type Prompt struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ChatMessage is one turn of a stored conversation
+type ChatMessage struct {
+	ID        int64        `json:"id"`
+	SessionID string       `json:"session_id"`
+	Role      string       `json:"role"`
+	Content   string       `json:"content"`
+	Sources   []ChatSource `json:"sources,omitempty"` // Context documents of an answer, when they were stored
+	CreatedAt time.Time    `json:"created_at"`
+}
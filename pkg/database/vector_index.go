@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// vectorIndexName returns the name of a collection's dedicated vector index
+func vectorIndexName(collectionID string) string {
+	return "idx_documents_embedding_" + strings.ReplaceAll(strings.ToLower(collectionID), "-", "")
+}
+
+// GetIndexType returns the vector index type used by a collection
+func (cm *CollectionManagerImpl) GetIndexType(id string) (IndexType, error) {
+	var indexType string
+	err := cm.db.QueryRow(`SELECT index_type FROM collections WHERE id = $1`, id).Scan(&indexType)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("collection not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get index type: %w", err)
+	}
+
+	return ParseIndexType(indexType)
+}
+
+// SetIndexType switches the vector index used by a collection in place.
+// HNSW collections use the shared index on the documents table, while
+// DiskANN collections get a dedicated partial index built by pgvectorscale.
+func (cm *CollectionManagerImpl) SetIndexType(id string, indexType IndexType) error {
+	if _, err := ParseIndexType(string(indexType)); err != nil {
+		return err
+	}
+	// The collection ID is interpolated into DDL below
+	if !isUUID(id) {
+		return fmt.Errorf("invalid collection ID: %s", id)
+	}
+
+	tx, err := cm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var normalized bool
+	err = tx.QueryRow(`UPDATE collections SET index_type = $2, updated_at = NOW() WHERE id = $1 RETURNING normalized`, id, string(indexType)).Scan(&normalized)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("collection not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update index type: %w", err)
+	}
+
+	if err := createCollectionVectorIndex(tx, id, indexType, normalized); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit index change: %w", err)
+	}
+
+	return nil
+}
+
+// GetNormalized reports whether a collection's embeddings are flagged as
+// normalized and searched by inner product
+func (cm *CollectionManagerImpl) GetNormalized(id string) (bool, error) {
+	var normalized bool
+	err := cm.db.QueryRow(`SELECT normalized FROM collections WHERE id = $1`, id).Scan(&normalized)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("collection not found")
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get normalized flag: %w", err)
+	}
+	return normalized, nil
+}
+
+// SetNormalized flags a collection's embeddings as normalized, or clears
+// the flag, and rebuilds its vector index with the matching operator class.
+// Inner product ranks unit vectors the same as cosine distance but is
+// cheaper to compute, so it is only correct when the embedding model
+// returns unit vectors.
+func (cm *CollectionManagerImpl) SetNormalized(id string, normalized bool) error {
+	// The collection ID is interpolated into DDL below
+	if !isUUID(id) {
+		return fmt.Errorf("invalid collection ID: %s", id)
+	}
+
+	tx, err := cm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var indexType string
+	err = tx.QueryRow(`UPDATE collections SET normalized = $2, updated_at = NOW() WHERE id = $1 RETURNING index_type`, id, normalized).Scan(&indexType)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("collection not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update normalized flag: %w", err)
+	}
+
+	parsed, err := ParseIndexType(indexType)
+	if err != nil {
+		return err
+	}
+	if err := createCollectionVectorIndex(tx, id, parsed, normalized); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit normalized flag: %w", err)
+	}
+
+	return nil
+}
+
+// MaxEmbeddingNormDeviation returns how far the norm of a collection's
+// embeddings strays from 1 in a sample of its chunks, which is 0 for a
+// model that returns unit vectors
+func (cm *CollectionManagerImpl) MaxEmbeddingNormDeviation(id string, sample int) (float64, error) {
+	var deviation sql.NullFloat64
+	err := cm.db.QueryRow(`
+		SELECT MAX(ABS(vector_norm(embedding) - 1))
+		FROM (SELECT embedding FROM documents WHERE collection_id = $1 AND embedding IS NOT NULL LIMIT $2) s
+	`, id, sample).Scan(&deviation)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check embedding norms: %w", err)
+	}
+	return deviation.Float64, nil
+}
+
+// createCollectionVectorIndex replaces a collection's dedicated vector
+// index with the one for its index type and normalized flag. DiskANN
+// collections always get one. HNSW collections use the shared cosine index
+// unless they are normalized, when they get a partial inner product index.
+func createCollectionVectorIndex(tx *sql.Tx, collectionID string, indexType IndexType, normalized bool) error {
+	if err := dropCollectionVectorIndex(tx, collectionID); err != nil {
+		return err
+	}
+
+	opclass := "vector_cosine_ops"
+	if normalized {
+		opclass = "vector_ip_ops"
+	}
+
+	var method string
+	switch {
+	case indexType == IndexTypeDiskANN:
+		if err := ensureExtension(tx, VectorScaleExtension); err != nil {
+			return err
+		}
+		method = "diskann"
+	case normalized:
+		method = "hnsw"
+	default:
+		return nil
+	}
+
+	query := fmt.Sprintf(
+		`CREATE INDEX %s ON documents USING %s (embedding %s) WHERE collection_id = %s`,
+		pq.QuoteIdentifier(vectorIndexName(collectionID)), method, opclass, pq.QuoteLiteral(strings.ToLower(collectionID)))
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to create %s index: %w", method, err)
+	}
+	return nil
+}
+
+// dropCollectionVectorIndex drops a collection's dedicated vector index if it exists
+func dropCollectionVectorIndex(tx *sql.Tx, collectionID string) error {
+	query := fmt.Sprintf(`DROP INDEX IF EXISTS %s`, pq.QuoteIdentifier(vectorIndexName(collectionID)))
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to drop vector index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DiskFile is a file found in a collection folder on this machine
+type DiskFile struct {
+	Folder   string    // Collection folder the file is in
+	FilePath string    // Path relative to the folder, with / separators
+	Path     string    // Where the file is on this machine
+	ModTime  time.Time // When the file was last modified
+}
+
+// IndexReport lists the differences between a collection's index and the
+// files in its folders
+type IndexReport struct {
+	Unindexed []DiskFile     // Files on disk that aren't indexed
+	Missing   []*IndexedFile // Indexed files that are no longer on disk
+	Stale     []*IndexedFile // Indexed files modified since they were indexed
+	Current   int            // Indexed files that are up to date
+}
+
+// CompareIndex compares the indexed files of a collection with the files
+// found in its folders. Files are indexed with their modification time, so a
+// file modified later is stale. Files indexed before paths were stored
+// relative to their folder have no folder and are matched by their path on
+// this machine.
+func CompareIndex(indexed []*IndexedFile, onDisk []DiskFile) *IndexReport {
+	type key struct{ folder, path string }
+	disk := make(map[key]DiskFile, len(onDisk))
+	for _, file := range onDisk {
+		disk[key{file.Folder, file.FilePath}] = file
+		disk[key{"", file.Path}] = file
+	}
+
+	report := &IndexReport{}
+	seen := make(map[string]bool, len(indexed))
+	for _, file := range indexed {
+		found, ok := disk[key{file.Folder, file.FilePath}]
+		if !ok {
+			report.Missing = append(report.Missing, file)
+			continue
+		}
+		seen[found.Path] = true
+		// The database keeps timestamps to the microsecond
+		if found.ModTime.Truncate(time.Microsecond).After(file.UpdatedAt) {
+			report.Stale = append(report.Stale, file)
+		} else {
+			report.Current++
+		}
+	}
+
+	for _, file := range onDisk {
+		if !seen[file.Path] {
+			report.Unindexed = append(report.Unindexed, file)
+		}
+	}
+
+	return report
+}
+
+// EmbeddingDimensionCounts counts the chunks of a collection by the number of
+// dimensions of their embedding; chunks without one are counted under 0
+func EmbeddingDimensionCounts(db *sql.DB, collectionID string) (map[int]int, error) {
+	rows, err := db.Query(`
+		SELECT COALESCE(vector_dims(embedding), 0), COUNT(*)
+		FROM documents
+		WHERE collection_id = $1
+		GROUP BY 1
+	`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count embedding dimensions: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var dimensions, chunks int
+		if err := rows.Scan(&dimensions, &chunks); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding dimensions: %w", err)
+		}
+		counts[dimensions] = chunks
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over embedding dimensions: %w", err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareIndex(t *testing.T) {
+	indexedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	indexed := []*IndexedFile{
+		{Folder: "/docs", FilePath: "current.md", UpdatedAt: indexedAt},
+		{Folder: "/docs", FilePath: "stale.md", UpdatedAt: indexedAt},
+		{Folder: "/docs", FilePath: "deleted.md", UpdatedAt: indexedAt},
+		{Folder: "", FilePath: "/docs/legacy.md", UpdatedAt: indexedAt},
+	}
+	onDisk := []DiskFile{
+		// Sub-microsecond differences are lost in the database
+		{Folder: "/docs", FilePath: "current.md", Path: "/docs/current.md", ModTime: indexedAt.Add(500 * time.Nanosecond)},
+		{Folder: "/docs", FilePath: "stale.md", Path: "/docs/stale.md", ModTime: indexedAt.Add(time.Minute)},
+		{Folder: "/docs", FilePath: "legacy.md", Path: "/docs/legacy.md", ModTime: indexedAt},
+		{Folder: "/docs", FilePath: "new.md", Path: "/docs/new.md", ModTime: indexedAt},
+	}
+
+	report := CompareIndex(indexed, onDisk)
+	assert.Equal(t, 2, report.Current)
+	assert.Equal(t, []*IndexedFile{indexed[1]}, report.Stale)
+	assert.Equal(t, []*IndexedFile{indexed[2]}, report.Missing)
+	assert.Equal(t, []DiskFile{onDisk[3]}, report.Unindexed)
+}
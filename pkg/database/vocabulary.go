@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// minVocabularyTermLength is the length of the shortest term kept in a vocabulary
+	minVocabularyTermLength = 3
+	// maxVocabularyTermLength is the length of the longest term kept in a vocabulary
+	maxVocabularyTermLength = 40
+)
+
+// VocabularyManagerImpl implements VocabularyManager
+type VocabularyManagerImpl struct {
+	db *sql.DB
+}
+
+// NewVocabularyManager creates a new vocabulary manager
+func NewVocabularyManager(db *sql.DB) VocabularyManager {
+	return &VocabularyManagerImpl{db: db}
+}
+
+// vocabularyInsertQuery returns the query that collects the terms of the
+// documents matching the given condition, counting the chunks each term
+// appears in. Terms are split without stemming so they can be suggested as
+// written. Words with digits are left out since they are rarely misspelled
+// words, and so is encrypted content.
+func vocabularyInsertQuery(where string) string {
+	conditions := "d.content NOT LIKE '" + encryptedPrefix + "%'"
+	if where != "" {
+		conditions = where + " AND " + conditions
+	}
+	where = "WHERE " + conditions
+	return fmt.Sprintf(`
+		INSERT INTO collection_terms (collection_id, term, doc_count)
+		SELECT d.collection_id, t.lexeme, COUNT(*)
+		FROM documents d, unnest(to_tsvector('simple', d.content)) t
+		%s
+		GROUP BY d.collection_id, t.lexeme
+		HAVING length(t.lexeme) BETWEEN %d AND %d
+		   AND t.lexeme ~ '^[[:alpha:]]+$'
+	`, where, minVocabularyTermLength, maxVocabularyTermLength)
+}
+
+// RefreshVocabulary rebuilds the vocabulary of a collection from its documents
+// and returns the number of terms
+func (vm *VocabularyManagerImpl) RefreshVocabulary(collectionID string) (int, error) {
+	tx, err := vm.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM collection_terms WHERE collection_id = $1`, collectionID); err != nil {
+		return 0, fmt.Errorf("failed to clear vocabulary: %w", err)
+	}
+
+	result, err := tx.Exec(vocabularyInsertQuery("d.collection_id = $1"), collectionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build vocabulary: %w", err)
+	}
+	terms, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count vocabulary terms: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit vocabulary: %w", err)
+	}
+
+	return int(terms), nil
+}
+
+// KnownTerms returns which of the given lowercase terms are in the vocabulary of a collection
+func (vm *VocabularyManagerImpl) KnownTerms(collectionID string, terms []string) (map[string]bool, error) {
+	known := make(map[string]bool)
+	if len(terms) == 0 {
+		return known, nil
+	}
+
+	rows, err := vm.db.Query(`
+		SELECT term FROM collection_terms
+		WHERE collection_id = $1 AND term = ANY($2)
+	`, collectionID, pq.Array(terms))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up terms: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, fmt.Errorf("failed to scan term: %w", err)
+		}
+		known[term] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read terms: %w", err)
+	}
+
+	return known, nil
+}
+
+// NearestTerm returns the vocabulary term most similar to a lowercase term,
+// preferring terms found in more chunks when they are equally similar. It
+// returns an empty string when no term is at least minSimilarity similar.
+func (vm *VocabularyManagerImpl) NearestTerm(collectionID, term string, minSimilarity float64) (string, error) {
+	var nearest string
+	err := vm.db.QueryRow(`
+		SELECT term FROM collection_terms
+		WHERE collection_id = $1
+		  AND term % $2
+		  AND similarity(term, $2) >= $3
+		ORDER BY similarity(term, $2) DESC, doc_count DESC, term
+		LIMIT 1
+	`, collectionID, term, minSimilarity).Scan(&nearest)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find nearest term: %w", err)
+	}
+
+	return nearest, nil
+}
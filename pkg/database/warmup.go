@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// PrewarmExtension is the name of the pg_prewarm extension, which loads
+// relations into the buffer cache
+const PrewarmExtension = "pg_prewarm"
+
+// WarmupResult describes what WarmUpCollection loaded
+type WarmupResult struct {
+	Chunks    int      // Chunks of the collection that were read
+	Prewarmed []string // Indexes loaded into the buffer cache with pg_prewarm
+}
+
+// WarmUpCollection reads a collection's chunks and runs a vector search on
+// it, so the first real search finds the table and its vector index in the
+// database's caches. When the pg_prewarm extension is installed, the
+// collection's vector and text indexes are loaded into the buffer cache too.
+func WarmUpCollection(db *sql.DB, collectionID string) (*WarmupResult, error) {
+	result := &WarmupResult{}
+
+	status, err := CheckExtension(db, PrewarmExtension)
+	if err != nil {
+		return nil, err
+	}
+	if status.Installed {
+		rows, err := db.Query(`
+			SELECT indexname, pg_prewarm(format('%I', indexname)::regclass)
+			FROM pg_indexes
+			WHERE tablename = 'documents'
+			  AND indexname = ANY(ARRAY[$1, 'idx_documents_embedding_hnsw', 'idx_documents_content_tsv'])
+			ORDER BY indexname
+		`, vectorIndexName(collectionID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to prewarm indexes: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			var blocks int64
+			if err := rows.Scan(&name, &blocks); err != nil {
+				return nil, fmt.Errorf("failed to prewarm indexes: %w", err)
+			}
+			result.Prewarmed = append(result.Prewarmed, name)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to prewarm indexes: %w", err)
+		}
+	}
+
+	// Reading every chunk loads the collection's table pages
+	var totalLength int64
+	err = db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(length(content)), 0)
+		FROM documents
+		WHERE collection_id = $1
+	`, collectionID).Scan(&result.Chunks, &totalLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection: %w", err)
+	}
+
+	// Search with the embedding of one of the chunks, so no embedding
+	// request is needed
+	var embedding pgvector.Vector
+	err = db.QueryRow(`
+		SELECT embedding
+		FROM documents
+		WHERE collection_id = $1 AND embedding IS NOT NULL
+		LIMIT 1
+	`, collectionID).Scan(&embedding)
+	if err == sql.ErrNoRows {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding: %w", err)
+	}
+
+	opts := &SearchOptions{SearchType: SearchTypeVector, MaxDistance: 1.0}
+	_, err = NewSearchEngine(db).SearchDocumentsWithOptions(collectionID, embedding.Slice(), "", 10, opts)
+	// The pages are read even when the content can't be decrypted
+	if err != nil && !errors.Is(err, ErrContentEncrypted) {
+		return nil, err
+	}
+
+	return result, nil
+}
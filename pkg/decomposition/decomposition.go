@@ -0,0 +1,84 @@
+// Package decomposition splits complex questions into sub-questions that are
+// retrieved separately. A comparative question such as "how do Postgres and
+// MySQL handle replication?" retrieves documents about one side or the other
+// as a whole, but one sub-question per side finds both.
+package decomposition
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// decomposePrompt asks the chat model to split a question into sub-questions
+const decomposePrompt = `Split the question below into at most %d self-contained sub-questions that can each be answered by searching documents on its own, such as one per item that is compared.
+If the question is simple enough to search for as it is, reply with the question itself.
+Reply with one sub-question per line and nothing else.
+
+Question: %s`
+
+// listMarkerPattern matches bullets and numbering at the start of a line
+var listMarkerPattern = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s*`)
+
+// Service splits questions into sub-questions with a chat model
+type Service struct {
+	chat   client.Client
+	config *config.DecompositionConfig
+}
+
+// New creates a new question decomposition service
+func New(chat client.Client, config *config.DecompositionConfig) *Service {
+	return &Service{
+		chat:   chat,
+		config: config,
+	}
+}
+
+// Decompose returns the sub-questions of a question, or none when the
+// question is simple enough to be retrieved as it is
+func (s *Service) Decompose(ctx context.Context, question string) ([]string, error) {
+	n := s.config.GetMaxSubQuestions()
+	messages := []client.Message{
+		{Role: "system", Content: "You split complex questions into simpler ones to improve document retrieval."},
+		{Role: "user", Content: fmt.Sprintf(decomposePrompt, n, question)},
+	}
+
+	response, err := s.chat.Chat(ctx, s.config.Model, messages, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split question into sub-questions: %w", err)
+	}
+
+	subQuestions := parseSubQuestions(response.Message.Content, n)
+	if len(subQuestions) < 2 {
+		return nil, nil
+	}
+	return subQuestions, nil
+}
+
+// parseSubQuestions extracts up to n distinct sub-questions from a model
+// response
+func parseSubQuestions(text string, n int) []string {
+	text = client.StripThinking(text)
+
+	var subQuestions []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(listMarkerPattern.ReplaceAllString(strings.TrimSpace(line), ""))
+		line = strings.Trim(line, `"'`)
+		key := strings.Join(strings.FieldsFunc(strings.ToLower(line), unicode.IsSpace), " ")
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		subQuestions = append(subQuestions, line)
+		if len(subQuestions) == n {
+			break
+		}
+	}
+	return subQuestions
+}
@@ -0,0 +1,48 @@
+package decomposition
+
+import (
+	"context"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockChat returns a fixed chat response
+type mockChat struct {
+	client.Client
+	response string
+	messages []client.Message
+}
+
+func (m *mockChat) Chat(ctx context.Context, model string, messages []client.Message, stream bool) (*client.ChatResponse, error) {
+	m.messages = messages
+	return &client.ChatResponse{Message: client.Message{Role: "assistant", Content: m.response}}, nil
+}
+
+func TestDecompose(t *testing.T) {
+	chat := &mockChat{response: "<think>\ntwo databases\n</think>\n1. How does Postgres handle replication?\n2. How does MySQL handle replication?\n3. how does  postgres handle replication?\n"}
+	service := New(chat, &config.DecompositionConfig{MaxSubQuestions: 4})
+
+	subQuestions, err := service.Decompose(context.Background(), "How do Postgres and MySQL differ in replication?")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"How does Postgres handle replication?", "How does MySQL handle replication?"}, subQuestions)
+	require.Len(t, chat.messages, 2)
+	assert.Contains(t, chat.messages[1].Content, "at most 4 self-contained sub-questions")
+}
+
+func TestDecomposeSimpleQuestion(t *testing.T) {
+	chat := &mockChat{response: "How do I rotate the database password?"}
+	service := New(chat, &config.DecompositionConfig{})
+
+	subQuestions, err := service.Decompose(context.Background(), "How do I rotate the database password?")
+	require.NoError(t, err)
+	assert.Empty(t, subQuestions)
+}
+
+func TestParseSubQuestions(t *testing.T) {
+	text := "- first?\n\n* second?\n'third?'\nfourth?"
+	assert.Equal(t, []string{"first?", "second?", "third?"}, parseSubQuestions(text, 3))
+}
@@ -0,0 +1,174 @@
+package dockerdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for the managed PostgreSQL container
+const (
+	DefaultName   = "rag-cli-postgres"
+	DefaultImage  = "pgvector/pgvector:pg17"
+	DefaultVolume = "rag-cli-pgdata"
+	DefaultPort   = 5432
+)
+
+// ErrDockerUnavailable is returned when the docker CLI or daemon can't be used
+var ErrDockerUnavailable = errors.New("docker is not available")
+
+// Options describes the PostgreSQL container to run
+type Options struct {
+	Name     string // Container name
+	Image    string // Image with PostgreSQL and pgvector
+	Volume   string // Named volume holding the data directory
+	Port     int    // Host port mapped to PostgreSQL's port
+	User     string
+	Password string
+	Database string
+}
+
+// State is the state of the container as reported by docker
+type State string
+
+const (
+	StateMissing State = "missing" // No container with the name exists
+	StateRunning State = "running"
+)
+
+// Manager runs the PostgreSQL container with the docker CLI
+type Manager struct {
+	docker string
+}
+
+// New creates a new container manager using the docker CLI on the PATH
+func New() *Manager {
+	return &Manager{docker: "docker"}
+}
+
+// Available checks that the docker CLI is installed and the daemon is reachable
+func (m *Manager) Available(ctx context.Context) error {
+	if _, err := exec.LookPath(m.docker); err != nil {
+		return fmt.Errorf("%w: %s not found on PATH", ErrDockerUnavailable, m.docker)
+	}
+	if _, err := m.run(ctx, "version", "--format", "{{.Server.Version}}"); err != nil {
+		return fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
+	}
+	return nil
+}
+
+// State returns the state of the named container
+func (m *Manager) State(ctx context.Context, name string) (State, error) {
+	out, err := m.run(ctx, "container", "inspect", "--format", "{{.State.Status}}", name)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "no such") {
+			return StateMissing, nil
+		}
+		return "", err
+	}
+	return State(strings.TrimSpace(out)), nil
+}
+
+// Start creates and starts the container, or starts it again if it exists
+// but is stopped. It returns whether a new container was created.
+func (m *Manager) Start(ctx context.Context, opts Options) (bool, error) {
+	state, err := m.State(ctx, opts.Name)
+	if err != nil {
+		return false, err
+	}
+
+	switch state {
+	case StateRunning:
+		return false, nil
+	case StateMissing:
+		if _, err := m.run(ctx, RunArgs(opts)...); err != nil {
+			return false, fmt.Errorf("failed to start container: %w", err)
+		}
+		return true, nil
+	default:
+		if _, err := m.run(ctx, "start", opts.Name); err != nil {
+			return false, fmt.Errorf("failed to start container: %w", err)
+		}
+		return false, nil
+	}
+}
+
+// WaitReady waits until PostgreSQL in the container accepts connections
+func (m *Manager) WaitReady(ctx context.Context, opts Options, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		_, err := m.run(ctx, "exec", opts.Name, "pg_isready", "-h", "localhost", "-U", opts.User, "-d", opts.Database)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database did not become ready within %s: %w", timeout, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Stop stops the container
+func (m *Manager) Stop(ctx context.Context, name string) error {
+	if _, err := m.run(ctx, "stop", name); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+	return nil
+}
+
+// Remove removes the container, and its data volume if volume is not empty
+func (m *Manager) Remove(ctx context.Context, name, volume string) error {
+	if _, err := m.run(ctx, "rm", "--force", name); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	if volume != "" {
+		if _, err := m.run(ctx, "volume", "rm", volume); err != nil {
+			return fmt.Errorf("failed to remove volume: %w", err)
+		}
+	}
+	return nil
+}
+
+// RunArgs returns the docker arguments that create and start the container.
+// PostgreSQL is only published on the loopback interface.
+func RunArgs(opts Options) []string {
+	args := []string{
+		"run", "--detach",
+		"--name", opts.Name,
+		"--restart", "unless-stopped",
+		"--publish", "127.0.0.1:" + strconv.Itoa(opts.Port) + ":5432",
+		"--env", "POSTGRES_USER=" + opts.User,
+		"--env", "POSTGRES_PASSWORD=" + opts.Password,
+		"--env", "POSTGRES_DB=" + opts.Database,
+	}
+	if opts.Volume != "" {
+		args = append(args, "--volume", opts.Volume+":/var/lib/postgresql/data")
+	}
+	return append(args, opts.Image)
+}
+
+// run runs a docker command and returns its standard output. Errors include
+// what docker printed on standard error.
+func (m *Manager) run(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, m.docker, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("docker %s: %s", args[0], message)
+		}
+		return "", fmt.Errorf("docker %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,38 @@
+package dockerdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunArgs(t *testing.T) {
+	args := RunArgs(Options{
+		Name:     DefaultName,
+		Image:    DefaultImage,
+		Volume:   DefaultVolume,
+		Port:     5433,
+		User:     "postgres",
+		Password: "secret",
+		Database: "rag_cli",
+	})
+
+	assert.Equal(t, []string{
+		"run", "--detach",
+		"--name", "rag-cli-postgres",
+		"--restart", "unless-stopped",
+		"--publish", "127.0.0.1:5433:5432",
+		"--env", "POSTGRES_USER=postgres",
+		"--env", "POSTGRES_PASSWORD=secret",
+		"--env", "POSTGRES_DB=rag_cli",
+		"--volume", "rag-cli-pgdata:/var/lib/postgresql/data",
+		"pgvector/pgvector:pg17",
+	}, args)
+}
+
+func TestRunArgsWithoutVolume(t *testing.T) {
+	args := RunArgs(Options{Name: "pg", Image: "img", Port: 5432, User: "u", Password: "p", Database: "d"})
+
+	assert.NotContains(t, args, "--volume")
+	assert.Equal(t, "img", args[len(args)-1])
+}
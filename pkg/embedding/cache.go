@@ -0,0 +1,114 @@
+package embedding
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+)
+
+// TextEmbedder embeds single texts, such as search queries
+type TextEmbedder interface {
+	GenerateEmbeddingForText(ctx context.Context, text string) ([]float32, error)
+}
+
+// Cache is a least recently used cache of text embeddings. Texts that only
+// differ in case or whitespace share an entry. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+}
+
+// cacheEntry is a cached embedding and the key it is stored under
+type cacheEntry struct {
+	key       string
+	embedding []float32
+}
+
+// NewCache creates a cache that keeps the embeddings of up to size texts
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// cacheKey normalizes a text to the key its embedding is cached under
+func cacheKey(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// Get returns the cached embedding of a text
+func (c *Cache) Get(text string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[cacheKey(text)]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).embedding, true
+}
+
+// Add caches the embedding of a text, evicting the least recently used
+// embedding when the cache is full
+func (c *Cache) Add(text string, embedding []float32) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(text)
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).embedding = embedding
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, embedding: embedding})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached embeddings
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// cachedEmbedder embeds texts that are not in its cache
+type cachedEmbedder struct {
+	embedder TextEmbedder
+	cache    *Cache
+}
+
+// NewCachedEmbedder returns a TextEmbedder that reuses the embeddings in
+// cache and adds the ones it generates to it
+func NewCachedEmbedder(embedder TextEmbedder, cache *Cache) TextEmbedder {
+	return &cachedEmbedder{embedder: embedder, cache: cache}
+}
+
+// GenerateEmbeddingForText returns the cached embedding of a text, or
+// generates and caches it
+func (e *cachedEmbedder) GenerateEmbeddingForText(ctx context.Context, text string) ([]float32, error) {
+	if embedding, ok := e.cache.Get(text); ok {
+		return embedding, nil
+	}
+
+	embedding, err := e.embedder.GenerateEmbeddingForText(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	e.cache.Add(text, embedding)
+	return embedding, nil
+}
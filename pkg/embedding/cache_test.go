@@ -0,0 +1,54 @@
+package embedding
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingEmbedder returns a one-dimensional embedding per call
+type countingEmbedder struct {
+	calls int
+}
+
+func (e *countingEmbedder) GenerateEmbeddingForText(ctx context.Context, text string) ([]float32, error) {
+	e.calls++
+	return []float32{float32(e.calls)}, nil
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(2)
+	cache.Add("first", []float32{1})
+	cache.Add("second", []float32{2})
+
+	// Using the first embedding makes the second the least recently used
+	_, ok := cache.Get("first")
+	require.True(t, ok)
+	cache.Add("third", []float32{3})
+
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.Get("second")
+	assert.False(t, ok)
+	embedding, ok := cache.Get("first")
+	assert.True(t, ok)
+	assert.Equal(t, []float32{1}, embedding)
+}
+
+func TestCachedEmbedder(t *testing.T) {
+	embedder := &countingEmbedder{}
+	cached := NewCachedEmbedder(embedder, NewCache(8))
+	ctx := context.Background()
+
+	first, err := cached.GenerateEmbeddingForText(ctx, "How do I reset my password?")
+	require.NoError(t, err)
+	again, err := cached.GenerateEmbeddingForText(ctx, "  how do I reset  my password? ")
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+	assert.Equal(t, 1, embedder.calls)
+
+	_, err = cached.GenerateEmbeddingForText(ctx, "What about my username?")
+	require.NoError(t, err)
+	assert.Equal(t, 2, embedder.calls)
+}
@@ -0,0 +1,300 @@
+package embedding
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/extract"
+)
+
+// codeLanguage describes how the source files of a language are split into
+// their declarations. Declarations start at lines that aren't indented, and
+// are named by the first of the symbol patterns their first line matches.
+type codeLanguage struct {
+	comments []string         // Prefixes of comment and decorator lines, which belong to the declaration below them
+	symbols  []*regexp.Regexp // Patterns whose groups name a declaration, joined by dots
+	members  []*regexp.Regexp // Patterns naming the indented members of large declarations, such as methods
+}
+
+var (
+	goLanguage = &codeLanguage{
+		comments: []string{"//", "/*", " *", "*/"},
+		symbols: []*regexp.Regexp{
+			regexp.MustCompile(`^func\s+\(\s*(?:\w+\s+)?\*?\s*(\w+)(?:\[[^\]]*\])?\s*\)\s*(\w+)`),
+			regexp.MustCompile(`^func\s+(\w+)`),
+			regexp.MustCompile(`^type\s+(\w+)`),
+			regexp.MustCompile(`^(?:var|const)\s+(\w+)`),
+		},
+	}
+	pythonLanguage = &codeLanguage{
+		comments: []string{"#", "@"},
+		symbols: []*regexp.Regexp{
+			regexp.MustCompile(`^(?:async\s+)?def\s+(\w+)`),
+			regexp.MustCompile(`^class\s+(\w+)`),
+		},
+		members: []*regexp.Regexp{
+			regexp.MustCompile(`^\s+(?:async\s+)?def\s+(\w+)`),
+		},
+	}
+	javaScriptLanguage = &codeLanguage{
+		comments: []string{"//", "/*", " *", "*/", "@"},
+		symbols: []*regexp.Regexp{
+			regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(\w+)`),
+			regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`),
+			regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*=>|\w+\s*=>)`),
+			regexp.MustCompile(`^(?:export\s+)?(?:interface|type|enum)\s+(\w+)`),
+		},
+		members: []*regexp.Regexp{
+			regexp.MustCompile(`^\s+(?:(?:public|private|protected|static|async|get|set)\s+)*(\w+)\s*\([^)]*\)\s*(?::\s*[^{]+)?\{\s*$`),
+		},
+	}
+)
+
+// codeLanguages are the languages whose files are split into declarations,
+// by file extension
+var codeLanguages = map[string]*codeLanguage{
+	".go":  goLanguage,
+	".py":  pythonLanguage,
+	".js":  javaScriptLanguage,
+	".jsx": javaScriptLanguage,
+	".mjs": javaScriptLanguage,
+	".cjs": javaScriptLanguage,
+	".ts":  javaScriptLanguage,
+	".tsx": javaScriptLanguage,
+}
+
+// memberKeywords are words the member patterns match that don't name a
+// member, such as the if of `  if (done) {`
+var memberKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "function": true, "return": true,
+}
+
+// codeUnit is a declaration, or the code between declarations, with the
+// symbol it defines, if any
+type codeUnit struct {
+	text   string
+	symbol string
+	part   bool // Part of a declaration split between lines, which has a chunk of its own
+}
+
+// IsCodeFile reports whether a file is source code that ChunkCode splits
+// into declarations, by its extension
+func IsCodeFile(filePath string) bool {
+	return codeLanguages[strings.ToLower(path.Ext(filePath))] != nil
+}
+
+// ChunkCode splits the source code of a file into chunks between its
+// top-level declarations, such as functions, types and classes, with the
+// comments above them. Small declarations share chunks; large ones are split
+// between their methods, if they have any, and then between blank lines.
+// Each chunk has the names of the symbols it defines as its symbol
+// metadata, and chunks don't overlap. Files of unknown languages are chunked
+// by ChunkText.
+func (s *Service) ChunkCode(filePath, text string, metadata map[string]string) ([]*Chunk, error) {
+	language := codeLanguages[strings.ToLower(path.Ext(filePath))]
+	if language == nil {
+		return s.ChunkText(text, metadata)
+	}
+
+	var units []codeUnit
+	for _, unit := range language.declarations(text) {
+		units = append(units, s.splitCodeUnit(language, unit)...)
+	}
+
+	var chunks []*Chunk
+	var current strings.Builder
+	var symbols []string
+	finish := func() {
+		content := strings.TrimRight(strings.Trim(current.String(), "\n"), " \t\n")
+		if strings.TrimSpace(content) != "" {
+			chunkMeta := chunkMetadata(metadata, len(chunks), 0)
+			if len(symbols) > 0 {
+				chunkMeta[extract.MetadataSymbol] = strings.Join(symbols, ", ")
+			}
+			chunks = append(chunks, &Chunk{Content: content, Index: len(chunks), Metadata: chunkMeta})
+		}
+		current.Reset()
+		symbols = nil
+	}
+	for _, unit := range units {
+		if unit.part || (current.Len() > 0 && s.length(current.String()+"\n"+unit.text) > s.config.ChunkSize) {
+			finish()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(unit.text)
+		if unit.symbol != "" && (len(symbols) == 0 || symbols[len(symbols)-1] != unit.symbol) {
+			symbols = append(symbols, unit.symbol)
+		}
+		if unit.part {
+			finish()
+		}
+	}
+	finish()
+
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("empty text provided")
+	}
+	return chunks, nil
+}
+
+// declarations splits source code into its top-level declarations and the
+// code between them
+func (l *codeLanguage) declarations(text string) []codeUnit {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	starts := []int{0}
+	for i, line := range lines {
+		if i == 0 || !l.startsDeclaration(line) {
+			continue
+		}
+		// Comments and decorators right above a declaration belong to it
+		start := i
+		for start > 0 && l.isComment(lines[start-1]) {
+			start--
+		}
+		if start > starts[len(starts)-1] {
+			starts = append(starts, start)
+		}
+	}
+
+	units := make([]codeUnit, len(starts))
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		units[i] = codeUnit{text: strings.Join(lines[start:end], "\n"), symbol: l.symbol(lines[start:end])}
+	}
+	return units
+}
+
+// startsDeclaration reports whether a line starts a top-level declaration,
+// or other top-level code: it isn't indented, blank, a comment or the end
+// of a block
+func (l *codeLanguage) startsDeclaration(line string) bool {
+	if line == "" || line[0] == ' ' || line[0] == '\t' || l.isComment(line) {
+		return false
+	}
+	return !strings.ContainsRune(")]}", rune(line[0]))
+}
+
+// isComment reports whether a line is a comment or decorator
+func (l *codeLanguage) isComment(line string) bool {
+	for _, prefix := range l.comments {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// symbol names the declaration of lines by the first line that isn't a
+// comment, or returns an empty string for code that declares nothing
+func (l *codeLanguage) symbol(lines []string) string {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" || l.isComment(line) {
+			continue
+		}
+		return matchSymbol(l.symbols, line)
+	}
+	return ""
+}
+
+// matchSymbol returns the name a line declares by the first pattern it
+// matches, joining the pattern's groups by dots
+func matchSymbol(patterns []*regexp.Regexp, line string) string {
+	for _, pattern := range patterns {
+		if match := pattern.FindStringSubmatch(line); match != nil {
+			return strings.Join(match[1:], ".")
+		}
+	}
+	return ""
+}
+
+// splitCodeUnit splits a declaration larger than a chunk between its
+// members, named after the declaration, and then between blank lines and
+// lines
+func (s *Service) splitCodeUnit(language *codeLanguage, unit codeUnit) []codeUnit {
+	if s.length(unit.text) <= s.config.ChunkSize {
+		return []codeUnit{unit}
+	}
+
+	var units []codeUnit
+	for _, member := range language.splitMembers(unit) {
+		if s.length(member.text) <= s.config.ChunkSize {
+			units = append(units, member)
+			continue
+		}
+		for _, part := range s.splitCodeLines(member.text) {
+			units = append(units, codeUnit{text: part, symbol: member.symbol, part: true})
+		}
+	}
+	return units
+}
+
+// splitMembers splits a declaration between its members, such as the methods of a
+// class. Members are as indented as the first one, so functions nested in
+// them stay in them. The code before the first member is named after the
+// declaration, and each member after the declaration and the member.
+func (l *codeLanguage) splitMembers(unit codeUnit) []codeUnit {
+	lines := strings.Split(unit.text, "\n")
+	var units []codeUnit
+	start, symbol := 0, unit.symbol
+	indent := ""
+	for i := 1; i < len(lines); i++ {
+		name := matchSymbol(l.members, lines[i])
+		if name == "" || memberKeywords[name] {
+			continue
+		}
+		lineIndent := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+		if indent == "" {
+			indent = lineIndent
+		} else if lineIndent != indent {
+			continue
+		}
+		end := i
+		for end > start+1 && l.isComment(strings.TrimLeft(lines[end-1], " \t")) {
+			end--
+		}
+		units = append(units, codeUnit{text: strings.Join(lines[start:end], "\n"), symbol: symbol})
+		start = end
+		symbol = name
+		if unit.symbol != "" {
+			symbol = unit.symbol + "." + name
+		}
+	}
+	return append(units, codeUnit{text: strings.Join(lines[start:], "\n"), symbol: symbol})
+}
+
+// splitCodeLines splits code into parts of up to a chunk, between blank
+// lines where possible and between lines otherwise. A single line larger
+// than a chunk is a part of its own, and lines that only close blocks stay
+// with the part before them.
+func (s *Service) splitCodeLines(code string) []string {
+	var pieces []string
+	for _, block := range strings.SplitAfter(code, "\n\n") {
+		if s.length(block) <= s.config.ChunkSize {
+			pieces = append(pieces, block)
+			continue
+		}
+		pieces = append(pieces, strings.SplitAfter(block, "\n")...)
+	}
+
+	var parts []string
+	var current strings.Builder
+	for _, piece := range pieces {
+		closing := strings.Trim(piece, " \t\n)]};,") == ""
+		if current.Len() > 0 && !closing && s.length(current.String()+piece) > s.config.ChunkSize {
+			parts = append(parts, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+		}
+		current.WriteString(piece)
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		parts = append(parts, strings.TrimRight(current.String(), "\n"))
+	}
+	return parts
+}
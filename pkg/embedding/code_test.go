@@ -0,0 +1,167 @@
+package embedding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/extract"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkSymbols returns the contents and symbols of chunks
+func chunkSymbols(chunks []*Chunk) ([]string, []string) {
+	var contents, symbols []string
+	for _, chunk := range chunks {
+		contents = append(contents, chunk.Content)
+		symbols = append(symbols, chunk.Metadata[extract.MetadataSymbol])
+	}
+	return contents, symbols
+}
+
+func TestChunkCodeGo(t *testing.T) {
+	source := `package store
+
+import "errors"
+
+// ErrNotFound is returned for missing keys
+var ErrNotFound = errors.New("not found")
+
+// Store keeps values by key
+type Store struct {
+	values map[string]string
+}
+
+// Get returns the value of a key
+func (s *Store) Get(key string) (string, error) {
+	value, ok := s.values[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func New() *Store {
+	return &Store{values: make(map[string]string)}
+}
+`
+	service := New(nil, &config.EmbeddingConfig{ChunkSize: 180, ChunkOverlap: 20})
+	chunks, err := service.ChunkCode("store.go", source, map[string]string{"file": "store.go"})
+	require.NoError(t, err)
+
+	contents, symbols := chunkSymbols(chunks)
+	assert.Equal(t, []string{"ErrNotFound", "Store", "Store.Get", "New"}, symbols)
+	assert.True(t, strings.HasPrefix(contents[0], "package store"), "code before the first declaration stays with it")
+	assert.True(t, strings.HasPrefix(contents[2], "// Get returns"), "comments stay with their declaration")
+	assert.True(t, strings.HasSuffix(contents[2], "return value, nil\n}"))
+	for i, chunk := range chunks {
+		assert.Equal(t, i, chunk.Index)
+		assert.Equal(t, "store.go", chunk.Metadata["file"])
+	}
+
+	// Small declarations share chunks
+	service = New(nil, &config.EmbeddingConfig{ChunkSize: 1000})
+	chunks, err = service.ChunkCode("store.go", source, nil)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "ErrNotFound, Store, Store.Get, New", chunks[0].Metadata[extract.MetadataSymbol])
+	assert.Equal(t, strings.TrimSpace(source), chunks[0].Content)
+}
+
+func TestChunkCodePython(t *testing.T) {
+	source := `import os
+
+
+class Parser:
+    """Parses config files."""
+
+    def __init__(self, path):
+        self.path = path
+
+    @property
+    def name(self):
+        return os.path.basename(self.path)
+
+    def parse(self):
+        def strip(line):
+            return line.strip()
+        with open(self.path) as f:
+            return [strip(line) for line in f]
+
+
+@cache
+def load(path):
+    return Parser(path).parse()
+
+
+if __name__ == "__main__":
+    print(load("app.cfg"))
+`
+	service := New(nil, &config.EmbeddingConfig{ChunkSize: 120})
+	chunks, err := service.ChunkCode("parser.py", source, nil)
+	require.NoError(t, err)
+
+	// The class is too large for a chunk, so it is split between its
+	// methods, but not at the function nested in one. The method too large
+	// for a chunk is split between lines into chunks of its own.
+	contents, symbols := chunkSymbols(chunks)
+	assert.Equal(t, []string{"Parser, Parser.__init__", "Parser.name", "Parser.parse", "Parser.parse", "load"}, symbols)
+	assert.True(t, strings.HasPrefix(contents[1], "    @property"), "decorators stay with their method")
+	assert.Contains(t, contents[2], "def strip(line)")
+	assert.True(t, strings.HasPrefix(contents[4], "@cache"))
+	assert.True(t, strings.HasSuffix(contents[4], `print(load("app.cfg"))`), "code that declares nothing joins the declaration before it")
+}
+
+func TestChunkCodeJavaScript(t *testing.T) {
+	source := `import { api } from "./api";
+
+export async function fetchUser(id) {
+  return api.get("/users/" + id);
+}
+
+export const formatName = (user) => {
+  return user.first + " " + user.last;
+};
+
+export default class UserCard {
+  render() {
+    if (this.user) {
+      return formatName(this.user);
+    }
+  }
+}
+`
+	service := New(nil, &config.EmbeddingConfig{ChunkSize: 100})
+	chunks, err := service.ChunkCode("user.js", source, nil)
+	require.NoError(t, err)
+
+	contents, symbols := chunkSymbols(chunks)
+	assert.Equal(t, []string{"", "fetchUser", "formatName", "UserCard", "UserCard.render"}, symbols)
+	assert.Equal(t, "export default class UserCard {", contents[3])
+	assert.True(t, strings.HasSuffix(contents[4], "  }\n}"), "the if isn't a method")
+}
+
+func TestChunkCodeSplitsLargeFunctions(t *testing.T) {
+	source := "func Run() {\n\tstep(1)\n\tstep(2)\n\n\tstep(3)\n\tstep(4)\n}\n"
+	service := New(nil, &config.EmbeddingConfig{ChunkSize: 32})
+	chunks, err := service.ChunkCode("run.go", source, nil)
+	require.NoError(t, err)
+
+	contents, symbols := chunkSymbols(chunks)
+	assert.Equal(t, []string{"func Run() {\n\tstep(1)\n\tstep(2)", "\tstep(3)\n\tstep(4)\n}"}, contents)
+	assert.Equal(t, []string{"Run", "Run"}, symbols)
+}
+
+func TestIsCodeFile(t *testing.T) {
+	assert.True(t, IsCodeFile("cmd/main.go"))
+	assert.True(t, IsCodeFile("app/Parser.PY"))
+	assert.True(t, IsCodeFile("web/index.tsx"))
+	assert.False(t, IsCodeFile("main.rs"))
+
+	// Other languages are chunked as text
+	service := New(nil, &config.EmbeddingConfig{ChunkSize: 1000})
+	chunks, err := service.ChunkCode("main.rs", "fn main() {}", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", chunks[0].Metadata[extract.MetadataSymbol])
+}
@@ -1,8 +1,11 @@
 package embedding
 
 import (
+	"context"
 	"fmt"
 	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
 )
 
 // ModelDimensions maps embedding model names to their dimensions
@@ -72,7 +75,49 @@ func GetModelDimensions(modelName string) (int, error) {
 		return 1024, nil
 	}
 
-	return 0, fmt.Errorf("unknown embedding model: %s. Please specify dimensions manually in config", modelName)
+	return 0, fmt.Errorf("unknown embedding model: %s. Please add its dimensions to embedding.model_dimensions in config", modelName)
+}
+
+// LookupDimensions returns the dimensions of a model from overrides, such as
+// embedding.model_dimensions, falling back to the built-in dimensions.
+// Overrides are matched ignoring case, since configuration keys are read in
+// lower case.
+func LookupDimensions(modelName string, overrides map[string]int) (int, error) {
+	if dimensions, exists := overrides[modelName]; exists {
+		return dimensions, nil
+	}
+	for name, dimensions := range overrides {
+		if strings.EqualFold(name, modelName) {
+			return dimensions, nil
+		}
+	}
+	return GetModelDimensions(modelName)
+}
+
+// probeText is embedded to find the dimensions of a model
+const probeText = "dimensions probe"
+
+// ProbeDimensions returns the dimensions of the embedder's model by
+// embedding a short text with it
+func ProbeDimensions(ctx context.Context, embedder client.Embedder) (int, error) {
+	embedding, err := embedder.GenerateEmbedding(ctx, probeText, client.InputTypeDocument)
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe embedding dimensions: %w", err)
+	}
+	if len(embedding) == 0 {
+		return 0, fmt.Errorf("failed to probe embedding dimensions: the model returned an empty embedding")
+	}
+	return len(embedding), nil
+}
+
+// ResolveDimensions returns the dimensions of the embedder's model, looking
+// them up with LookupDimensions or, for models neither the overrides nor the
+// built-in dimensions know, probing the model
+func ResolveDimensions(ctx context.Context, modelName string, overrides map[string]int, embedder client.Embedder) (int, error) {
+	if dimensions, err := LookupDimensions(modelName, overrides); err == nil {
+		return dimensions, nil
+	}
+	return ProbeDimensions(ctx, embedder)
 }
 
 // ValidateDimensions validates that the provided dimensions match the model
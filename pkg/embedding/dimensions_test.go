@@ -0,0 +1,48 @@
+package embedding
+
+import (
+	"context"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupDimensions(t *testing.T) {
+	// Configuration keys are read in lower case
+	overrides := map[string]int{"my-embedder:latest": 512, "nomic-embed-text": 1024}
+
+	dimensions, err := LookupDimensions("My-Embedder:latest", overrides)
+	require.NoError(t, err)
+	assert.Equal(t, 512, dimensions)
+
+	dimensions, err = LookupDimensions("nomic-embed-text", overrides)
+	require.NoError(t, err)
+	assert.Equal(t, 1024, dimensions, "overrides take precedence over built-in dimensions")
+
+	dimensions, err = LookupDimensions("text-embedding-3-large", overrides)
+	require.NoError(t, err)
+	assert.Equal(t, 3072, dimensions)
+
+	_, err = LookupDimensions("unknown-embedder", overrides)
+	assert.ErrorContains(t, err, "embedding.model_dimensions")
+}
+
+func TestResolveDimensions(t *testing.T) {
+	embedder := &clienttest.Client{Dimensions: 96}
+
+	// Known models aren't probed
+	dimensions, err := ResolveDimensions(context.Background(), "all-minilm", nil, embedder)
+	require.NoError(t, err)
+	assert.Equal(t, 384, dimensions)
+	assert.Equal(t, 0, embedder.CallCount("GenerateEmbedding"))
+
+	dimensions, err = ResolveDimensions(context.Background(), "unknown-embedder", nil, embedder)
+	require.NoError(t, err)
+	assert.Equal(t, 96, dimensions)
+	assert.Equal(t, 1, embedder.CallCount("GenerateEmbedding"))
+
+	_, err = ResolveDimensions(context.Background(), "unknown-embedder", nil, &clienttest.Client{Err: assert.AnError})
+	assert.ErrorIs(t, err, assert.AnError)
+}
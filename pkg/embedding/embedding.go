@@ -3,17 +3,21 @@ package embedding
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/busybytelab.com/rag-cli/pkg/client"
 	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/extract"
 )
 
 // Service represents the embedding service
 type Service struct {
-	embedder client.Embedder
-	config   *config.EmbeddingConfig
+	embedder  client.Embedder
+	config    *config.EmbeddingConfig
+	tokenizer client.Tokenizer // Sizes chunks with the tokens chunk strategy
 }
 
 // Chunk represents a text chunk with its metadata
@@ -32,7 +36,16 @@ func New(embedder client.Embedder, config *config.EmbeddingConfig) *Service {
 	}
 }
 
-// ChunkText splits text into chunks based on configuration
+// SetTokenizer sets the tokenizer that sizes chunks when
+// embedding.chunk_strategy is tokens, the client.ApproximateTokenizer by default
+func (s *Service) SetTokenizer(tokenizer client.Tokenizer) {
+	s.tokenizer = tokenizer
+}
+
+// ChunkText splits text into chunks based on configuration. Chunks are
+// sized in bytes, or in tokens when embedding.chunk_strategy is tokens; the
+// overlap is recorded in bytes either way. Text is split between sentences,
+// or at embedding.separators when embedding.chunk_strategy is recursive.
 func (s *Service) ChunkText(text string, metadata map[string]string) ([]*Chunk, error) {
 	if metadata == nil {
 		metadata = make(map[string]string)
@@ -43,6 +56,9 @@ func (s *Service) ChunkText(text string, metadata map[string]string) ([]*Chunk,
 	if text == "" {
 		return nil, fmt.Errorf("empty text provided")
 	}
+	if s.config.GetChunkStrategy() == config.ChunkStrategyRecursive {
+		return s.chunkRecursive(text, metadata), nil
+	}
 
 	// Split text into sentences first
 	sentences := s.splitIntoSentences(text)
@@ -51,24 +67,29 @@ func (s *Service) ChunkText(text string, metadata map[string]string) ([]*Chunk,
 	var currentChunk strings.Builder
 	currentLength := 0
 	chunkIndex := 0
+	overlap := 0 // Length of the current chunk's copy of the previous chunk's end
 
 	for _, sentence := range sentences {
-		sentenceLength := len(sentence)
+		sentenceLength := s.length(sentence)
 
 		// If adding this sentence would exceed chunk size, finalize current chunk
 		if currentLength+sentenceLength > s.config.ChunkSize && currentLength > 0 {
 			chunk := &Chunk{
 				Content:  strings.TrimSpace(currentChunk.String()),
 				Index:    chunkIndex,
-				Metadata: copyMetadata(metadata),
+				Metadata: chunkMetadata(metadata, chunkIndex, overlap),
 			}
 			chunks = append(chunks, chunk)
 
 			// Start new chunk with overlap
 			overlapText := s.getOverlapText(currentChunk.String(), s.config.ChunkOverlap)
 			currentChunk.Reset()
-			currentChunk.WriteString(overlapText)
-			currentLength = len(overlapText)
+			if overlapText != "" {
+				// The overlap lost the whitespace before the next sentence
+				currentChunk.WriteString(overlapText + " ")
+			}
+			currentLength = s.length(currentChunk.String())
+			overlap = len(overlapText)
 			chunkIndex++
 		}
 
@@ -81,7 +102,7 @@ func (s *Service) ChunkText(text string, metadata map[string]string) ([]*Chunk,
 		chunk := &Chunk{
 			Content:  strings.TrimSpace(currentChunk.String()),
 			Index:    chunkIndex,
-			Metadata: copyMetadata(metadata),
+			Metadata: chunkMetadata(metadata, chunkIndex, overlap),
 		}
 		chunks = append(chunks, chunk)
 	}
@@ -89,68 +110,150 @@ func (s *Service) ChunkText(text string, metadata map[string]string) ([]*Chunk,
 	return chunks, nil
 }
 
-// GenerateEmbeddings generates embeddings for all chunks
+// ChunkSections splits the sections of an extracted document into chunks,
+// so no chunk spans two sections. Each chunk has the file's metadata and its
+// section's location, and chunks are numbered across sections.
+func (s *Service) ChunkSections(sections []extract.Section, metadata map[string]string) ([]*Chunk, error) {
+	var chunks []*Chunk
+	for _, section := range sections {
+		if strings.TrimSpace(section.Text) == "" {
+			continue
+		}
+
+		sectionMetadata := copyMetadata(metadata)
+		for key, value := range section.Metadata {
+			sectionMetadata[key] = value
+		}
+		sectionChunks, err := s.ChunkText(section.Text, sectionMetadata)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, chunk := range sectionChunks {
+			chunk.Index = len(chunks)
+			if i == 0 && chunk.Index > 0 {
+				// Sections don't overlap
+				chunk.Metadata[OverlapMetadataKey] = "0"
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("empty text provided")
+	}
+	return chunks, nil
+}
+
+// GenerateEmbeddings generates embeddings for all chunks, sending
+// embedding.batch_size chunks per request
 func (s *Service) GenerateEmbeddings(ctx context.Context, chunks []*Chunk) error {
-	for i, chunk := range chunks {
-		embedding, err := s.embedder.GenerateEmbedding(ctx, chunk.Content)
+	batchSize := s.config.GetBatchSize()
+	for start := 0; start < len(chunks); start += batchSize {
+		end := start + batchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		texts := make([]string, end-start)
+		for i, chunk := range chunks[start:end] {
+			texts[i] = chunk.Content
+		}
+		embeddings, err := s.embedder.GenerateEmbeddings(ctx, texts, client.InputTypeDocument)
 		if err != nil {
-			return fmt.Errorf("failed to generate embedding for chunk %d: %w", i, err)
+			return fmt.Errorf("failed to generate embeddings for chunks %d-%d: %w", start, end-1, err)
+		}
+		if len(embeddings) != len(texts) {
+			return fmt.Errorf("expected %d embeddings for chunks %d-%d, got %d", len(texts), start, end-1, len(embeddings))
+		}
+		for i, chunk := range chunks[start:end] {
+			chunk.Embedding = embeddings[i]
 		}
-		chunk.Embedding = embedding
 	}
 	return nil
 }
 
-// GenerateEmbeddingForText generates embedding for a single text
+// EmbeddingRequests returns the number of requests GenerateEmbeddings sends
+// to embed the given number of chunks
+func (s *Service) EmbeddingRequests(chunks int) int {
+	batchSize := s.config.GetBatchSize()
+	return (chunks + batchSize - 1) / batchSize
+}
+
+// GenerateEmbeddingForText generates the embedding of a search query
 func (s *Service) GenerateEmbeddingForText(ctx context.Context, text string) ([]float32, error) {
-	return s.embedder.GenerateEmbedding(ctx, text)
+	return s.embedder.GenerateEmbedding(ctx, text, client.InputTypeQuery)
+}
+
+// GenerateDocumentEmbedding generates the embedding of a text that is
+// searched rather than searched with, such as a file path
+func (s *Service) GenerateDocumentEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return s.embedder.GenerateEmbedding(ctx, text, client.InputTypeDocument)
 }
 
-// splitIntoSentences splits text into sentences
+// splitIntoSentences splits text into sentences, each with the whitespace
+// that follows it, so the sentences join back into the text
 func (s *Service) splitIntoSentences(text string) []string {
-	// Simple sentence splitting - can be improved with NLP libraries
 	var sentences []string
-	var current strings.Builder
-
-	for _, char := range text {
-		current.WriteRune(char)
-
-		// Check for sentence endings
-		if char == '.' || char == '!' || char == '?' {
-			// Look ahead to see if it's really the end of a sentence
-			nextChar := ' '
-			if len(text) > current.Len() {
-				nextChar = rune(text[current.Len()])
-			}
+	start := 0
+	for i, char := range text {
+		if char != '.' && char != '!' && char != '?' {
+			continue
+		}
 
-			// If next character is whitespace or end of text, it's likely end of sentence
-			if unicode.IsSpace(nextChar) || current.Len() == len(text) {
-				sentence := strings.TrimSpace(current.String())
-				if sentence != "" {
-					sentences = append(sentences, sentence)
-				}
-				current.Reset()
+		// It's the end of a sentence if whitespace or the end of the text
+		// follows
+		end := i + utf8.RuneLen(char)
+		if next, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && !unicode.IsSpace(next) {
+			continue
+		}
+		for end < len(text) {
+			next, size := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsSpace(next) {
+				break
 			}
+			end += size
 		}
+		sentences = append(sentences, text[start:end])
+		start = end
 	}
 
 	// Add any remaining text
-	remaining := strings.TrimSpace(current.String())
-	if remaining != "" {
-		sentences = append(sentences, remaining)
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
 	}
 
 	return sentences
 }
 
-// getOverlapText gets the last N characters from text for overlap
+// length returns the size of text in the unit of the chunk strategy
+func (s *Service) length(text string) int {
+	if s.config.GetChunkStrategy() == config.ChunkStrategyTokens {
+		return s.getTokenizer().CountTokens(text)
+	}
+	return len(text)
+}
+
+// getTokenizer returns the tokenizer that sizes chunks
+func (s *Service) getTokenizer() client.Tokenizer {
+	if s.tokenizer == nil {
+		return client.ApproximateTokenizer{}
+	}
+	return s.tokenizer
+}
+
+// getOverlapText gets the last N bytes, or tokens with the tokens chunk
+// strategy, from text for overlap
 func (s *Service) getOverlapText(text string, overlapSize int) string {
-	if overlapSize <= 0 || len(text) <= overlapSize {
+	text = strings.TrimRightFunc(text, unicode.IsSpace)
+	if overlapSize <= 0 || s.length(text) <= overlapSize {
 		return ""
 	}
 
 	// Find the last sentence boundary within the overlap
 	overlapText := text[len(text)-overlapSize:]
+	if s.config.GetChunkStrategy() == config.ChunkStrategyTokens {
+		overlapText = tokenTail(s.getTokenizer(), text, overlapSize)
+	}
 
 	// Try to find a sentence boundary
 	for i := 0; i < len(overlapText); i++ {
@@ -166,6 +269,16 @@ func (s *Service) getOverlapText(text string, overlapSize int) string {
 	return strings.TrimSpace(overlapText)
 }
 
+// chunkMetadata copies a file's metadata for one of its chunks, recording how
+// much of the previous chunk the chunk starts with
+func chunkMetadata(metadata map[string]string, index, overlap int) map[string]string {
+	copied := copyMetadata(metadata)
+	if index > 0 {
+		copied[OverlapMetadataKey] = strconv.Itoa(overlap)
+	}
+	return copied
+}
+
 // copyMetadata creates a copy of metadata map
 func copyMetadata(metadata map[string]string) map[string]string {
 	if metadata == nil {
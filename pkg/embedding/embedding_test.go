@@ -0,0 +1,87 @@
+package embedding
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/client/clienttest"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/extract"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateEmbeddingsBatches(t *testing.T) {
+	embedder := &clienttest.Client{}
+	service := New(embedder, &config.EmbeddingConfig{ChunkSize: 100, BatchSize: 2})
+
+	chunks := make([]*Chunk, 5)
+	for i := range chunks {
+		chunks[i] = &Chunk{Content: strings.Repeat("word ", i+1), Index: i}
+	}
+	require.NoError(t, service.GenerateEmbeddings(context.Background(), chunks))
+
+	// Five chunks in batches of two take three requests
+	assert.Equal(t, 3, embedder.CallCount("GenerateEmbeddings"))
+	assert.Equal(t, 3, service.EmbeddingRequests(len(chunks)))
+	for _, chunk := range chunks {
+		assert.Equal(t, clienttest.HashEmbedding(chunk.Content, clienttest.DefaultDimensions), chunk.Embedding)
+	}
+}
+
+func TestGenerateEmbeddingsError(t *testing.T) {
+	embedder := &clienttest.Client{Err: assert.AnError}
+	service := New(embedder, &config.EmbeddingConfig{ChunkSize: 100})
+
+	err := service.GenerateEmbeddings(context.Background(), []*Chunk{{Content: "text"}})
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestChunkSections(t *testing.T) {
+	service := New(&clienttest.Client{}, &config.EmbeddingConfig{ChunkSize: 30, ChunkOverlap: 10})
+
+	sections := []extract.Section{
+		{Text: "First page. It has two sentences.", Metadata: map[string]string{"page": "1"}},
+		{Text: " ", Metadata: map[string]string{"page": "2"}},
+		{Text: "Third page.", Metadata: map[string]string{"page": "3", "heading": "End"}},
+	}
+	chunks, err := service.ChunkSections(sections, map[string]string{"file_name": "report.docx"})
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+
+	// Chunks are numbered across sections and keep their section's location
+	for i, chunk := range chunks {
+		assert.Equal(t, i, chunk.Index)
+		assert.Equal(t, "report.docx", chunk.Metadata["file_name"])
+	}
+	assert.Equal(t, "1", chunks[1].Metadata["page"])
+	assert.Equal(t, "3", chunks[2].Metadata["page"])
+	assert.Equal(t, "End", chunks[2].Metadata["heading"])
+	assert.Empty(t, chunks[1].Metadata["heading"])
+
+	// The first chunk of a section doesn't repeat the previous section
+	assert.Equal(t, "0", chunks[2].Metadata[OverlapMetadataKey])
+	assert.True(t, SameText("First page. It has two sentences. Third page.", StitchChunks(chunks)))
+
+	_, err = service.ChunkSections(sections[1:2], nil)
+	assert.Error(t, err)
+}
+
+func TestEmbeddingInputTypes(t *testing.T) {
+	embedder := &clienttest.Client{}
+	service := New(embedder, &config.EmbeddingConfig{ChunkSize: 100})
+
+	require.NoError(t, service.GenerateEmbeddings(context.Background(), []*Chunk{{Content: "chunk"}}))
+	_, err := service.GenerateEmbeddingForText(context.Background(), "query")
+	require.NoError(t, err)
+	_, err = service.GenerateDocumentEmbedding(context.Background(), "docs/path.md")
+	require.NoError(t, err)
+
+	calls := embedder.Calls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, client.InputTypeDocument, calls[0].InputType)
+	assert.Equal(t, client.InputTypeQuery, calls[1].InputType)
+	assert.Equal(t, client.InputTypeDocument, calls[2].InputType)
+}
@@ -0,0 +1,279 @@
+package embedding
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/extract"
+)
+
+// HeadingPathSeparator joins the headings of a Markdown chunk's heading
+// path, e.g. "Install > Linux"
+const HeadingPathSeparator = " > "
+
+// markdownExtensions are the extensions of Markdown files
+var markdownExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".mdx":      true,
+}
+
+// markdownBlock is a paragraph, list, table or code block of a Markdown
+// section
+type markdownBlock struct {
+	text string
+	keep bool // Code blocks and tables, which are never split
+}
+
+// markdownSection is the text under a heading, up to the next heading
+type markdownSection struct {
+	path   string // Headings the section is under, joined by HeadingPathSeparator
+	blocks []markdownBlock
+}
+
+// markdownHeading is a heading of the current heading path
+type markdownHeading struct {
+	level int
+	title string
+}
+
+// IsMarkdownFile reports whether a file is a Markdown file, by its extension
+func IsMarkdownFile(filePath string) bool {
+	return markdownExtensions[strings.ToLower(path.Ext(filePath))]
+}
+
+// ChunkMarkdown splits Markdown text into chunks at its headings, so no chunk
+// spans two sections. Sections are split between their blocks, and
+// paragraphs larger than a chunk between their sentences; code blocks and
+// tables are kept whole even when they are larger than a chunk. Each chunk
+// has the heading path of its section as its heading metadata, and chunks
+// don't overlap.
+func (s *Service) ChunkMarkdown(text string, metadata map[string]string) ([]*Chunk, error) {
+	var chunks []*Chunk
+	for _, section := range parseMarkdown(text) {
+		sectionMetadata := copyMetadata(metadata)
+		if section.path != "" {
+			sectionMetadata[extract.MetadataHeading] = section.path
+		}
+		for _, content := range s.packMarkdownBlocks(section.blocks) {
+			chunks = append(chunks, &Chunk{
+				Content:  content,
+				Index:    len(chunks),
+				Metadata: chunkMetadata(sectionMetadata, len(chunks), 0),
+			})
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("empty text provided")
+	}
+	return chunks, nil
+}
+
+// packMarkdownBlocks joins the blocks of a section into chunk contents of up
+// to the chunk size
+func (s *Service) packMarkdownBlocks(blocks []markdownBlock) []string {
+	var contents []string
+	var current strings.Builder
+	add := func(piece, separator string) {
+		if current.Len() > 0 && s.length(current.String()+separator+piece) > s.config.ChunkSize {
+			contents = append(contents, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(separator)
+		}
+		current.WriteString(piece)
+	}
+
+	for _, block := range blocks {
+		if block.keep || s.length(block.text) <= s.config.ChunkSize {
+			add(block.text, "\n\n")
+			continue
+		}
+		// Sentences keep the whitespace that follows them
+		for i, sentence := range s.splitIntoSentences(block.text) {
+			separator := ""
+			if i == 0 {
+				separator = "\n\n"
+			}
+			add(sentence, separator)
+		}
+	}
+	if content := strings.TrimSpace(current.String()); content != "" {
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+// parseMarkdown splits Markdown text into its sections and their blocks. A
+// section's heading starts its first block, and sections with nothing but
+// their heading are left out, as their subsections' heading paths name them.
+func parseMarkdown(text string) []markdownSection {
+	var sections []markdownSection
+	var section markdownSection
+	var headings []markdownHeading
+	var lines []string
+	keep := false        // The block has a code block or table
+	headingOnly := false // The block has nothing but the section's heading yet
+	fence := ""          // Marker of the open code block, if any
+
+	flush := func() {
+		block := strings.Trim(strings.Join(lines, "\n"), "\n")
+		if strings.TrimSpace(block) != "" {
+			section.blocks = append(section.blocks, markdownBlock{text: block, keep: keep})
+		}
+		lines, keep, headingOnly = nil, false, false
+	}
+	startSection := func(level int, title string) {
+		if headingOnly {
+			lines = nil
+		}
+		flush()
+		if len(section.blocks) > 0 {
+			sections = append(sections, section)
+		}
+		for len(headings) > 0 && headings[len(headings)-1].level >= level {
+			headings = headings[:len(headings)-1]
+		}
+		headings = append(headings, markdownHeading{level: level, title: title})
+		section = markdownSection{path: headingPath(headings)}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if fence != "" {
+			lines = append(lines, line)
+			if closesFence(line, fence) {
+				fence = ""
+				flush()
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if marker := fenceMarker(line); marker != "" {
+			if !headingOnly {
+				flush()
+			}
+			lines = append(lines, line)
+			fence, keep, headingOnly = marker, true, false
+			continue
+		}
+		if level, title, ok := atxHeading(line); ok {
+			startSection(level, title)
+			lines, headingOnly = []string{line}, true
+			continue
+		}
+		if level := setextLevel(line); level > 0 && len(lines) == 1 && !headingOnly && !keep {
+			heading := lines[0]
+			lines = nil
+			startSection(level, strings.TrimSpace(heading))
+			lines, headingOnly = []string{heading, line}, true
+			continue
+		}
+		if trimmed == "" {
+			if headingOnly {
+				lines = append(lines, line)
+			} else {
+				flush()
+			}
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "|") {
+			keep = true
+		}
+		lines = append(lines, line)
+		headingOnly = false
+	}
+
+	// An unclosed code block runs to the end of the text
+	if !headingOnly {
+		flush()
+	}
+	if len(section.blocks) > 0 {
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// headingPath joins the titles of the headings a section is under
+func headingPath(headings []markdownHeading) string {
+	var titles []string
+	for _, heading := range headings {
+		if heading.title != "" {
+			titles = append(titles, heading.title)
+		}
+	}
+	return strings.Join(titles, HeadingPathSeparator)
+}
+
+// indentation returns line without the up to three spaces Markdown allows
+// before headings and code fences, or false when it is indented more
+func indentation(line string) (string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	return trimmed, len(line)-len(trimmed) <= 3
+}
+
+// atxHeading parses a heading line such as "## Install", returning its level
+// and title
+func atxHeading(line string) (int, string, bool) {
+	line, ok := indentation(line)
+	if !ok {
+		return 0, "", false
+	}
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level < 1 || level > 6 {
+		return 0, "", false
+	}
+	rest := line[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, "", false
+	}
+
+	// Drop the optional closing sequence of #s
+	title := strings.TrimSpace(rest)
+	if closing := strings.TrimRight(title, "#"); closing == "" || strings.HasSuffix(closing, " ") {
+		title = strings.TrimSpace(closing)
+	}
+	return level, title, true
+}
+
+// setextLevel returns the level of the heading a line underlines, 1 for ===
+// and 2 for ---, or 0 when it doesn't underline one
+func setextLevel(line string) int {
+	line, ok := indentation(line)
+	line = strings.TrimRight(line, " \t")
+	if !ok || len(line) < 3 {
+		return 0
+	}
+	switch {
+	case strings.Trim(line, "=") == "":
+		return 1
+	case strings.Trim(line, "-") == "":
+		return 2
+	}
+	return 0
+}
+
+// fenceMarker returns the marker opening a code block, such as ``` or ~~~~,
+// or an empty string when line doesn't open one
+func fenceMarker(line string) string {
+	line, ok := indentation(line)
+	if !ok || len(line) < 3 || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	marker := line[:len(line)-len(strings.TrimLeft(line, line[:1]))]
+	if len(marker) < 3 || (marker[0] == '`' && strings.Contains(line[len(marker):], "`")) {
+		return ""
+	}
+	return marker
+}
+
+// closesFence reports whether line closes the code block opened by marker
+func closesFence(line, marker string) bool {
+	line, ok := indentation(line)
+	line = strings.TrimRight(line, " \t")
+	return ok && len(line) >= len(marker) && strings.Trim(line, marker[:1]) == ""
+}
@@ -0,0 +1,110 @@
+package embedding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/extract"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const markdownGuide = "Intro text.\n" +
+	"\n" +
+	"# Install\n" +
+	"\n" +
+	"## Linux\n" +
+	"\n" +
+	"Run the installer.\n" +
+	"\n" +
+	"```sh\n" +
+	"# Not a heading\n" +
+	"\n" +
+	"./install.sh --prefix /usr/local\n" +
+	"```\n" +
+	"\n" +
+	"| Distro | Package |\n" +
+	"|--------|---------|\n" +
+	"| Debian | deb |\n" +
+	"\n" +
+	"macOS\n" +
+	"-----\n" +
+	"Use Homebrew. It keeps the tools up to date. Run brew upgrade to update them.\n"
+
+func TestChunkMarkdown(t *testing.T) {
+	service := New(nil, &config.EmbeddingConfig{ChunkSize: 40, ChunkOverlap: 10})
+	chunks, err := service.ChunkMarkdown(markdownGuide, map[string]string{"file": "guide.md"})
+	require.NoError(t, err)
+
+	var contents, headings []string
+	for i, chunk := range chunks {
+		assert.Equal(t, i, chunk.Index)
+		assert.Equal(t, "guide.md", chunk.Metadata["file"])
+		contents = append(contents, chunk.Content)
+		headings = append(headings, chunk.Metadata[extract.MetadataHeading])
+	}
+
+	// The empty Install section is left out, the code block and table are
+	// kept whole although they are larger than a chunk, and the long
+	// paragraph is split between its sentences
+	assert.Equal(t, []string{
+		"Intro text.",
+		"## Linux\n\nRun the installer.",
+		"```sh\n# Not a heading\n\n./install.sh --prefix /usr/local\n```",
+		"| Distro | Package |\n|--------|---------|\n| Debian | deb |",
+		"macOS\n-----\nUse Homebrew.",
+		"It keeps the tools up to date.",
+		"Run brew upgrade to update them.",
+	}, contents)
+	assert.Equal(t, []string{"", "Install > Linux", "Install > Linux", "Install > Linux", "Install > macOS", "Install > macOS", "Install > macOS"}, headings)
+
+	// Chunks don't overlap
+	for _, chunk := range chunks[1:] {
+		assert.Equal(t, "0", chunk.Metadata[OverlapMetadataKey])
+	}
+}
+
+func TestChunkMarkdownPacksBlocks(t *testing.T) {
+	service := New(nil, &config.EmbeddingConfig{ChunkSize: 1000})
+	chunks, err := service.ChunkMarkdown(markdownGuide, nil)
+	require.NoError(t, err)
+
+	// Blocks of a section share chunks, but sections don't
+	require.Len(t, chunks, 3)
+	assert.True(t, strings.HasPrefix(chunks[1].Content, "## Linux"))
+	assert.True(t, strings.HasSuffix(chunks[1].Content, "| Debian | deb |"))
+	assert.Equal(t, "Install > macOS", chunks[2].Metadata[extract.MetadataHeading])
+
+	_, err = service.ChunkMarkdown("# Empty\n\n## Sections\n", nil)
+	assert.Error(t, err)
+}
+
+func TestAtxHeading(t *testing.T) {
+	cases := []struct {
+		line  string
+		level int
+		title string
+		ok    bool
+	}{
+		{"# Install", 1, "Install", true},
+		{"### Setup ###", 3, "Setup", true},
+		{"## C#", 2, "C#", true},
+		{"   ## Indented", 2, "Indented", true},
+		{"    # Code", 0, "", false},
+		{"#hashtag", 0, "", false},
+		{"####### Seven", 0, "", false},
+	}
+	for _, c := range cases {
+		level, title, ok := atxHeading(c.line)
+		assert.Equal(t, c.ok, ok, c.line)
+		assert.Equal(t, c.level, level, c.line)
+		assert.Equal(t, c.title, title, c.line)
+	}
+}
+
+func TestIsMarkdownFile(t *testing.T) {
+	assert.True(t, IsMarkdownFile("docs/README.md"))
+	assert.True(t, IsMarkdownFile("guide.Markdown"))
+	assert.False(t, IsMarkdownFile("notes.txt"))
+}
@@ -0,0 +1,114 @@
+package embedding
+
+import (
+	"strings"
+)
+
+// recursiveChunk is a chunk of the recursive chunk strategy before it is
+// trimmed, with the end of the previous chunk it starts with
+type recursiveChunk struct {
+	text    string
+	overlap string
+}
+
+// chunkRecursive splits text with the recursive chunk strategy: at the first
+// of embedding.separators the text contains, splitting the pieces still
+// larger than a chunk at the next separators in turn, and joining the
+// pieces of each split into chunks of up to the chunk size
+func (s *Service) chunkRecursive(text string, metadata map[string]string) []*Chunk {
+	var chunks []*Chunk
+	for _, raw := range s.splitRecursive(text, s.config.GetSeparators()) {
+		content := strings.TrimSpace(raw.text)
+		if content == "" {
+			continue
+		}
+		chunks = append(chunks, &Chunk{
+			Content:  content,
+			Index:    len(chunks),
+			Metadata: chunkMetadata(metadata, len(chunks), len(strings.TrimSpace(raw.overlap))),
+		})
+	}
+	return chunks
+}
+
+// splitRecursive splits text at the first of the separators it contains,
+// and the pieces larger than a chunk at the separators after it. An empty
+// separator splits between characters; text without any of the separators
+// is a chunk of its own.
+func (s *Service) splitRecursive(text string, separators []string) []recursiveChunk {
+	found := false
+	var separator string
+	var rest []string
+	for i, sep := range separators {
+		if sep == "" || strings.Contains(text, sep) {
+			found, separator, rest = true, sep, separators[i+1:]
+			break
+		}
+	}
+	if !found {
+		return []recursiveChunk{{text: text}}
+	}
+
+	var chunks []recursiveChunk
+	var small []string
+	for _, piece := range splitAfterSeparator(text, separator) {
+		if s.length(piece) <= s.config.ChunkSize {
+			small = append(small, piece)
+			continue
+		}
+		chunks = append(chunks, s.mergePieces(small)...)
+		small = nil
+		chunks = append(chunks, s.splitRecursive(piece, rest)...)
+	}
+	return append(chunks, s.mergePieces(small)...)
+}
+
+// mergePieces joins pieces into chunks of up to the chunk size. Each chunk
+// after the first starts with the last pieces of the previous one that fit
+// in the chunk overlap.
+func (s *Service) mergePieces(pieces []string) []recursiveChunk {
+	var chunks []recursiveChunk
+	var current []string
+	currentLength := 0
+	overlap := ""
+	for _, piece := range pieces {
+		pieceLength := s.length(piece)
+		if len(current) > 0 && currentLength+pieceLength > s.config.ChunkSize {
+			chunks = append(chunks, recursiveChunk{text: strings.Join(current, ""), overlap: overlap})
+
+			// Keep the end of the chunk that fits in the overlap, and leaves
+			// room for the piece
+			keep, kept := len(current), 0
+			for keep > 0 {
+				length := kept + s.length(current[keep-1])
+				if length > s.config.ChunkOverlap || length+pieceLength > s.config.ChunkSize {
+					break
+				}
+				kept = length
+				keep--
+			}
+			current = current[keep:]
+			overlap = strings.Join(current, "")
+			currentLength = kept
+		}
+		current = append(current, piece)
+		currentLength += pieceLength
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, recursiveChunk{text: strings.Join(current, ""), overlap: overlap})
+	}
+	return chunks
+}
+
+// splitAfterSeparator splits text after each separator, so the pieces join
+// back into the text, or into its characters when the separator is empty
+func splitAfterSeparator(text, separator string) []string {
+	if separator == "" {
+		return strings.Split(text, "")
+	}
+	pieces := strings.SplitAfter(text, separator)
+	if pieces[len(pieces)-1] == "" {
+		pieces = pieces[:len(pieces)-1]
+	}
+	return pieces
+}
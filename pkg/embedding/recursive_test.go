@@ -0,0 +1,69 @@
+package embedding
+
+import (
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkRecursive(t *testing.T) {
+	service := New(nil, &config.EmbeddingConfig{ChunkStrategy: config.ChunkStrategyRecursive, ChunkSize: 30, ChunkOverlap: 10})
+	text := "First paragraph.\n\nline one\nline two\n\nA long sentence that runs on. And then it stops."
+
+	chunks, err := service.ChunkText(text, map[string]string{"source": "notes.txt"})
+	require.NoError(t, err)
+
+	var contents []string
+	for _, chunk := range chunks {
+		contents = append(contents, chunk.Content)
+	}
+	assert.Equal(t, []string{
+		"First paragraph.",
+		"line one\nline two",
+		"A long sentence that runs on.",
+		"And then it stops.",
+	}, contents)
+	assert.Equal(t, "notes.txt", chunks[1].Metadata["source"])
+	assert.Equal(t, "0", chunks[1].Metadata[OverlapMetadataKey])
+	assert.True(t, SameText(text, StitchChunks(chunks)))
+}
+
+func TestChunkRecursiveOverlap(t *testing.T) {
+	service := New(nil, &config.EmbeddingConfig{
+		ChunkStrategy: config.ChunkStrategyRecursive,
+		ChunkSize:     12,
+		ChunkOverlap:  6,
+		Separators:    []string{",", ""},
+	})
+	text := "alpha,beta,gamma,delta,epsilon"
+
+	chunks, err := service.ChunkText(text, nil)
+	require.NoError(t, err)
+
+	var contents []string
+	for _, chunk := range chunks {
+		contents = append(contents, chunk.Content)
+	}
+	assert.Equal(t, []string{"alpha,beta,", "beta,gamma,", "gamma,delta,", "epsilon"}, contents)
+	assert.Equal(t, "5", chunks[1].Metadata[OverlapMetadataKey])
+	assert.Equal(t, text, StitchChunks(chunks))
+}
+
+func TestChunkRecursiveCharacters(t *testing.T) {
+	service := New(nil, &config.EmbeddingConfig{
+		ChunkStrategy: config.ChunkStrategyRecursive,
+		ChunkSize:     4,
+		Separators:    []string{"\n", ""},
+	})
+
+	chunks, err := service.ChunkText("abcdefghij\nkl", nil)
+	require.NoError(t, err)
+
+	var contents []string
+	for _, chunk := range chunks {
+		contents = append(contents, chunk.Content)
+	}
+	assert.Equal(t, []string{"abcd", "efgh", "ij", "kl"}, contents)
+}
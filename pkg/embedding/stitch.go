@@ -0,0 +1,72 @@
+package embedding
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// OverlapMetadataKey is the chunk metadata key holding the length in bytes of
+// the previous chunk's end that a chunk starts with
+const OverlapMetadataKey = "overlap"
+
+// StitchChunks joins the chunks of a file, sorted by index, back into the
+// text they were split from, dropping the overlap each chunk repeats from the
+// previous one. The overlap recorded in a chunk's metadata is used when it
+// matches the end of the previous chunk; chunks indexed before it was
+// recorded use the longest end of the previous chunk they start with.
+// Chunks after a missing index are joined with a blank line, since their
+// overlap is with the missing chunk.
+func StitchChunks(chunks []*Chunk) string {
+	var b strings.Builder
+	for i, chunk := range chunks {
+		if i == 0 {
+			b.WriteString(chunk.Content)
+			continue
+		}
+
+		previous := chunks[i-1]
+		if chunk.Index != previous.Index+1 {
+			b.WriteString("\n\n")
+			b.WriteString(chunk.Content)
+			continue
+		}
+
+		b.WriteString(chunk.Content[chunkOverlap(previous.Content, chunk):])
+	}
+	return b.String()
+}
+
+// chunkOverlap returns the length of the start of a chunk that repeats the
+// end of the previous chunk's content
+func chunkOverlap(previous string, chunk *Chunk) int {
+	if value, ok := chunk.Metadata[OverlapMetadataKey]; ok {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 && n <= len(chunk.Content) &&
+			strings.HasSuffix(previous, chunk.Content[:n]) {
+			return n
+		}
+	}
+
+	for n := min(len(previous), len(chunk.Content)); n > 0; n-- {
+		if strings.HasSuffix(previous, chunk.Content[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// SameText reports whether two texts are the same apart from whitespace,
+// which chunking doesn't keep between sentences
+func SameText(a, b string) bool {
+	return withoutSpace(a) == withoutSpace(b)
+}
+
+// withoutSpace removes all whitespace from a text
+func withoutSpace(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
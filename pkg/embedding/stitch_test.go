@@ -0,0 +1,45 @@
+package embedding
+
+import (
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStitchChunksRebuildsChunkedText(t *testing.T) {
+	service := New(nil, &config.EmbeddingConfig{ChunkSize: 40, ChunkOverlap: 20})
+	text := "The first sentence is here. A second one follows it. Then comes a third. And a fourth ends it."
+
+	chunks, err := service.ChunkText(text, nil)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 1)
+	// Chunking drops some of the whitespace between sentences
+	assert.True(t, SameText(text, StitchChunks(chunks)))
+	assert.Equal(t, "20", chunks[1].Metadata[OverlapMetadataKey])
+}
+
+func TestSameText(t *testing.T) {
+	assert.True(t, SameText("One. Two.\n", "One.Two."))
+	assert.False(t, SameText("One. Two.", "One. Tw o!"))
+}
+
+func TestStitchChunksWithoutRecordedOverlap(t *testing.T) {
+	chunks := []*Chunk{
+		{Content: "Alpha beta. Gamma delta.", Index: 0},
+		{Content: "Gamma delta. Epsilon.", Index: 1},
+		{Content: "Omega.", Index: 3},
+	}
+
+	assert.Equal(t, "Alpha beta. Gamma delta. Epsilon.\n\nOmega.", StitchChunks(chunks))
+}
+
+func TestStitchChunksIgnoresWrongRecordedOverlap(t *testing.T) {
+	chunks := []*Chunk{
+		{Content: "One. Two.", Index: 0},
+		{Content: "Two. Three.", Index: 1, Metadata: map[string]string{OverlapMetadataKey: "8"}},
+	}
+
+	assert.Equal(t, "One. Two. Three.", StitchChunks(chunks))
+}
@@ -0,0 +1,25 @@
+package embedding
+
+import (
+	"sort"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+)
+
+// tokenTail returns the longest end of text with at most the given number
+// of tokens, starting on a character
+func tokenTail(tokenizer client.Tokenizer, text string, tokens int) string {
+	var starts []int
+	for i := range text {
+		starts = append(starts, i)
+	}
+
+	// The later a tail starts, the fewer tokens it has
+	first := sort.Search(len(starts), func(i int) bool {
+		return tokenizer.CountTokens(text[starts[i]:]) <= tokens
+	})
+	if first == len(starts) {
+		return ""
+	}
+	return text[starts[first]:]
+}
@@ -0,0 +1,47 @@
+package embedding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wordTokenizer counts every word as a token
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestTokenTail(t *testing.T) {
+	assert.Equal(t, "three four", strings.TrimSpace(tokenTail(wordTokenizer{}, "one two three four", 2)))
+	assert.Equal(t, "one two", tokenTail(wordTokenizer{}, "one two", 5))
+	assert.Equal(t, "", tokenTail(wordTokenizer{}, "one", 0))
+}
+
+func TestChunkTextTokens(t *testing.T) {
+	service := New(nil, &config.EmbeddingConfig{ChunkStrategy: config.ChunkStrategyTokens, ChunkSize: 6, ChunkOverlap: 3})
+	service.SetTokenizer(wordTokenizer{})
+
+	text := "One two three four. Five six seven. Eight nine ten eleven. Twelve."
+	chunks, err := service.ChunkText(text, nil)
+	require.NoError(t, err)
+
+	// Chunks hold whole sentences up to six words after the last three
+	// words of the previous chunk, or its last sentence that fits in them
+	var contents []string
+	for _, chunk := range chunks {
+		contents = append(contents, chunk.Content)
+	}
+	assert.Equal(t, []string{
+		"One two three four.",
+		"two three four. Five six seven.",
+		"Five six seven. Eight nine ten eleven.",
+		"nine ten eleven. Twelve.",
+	}, contents)
+	assert.Equal(t, "15", chunks[1].Metadata[OverlapMetadataKey], "the overlap is recorded in bytes")
+	assert.Equal(t, text, StitchChunks(chunks))
+}
@@ -0,0 +1,211 @@
+package expansion
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// maxSynonymVariants limits how many synonym rewrites are generated per query
+const maxSynonymVariants = 5
+
+// paraphrasePrompt asks the chat model for paraphrases of a search query
+const paraphrasePrompt = `Write %d different paraphrases of the search query below.
+Spell out acronyms and use alternative terminology where it helps find relevant documents.
+Reply with one paraphrase per line and nothing else.
+
+Query: %s`
+
+// rewritePrompt asks the chat model for search queries that retrieve the
+// documents a query is looking for
+const rewritePrompt = `Rewrite the question below into %d search queries that together find the documents answering it.
+Make each query short and specific: use the keywords, names and technical terms the documents likely contain, and cover a different aspect of the question in each.
+Reply with one query per line and nothing else.
+
+Question: %s`
+
+// listMarkerPattern matches bullets and numbering at the start of a line
+var listMarkerPattern = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s*`)
+
+// Service expands search queries into variants that are searched alongside them
+type Service struct {
+	chat   client.Client
+	config *config.ExpansionConfig
+	groups [][]string
+}
+
+// New creates a new query expansion service. The chat client is only used
+// for paraphrases and may be nil when they are disabled.
+func New(chat client.Client, config *config.ExpansionConfig) *Service {
+	// Each term and its synonyms form a group of interchangeable terms.
+	// Terms are sorted so expansion doesn't depend on map order.
+	terms := make([]string, 0, len(config.Synonyms))
+	for term := range config.Synonyms {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	groups := make([][]string, 0, len(terms))
+	for _, term := range terms {
+		group := []string{term}
+		group = append(group, config.Synonyms[term]...)
+		groups = append(groups, group)
+	}
+
+	return &Service{
+		chat:   chat,
+		config: config,
+		groups: groups,
+	}
+}
+
+// Expand returns the variants of a query: synonym rewrites followed by LLM
+// paraphrases, and search queries rewritten by the LLM when rewriting is
+// enabled. The original query is not included.
+func (s *Service) Expand(ctx context.Context, query string) ([]string, error) {
+	variants := s.SynonymVariants(query)
+
+	if s.config.Paraphrases > 0 && s.chat != nil {
+		paraphrases, err := s.Paraphrases(ctx, query)
+		if err != nil {
+			return dedupe(query, variants), err
+		}
+		variants = append(variants, paraphrases...)
+	}
+
+	if s.config.Rewrite && s.chat != nil {
+		rewrites, err := s.Rewrites(ctx, query)
+		if err != nil {
+			return dedupe(query, variants), err
+		}
+		variants = append(variants, rewrites...)
+	}
+
+	return dedupe(query, variants), nil
+}
+
+// Expands reports whether queries are expanded with synonyms and paraphrases
+func (s *Service) Expands() bool {
+	return s.config.Enabled
+}
+
+// RewritesQueries reports whether the chat model rewrites queries into
+// search queries
+func (s *Service) RewritesQueries() bool {
+	return s.config.Rewrite && s.chat != nil
+}
+
+// SynonymVariants rewrites the query by replacing each term that has
+// synonyms with each of its synonyms
+func (s *Service) SynonymVariants(query string) []string {
+	var variants []string
+	for _, group := range s.groups {
+		for _, term := range group {
+			pattern := termPattern(term)
+			if !pattern.MatchString(query) {
+				continue
+			}
+			for _, alternative := range group {
+				if strings.EqualFold(alternative, term) {
+					continue
+				}
+				variant := pattern.ReplaceAllString(query, "${1}"+escapeReplacement(alternative)+"${2}")
+				variants = append(variants, variant)
+				if len(variants) >= maxSynonymVariants {
+					return variants
+				}
+			}
+		}
+	}
+	return variants
+}
+
+// Paraphrases asks the chat model for paraphrases of the query
+func (s *Service) Paraphrases(ctx context.Context, query string) ([]string, error) {
+	messages := []client.Message{
+		{Role: "system", Content: "You rewrite search queries to improve document retrieval."},
+		{Role: "user", Content: fmt.Sprintf(paraphrasePrompt, s.config.Paraphrases, query)},
+	}
+
+	response, err := s.chat.Chat(ctx, s.config.Model, messages, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate paraphrases: %w", err)
+	}
+
+	return parseParaphrases(response.Message.Content, s.config.Paraphrases), nil
+}
+
+// Rewrites asks the chat model to rewrite the query into search queries,
+// such as the keywords a question's answer contains, for multi-query
+// retrieval
+func (s *Service) Rewrites(ctx context.Context, query string) ([]string, error) {
+	messages := []client.Message{
+		{Role: "system", Content: "You rewrite questions into search queries to improve document retrieval."},
+		{Role: "user", Content: fmt.Sprintf(rewritePrompt, s.config.GetRewrites(), query)},
+	}
+
+	response, err := s.chat.Chat(ctx, s.config.Model, messages, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewrite query: %w", err)
+	}
+
+	return parseParaphrases(response.Message.Content, s.config.GetRewrites()), nil
+}
+
+// parseParaphrases extracts up to n paraphrases from a model response
+func parseParaphrases(text string, n int) []string {
+	text = client.StripThinking(text)
+
+	var paraphrases []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(listMarkerPattern.ReplaceAllString(strings.TrimSpace(line), ""))
+		line = strings.Trim(line, `"'`)
+		if line == "" {
+			continue
+		}
+		paraphrases = append(paraphrases, line)
+		if len(paraphrases) == n {
+			break
+		}
+	}
+	return paraphrases
+}
+
+// termPattern matches a term as a whole word, case-insensitively. The
+// characters around the term are captured so they can be kept.
+func termPattern(term string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[^\p{L}\p{N}])` + regexp.QuoteMeta(term) + `([^\p{L}\p{N}]|$)`)
+}
+
+// escapeReplacement escapes $ in a regexp replacement string
+func escapeReplacement(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+// dedupe removes variants that only differ from the query or from each
+// other in case and spacing
+func dedupe(query string, variants []string) []string {
+	seen := map[string]bool{normalize(query): true}
+
+	var unique []string
+	for _, variant := range variants {
+		key := normalize(variant)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, variant)
+	}
+	return unique
+}
+
+// normalize lowercases text and collapses whitespace
+func normalize(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), unicode.IsSpace), " ")
+}
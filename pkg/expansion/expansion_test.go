@@ -0,0 +1,75 @@
+package expansion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockChat returns a fixed chat response
+type mockChat struct {
+	client.Client
+	response string
+	messages []client.Message
+}
+
+func (m *mockChat) Chat(ctx context.Context, model string, messages []client.Message, stream bool) (*client.ChatResponse, error) {
+	m.messages = messages
+	return &client.ChatResponse{Message: client.Message{Role: "assistant", Content: m.response}}, nil
+}
+
+func TestSynonymVariants(t *testing.T) {
+	service := New(nil, &config.ExpansionConfig{
+		Synonyms: map[string][]string{
+			"k8s": {"kubernetes"},
+			"db":  {"database", "postgres"},
+		},
+	})
+
+	assert.Equal(t, []string{"database connection limits", "postgres connection limits"}, service.SynonymVariants("DB connection limits"))
+	assert.Equal(t, []string{"deploy k8s pods"}, service.SynonymVariants("deploy Kubernetes pods"))
+
+	// Terms only match whole words
+	assert.Empty(t, service.SynonymVariants("dbus messages"))
+}
+
+func TestExpandWithParaphrases(t *testing.T) {
+	chat := &mockChat{response: "<think>\nacronyms...\n</think>\n1. kubernetes pod restarts\n2. \"why do k8s pods restart\"\n- K8S POD RESTARTS\n3. container restart loop\n"}
+	service := New(chat, &config.ExpansionConfig{
+		Paraphrases: 3,
+		Synonyms:    map[string][]string{"k8s": {"kubernetes"}},
+	})
+
+	variants, err := service.Expand(context.Background(), "k8s pod restarts")
+	require.NoError(t, err)
+
+	// The synonym rewrite and the paraphrase that repeats it are deduplicated,
+	// as is the paraphrase that only differs from the query in case
+	assert.Equal(t, []string{"kubernetes pod restarts", "why do k8s pods restart"}, variants)
+	require.Len(t, chat.messages, 2)
+	assert.Contains(t, chat.messages[1].Content, "Write 3 different paraphrases")
+}
+
+func TestExpandWithRewrites(t *testing.T) {
+	chat := &mockChat{response: "1. pod CrashLoopBackOff after rollout\n2. kubernetes deployment restart policy\n3. Why do my pods keep restarting after deploys?\n4. extra"}
+	service := New(chat, &config.ExpansionConfig{Rewrite: true, Rewrites: 3})
+	assert.True(t, service.RewritesQueries())
+	assert.False(t, service.Expands())
+
+	variants, err := service.Expand(context.Background(), "why do my pods keep restarting after deploys?")
+	require.NoError(t, err)
+
+	// The rewrite that repeats the query is left out
+	assert.Equal(t, []string{"pod CrashLoopBackOff after rollout", "kubernetes deployment restart policy"}, variants)
+	require.Len(t, chat.messages, 2)
+	assert.Contains(t, chat.messages[1].Content, "into 3 search queries")
+}
+
+func TestParseParaphrases(t *testing.T) {
+	text := "Here you go\n\n* first\n2) second\n'third'\nfourth"
+	assert.Equal(t, []string{"Here you go", "first", "second"}, parseParaphrases(text, 3))
+}
@@ -0,0 +1,92 @@
+// Package export writes the embeddings of a collection to files that tools
+// outside rag-cli read, such as pandas, Polars, DuckDB or NumPy, so they can
+// be clustered or used to train rerankers without access to the database.
+// It also reads embeddings computed outside rag-cli from such files, so they
+// can be imported into a collection.
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+const (
+	// FormatParquet writes one Parquet file with a row per chunk
+	FormatParquet = "parquet"
+	// FormatNumPy writes the embeddings as a NumPy array in an .npy file and
+	// what identifies their chunks as JSON lines in a .jsonl file next to it
+	FormatNumPy = "npy"
+)
+
+// Formats are the formats embeddings can be exported in
+var Formats = []string{FormatParquet, FormatNumPy}
+
+// Writer writes exported embeddings. Close must be called to complete the
+// files.
+type Writer interface {
+	Write(record *database.EmbeddingRecord) error
+	Close() error
+}
+
+// Create creates the files of an export in the given format at path, and
+// returns a writer for them along with the paths of the files
+func Create(format, path string) (Writer, []string, error) {
+	switch format {
+	case FormatParquet:
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create export file: %w", err)
+		}
+		return &fileWriter{Writer: NewParquetWriter(file), files: []*os.File{file}}, []string{path}, nil
+	case FormatNumPy:
+		recordsPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".jsonl"
+		if recordsPath == path {
+			return nil, nil, fmt.Errorf("export file %s can't end with .jsonl", path)
+		}
+		vectors, err := os.Create(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create export file: %w", err)
+		}
+		records, err := os.Create(recordsPath)
+		if err != nil {
+			vectors.Close()
+			return nil, nil, fmt.Errorf("failed to create export file: %w", err)
+		}
+		writer, err := NewNumPyWriter(vectors, records)
+		if err != nil {
+			vectors.Close()
+			records.Close()
+			return nil, nil, err
+		}
+		return &fileWriter{Writer: writer, files: []*os.File{vectors, records}}, []string{path, recordsPath}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown export format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// DefaultPath returns the file an export in the given format is written to
+// when no path is given
+func DefaultPath(collection, format string) string {
+	return collection + "-embeddings." + format
+}
+
+// fileWriter closes the files of an export after completing them
+type fileWriter struct {
+	Writer
+	files []*os.File
+}
+
+// Close completes the export and closes its files
+func (w *fileWriter) Close() error {
+	err := w.Writer.Close()
+	for _, file := range w.files {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close export file: %w", closeErr)
+		}
+	}
+	return err
+}
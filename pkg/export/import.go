@@ -0,0 +1,119 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImportRecord is a chunk with an embedding computed outside rag-cli, read
+// from a file to import
+type ImportRecord struct {
+	Row        int // Row of the chunk in the file, from 1
+	FilePath   string
+	Content    string
+	ChunkIndex int    // -1 when the file doesn't number the chunks of files
+	Metadata   string // JSON object, empty when the file has no metadata
+	Model      string // Model the file says computed the embedding, if any
+	Embedding  []float32
+}
+
+// importColumns maps the names of the columns of files to import, and the
+// keys of their JSON records, to what they hold. Other columns are ignored,
+// so an export with a content column added can be imported.
+var importColumns = map[string]string{
+	"file_path":   "file_path",
+	"path":        "file_path",
+	"content":     "content",
+	"text":        "content",
+	"chunk_index": "chunk_index",
+	"metadata":    "metadata",
+	"model":       "model",
+	"embedding":   "embedding",
+	"vector":      "embedding",
+}
+
+// Read calls fn with each chunk of a file of embeddings to import: a Parquet
+// file, or an .npy file of a float32 or float64 array with a row per chunk
+// next to a .jsonl file of the same name that holds a JSON object per row.
+// Reading stops at the first error fn returns.
+func Read(path string, fn func(*ImportRecord) error) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".parquet":
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open import file: %w", err)
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to open import file: %w", err)
+		}
+		return ReadParquet(file, info.Size(), fn)
+	case ".npy":
+		vectors, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open import file: %w", err)
+		}
+		defer vectors.Close()
+		records, err := os.Open(strings.TrimSuffix(path, filepath.Ext(path)) + ".jsonl")
+		if err != nil {
+			return fmt.Errorf("failed to open records file: %w", err)
+		}
+		defer records.Close()
+		return ReadNumPy(bufio.NewReader(vectors), records, fn)
+	default:
+		return fmt.Errorf("unknown type of import file %s (expected a .parquet or .npy file)", path)
+	}
+}
+
+// check returns an error for a record without what every chunk needs
+func (record *ImportRecord) check() error {
+	switch {
+	case record.FilePath == "":
+		return fmt.Errorf("row %d has no file_path", record.Row)
+	case record.Content == "":
+		return fmt.Errorf("row %d has no content", record.Row)
+	case len(record.Embedding) == 0:
+		return fmt.Errorf("row %d has no embedding", record.Row)
+	}
+	return nil
+}
+
+// parseImportRecord parses the JSON object of a row of an .npy file
+func parseImportRecord(line []byte, row int) (*ImportRecord, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, fmt.Errorf("row %d of the records file isn't a JSON object: %w", row, err)
+	}
+
+	record := &ImportRecord{Row: row, ChunkIndex: -1}
+	for name, value := range fields {
+		var err error
+		switch importColumns[name] {
+		case "file_path":
+			err = json.Unmarshal(value, &record.FilePath)
+		case "content":
+			err = json.Unmarshal(value, &record.Content)
+		case "model":
+			err = json.Unmarshal(value, &record.Model)
+		case "chunk_index":
+			err = json.Unmarshal(value, &record.ChunkIndex)
+		case "metadata":
+			// Metadata is an object, or a string holding one as in Parquet
+			// files
+			if len(value) > 0 && value[0] == '"' {
+				err = json.Unmarshal(value, &record.Metadata)
+			} else if string(value) != "null" {
+				record.Metadata = string(value)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d has an invalid %s: %w", row, name, err)
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadNumPy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.npy")
+	writer, files, err := Create(FormatNumPy, path)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(&database.EmbeddingRecord{FilePath: "setup.md", Embedding: []float32{0.5, -1}}))
+	require.NoError(t, writer.Write(&database.EmbeddingRecord{FilePath: "setup.md", ChunkIndex: 1, Embedding: []float32{2, 0.25}}))
+	require.NoError(t, writer.Close())
+
+	// The records of the array are replaced with ones that have content
+	records := `{"path":"setup.md","text":"Install it","chunk_index":0,"metadata":{"lang":"en"},"model":"nomic-embed-text"}
+
+{"file_path":"setup.md","content":"Run it","metadata":"{\"lang\":\"de\"}","id":"ignored"}
+`
+	require.NoError(t, os.WriteFile(files[1], []byte(records), 0644))
+
+	var read []*ImportRecord
+	err = Read(path, func(record *ImportRecord) error {
+		read = append(read, record)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*ImportRecord{
+		{Row: 1, FilePath: "setup.md", Content: "Install it", ChunkIndex: 0, Metadata: `{"lang":"en"}`, Model: "nomic-embed-text", Embedding: []float32{0.5, -1}},
+		{Row: 2, FilePath: "setup.md", Content: "Run it", ChunkIndex: -1, Metadata: `{"lang":"de"}`, Embedding: []float32{2, 0.25}},
+	}, read)
+
+	// Every row needs a line of JSON with content
+	require.NoError(t, os.WriteFile(files[1], []byte(`{"file_path":"setup.md","content":"Install it"}`), 0644))
+	err = Read(path, func(*ImportRecord) error { return nil })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 lines for 2 rows")
+
+	require.NoError(t, os.WriteFile(files[1], []byte("{\"file_path\":\"setup.md\"}\n{}\n"), 0644))
+	err = Read(path, func(*ImportRecord) error { return nil })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "row 1 has no content")
+}
+
+func TestReadNumPyFloat64(t *testing.T) {
+	vectors := []byte("\x93NUMPY\x02\x00")
+	header := "{'descr': '<f8', 'fortran_order': False, 'shape': (1, 3), }\n"
+	vectors = binary.LittleEndian.AppendUint32(vectors, uint32(len(header)))
+	vectors = append(vectors, header...)
+	for _, value := range []float64{0.25, -2, 8} {
+		vectors = binary.LittleEndian.AppendUint64(vectors, math.Float64bits(value))
+	}
+
+	var read []*ImportRecord
+	err := ReadNumPy(bytes.NewReader(vectors), strings.NewReader(`{"file_path":"a.md","content":"a"}`), func(record *ImportRecord) error {
+		read = append(read, record)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, read, 1)
+	assert.Equal(t, []float32{0.25, -2, 8}, read[0].Embedding)
+
+	fortran := bytes.Replace(vectors, []byte("False"), []byte("True "), 1)
+	assert.Error(t, ReadNumPy(bytes.NewReader(fortran), strings.NewReader(`{}`), func(*ImportRecord) error { return nil }))
+	integers := bytes.Replace(vectors, []byte("<f8"), []byte("<i8"), 1)
+	assert.Error(t, ReadNumPy(bytes.NewReader(integers), strings.NewReader(`{}`), func(*ImportRecord) error { return nil }))
+}
+
+func TestReadUnknownType(t *testing.T) {
+	assert.Error(t, Read(filepath.Join(t.TempDir(), "vectors.csv"), func(*ImportRecord) error { return nil }))
+}
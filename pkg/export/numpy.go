@@ -0,0 +1,239 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+// npyHeaderSize is the size of the .npy header, which is written again once
+// the number of rows is known. It holds the largest possible shape and is a
+// multiple of 64, as the format recommends.
+const npyHeaderSize = 128
+
+// NumPyWriter writes embeddings as a float32 array of one row per chunk in
+// the .npy format, which numpy.load reads, and what identifies the chunk of
+// each row as a line of JSON to a second file
+type NumPyWriter struct {
+	vectors    io.WriteSeeker
+	buffered   *bufio.Writer
+	records    *json.Encoder
+	dimensions int
+	rows       int64
+}
+
+// npyRecord is the line of JSON written for each row of the array
+type npyRecord struct {
+	ID         string          `json:"id"`
+	Folder     string          `json:"folder"`
+	FilePath   string          `json:"file_path"`
+	ChunkIndex int             `json:"chunk_index"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// NewNumPyWriter creates a writer of an .npy file to vectors and of JSON
+// lines to records
+func NewNumPyWriter(vectors io.WriteSeeker, records io.Writer) (*NumPyWriter, error) {
+	// The header is written once the shape is known
+	if _, err := vectors.Write(make([]byte, npyHeaderSize)); err != nil {
+		return nil, fmt.Errorf("failed to write NumPy file: %w", err)
+	}
+	return &NumPyWriter{
+		vectors:  vectors,
+		buffered: bufio.NewWriter(vectors),
+		records:  json.NewEncoder(records),
+	}, nil
+}
+
+// Write adds the row of a chunk's embedding. All embeddings must have the
+// same number of dimensions.
+func (nw *NumPyWriter) Write(record *database.EmbeddingRecord) error {
+	if len(record.Embedding) == 0 {
+		return fmt.Errorf("chunk %s has no embedding", record.ID)
+	}
+	if nw.dimensions == 0 {
+		nw.dimensions = len(record.Embedding)
+	}
+	if len(record.Embedding) != nw.dimensions {
+		return fmt.Errorf("embedding of chunk %s has %d dimensions, expected %d", record.ID, len(record.Embedding), nw.dimensions)
+	}
+
+	var row []byte
+	for _, value := range record.Embedding {
+		row = binary.LittleEndian.AppendUint32(row, math.Float32bits(value))
+	}
+	if _, err := nw.buffered.Write(row); err != nil {
+		return fmt.Errorf("failed to write NumPy file: %w", err)
+	}
+
+	metadata := json.RawMessage(record.Metadata)
+	if !json.Valid(metadata) {
+		metadata = json.RawMessage("{}")
+	}
+	err := nw.records.Encode(npyRecord{
+		ID:         record.ID,
+		Folder:     record.Folder,
+		FilePath:   record.FilePath,
+		ChunkIndex: record.ChunkIndex,
+		Metadata:   metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write records file: %w", err)
+	}
+
+	nw.rows++
+	return nil
+}
+
+// Close writes the header with the shape of the array
+func (nw *NumPyWriter) Close() error {
+	if err := nw.buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write NumPy file: %w", err)
+	}
+	if _, err := nw.vectors.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to write NumPy file: %w", err)
+	}
+	if _, err := nw.vectors.Write(npyHeader(nw.rows, nw.dimensions)); err != nil {
+		return fmt.Errorf("failed to write NumPy file: %w", err)
+	}
+	return nil
+}
+
+// npyHeader encodes the version 1.0 header of a little-endian float32 array
+// of the given shape, padded with spaces to npyHeaderSize
+func npyHeader(rows int64, dimensions int) []byte {
+	header := []byte("\x93NUMPY\x01\x00")
+	header = binary.LittleEndian.AppendUint16(header, npyHeaderSize-10)
+	header = fmt.Appendf(header, "{'descr': '<f4', 'fortran_order': False, 'shape': (%d, %d), }", rows, dimensions)
+	for len(header) < npyHeaderSize-1 {
+		header = append(header, ' ')
+	}
+	return append(header, '\n')
+}
+
+// Patterns of the entries of .npy headers, which are Python dict literals
+var (
+	npyDescrPattern   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	npyFortranPattern = regexp.MustCompile(`'fortran_order':\s*True`)
+	npyShapePattern   = regexp.MustCompile(`'shape':\s*\(\s*(\d+)\s*,\s*(\d+)\s*,?\s*\)`)
+)
+
+// ReadNumPy calls fn with each row of a two-dimensional .npy array of
+// little-endian float32 or float64 embeddings, along with the JSON object
+// of the row in records, which holds a line per row. Blank lines are
+// skipped. Reading stops at the first error fn returns.
+func ReadNumPy(vectors io.Reader, records io.Reader, fn func(*ImportRecord) error) error {
+	rows, dimensions, width, err := readNpyHeader(vectors)
+	if err != nil {
+		return err
+	}
+
+	lines := bufio.NewScanner(records)
+	lines.Buffer(nil, 64<<20)
+	nextLine := func() bool {
+		for lines.Scan() {
+			if len(bytes.TrimSpace(lines.Bytes())) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	values := make([]byte, dimensions*width)
+	for row := 1; row <= rows; row++ {
+		if _, err := io.ReadFull(vectors, values); err != nil {
+			return fmt.Errorf("failed to read row %d of NumPy file: %w", row, err)
+		}
+		if !nextLine() {
+			if err := lines.Err(); err != nil {
+				return fmt.Errorf("failed to read records file: %w", err)
+			}
+			return fmt.Errorf("records file has %d lines for %d rows", row-1, rows)
+		}
+
+		record, err := parseImportRecord(lines.Bytes(), row)
+		if err != nil {
+			return err
+		}
+		record.Embedding = make([]float32, dimensions)
+		for i := range record.Embedding {
+			if width == 8 {
+				record.Embedding[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(values[8*i:])))
+			} else {
+				record.Embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(values[4*i:]))
+			}
+		}
+		if err := record.check(); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	if nextLine() {
+		return fmt.Errorf("records file has more lines than the %d rows of the NumPy file", rows)
+	}
+	if err := lines.Err(); err != nil {
+		return fmt.Errorf("failed to read records file: %w", err)
+	}
+	return nil
+}
+
+// readNpyHeader reads the header of an .npy file, returning the shape of its
+// array and the size of its values
+func readNpyHeader(r io.Reader) (rows, dimensions, width int, err error) {
+	prefix := make([]byte, 8)
+	if _, err := io.ReadFull(r, prefix); err != nil || string(prefix[:6]) != "\x93NUMPY" {
+		return 0, 0, 0, fmt.Errorf("not a NumPy .npy file")
+	}
+
+	// Version 1 stores the header length in 2 bytes, later versions in 4
+	var length int
+	if prefix[6] == 1 {
+		var size uint16
+		err = binary.Read(r, binary.LittleEndian, &size)
+		length = int(size)
+	} else {
+		var size uint32
+		err = binary.Read(r, binary.LittleEndian, &size)
+		length = int(size)
+	}
+	if err != nil || length > 1<<20 {
+		return 0, 0, 0, fmt.Errorf("invalid NumPy file header")
+	}
+	header := make([]byte, length)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid NumPy file header")
+	}
+
+	descr := npyDescrPattern.FindSubmatch(header)
+	switch {
+	case descr == nil:
+		return 0, 0, 0, fmt.Errorf("invalid NumPy file header")
+	case string(descr[1]) == "<f4":
+		width = 4
+	case string(descr[1]) == "<f8":
+		width = 8
+	default:
+		return 0, 0, 0, fmt.Errorf("NumPy array of type %s isn't supported (expected float32 or float64)", descr[1])
+	}
+	if npyFortranPattern.Match(header) {
+		return 0, 0, 0, fmt.Errorf("NumPy arrays in Fortran order aren't supported")
+	}
+	shape := npyShapePattern.FindSubmatch(header)
+	if shape == nil {
+		return 0, 0, 0, fmt.Errorf("NumPy array must have two dimensions: a row of values per chunk")
+	}
+	rows, _ = strconv.Atoi(string(shape[1]))
+	dimensions, _ = strconv.Atoi(string(shape[2]))
+	return rows, dimensions, width, nil
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumPyExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.npy")
+	writer, files, err := Create(FormatNumPy, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{path, strings.TrimSuffix(path, ".npy") + ".jsonl"}, files)
+
+	require.NoError(t, writer.Write(&database.EmbeddingRecord{ID: "a", Folder: "/docs", FilePath: "setup.md", Metadata: `{"lang":"en"}`, Embedding: []float32{0.5, -1}}))
+	require.NoError(t, writer.Write(&database.EmbeddingRecord{ID: "b", Folder: "/docs", FilePath: "setup.md", ChunkIndex: 1, Metadata: `{}`, Embedding: []float32{2, 0.25}}))
+	assert.Error(t, writer.Write(&database.EmbeddingRecord{ID: "c", Embedding: []float32{1, 2, 3}}), "Expected an error for other dimensions")
+	require.NoError(t, writer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, data, npyHeaderSize+4*4)
+	assert.Equal(t, "\x93NUMPY\x01\x00", string(data[:8]))
+	assert.Equal(t, uint16(npyHeaderSize-10), binary.LittleEndian.Uint16(data[8:10]))
+	header := string(data[10:npyHeaderSize])
+	assert.True(t, strings.HasPrefix(header, "{'descr': '<f4', 'fortran_order': False, 'shape': (2, 2), }"), header)
+	assert.True(t, strings.HasSuffix(header, " \n"), header)
+
+	var values []float32
+	for i := npyHeaderSize; i < len(data); i += 4 {
+		values = append(values, math.Float32frombits(binary.LittleEndian.Uint32(data[i:])))
+	}
+	assert.Equal(t, []float32{0.5, -1, 2, 0.25}, values)
+
+	records, err := os.ReadFile(files[1])
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","folder":"/docs","file_path":"setup.md","chunk_index":0,"metadata":{"lang":"en"}}
+{"id":"b","folder":"/docs","file_path":"setup.md","chunk_index":1,"metadata":{}}
+`, string(records))
+}
+
+func TestCreateUnknownFormat(t *testing.T) {
+	_, _, err := Create("csv", filepath.Join(t.TempDir(), "docs.csv"))
+	assert.Error(t, err)
+	_, _, err = Create(FormatNumPy, filepath.Join(t.TempDir(), "docs.jsonl"))
+	assert.Error(t, err, "Expected an error for an array file that would be overwritten by the records")
+}
@@ -0,0 +1,324 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+// parquetMagic starts and ends every Parquet file
+const parquetMagic = "PAR1"
+
+// parquetRowGroupRows is how many rows are buffered before they are written
+// as a row group
+const parquetRowGroupRows = 10000
+
+// Values of the Parquet format's enums
+const (
+	parquetInt32        = 1
+	parquetFloat        = 4
+	parquetByteArray    = 6
+	parquetRequired     = 0
+	parquetRepeated     = 2
+	parquetUTF8         = 0
+	parquetList         = 3
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+// parquetColumn is a leaf column of the exported file
+type parquetColumn struct {
+	path          []string
+	physicalType  int32
+	repeated      bool // The column holds the elements of the embedding list
+	values        bytes.Buffer
+	chunkMetadata []parquetChunk
+}
+
+// parquetChunk is where a column chunk of a row group was written
+type parquetChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+// ParquetWriter writes embeddings as a Parquet file with the columns id,
+// folder, file_path, chunk_index, metadata and embedding, the last a list of
+// floats. Values are written uncompressed and in the plain encoding, which
+// every Parquet reader supports.
+type ParquetWriter struct {
+	w          io.Writer
+	offset     int64
+	dimensions int
+	rows       int // Rows in the current row group
+	totalRows  int64
+	rowGroups  []int // Rows of each written row group
+	columns    []*parquetColumn
+	err        error
+}
+
+// NewParquetWriter creates a writer of a Parquet file to w
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	return &ParquetWriter{
+		w: w,
+		columns: []*parquetColumn{
+			{path: []string{"id"}, physicalType: parquetByteArray},
+			{path: []string{"folder"}, physicalType: parquetByteArray},
+			{path: []string{"file_path"}, physicalType: parquetByteArray},
+			{path: []string{"chunk_index"}, physicalType: parquetInt32},
+			{path: []string{"metadata"}, physicalType: parquetByteArray},
+			{path: []string{"embedding", "list", "element"}, physicalType: parquetFloat, repeated: true},
+		},
+	}
+}
+
+// Write adds the row of a chunk's embedding. All embeddings must have the
+// same number of dimensions.
+func (pw *ParquetWriter) Write(record *database.EmbeddingRecord) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if len(record.Embedding) == 0 {
+		return fmt.Errorf("chunk %s has no embedding", record.ID)
+	}
+	if pw.dimensions == 0 {
+		pw.dimensions = len(record.Embedding)
+	}
+	if len(record.Embedding) != pw.dimensions {
+		return fmt.Errorf("embedding of chunk %s has %d dimensions, expected %d", record.ID, len(record.Embedding), pw.dimensions)
+	}
+
+	for i, value := range []string{record.ID, record.Folder, record.FilePath} {
+		writeByteArray(&pw.columns[i].values, value)
+	}
+	binary.Write(&pw.columns[3].values, binary.LittleEndian, int32(record.ChunkIndex))
+	writeByteArray(&pw.columns[4].values, record.Metadata)
+	for _, value := range record.Embedding {
+		binary.Write(&pw.columns[5].values, binary.LittleEndian, math.Float32bits(value))
+	}
+
+	pw.rows++
+	if pw.rows == parquetRowGroupRows {
+		pw.err = pw.flush()
+	}
+	return pw.err
+}
+
+// Close writes the buffered rows and the file metadata
+func (pw *ParquetWriter) Close() error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if pw.offset == 0 {
+		if err := pw.write([]byte(parquetMagic)); err != nil {
+			return err
+		}
+	}
+	if pw.rows > 0 {
+		if err := pw.flush(); err != nil {
+			return err
+		}
+	}
+
+	footer := pw.fileMetadata()
+	if err := pw.write(footer); err != nil {
+		return err
+	}
+	length := binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))
+	if err := pw.write(append(length, parquetMagic...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// write writes to the file, keeping track of the offset
+func (pw *ParquetWriter) write(data []byte) error {
+	n, err := pw.w.Write(data)
+	pw.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write Parquet file: %w", err)
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group with one data page per
+// column
+func (pw *ParquetWriter) flush() error {
+	if pw.offset == 0 {
+		if err := pw.write([]byte(parquetMagic)); err != nil {
+			return err
+		}
+	}
+
+	for _, column := range pw.columns {
+		var page bytes.Buffer
+		numValues := pw.rows
+		if column.repeated {
+			numValues = pw.rows * pw.dimensions
+			writeLevels(&page, embeddingRepetitionLevels(pw.rows, pw.dimensions))
+			writeLevels(&page, rleRun(numValues, 1))
+		}
+		page.Write(column.values.Bytes())
+		column.values.Reset()
+
+		header := pageHeader(page.Len(), numValues)
+		chunk := parquetChunk{offset: pw.offset, size: int64(len(header) + page.Len()), numValues: int64(numValues)}
+		if err := pw.write(header); err != nil {
+			return err
+		}
+		if err := pw.write(page.Bytes()); err != nil {
+			return err
+		}
+		column.chunkMetadata = append(column.chunkMetadata, chunk)
+	}
+
+	pw.rowGroups = append(pw.rowGroups, pw.rows)
+	pw.totalRows += int64(pw.rows)
+	pw.rows = 0
+	return nil
+}
+
+// fileMetadata encodes the schema and the row groups of the file
+func (pw *ParquetWriter) fileMetadata() []byte {
+	t := &thriftWriter{}
+	t.begin()
+	t.i32(1, 1)
+
+	// The schema is flattened depth first, with the embedding a list in
+	// the standard three-level layout
+	t.list(2, thriftStruct, 9)
+	schemaGroup(t, "schema", -1, 6, -1)
+	for _, name := range []string{"id", "folder", "file_path"} {
+		schemaLeaf(t, name, parquetByteArray, parquetRequired, parquetUTF8)
+	}
+	schemaLeaf(t, "chunk_index", parquetInt32, parquetRequired, -1)
+	schemaLeaf(t, "metadata", parquetByteArray, parquetRequired, parquetUTF8)
+	schemaGroup(t, "embedding", parquetRequired, 1, parquetList)
+	schemaGroup(t, "list", parquetRepeated, 1, -1)
+	schemaLeaf(t, "element", parquetFloat, parquetRequired, -1)
+
+	t.i64(3, pw.totalRows)
+	t.list(4, thriftStruct, len(pw.rowGroups))
+	for i, rows := range pw.rowGroups {
+		t.begin()
+		t.list(1, thriftStruct, len(pw.columns))
+		var size int64
+		for _, column := range pw.columns {
+			chunk := column.chunkMetadata[i]
+			size += chunk.size
+			t.begin()
+			t.i64(2, chunk.offset)
+			t.structField(3)
+			t.i32(1, column.physicalType)
+			if column.repeated {
+				t.list(2, thriftI32, 2)
+				t.i32Element(parquetPlain)
+				t.i32Element(parquetRLE)
+			} else {
+				t.list(2, thriftI32, 1)
+				t.i32Element(parquetPlain)
+			}
+			t.list(3, thriftBinary, len(column.path))
+			for _, name := range column.path {
+				t.stringElement(name)
+			}
+			t.i32(4, parquetUncompressed)
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, size)
+		t.i64(3, int64(rows))
+		t.end()
+	}
+	t.string(6, "rag-cli")
+	t.end()
+	return t.buf.Bytes()
+}
+
+// schemaGroup writes the schema element of a group; repetition and
+// convertedType are left out when negative
+func schemaGroup(t *thriftWriter, name string, repetition int32, children int32, convertedType int32) {
+	t.begin()
+	if repetition >= 0 {
+		t.i32(3, repetition)
+	}
+	t.string(4, name)
+	t.i32(5, children)
+	if convertedType >= 0 {
+		t.i32(6, convertedType)
+	}
+	t.end()
+}
+
+// schemaLeaf writes the schema element of a column; convertedType is left
+// out when negative
+func schemaLeaf(t *thriftWriter, name string, physicalType, repetition, convertedType int32) {
+	t.begin()
+	t.i32(1, physicalType)
+	t.i32(3, repetition)
+	t.string(4, name)
+	if convertedType >= 0 {
+		t.i32(6, convertedType)
+	}
+	t.end()
+}
+
+// pageHeader encodes the header of an uncompressed data page
+func pageHeader(size, numValues int) []byte {
+	t := &thriftWriter{}
+	t.begin()
+	t.i32(1, parquetDataPage)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.structField(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, parquetPlain)
+	t.i32(3, parquetRLE)
+	t.i32(4, parquetRLE)
+	t.end()
+	t.end()
+	return t.buf.Bytes()
+}
+
+// embeddingRepetitionLevels encodes the repetition levels of rows of
+// embeddings: 0 for the first value of each row, which starts a new list,
+// and 1 for the rest
+func embeddingRepetitionLevels(rows, dimensions int) []byte {
+	var levels []byte
+	for range rows {
+		levels = append(levels, rleRun(1, 0)...)
+		if dimensions > 1 {
+			levels = append(levels, rleRun(dimensions-1, 1)...)
+		}
+	}
+	return levels
+}
+
+// rleRun encodes count repeats of a level of bit width 1 as a run of the
+// RLE/bit-packing hybrid encoding
+func rleRun(count int, level byte) []byte {
+	return append(binary.AppendUvarint(nil, uint64(count)<<1), level)
+}
+
+// writeLevels writes encoded levels prefixed with their length, as data
+// pages store them
+func writeLevels(page *bytes.Buffer, levels []byte) {
+	page.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))))
+	page.Write(levels)
+}
+
+// writeByteArray writes a value of a byte array column in the plain encoding
+func writeByteArray(values *bytes.Buffer, value string) {
+	values.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(value))))
+	values.WriteString(value)
+}
@@ -0,0 +1,655 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+
+	"github.com/golang/snappy"
+)
+
+// Values of the Parquet format's enums that only files written by other
+// tools use
+const (
+	parquetInt64           = 2
+	parquetDouble          = 5
+	parquetOptional        = 1
+	parquetPlainDictionary = 2
+	parquetRLEDictionary   = 8
+	parquetSnappy          = 1
+	parquetGzip            = 2
+	parquetDictionaryPage  = 2
+	parquetDataPageV2      = 3
+)
+
+// parquetCodecs names the compression codecs that aren't supported
+var parquetCodecs = map[int64]string{3: "LZO", 4: "Brotli", 5: "LZ4", 6: "Zstandard", 7: "LZ4"}
+
+// errParquetCorrupt is returned for pages that can't be decoded
+var errParquetCorrupt = errors.New("corrupt Parquet page")
+
+// parquetLeaf is a leaf column of a Parquet file's schema
+type parquetLeaf struct {
+	name          string // Top-level field of the column
+	nested        bool   // The column is in a group, such as a list
+	physicalType  int64
+	maxDefinition int
+	maxRepetition int
+	index         int // Position of the column's chunks in row groups
+}
+
+// parquetValues are decoded values of a column: integers, floats or strings
+// depending on its physical type
+type parquetValues struct {
+	ints    []int64
+	floats  []float32
+	strings []string
+}
+
+// parquetColumnValues are the values of a column in a row group, a list of
+// floats per row for the embedding
+type parquetColumnValues struct {
+	parquetValues
+	lists [][]float32
+	nulls []bool // The row's value is null, or its list holds nulls
+}
+
+// ReadParquet calls fn with each row of a Parquet file of embeddings to
+// import. The file needs the columns file_path, content and embedding, the
+// last a list of floats or doubles, and may have the columns chunk_index,
+// metadata (JSON) and model. Pages may be compressed with Snappy or gzip and
+// use the plain or dictionary encodings, as pyarrow and Polars write them.
+// Reading stops at the first error fn returns.
+func ReadParquet(r io.ReaderAt, size int64, fn func(*ImportRecord) error) error {
+	metadata, err := readParquetMetadata(r, size)
+	if err != nil {
+		return err
+	}
+	leaves, err := parquetLeaves(thriftStructs(metadata, 2))
+	if err != nil {
+		return err
+	}
+	columns, err := importParquetColumns(leaves)
+	if err != nil {
+		return err
+	}
+
+	row := 0
+	for _, rowGroup := range thriftStructs(metadata, 4) {
+		rows := int(thriftInt(rowGroup, 3, 0))
+		chunks := thriftStructs(rowGroup, 1)
+		values := make(map[string]*parquetColumnValues)
+		for name, leaf := range columns {
+			if leaf.index >= len(chunks) {
+				return fmt.Errorf("invalid Parquet file: row group has no column %s", leaf.name)
+			}
+			column, err := readParquetColumn(r, size, chunks[leaf.index], leaf)
+			if err != nil {
+				return fmt.Errorf("failed to read column %s: %w", leaf.name, err)
+			}
+			if len(column.nulls) != rows {
+				return fmt.Errorf("invalid Parquet file: column %s has %d values for %d rows", leaf.name, len(column.nulls), rows)
+			}
+			values[name] = column
+		}
+
+		for i := range rows {
+			row++
+			record := &ImportRecord{Row: row, ChunkIndex: -1}
+			record.FilePath = values["file_path"].stringAt(i)
+			record.Content = values["content"].stringAt(i)
+			if column := values["metadata"]; column != nil {
+				record.Metadata = column.stringAt(i)
+			}
+			if column := values["model"]; column != nil {
+				record.Model = column.stringAt(i)
+			}
+			if column := values["chunk_index"]; column != nil && !column.nulls[i] {
+				record.ChunkIndex = int(column.ints[i])
+			}
+			embedding := values["embedding"]
+			if embedding.nulls[i] && len(embedding.lists[i]) > 0 {
+				return fmt.Errorf("row %d has null values in its embedding", row)
+			}
+			record.Embedding = embedding.lists[i]
+
+			if err := record.check(); err != nil {
+				return err
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readParquetMetadata reads the file metadata from the footer of a Parquet
+// file
+func readParquetMetadata(r io.ReaderAt, size int64) (map[int16]any, error) {
+	tail := make([]byte, 8)
+	if size < 12 {
+		return nil, fmt.Errorf("not a Parquet file")
+	}
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return nil, fmt.Errorf("failed to read Parquet file: %w", err)
+	}
+	if string(tail[4:]) != parquetMagic {
+		return nil, fmt.Errorf("not a Parquet file, or one with encrypted metadata")
+	}
+
+	length := int64(binary.LittleEndian.Uint32(tail))
+	if length > size-12 {
+		return nil, fmt.Errorf("invalid Parquet file: metadata is longer than the file")
+	}
+	footer := make([]byte, length)
+	if _, err := r.ReadAt(footer, size-8-length); err != nil {
+		return nil, fmt.Errorf("failed to read Parquet file: %w", err)
+	}
+	metadata, err := readThriftStruct(bytes.NewReader(footer))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet file metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// parquetLeaves returns the leaf columns of a schema, which is flattened
+// depth first with the root first. Each optional or repeated field on the
+// way to a leaf adds a definition level, and each repeated one a repetition
+// level.
+func parquetLeaves(schema []map[int16]any) ([]*parquetLeaf, error) {
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("invalid Parquet file: no schema")
+	}
+
+	var leaves []*parquetLeaf
+	next := 1
+	var walk func(children int, top string, definition, repetition int) error
+	walk = func(children int, top string, definition, repetition int) error {
+		for range children {
+			if next >= len(schema) {
+				return fmt.Errorf("invalid Parquet file: schema is missing fields")
+			}
+			element := schema[next]
+			next++
+
+			name := top
+			if name == "" {
+				name = thriftString(element, 4)
+			}
+			d, r := definition, repetition
+			switch thriftInt(element, 3, parquetRequired) {
+			case parquetOptional:
+				d++
+			case parquetRepeated:
+				d++
+				r++
+			}
+
+			if groupChildren := thriftInt(element, 5, 0); groupChildren > 0 {
+				if err := walk(int(groupChildren), name, d, r); err != nil {
+					return err
+				}
+				continue
+			}
+			leaves = append(leaves, &parquetLeaf{
+				name:          name,
+				nested:        top != "",
+				physicalType:  thriftInt(element, 1, -1),
+				maxDefinition: d,
+				maxRepetition: r,
+				index:         len(leaves),
+			})
+		}
+		return nil
+	}
+	if err := walk(int(thriftInt(schema[0], 5, 0)), "", 0, 0); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+// importParquetColumns returns the leaf columns of what an import reads,
+// checking they have the types it needs
+func importParquetColumns(leaves []*parquetLeaf) (map[string]*parquetLeaf, error) {
+	columns := make(map[string]*parquetLeaf)
+	for _, leaf := range leaves {
+		column := importColumns[leaf.name]
+		if column == "" {
+			continue
+		}
+		if other, ok := columns[column]; ok {
+			if other.name == leaf.name {
+				return nil, fmt.Errorf("column %s must hold a single value per row", leaf.name)
+			}
+			return nil, fmt.Errorf("file has both the columns %s and %s", other.name, leaf.name)
+		}
+
+		switch column {
+		case "embedding":
+			if leaf.maxRepetition != 1 || (leaf.physicalType != parquetFloat && leaf.physicalType != parquetDouble) {
+				return nil, fmt.Errorf("column %s must be a list of floats or doubles", leaf.name)
+			}
+		case "chunk_index":
+			if leaf.nested || (leaf.physicalType != parquetInt32 && leaf.physicalType != parquetInt64) {
+				return nil, fmt.Errorf("column %s must be an integer", leaf.name)
+			}
+		default:
+			if leaf.nested || leaf.physicalType != parquetByteArray {
+				return nil, fmt.Errorf("column %s must be a string", leaf.name)
+			}
+		}
+		columns[column] = leaf
+	}
+
+	for _, column := range []string{"file_path", "content", "embedding"} {
+		if columns[column] == nil {
+			return nil, fmt.Errorf("file has no %s column", column)
+		}
+	}
+	return columns, nil
+}
+
+// readParquetColumn reads the values of a column chunk of a row group,
+// page by page
+func readParquetColumn(r io.ReaderAt, size int64, chunk map[int16]any, leaf *parquetLeaf) (*parquetColumnValues, error) {
+	metadata := thriftFields(chunk, 3)
+	if metadata == nil {
+		return nil, fmt.Errorf("column chunks in other files aren't supported")
+	}
+	codec := thriftInt(metadata, 4, parquetUncompressed)
+	numValues := thriftInt(metadata, 5, 0)
+	start := thriftInt(metadata, 9, 0)
+	if dictionary := thriftInt(metadata, 11, 0); dictionary > 0 && dictionary < start {
+		start = dictionary
+	}
+	length := thriftInt(metadata, 7, 0)
+	if start < 0 || length < 0 || start+length > size {
+		return nil, fmt.Errorf("invalid Parquet file: column chunk is outside the file")
+	}
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, start); err != nil {
+		return nil, fmt.Errorf("failed to read Parquet file: %w", err)
+	}
+
+	column := &parquetColumnValues{}
+	pages := bytes.NewReader(data)
+	var dictionary *parquetValues
+	for read := int64(0); read < numValues; {
+		header, err := readThriftStruct(pages)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page header: %w", err)
+		}
+		uncompressedSize := thriftInt(header, 2, 0)
+		compressedSize := thriftInt(header, 3, 0)
+		offset := int64(len(data) - pages.Len())
+		if compressedSize < 0 || compressedSize > int64(pages.Len()) {
+			return nil, errParquetCorrupt
+		}
+		body := data[offset : offset+compressedSize]
+		pages.Seek(compressedSize, io.SeekCurrent)
+
+		switch thriftInt(header, 1, -1) {
+		case parquetDictionaryPage:
+			page, err := decompressParquetPage(codec, body, uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			values, err := decodeParquetPlain(page, leaf.physicalType, int(thriftInt(thriftFields(header, 7), 1, 0)))
+			if err != nil {
+				return nil, err
+			}
+			dictionary = &values
+		case parquetDataPage:
+			page, err := decompressParquetPage(codec, body, uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			pageHeader := thriftFields(header, 5)
+			levels := int(thriftInt(pageHeader, 1, 0))
+			repetitions, page, err := readParquetLevels(page, leaf.maxRepetition, levels)
+			if err != nil {
+				return nil, err
+			}
+			definitions, page, err := readParquetLevels(page, leaf.maxDefinition, levels)
+			if err != nil {
+				return nil, err
+			}
+			values, err := decodeParquetValues(page, thriftInt(pageHeader, 2, parquetPlain), leaf, countDefined(definitions, leaf, levels), dictionary)
+			if err != nil {
+				return nil, err
+			}
+			if err := column.add(leaf, repetitions, definitions, levels, values); err != nil {
+				return nil, err
+			}
+			read += int64(levels)
+		case parquetDataPageV2:
+			// Levels are stored before the values without lengths or
+			// compression
+			pageHeader := thriftFields(header, 8)
+			levels := int(thriftInt(pageHeader, 1, 0))
+			definitionsLength := thriftInt(pageHeader, 5, 0)
+			repetitionsLength := thriftInt(pageHeader, 6, 0)
+			if definitionsLength < 0 || repetitionsLength < 0 || definitionsLength+repetitionsLength > int64(len(body)) {
+				return nil, errParquetCorrupt
+			}
+			var repetitions, definitions []int32
+			if leaf.maxRepetition > 0 {
+				if repetitions, err = decodeHybrid(body[:repetitionsLength], bits.Len(uint(leaf.maxRepetition)), levels); err != nil {
+					return nil, err
+				}
+			}
+			if leaf.maxDefinition > 0 {
+				if definitions, err = decodeHybrid(body[repetitionsLength:repetitionsLength+definitionsLength], bits.Len(uint(leaf.maxDefinition)), levels); err != nil {
+					return nil, err
+				}
+			}
+			page := body[repetitionsLength+definitionsLength:]
+			if compressed, ok := pageHeader[7].(bool); !ok || compressed {
+				if page, err = decompressParquetPage(codec, page, uncompressedSize-repetitionsLength-definitionsLength); err != nil {
+					return nil, err
+				}
+			}
+			values, err := decodeParquetValues(page, thriftInt(pageHeader, 4, parquetPlain), leaf, countDefined(definitions, leaf, levels), dictionary)
+			if err != nil {
+				return nil, err
+			}
+			if err := column.add(leaf, repetitions, definitions, levels, values); err != nil {
+				return nil, err
+			}
+			read += int64(levels)
+		}
+		if pages.Len() == 0 && read < numValues {
+			return nil, fmt.Errorf("invalid Parquet file: column chunk has %d of %d values", read, numValues)
+		}
+	}
+	return column, nil
+}
+
+// add places the values of a page in the rows of the column, as their
+// repetition and definition levels say. Nil levels are those of required
+// values that aren't in a list.
+func (c *parquetColumnValues) add(leaf *parquetLeaf, repetitions, definitions []int32, levels int, values parquetValues) error {
+	next := 0
+	for i := range levels {
+		defined := definitions == nil || int(definitions[i]) == leaf.maxDefinition
+
+		if leaf.maxRepetition > 0 {
+			// A repetition level of 0 starts the list of a new row
+			if repetitions == nil || repetitions[i] == 0 {
+				c.lists = append(c.lists, nil)
+				c.nulls = append(c.nulls, false)
+			} else if len(c.lists) == 0 {
+				return errParquetCorrupt
+			}
+			row := len(c.lists) - 1
+			if !defined {
+				c.nulls[row] = true
+				continue
+			}
+			if next >= len(values.floats) {
+				return errParquetCorrupt
+			}
+			c.lists[row] = append(c.lists[row], values.floats[next])
+			next++
+			continue
+		}
+
+		c.nulls = append(c.nulls, !defined)
+		if leaf.physicalType == parquetByteArray {
+			value := ""
+			if defined {
+				if next >= len(values.strings) {
+					return errParquetCorrupt
+				}
+				value = values.strings[next]
+				next++
+			}
+			c.strings = append(c.strings, value)
+		} else {
+			var value int64
+			if defined {
+				if next >= len(values.ints) {
+					return errParquetCorrupt
+				}
+				value = values.ints[next]
+				next++
+			}
+			c.ints = append(c.ints, value)
+		}
+	}
+	return nil
+}
+
+// stringAt returns the value of a string column in a row, empty when null
+func (c *parquetColumnValues) stringAt(row int) string {
+	return c.strings[row]
+}
+
+// countDefined returns how many of a page's levels have a value, as only
+// those are stored
+func countDefined(definitions []int32, leaf *parquetLeaf, levels int) int {
+	if definitions == nil {
+		return levels
+	}
+	count := 0
+	for _, definition := range definitions {
+		if int(definition) == leaf.maxDefinition {
+			count++
+		}
+	}
+	return count
+}
+
+// readParquetLevels reads the levels at the start of a data page of version
+// 1, prefixed with their length, and returns them with the rest of the page.
+// Columns whose maximum level is 0 store none.
+func readParquetLevels(page []byte, maxLevel, levels int) ([]int32, []byte, error) {
+	if maxLevel == 0 {
+		return nil, page, nil
+	}
+	if len(page) < 4 {
+		return nil, nil, errParquetCorrupt
+	}
+	length := int(binary.LittleEndian.Uint32(page))
+	if length > len(page)-4 {
+		return nil, nil, errParquetCorrupt
+	}
+	decoded, err := decodeHybrid(page[4:4+length], bits.Len(uint(maxLevel)), levels)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decoded, page[4+length:], nil
+}
+
+// decompressParquetPage decompresses a page with the codec of its column
+func decompressParquetPage(codec int64, page []byte, uncompressedSize int64) ([]byte, error) {
+	switch codec {
+	case parquetUncompressed:
+		return page, nil
+	case parquetSnappy:
+		// The size is checked before decoding allocates it
+		if n, err := snappy.DecodedLen(page); err != nil || int64(n) != uncompressedSize {
+			return nil, errParquetCorrupt
+		}
+		decompressed, err := snappy.Decode(nil, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress page: %w", err)
+		}
+		return decompressed, nil
+	case parquetGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(page))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress page: %w", err)
+		}
+		decompressed, err := io.ReadAll(io.LimitReader(reader, uncompressedSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress page: %w", err)
+		}
+		return decompressed, nil
+	default:
+		name := parquetCodecs[codec]
+		if name == "" {
+			name = fmt.Sprintf("codec %d", codec)
+		}
+		return nil, fmt.Errorf("%s compression isn't supported: write the file with Snappy or gzip compression, or none", name)
+	}
+}
+
+// decodeParquetValues decodes the values of a data page, looking up values
+// encoded as dictionary indexes in the column chunk's dictionary
+func decodeParquetValues(page []byte, encoding int64, leaf *parquetLeaf, count int, dictionary *parquetValues) (parquetValues, error) {
+	switch encoding {
+	case parquetPlain:
+		return decodeParquetPlain(page, leaf.physicalType, count)
+	case parquetPlainDictionary, parquetRLEDictionary:
+		if dictionary == nil {
+			return parquetValues{}, fmt.Errorf("invalid Parquet file: dictionary encoded page without a dictionary")
+		}
+		if count == 0 {
+			return parquetValues{}, nil
+		}
+		if len(page) == 0 {
+			return parquetValues{}, errParquetCorrupt
+		}
+		indexes, err := decodeHybrid(page[1:], int(page[0]), count)
+		if err != nil {
+			return parquetValues{}, err
+		}
+		return dictionary.lookup(indexes)
+	default:
+		return parquetValues{}, fmt.Errorf("Parquet encoding %d isn't supported: write the file with the plain or dictionary encoding", encoding)
+	}
+}
+
+// decodeParquetPlain decodes count values of a physical type in the plain
+// encoding, converting doubles to floats
+func decodeParquetPlain(data []byte, physicalType int64, count int) (parquetValues, error) {
+	var values parquetValues
+	width := map[int64]int{parquetInt32: 4, parquetInt64: 8, parquetFloat: 4, parquetDouble: 8}[physicalType]
+	if width > 0 && len(data) < width*count {
+		return values, errParquetCorrupt
+	}
+
+	switch physicalType {
+	case parquetInt32:
+		values.ints = make([]int64, count)
+		for i := range values.ints {
+			values.ints[i] = int64(int32(binary.LittleEndian.Uint32(data[4*i:])))
+		}
+	case parquetInt64:
+		values.ints = make([]int64, count)
+		for i := range values.ints {
+			values.ints[i] = int64(binary.LittleEndian.Uint64(data[8*i:]))
+		}
+	case parquetFloat:
+		values.floats = make([]float32, count)
+		for i := range values.floats {
+			values.floats[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+	case parquetDouble:
+		values.floats = make([]float32, count)
+		for i := range values.floats {
+			values.floats[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:])))
+		}
+	case parquetByteArray:
+		values.strings = make([]string, 0, min(count, len(data)/4))
+		for range count {
+			if len(data) < 4 {
+				return values, errParquetCorrupt
+			}
+			length := int(binary.LittleEndian.Uint32(data))
+			if length > len(data)-4 {
+				return values, errParquetCorrupt
+			}
+			values.strings = append(values.strings, string(data[4:4+length]))
+			data = data[4+length:]
+		}
+	default:
+		return values, fmt.Errorf("Parquet type %d isn't supported", physicalType)
+	}
+	return values, nil
+}
+
+// lookup returns the values of a dictionary at the given indexes
+func (v *parquetValues) lookup(indexes []int32) (parquetValues, error) {
+	var values parquetValues
+	size := len(v.ints) + len(v.floats) + len(v.strings)
+	for _, index := range indexes {
+		if index < 0 || int(index) >= size {
+			return values, fmt.Errorf("invalid Parquet file: dictionary index %d out of range", index)
+		}
+		switch {
+		case v.ints != nil:
+			values.ints = append(values.ints, v.ints[index])
+		case v.floats != nil:
+			values.floats = append(values.floats, v.floats[index])
+		default:
+			values.strings = append(values.strings, v.strings[index])
+		}
+	}
+	return values, nil
+}
+
+// decodeHybrid decodes count values of the given bit width in the
+// RLE/bit-packing hybrid encoding, which Parquet uses for levels and
+// dictionary indexes: a sequence of runs of a repeated value and of groups
+// of 8 bit-packed values
+func decodeHybrid(data []byte, bitWidth, count int) ([]int32, error) {
+	if bitWidth > 32 {
+		return nil, errParquetCorrupt
+	}
+	values := make([]int32, 0, min(count, 1<<20))
+	byteWidth := (bitWidth + 7) / 8
+	mask := uint64(1)<<bitWidth - 1
+
+	for len(values) < count {
+		header, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errParquetCorrupt
+		}
+		data = data[n:]
+
+		if header&1 == 0 {
+			// A run of a value stored in as many bytes as it needs
+			if len(data) < byteWidth {
+				return nil, errParquetCorrupt
+			}
+			var value int32
+			for i := byteWidth - 1; i >= 0; i-- {
+				value = value<<8 | int32(data[i])
+			}
+			data = data[byteWidth:]
+			for range min(int(header>>1), count-len(values)) {
+				values = append(values, value)
+			}
+			continue
+		}
+
+		// Groups of 8 values packed from the least significant bit
+		groups := int(header >> 1)
+		size := min(groups*bitWidth, len(data))
+		var buffer uint64
+		buffered, next := 0, 0
+		for range min(groups*8, count-len(values)) {
+			for buffered < bitWidth {
+				if next == size {
+					return nil, errParquetCorrupt
+				}
+				buffer |= uint64(data[next]) << buffered
+				next++
+				buffered += 8
+			}
+			values = append(values, int32(buffer&mask))
+			buffer >>= bitWidth
+			buffered -= bitWidth
+		}
+		data = data[size:]
+	}
+	return values, nil
+}
@@ -0,0 +1,272 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadParquet(t *testing.T) {
+	data := testParquetFile(t)
+
+	var records []*ImportRecord
+	err := ReadParquet(bytes.NewReader(data), int64(len(data)), func(record *ImportRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*ImportRecord{
+		{Row: 1, FilePath: "guide.md", Content: "alpha", ChunkIndex: 0, Embedding: []float32{0.5, 1}},
+		{Row: 2, FilePath: "guide.md", Content: "beta", ChunkIndex: -1, Embedding: []float32{2, -1}},
+	}, records)
+}
+
+func TestReadParquetExport(t *testing.T) {
+	// Exports have no content to search and chat with
+	var buf bytes.Buffer
+	writer := NewParquetWriter(&buf)
+	require.NoError(t, writer.Write(&database.EmbeddingRecord{ID: "a", FilePath: "setup.md", Metadata: `{}`, Embedding: []float32{1, 0}}))
+	require.NoError(t, writer.Close())
+
+	err := ReadParquet(bytes.NewReader(buf.Bytes()), int64(buf.Len()), func(*ImportRecord) error { return nil })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no content column")
+
+	err = ReadParquet(bytes.NewReader([]byte("not parquet at all")), 18, func(*ImportRecord) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestReadParquetWrittenByParquetGo(t *testing.T) {
+	// A file written by github.com/xitongsys/parquet-go, another
+	// implementation of the format, with dictionary encoded strings and
+	// Snappy compressed pages (see testdata/README.md)
+	data, err := os.ReadFile("testdata/parquet-go-flat.parquet")
+	require.NoError(t, err)
+	r := bytes.NewReader(data)
+
+	metadata, err := readParquetMetadata(r, int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), thriftInt(metadata, 3, 0))
+	leaves, err := parquetLeaves(thriftStructs(metadata, 2))
+	require.NoError(t, err)
+	byName := make(map[string]*parquetLeaf)
+	for _, leaf := range leaves {
+		byName[leaf.name] = leaf
+	}
+
+	rowGroups := thriftStructs(metadata, 4)
+	require.Len(t, rowGroups, 1)
+	chunks := thriftStructs(rowGroups[0], 1)
+	read := func(name string) *parquetColumnValues {
+		leaf := byName[name]
+		require.NotNil(t, leaf, "column %s", name)
+		column, err := readParquetColumn(r, int64(len(data)), chunks[leaf.index], leaf)
+		require.NoError(t, err, "column %s", name)
+		return column
+	}
+
+	names := read("name")
+	require.Len(t, names.strings, 10)
+	for _, name := range names.strings {
+		assert.Equal(t, "StudentName", name)
+	}
+	assert.Equal(t, []int64{20, 21, 22, 23, 24, 20, 21, 22, 23, 24}, read("age").ints)
+	assert.Equal(t, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, read("id").ints)
+
+	// It has no columns to import
+	err = ReadParquet(r, int64(len(data)), func(*ImportRecord) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestDecodeHybrid(t *testing.T) {
+	// The bit-packed example of the Parquet format followed by a run
+	data := []byte{0x03, 0x88, 0xc6, 0xfa, 0x0a, 0x07}
+	values, err := decodeHybrid(data, 3, 13)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 1, 2, 3, 4, 5, 6, 7, 7, 7, 7, 7, 7}, values)
+
+	_, err = decodeHybrid(data[:2], 3, 8)
+	assert.Error(t, err, "Expected an error for truncated values")
+}
+
+func TestDecompressParquetPage(t *testing.T) {
+	page := bytes.Repeat([]byte("abc"), 100)
+	decompressed, err := decompressParquetPage(parquetSnappy, snappy.Encode(nil, page), int64(len(page)))
+	require.NoError(t, err)
+	assert.Equal(t, page, decompressed)
+
+	_, err = decompressParquetPage(parquetSnappy, snappy.Encode(nil, page), 1<<40)
+	assert.Error(t, err, "Expected an error for a page of another size than its header says")
+	_, err = decompressParquetPage(5, page, int64(len(page)))
+	assert.ErrorContains(t, err, "LZ4")
+}
+
+// testParquetFile builds a Parquet file as other tools write them, with
+// optional columns, a list of optional doubles, dictionary encoding, both
+// versions of data pages, and Snappy and gzip compression
+func testParquetFile(t *testing.T) []byte {
+	t.Helper()
+
+	type chunk struct {
+		name                 string
+		physicalType, codec  int32
+		offset, dataOffset   int64
+		size, numValues      int64
+		path                 []string
+		dictionaryPageLength int64
+	}
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+	var chunks []chunk
+	addChunk := func(c chunk, pages ...[]byte) {
+		c.offset = int64(file.Len())
+		c.dataOffset = c.offset + c.dictionaryPageLength
+		for _, page := range pages {
+			file.Write(page)
+		}
+		c.size = int64(file.Len()) - c.offset
+		chunks = append(chunks, c)
+	}
+
+	// file_path: optional, with a dictionary, Snappy compressed
+	dictionary := plainStrings("guide.md")
+	dictionaryPage := testPage(parquetDictionaryPage, len(dictionary), snappy.Encode(nil, dictionary), func(t *thriftWriter) {
+		t.structField(7)
+		t.i32(1, 1)
+		t.i32(2, parquetPlain)
+		t.end()
+	})
+	values := levelsV1([]byte{0x04, 0x01}) // Both defined
+	values = append(values, 0x01, 0x03, 0x00)
+	addChunk(chunk{path: []string{"file_path"}, physicalType: parquetByteArray, codec: parquetSnappy, numValues: 2, dictionaryPageLength: int64(len(dictionaryPage))},
+		dictionaryPage,
+		testPage(parquetDataPage, len(values), snappy.Encode(nil, values), dataPageV1(2, parquetRLEDictionary)))
+
+	// content: required, in a data page of version 2
+	values = plainStrings("alpha", "beta")
+	addChunk(chunk{path: []string{"content"}, physicalType: parquetByteArray, codec: parquetUncompressed, numValues: 2},
+		testPage(parquetDataPageV2, len(values), values, func(t *thriftWriter) {
+			t.structField(8)
+			t.i32(1, 2)
+			t.i32(2, 0)
+			t.i32(3, 2)
+			t.i32(4, parquetPlain)
+			t.i32(5, 0)
+			t.i32(6, 0)
+			t.field(7, thriftFalse)
+			t.end()
+		}))
+
+	// chunk_index: optional, null in the second row
+	values = levelsV1([]byte{0x03, 0x01})
+	values = binary.LittleEndian.AppendUint64(values, 0)
+	addChunk(chunk{path: []string{"chunk_index"}, physicalType: parquetInt64, codec: parquetUncompressed, numValues: 2},
+		testPage(parquetDataPage, len(values), values, dataPageV1(2, parquetPlain)))
+
+	// embedding: an optional list of optional doubles, gzip compressed
+	values = levelsV1([]byte{0x03, 0x0a}) // Repetition levels 0, 1, 0, 1
+	values = append(values, levelsV1([]byte{0x08, 0x03})...)
+	for _, value := range []float64{0.5, 1, 2, -1} {
+		values = binary.LittleEndian.AppendUint64(values, math.Float64bits(value))
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(values)
+	require.NoError(t, gz.Close())
+	addChunk(chunk{path: []string{"embedding", "list", "element"}, physicalType: parquetDouble, codec: parquetGzip, numValues: 4},
+		testPage(parquetDataPage, len(values), compressed.Bytes(), dataPageV1(4, parquetPlain)))
+
+	tw := &thriftWriter{}
+	tw.begin()
+	tw.i32(1, 1)
+	tw.list(2, thriftStruct, 7)
+	schemaGroup(tw, "schema", -1, 4, -1)
+	schemaLeaf(tw, "file_path", parquetByteArray, parquetOptional, parquetUTF8)
+	schemaLeaf(tw, "content", parquetByteArray, parquetRequired, parquetUTF8)
+	schemaLeaf(tw, "chunk_index", parquetInt64, parquetOptional, -1)
+	schemaGroup(tw, "embedding", parquetOptional, 1, parquetList)
+	schemaGroup(tw, "list", parquetRepeated, 1, -1)
+	schemaLeaf(tw, "element", parquetDouble, parquetOptional, -1)
+	tw.i64(3, 2)
+	tw.list(4, thriftStruct, 1)
+	tw.begin()
+	tw.list(1, thriftStruct, len(chunks))
+	for _, c := range chunks {
+		tw.begin()
+		tw.i64(2, c.offset)
+		tw.structField(3)
+		tw.i32(1, c.physicalType)
+		tw.list(2, thriftI32, 1)
+		tw.i32Element(parquetPlain)
+		tw.list(3, thriftBinary, len(c.path))
+		for _, name := range c.path {
+			tw.stringElement(name)
+		}
+		tw.i32(4, c.codec)
+		tw.i64(5, c.numValues)
+		tw.i64(6, c.size)
+		tw.i64(7, c.size)
+		tw.i64(9, c.dataOffset)
+		if c.dictionaryPageLength > 0 {
+			tw.i64(11, c.offset)
+		}
+		tw.end()
+		tw.end()
+	}
+	tw.i64(2, int64(file.Len()-4))
+	tw.i64(3, 2)
+	tw.end()
+	tw.end()
+
+	file.Write(tw.buf.Bytes())
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(tw.buf.Len())))
+	file.WriteString(parquetMagic)
+	return file.Bytes()
+}
+
+// testPage encodes a page header, with the fields of its type written by
+// fields, followed by the page
+func testPage(pageType int32, uncompressedSize int, page []byte, fields func(t *thriftWriter)) []byte {
+	tw := &thriftWriter{}
+	tw.begin()
+	tw.i32(1, pageType)
+	tw.i32(2, int32(uncompressedSize))
+	tw.i32(3, int32(len(page)))
+	fields(tw)
+	tw.end()
+	return append(tw.buf.Bytes(), page...)
+}
+
+// dataPageV1 returns the fields of the header of a data page of version 1
+func dataPageV1(numValues, encoding int32) func(t *thriftWriter) {
+	return func(t *thriftWriter) {
+		t.structField(5)
+		t.i32(1, numValues)
+		t.i32(2, encoding)
+		t.i32(3, parquetRLE)
+		t.i32(4, parquetRLE)
+		t.end()
+	}
+}
+
+// levelsV1 prefixes encoded levels with their length
+func levelsV1(levels []byte) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))), levels...)
+}
+
+// plainStrings encodes strings in the plain encoding
+func plainStrings(values ...string) []byte {
+	var buf bytes.Buffer
+	for _, value := range values {
+		writeByteArray(&buf, value)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,260 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Types of the Thrift compact protocol
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol, which Parquet
+// uses for its page headers and file metadata. Only the types Parquet
+// metadata needs are supported.
+type thriftWriter struct {
+	buf       bytes.Buffer
+	lastField int16
+	fields    []int16 // Last field IDs of the enclosing structs
+}
+
+// varint writes an unsigned variable-length integer
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+// zigzag writes a signed integer in zigzag encoding
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+// field writes the header of a struct field. Field IDs that follow the
+// previous one closely are written as a delta in the type byte.
+func (t *thriftWriter) field(id int16, fieldType byte) {
+	if delta := id - t.lastField; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.zigzag(int64(id))
+	}
+	t.lastField = id
+}
+
+// i32 writes a 32-bit integer field
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+// i64 writes a 64-bit integer field
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+// string writes a string field
+func (t *thriftWriter) string(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+// list writes the header of a list field of size elements
+func (t *thriftWriter) list(id int16, elementType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		t.buf.WriteByte(0xf0 | elementType)
+		t.varint(uint64(size))
+	}
+}
+
+// i32Element writes an element of a list of 32-bit integers
+func (t *thriftWriter) i32Element(v int32) {
+	t.zigzag(int64(v))
+}
+
+// stringElement writes an element of a list of strings
+func (t *thriftWriter) stringElement(v string) {
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+// structField starts a struct field, which is ended by end
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// begin starts a struct that is a list element or the top-level struct
+func (t *thriftWriter) begin() {
+	t.fields = append(t.fields, t.lastField)
+	t.lastField = 0
+}
+
+// end ends the current struct
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.lastField = t.fields[len(t.fields)-1]
+	t.fields = t.fields[:len(t.fields)-1]
+}
+
+// readThriftStruct decodes a struct in the Thrift compact protocol into its
+// fields by ID, with integers as int64, strings as []byte, lists as []any
+// and structs as maps, so Parquet metadata can be read without generated
+// code. Maps aren't supported, as Parquet metadata has none.
+func readThriftStruct(r *bytes.Reader) (map[int16]any, error) {
+	fields := make(map[int16]any)
+	var lastField int16
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Thrift struct: %w", err)
+		}
+		if header == 0 {
+			return fields, nil
+		}
+		id := lastField + int16(header>>4)
+		if header>>4 == 0 {
+			long, err := readZigzag(r)
+			if err != nil {
+				return nil, err
+			}
+			id = int16(long)
+		}
+		lastField = id
+
+		// Booleans are stored in the type of their field header
+		switch header & 0x0f {
+		case thriftTrue:
+			fields[id] = true
+		case thriftFalse:
+			fields[id] = false
+		default:
+			if fields[id], err = readThriftValue(r, header&0x0f); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readThriftValue decodes a value of the given compact protocol type
+func readThriftValue(r *bytes.Reader, valueType byte) (any, error) {
+	switch valueType {
+	case thriftTrue, thriftFalse:
+		// Booleans in lists take a byte
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Thrift value: %w", err)
+		}
+		return b == thriftTrue, nil
+	case thriftByte:
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Thrift value: %w", err)
+		}
+		return int64(int8(b)), nil
+	case thriftI16, thriftI32, thriftI64:
+		return readZigzag(r)
+	case thriftDouble:
+		var v float64
+		if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+			return nil, fmt.Errorf("failed to read Thrift value: %w", err)
+		}
+		return v, nil
+	case thriftBinary:
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Thrift value: %w", err)
+		}
+		if length > uint64(r.Len()) {
+			return nil, fmt.Errorf("Thrift string of %d bytes is longer than its struct", length)
+		}
+		value := make([]byte, length)
+		r.Read(value)
+		return value, nil
+	case thriftList, thriftSet:
+		header, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Thrift value: %w", err)
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = binary.ReadUvarint(r); err != nil {
+				return nil, fmt.Errorf("failed to read Thrift value: %w", err)
+			}
+		}
+		if size > uint64(r.Len()) {
+			return nil, fmt.Errorf("Thrift list of %d elements is longer than its struct", size)
+		}
+		elements := make([]any, 0, size)
+		for range size {
+			element, err := readThriftValue(r, header&0x0f)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		return elements, nil
+	case thriftStruct:
+		return readThriftStruct(r)
+	default:
+		return nil, fmt.Errorf("unsupported Thrift type %d", valueType)
+	}
+}
+
+// readZigzag decodes a zigzag encoded integer
+func readZigzag(r *bytes.Reader) (int64, error) {
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read Thrift value: %w", err)
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+// thriftInt returns an integer field of a decoded struct, or def when the
+// struct doesn't have it
+func thriftInt(fields map[int16]any, id int16, def int64) int64 {
+	if v, ok := fields[id].(int64); ok {
+		return v
+	}
+	return def
+}
+
+// thriftString returns a string field of a decoded struct
+func thriftString(fields map[int16]any, id int16) string {
+	v, _ := fields[id].([]byte)
+	return string(v)
+}
+
+// thriftFields returns a struct field of a decoded struct, or nil when the
+// struct doesn't have it
+func thriftFields(fields map[int16]any, id int16) map[int16]any {
+	v, _ := fields[id].(map[int16]any)
+	return v
+}
+
+// thriftStructs returns a list of structs field of a decoded struct
+func thriftStructs(fields map[int16]any, id int16) []map[int16]any {
+	list, _ := fields[id].([]any)
+	structs := make([]map[int16]any, 0, len(list))
+	for _, element := range list {
+		if v, ok := element.(map[int16]any); ok {
+			structs = append(structs, v)
+		}
+	}
+	return structs
+}
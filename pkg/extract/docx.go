@@ -0,0 +1,106 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// docxExtractor extracts the text of Word documents (.docx). Paragraphs
+// styled as headings or titles start sections, and page numbers are counted
+// from the page breaks Word recorded when it last laid the document out, or
+// from hard page breaks when there are none.
+type docxExtractor struct{}
+
+// Extract extracts the text of a Word document
+func (docxExtractor) Extract(data []byte) (*Document, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := a.read("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	renderedBreaks := bytes.Contains(body, []byte("lastRenderedPageBreak"))
+	hasPages := renderedBreaks || bytes.Contains(body, []byte(`type="page"`))
+
+	b := newSectionBuilder()
+	page := 1
+	if hasPages {
+		b.set(MetadataPage, "1")
+	}
+	nextPage := func() {
+		page++
+		b.set(MetadataPage, strconv.Itoa(page))
+	}
+
+	var paragraph strings.Builder
+	var style string
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				paragraph.Reset()
+				style = ""
+			case "pStyle":
+				style = attr(t, "val")
+			case "t":
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return nil, fmt.Errorf("failed to parse document: %w", err)
+				}
+				paragraph.WriteString(text)
+			case "tab":
+				paragraph.WriteByte('\t')
+			case "br", "cr":
+				if attr(t, "type") == "page" {
+					if !renderedBreaks {
+						b.paragraph(paragraph.String())
+						paragraph.Reset()
+						nextPage()
+					}
+					continue
+				}
+				paragraph.WriteByte('\n')
+			case "lastRenderedPageBreak":
+				b.paragraph(paragraph.String())
+				paragraph.Reset()
+				nextPage()
+			}
+		case xml.EndElement:
+			if t.Name.Local != "p" {
+				continue
+			}
+			if isHeadingStyle(style) {
+				b.heading(paragraph.String())
+			} else {
+				b.paragraph(paragraph.String())
+			}
+			paragraph.Reset()
+		}
+	}
+
+	return b.document()
+}
+
+// isHeadingStyle reports whether a Word paragraph style is a heading, e.g.
+// Heading1 or Title
+func isHeadingStyle(style string) bool {
+	style = strings.ToLower(style)
+	return strings.HasPrefix(style, "heading") || style == "title"
+}
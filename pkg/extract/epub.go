@@ -0,0 +1,181 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// epubExtractor extracts the text of EPUB ebooks (.epub). Each document of
+// the reading order is a chapter, and headings start sections within it.
+type epubExtractor struct{}
+
+// blockElements are the HTML elements that end a paragraph
+var blockElements = map[string]bool{
+	"p": true, "div": true, "li": true, "dt": true, "dd": true, "tr": true,
+	"blockquote": true, "pre": true, "section": true, "article": true,
+	"table": true, "ul": true, "ol": true, "dl": true, "figcaption": true,
+}
+
+// Extract extracts the text of an ebook
+func (epubExtractor) Extract(data []byte) (*Document, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	chapters, err := spine(a)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newSectionBuilder()
+	for i, chapter := range chapters {
+		content, err := a.read(chapter)
+		if err != nil {
+			return nil, err
+		}
+		b.set(MetadataChapter, strconv.Itoa(i+1))
+		b.set(MetadataHeading, "")
+		if err := chapterText(b, content); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", chapter, err)
+		}
+	}
+	return b.document()
+}
+
+// spine returns the documents of an ebook in reading order
+func spine(a *archive) ([]string, error) {
+	container, err := a.read("META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	var rootfiles struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(container, &rootfiles); err != nil {
+		return nil, fmt.Errorf("failed to parse container: %w", err)
+	}
+	if len(rootfiles.Rootfiles) == 0 {
+		return nil, fmt.Errorf("container has no package document")
+	}
+
+	opfPath := rootfiles.Rootfiles[0].FullPath
+	opf, err := a.read(opfPath)
+	if err != nil {
+		return nil, err
+	}
+	var pkg struct {
+		Manifest []struct {
+			ID   string `xml:"id,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal(opf, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package document: %w", err)
+	}
+
+	// Manifest paths are relative to the package document
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		href, err := url.PathUnescape(item.Href)
+		if err != nil {
+			href = item.Href
+		}
+		hrefs[item.ID] = path.Join(path.Dir(opfPath), href)
+	}
+
+	var chapters []string
+	for _, item := range pkg.Spine {
+		if href, ok := hrefs[item.IDRef]; ok && a.has(href) {
+			chapters = append(chapters, href)
+		}
+	}
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("no chapters found")
+	}
+	return chapters, nil
+}
+
+// chapterText adds the text of a chapter's body to the builder
+func chapterText(b *sectionBuilder, content []byte) error {
+	var paragraph strings.Builder
+	inBody := false
+	skip := 0    // Depth inside elements whose text isn't shown, such as scripts
+	heading := 0 // Depth inside headings
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "body":
+				inBody = true
+			case name == "script" || name == "style":
+				skip++
+			case isHTMLHeading(name):
+				b.paragraph(paragraph.String())
+				paragraph.Reset()
+				heading++
+			case name == "br":
+				paragraph.WriteByte('\n')
+			case blockElements[name] && heading == 0:
+				b.paragraph(paragraph.String())
+				paragraph.Reset()
+			}
+		case xml.CharData:
+			if inBody && skip == 0 {
+				paragraph.Write(t)
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "body":
+				inBody = false
+			case name == "script" || name == "style":
+				if skip > 0 {
+					skip--
+				}
+			case isHTMLHeading(name):
+				if heading > 0 {
+					heading--
+				}
+				if heading == 0 {
+					b.heading(paragraph.String())
+					paragraph.Reset()
+				}
+			case blockElements[name] && heading == 0:
+				b.paragraph(paragraph.String())
+				paragraph.Reset()
+			}
+		}
+	}
+	b.paragraph(paragraph.String())
+	return nil
+}
+
+// isHTMLHeading reports whether an HTML element is a heading, h1 to h6
+func isHTMLHeading(name string) bool {
+	return len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6'
+}
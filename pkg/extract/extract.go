@@ -0,0 +1,240 @@
+// Package extract reads the text of document formats that aren't plain text,
+// such as Office documents and ebooks, split into sections that record where
+// in the document their text is, for citations.
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Section metadata keys
+const (
+	MetadataPage    = "page"    // Page number, in documents with page breaks
+	MetadataSlide   = "slide"   // Slide number, in presentations
+	MetadataChapter = "chapter" // Chapter number, in ebooks
+	MetadataHeading = "heading" // Heading of the section
+	MetadataSymbol  = "symbol"  // Functions, types and classes defined, in source code, separated by commas
+)
+
+// MetadataKeys are the metadata keys sections may have
+var MetadataKeys = []string{MetadataPage, MetadataSlide, MetadataChapter, MetadataHeading, MetadataSymbol}
+
+// maxPartSize is the most bytes read from one part of a document archive, so
+// a small file can't decompress into an arbitrarily large one
+const maxPartSize = 64 << 20
+
+// Section is a part of a document with its own location, such as a page,
+// slide or chapter, or the text under a heading
+type Section struct {
+	Text     string
+	Metadata map[string]string // Location of the section, by the Metadata keys
+}
+
+// Document is the text extracted from a file
+type Document struct {
+	Sections []Section
+}
+
+// Text returns the text of every section, separated by blank lines
+func (d *Document) Text() string {
+	texts := make([]string, len(d.Sections))
+	for i, section := range d.Sections {
+		texts[i] = section.Text
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// Extractor extracts the text of a file format
+type Extractor interface {
+	Extract(data []byte) (*Document, error)
+}
+
+// extractors are the extractors by file extension
+var extractors = map[string]Extractor{
+	".docx": docxExtractor{},
+	".odt":  odtExtractor{},
+	".pptx": pptxExtractor{},
+	".epub": epubExtractor{},
+}
+
+// For returns the extractor for a file, by its extension, or nil when the
+// file's format has none
+func For(filePath string) Extractor {
+	return extractors[strings.ToLower(path.Ext(filePath))]
+}
+
+// Supported reports whether the text of a file can be extracted
+func Supported(filePath string) bool {
+	return For(filePath) != nil
+}
+
+// Extensions returns the file extensions with an extractor, sorted
+func Extensions() []string {
+	extensions := make([]string, 0, len(extractors))
+	for extension := range extractors {
+		extensions = append(extensions, extension)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+// Location describes where a chunk is in its document from its metadata,
+// e.g. `page 3, "Installation"` or `symbol Store.Get`, or returns an empty
+// string when the metadata has no location
+func Location(metadata map[string]string) string {
+	var parts []string
+	for _, key := range []string{MetadataPage, MetadataSlide, MetadataChapter} {
+		if value := metadata[key]; value != "" {
+			parts = append(parts, key+" "+value)
+		}
+	}
+	if heading := metadata[MetadataHeading]; heading != "" {
+		parts = append(parts, fmt.Sprintf("%q", heading))
+	}
+	if symbol := metadata[MetadataSymbol]; strings.Contains(symbol, ",") {
+		parts = append(parts, "symbols "+symbol)
+	} else if symbol != "" {
+		parts = append(parts, "symbol "+symbol)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// archive is a document stored as a zip file of parts
+type archive struct {
+	files map[string]*zip.File
+}
+
+// openArchive opens a document stored as a zip file
+func openArchive(data []byte) (*archive, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	a := &archive{files: make(map[string]*zip.File, len(reader.File))}
+	for _, file := range reader.File {
+		a.files[file.Name] = file
+	}
+	return a, nil
+}
+
+// has reports whether the archive has a part
+func (a *archive) has(name string) bool {
+	_, ok := a.files[name]
+	return ok
+}
+
+// read returns the content of a part
+func (a *archive) read(name string) ([]byte, error) {
+	file, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxPartSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxPartSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", name, maxPartSize)
+	}
+	return data, nil
+}
+
+// attr returns the value of an element's attribute, by its local name
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// sectionBuilder collects the paragraphs of a document into sections. A new
+// section starts whenever the location changes.
+type sectionBuilder struct {
+	sections []Section
+	text     strings.Builder
+	metadata map[string]string
+}
+
+// newSectionBuilder creates a builder whose first section has no location
+func newSectionBuilder() *sectionBuilder {
+	return &sectionBuilder{metadata: make(map[string]string)}
+}
+
+// set changes a part of the location, starting a new section if the current
+// one has text
+func (b *sectionBuilder) set(key, value string) {
+	if b.metadata[key] == value {
+		return
+	}
+	b.flush()
+	metadata := make(map[string]string, len(b.metadata)+1)
+	for k, v := range b.metadata {
+		metadata[k] = v
+	}
+	if value == "" {
+		delete(metadata, key)
+	} else {
+		metadata[key] = value
+	}
+	b.metadata = metadata
+}
+
+// heading starts a section under a heading, whose text is part of it
+func (b *sectionBuilder) heading(text string) {
+	text = normalizeSpace(text)
+	if text == "" {
+		return
+	}
+	b.set(MetadataHeading, text)
+	b.paragraph(text)
+}
+
+// paragraph adds a paragraph to the current section
+func (b *sectionBuilder) paragraph(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if b.text.Len() > 0 {
+		b.text.WriteString("\n\n")
+	}
+	b.text.WriteString(text)
+}
+
+// flush ends the current section, if it has text
+func (b *sectionBuilder) flush() {
+	if b.text.Len() == 0 {
+		return
+	}
+	b.sections = append(b.sections, Section{Text: b.text.String(), Metadata: b.metadata})
+	b.text.Reset()
+}
+
+// document returns the document made of the sections
+func (b *sectionBuilder) document() (*Document, error) {
+	b.flush()
+	if len(b.sections) == 0 {
+		return nil, fmt.Errorf("no text found")
+	}
+	return &Document{Sections: b.sections}, nil
+}
+
+// normalizeSpace replaces runs of whitespace with single spaces
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,135 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipFile builds a zip archive with the given parts
+func zipFile(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestFor(t *testing.T) {
+	assert.True(t, Supported("docs/Report.DOCX"))
+	assert.True(t, Supported("book.epub"))
+	assert.False(t, Supported("README.md"))
+	assert.Nil(t, For("notes.txt"))
+	assert.Equal(t, []string{".docx", ".epub", ".odt", ".pptx"}, Extensions())
+}
+
+func TestLocation(t *testing.T) {
+	assert.Equal(t, `page 3, "Installation"`, Location(map[string]string{"page": "3", "heading": "Installation", "file_name": "a.docx"}))
+	assert.Equal(t, "slide 2", Location(map[string]string{"slide": "2"}))
+	assert.Equal(t, "symbol Store.Get", Location(map[string]string{"symbol": "Store.Get"}))
+	assert.Equal(t, "symbols New, Store", Location(map[string]string{"symbol": "New, Store"}))
+	assert.Empty(t, Location(map[string]string{"file_name": "a.md"}))
+}
+
+func TestDocx(t *testing.T) {
+	data := zipFile(t, map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr><w:r><w:t>Backups</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Backups run </w:t></w:r><w:r><w:t>nightly.</w:t></w:r></w:p>
+<w:p><w:r><w:br w:type="page"/></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Retention</w:t></w:r></w:p>
+<w:p><w:r><w:t>Kept for</w:t><w:tab/><w:t>30 days.</w:t></w:r></w:p>
+</w:body></w:document>`,
+	})
+
+	doc, err := For("backups.docx").Extract(data)
+	require.NoError(t, err)
+	require.Len(t, doc.Sections, 2)
+	assert.Equal(t, Section{Text: "Backups\n\nBackups run nightly.", Metadata: map[string]string{"page": "1", "heading": "Backups"}}, doc.Sections[0])
+	assert.Equal(t, Section{Text: "Retention\n\nKept for\t30 days.", Metadata: map[string]string{"page": "2", "heading": "Retention"}}, doc.Sections[1])
+	assert.Equal(t, "Backups\n\nBackups run nightly.\n\nRetention\n\nKept for\t30 days.", doc.Text())
+}
+
+func TestODT(t *testing.T) {
+	data := zipFile(t, map[string]string{
+		"content.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"><office:body><office:text>
+<text:h text:outline-level="1">Onboarding</text:h>
+<text:p>New hires get<text:s text:c="2"/>a laptop.</text:p>
+<text:soft-page-break/>
+<text:p>Accounts are created on day one.</text:p>
+</office:text></office:body></office:document-content>`,
+	})
+
+	doc, err := For("onboarding.odt").Extract(data)
+	require.NoError(t, err)
+	require.Len(t, doc.Sections, 2)
+	assert.Equal(t, Section{Text: "Onboarding\n\nNew hires get  a laptop.", Metadata: map[string]string{"page": "1", "heading": "Onboarding"}}, doc.Sections[0])
+	assert.Equal(t, Section{Text: "Accounts are created on day one.", Metadata: map[string]string{"page": "2", "heading": "Onboarding"}}, doc.Sections[1])
+}
+
+func TestPPTX(t *testing.T) {
+	slide := func(title, body string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?>
+<p:sld xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"><p:cSld><p:spTree>
+<p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + title + `</a:t></a:r></a:p></p:txBody></p:sp>
+<p:sp><p:txBody><a:p><a:r><a:t>` + body + `</a:t></a:r></a:p></p:txBody></p:sp>
+</p:spTree></p:cSld></p:sld>`
+	}
+	data := zipFile(t, map[string]string{
+		"ppt/presentation.xml": `<p:presentation xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<p:sldIdLst><p:sldId id="256" r:id="rId3"/><p:sldId id="257" r:id="rId2"/></p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId2" Target="slides/slide1.xml"/><Relationship Id="rId3" Target="slides/slide2.xml"/></Relationships>`,
+		"ppt/slides/slide1.xml": slide("Deploys", "Deploys happen on Tuesdays."),
+		"ppt/slides/slide2.xml": slide("Agenda", "Deploys and rollbacks."),
+	})
+
+	// Slides are in presentation order, not by their part names
+	doc, err := For("deploys.pptx").Extract(data)
+	require.NoError(t, err)
+	require.Len(t, doc.Sections, 2)
+	assert.Equal(t, Section{Text: "Agenda\n\nDeploys and rollbacks.", Metadata: map[string]string{"slide": "1", "heading": "Agenda"}}, doc.Sections[0])
+	assert.Equal(t, Section{Text: "Deploys\n\nDeploys happen on Tuesdays.", Metadata: map[string]string{"slide": "2", "heading": "Deploys"}}, doc.Sections[1])
+}
+
+func TestEPUB(t *testing.T) {
+	data := zipFile(t, map[string]string{
+		"META-INF/container.xml": `<container xmlns="urn:oasis:names:tc:opendocument:xmlns:container" version="1.0">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`,
+		"OEBPS/content.opf": `<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<manifest><item id="c1" href="text/chapter%201.xhtml"/><item id="c2" href="text/chapter2.xhtml"/></manifest>
+<spine><itemref idref="c2"/><itemref idref="c1"/></spine></package>`,
+		"OEBPS/text/chapter 1.xhtml": `<html><head><title>Ignored</title><style>p { color: red; }</style></head>
+<body><h1>The End</h1><p>It was over&nbsp;at last.<br>Really.</p></body></html>`,
+		"OEBPS/text/chapter2.xhtml": `<html><body><p>Before any heading.</p><h2>The <em>Start</em></h2><div>It began.</div><script>var x = 1;</script></body></html>`,
+	})
+
+	doc, err := For("book.epub").Extract(data)
+	require.NoError(t, err)
+	require.Len(t, doc.Sections, 3)
+	assert.Equal(t, Section{Text: "Before any heading.", Metadata: map[string]string{"chapter": "1"}}, doc.Sections[0])
+	assert.Equal(t, Section{Text: "The Start\n\nIt began.", Metadata: map[string]string{"chapter": "1", "heading": "The Start"}}, doc.Sections[1])
+	assert.Equal(t, Section{Text: "The End\n\nIt was over at last.\nReally.", Metadata: map[string]string{"chapter": "2", "heading": "The End"}}, doc.Sections[2])
+}
+
+func TestExtractErrors(t *testing.T) {
+	_, err := For("a.docx").Extract([]byte("not a zip file"))
+	assert.Error(t, err)
+
+	_, err = For("a.docx").Extract(zipFile(t, map[string]string{"other.xml": "<x/>"}))
+	assert.ErrorContains(t, err, "word/document.xml is missing")
+
+	_, err = For("a.odt").Extract(zipFile(t, map[string]string{"content.xml": "<office:document-content/>"}))
+	assert.ErrorContains(t, err, "no text found")
+}
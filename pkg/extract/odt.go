@@ -0,0 +1,104 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// odtExtractor extracts the text of OpenDocument text documents (.odt).
+// Headings start sections, and page numbers are counted from the page breaks
+// the editor recorded when it last laid the document out.
+type odtExtractor struct{}
+
+// Extract extracts the text of an OpenDocument text document
+func (odtExtractor) Extract(data []byte) (*Document, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	content, err := a.read("content.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	b := newSectionBuilder()
+	page := 1
+	if bytes.Contains(content, []byte("soft-page-break")) {
+		b.set(MetadataPage, "1")
+	}
+
+	// Paragraphs can hold others, such as the text of notes; their text is
+	// part of the outermost paragraph
+	var paragraph strings.Builder
+	depth := 0
+	isHeading := false
+	pendingPages := 0 // Page breaks inside the current paragraph
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p", "h":
+				if depth == 0 {
+					paragraph.Reset()
+					isHeading = t.Name.Local == "h"
+				}
+				depth++
+			case "s":
+				count, err := strconv.Atoi(attr(t, "c"))
+				if err != nil || count < 1 {
+					count = 1
+				}
+				paragraph.WriteString(strings.Repeat(" ", count))
+			case "tab":
+				paragraph.WriteByte('\t')
+			case "line-break":
+				paragraph.WriteByte('\n')
+			case "soft-page-break":
+				// Paragraphs are cited by the page they start on
+				if depth > 0 {
+					pendingPages++
+					continue
+				}
+				page++
+				b.set(MetadataPage, strconv.Itoa(page))
+			}
+		case xml.CharData:
+			if depth > 0 {
+				paragraph.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local != "p" && t.Name.Local != "h" {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if isHeading {
+				b.heading(paragraph.String())
+			} else {
+				b.paragraph(paragraph.String())
+			}
+			if pendingPages > 0 {
+				page += pendingPages
+				pendingPages = 0
+				b.set(MetadataPage, strconv.Itoa(page))
+			}
+		}
+	}
+
+	return b.document()
+}
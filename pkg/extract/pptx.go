@@ -0,0 +1,185 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pptxExtractor extracts the text of PowerPoint presentations (.pptx). Each
+// slide is a section, headed by its title.
+type pptxExtractor struct{}
+
+// slidePattern matches the names of slide parts
+var slidePattern = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// Extract extracts the text of a presentation
+func (pptxExtractor) Extract(data []byte) (*Document, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	slides, err := slideOrder(a)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newSectionBuilder()
+	for i, slide := range slides {
+		content, err := a.read(slide)
+		if err != nil {
+			return nil, err
+		}
+		title, paragraphs, err := slideText(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", slide, err)
+		}
+
+		b.set(MetadataSlide, strconv.Itoa(i+1))
+		b.set(MetadataHeading, normalizeSpace(title))
+		for _, paragraph := range paragraphs {
+			b.paragraph(paragraph)
+		}
+	}
+	return b.document()
+}
+
+// slideOrder returns the slide parts in the order of the presentation, or
+// by their number when the presentation doesn't list them
+func slideOrder(a *archive) ([]string, error) {
+	if a.has("ppt/presentation.xml") && a.has("ppt/_rels/presentation.xml.rels") {
+		presentation, err := a.read("ppt/presentation.xml")
+		if err != nil {
+			return nil, err
+		}
+		rels, err := a.read("ppt/_rels/presentation.xml.rels")
+		if err != nil {
+			return nil, err
+		}
+
+		var relationships struct {
+			Relationships []struct {
+				ID     string `xml:"Id,attr"`
+				Target string `xml:"Target,attr"`
+			} `xml:"Relationship"`
+		}
+		if err := xml.Unmarshal(rels, &relationships); err != nil {
+			return nil, fmt.Errorf("failed to parse presentation relationships: %w", err)
+		}
+		targets := make(map[string]string, len(relationships.Relationships))
+		for _, rel := range relationships.Relationships {
+			targets[rel.ID] = path.Join("ppt", rel.Target)
+		}
+
+		var slides []string
+		decoder := xml.NewDecoder(bytes.NewReader(presentation))
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse presentation: %w", err)
+			}
+			t, ok := token.(xml.StartElement)
+			if !ok || t.Name.Local != "sldId" {
+				continue
+			}
+			// The relationship is r:id; the plain id numbers the slide
+			for _, relationship := range t.Attr {
+				if relationship.Name.Local != "id" || relationship.Name.Space == "" {
+					continue
+				}
+				if target, ok := targets[relationship.Value]; ok && a.has(target) {
+					slides = append(slides, target)
+				}
+			}
+		}
+		if len(slides) > 0 {
+			return slides, nil
+		}
+	}
+
+	var slides []string
+	numbers := make(map[string]int)
+	for name := range a.files {
+		if match := slidePattern.FindStringSubmatch(name); match != nil {
+			numbers[name], _ = strconv.Atoi(match[1])
+			slides = append(slides, name)
+		}
+	}
+	if len(slides) == 0 {
+		return nil, fmt.Errorf("no slides found")
+	}
+	sort.Slice(slides, func(i, j int) bool {
+		return numbers[slides[i]] < numbers[slides[j]]
+	})
+	return slides, nil
+}
+
+// slideText returns the title of a slide and the paragraphs of its shapes
+// and tables
+func slideText(content []byte) (string, []string, error) {
+	var title string
+	var paragraphs, shape []string
+	var paragraph strings.Builder
+	inShape, isTitle := false, false
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sp":
+				inShape, isTitle, shape = true, false, nil
+			case "ph":
+				placeholder := attr(t, "type")
+				isTitle = isTitle || placeholder == "title" || placeholder == "ctrTitle"
+			case "p":
+				paragraph.Reset()
+			case "t":
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return "", nil, err
+				}
+				paragraph.WriteString(text)
+			case "br":
+				paragraph.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				text := strings.TrimSpace(paragraph.String())
+				if text == "" {
+					continue
+				}
+				if inShape {
+					shape = append(shape, text)
+				} else {
+					paragraphs = append(paragraphs, text)
+				}
+			case "sp":
+				if isTitle && title == "" {
+					title = strings.Join(shape, " ")
+				}
+				paragraphs = append(paragraphs, shape...)
+				inShape = false
+			}
+		}
+	}
+	return title, paragraphs, nil
+}
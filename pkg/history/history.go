@@ -0,0 +1,173 @@
+// Package history keeps long chat conversations within the context of the
+// chat model by replacing their older turns with a summary.
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// summaryPrefix starts the message that holds the summary of older turns
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// summarizePrompt asks the chat model for a summary of a conversation
+const summarizePrompt = `Summarize the conversation below between a user and an assistant that answers questions from documents.
+Keep the facts, decisions, names, file names and open questions needed to continue the conversation.
+Write the summary in at most %d words and reply with nothing else.
+
+%s`
+
+// Service summarizes the older turns of conversations that get too long
+type Service struct {
+	chat   client.Client
+	config *config.HistoryConfig
+}
+
+// New creates a new history service
+func New(chat client.Client, config *config.HistoryConfig) *Service {
+	return &Service{
+		chat:   chat,
+		config: config,
+	}
+}
+
+// EstimateTokens estimates the number of tokens of messages with the chat
+// model's tokenizer (see client.UseTokenCounter)
+func EstimateTokens(messages []client.Message) int {
+	return client.EstimateMessageTokens(messages)
+}
+
+// IsSummary reports whether a message is the summary of older turns
+func IsSummary(m client.Message) bool {
+	return m.Role == "system" && strings.HasPrefix(m.Content, summaryPrefix)
+}
+
+// Compact returns the conversation unchanged while it is shorter than the
+// summary threshold. A longer conversation is returned as a summary of its
+// older turns, including an earlier summary, followed by the most recent
+// turns. The chat model writes the summary, using model unless the history
+// settings name another one.
+func (s *Service) Compact(ctx context.Context, model string, conversation []client.Message) ([]client.Message, error) {
+	if !s.config.Summarize || EstimateTokens(conversation) <= s.config.GetSummarizeAfter() {
+		return conversation, nil
+	}
+	return s.summarizeOlder(ctx, model, conversation, s.config.GetSummarizeAfter())
+}
+
+// Fit returns the conversation unchanged while it fits in maxTokens. A
+// longer conversation has its older turns summarized, when summaries are
+// enabled, and then loses its oldest turns until it fits. When the summary
+// fails, the conversation is trimmed along with the error.
+func (s *Service) Fit(ctx context.Context, model string, conversation []client.Message, maxTokens int) ([]client.Message, error) {
+	if EstimateTokens(conversation) <= maxTokens {
+		return conversation, nil
+	}
+
+	var err error
+	if s.config.Summarize && maxTokens > 0 {
+		var summarized []client.Message
+		summarized, err = s.summarizeOlder(ctx, model, conversation, min(maxTokens, s.config.GetSummarizeAfter()))
+		if err == nil {
+			conversation = summarized
+		}
+	}
+	return Trim(conversation, maxTokens), err
+}
+
+// Trim leaves out the oldest turns of a conversation, an earlier summary
+// first, until it fits in maxTokens
+func Trim(conversation []client.Message, maxTokens int) []client.Message {
+	for len(conversation) > 0 && EstimateTokens(conversation) > maxTokens {
+		conversation = conversation[1:]
+		for len(conversation) > 0 && conversation[0].Role != "user" {
+			conversation = conversation[1:]
+		}
+	}
+	return conversation
+}
+
+// summarizeOlder replaces the older turns of a conversation, including an
+// earlier summary, with a summary that takes up about half of budget tokens
+func (s *Service) summarizeOlder(ctx context.Context, model string, conversation []client.Message, budget int) ([]client.Message, error) {
+	split := s.splitIndex(conversation)
+	older, recent := conversation[:split], conversation[split:]
+	if len(older) == 0 || (len(older) == 1 && IsSummary(older[0])) {
+		// Only recent turns, which are kept whatever their length
+		return conversation, nil
+	}
+
+	summary, err := s.summarize(ctx, model, older, budget)
+	if err != nil {
+		return conversation, err
+	}
+
+	compacted := make([]client.Message, 0, len(recent)+1)
+	compacted = append(compacted, client.Message{Role: "system", Content: summaryPrefix + summary})
+	compacted = append(compacted, recent...)
+	return compacted, nil
+}
+
+// splitIndex returns the index of the first message of the turns that are
+// kept word for word. A turn starts with a user message.
+func (s *Service) splitIndex(conversation []client.Message) int {
+	turns := 0
+	for i := len(conversation) - 1; i >= 0; i-- {
+		if conversation[i].Role != "user" {
+			continue
+		}
+		turns++
+		if turns == s.config.GetKeepTurns() {
+			return i
+		}
+	}
+	return 0
+}
+
+// summarize asks the chat model for a summary of messages that takes up
+// about half of budget tokens
+func (s *Service) summarize(ctx context.Context, model string, messages []client.Message, budget int) (string, error) {
+	if s.config.Model != "" {
+		model = s.config.Model
+	}
+
+	// The summary may take up about half of the budget, in words
+	maxWords := max(budget*3/8, 1)
+	request := []client.Message{
+		{Role: "system", Content: "You summarize conversations so they can be continued without the full transcript."},
+		{Role: "user", Content: fmt.Sprintf(summarizePrompt, maxWords, formatTranscript(messages))},
+	}
+
+	response, err := s.chat.Chat(ctx, model, request, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+
+	summary := client.StripThinking(response.Message.Content)
+	if summary == "" {
+		return "", fmt.Errorf("failed to summarize conversation: the chat model returned an empty summary")
+	}
+	return summary, nil
+}
+
+// formatTranscript formats messages as a transcript for the summary prompt
+func formatTranscript(messages []client.Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		switch {
+		case IsSummary(m):
+			b.WriteString(strings.TrimSpace(m.Content))
+		case m.Role == "user":
+			b.WriteString("User: " + strings.TrimSpace(m.Content))
+		case m.Role == "assistant":
+			b.WriteString("Assistant: " + client.StripThinking(m.Content))
+		default:
+			continue
+		}
+		b.WriteString("\n\n")
+	}
+	return strings.TrimSpace(b.String())
+}
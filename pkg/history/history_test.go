@@ -0,0 +1,185 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockChat returns a fixed chat response
+type mockChat struct {
+	client.Client
+	response string
+	err      error
+	model    string
+	messages []client.Message
+}
+
+func (m *mockChat) Chat(ctx context.Context, model string, messages []client.Message, stream bool) (*client.ChatResponse, error) {
+	m.model = model
+	m.messages = messages
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &client.ChatResponse{Message: client.Message{Role: "assistant", Content: m.response}}, nil
+}
+
+// turns returns a conversation of n turns with answers of the given length
+func turns(n, answerLength int) []client.Message {
+	var conversation []client.Message
+	for i := 0; i < n; i++ {
+		conversation = append(conversation,
+			client.Message{Role: "user", Content: "question " + string(rune('a'+i))},
+			client.Message{Role: "assistant", Content: strings.Repeat("x", answerLength)},
+		)
+	}
+	return conversation
+}
+
+func TestCompactKeepsShortConversations(t *testing.T) {
+	chat := &mockChat{response: "summary"}
+	service := New(chat, &config.HistoryConfig{Summarize: true, SummarizeAfter: 1000})
+
+	conversation := turns(3, 100)
+	compacted, err := service.Compact(context.Background(), "model", conversation)
+	require.NoError(t, err)
+	assert.Equal(t, conversation, compacted)
+	assert.Nil(t, chat.messages, "the chat model should not be asked")
+}
+
+func TestCompactSummarizesOlderTurns(t *testing.T) {
+	chat := &mockChat{response: "<think>hmm</think>\nThe user asked about a, b and c."}
+	service := New(chat, &config.HistoryConfig{Summarize: true, SummarizeAfter: 100, KeepTurns: 2})
+
+	conversation := turns(5, 200)
+	compacted, err := service.Compact(context.Background(), "qwen3", conversation)
+	require.NoError(t, err)
+
+	require.Len(t, compacted, 5)
+	assert.True(t, IsSummary(compacted[0]))
+	assert.Equal(t, summaryPrefix+"The user asked about a, b and c.", compacted[0].Content)
+	assert.Equal(t, conversation[6:], compacted[1:], "the last 2 turns should be kept")
+	assert.Equal(t, "qwen3", chat.model)
+
+	prompt := chat.messages[1].Content
+	assert.Contains(t, prompt, "User: question a")
+	assert.Contains(t, prompt, "User: question c")
+	assert.NotContains(t, prompt, "question d")
+
+	// The next summary includes the earlier one
+	chat.response = "Everything so far."
+	compacted = append(compacted, turns(2, 200)...)
+	compacted, err = service.Compact(context.Background(), "qwen3", compacted)
+	require.NoError(t, err)
+	assert.Contains(t, chat.messages[1].Content, "The user asked about a, b and c.")
+	assert.Equal(t, summaryPrefix+"Everything so far.", compacted[0].Content)
+	assert.Len(t, compacted, 5)
+}
+
+func TestCompactKeepsRecentTurns(t *testing.T) {
+	chat := &mockChat{response: "summary"}
+	service := New(chat, &config.HistoryConfig{Summarize: true, SummarizeAfter: 10, KeepTurns: 2})
+
+	// Two long turns are kept word for word, even over the threshold
+	conversation := turns(2, 1000)
+	compacted, err := service.Compact(context.Background(), "model", conversation)
+	require.NoError(t, err)
+	assert.Equal(t, conversation, compacted)
+	assert.Nil(t, chat.messages)
+}
+
+func TestCompactUsesSummaryModel(t *testing.T) {
+	chat := &mockChat{response: "summary"}
+	service := New(chat, &config.HistoryConfig{Summarize: true, SummarizeAfter: 10, KeepTurns: 1, Model: "small"})
+
+	_, err := service.Compact(context.Background(), "large", turns(3, 100))
+	require.NoError(t, err)
+	assert.Equal(t, "small", chat.model)
+}
+
+func TestCompactFailure(t *testing.T) {
+	chat := &mockChat{err: errors.New("connection refused")}
+	service := New(chat, &config.HistoryConfig{Summarize: true, SummarizeAfter: 10})
+
+	conversation := turns(4, 100)
+	compacted, err := service.Compact(context.Background(), "model", conversation)
+	assert.Error(t, err)
+	assert.Equal(t, conversation, compacted, "the whole conversation should be kept")
+
+	chat.err = nil
+	chat.response = "<think>only thoughts</think>"
+	_, err = service.Compact(context.Background(), "model", conversation)
+	assert.Error(t, err)
+}
+
+func TestCompactDisabled(t *testing.T) {
+	chat := &mockChat{response: "summary"}
+	service := New(chat, &config.HistoryConfig{SummarizeAfter: 10})
+
+	conversation := turns(5, 100)
+	compacted, err := service.Compact(context.Background(), "model", conversation)
+	require.NoError(t, err)
+	assert.Equal(t, conversation, compacted)
+}
+
+func TestFitKeepsConversationsWithinBudget(t *testing.T) {
+	chat := &mockChat{response: "summary"}
+	service := New(chat, &config.HistoryConfig{Summarize: true, SummarizeAfter: 10})
+
+	conversation := turns(4, 200)
+	fitted, err := service.Fit(context.Background(), "model", conversation, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, conversation, fitted)
+	assert.Nil(t, chat.messages, "the chat model should not be asked")
+}
+
+func TestFitSummarizesOlderTurns(t *testing.T) {
+	chat := &mockChat{response: "short summary"}
+	service := New(chat, &config.HistoryConfig{Summarize: true, SummarizeAfter: 3000, KeepTurns: 2})
+
+	// Four turns of 61 tokens; the summary and the last two turns take 130
+	conversation := turns(4, 200)
+	fitted, err := service.Fit(context.Background(), "model", conversation, 140)
+	require.NoError(t, err)
+	require.Len(t, fitted, 5)
+	assert.True(t, IsSummary(fitted[0]))
+	assert.Equal(t, conversation[4:], fitted[1:])
+	assert.Contains(t, chat.messages[1].Content, "at most 52 words", "the summary should fit the budget")
+
+	// Without room for the summary, the oldest turns are left out too
+	fitted, err = service.Fit(context.Background(), "model", conversation, 100)
+	require.NoError(t, err)
+	assert.Equal(t, conversation[6:], fitted)
+}
+
+func TestFitTrimsWhenSummaryFails(t *testing.T) {
+	chat := &mockChat{err: errors.New("connection refused")}
+	service := New(chat, &config.HistoryConfig{Summarize: true, SummarizeAfter: 3000, KeepTurns: 1})
+
+	conversation := turns(4, 200)
+	fitted, err := service.Fit(context.Background(), "model", conversation, 130)
+	assert.Error(t, err)
+	assert.Equal(t, conversation[4:], fitted)
+}
+
+func TestTrim(t *testing.T) {
+	conversation := append([]client.Message{{Role: "system", Content: summaryPrefix + "earlier"}}, turns(3, 200)...)
+
+	assert.Equal(t, conversation, Trim(conversation, 1000))
+	assert.Equal(t, conversation[1:], Trim(conversation, 183), "the summary should go first")
+	assert.Equal(t, conversation[3:], Trim(conversation, 130), "whole turns should be left out")
+	assert.Empty(t, Trim(conversation, 10))
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 0, EstimateTokens(nil))
+	// Each message takes 4 tokens besides its content
+	assert.Equal(t, 13, EstimateTokens([]client.Message{{Content: "hello"}, {Content: "world!"}}))
+	assert.Equal(t, 6, EstimateTokens([]client.Message{{Content: "héllo"}}), "characters are counted, not bytes")
+}
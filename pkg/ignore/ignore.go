@@ -0,0 +1,248 @@
+// Package ignore decides which files of a folder are left out of indexing,
+// from the .gitignore and .ragignore files in the folder and from extra
+// patterns, all in the gitignore format.
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ignore file names
+const (
+	GitIgnoreFile = ".gitignore"
+	RagIgnoreFile = ".ragignore" // Files left out of indexing but not of git
+)
+
+// rule is a pattern of an ignore file
+type rule struct {
+	base    string // Directory of the ignore file, relative to the root ("" for the root)
+	re      *regexp.Regexp
+	negate  bool // The pattern starts with !, re-including what it matches
+	dirOnly bool // The pattern ends with /, matching only directories
+}
+
+// Matcher matches paths against the ignore files of a folder and extra
+// patterns. As in git, the last matching pattern decides, patterns of
+// deeper ignore files come after those of the folders above them, and the
+// extra patterns come last.
+type Matcher struct {
+	root     string
+	files    []string // Names of the ignore files read in every directory
+	rules    []rule
+	patterns []rule
+}
+
+// New creates a matcher for the folder root, reading the ignore files with
+// the given names and adding the extra patterns, which are relative to the
+// root. The ignore files of the root are read; those of its subdirectories
+// are read by AddDir as they are walked.
+func New(root string, files []string, patterns []string) (*Matcher, error) {
+	m := &Matcher{root: root, files: files}
+	for _, pattern := range patterns {
+		r, ok, err := parseRule("", pattern)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.patterns = append(m.patterns, r)
+		}
+	}
+	if err := m.AddDir(""); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AddDir reads the ignore files of a directory, relative to the root with /
+// separators
+func (m *Matcher) AddDir(dir string) error {
+	for _, name := range m.files {
+		file := filepath.Join(m.root, filepath.FromSlash(dir), name)
+		rules, err := readRules(dir, file)
+		if err != nil {
+			return err
+		}
+		m.rules = append(m.rules, rules...)
+	}
+	return nil
+}
+
+// Ignored reports whether a path, relative to the root with / separators,
+// is ignored. .git directories are always ignored.
+func (m *Matcher) Ignored(relativePath string, isDir bool) bool {
+	if isDir && path.Base(relativePath) == ".git" {
+		return true
+	}
+
+	ignored := false
+	for _, rules := range [][]rule{m.rules, m.patterns} {
+		for _, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			sub := relativePath
+			if r.base != "" {
+				if !strings.HasPrefix(relativePath, r.base+"/") {
+					continue
+				}
+				sub = relativePath[len(r.base)+1:]
+			}
+			if r.re.MatchString(sub) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// Walk walks the files of the root like filepath.WalkDir, reading the ignore
+// files of each directory and skipping the directories and files they
+// ignore. fn is only called for files.
+func (m *Matcher) Walk(fn func(path string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(m.root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(m.root, filePath)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		if d.IsDir() {
+			if relativePath == "." {
+				return nil
+			}
+			if m.Ignored(relativePath, true) {
+				return filepath.SkipDir
+			}
+			return m.AddDir(relativePath)
+		}
+		if m.Ignored(relativePath, false) {
+			return nil
+		}
+		return fn(filePath, d)
+	})
+}
+
+// ValidatePattern checks that a pattern in the gitignore format is valid
+func ValidatePattern(pattern string) error {
+	_, _, err := parseRule("", pattern)
+	return err
+}
+
+// readRules reads the patterns of an ignore file, which may not exist
+func readRules(base, file string) ([]rule, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+	defer f.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		r, ok, err := parseRule(base, scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, line, err)
+		}
+		if ok {
+			rules = append(rules, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+	return rules, nil
+}
+
+// parseRule parses a line of an ignore file, reporting false for blank lines
+// and comments
+func parseRule(base, line string) (rule, bool, error) {
+	pattern := strings.TrimRight(line, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return rule{}, false, nil
+	}
+
+	r := rule{base: base}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\`) {
+		// \# and \! start patterns with a literal # or !
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return rule{}, false, nil
+	}
+
+	// Patterns with a / are relative to the ignore file's directory; others
+	// match names at any depth below it
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	expr := globToRegex(pattern)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule{}, false, fmt.Errorf("invalid ignore pattern %q: %w", line, err)
+	}
+	r.re = re
+	return r, true, nil
+}
+
+// globToRegex translates a gitignore glob into a regular expression. * and
+// ? match within a path segment, ** matches across segments, and [...]
+// matches a character class.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		case glob[i] == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case glob[i] == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package ignore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates files under root, by their paths with / separators
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+// walked returns the files Walk visits, relative to root
+func walked(t *testing.T, m *Matcher, root string) []string {
+	t.Helper()
+	var files []string
+	err := m.Walk(func(path string, d fs.DirEntry) error {
+		relativePath, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+		files = append(files, filepath.ToSlash(relativePath))
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":                "# Dependencies\nnode_modules/\n*.log\n!keep.log\n/build\n",
+		".ragignore":                "drafts/**\n",
+		"README.md":                 "",
+		"debug.log":                 "",
+		"keep.log":                  "",
+		"build/out.md":              "",
+		"docs/build/guide.md":       "",
+		"docs/.gitignore":           "secret.md\n",
+		"docs/secret.md":            "",
+		"secret.md":                 "",
+		"drafts/idea.md":            "",
+		"node_modules/pkg/index.js": "",
+		"web/node_modules/x.js":     "",
+		".git/config":               "",
+		"vendor/lib.go":             "",
+	})
+
+	m, err := New(root, []string{GitIgnoreFile, RagIgnoreFile}, []string{"vendor/"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		".gitignore", ".ragignore", "README.md", "keep.log", "docs/build/guide.md", "docs/.gitignore", "secret.md",
+	}, walked(t, m, root))
+
+	// Without .gitignore, only .ragignore and the patterns apply
+	m, err = New(root, []string{RagIgnoreFile}, nil)
+	require.NoError(t, err)
+	files := walked(t, m, root)
+	assert.Contains(t, files, "debug.log")
+	assert.Contains(t, files, "node_modules/pkg/index.js")
+	assert.NotContains(t, files, "drafts/idea.md")
+	assert.NotContains(t, files, ".git/config")
+}
+
+func TestIgnored(t *testing.T) {
+	m, err := New(t.TempDir(), nil, []string{"*.min.js", "docs/**/*.tmp", "data[0-9].csv", "!data9.csv", `\#notes.md`})
+	require.NoError(t, err)
+
+	cases := []struct {
+		path    string
+		ignored bool
+	}{
+		{"app.min.js", true},
+		{"static/js/app.min.js", true},
+		{"app.js", false},
+		{"docs/a.tmp", true},
+		{"docs/a/b/c.tmp", true},
+		{"other/a.tmp", false},
+		{"data1.csv", true},
+		{"data9.csv", false},
+		{"dataX.csv", false},
+		{"#notes.md", true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.ignored, m.Ignored(c.path, false), c.path)
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.NoError(t, ValidatePattern("node_modules/"))
+	assert.NoError(t, ValidatePattern("# comment"))
+	assert.Error(t, ValidatePattern("[]"))
+}
@@ -0,0 +1,244 @@
+// Package localsearch searches the files of collection folders directly, the
+// way grep does, so collections can still be searched when the database is
+// unreachable. It also remembers the folders of collections, since they are
+// otherwise only known to the database.
+package localsearch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxLineLength is the length lines are cut to in hits
+	maxLineLength = 200
+	// binarySniffLength is the number of bytes checked for a NUL byte to
+	// tell binary files from text
+	binarySniffLength = 8000
+)
+
+// wordPattern matches the words of a query
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// Line is a line of a file that matches a query
+type Line struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+// Hit is a file that matches a query
+type Hit struct {
+	Path    string `json:"path"`
+	Terms   int    `json:"terms"`   // Number of distinct query terms found in the file
+	Matches int    `json:"matches"` // Number of matching lines
+	Lines   []Line `json:"lines"`   // The first matching lines
+}
+
+// Terms returns the distinct lowercase words of a query
+func Terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range wordPattern.FindAllString(strings.ToLower(query), -1) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// SearchFile returns the lines of a file that contain any of the terms, case
+// insensitively, keeping up to maxLines of them. It returns nil for files
+// without matches and for binary files.
+func SearchFile(path string, terms []string, maxLines int) (*Hit, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	head, _ := reader.Peek(binarySniffLength)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	hit := &Hit{Path: path}
+	found := make(map[string]bool)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		lower := strings.ToLower(line)
+		matched := false
+		for _, term := range terms {
+			if strings.Contains(lower, term) {
+				found[term] = true
+				matched = true
+			}
+		}
+		if !matched {
+			continue
+		}
+		hit.Matches++
+		if len(hit.Lines) < maxLines {
+			hit.Lines = append(hit.Lines, Line{Number: number, Text: trimLine(line)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if hit.Matches == 0 {
+		return nil, nil
+	}
+	hit.Terms = len(found)
+	return hit, nil
+}
+
+// Rank orders hits by the number of query terms they contain, then by their
+// number of matching lines
+func Rank(hits []*Hit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Terms != hits[j].Terms {
+			return hits[i].Terms > hits[j].Terms
+		}
+		if hits[i].Matches != hits[j].Matches {
+			return hits[i].Matches > hits[j].Matches
+		}
+		return hits[i].Path < hits[j].Path
+	})
+}
+
+// trimLine removes the indentation of a line and cuts it to maxLineLength
+func trimLine(line string) string {
+	line = strings.TrimSpace(line)
+	if runes := []rune(line); len(runes) > maxLineLength {
+		return string(runes[:maxLineLength]) + "..."
+	}
+	return line
+}
+
+// CachedCollection is a collection as remembered for local searches
+type CachedCollection struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Folders []string `json:"folders"`
+}
+
+// FolderCache remembers the folders of collections in a JSON file
+type FolderCache struct {
+	path string
+}
+
+// NewFolderCache creates a folder cache stored at path
+func NewFolderCache(path string) *FolderCache {
+	return &FolderCache{path: path}
+}
+
+// Lookup returns the collection with the given ID or name
+func (c *FolderCache) Lookup(ref string) (*CachedCollection, bool, error) {
+	collections, err := c.load()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, collection := range collections {
+		if collection.ID == ref || collection.Name == ref {
+			return collection, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// Remember stores the folders of a collection, replacing what was stored for
+// it before. The file is only written when something changed.
+func (c *FolderCache) Remember(collection CachedCollection) error {
+	collections, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	kept := []*CachedCollection{&collection}
+	for _, cached := range collections {
+		if cached.ID != collection.ID {
+			kept = append(kept, cached)
+		} else if cached.Name == collection.Name && slices.Equal(cached.Folders, collection.Folders) {
+			return nil
+		}
+	}
+	return c.save(kept)
+}
+
+// Forget removes a collection from the cache
+func (c *FolderCache) Forget(id string) error {
+	collections, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	var kept []*CachedCollection
+	for _, cached := range collections {
+		if cached.ID != id {
+			kept = append(kept, cached)
+		}
+	}
+	if len(kept) == len(collections) {
+		return nil
+	}
+	return c.save(kept)
+}
+
+// load reads the cached collections; a missing cache is empty
+func (c *FolderCache) load() ([]*CachedCollection, error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection cache: %w", err)
+	}
+
+	var collections []*CachedCollection
+	if err := json.Unmarshal(data, &collections); err != nil {
+		return nil, fmt.Errorf("failed to parse collection cache %s: %w", c.path, err)
+	}
+	return collections, nil
+}
+
+// save replaces the cache file, so commands running at the same time never
+// read half of it
+func (c *FolderCache) save(collections []*CachedCollection) error {
+	data, err := json.MarshalIndent(collections, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode collection cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write collection cache: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write collection cache: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write collection cache: %w", err)
+	}
+	if err := os.Rename(temp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write collection cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package localsearch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"reset", "the", "password", "api_key"}, Terms("Reset the password, reset API_KEY!"))
+	assert.Empty(t, Terms("?!"))
+}
+
+func TestSearchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "guide.md", "# Guide\n\n  Reset your PASSWORD here.\nNothing to see.\nThe password expires.\n")
+
+	hit, err := SearchFile(path, Terms("password reset"), 1)
+	require.NoError(t, err)
+	require.NotNil(t, hit)
+	assert.Equal(t, 2, hit.Terms)
+	assert.Equal(t, 2, hit.Matches)
+	assert.Equal(t, []Line{{Number: 3, Text: "Reset your PASSWORD here."}}, hit.Lines)
+
+	hit, err = SearchFile(path, Terms("invoice"), 3)
+	require.NoError(t, err)
+	assert.Nil(t, hit)
+
+	// Binary files are skipped
+	binary := writeFile(t, dir, "image.bin", "password\x00\x01")
+	hit, err = SearchFile(binary, Terms("password"), 3)
+	require.NoError(t, err)
+	assert.Nil(t, hit)
+
+	// Long lines are cut
+	long := writeFile(t, dir, "long.txt", "password "+strings.Repeat("x", 500))
+	hit, err = SearchFile(long, Terms("password"), 3)
+	require.NoError(t, err)
+	require.Len(t, hit.Lines, 1)
+	assert.Len(t, hit.Lines[0].Text, maxLineLength+3)
+
+	_, err = SearchFile(filepath.Join(dir, "missing.md"), Terms("password"), 3)
+	assert.Error(t, err)
+}
+
+func TestRank(t *testing.T) {
+	hits := []*Hit{
+		{Path: "b.md", Terms: 1, Matches: 5},
+		{Path: "c.md", Terms: 2, Matches: 1},
+		{Path: "a.md", Terms: 1, Matches: 5},
+		{Path: "d.md", Terms: 2, Matches: 3},
+	}
+	Rank(hits)
+	var paths []string
+	for _, hit := range hits {
+		paths = append(paths, hit.Path)
+	}
+	assert.Equal(t, []string{"d.md", "c.md", "a.md", "b.md"}, paths)
+}
+
+func TestFolderCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "collections.json")
+	cache := NewFolderCache(path)
+
+	_, ok, err := cache.Lookup("docs")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Remember(CachedCollection{ID: "1", Name: "docs", Folders: []string{"/docs"}}))
+	require.NoError(t, cache.Remember(CachedCollection{ID: "2", Name: "code", Folders: []string{"/src"}}))
+	require.NoError(t, cache.Remember(CachedCollection{ID: "1", Name: "handbook", Folders: []string{"/docs", "/wiki"}}))
+
+	collection, ok, err := cache.Lookup("handbook")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, []string{"/docs", "/wiki"}, collection.Folders)
+
+	collection, ok, err = cache.Lookup("2")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "code", collection.Name)
+
+	_, ok, err = cache.Lookup("docs")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Forget("2"))
+	_, ok, err = cache.Lookup("code")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0644))
+	_, _, err = cache.Lookup("docs")
+	assert.Error(t, err)
+}
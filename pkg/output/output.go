@@ -1,12 +1,20 @@
 package output
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/fatih/color"
 )
 
+// Output formats
+const (
+	FormatText = "text" // Colored text for people
+	FormatJSON = "json" // JSON results for scripts
+)
+
 var (
 	// Colors for different output types
 	InfoColor    = color.New(color.FgBlue)
@@ -24,8 +32,59 @@ var (
 
 	// Disable colors flag
 	colorsDisabled bool
+
+	// Format of command results
+	format = FormatText
+
+	// Where results are written
+	stdout io.Writer = os.Stdout
 )
 
+// SetFormat sets the format commands print their results in. In JSON mode,
+// messages are written to stderr, so stdout only holds the JSON result.
+func SetFormat(f string) error {
+	switch f {
+	case FormatText, FormatJSON:
+		format = f
+		return nil
+	default:
+		return fmt.Errorf("invalid output format: %s. Must be '%s' or '%s'", f, FormatText, FormatJSON)
+	}
+}
+
+// IsJSON reports whether commands print their results as JSON
+func IsJSON() bool {
+	return format == FormatJSON
+}
+
+// JSON prints a value as indented JSON
+func JSON(value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	fmt.Fprintln(stdout, string(data))
+	return nil
+}
+
+// Result prints the result of a command: value as JSON in JSON mode, and
+// otherwise whatever text prints
+func Result(value interface{}, text func()) error {
+	if IsJSON() {
+		return JSON(value)
+	}
+	text()
+	return nil
+}
+
+// messages returns where messages are written
+func messages() io.Writer {
+	if IsJSON() {
+		return os.Stderr
+	}
+	return stdout
+}
+
 // DisableColors disables color output
 func DisableColors() {
 	colorsDisabled = true
@@ -41,27 +100,27 @@ func EnableColors() {
 // Info prints an info message
 func Info(format string, args ...interface{}) {
 	if colorsDisabled {
-		fmt.Printf(format+"\n", args...)
+		fmt.Fprintf(messages(), format+"\n", args...)
 	} else {
-		InfoColor.Printf(format+"\n", args...)
+		InfoColor.Fprintf(messages(), format+"\n", args...)
 	}
 }
 
 // Success prints a success message
 func Success(format string, args ...interface{}) {
 	if colorsDisabled {
-		fmt.Printf(format+"\n", args...)
+		fmt.Fprintf(messages(), format+"\n", args...)
 	} else {
-		SuccessColor.Printf(format+"\n", args...)
+		SuccessColor.Fprintf(messages(), format+"\n", args...)
 	}
 }
 
 // Warning prints a warning message
 func Warning(format string, args ...interface{}) {
 	if colorsDisabled {
-		fmt.Printf(format+"\n", args...)
+		fmt.Fprintf(messages(), format+"\n", args...)
 	} else {
-		WarningColor.Printf(format+"\n", args...)
+		WarningColor.Fprintf(messages(), format+"\n", args...)
 	}
 }
 
@@ -77,9 +136,9 @@ func Error(format string, args ...interface{}) {
 // Bold prints a bold message
 func Bold(format string, args ...interface{}) {
 	if colorsDisabled {
-		fmt.Printf(format+"\n", args...)
+		fmt.Fprintf(messages(), format+"\n", args...)
 	} else {
-		BoldColor.Printf(format+"\n", args...)
+		BoldColor.Fprintf(messages(), format+"\n", args...)
 	}
 }
 
@@ -111,20 +170,20 @@ func Print(args ...interface{}) {
 // KeyValue prints a key-value pair with different colors
 func KeyValue(key, value string) {
 	if colorsDisabled {
-		fmt.Printf("%s: %s\n", key, value)
+		fmt.Fprintf(messages(), "%s: %s\n", key, value)
 	} else {
-		KeyColor.Printf("%s: ", key)
-		ValueColor.Printf("%s\n", value)
+		KeyColor.Fprintf(messages(), "%s: ", key)
+		ValueColor.Fprintf(messages(), "%s\n", value)
 	}
 }
 
 // KeyValuef prints a formatted key-value pair with different colors
 func KeyValuef(key, format string, args ...interface{}) {
 	if colorsDisabled {
-		fmt.Printf("%s: %s\n", key, fmt.Sprintf(format, args...))
+		fmt.Fprintf(messages(), "%s: %s\n", key, fmt.Sprintf(format, args...))
 	} else {
-		KeyColor.Printf("%s: ", key)
-		ValueColor.Printf(format+"\n", args...)
+		KeyColor.Fprintf(messages(), "%s: ", key)
+		ValueColor.Fprintf(messages(), format+"\n", args...)
 	}
 }
 
@@ -0,0 +1,57 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout collects what is written to stdout until the test ends
+func captureStdout(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous, previousFormat := stdout, format
+	stdout = &buf
+	DisableColors()
+	t.Cleanup(func() {
+		stdout, format = previous, previousFormat
+		EnableColors()
+	})
+	return &buf
+}
+
+func TestSetFormat(t *testing.T) {
+	captureStdout(t)
+
+	require.NoError(t, SetFormat(FormatJSON))
+	assert.True(t, IsJSON())
+	require.NoError(t, SetFormat(FormatText))
+	assert.False(t, IsJSON())
+	assert.Error(t, SetFormat("yaml"))
+	assert.False(t, IsJSON())
+}
+
+func TestResultAsText(t *testing.T) {
+	buf := captureStdout(t)
+
+	err := Result(map[string]int{"chunks": 3}, func() {
+		KeyValuef("Chunks", "%d", 3)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Chunks: 3\n", buf.String())
+}
+
+func TestResultAsJSON(t *testing.T) {
+	buf := captureStdout(t)
+	require.NoError(t, SetFormat(FormatJSON))
+
+	// Messages go to stderr, so stdout only holds the result
+	Info("Connecting...")
+	err := Result(map[string]int{"chunks": 3}, func() {
+		t.Error("Expected the text output not to be printed")
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"chunks": 3}`, buf.String())
+}
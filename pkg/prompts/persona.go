@@ -0,0 +1,71 @@
+package prompts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Persona is a built-in assistant style that shapes the system prompt, the
+// sampling temperature and the answer length of chat sessions
+type Persona struct {
+	Name         string
+	Description  string
+	SystemPrompt string  // Instructions added before the other system prompts
+	Temperature  float64 // Sampling temperature of the answers
+	MaxTokens    int     // Maximum length of the answers in tokens
+}
+
+// personas are the built-in personas, in the order they are listed
+var personas = []Persona{
+	{
+		Name:        "engineer",
+		Description: "Concise engineer: short, direct answers with code and commands where they help",
+		SystemPrompt: `Answer as a concise senior engineer. Lead with the answer, then give only the details needed to act on it.
+Prefer code snippets, commands and exact names from the context over prose. Skip pleasantries and restating the question.
+Keep answers under about 150 words unless the user asks for more.`,
+		Temperature: 0.2,
+		MaxTokens:   600,
+	},
+	{
+		Name:        "tutor",
+		Description: "Tutor: step-by-step explanations with examples, for learning a topic",
+		SystemPrompt: `Answer as a patient tutor. Explain the concepts behind the answer step by step, starting from what the user likely knows.
+Illustrate them with a short example from the context where possible, and define terms the first time they appear.
+End with a one-sentence recap of the key point.`,
+		Temperature: 0.6,
+		MaxTokens:   1500,
+	},
+	{
+		Name:        "summarizer",
+		Description: "Summarizer: brief bullet-point summaries of the relevant documents",
+		SystemPrompt: `Answer as a summarizer. Summarize what the context says about the question in at most five short bullet points,
+most important first, naming the source of each point. Don't add information that isn't in the context.`,
+		Temperature: 0.1,
+		MaxTokens:   400,
+	},
+}
+
+// Personas returns the built-in personas
+func Personas() []Persona {
+	return append([]Persona(nil), personas...)
+}
+
+// GetPersona returns the built-in persona with the given name
+func GetPersona(name string) (*Persona, error) {
+	for _, p := range personas {
+		if p.Name == name {
+			persona := p
+			return &persona, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown persona %q, must be one of: %s", name, strings.Join(PersonaNames(), ", "))
+}
+
+// PersonaNames returns the names of the built-in personas
+func PersonaNames() []string {
+	names := make([]string, len(personas))
+	for i, p := range personas {
+		names[i] = p.Name
+	}
+	return names
+}
@@ -0,0 +1,31 @@
+package prompts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPersona(t *testing.T) {
+	assert.Equal(t, []string{"engineer", "tutor", "summarizer"}, PersonaNames())
+
+	for _, name := range PersonaNames() {
+		persona, err := GetPersona(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, persona.Name)
+		assert.NotEmpty(t, persona.SystemPrompt)
+		assert.Greater(t, persona.MaxTokens, 0)
+	}
+
+	// Personas returned can't change the built-in ones
+	persona, err := GetPersona("tutor")
+	require.NoError(t, err)
+	persona.Temperature = 2
+	persona, err = GetPersona("tutor")
+	require.NoError(t, err)
+	assert.Equal(t, 0.6, persona.Temperature)
+
+	_, err = GetPersona("pirate")
+	assert.ErrorContains(t, err, "engineer, tutor, summarizer")
+}
@@ -0,0 +1,266 @@
+// Package prompts manages the library of named system prompts that chat
+// sessions can be started with. Prompts are stored in the database or as
+// files in the prompts directory, and may contain variables such as
+// {{collection}} that are filled in when they are used.
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+// fileExtensions are the extensions of prompt files, in order of preference
+var fileExtensions = []string{".md", ".txt"}
+
+var (
+	// namePattern matches valid prompt names, which are also file names
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// variablePattern matches variables such as {{collection}} and {{ user }}
+	variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// ErrNotFound is returned when no prompt has the requested name
+var ErrNotFound = errors.New("prompt not found")
+
+// Prompt is a prompt of the library. File is the path of the file the
+// prompt was read from, or empty for prompts stored in the database.
+type Prompt struct {
+	database.Prompt
+	File string `json:"file,omitempty"`
+}
+
+// Library looks up prompts in the database and in the prompts directory.
+// Prompts in the database take precedence over files with the same name.
+type Library struct {
+	store database.PromptManager
+	dir   string
+}
+
+// New creates a prompt library backed by store and the files in dir
+func New(store database.PromptManager, dir string) *Library {
+	return &Library{store: store, dir: dir}
+}
+
+// Dir returns the prompts directory
+func (l *Library) Dir() string {
+	return l.dir
+}
+
+// Get returns the prompt with the given name
+func (l *Library) Get(name string) (*Prompt, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	stored, err := l.store.GetPrompt(name)
+	if err == nil {
+		return &Prompt{Prompt: *stored}, nil
+	}
+	if !errors.Is(err, database.ErrPromptNotFound) {
+		return nil, err
+	}
+
+	for _, ext := range fileExtensions {
+		prompt, err := readPromptFile(filepath.Join(l.dir, name+ext))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return prompt, err
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// List returns the prompts of the database and the prompts directory,
+// sorted by name
+func (l *Library) List() ([]*Prompt, error) {
+	stored, err := l.store.ListPrompts()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*Prompt)
+	for _, p := range stored {
+		byName[p.Name] = &Prompt{Prompt: *p}
+	}
+
+	entries, err := os.ReadDir(l.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read prompts directory: %w", err)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if entry.IsDir() || !isPromptFile(ext) || ValidateName(name) != nil {
+			continue
+		}
+		if existing, ok := byName[name]; ok && (existing.File == "" || preferredExtension(filepath.Ext(existing.File), ext)) {
+			continue
+		}
+		prompt, err := readPromptFile(filepath.Join(l.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		byName[name] = prompt
+	}
+
+	prompts := make([]*Prompt, 0, len(byName))
+	for _, p := range byName {
+		prompts = append(prompts, p)
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].Name < prompts[j].Name
+	})
+	return prompts, nil
+}
+
+// Save stores a prompt in the database, replacing a stored prompt with the
+// same name
+func (l *Library) Save(prompt *database.Prompt) error {
+	if err := ValidateName(prompt.Name); err != nil {
+		return err
+	}
+	if strings.TrimSpace(prompt.Content) == "" {
+		return fmt.Errorf("prompt content cannot be empty")
+	}
+	return l.store.SavePrompt(prompt)
+}
+
+// Delete deletes a prompt from the database. Prompts read from files are
+// deleted by removing their file.
+func (l *Library) Delete(name string) error {
+	err := l.store.DeletePrompt(name)
+	if !errors.Is(err, database.ErrPromptNotFound) {
+		return err
+	}
+
+	prompt, getErr := l.Get(name)
+	if getErr != nil {
+		return getErr
+	}
+	return fmt.Errorf("prompt %s is read from %s; delete the file to remove it", name, prompt.File)
+}
+
+// ValidateName checks that a prompt name can be used as a file name
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid prompt name %q: use letters, digits, '.', '-' and '_'", name)
+	}
+	return nil
+}
+
+// Variables returns the names of the variables used in content, in order of
+// first use
+func Variables(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range variablePattern.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render fills in the variables of content. Variables without a value are an
+// error, so a typo doesn't end up in the prompt sent to the model.
+func Render(content string, vars map[string]string) (string, error) {
+	var missing []string
+	rendered := variablePattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value for prompt variable %s; set it with --var %s=value", missing[0], missing[0])
+	}
+	return rendered, nil
+}
+
+// DefaultVars returns the variables that are available to every prompt: the
+// date and time, and the name of the user running the command
+func DefaultVars(now time.Time) map[string]string {
+	vars := map[string]string{
+		"date": now.Format("2006-01-02"),
+		"time": now.Format("15:04"),
+		"user": os.Getenv("USER"),
+	}
+	if current, err := user.Current(); err == nil {
+		if current.Name != "" {
+			vars["user"] = current.Name
+		} else if current.Username != "" {
+			vars["user"] = current.Username
+		}
+	}
+	return vars
+}
+
+// readPromptFile reads a prompt file. The first line is used as the
+// description when it is a Markdown heading.
+func readPromptFile(path string) (*Prompt, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	prompt := &Prompt{
+		Prompt: database.Prompt{
+			Name:      name,
+			Content:   strings.TrimSpace(string(content)),
+			CreatedAt: info.ModTime(),
+			UpdatedAt: info.ModTime(),
+		},
+		File: path,
+	}
+	if first, rest, _ := strings.Cut(prompt.Content, "\n"); strings.HasPrefix(first, "# ") {
+		prompt.Description = strings.TrimSpace(strings.TrimPrefix(first, "# "))
+		prompt.Content = strings.TrimSpace(rest)
+	}
+	return prompt, nil
+}
+
+// isPromptFile reports whether ext is the extension of a prompt file
+func isPromptFile(ext string) bool {
+	for _, e := range fileExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredExtension reports whether a file with extension a is used over a
+// file with the same name and extension b
+func preferredExtension(a, b string) bool {
+	for _, e := range fileExtensions {
+		if e == a {
+			return true
+		}
+		if e == b {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package prompts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps prompts in memory
+type memoryStore struct {
+	prompts map[string]*database.Prompt
+}
+
+func newMemoryStore(prompts ...*database.Prompt) *memoryStore {
+	s := &memoryStore{prompts: make(map[string]*database.Prompt)}
+	for _, p := range prompts {
+		s.prompts[p.Name] = p
+	}
+	return s
+}
+
+func (s *memoryStore) SavePrompt(prompt *database.Prompt) error {
+	s.prompts[prompt.Name] = prompt
+	return nil
+}
+
+func (s *memoryStore) GetPrompt(name string) (*database.Prompt, error) {
+	if p, ok := s.prompts[name]; ok {
+		return p, nil
+	}
+	return nil, database.ErrPromptNotFound
+}
+
+func (s *memoryStore) ListPrompts() ([]*database.Prompt, error) {
+	var prompts []*database.Prompt
+	for _, p := range s.prompts {
+		prompts = append(prompts, p)
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+	return prompts, nil
+}
+
+func (s *memoryStore) DeletePrompt(name string) error {
+	if _, ok := s.prompts[name]; !ok {
+		return database.ErrPromptNotFound
+	}
+	delete(s.prompts, name)
+	return nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestLibraryGet(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "support-agent.md", "# Answers support tickets\n\nYou help {{user}} with {{collection}}.\n")
+	writeFile(t, dir, "reviewer.txt", "Review the code.")
+	writeFile(t, dir, "stored.md", "From the file")
+
+	library := New(newMemoryStore(&database.Prompt{Name: "stored", Content: "From the database"}), dir)
+
+	prompt, err := library.Get("support-agent")
+	require.NoError(t, err)
+	assert.Equal(t, "Answers support tickets", prompt.Description)
+	assert.Equal(t, "You help {{user}} with {{collection}}.", prompt.Content)
+	assert.Equal(t, filepath.Join(dir, "support-agent.md"), prompt.File)
+
+	prompt, err = library.Get("reviewer")
+	require.NoError(t, err)
+	assert.Equal(t, "Review the code.", prompt.Content)
+
+	prompt, err = library.Get("stored")
+	require.NoError(t, err)
+	assert.Equal(t, "From the database", prompt.Content, "stored prompts take precedence over files")
+	assert.Empty(t, prompt.File)
+
+	_, err = library.Get("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = library.Get("../secrets")
+	assert.Error(t, err)
+}
+
+func TestLibraryList(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "b.md", "From markdown")
+	writeFile(t, dir, "b.txt", "From text")
+	writeFile(t, dir, "c.md", "From the file")
+	writeFile(t, dir, "notes.json", "{}")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "d.md"), 0755))
+
+	library := New(newMemoryStore(
+		&database.Prompt{Name: "a", Content: "A"},
+		&database.Prompt{Name: "c", Content: "From the database"},
+	), dir)
+
+	prompts, err := library.List()
+	require.NoError(t, err)
+
+	var names, contents []string
+	for _, p := range prompts {
+		names = append(names, p.Name)
+		contents = append(contents, p.Content)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+	assert.Equal(t, []string{"A", "From markdown", "From the database"}, contents)
+
+	// A missing directory has no prompts
+	prompts, err = New(newMemoryStore(), filepath.Join(dir, "missing")).List()
+	require.NoError(t, err)
+	assert.Empty(t, prompts)
+}
+
+func TestLibrarySaveAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "from-file.md", "From the file")
+	library := New(newMemoryStore(), dir)
+
+	require.NoError(t, library.Save(&database.Prompt{Name: "support-agent", Content: "Be helpful."}))
+	assert.Error(t, library.Save(&database.Prompt{Name: "bad name", Content: "Be helpful."}))
+	assert.Error(t, library.Save(&database.Prompt{Name: "empty", Content: "  "}))
+
+	require.NoError(t, library.Delete("support-agent"))
+	_, err := library.Get("support-agent")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	err = library.Delete("from-file")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "delete the file")
+
+	assert.True(t, errors.Is(library.Delete("missing"), ErrNotFound))
+}
+
+func TestRender(t *testing.T) {
+	vars := map[string]string{"collection": "docs", "user": "Sam"}
+
+	rendered, err := Render("Help {{ user }} with {{collection}}. {{user}}!", vars)
+	require.NoError(t, err)
+	assert.Equal(t, "Help Sam with docs. Sam!", rendered)
+
+	_, err = Render("Answer in {{language}}", vars)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--var language=value")
+
+	rendered, err = Render("Braces { stay } as {{ they are", vars)
+	require.NoError(t, err)
+	assert.Equal(t, "Braces { stay } as {{ they are", rendered)
+}
+
+func TestVariables(t *testing.T) {
+	assert.Equal(t, []string{"user", "collection"}, Variables("{{user}} {{ collection }} {{user}}"))
+	assert.Empty(t, Variables("no variables"))
+}
+
+func TestDefaultVars(t *testing.T) {
+	vars := DefaultVars(time.Date(2024, 3, 5, 14, 7, 0, 0, time.UTC))
+	assert.Equal(t, "2024-03-05", vars["date"])
+	assert.Equal(t, "14:07", vars["time"])
+	assert.Contains(t, vars, "user")
+}
@@ -0,0 +1,84 @@
+// Package secrets finds secrets such as private keys and credentials in
+// text before it is embedded, in the manner of gitleaks.
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// builtinRules are the secrets that are always scanned for. Private keys are
+// also matched by their end line and by lines of their body, so every chunk of
+// a key split into chunks is found.
+var builtinRules = []config.SecretRule{
+	{Name: "private-key", Pattern: `-----(?:BEGIN|END)[ A-Z0-9]*PRIVATE KEY( BLOCK)?-----|(?:[A-Za-z0-9+/]{64}\s+){3,}`},
+	{Name: "aws-access-key-id", Pattern: `\b(?:A3T[A-Z0-9]|AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[A-Z0-9]{16}\b`},
+	{Name: "aws-secret-access-key", Pattern: `(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`},
+	{Name: "github-token", Pattern: `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`},
+	{Name: "slack-token", Pattern: `\bxox[abprs]-[A-Za-z0-9-]{10,}\b`},
+	{Name: "openai-api-key", Pattern: `\bsk-(?:proj-|svcacct-)?[A-Za-z0-9_-]{32,}\b`},
+	{Name: "google-api-key", Pattern: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{Name: "env-secret", Pattern: `(?m)^\s*(?:export\s+)?[A-Z0-9_]*(?:SECRET|PASSWORD|PASSWD|TOKEN|API_KEY|APIKEY|PRIVATE_KEY|ACCESS_KEY|CREDENTIALS)[A-Z0-9_]*\s*=\s*["']?[^\s"'$<{]{8,}`},
+}
+
+// rule is a compiled secret rule
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Scanner finds secrets in text
+type Scanner struct {
+	rules []rule
+	allow []*regexp.Regexp
+}
+
+// New creates a scanner for the built-in rules and the rules of the
+// secrets settings
+func New(cfg *config.SecretsConfig) (*Scanner, error) {
+	s := &Scanner{}
+	for _, r := range append(append([]config.SecretRule{}, builtinRules...), cfg.Rules...) {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile secret rule %s: %w", r.Name, err)
+		}
+		s.rules = append(s.rules, rule{name: r.Name, pattern: pattern})
+	}
+	for _, a := range cfg.Allow {
+		pattern, err := regexp.Compile(a)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile allow pattern %s: %w", a, err)
+		}
+		s.allow = append(s.allow, pattern)
+	}
+	return s, nil
+}
+
+// Scan returns the sorted names of the rules whose secrets are in text.
+// Matches of an allow pattern, such as documented example keys, are ignored.
+func (s *Scanner) Scan(text string) []string {
+	var found []string
+	for _, r := range s.rules {
+		for _, match := range r.pattern.FindAllString(text, -1) {
+			if !s.allowed(match) {
+				found = append(found, r.name)
+				break
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// allowed reports whether a match is allowed
+func (s *Scanner) allowed(match string) bool {
+	for _, a := range s.allow {
+		if a.MatchString(match) {
+			return true
+		}
+	}
+	return false
+}
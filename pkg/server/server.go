@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// readinessTimeout bounds how long the readiness checks of one request may take
+const readinessTimeout = 5 * time.Second
+
+// ReadinessCheck reports whether a dependency of the server, such as the
+// database, can currently be used
+type ReadinessCheck func(ctx context.Context) error
+
+// namedCheck is a readiness check with the name it is reported under
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// Server is the HTTP server used by serve. It always answers /healthz and
+// /readyz; API endpoints are registered with Handle.
+type Server struct {
+	config *config.ServerConfig
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// New creates a new server
+func New(config *config.ServerConfig) *Server {
+	s := &Server{
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /readyz", s.handleReady)
+	return s
+}
+
+// Handle registers a handler for a pattern, as http.ServeMux.Handle does
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// AddReadinessCheck adds a check that must pass for /readyz to report ready
+func (s *Server) AddReadinessCheck(name string, check ReadinessCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+}
+
+// Handler returns the handler serving all of the server's endpoints
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Run listens on the configured address and serves until ctx is done, then
+// shuts down gracefully
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.GetListen())
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.GetListen(), err)
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves on the listener until ctx is done. It then reports not ready,
+// stops accepting connections and waits up to the shutdown timeout for
+// in-flight requests to finish.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	httpServer := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.config.TLSEnabled() {
+			serveErr <- httpServer.ServeTLS(listener, s.config.TLSCert, s.config.TLSKey)
+		} else {
+			serveErr <- httpServer.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.draining.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.GetShutdownTimeout())
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		httpServer.Close()
+		return fmt.Errorf("failed to drain in-flight requests: %w", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+	return nil
+}
+
+// handleHealth reports that the process is up
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady reports whether the server can handle requests: it is not
+// shutting down and all readiness checks pass
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+		return
+	}
+
+	s.mu.Lock()
+	checks := append([]namedCheck(nil), s.checks...)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	results := make(map[string]string, len(checks))
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			status = http.StatusServiceUnavailable
+			results[c.name] = err.Error()
+		} else {
+			results[c.name] = "ok"
+		}
+	}
+
+	response := map[string]interface{}{"status": "ready", "checks": results}
+	if status != http.StatusOK {
+		response["status"] = "not ready"
+	}
+	writeJSON(w, status, response)
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
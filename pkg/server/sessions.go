@@ -0,0 +1,376 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+// maxRequestBody bounds the size of a JSON request body
+const maxRequestBody = 1 << 20
+
+// Responder answers a user message in a chat session, given the session's
+// conversation so far. Pieces of the answer are passed to onChunk as they
+// are generated when it is not nil.
+type Responder interface {
+	Respond(ctx context.Context, session *database.ChatSession, history []client.Message, input string, onChunk client.ChunkHandler) (string, error)
+}
+
+// CollectionResolver finds a collection by ID, name or alias
+type CollectionResolver func(ref string) (*database.Collection, error)
+
+// SessionAPI serves chat sessions over HTTP. Each session's conversation is
+// stored in the database, so any number of sessions can be active at once
+// and they survive restarts of the server. Sessions expire after being idle
+// for the session TTL.
+type SessionAPI struct {
+	store     database.ChatSessionManager
+	responder Responder
+	resolve   CollectionResolver
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+// sessionLock serializes the messages of one session so its turns are
+// answered and stored in order
+type sessionLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// createSessionRequest is the body of POST /v1/sessions
+type createSessionRequest struct {
+	Collection string `json:"collection"`
+	Name       string `json:"name"`
+	database.ChatSessionSettings
+}
+
+// messageRequest is the body of POST /v1/sessions/{id}/messages
+type messageRequest struct {
+	Content string `json:"content"`
+}
+
+// NewSessionAPI creates the chat session API. Sessions expire after being
+// idle for ttl, or never when ttl is 0.
+func NewSessionAPI(store database.ChatSessionManager, responder Responder, resolve CollectionResolver, ttl time.Duration) *SessionAPI {
+	return &SessionAPI{
+		store:     store,
+		responder: responder,
+		resolve:   resolve,
+		ttl:       ttl,
+		locks:     make(map[string]*sessionLock),
+	}
+}
+
+// Register adds the session endpoints to the server
+func (a *SessionAPI) Register(s *Server) {
+	s.Handle("POST /v1/sessions", http.HandlerFunc(a.handleCreate))
+	s.Handle("GET /v1/sessions/{id}", http.HandlerFunc(a.handleGet))
+	s.Handle("DELETE /v1/sessions/{id}", http.HandlerFunc(a.handleDelete))
+	s.Handle("POST /v1/sessions/{id}/messages", http.HandlerFunc(a.handleMessage))
+}
+
+// RunEviction deletes expired sessions every interval until ctx is done.
+// Failures are passed to onError and retried on the next tick.
+func (a *SessionAPI) RunEviction(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := a.store.DeleteExpiredSessions(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// expiresAt returns when a session used now expires, or nil if sessions don't expire
+func (a *SessionAPI) expiresAt() *time.Time {
+	if a.ttl <= 0 {
+		return nil
+	}
+	expires := time.Now().Add(a.ttl)
+	return &expires
+}
+
+// lock locks a session and returns the function that unlocks it
+func (a *SessionAPI) lock(id string) func() {
+	a.mu.Lock()
+	l := a.locks[id]
+	if l == nil {
+		l = &sessionLock{}
+		a.locks[id] = l
+	}
+	l.refs++
+	a.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		a.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(a.locks, id)
+		}
+		a.mu.Unlock()
+	}
+}
+
+// handleCreate starts a new session with a collection
+func (a *SessionAPI) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req createSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Collection == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("collection is required"))
+		return
+	}
+	if req.SearchType != "" {
+		searchType, err := database.ParseSearchType(string(req.SearchType))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		req.SearchType = searchType
+	}
+	if req.Limit < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("limit must not be negative"))
+		return
+	}
+
+	collection, err := a.resolve(req.Collection)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var ambiguous *database.AmbiguousCollectionError
+		if errors.Is(err, database.ErrCollectionNotFound) {
+			status = http.StatusNotFound
+		} else if errors.As(err, &ambiguous) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	session := &database.ChatSession{
+		Name:         req.Name,
+		CollectionID: collection.ID,
+		Settings:     req.ChatSessionSettings,
+		ExpiresAt:    a.expiresAt(),
+	}
+	if err := a.store.CreateSession(session); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, session)
+}
+
+// handleGet returns a session and its conversation
+func (a *SessionAPI) handleGet(w http.ResponseWriter, r *http.Request) {
+	session, ok := a.getSession(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	messages, err := a.store.ListMessages(session.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if messages == nil {
+		messages = []*database.ChatMessage{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"session":  session,
+		"messages": messages,
+	})
+}
+
+// handleDelete ends a session and deletes its conversation
+func (a *SessionAPI) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := a.store.DeleteSession(r.PathValue("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrChatSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMessage answers a user message. Clients that accept
+// text/event-stream (or pass stream=true) receive the answer as server-sent
+// events while it is generated: "chunk" events with pieces of the answer,
+// then a "done" event with the stored message, or an "error" event.
+// Otherwise the stored message is returned once the answer is complete.
+func (a *SessionAPI) handleMessage(w http.ResponseWriter, r *http.Request) {
+	var req messageRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	input := strings.TrimSpace(req.Content)
+	if input == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("content is required"))
+		return
+	}
+
+	id := r.PathValue("id")
+	unlock := a.lock(id)
+	defer unlock()
+
+	session, ok := a.getSession(w, id)
+	if !ok {
+		return
+	}
+
+	stored, err := a.store.ListMessages(session.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	history := make([]client.Message, len(stored))
+	for i, message := range stored {
+		history[i] = client.Message{Role: message.Role, Content: message.Content}
+	}
+
+	var events *eventStream
+	var onChunk client.ChunkHandler
+	if wantsEventStream(r) {
+		events, err = newEventStream(w)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		onChunk = func(content string) error {
+			return events.send("chunk", map[string]string{"content": content})
+		}
+	}
+
+	answer, err := a.responder.Respond(r.Context(), session, history, input, onChunk)
+	if err == nil {
+		var reply *database.ChatMessage
+		reply, err = a.storeTurn(session.ID, input, answer)
+		if err == nil {
+			if events != nil {
+				events.send("done", map[string]interface{}{"message": reply})
+			} else {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"message": reply})
+			}
+			return
+		}
+	}
+
+	err = fmt.Errorf("failed to generate response: %w", err)
+	if events != nil {
+		events.send("error", map[string]string{"error": err.Error()})
+	} else {
+		writeError(w, http.StatusBadGateway, err)
+	}
+}
+
+// storeTurn stores a question with its answer and extends the session's
+// expiry. Turns are only stored once answered, so a failed answer can be
+// retried without leaving the question in the conversation twice.
+func (a *SessionAPI) storeTurn(sessionID, input, answer string) (*database.ChatMessage, error) {
+	if _, err := a.store.AddMessage(sessionID, "user", input); err != nil {
+		return nil, err
+	}
+	reply, err := a.store.AddMessage(sessionID, "assistant", answer)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.store.TouchSession(sessionID, a.expiresAt()); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// getSession returns a session, writing the error response if it can't be loaded
+func (a *SessionAPI) getSession(w http.ResponseWriter, id string) (*database.ChatSession, bool) {
+	session, err := a.store.GetSession(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrChatSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return nil, false
+	}
+	return session, true
+}
+
+// wantsEventStream reports whether a client asked for server-sent events
+func wantsEventStream(r *http.Request) bool {
+	return r.URL.Query().Get("stream") == "true" ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// eventStream writes server-sent events
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newEventStream starts a server-sent event response
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported by the connection")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// send writes an event with a JSON payload and flushes it to the client
+func (e *eventStream) send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+// decodeJSON decodes a JSON request body
+func decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySessions is an in-memory chat session store
+type memorySessions struct {
+	mu       sync.Mutex
+	sessions map[string]*database.ChatSession
+	messages map[string][]*database.ChatMessage
+	nextID   int64
+	evicted  atomic.Int32
+}
+
+func newMemorySessions() *memorySessions {
+	return &memorySessions{
+		sessions: make(map[string]*database.ChatSession),
+		messages: make(map[string][]*database.ChatMessage),
+	}
+}
+
+func (m *memorySessions) CreateSession(session *database.ChatSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	session.ID = fmt.Sprintf("session-%d", m.nextID)
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *memorySessions) GetSession(id string) (*database.ChatSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, database.ErrChatSessionNotFound
+	}
+	return session, nil
+}
+
+func (m *memorySessions) GetSessionByIdOrName(idOrName string) (*database.ChatSession, error) {
+	return m.GetSession(idOrName)
+}
+
+func (m *memorySessions) ListSessions() ([]*database.ChatSessionSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sessions []*database.ChatSessionSummary
+	for id, session := range m.sessions {
+		sessions = append(sessions, &database.ChatSessionSummary{ChatSession: *session, Messages: len(m.messages[id])})
+	}
+	return sessions, nil
+}
+
+func (m *memorySessions) DeleteSession(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[id]; !ok {
+		return database.ErrChatSessionNotFound
+	}
+	delete(m.sessions, id)
+	delete(m.messages, id)
+	return nil
+}
+
+func (m *memorySessions) TouchSession(id string, expiresAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id].ExpiresAt = expiresAt
+	return nil
+}
+
+func (m *memorySessions) DeleteExpiredSessions() (int, error) {
+	m.evicted.Add(1)
+	return 0, nil
+}
+
+func (m *memorySessions) AddMessage(sessionID, role, content string) (*database.ChatMessage, error) {
+	return m.AddMessageWithSources(sessionID, role, content, nil)
+}
+
+func (m *memorySessions) AddMessageWithSources(sessionID, role, content string, sources []database.ChatSource) (*database.ChatMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	message := &database.ChatMessage{ID: m.nextID, SessionID: sessionID, Role: role, Content: content, Sources: sources}
+	m.messages[sessionID] = append(m.messages[sessionID], message)
+	return message, nil
+}
+
+func (m *memorySessions) ListMessages(sessionID string) ([]*database.ChatMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*database.ChatMessage(nil), m.messages[sessionID]...), nil
+}
+
+// echoResponder answers with the input, streamed one word at a time
+type echoResponder struct {
+	err       error
+	mu        sync.Mutex
+	histories [][]client.Message
+}
+
+func (r *echoResponder) Respond(ctx context.Context, session *database.ChatSession, history []client.Message, input string, onChunk client.ChunkHandler) (string, error) {
+	r.mu.Lock()
+	r.histories = append(r.histories, history)
+	r.mu.Unlock()
+
+	if r.err != nil {
+		return "", r.err
+	}
+	words := strings.Fields(input)
+	for i, word := range words {
+		if i > 0 {
+			word = " " + word
+		}
+		if onChunk != nil {
+			if err := onChunk(word); err != nil {
+				return "", err
+			}
+		}
+	}
+	return strings.Join(words, " "), nil
+}
+
+func resolveTestCollection(ref string) (*database.Collection, error) {
+	if ref != "docs" {
+		return nil, database.ErrCollectionNotFound
+	}
+	return &database.Collection{ID: "collection-1", Name: "docs"}, nil
+}
+
+func newTestSessionServer(store *memorySessions, responder Responder) http.Handler {
+	s := New(&config.ServerConfig{})
+	NewSessionAPI(store, responder, resolveTestCollection, time.Hour).Register(s)
+	return s.Handler()
+}
+
+func doRequest(h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func createTestSession(t *testing.T, h http.Handler) string {
+	rec := doRequest(h, http.MethodPost, "/v1/sessions", `{"collection":"docs","search_type":"vector","limit":3}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var session database.ChatSession
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	return session.ID
+}
+
+func TestCreateSession(t *testing.T) {
+	store := newMemorySessions()
+	h := newTestSessionServer(store, &echoResponder{})
+
+	id := createTestSession(t, h)
+	session := store.sessions[id]
+	assert.Equal(t, "collection-1", session.CollectionID)
+	assert.Equal(t, database.SearchTypeVector, session.Settings.SearchType)
+	assert.Equal(t, 3, session.Settings.Limit)
+	require.NotNil(t, session.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *session.ExpiresAt, time.Minute)
+
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodPost, "/v1/sessions", `{"collection":"missing"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(h, http.MethodPost, "/v1/sessions", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(h, http.MethodPost, "/v1/sessions", `{"collection":"docs","search_type":"magic"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(h, http.MethodPost, "/v1/sessions", `{"collection":"docs","colour":"blue"}`).Code)
+}
+
+func TestSessionMessages(t *testing.T) {
+	store := newMemorySessions()
+	responder := &echoResponder{}
+	h := newTestSessionServer(store, responder)
+	id := createTestSession(t, h)
+
+	rec := doRequest(h, http.MethodPost, "/v1/sessions/"+id+"/messages", `{"content":"first question"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"content":"first question"`)
+
+	rec = doRequest(h, http.MethodPost, "/v1/sessions/"+id+"/messages", `{"content":"second question"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The second answer is given the stored first turn as history
+	require.Len(t, responder.histories, 2)
+	assert.Empty(t, responder.histories[0])
+	assert.Equal(t, []client.Message{
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "first question"},
+	}, responder.histories[1])
+
+	rec = doRequest(h, http.MethodGet, "/v1/sessions/"+id, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Session  database.ChatSession   `json:"session"`
+		Messages []database.ChatMessage `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, id, body.Session.ID)
+	assert.Len(t, body.Messages, 4)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(h, http.MethodPost, "/v1/sessions/"+id+"/messages", `{"content":"  "}`).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodPost, "/v1/sessions/unknown/messages", `{"content":"hi"}`).Code)
+}
+
+func TestSessionMessageStreaming(t *testing.T) {
+	store := newMemorySessions()
+	h := newTestSessionServer(store, &echoResponder{})
+	id := createTestSession(t, h)
+
+	rec := doRequest(h, http.MethodPost, "/v1/sessions/"+id+"/messages", `{"content":"streamed answer"}`, "Accept", "text/event-stream")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+	require.Len(t, events, 3)
+	assert.Equal(t, "event: chunk\ndata: {\"content\":\"streamed\"}", events[0])
+	assert.Equal(t, "event: chunk\ndata: {\"content\":\" answer\"}", events[1])
+	assert.True(t, strings.HasPrefix(events[2], "event: done\ndata: "), events[2])
+	assert.Contains(t, events[2], `"content":"streamed answer"`)
+
+	assert.Len(t, store.messages[id], 2)
+}
+
+func TestSessionMessageFailure(t *testing.T) {
+	store := newMemorySessions()
+	h := newTestSessionServer(store, &echoResponder{err: errors.New("model not found")})
+	id := createTestSession(t, h)
+
+	rec := doRequest(h, http.MethodPost, "/v1/sessions/"+id+"/messages", `{"content":"hello"}`)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Contains(t, rec.Body.String(), "model not found")
+
+	rec = doRequest(h, http.MethodPost, "/v1/sessions/"+id+"/messages?stream=true", `{"content":"hello"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "event: error\n"), rec.Body.String())
+
+	// Unanswered questions are not stored
+	assert.Empty(t, store.messages[id])
+}
+
+func TestSessionMessagesAreSerialized(t *testing.T) {
+	store := newMemorySessions()
+	responder := &echoResponder{}
+	h := newTestSessionServer(store, responder)
+	id := createTestSession(t, h)
+
+	const turns = 10
+	var wg sync.WaitGroup
+	for i := 0; i < turns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doRequest(h, http.MethodPost, "/v1/sessions/"+id+"/messages", fmt.Sprintf(`{"content":"question %d"}`, i))
+		}(i)
+	}
+	wg.Wait()
+
+	// Each turn saw all of the turns answered before it
+	seen := make(map[int]bool)
+	for _, history := range responder.histories {
+		seen[len(history)] = true
+	}
+	for i := 0; i < turns; i++ {
+		assert.True(t, seen[2*i], "no turn was answered with %d messages of history", 2*i)
+	}
+	assert.Len(t, store.messages[id], 2*turns)
+}
+
+func TestDeleteSession(t *testing.T) {
+	store := newMemorySessions()
+	h := newTestSessionServer(store, &echoResponder{})
+	id := createTestSession(t, h)
+
+	assert.Equal(t, http.StatusNoContent, doRequest(h, http.MethodDelete, "/v1/sessions/"+id, "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodDelete, "/v1/sessions/"+id, "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(h, http.MethodGet, "/v1/sessions/"+id, "").Code)
+}
+
+func TestRunEviction(t *testing.T) {
+	store := newMemorySessions()
+	api := NewSessionAPI(store, &echoResponder{}, resolveTestCollection, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		api.RunEviction(ctx, 5*time.Millisecond, nil)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return store.evicted.Load() >= 2 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
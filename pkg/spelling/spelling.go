@@ -0,0 +1,167 @@
+package spelling
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+// minWordLength is the length of the shortest word that is spell checked
+const minWordLength = 4
+
+// correctionPrompt asks the chat model to correct the spelling of a search query
+const correctionPrompt = `Correct the spelling mistakes in the search query below.
+Keep technical terms, product names, acronyms and code identifiers that are spelled correctly unchanged.
+Reply with the corrected query only, or with the query unchanged if it has no mistakes.
+
+Query: %s`
+
+// wordPattern matches the words of a query
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// Service corrects misspelled words in search queries
+type Service struct {
+	vocabulary database.VocabularyManager
+	chat       client.Client
+	config     *config.SpellCheckConfig
+}
+
+// New creates a new spell check service. The vocabulary manager is used in
+// vocabulary mode and the chat client in llm mode; the other may be nil.
+func New(vocabulary database.VocabularyManager, chat client.Client, config *config.SpellCheckConfig) *Service {
+	return &Service{
+		vocabulary: vocabulary,
+		chat:       chat,
+		config:     config,
+	}
+}
+
+// AutoCorrect reports whether searches should use the corrected query
+// instead of only suggesting it
+func (s *Service) AutoCorrect() bool {
+	return s.config.AutoCorrect
+}
+
+// Correct returns the query with its misspelled words corrected, or the
+// query unchanged when no corrections are found
+func (s *Service) Correct(ctx context.Context, collectionID, query string) (string, error) {
+	switch s.config.GetMode() {
+	case config.SpellCheckModeLLM:
+		return s.correctWithLLM(ctx, query)
+	default:
+		return s.correctWithVocabulary(collectionID, query)
+	}
+}
+
+// correctWithVocabulary replaces each word that is not in the collection's
+// vocabulary with the most similar term that is
+func (s *Service) correctWithVocabulary(collectionID, query string) (string, error) {
+	locations := wordPattern.FindAllStringIndex(query, -1)
+
+	var words []string
+	for _, loc := range locations {
+		if word := query[loc[0]:loc[1]]; checkable(word) {
+			words = append(words, strings.ToLower(word))
+		}
+	}
+	if len(words) == 0 {
+		return query, nil
+	}
+
+	known, err := s.vocabulary.KnownTerms(collectionID, words)
+	if err != nil {
+		return query, err
+	}
+
+	// Look up each unknown word once, even if it's repeated
+	corrections := make(map[string]string)
+	for _, word := range words {
+		if _, done := corrections[word]; done || known[word] {
+			continue
+		}
+		nearest, err := s.vocabulary.NearestTerm(collectionID, word, s.config.GetMinSimilarity())
+		if err != nil {
+			return query, err
+		}
+		corrections[word] = nearest
+	}
+
+	// Replace from the end so earlier locations stay valid
+	corrected := query
+	for i := len(locations) - 1; i >= 0; i-- {
+		loc := locations[i]
+		word := query[loc[0]:loc[1]]
+		if replacement := corrections[strings.ToLower(word)]; replacement != "" {
+			corrected = corrected[:loc[0]] + matchCase(word, replacement) + corrected[loc[1]:]
+		}
+	}
+
+	return corrected, nil
+}
+
+// correctWithLLM asks the chat model to correct the query
+func (s *Service) correctWithLLM(ctx context.Context, query string) (string, error) {
+	messages := []client.Message{
+		{Role: "system", Content: "You correct spelling mistakes in search queries."},
+		{Role: "user", Content: fmt.Sprintf(correctionPrompt, query)},
+	}
+
+	response, err := s.chat.Chat(ctx, s.config.Model, messages, false)
+	if err != nil {
+		return query, fmt.Errorf("failed to correct query: %w", err)
+	}
+
+	corrected := parseCorrection(response.Message.Content)
+	if corrected == "" {
+		return query, nil
+	}
+	return corrected, nil
+}
+
+// parseCorrection extracts the corrected query from a model response
+func parseCorrection(text string) string {
+	text = client.StripThinking(text)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimPrefix(line, "Query:")
+		line = strings.Trim(strings.TrimSpace(line), `"'`)
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// checkable reports whether a word should be spell checked. Short words and
+// words with digits are mostly acronyms, versions or identifiers.
+func checkable(word string) bool {
+	if utf8.RuneCountInString(word) < minWordLength {
+		return false
+	}
+	for _, r := range word {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCase gives a replacement the capitalization of the word it replaces
+func matchCase(word, replacement string) string {
+	if strings.ToUpper(word) == word {
+		return strings.ToUpper(replacement)
+	}
+	first, _ := utf8.DecodeRuneInString(word)
+	if unicode.IsUpper(first) {
+		r, size := utf8.DecodeRuneInString(replacement)
+		return string(unicode.ToUpper(r)) + replacement[size:]
+	}
+	return replacement
+}
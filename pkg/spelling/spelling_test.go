@@ -0,0 +1,81 @@
+package spelling
+
+import (
+	"context"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockVocabulary is an in-memory collection vocabulary with fixed nearest terms
+type mockVocabulary struct {
+	database.VocabularyManager
+	terms   map[string]bool
+	nearest map[string]string
+	lookups []string
+}
+
+func (m *mockVocabulary) KnownTerms(collectionID string, terms []string) (map[string]bool, error) {
+	known := make(map[string]bool)
+	for _, term := range terms {
+		if m.terms[term] {
+			known[term] = true
+		}
+	}
+	return known, nil
+}
+
+func (m *mockVocabulary) NearestTerm(collectionID, term string, minSimilarity float64) (string, error) {
+	m.lookups = append(m.lookups, term)
+	return m.nearest[term], nil
+}
+
+// mockChat returns a fixed chat response
+type mockChat struct {
+	client.Client
+	response string
+}
+
+func (m *mockChat) Chat(ctx context.Context, model string, messages []client.Message, stream bool) (*client.ChatResponse, error) {
+	return &client.ChatResponse{Message: client.Message{Role: "assistant", Content: m.response}}, nil
+}
+
+func TestCorrectWithVocabulary(t *testing.T) {
+	vocabulary := &mockVocabulary{
+		terms:   map[string]bool{"connection": true, "pooling": true},
+		nearest: map[string]string{"postgre": "postgres", "conection": "connection"},
+	}
+	service := New(vocabulary, nil, &config.SpellCheckConfig{Mode: config.SpellCheckModeVocabulary})
+
+	corrected, err := service.Correct(context.Background(), "collection", "Postgre conection pooling, CONECTION limits v2 API")
+	require.NoError(t, err)
+	assert.Equal(t, "Postgres connection pooling, CONNECTION limits v2 API", corrected)
+
+	// Known, short and repeated words are not looked up
+	assert.Equal(t, []string{"postgre", "conection", "limits"}, vocabulary.lookups)
+}
+
+func TestCorrectWithLLM(t *testing.T) {
+	chat := &mockChat{response: "<think>\nfix the typo\n</think>\n\"kubernetes ingress\"\n"}
+	service := New(nil, chat, &config.SpellCheckConfig{Mode: config.SpellCheckModeLLM})
+
+	corrected, err := service.Correct(context.Background(), "collection", "kuberentes ingress")
+	require.NoError(t, err)
+	assert.Equal(t, "kubernetes ingress", corrected)
+
+	// An empty response leaves the query unchanged
+	chat.response = "\n"
+	corrected, err = service.Correct(context.Background(), "collection", "kuberentes ingress")
+	require.NoError(t, err)
+	assert.Equal(t, "kuberentes ingress", corrected)
+}
+
+func TestMatchCase(t *testing.T) {
+	assert.Equal(t, "postgres", matchCase("postgre", "postgres"))
+	assert.Equal(t, "Postgres", matchCase("Postgre", "postgres"))
+	assert.Equal(t, "POSTGRES", matchCase("POSTGRE", "postgres"))
+}
@@ -0,0 +1,313 @@
+// Package telemetry keeps anonymous, opt-in usage statistics: how often each
+// command runs, the classes of errors it fails with and how long it takes.
+// Neither arguments, flags, paths, queries, document content nor error
+// messages are recorded. The statistics are kept in the data directory and
+// sent at most once a day, when telemetry is enabled and an endpoint is set.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/lib/pq"
+)
+
+// DefaultEndpoint is where reports are sent when the settings don't name an
+// endpoint. It is empty unless set at build time with
+// -ldflags "-X github.com/busybytelab.com/rag-cli/pkg/telemetry.DefaultEndpoint=<url>".
+var DefaultEndpoint = ""
+
+const (
+	// maxSamples is the number of recent durations kept per command for percentiles
+	maxSamples = 200
+	// reportInterval is how often reports are sent
+	reportInterval = 24 * time.Hour
+)
+
+// Store is the usage statistics kept between commands
+type Store struct {
+	InstallID   string                    `json:"install_id"` // Random, not derived from the machine
+	PeriodStart time.Time                 `json:"period_start"`
+	LastSent    time.Time                 `json:"last_sent,omitempty"`
+	Commands    map[string]*CommandCounts `json:"commands"`
+}
+
+// CommandCounts is the usage of one command
+type CommandCounts struct {
+	Count       int            `json:"count"`
+	Errors      map[string]int `json:"errors,omitempty"` // By error class
+	DurationsMS []int64        `json:"durations_ms"`     // Most recent durations
+}
+
+// Payload is a report exactly as it is sent
+type Payload struct {
+	InstallID   string                   `json:"install_id"`
+	Version     string                   `json:"version"`
+	OS          string                   `json:"os"`
+	Arch        string                   `json:"arch"`
+	PeriodStart time.Time                `json:"period_start"`
+	PeriodEnd   time.Time                `json:"period_end"`
+	Commands    map[string]CommandReport `json:"commands"`
+}
+
+// CommandReport is the reported usage of one command
+type CommandReport struct {
+	Count  int            `json:"count"`
+	Errors map[string]int `json:"errors,omitempty"`
+	P50MS  int64          `json:"p50_ms"`
+	P90MS  int64          `json:"p90_ms"`
+	P99MS  int64          `json:"p99_ms"`
+}
+
+// Recorder records command usage in a file and sends reports of it
+type Recorder struct {
+	path     string
+	endpoint string
+	version  string
+	client   *http.Client
+	now      func() time.Time
+}
+
+// New creates a recorder that keeps statistics in path and sends reports to
+// endpoint, or DefaultEndpoint when it is empty
+func New(path, endpoint, version string) *Recorder {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	return &Recorder{
+		path:     path,
+		endpoint: endpoint,
+		version:  version,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// Endpoint returns where reports are sent, or "" when they aren't sent
+func (r *Recorder) Endpoint() string {
+	return r.endpoint
+}
+
+// DoNotTrack reports whether the DO_NOT_TRACK environment variable turns
+// telemetry off, whatever the settings
+func DoNotTrack() bool {
+	value := os.Getenv("DO_NOT_TRACK")
+	return value != "" && value != "0" && value != "false"
+}
+
+// Record adds a run of command to the statistics
+func (r *Recorder) Record(command string, duration time.Duration, runErr error) error {
+	store, err := r.Load()
+	if err != nil {
+		return err
+	}
+
+	counts := store.Commands[command]
+	if counts == nil {
+		counts = &CommandCounts{}
+		store.Commands[command] = counts
+	}
+	counts.Count++
+	if runErr != nil {
+		if counts.Errors == nil {
+			counts.Errors = make(map[string]int)
+		}
+		counts.Errors[ErrorClass(runErr)]++
+	}
+	counts.DurationsMS = append(counts.DurationsMS, duration.Milliseconds())
+	if len(counts.DurationsMS) > maxSamples {
+		counts.DurationsMS = counts.DurationsMS[len(counts.DurationsMS)-maxSamples:]
+	}
+	return r.save(store)
+}
+
+// Payload returns the report the statistics would be sent as
+func (r *Recorder) Payload(store *Store) *Payload {
+	payload := &Payload{
+		InstallID:   store.InstallID,
+		Version:     r.version,
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		PeriodStart: store.PeriodStart,
+		PeriodEnd:   r.now().UTC(),
+		Commands:    make(map[string]CommandReport, len(store.Commands)),
+	}
+	for name, counts := range store.Commands {
+		payload.Commands[name] = CommandReport{
+			Count:  counts.Count,
+			Errors: counts.Errors,
+			P50MS:  percentile(counts.DurationsMS, 50),
+			P90MS:  percentile(counts.DurationsMS, 90),
+			P99MS:  percentile(counts.DurationsMS, 99),
+		}
+	}
+	return payload
+}
+
+// SendIfDue sends a report when the last one is a day old and there is
+// something to report. The statistics start over once they are sent.
+func (r *Recorder) SendIfDue(ctx context.Context) error {
+	if r.endpoint == "" {
+		return nil
+	}
+	store, err := r.Load()
+	if err != nil {
+		return err
+	}
+	last := store.LastSent
+	if last.IsZero() {
+		last = store.PeriodStart
+	}
+	if len(store.Commands) == 0 || r.now().Sub(last) < reportInterval {
+		return nil
+	}
+
+	body, err := json.Marshal(r.Payload(store))
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry report: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry report: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send telemetry report: %s", resp.Status)
+	}
+
+	store.Commands = make(map[string]*CommandCounts)
+	store.PeriodStart = r.now().UTC()
+	store.LastSent = store.PeriodStart
+	return r.save(store)
+}
+
+// Load reads the statistics, starting new ones with a new install ID when
+// there are none
+func (r *Recorder) Load() (*Store, error) {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		id, err := newInstallID()
+		if err != nil {
+			return nil, err
+		}
+		return &Store{InstallID: id, PeriodStart: r.now().UTC(), Commands: make(map[string]*CommandCounts)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read telemetry: %w", err)
+	}
+
+	var store Store
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("failed to read telemetry from %s: %w", r.path, err)
+	}
+	if store.Commands == nil {
+		store.Commands = make(map[string]*CommandCounts)
+	}
+	return &store, nil
+}
+
+// Forget deletes the statistics and the install ID
+func (r *Recorder) Forget() error {
+	if err := os.Remove(r.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete telemetry: %w", err)
+	}
+	return nil
+}
+
+// save replaces the statistics file
+func (r *Recorder) save(store *Store) error {
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create telemetry directory: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write telemetry: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write telemetry: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write telemetry: %w", err)
+	}
+	if err := os.Rename(temp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write telemetry: %w", err)
+	}
+	return nil
+}
+
+// ErrorClass returns the class of an error, which is reported instead of
+// its message
+func ErrorClass(err error) string {
+	var (
+		pqErr  *pq.Error
+		netErr net.Error
+	)
+	switch {
+	case errors.Is(err, client.ErrBudgetExceeded):
+		return "budget"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &pqErr):
+		return "database"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, os.ErrPermission):
+		return "filesystem"
+	default:
+		return "other"
+	}
+}
+
+// percentile returns the p-th percentile of durations, by the nearest rank
+func percentile(durations []int64, p int) int64 {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// newInstallID returns a random ID that tells reports of one installation
+// apart from others without identifying it
+func newInstallID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate install ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,109 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	recorder := New(filepath.Join(t.TempDir(), "telemetry.json"), "", "1.2.3")
+
+	for i := 1; i <= 10; i++ {
+		require.NoError(t, recorder.Record("search", time.Duration(i)*100*time.Millisecond, nil))
+	}
+	require.NoError(t, recorder.Record("index", time.Second, fmt.Errorf("failed to index: %w", context.DeadlineExceeded)))
+
+	store, err := recorder.Load()
+	require.NoError(t, err)
+	assert.Len(t, store.InstallID, 32)
+
+	payload := recorder.Payload(store)
+	assert.Equal(t, "1.2.3", payload.Version)
+	assert.Equal(t, CommandReport{Count: 10, P50MS: 500, P90MS: 900, P99MS: 1000}, payload.Commands["search"])
+	assert.Equal(t, map[string]int{"timeout": 1}, payload.Commands["index"].Errors)
+
+	// The install ID is kept between runs
+	again, err := recorder.Load()
+	require.NoError(t, err)
+	assert.Equal(t, store.InstallID, again.InstallID)
+}
+
+func TestRecordKeepsRecentDurations(t *testing.T) {
+	recorder := New(filepath.Join(t.TempDir(), "telemetry.json"), "", "dev")
+	for i := 0; i < maxSamples+10; i++ {
+		require.NoError(t, recorder.Record("chat", time.Millisecond, nil))
+	}
+
+	store, err := recorder.Load()
+	require.NoError(t, err)
+	assert.Equal(t, maxSamples+10, store.Commands["chat"].Count)
+	assert.Len(t, store.Commands["chat"].DurationsMS, maxSamples)
+}
+
+func TestSendIfDue(t *testing.T) {
+	var received []Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received = append(received, payload)
+	}))
+	defer server.Close()
+
+	now := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+	recorder := New(filepath.Join(t.TempDir(), "telemetry.json"), server.URL, "dev")
+	recorder.now = func() time.Time { return now }
+
+	require.NoError(t, recorder.Record("search", time.Second, nil))
+	require.NoError(t, recorder.SendIfDue(context.Background()))
+	assert.Empty(t, received, "nothing is sent before a day has passed")
+
+	now = now.Add(25 * time.Hour)
+	require.NoError(t, recorder.SendIfDue(context.Background()))
+	require.Len(t, received, 1)
+	assert.Equal(t, 1, received[0].Commands["search"].Count)
+
+	// The statistics start over once sent
+	store, err := recorder.Load()
+	require.NoError(t, err)
+	assert.Empty(t, store.Commands)
+	require.NoError(t, recorder.SendIfDue(context.Background()))
+	assert.Len(t, received, 1)
+}
+
+func TestForget(t *testing.T) {
+	recorder := New(filepath.Join(t.TempDir(), "telemetry.json"), "", "dev")
+	require.NoError(t, recorder.Record("search", time.Second, nil))
+	before, err := recorder.Load()
+	require.NoError(t, err)
+
+	require.NoError(t, recorder.Forget())
+	after, err := recorder.Load()
+	require.NoError(t, err)
+	assert.Empty(t, after.Commands)
+	assert.NotEqual(t, before.InstallID, after.InstallID)
+	assert.NoError(t, recorder.Forget(), "forgetting twice is fine")
+}
+
+func TestErrorClass(t *testing.T) {
+	assert.Equal(t, "budget", ErrorClass(fmt.Errorf("failed: %w", client.ErrBudgetExceeded)))
+	assert.Equal(t, "canceled", ErrorClass(context.Canceled))
+	assert.Equal(t, "other", ErrorClass(errors.New("secret message")))
+}
+
+func TestDoNotTrack(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "1")
+	assert.True(t, DoNotTrack())
+	t.Setenv("DO_NOT_TRACK", "0")
+	assert.False(t, DoNotTrack())
+}
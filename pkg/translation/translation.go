@@ -0,0 +1,95 @@
+// Package translation translates search queries into the language of a
+// collection's documents with the chat model, and retrieved documents back
+// into the language of the question, so questions can be answered from
+// documents written in another language.
+package translation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+// queryPrompt asks the chat model to translate a search query
+const queryPrompt = `Translate the search query below into %s.
+Keep names, code, file names and technical terms that are usually left untranslated as they are.
+If the query is already in %s, repeat it unchanged. Reply with the translation only.
+
+Query: %s`
+
+// documentPrompt asks the chat model to translate a retrieved document into
+// the language of the question it was retrieved for
+const documentPrompt = `Translate the text below into the language of this question: %q
+Keep names, code, file names and formatting as they are. If the text is already in that language,
+repeat it unchanged. Reply with the translation only.
+
+Text:
+%s`
+
+// Service translates queries and documents with the chat model
+type Service struct {
+	chat   client.Client
+	config *config.TranslationConfig
+}
+
+// New creates a new translation service
+func New(chat client.Client, config *config.TranslationConfig) *Service {
+	return &Service{chat: chat, config: config}
+}
+
+// Language returns the language of the documents queries are translated into
+func (s *Service) Language() string {
+	return s.config.Language
+}
+
+// TranslatesDocuments reports whether retrieved documents are translated
+// back into the language of the question
+func (s *Service) TranslatesDocuments() bool {
+	return s.config.TranslateResults
+}
+
+// TranslateQuery translates a search query into the language of the
+// documents
+func (s *Service) TranslateQuery(ctx context.Context, query string) (string, error) {
+	language := s.config.Language
+	translation, err := s.translate(ctx, fmt.Sprintf(queryPrompt, language, language, query))
+	if err != nil {
+		return "", fmt.Errorf("failed to translate query: %w", err)
+	}
+	translation = strings.Trim(translation, `"'`)
+	if translation == "" {
+		return query, nil
+	}
+	return translation, nil
+}
+
+// TranslateDocument translates the text of a retrieved document into the
+// language of the question it was retrieved for
+func (s *Service) TranslateDocument(ctx context.Context, question, text string) (string, error) {
+	translation, err := s.translate(ctx, fmt.Sprintf(documentPrompt, question, text))
+	if err != nil {
+		return "", fmt.Errorf("failed to translate document: %w", err)
+	}
+	if translation == "" {
+		return text, nil
+	}
+	return translation, nil
+}
+
+// translate sends a translation request to the chat model and returns its
+// answer without reasoning blocks
+func (s *Service) translate(ctx context.Context, prompt string) (string, error) {
+	messages := []client.Message{
+		{Role: "system", Content: "You are a translator. You translate text faithfully, without explanations."},
+		{Role: "user", Content: prompt},
+	}
+
+	response, err := s.chat.Chat(ctx, s.config.Model, messages, false)
+	if err != nil {
+		return "", err
+	}
+	return client.StripThinking(response.Message.Content), nil
+}
@@ -0,0 +1,65 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/client"
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockChat returns a fixed chat response
+type mockChat struct {
+	client.Client
+	response string
+	err      error
+	model    string
+	messages []client.Message
+}
+
+func (m *mockChat) Chat(ctx context.Context, model string, messages []client.Message, stream bool) (*client.ChatResponse, error) {
+	m.model = model
+	m.messages = messages
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &client.ChatResponse{Message: client.Message{Role: "assistant", Content: m.response}}, nil
+}
+
+func TestTranslateQuery(t *testing.T) {
+	chat := &mockChat{response: "<think>\nGerman...\n</think>\n\"Wie setze ich das Passwort zurück?\"\n"}
+	service := New(chat, &config.TranslationConfig{Enabled: true, Language: "German", Model: "translator"})
+
+	translation, err := service.TranslateQuery(context.Background(), "How do I reset the password?")
+	require.NoError(t, err)
+	assert.Equal(t, "Wie setze ich das Passwort zurück?", translation)
+	assert.Equal(t, "translator", chat.model)
+	require.Len(t, chat.messages, 2)
+	assert.Contains(t, chat.messages[1].Content, "into German")
+	assert.Contains(t, chat.messages[1].Content, "Query: How do I reset the password?")
+
+	// An empty answer leaves the query as it is
+	chat.response = "<think></think>"
+	translation, err = service.TranslateQuery(context.Background(), "reset password")
+	require.NoError(t, err)
+	assert.Equal(t, "reset password", translation)
+
+	chat.err = errors.New("model not found")
+	_, err = service.TranslateQuery(context.Background(), "reset password")
+	assert.ErrorContains(t, err, "failed to translate query")
+}
+
+func TestTranslateDocument(t *testing.T) {
+	chat := &mockChat{response: "Run `passwd` to reset it."}
+	service := New(chat, &config.TranslationConfig{Enabled: true, Language: "German", TranslateResults: true})
+	assert.True(t, service.TranslatesDocuments())
+
+	translation, err := service.TranslateDocument(context.Background(), "How do I reset the password?", "Führe `passwd` aus.")
+	require.NoError(t, err)
+	assert.Equal(t, "Run `passwd` to reset it.", translation)
+	assert.Contains(t, chat.messages[1].Content, `question: "How do I reset the password?"`)
+	assert.Contains(t, chat.messages[1].Content, "Führe `passwd` aus.")
+}
@@ -0,0 +1,158 @@
+// Package tuning fits the ranking of a collection to the relevance judgments
+// collected for its search results. Every candidate setting of a grid is
+// searched for each judged query and scored by the mean reciprocal rank of
+// the first relevant file, so the tuned ranking is the one that puts a
+// relevant file first most often.
+package tuning
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+)
+
+// weightSteps is the number of steps of the vector weights tried between 0
+// and 1
+const weightSteps = 10
+
+// RecencyBoosts are the recency boost rules tried besides none
+var RecencyBoosts = []string{"recency:90d=+0.05", "recency:90d=+0.1", "recency:90d=+0.2"}
+
+// File is a file of a collection, by its folder and its path in the folder
+type File struct {
+	Folder string
+	Path   string
+}
+
+// Query is a judged query with the files judged relevant to it
+type Query struct {
+	Text     string
+	Relevant map[File]bool // The relevant files in the collection
+}
+
+// Settings are the ranking settings a collection is tuned over
+type Settings struct {
+	VectorWeight float64 `json:"vector_weight"`
+	TextWeight   float64 `json:"text_weight"`
+	Rerank       bool    `json:"rerank"`
+	Recency      string  `json:"recency,omitempty"` // Recency boost rule, or empty for none
+}
+
+// Outcome is how well settings rank the judged queries
+type Outcome struct {
+	Settings Settings `json:"settings"`
+	MRR      float64  `json:"mrr"`  // Mean reciprocal rank of the first relevant file
+	Hits     int      `json:"hits"` // Queries with a relevant file among their results
+}
+
+// Searcher searches for a judged query with settings
+type Searcher func(query Query, settings Settings) ([]*database.SearchResult, error)
+
+// Queries groups judgments by query, in the order the queries first appear.
+// Queries without a relevant file are left out, since no ranking can put
+// one first.
+func Queries(judgments []*database.Judgment) []Query {
+	var queries []Query
+	index := make(map[string]int)
+	for _, judgment := range judgments {
+		i, ok := index[judgment.Query]
+		if !ok {
+			i = len(queries)
+			index[judgment.Query] = i
+			queries = append(queries, Query{Text: judgment.Query, Relevant: make(map[File]bool)})
+		}
+		if judgment.Relevant {
+			queries[i].Relevant[File{Folder: judgment.Folder, Path: judgment.FilePath}] = true
+		}
+	}
+
+	judged := queries[:0]
+	for _, query := range queries {
+		if len(query.Relevant) > 0 {
+			judged = append(judged, query)
+		}
+	}
+	return judged
+}
+
+// Grid returns the settings tried: vector weights from 0 to 1 in steps of
+// 0.1 with the rest of the weight on text, each without and with every
+// recency boost, and all of them reranked too when rerank is set
+func Grid(rerank bool) []Settings {
+	reranks := []bool{false}
+	if rerank {
+		reranks = append(reranks, true)
+	}
+
+	var grid []Settings
+	for _, rerank := range reranks {
+		for _, recency := range append([]string{""}, RecencyBoosts...) {
+			for step := 0; step <= weightSteps; step++ {
+				grid = append(grid, Settings{
+					VectorWeight: float64(step) / weightSteps,
+					TextWeight:   float64(weightSteps-step) / weightSteps,
+					Rerank:       rerank,
+					Recency:      recency,
+				})
+			}
+		}
+	}
+	return grid
+}
+
+// Tune evaluates every settings of the grid and returns the outcomes, best
+// first. Settings that rank equally well keep their order in the grid, so
+// the current settings can be put first to keep them unless others rank
+// better.
+func Tune(search Searcher, queries []Query, grid []Settings) ([]Outcome, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no judged queries to tune with")
+	}
+
+	outcomes := make([]Outcome, 0, len(grid))
+	for _, settings := range grid {
+		outcome, err := Evaluate(search, queries, settings)
+		if err != nil {
+			return nil, err
+		}
+		outcomes = append(outcomes, outcome)
+	}
+
+	sort.SliceStable(outcomes, func(i, j int) bool { return outcomes[i].MRR > outcomes[j].MRR })
+	return outcomes, nil
+}
+
+// Evaluate searches for every query with settings and scores the rankings
+func Evaluate(search Searcher, queries []Query, settings Settings) (Outcome, error) {
+	outcome := Outcome{Settings: settings}
+	if len(queries) == 0 {
+		return outcome, nil
+	}
+
+	total := 0.0
+	for _, query := range queries {
+		results, err := search(query, settings)
+		if err != nil {
+			return outcome, fmt.Errorf("failed to search for %q: %w", query.Text, err)
+		}
+		rank := ReciprocalRank(results, query.Relevant)
+		if rank > 0 {
+			outcome.Hits++
+		}
+		total += rank
+	}
+	outcome.MRR = total / float64(len(queries))
+	return outcome, nil
+}
+
+// ReciprocalRank returns one over the rank of the first result from a
+// relevant file, or 0 when no result is
+func ReciprocalRank(results []*database.SearchResult, relevant map[File]bool) float64 {
+	for i, result := range results {
+		if relevant[File{Folder: result.Document.Folder, Path: result.Document.FilePath}] {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
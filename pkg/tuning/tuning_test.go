@@ -0,0 +1,97 @@
+package tuning
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// results returns search results from files
+func results(paths ...string) []*database.SearchResult {
+	var results []*database.SearchResult
+	for _, path := range paths {
+		results = append(results, &database.SearchResult{Document: &database.Document{FilePath: path}})
+	}
+	return results
+}
+
+func TestQueries(t *testing.T) {
+	queries := Queries([]*database.Judgment{
+		{Query: "reset password", FilePath: "auth.md", Relevant: true},
+		{Query: "invoices", FilePath: "auth.md", Relevant: false},
+		{Query: "reset password", FilePath: "billing.md", Relevant: false},
+		{Query: "reset password", Folder: "/wiki", FilePath: "auth.md", Relevant: true},
+		{Query: "refunds", FilePath: "billing.md", Relevant: true},
+	})
+
+	require.Len(t, queries, 2, "queries without a relevant file should be left out")
+	assert.Equal(t, Query{Text: "reset password", Relevant: map[File]bool{{Path: "auth.md"}: true, {Folder: "/wiki", Path: "auth.md"}: true}}, queries[0])
+	assert.Equal(t, "refunds", queries[1].Text)
+}
+
+func TestGrid(t *testing.T) {
+	grid := Grid(false)
+	require.Len(t, grid, 11*(1+len(RecencyBoosts)))
+	assert.Equal(t, Settings{VectorWeight: 0, TextWeight: 1}, grid[0])
+	assert.Equal(t, Settings{VectorWeight: 0.7, TextWeight: 0.3}, grid[7])
+	assert.Equal(t, Settings{VectorWeight: 1, TextWeight: 0, Recency: RecencyBoosts[len(RecencyBoosts)-1]}, grid[len(grid)-1])
+	for _, settings := range grid {
+		assert.False(t, settings.Rerank)
+		assert.InDelta(t, 1, settings.VectorWeight+settings.TextWeight, 1e-9)
+	}
+
+	reranked := Grid(true)
+	require.Len(t, reranked, 2*len(grid))
+	assert.True(t, reranked[len(reranked)-1].Rerank)
+}
+
+func TestReciprocalRank(t *testing.T) {
+	relevant := map[File]bool{{Path: "auth.md"}: true, {Path: "sso.md"}: true}
+	assert.Equal(t, 1.0, ReciprocalRank(results("auth.md", "billing.md"), relevant))
+	assert.Equal(t, 1.0/3, ReciprocalRank(results("billing.md", "faq.md", "sso.md", "auth.md"), relevant))
+	assert.Zero(t, ReciprocalRank(results("billing.md"), relevant))
+	assert.Zero(t, ReciprocalRank(nil, relevant))
+
+	// A file with the same path in another folder isn't the relevant one
+	elsewhere := results("auth.md")
+	elsewhere[0].Document.Folder = "/wiki"
+	assert.Zero(t, ReciprocalRank(elsewhere, relevant))
+}
+
+func TestTune(t *testing.T) {
+	queries := []Query{
+		{Text: "reset password", Relevant: map[File]bool{{Path: "auth.md"}: true}},
+		{Text: "refunds", Relevant: map[File]bool{{Path: "billing.md"}: true}},
+	}
+	// Text-heavy weights find both files first, vector-heavy ones second
+	search := func(query Query, settings Settings) ([]*database.SearchResult, error) {
+		var relevant string
+		for file := range query.Relevant {
+			relevant = file.Path
+		}
+		if settings.TextWeight > settings.VectorWeight {
+			return results(relevant, "faq.md"), nil
+		}
+		return results("faq.md", relevant), nil
+	}
+	current := Settings{VectorWeight: 0.7, TextWeight: 0.3}
+	text := Settings{VectorWeight: 0.3, TextWeight: 0.7}
+	equal := Settings{VectorWeight: 0.3, TextWeight: 0.7, Recency: RecencyBoosts[0]}
+
+	outcomes, err := Tune(search, queries, []Settings{current, text, equal})
+	require.NoError(t, err)
+	require.Len(t, outcomes, 3)
+	assert.Equal(t, Outcome{Settings: text, MRR: 1, Hits: 2}, outcomes[0], "ties should keep the grid order")
+	assert.Equal(t, equal, outcomes[1].Settings)
+	assert.Equal(t, Outcome{Settings: current, MRR: 0.5, Hits: 2}, outcomes[2])
+
+	_, err = Tune(search, nil, Grid(false))
+	assert.Error(t, err, "Expected an error without judged queries")
+
+	failing := func(Query, Settings) ([]*database.SearchResult, error) { return nil, errors.New("connection refused") }
+	_, err = Tune(failing, queries, Grid(false))
+	assert.ErrorContains(t, err, "reset password")
+}
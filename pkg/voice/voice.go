@@ -0,0 +1,242 @@
+// Package voice lets chat be used hands-free: it records spoken questions and
+// transcribes them with a Whisper server, and speaks answers with a
+// text-to-speech server. Both servers are reached through the
+// OpenAI-compatible audio endpoints, and audio is recorded and played by
+// external commands such as sox's rec and play.
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+)
+
+var (
+	// codeBlockPattern matches fenced code blocks, which aren't read aloud
+	codeBlockPattern = regexp.MustCompile("(?s)```.*?(```|$)")
+	// linkPattern matches Markdown links, whose text is read without the URL
+	linkPattern = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	// markupPattern matches Markdown emphasis, inline code and heading and
+	// quote markers
+	markupPattern = regexp.MustCompile("(?m)[*_`~]+|^[ \\t]*[#>]+[ \\t]*")
+	// blankLinesPattern matches the blank lines left where code blocks were
+	blankLinesPattern = regexp.MustCompile(`\n\s*\n`)
+)
+
+// Service records and transcribes questions and speaks answers
+type Service struct {
+	config *config.VoiceConfig
+	http   *http.Client
+}
+
+// transcription is the response of the transcription endpoint
+type transcription struct {
+	Text string `json:"text"`
+}
+
+// speechRequest is the request of the speech endpoint
+type speechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format"`
+}
+
+// New creates a new voice service
+func New(config *config.VoiceConfig) *Service {
+	return &Service{
+		config: config,
+		http:   &http.Client{Timeout: config.GetTimeout()},
+	}
+}
+
+// Listen records a spoken question with the record command and returns its
+// transcription
+func (s *Service) Listen(ctx context.Context) (string, error) {
+	if s.config.RecordCommand == "" || s.config.TranscriptionURL == "" {
+		return "", fmt.Errorf("voice input needs voice.record_command and voice.transcription_url")
+	}
+
+	path, err := tempAudioFile(nil)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	if err := runCommand(ctx, s.config.RecordCommand, path); err != nil {
+		return "", fmt.Errorf("failed to record speech: %w", err)
+	}
+	return s.Transcribe(ctx, path)
+}
+
+// Transcribe returns the text spoken in an audio file
+func (s *Service) Transcribe(ctx context.Context, audioPath string) (string, error) {
+	audio, err := os.ReadFile(audioPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return "", fmt.Errorf("failed to create transcription request: %w", err)
+	}
+	if _, err := file.Write(audio); err != nil {
+		return "", fmt.Errorf("failed to create transcription request: %w", err)
+	}
+	fields := map[string]string{"model": s.config.TranscriptionModel, "response_format": "json"}
+	if s.config.Language != "" {
+		fields["language"] = s.config.Language
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return "", fmt.Errorf("failed to create transcription request: %w", err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("failed to create transcription request: %w", err)
+	}
+
+	response, err := s.post(ctx, s.config.TranscriptionURL, form.FormDataContentType(), &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to transcribe speech: %w", err)
+	}
+
+	var result transcription
+	if err := json.Unmarshal(response, &result); err != nil {
+		return "", fmt.Errorf("failed to parse transcription: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// Speak reads text aloud with the text-to-speech server and the play
+// command, leaving out the Markdown that can't be spoken
+func (s *Service) Speak(ctx context.Context, text string) error {
+	if s.config.PlayCommand == "" || s.config.SpeechURL == "" {
+		return fmt.Errorf("voice output needs voice.speech_url and voice.play_command")
+	}
+	text = SpeakableText(text)
+	if text == "" {
+		return nil
+	}
+
+	audio, err := s.Synthesize(ctx, text)
+	if err != nil {
+		return err
+	}
+	path, err := tempAudioFile(audio)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	if err := runCommand(ctx, s.config.PlayCommand, path); err != nil {
+		return fmt.Errorf("failed to play speech: %w", err)
+	}
+	return nil
+}
+
+// Synthesize returns text spoken in the configured voice as WAV audio
+func (s *Service) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	body, err := json.Marshal(speechRequest{
+		Model:          s.config.SpeechModel,
+		Input:          text,
+		Voice:          s.config.Voice,
+		ResponseFormat: "wav",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speech request: %w", err)
+	}
+
+	audio, err := s.post(ctx, s.config.SpeechURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize speech: %w", err)
+	}
+	return audio, nil
+}
+
+// SpeakableText returns text as it is read aloud: without code blocks, link
+// URLs and Markdown markup
+func SpeakableText(text string) string {
+	text = codeBlockPattern.ReplaceAllString(text, "")
+	text = linkPattern.ReplaceAllString(text, "$1")
+	text = markupPattern.ReplaceAllString(text, "")
+	text = blankLinesPattern.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// post sends a request to an audio endpoint and returns the response body
+func (s *Service) post(ctx context.Context, url, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.APIKey)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status code %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// tempAudioFile creates a temporary WAV file with the given audio
+func tempAudioFile(audio []byte) (string, error) {
+	file, err := os.CreateTemp("", "rag-cli-voice-*.wav")
+	if err != nil {
+		return "", fmt.Errorf("failed to create audio file: %w", err)
+	}
+	_, err = file.Write(audio)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write audio file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// runCommand runs a record or play command with the shell, with the path of
+// the audio file in place of {file}
+func runCommand(ctx context.Context, command, path string) error {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	command = strings.ReplaceAll(command, config.FilePlaceholder, path)
+
+	out, err := exec.CommandContext(ctx, shell, flag, command).CombinedOutput()
+	if err != nil {
+		if message := strings.TrimSpace(string(out)); message != "" {
+			return fmt.Errorf("%w: %s", err, message)
+		}
+		return err
+	}
+	return nil
+}
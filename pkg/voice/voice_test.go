@@ -0,0 +1,89 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/busybytelab.com/rag-cli/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		audio, _ := io.ReadAll(file)
+		assert.Equal(t, "RIFF", string(audio))
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		assert.Equal(t, "de", r.FormValue("language"))
+		w.Write([]byte(`{"text": " How do I install it? "}`))
+	}))
+	defer server.Close()
+
+	service := New(&config.VoiceConfig{
+		TranscriptionURL:   server.URL,
+		TranscriptionModel: "whisper-1",
+		Language:           "de",
+		RecordCommand:      "printf RIFF > {file}",
+		APIKey:             "secret",
+	})
+	text, err := service.Listen(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "How do I install it?", text)
+}
+
+func TestListenRecordError(t *testing.T) {
+	service := New(&config.VoiceConfig{TranscriptionURL: "http://localhost:1", RecordCommand: "echo no microphone >&2; exit 1 # {file}"})
+	_, err := service.Listen(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no microphone")
+}
+
+func TestSpeak(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request speechRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, speechRequest{Model: "tts-1", Input: "Run the install command.", Voice: "alloy", ResponseFormat: "wav"}, request)
+		w.Write([]byte("WAVE"))
+	}))
+	defer server.Close()
+
+	played := filepath.Join(t.TempDir(), "played.wav")
+	service := New(&config.VoiceConfig{
+		SpeechURL:   server.URL,
+		SpeechModel: "tts-1",
+		Voice:       "alloy",
+		PlayCommand: "cp {file} " + played,
+	})
+	require.NoError(t, service.Speak(context.Background(), "Run the **install** command.\n\n```sh\nmake install\n```"))
+
+	audio, err := os.ReadFile(played)
+	require.NoError(t, err)
+	assert.Equal(t, "WAVE", string(audio))
+}
+
+func TestSynthesizeServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown voice", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	service := New(&config.VoiceConfig{SpeechURL: server.URL})
+	_, err := service.Synthesize(context.Background(), "Hello")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "400")
+	assert.Contains(t, err.Error(), "unknown voice")
+}
+
+func TestSpeakableText(t *testing.T) {
+	text := "## Install\n\nSee [the guide](https://example.com) and run `make`:\n\n```\nmake\n```\n> _Note:_ it takes a while."
+	assert.Equal(t, "Install\n\nSee the guide and run make:\n\nNote: it takes a while.", SpeakableText(text))
+}
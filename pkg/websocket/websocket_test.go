@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer accepts a WebSocket handshake and hands the raw connection to fn
+func testServer(t *testing.T, fn func(c *Conn)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		hijacker, ok := w.(http.Hijacker)
+		require.True(t, ok)
+		conn, rw, err := hijacker.Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+		rw.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+
+		// Server frames are written unmasked, which readFrame accepts
+		fn(&Conn{conn: conn, reader: bufio.NewReader(rw)})
+	}))
+}
+
+// writeServerFrame writes an unmasked frame as a server would
+func writeServerFrame(t *testing.T, c *Conn, fin bool, opcode byte, payload string) {
+	head := opcode
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head, byte(len(payload))}
+	frame = append(frame, payload...)
+	_, err := c.conn.Write(frame)
+	require.NoError(t, err)
+}
+
+func dialTestServer(t *testing.T, server *httptest.Server) *Conn {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/socket?ticket=1", http.Header{"Authorization": {"Bearer token"}})
+	require.NoError(t, err)
+	return conn
+}
+
+func TestReadAndWriteMessages(t *testing.T) {
+	pong := make(chan string, 1)
+	server := testServer(t, func(c *Conn) {
+		// A ping and a fragmented message
+		writeServerFrame(t, c, true, opPing, "are you there")
+		writeServerFrame(t, c, false, opText, "hello ")
+		writeServerFrame(t, c, true, opContinuation, "world")
+
+		_, opcode, payload, err := c.readFrame()
+		require.NoError(t, err)
+		assert.Equal(t, byte(opPong), opcode)
+		pong <- string(payload)
+
+		// Echo a message from the client
+		_, opcode, payload, err = c.readFrame()
+		require.NoError(t, err)
+		assert.Equal(t, byte(opText), opcode)
+		writeServerFrame(t, c, true, opText, "echo: "+string(payload))
+
+		writeServerFrame(t, c, true, opClose, "")
+	})
+	defer server.Close()
+
+	conn := dialTestServer(t, server)
+	defer conn.Close()
+
+	message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(message))
+	assert.Equal(t, "are you there", <-pong)
+
+	require.NoError(t, conn.WriteMessage([]byte(`{"envelope_id":"1"}`)))
+	message, err = conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, `echo: {"envelope_id":"1"}`, string(message))
+
+	_, err = conn.ReadMessage()
+	assert.True(t, errors.Is(err, ErrClosed), "expected ErrClosed, got %v", err)
+}
+
+func TestWriteFrameLengths(t *testing.T) {
+	sizes := []int{0, 125, 126, 70000}
+	received := make(chan int, len(sizes))
+	server := testServer(t, func(c *Conn) {
+		for range sizes {
+			_, _, payload, err := c.readFrame()
+			require.NoError(t, err)
+			for _, b := range payload {
+				require.Equal(t, byte('x'), b)
+			}
+			received <- len(payload)
+		}
+	})
+	defer server.Close()
+
+	conn := dialTestServer(t, server)
+	defer conn.Close()
+
+	for _, size := range sizes {
+		require.NoError(t, conn.WriteMessage([]byte(strings.Repeat("x", size))))
+		assert.Equal(t, size, <-received)
+	}
+}
+
+func TestDialRejectsFailedHandshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid ticket", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid ticket")
+
+	_, err = Dial(context.Background(), server.URL, nil)
+	assert.Error(t, err)
+}
+
+func TestCloseCode(t *testing.T) {
+	server := testServer(t, func(c *Conn) {
+		writeServerFrame(t, c, true, opClose, "\x0f\xa4Authentication failed.")
+	})
+	defer server.Close()
+
+	conn := dialTestServer(t, server)
+	defer conn.Close()
+
+	_, err := conn.ReadMessage()
+	var closeErr *CloseError
+	require.True(t, errors.As(err, &closeErr), "expected a CloseError, got %v", err)
+	assert.Equal(t, 4004, closeErr.Code)
+	assert.Equal(t, "Authentication failed.", closeErr.Reason)
+	assert.True(t, errors.Is(err, ErrClosed))
+}